@@ -0,0 +1,105 @@
+package file
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// WithAccept makes NewFromURL negotiate the representation it downloads.
+// mediaTypes are listed most preferred first and sent as a weighted Accept
+// header ("application/json, text/csv;q=0.9"); ranges such as "text/*" are
+// allowed, and an entry that already carries a q parameter keeps it. The
+// response's Content-Type must then match one of them or NewFromURL fails
+// with ErrUnexpectedType before reading the body; see WithAcceptLenient.
+// When the file name has no extension, the negotiated type's extension is
+// appended ("report" becomes "report.csv").
+func WithAccept(mediaTypes ...string) ReadOption {
+	return readOption(func(o *readOptions) {
+		if len(mediaTypes) == 0 {
+			o.reject("WithAccept", "no media types")
+			return
+		}
+		for _, mt := range mediaTypes {
+			if _, _, err := mime.ParseMediaType(mt); err != nil {
+				o.reject("WithAccept", "%q: %v", mt, err)
+				return
+			}
+		}
+		o.accept = mediaTypes
+	})
+}
+
+// WithAcceptLenient keeps the Accept header from WithAccept but stores
+// whatever representation the server returns instead of failing with
+// ErrUnexpectedType.
+func WithAcceptLenient() ReadOption {
+	return readOption(func(o *readOptions) { o.acceptLenient = true })
+}
+
+// acceptHeader builds an Accept header value from mediaTypes in preference
+// order. Weights step down from 1 by 0.1, or by less when there are more
+// than ten types, so every entry stays above zero.
+func acceptHeader(mediaTypes []string) string {
+	step := 0.1
+	if n := len(mediaTypes); n > 10 {
+		step = 0.9 / float64(n-1)
+	}
+	parts := make([]string, len(mediaTypes))
+	for i, mt := range mediaTypes {
+		mt = strings.TrimSpace(mt)
+		_, params, _ := mime.ParseMediaType(mt)
+		if _, ok := params["q"]; i == 0 || ok {
+			parts[i] = mt
+			continue
+		}
+		q := float64(int((1-float64(i)*step)*1000+0.5)) / 1000
+		parts[i] = mt + ";q=" + strconv.FormatFloat(q, 'f', -1, 64)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// acceptedType reports whether the declared Content-Type ct matches one of
+// the accepted media types or ranges. Parameters are ignored.
+func acceptedType(ct string, accepted []string) bool {
+	if ct == "" {
+		return false
+	}
+	typ, sub, _ := strings.Cut(mediaTypeBase(ct), "/")
+	for _, a := range accepted {
+		mt, _, err := mime.ParseMediaType(a)
+		if err != nil {
+			continue
+		}
+		atyp, asub, _ := strings.Cut(mt, "/")
+		if (atyp == "*" || atyp == typ) && (asub == "*" || asub == sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNegotiated fails with ErrUnexpectedType when the response type ct
+// is not among the accepted ones.
+func (o readOptions) checkNegotiated(ct, op string) error {
+	if len(o.accept) == 0 || o.acceptLenient || acceptedType(ct, o.accept) {
+		return nil
+	}
+	got := ct
+	if got == "" {
+		got = "none"
+	}
+	return newError(ErrUnexpectedType, op, fmt.Errorf("server returned Content-Type %s, accepted %s", got, strings.Join(o.accept, ", ")))
+}
+
+// applyNegotiatedExtension gives a name without an extension the extension
+// of the negotiated MIME type.
+func applyNegotiatedExtension(m *Metadata, negotiated string) {
+	ext := ExtensionFromMimeType(mediaTypeBase(negotiated))
+	if ext == "" || m.Name == "" || StrictExtensionFromFilename(m.Name) != "" {
+		return
+	}
+	m.Name += "." + ext
+	m.Extension = ext
+}
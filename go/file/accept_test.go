@@ -0,0 +1,129 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptHeader(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{[]string{"application/json"}, "application/json"},
+		{[]string{"application/json", "text/csv"}, "application/json, text/csv;q=0.9"},
+		{[]string{"text/csv; charset=utf-8", "application/json", "*/*"}, "text/csv; charset=utf-8, application/json;q=0.9, */*;q=0.8"},
+		{[]string{"application/json", "text/csv;q=0.2"}, "application/json, text/csv;q=0.2"},
+		{
+			[]string{"a/1", "a/2", "a/3", "a/4", "a/5", "a/6", "a/7", "a/8", "a/9", "a/10", "a/11"},
+			"a/1, a/2;q=0.91, a/3;q=0.82, a/4;q=0.73, a/5;q=0.64, a/6;q=0.55, a/7;q=0.46, a/8;q=0.37, a/9;q=0.28, a/10;q=0.19, a/11;q=0.1",
+		},
+	}
+	for _, tt := range tests {
+		if got := acceptHeader(tt.in); got != tt.want {
+			t.Errorf("acceptHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// negotiatingServer serves the same report as CSV when the most preferred
+// Accept entry is a text type and as JSON otherwise, including for XML
+// requests it cannot satisfy.
+func negotiatingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.HasPrefix(accept, "text/"):
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			fmt.Fprint(w, "id,name\n1,widget\n")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"id":1,"name":"widget"}]`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewFromURL_WithAccept(t *testing.T) {
+	srv := negotiatingServer(t)
+
+	t.Run("csv", func(t *testing.T) {
+		f, err := NewFromURL(srv.URL+"/reports/latest", WithAccept("text/csv", "application/json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text, _ := f.ReadText(); !strings.HasPrefix(text, "id,name") {
+			t.Errorf("content = %q, want CSV", text)
+		}
+		if f.Name() != "latest.csv" || f.Extension() != "csv" {
+			t.Errorf("name = %q, ext = %q; want the negotiated extension", f.Name(), f.Extension())
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		f, err := NewFromURL(srv.URL+"/reports/latest", WithAccept("application/json", "text/csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name() != "latest.json" || f.MimeType() != "application/json" {
+			t.Errorf("name = %q, mime = %q", f.Name(), f.MimeType())
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		if _, err := NewFromURL(srv.URL+"/r", WithAccept("text/*")); err != nil {
+			t.Errorf("text/* should accept text/csv: %v", err)
+		}
+	})
+
+	t.Run("unexpected type", func(t *testing.T) {
+		_, err := NewFromURL(srv.URL+"/r", WithAccept("application/xml"))
+		if !errors.Is(err, ErrUnexpectedType) {
+			t.Fatalf("err = %v, want ErrUnexpectedType", err)
+		}
+		if !strings.Contains(err.Error(), "application/json") {
+			t.Errorf("err = %v, want it to name the negotiated type", err)
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		f, err := NewFromURL(srv.URL+"/r", WithAccept("application/xml"), WithAcceptLenient())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.MimeType() != "application/json" {
+			t.Errorf("mime = %q", f.MimeType())
+		}
+	})
+
+	t.Run("name with extension is kept", func(t *testing.T) {
+		f, err := NewFromURL(srv.URL+"/export.txt", WithAccept("text/csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name() != "export.txt" {
+			t.Errorf("name = %q", f.Name())
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, hints := range [][]ReadOption{
+			{WithAccept()},
+			{WithAccept("not a type")},
+			{WithAcceptLenient()},
+		} {
+			if _, err := NewFromURL(srv.URL, hints...); !errors.Is(err, ErrInvalidOption) {
+				t.Errorf("err = %v, want ErrInvalidOption", err)
+			}
+		}
+		if err := CheckOptions(WithAcceptLenient()); err == nil || !strings.Contains(err.Error(), "WithAcceptLenient requires WithAccept") {
+			t.Errorf("CheckOptions err = %v", err)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package file
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// ArchiveOption configures ZipTo.
+type ArchiveOption func(*archiveOptions)
+
+func (ArchiveOption) isOption() {}
+
+// archiveOptions is the resolved set of ArchiveOption values.
+type archiveOptions struct {
+	optionErrors
+	deterministic bool
+	epoch         time.Time
+}
+
+// newArchiveOptions applies opts over the defaults.
+func newArchiveOptions(opts []ArchiveOption) archiveOptions {
+	var o archiveOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values.
+func (o archiveOptions) validate() error {
+	return errors.Join(o.errs...)
+}
+
+// dosEpoch is the earliest modification time a zip entry can record.
+var dosEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// deterministicLevel is the Deflate level WithDeterministic pins.
+const deterministicLevel = 6
+
+// WithDeterministic makes an archive a pure function of the entry names and
+// contents, so identical trees produce byte-identical archives whatever
+// order the files were written in and whenever they were touched. Entries
+// are written in lexical walk order (each directory's entries sorted by
+// name), every file is Deflate-compressed at level 6, and the metadata
+// below is given up:
+//
+//   - modification times are all set to epoch, or to 1980-01-01 00:00 UTC
+//     (the earliest a zip can record) when epoch is zero, at the two-second
+//     resolution of the zip format
+//   - permissions are normalized to 0644 for files and 0755 for
+//     directories
+//   - extra fields, including the extended timestamp and Unix owner
+//     fields, are omitted
+//
+// Output is byte-identical for a given Go release; compress/flate's output
+// may change between releases. An epoch outside 1980–2107 is rejected.
+func WithDeterministic(epoch time.Time) ArchiveOption {
+	return func(o *archiveOptions) {
+		if epoch.IsZero() {
+			epoch = dosEpoch
+		}
+		if epoch = epoch.UTC(); epoch.Year() < 1980 || epoch.Year() > 2107 {
+			o.reject("WithDeterministic", "epoch %s outside the zip range 1980–2107", epoch.Format(time.RFC3339))
+			return
+		}
+		o.deterministic = true
+		o.epoch = epoch
+	}
+}
+
+// newZipWriter returns a zip.Writer over w, pinning the Deflate level when
+// the output must be deterministic.
+func (o archiveOptions) newZipWriter(w io.Writer) *zip.Writer {
+	zw := zip.NewWriter(w)
+	if o.deterministic {
+		zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, deterministicLevel)
+		})
+	}
+	return zw
+}
+
+// zipHeader returns the header for the entry name described by info.
+func (o archiveOptions) zipHeader(name string, info fs.FileInfo) (*zip.FileHeader, error) {
+	if !o.deterministic {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Name = name
+		if !info.IsDir() {
+			hdr.Method = zip.Deflate
+		}
+		return hdr, nil
+	}
+	hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if info.IsDir() {
+		hdr.Method = zip.Store
+		hdr.SetMode(fs.ModeDir | 0o755)
+	} else {
+		hdr.SetMode(0o644)
+	}
+	// Leaving Modified zero keeps the writer from adding an extended
+	// timestamp field; the MS-DOS fields carry the epoch instead.
+	hdr.ModifiedDate, hdr.ModifiedTime = msDosTime(o.epoch)
+	return hdr, nil
+}
+
+// msDosTime converts t to the MS-DOS date and time a zip header stores.
+func msDosTime(t time.Time) (date, clock uint16) {
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
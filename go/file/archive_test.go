@@ -0,0 +1,124 @@
+package file
+
+import (
+	"archive/zip"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTree creates files (relative path to content) under a new temporary
+// directory in the given order, each with its own modification time and
+// mode.
+func writeTree(t *testing.T, files map[string]string, order []string, mtime time.Time) string {
+	t.Helper()
+	root := t.TempDir()
+	for i, rel := range order {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(files[rel]), os.FileMode(0o600+i%2*0o44)); err != nil {
+			t.Fatal(err)
+		}
+		stamp := mtime.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(p, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func zipSHA256(t *testing.T, root string, opts ...ArchiveOption) string {
+	t.Helper()
+	f, err := NewFromFile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipped, err := f.ZipTo(filepath.Join(t.TempDir(), "tree.zip"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := zipped.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hexSHA256(data)
+}
+
+func TestFile_ZipTo_Deterministic(t *testing.T) {
+	files := map[string]string{
+		"readme.md":           "# tree",
+		"src/main.go":         "package main",
+		"src/util/strings.go": "package util",
+		"assets/logo.svg":     "<svg/>",
+	}
+	order := []string{"readme.md", "src/main.go", "src/util/strings.go", "assets/logo.svg"}
+	shuffled := append([]string(nil), order...)
+	rand.New(rand.NewSource(7)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	base := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	first := writeTree(t, files, order, base)
+	second := writeTree(t, files, shuffled, base.Add(72*time.Hour))
+
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := zipSHA256(t, first, WithDeterministic(epoch))
+	if got := zipSHA256(t, second, WithDeterministic(epoch)); got != want {
+		t.Errorf("shuffled tree: sha256 %s, want %s", got, want)
+	}
+	if zipSHA256(t, first) == zipSHA256(t, second) {
+		t.Error("default archives of trees with different times should differ")
+	}
+
+	t.Run("clock change", func(t *testing.T) {
+		later := time.Now().Add(24 * time.Hour)
+		filepath.Walk(first, func(p string, _ os.FileInfo, _ error) error {
+			return os.Chtimes(p, later, later)
+		})
+		if got := zipSHA256(t, first, WithDeterministic(epoch)); got != want {
+			t.Errorf("after touch: sha256 %s, want %s", got, want)
+		}
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		f, _ := NewFromFile(first)
+		zipped, err := f.ZipTo(filepath.Join(t.TempDir(), "tree.zip"), WithDeterministic(time.Time{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.OpenReader(zipped.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		for _, zf := range zr.File {
+			wantMode := os.FileMode(0o644)
+			if zf.FileInfo().IsDir() {
+				wantMode = os.ModeDir | 0o755
+			}
+			if zf.Mode() != wantMode {
+				t.Errorf("%s mode = %v, want %v", zf.Name, zf.Mode(), wantMode)
+			}
+			if !zf.Modified.Equal(dosEpoch) {
+				t.Errorf("%s modified = %v, want %v", zf.Name, zf.Modified, dosEpoch)
+			}
+			if len(zf.Extra) != 0 {
+				t.Errorf("%s has %d bytes of extra fields", zf.Name, len(zf.Extra))
+			}
+		}
+	})
+
+	t.Run("epoch out of range", func(t *testing.T) {
+		f, _ := NewFromFile(first)
+		dest := filepath.Join(t.TempDir(), "tree.zip")
+		if _, err := f.ZipTo(dest, WithDeterministic(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC))); !errors.Is(err, ErrInvalidOption) {
+			t.Fatalf("err = %v, want ErrInvalidOption", err)
+		}
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Error("archive written despite an invalid option")
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package file
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReservedAttrPrefix starts the attribute, user metadata and tag keys this
+// package writes itself, such as S3MetaUncompressedSize and
+// DefaultRetentionTagKey. Keep your own keys outside it. Attributes under
+// the prefix are never written as S3 user metadata, and the prefixed user
+// metadata the package writes is not surfaced in Attributes.
+const ReservedAttrPrefix = "x-smoo-"
+
+// MaxS3UserMetadataSize is S3's limit on an object's user metadata: the
+// UTF-8 length of every key plus every value, as sent.
+const MaxS3UserMetadataSize = 2048
+
+// GetAttrString returns the attribute key. S3 lowercases user metadata
+// keys, so when key itself is not set a key differing only in case
+// matches.
+func (m Metadata) GetAttrString(key string) (string, bool) {
+	if v, ok := m.Attributes[key]; ok {
+		return v, true
+	}
+	for k, v := range m.Attributes {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// GetAttrInt64 returns the attribute key parsed as a decimal integer. ok
+// is false when it is missing or does not parse.
+func (m Metadata) GetAttrInt64(key string) (int64, bool) {
+	s, ok := m.GetAttrString(key)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
+
+// GetAttrTime returns the attribute key parsed as an RFC 3339 timestamp.
+// ok is false when it is missing or does not parse.
+func (m Metadata) GetAttrTime(key string) (time.Time, bool) {
+	s, ok := m.GetAttrString(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// GetAttrBool returns the attribute key parsed as strconv.ParseBool does.
+// ok is false when it is missing or does not parse.
+func (m Metadata) GetAttrBool(key string) (bool, bool) {
+	s, ok := m.GetAttrString(key)
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(s)
+	return b, err == nil
+}
+
+// SetAttrString sets the attribute key to value.
+func (m *Metadata) SetAttrString(key, value string) {
+	m.Attributes = mergeAttributes(m.Attributes, map[string]string{key: value})
+}
+
+// SetAttrInt64 sets the attribute key to n in decimal.
+func (m *Metadata) SetAttrInt64(key string, n int64) {
+	m.SetAttrString(key, strconv.FormatInt(n, 10))
+}
+
+// SetAttrTime sets the attribute key to t in UTC, formatted as RFC 3339
+// with nanoseconds.
+func (m *Metadata) SetAttrTime(key string, t time.Time) {
+	m.SetAttrString(key, t.UTC().Format(time.RFC3339Nano))
+}
+
+// SetAttrBool sets the attribute key to "true" or "false".
+func (m *Metadata) SetAttrBool(key string, b bool) {
+	m.SetAttrString(key, strconv.FormatBool(b))
+}
+
+// encodeS3UserMetadata returns attrs as S3 user metadata: keys lowercased
+// and values outside printable ASCII encoded as RFC 2047 words, which
+// decodeS3UserMetadata undoes. Keys under ReservedAttrPrefix and empty
+// values are skipped.
+func encodeS3UserMetadata(attrs map[string]string) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		k = strings.ToLower(k)
+		if v == "" || strings.HasPrefix(k, ReservedAttrPrefix) {
+			continue
+		}
+		out[k] = encodeS3MetaValue(v)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// encodeS3MetaValue returns v, or its RFC 2047 encoding when it does not
+// survive an HTTP header as is.
+func encodeS3MetaValue(v string) string {
+	if strings.ContainsFunc(v, func(r rune) bool { return r < ' ' || r > '~' }) || strings.Contains(v, "=?") {
+		return mime.BEncoding.Encode("utf-8", v)
+	}
+	return v
+}
+
+// decodeS3UserMetadata returns S3 user metadata as attributes, decoding
+// RFC 2047 words and leaving out keys under ReservedAttrPrefix.
+func decodeS3UserMetadata(meta map[string]string) map[string]string {
+	var attrs map[string]string
+	dec := new(mime.WordDecoder)
+	for k, v := range meta {
+		if strings.HasPrefix(strings.ToLower(k), ReservedAttrPrefix) {
+			continue
+		}
+		if decoded, err := dec.DecodeHeader(v); err == nil {
+			v = decoded
+		}
+		if attrs == nil {
+			attrs = make(map[string]string, len(meta))
+		}
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// checkS3UserMetadataSize fails with ErrMetadataTooLarge when meta exceeds
+// MaxS3UserMetadataSize, naming the largest entries.
+func checkS3UserMetadataSize(op string, meta map[string]string) error {
+	total := 0
+	keys := make([]string, 0, len(meta))
+	for k, v := range meta {
+		total += len(k) + len(v)
+		keys = append(keys, k)
+	}
+	if total <= MaxS3UserMetadataSize {
+		return nil
+	}
+	size := func(k string) int { return len(k) + len(meta[k]) }
+	slices.SortFunc(keys, func(a, b string) int {
+		if d := size(b) - size(a); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+	largest := make([]string, 0, 3)
+	for _, k := range keys[:min(3, len(keys))] {
+		largest = append(largest, fmt.Sprintf("%q (%d bytes)", k, size(k)))
+	}
+	return newError(ErrMetadataTooLarge, op, fmt.Errorf("user metadata is %d bytes, over S3's %d-byte limit; largest: %s",
+		total, MaxS3UserMetadataSize, strings.Join(largest, ", ")))
+}
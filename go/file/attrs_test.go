@@ -0,0 +1,169 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestAttr_TypedRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.FixedZone("X", 7200))
+	var m Metadata
+	m.SetAttrString("owner", "Zoë")
+	m.SetAttrInt64("rows", -9007199254740993)
+	m.SetAttrTime("processed-at", at)
+	m.SetAttrBool("reviewed", true)
+
+	if got := m.Attributes["processed-at"]; got != "2024-05-01T10:30:00.123456789Z" {
+		t.Errorf("SetAttrTime stored %q", got)
+	}
+	if s, ok := m.GetAttrString("owner"); !ok || s != "Zoë" {
+		t.Errorf("GetAttrString = %q, %v", s, ok)
+	}
+	if n, ok := m.GetAttrInt64("rows"); !ok || n != -9007199254740993 {
+		t.Errorf("GetAttrInt64 = %d, %v", n, ok)
+	}
+	if tm, ok := m.GetAttrTime("processed-at"); !ok || !tm.Equal(at) {
+		t.Errorf("GetAttrTime = %v, %v", tm, ok)
+	}
+	if b, ok := m.GetAttrBool("reviewed"); !ok || !b {
+		t.Errorf("GetAttrBool = %v, %v", b, ok)
+	}
+	if _, ok := m.GetAttrString("Owner"); !ok {
+		t.Error("GetAttrString does not match a key differing in case")
+	}
+
+	m.SetAttrString("junk", "not a number")
+	if _, ok := m.GetAttrInt64("junk"); ok {
+		t.Error("GetAttrInt64 of a non-number reported ok")
+	}
+	if _, ok := m.GetAttrTime("junk"); ok {
+		t.Error("GetAttrTime of a non-time reported ok")
+	}
+	if _, ok := m.GetAttrBool("junk"); ok {
+		t.Error("GetAttrBool of a non-bool reported ok")
+	}
+	if _, ok := m.GetAttrBool("missing"); ok {
+		t.Error("GetAttrBool of a missing key reported ok")
+	}
+}
+
+func TestAttr_S3RoundTrip(t *testing.T) {
+	stored := gzipRoundTripS3(t)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var m Metadata
+	m.SetAttrString("Owner", "Zoë =?utf-8?q?not-encoded?=")
+	m.SetAttrInt64("rows", 42)
+	m.SetAttrTime("processed-at", at)
+	m.SetAttrBool("reviewed", false)
+	m.SetAttrString(S3MetaUncompressedSize, "1")
+
+	f, _ := NewFromBytes([]byte("a,b\n1,2\n"), MetadataHint{Name: "rows.csv", Attributes: m.Attributes})
+	if err := f.UploadToS3("bucket", "rows.csv"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if _, ok := stored.Metadata["Owner"]; ok {
+		t.Error("user metadata key not lowercased")
+	}
+	if _, ok := stored.Metadata[S3MetaUncompressedSize]; ok {
+		t.Error("reserved attribute written as user metadata")
+	}
+	for k, v := range stored.Metadata {
+		if strings.ContainsFunc(v, func(r rune) bool { return r > '~' }) {
+			t.Errorf("user metadata %s = %q is not ASCII", k, v)
+		}
+	}
+
+	got, err := NewFromS3("bucket", "rows.csv")
+	if err != nil {
+		t.Fatalf("NewFromS3: %v", err)
+	}
+	gm := got.Metadata()
+	if s, _ := gm.GetAttrString("Owner"); s != "Zoë =?utf-8?q?not-encoded?=" {
+		t.Errorf("string attribute = %q", s)
+	}
+	if n, ok := gm.GetAttrInt64("rows"); !ok || n != 42 {
+		t.Errorf("int attribute = %d, %v", n, ok)
+	}
+	if tm, ok := gm.GetAttrTime("processed-at"); !ok || !tm.Equal(at) {
+		t.Errorf("time attribute = %v, %v", tm, ok)
+	}
+	if b, ok := gm.GetAttrBool("reviewed"); !ok || b {
+		t.Errorf("bool attribute = %v, %v", b, ok)
+	}
+	if _, ok := gm.Attributes[S3MetaUncompressedSize]; ok {
+		t.Error("reserved user metadata surfaced in Attributes")
+	}
+}
+
+func TestAttr_S3SizeLimit(t *testing.T) {
+	var puts int
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			puts++
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	var m Metadata
+	m.SetAttrString("notes", strings.Repeat("x", 1500))
+	m.SetAttrString("more", strings.Repeat("y", 600))
+	f, _ := NewFromBytes([]byte("x"), MetadataHint{Attributes: m.Attributes})
+
+	err := f.UploadToS3("bucket", "k")
+	if !errors.Is(err, ErrMetadataTooLarge) {
+		t.Fatalf("err = %v, want ErrMetadataTooLarge", err)
+	}
+	if CodeOf(err) != CodeTooLarge {
+		t.Errorf("CodeOf = %s, want %s", CodeOf(err), CodeTooLarge)
+	}
+	if !strings.Contains(err.Error(), `"notes"`) {
+		t.Errorf("error does not name the largest key: %v", err)
+	}
+	if puts != 0 {
+		t.Errorf("PutObject called %d times", puts)
+	}
+	if _, err := f.AsS3PutInput("bucket", "k"); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Errorf("AsS3PutInput err = %v, want ErrMetadataTooLarge", err)
+	}
+
+	m.SetAttrString("more", strings.Repeat("y", 500))
+	ok, _ := NewFromBytes([]byte("x"), MetadataHint{Attributes: m.Attributes})
+	if err := ok.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3 at %d bytes: %v", len("notes")+1500+len("more")+500, err)
+	}
+}
+
+func TestUpdateS3Metadata_AttributeLimits(t *testing.T) {
+	var copies int
+	defer setMockS3(&mockS3Client{
+		headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				ETag:     aws.String(`"e1"`),
+				Metadata: map[string]string{"existing": strings.Repeat("z", 2000)},
+			}, nil
+		},
+		copyObjectFn: func(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			copies++
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k"}
+	err := f.UpdateS3Metadata(context.Background(), MetadataHint{Attributes: map[string]string{"note": strings.Repeat("n", 100)}})
+	if !errors.Is(err, ErrMetadataTooLarge) {
+		t.Fatalf("err = %v, want ErrMetadataTooLarge", err)
+	}
+	err = f.UpdateS3Metadata(context.Background(), MetadataHint{Attributes: map[string]string{"X-Smoo-Owner": "me"}})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("reserved key: err = %v, want ErrInvalidArgument", err)
+	}
+	if copies != 0 {
+		t.Errorf("CopyObject called %d times", copies)
+	}
+}
@@ -0,0 +1,115 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ItemError is one failed item of a batch operation. It identifies the
+// item by its position and key, and wraps the FileError it failed with.
+type ItemError struct {
+	// Index is the item's position in the batch, in the order the
+	// operation processed it.
+	Index int
+	// Key names the item: the S3 key for S3 batches, or the relative path
+	// for local ones.
+	Key string
+	// URL is the item's s3:// URI, when it has one.
+	URL string
+	// Err is why the item failed.
+	Err *FileError
+}
+
+// Error names the item and its failure.
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Err)
+}
+
+// Unwrap returns the item's FileError, so errors.Is matches its sentinel.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError reports the items of a batch operation that failed, out of
+// Total. errors.Is and errors.As look through it into every item, so
+// errors.Is(err, ErrNotFound) asks whether any item was missing, and
+// CodeOf classifies by the first matching item. Inspect individual items
+// with Failed.
+type BatchError struct {
+	// Op is the batch operation (e.g., "DeleteS3Prefix").
+	Op string
+	// Total is the number of items in the batch. Items neither failed nor
+	// succeeded were not attempted, because the operation stopped early.
+	Total int
+
+	items     []*ItemError
+	succeeded int
+}
+
+// newBatchError returns a BatchError for op, or nil when items is empty.
+func newBatchError(op string, total, succeeded int, items []*ItemError) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return &BatchError{Op: op, Total: total, items: items, succeeded: succeeded}
+}
+
+// maxListedFailures caps how many items BatchError.Error lists.
+const maxListedFailures = 10
+
+// Error summarizes the batch ("3/120 failed") and lists the first few
+// failures.
+func (e *BatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "file: %s: %d/%d failed", e.Op, len(e.items), e.Total)
+	if rest := e.Total - len(e.items) - e.succeeded; rest > 0 {
+		fmt.Fprintf(&b, " (%d not completed)", rest)
+	}
+	for i, item := range e.items {
+		if i == maxListedFailures {
+			fmt.Fprintf(&b, "; and %d more", len(e.items)-i)
+			break
+		}
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(item.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns every item's error.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.items))
+	for i, item := range e.items {
+		errs[i] = item
+	}
+	return errs
+}
+
+// Failed returns the failed items, in processing order.
+func (e *BatchError) Failed() []ItemError {
+	out := make([]ItemError, len(e.items))
+	for i, item := range e.items {
+		out[i] = *item
+	}
+	return out
+}
+
+// Succeeded returns how many items completed. For an all-or-nothing batch
+// such as CommitAll it is zero whenever anything failed.
+func (e *BatchError) Succeeded() int {
+	return e.succeeded
+}
+
+// itemError wraps err as the ItemError for the item at index, keeping err
+// itself when it is already a FileError.
+func itemError(index int, key, url, op string, sentinel, err error) *ItemError {
+	fe, ok := err.(*FileError)
+	if !ok {
+		fe = newError(sentinel, op, err)
+	}
+	return &ItemError{Index: index, Key: key, URL: url, Err: fe}
+}
@@ -0,0 +1,136 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestBatchError_Unwrap(t *testing.T) {
+	err := newBatchError("Sync", 5, 3, []*ItemError{
+		itemError(1, "a.txt", "s3://b/a.txt", "Sync", ErrS3, errors.New("denied")),
+		itemError(4, "e.txt", "", "Sync", ErrRead, newError(ErrNotFound, "Read", os.ErrNotExist)),
+	})
+
+	for _, sentinel := range []error{ErrS3, ErrNotFound, os.ErrNotExist} {
+		if !errors.Is(err, sentinel) {
+			t.Errorf("errors.Is(err, %v) = false", sentinel)
+		}
+	}
+	if errors.Is(err, ErrWrite) {
+		t.Error("errors.Is(err, ErrWrite) = true, want false")
+	}
+	var fe *FileError
+	if !errors.As(err, &fe) || fe.Sentinel != ErrS3 || fe.Op != "Sync" {
+		t.Errorf("errors.As FileError = %+v, want the first item's", fe)
+	}
+
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("err = %T, want *BatchError", err)
+	}
+	failed := be.Failed()
+	if len(failed) != 2 || failed[0].Index != 1 || failed[0].URL != "s3://b/a.txt" || failed[1].Key != "e.txt" {
+		t.Errorf("Failed() = %+v", failed)
+	}
+	if failed[1].Err.Sentinel != ErrNotFound {
+		t.Errorf("a FileError item should be kept as is, got sentinel %v", failed[1].Err.Sentinel)
+	}
+	if be.Succeeded() != 3 {
+		t.Errorf("Succeeded() = %d, want 3", be.Succeeded())
+	}
+
+	if err := newBatchError("Sync", 5, 5, nil); err != nil {
+		t.Errorf("newBatchError with no failures = %v, want nil", err)
+	}
+}
+
+func TestBatchError_Format(t *testing.T) {
+	var items []*ItemError
+	for i := range 12 {
+		items = append(items, itemError(i*10, fmt.Sprintf("k%02d", i*10), "", "Sync", ErrS3, errors.New("boom")))
+	}
+	msg := newBatchError("Sync", 120, 108, items).Error()
+	if !strings.HasPrefix(msg, "file: Sync: 12/120 failed: k00: ") {
+		t.Errorf("summary = %q", msg)
+	}
+	if !strings.Contains(msg, "k90: ") || strings.Contains(msg, "k100") {
+		t.Errorf("want the first %d failures listed: %q", maxListedFailures, msg)
+	}
+	if !strings.HasSuffix(msg, "; and 2 more") {
+		t.Errorf("want the remainder counted: %q", msg)
+	}
+
+	msg = newBatchError("CommitAll", 3, 0, items[:1]).Error()
+	if !strings.HasPrefix(msg, "file: CommitAll: 1/3 failed (2 not completed): k00: ") {
+		t.Errorf("stopped batch = %q", msg)
+	}
+}
+
+func TestFile_UploadDirToS3_ContinuesPastFailures(t *testing.T) {
+	var uploaded []string
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			_, _ = io.ReadAll(params.Body)
+			if aws.ToString(params.Key) == "site/sub/b.json" {
+				return nil, errors.New("denied")
+			}
+			uploaded = append(uploaded, aws.ToString(params.Key))
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f, _ := NewFromFile(dirFixture(t))
+	err := f.UploadDirToS3(context.Background(), "bucket", "site")
+	var be *BatchError
+	if !errors.As(err, &be) || !errors.Is(err, ErrS3) {
+		t.Fatalf("err = %v, want an ErrS3 BatchError", err)
+	}
+	if failed := be.Failed(); len(failed) != 1 || failed[0].Key != "site/sub/b.json" || failed[0].URL != "s3://bucket/site/sub/b.json" {
+		t.Errorf("Failed() = %+v", failed)
+	}
+	if be.Total != 3 || be.Succeeded() != 2 || len(uploaded) != 2 {
+		t.Errorf("Total = %d, Succeeded() = %d, uploaded %v", be.Total, be.Succeeded(), uploaded)
+	}
+}
+
+func TestCommitAll_ReportsEveryStagingFailure(t *testing.T) {
+	files := exportFiles(t)
+	for _, name := range []string{"b.csv", "meta/d.json"} {
+		p := filepath.Join(t.TempDir(), "gone")
+		if err := os.WriteFile(p, []byte("soon gone"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := NewFromFileLazy(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(p)
+		files[name] = f
+	}
+
+	dir := t.TempDir()
+	err := CommitAll(files, dir)
+	var be *BatchError
+	if !errors.As(err, &be) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want a BatchError of ErrNotFound items", err)
+	}
+	failed := be.Failed()
+	if len(failed) != 2 || failed[0].Key != "b.csv" || failed[1].Key != "meta/d.json" {
+		t.Errorf("Failed() = %+v", failed)
+	}
+	if be.Total != 5 || be.Succeeded() != 0 {
+		t.Errorf("Total = %d, Succeeded() = %d, want 5 and 0", be.Total, be.Succeeded())
+	}
+	if snap := dirSnapshot(t, dir); len(snap) != 1 {
+		t.Errorf("dir changed: %v", snap)
+	}
+}
@@ -0,0 +1,243 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Benchmark payloads, 1 KB each: plain text exercises mimetype's slowest
+// path (it falls through every binary signature), PNG and JSON are typical
+// early matches.
+var (
+	benchText = bytes.Repeat([]byte("hello world, "), 80)[:1024]
+	benchPNG  = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 1016)...)
+	benchJSON = append(append([]byte(`{"a":`), bytes.Repeat([]byte("1"), 1018)...), '}')
+)
+
+var benchPayloads = []struct {
+	name string
+	data []byte
+}{
+	{"text", benchText},
+	{"png", benchPNG},
+	{"json", benchJSON},
+}
+
+// Allocation ceilings for a 1 KB payload, with a little headroom over the
+// measured counts. Raise them only with a reason in the commit message.
+const (
+	// maxDetectAllocs bounds DetectMimeTypeFromBytes on benchText; nearly
+	// all of it is mimetype's text and charset sniffing.
+	maxDetectAllocs = 50
+	// maxConstructOverhead bounds what NewFromBytes allocates on top of one
+	// detection pass (Metadata, File, provenance). Detection runs once per
+	// construction, so a second pass would blow through this.
+	maxConstructOverhead = 8
+)
+
+func TestAllocBudget_DetectMimeTypeFromBytes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation budgets are not checked in -short mode")
+	}
+	for _, p := range benchPayloads {
+		got := testing.AllocsPerRun(100, func() { DetectMimeTypeFromBytes(p.data) })
+		if got > maxDetectAllocs {
+			t.Errorf("DetectMimeTypeFromBytes(%s, 1 KB) = %v allocs, ceiling %d", p.name, got, maxDetectAllocs)
+		}
+	}
+}
+
+func TestAllocBudget_NewFromBytes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation budgets are not checked in -short mode")
+	}
+	for _, p := range benchPayloads {
+		detect := testing.AllocsPerRun(100, func() { DetectMimeTypeFromBytes(p.data) })
+		got := testing.AllocsPerRun(100, func() { _, _ = NewFromBytes(p.data) })
+		if got > detect+maxConstructOverhead {
+			t.Errorf("NewFromBytes(%s, 1 KB) = %v allocs, ceiling %v (one detection pass of %v + %d)",
+				p.name, got, detect+maxConstructOverhead, detect, maxConstructOverhead)
+		}
+	}
+}
+
+// --- Constructors ---
+
+func BenchmarkNewFromBytes(b *testing.B) {
+	for _, p := range benchPayloads {
+		b.Run(p.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(p.data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := NewFromBytes(p.data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNewFromBytes_Parallel(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := NewFromBytes(benchText, MetadataHint{Name: "greeting.txt"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkNewFromFile(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "bench.txt")
+	if err := os.WriteFile(path, benchText, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewFromStream(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromStream(bytes.NewReader(benchText)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewFromStreamLazy(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromStreamLazy(bytes.NewReader(benchText)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewFromFS(b *testing.B) {
+	fsys := fstest.MapFS{"docs/bench.txt": {Data: benchText}}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromFS(fsys, "docs/bench.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewFromURL(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(benchText)
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromURL(srv.URL + "/bench.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewFromS3(b *testing.B) {
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(benchText)),
+				ContentType:   aws.String("text/plain"),
+				ContentLength: aws.Int64(int64(len(benchText))),
+				ETag:          aws.String(`"etag"`),
+			}, nil
+		},
+	}, &mockPresignClient{})()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromS3("bucket", "bench.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// --- Detection ---
+
+func BenchmarkDetectMimeTypeFromBytes(b *testing.B) {
+	for _, p := range benchPayloads {
+		b.Run(p.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(p.data)))
+			for i := 0; i < b.N; i++ {
+				DetectMimeTypeFromBytes(p.data)
+			}
+		})
+	}
+}
+
+func BenchmarkMimeTypeFromFilename(b *testing.B) {
+	names := []string{"report.pdf", "photo.JPG", "data.tar.gz", "README"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MimeTypeFromFilename(names[i%len(names)])
+	}
+}
+
+// --- Save and upload ---
+
+func BenchmarkSave(b *testing.B) {
+	f, _ := NewFromBytes(benchText, MetadataHint{Name: "bench.txt"})
+	dest := filepath.Join(b.TempDir(), "out", "bench.txt")
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Save(dest); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUploadToS3(b *testing.B) {
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			_, _ = io.Copy(io.Discard, params.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	for _, size := range []int{1 << 10, 1 << 20} {
+		data := bytes.Repeat([]byte("x"), size)
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				f, _ := NewFromBytes(data)
+				if err := f.UploadToS3("bucket", "bench/key"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
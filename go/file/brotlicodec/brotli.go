@@ -0,0 +1,44 @@
+// Package brotlicodec adds Brotli support (file.CodecBrotli) to the file
+// package.
+//
+// It is a separate module (github.com/SmooAI/file/go/file/brotlicodec) so
+// programs that never use Brotli neither link the encoder nor carry it in
+// their module graph. Register it once at startup:
+//
+//	brotlicodec.Register()
+//
+// after which (*file.File).Compress and Decompress accept file.CodecBrotli
+// and NewFromURL decodes "Content-Encoding: br" responses. Brotli streams
+// have no signature, so Decompress recognizes them by a ".br" extension or
+// the file.AttrContentEncoding attribute.
+package brotlicodec
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// Compressor implements file.Compressor for Brotli. Levels use the Brotli
+// scale (1-11); 0 selects brotli.DefaultCompression.
+type Compressor struct{}
+
+// Register registers a Compressor for file.CodecBrotli.
+func Register() {
+	file.RegisterCodec(file.CodecBrotli, Compressor{})
+}
+
+// NewWriter implements file.Compressor.
+func (Compressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = brotli.DefaultCompression
+	}
+	return brotli.NewWriterLevel(w, level), nil
+}
+
+// NewReader implements file.Compressor.
+func (Compressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
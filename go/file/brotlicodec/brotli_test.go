@@ -0,0 +1,113 @@
+package brotlicodec
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+var payload = []byte(strings.Repeat("body { color: #333; }\n", 300))
+
+func TestBrotli_RoundTrip(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecBrotli, nil)
+
+	for _, level := range []int{0, 1, 11} {
+		f, _ := file.NewFromBytes(payload, file.MetadataHint{Name: "site.css"})
+		br, err := f.Compress(file.CodecBrotli, level)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if br.Name() != "site.css.br" || br.Extension() != "br" {
+			t.Errorf("level %d: metadata = %+v", level, br.Metadata())
+		}
+		back, err := br.Decompress()
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		data, _ := back.Read()
+		if !bytes.Equal(data, payload) || back.Name() != "site.css" {
+			t.Errorf("level %d: round trip mismatch (name %q)", level, back.Name())
+		}
+	}
+}
+
+func TestBrotli_DetectedByAttributeWithoutExtension(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecBrotli, nil)
+
+	f, _ := file.NewFromBytes(payload)
+	br, _ := f.Compress(file.CodecBrotli, 0)
+	// Brotli has no signature; the content-encoding attribute identifies it.
+	anon, _ := file.NewFromBytes(mustRead(t, br), file.MetadataHint{
+		Attributes: map[string]string{file.AttrContentEncoding: "br"},
+	})
+	if _, err := anon.Decompress(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrotli_CrossCodecMisuse(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecBrotli, nil)
+
+	f, _ := file.NewFromBytes(payload)
+	br, _ := f.Compress(file.CodecBrotli, 0)
+
+	// Brotli bytes labelled as gzip: no gzip signature, so the extension
+	// picks gzip and its decoder rejects the data.
+	mislabelled, _ := file.NewFromBytes(mustRead(t, br), file.MetadataHint{Name: "site.css.gz", Extension: "gz"})
+	if _, err := mislabelled.Decompress(); !errors.Is(err, file.ErrCompression) {
+		t.Errorf("expected ErrCompression, got %v", err)
+	}
+
+	// zstd is not registered in this package's tests.
+	if _, err := f.Compress(file.CodecZstd, 0); !errors.Is(err, file.ErrCompression) {
+		t.Errorf("expected ErrCompression for unregistered zstd, got %v", err)
+	}
+}
+
+func TestBrotli_HTTPContentEncoding(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecBrotli, nil)
+
+	var encoded bytes.Buffer
+	bw := brotli.NewWriter(&encoded)
+	bw.Write(payload)
+	bw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(encoded.Bytes())
+	}))
+	defer srv.Close()
+	orig := file.CurrentHTTPClient()
+	file.SetHTTPClient(srv.Client())
+	defer file.SetHTTPClient(orig)
+
+	f, err := file.NewFromURL(srv.URL + "/site.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := f.Read()
+	if !bytes.Equal(data, payload) {
+		t.Error("response was not decoded")
+	}
+}
+
+func mustRead(t *testing.T, f *file.File) []byte {
+	t.Helper()
+	data, err := f.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
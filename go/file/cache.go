@@ -0,0 +1,281 @@
+package file
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// FileCache keeps recently fetched S3 objects and URLs in memory so a burst
+// of requests for the same source resolves it once. Entries are keyed by
+// source identity, the URI and the ETag it was fetched at
+// ("s3://bucket/key@etag"), and evicted least recently used first once the
+// cache holds more than its entry or byte capacity. An entry older than the TTL is revalidated
+// with a conditional request (If-None-Match on its ETag) before it is
+// served again, and refetched only when the source changed.
+//
+// Files returned by GetOrFetch are frozen views of the cached File: each
+// call gets its own handle, so SetMetadata and the like never leak between
+// callers, while the content buffer is shared. The bytes returned by Read
+// must therefore not be modified, and operations that would rewrite the
+// content fail with ErrReadOnly. Safe for concurrent use.
+type FileCache struct {
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	lru      *list.List               // of *cacheEntry, most recently used first
+	entries  map[string]*list.Element // by source identity
+	current  map[string]string        // URI to the identity last fetched for it
+	bytes    int64
+	inflight map[string]*cacheCall
+}
+
+// cacheEntry is one cached File.
+type cacheEntry struct {
+	id      string
+	uri     string
+	file    *File
+	size    int64
+	checked time.Time // when the entry was fetched or last revalidated
+}
+
+// cacheCall is a fetch in progress that concurrent GetOrFetch calls for
+// the same URI wait on instead of fetching again.
+type cacheCall struct {
+	done chan struct{}
+	file *File
+	err  error
+}
+
+// NewFileCache returns a FileCache holding at most maxEntries Files and
+// maxBytes of content; zero or less leaves that dimension unbounded. A File
+// larger than maxBytes is returned but not cached. Entries older than ttl
+// are revalidated before use; zero or less never revalidates.
+func NewFileCache(maxEntries int, maxBytes int64, ttl time.Duration) *FileCache {
+	return &FileCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		now:        time.Now,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		current:    map[string]string{},
+		inflight:   map[string]*cacheCall{},
+	}
+}
+
+// GetOrFetch returns the File for uri, an s3://bucket/key or http(s) URL,
+// from the cache or by fetching it with NewFromS3WithContext or
+// NewFromURLWithContext.
+// Concurrent calls for the same uri share one fetch (or revalidation), and
+// its error: if the caller that started it is cancelled, the others fail
+// too. A fetch that panics fails the calls waiting on it with ErrPanic
+// before the panic reaches its own caller. Other URI schemes fail with
+// ErrInvalidSource.
+func (c *FileCache) GetOrFetch(ctx context.Context, uri string) (*File, error) {
+	if !cacheableURI(uri) {
+		return nil, newError(ErrInvalidSource, "GetOrFetch", fmt.Errorf("%q is not an s3:// or http(s) URI", uri))
+	}
+
+	c.mu.Lock()
+	var stale *cacheEntry
+	if el, ok := c.entries[c.current[uri]]; ok {
+		e := el.Value.(*cacheEntry)
+		if c.ttl <= 0 || c.now().Sub(e.checked) < c.ttl {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return e.file.frozenView(), nil
+		}
+		stale = e
+	}
+	if call, ok := c.inflight[uri]; ok {
+		c.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.file.frozenView(), nil
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[uri] = call
+	c.mu.Unlock()
+
+	fetched := false
+	defer func() {
+		if !fetched {
+			call.err = newError(ErrPanic, "GetOrFetch", fmt.Errorf("fetching %s panicked", uri))
+		}
+		c.mu.Lock()
+		delete(c.inflight, uri)
+		if call.err == nil {
+			c.store(uri, call.file)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.file, call.err = c.fetch(ctx, uri, stale)
+	fetched = true
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.file.frozenView(), nil
+}
+
+// Len returns the number of cached Files.
+func (c *FileCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// fetch revalidates stale, returning its File when the source is
+// unchanged, and otherwise fetches uri.
+func (c *FileCache) fetch(ctx context.Context, uri string, stale *cacheEntry) (*File, error) {
+	if stale != nil && stale.file.meta.Hash != "" {
+		unchanged, err := revalidate(ctx, uri, stale.file.meta.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if unchanged {
+			return stale.file, nil
+		}
+	}
+	if bucket, key, ok := parseS3URI(uri); ok {
+		return NewFromS3WithContext(ctx, bucket, key)
+	}
+	return NewFromURLWithContext(ctx, uri)
+}
+
+// store caches f, fetched from uri, under its source identity, or
+// refreshes the entry when f is already the cached File, then evicts down
+// to capacity. An entry for an older ETag of uri is dropped. c.mu must be
+// held.
+func (c *FileCache) store(uri string, f *File) {
+	id := uri + "@" + strings.Trim(f.meta.Hash, `"`)
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*cacheEntry)
+		if e.file == f {
+			e.checked = c.now()
+			c.lru.MoveToFront(el)
+			return
+		}
+		c.remove(el)
+	}
+	if el, ok := c.entries[c.current[uri]]; ok {
+		c.remove(el)
+	}
+	size := int64(len(f.data))
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+	c.entries[id] = c.lru.PushFront(&cacheEntry{id: id, uri: uri, file: f, size: size, checked: c.now()})
+	c.current[uri] = id
+	c.bytes += size
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops el from the cache. c.mu must be held.
+func (c *FileCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.id)
+	if c.current[e.uri] == e.id {
+		delete(c.current, e.uri)
+	}
+	c.bytes -= e.size
+}
+
+// cacheableURI reports whether FileCache can fetch and revalidate uri.
+func cacheableURI(uri string) bool {
+	if _, _, ok := parseS3URI(uri); ok {
+		return true
+	}
+	lower := strings.ToLower(uri)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// revalidate asks whether the source at uri still has the given ETag, with
+// a conditional HEAD. A 304, or a response carrying the same ETag, means
+// unchanged. MetadataTimeout applies when ctx has no deadline.
+func revalidate(ctx context.Context, uri, etag string) (bool, error) {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	quoted := `"` + strings.Trim(etag, `"`) + `"`
+	if bucket, key, ok := parseS3URI(uri); ok {
+		s3Client, _ := s3Clients()
+		out, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			IfNoneMatch: aws.String(quoted),
+		})
+		if remoteStatus(err) == http.StatusNotModified {
+			return true, nil
+		}
+		if err != nil {
+			return false, dl.newError(ctx, ErrS3, "GetOrFetch", err)
+		}
+		return strings.Trim(aws.ToString(out.ETag), `"`) == strings.Trim(etag, `"`), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, uri, nil)
+	if err != nil {
+		return false, newError(ErrHTTP, "GetOrFetch", err)
+	}
+	req.Header.Set("If-None-Match", quoted)
+	resp, err := CurrentHTTPClient().Do(req)
+	if err != nil {
+		return false, dl.newError(ctx, ErrHTTP, "GetOrFetch", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return true, nil
+	}
+	got := resp.Header.Get("ETag")
+	return got != "" && strings.Trim(got, `"`) == strings.Trim(etag, `"`), nil
+}
+
+// frozenView returns a frozen handle on f's content for one GetOrFetch
+// caller. Metadata and bookkeeping are copied; the content is shared.
+func (f *File) frozenView() *File {
+	v := *f
+	v.meta = f.meta.clone()
+	v.sourceDigests = maps.Clone(f.sourceDigests)
+	v.provenance = slices.Clone(f.provenance)
+	if f.derivatives != nil {
+		v.derivatives = make(map[string]*File, len(f.derivatives))
+		for name, d := range f.derivatives {
+			v.derivatives[name] = d.frozenView()
+		}
+	}
+	v.frozen = true
+	return &v
+}
+
+// Frozen reports whether f is a read-only view shared through a FileCache.
+// Operations that would rewrite a frozen File's content fail with
+// ErrReadOnly.
+func (f *File) Frozen() bool { return f.frozen }
+
+// checkWritable fails op with ErrReadOnly when f is frozen.
+func (f *File) checkWritable(op string) error {
+	if f.frozen {
+		return newError(ErrReadOnly, op, errors.New("file is a shared, read-only view from a FileCache"))
+	}
+	return nil
+}
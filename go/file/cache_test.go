@@ -0,0 +1,308 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// cacheS3 installs an S3 mock serving body for every key with the given
+// ETag, counting GetObject calls. HeadObject answers 304 while the ETag
+// matches If-None-Match.
+func cacheS3(t *testing.T, body []byte, etag *string) (gets *atomic.Int32, heads *atomic.Int32) {
+	gets, heads = new(atomic.Int32), new(atomic.Int32)
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			gets.Add(1)
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentType:   aws.String("text/plain"),
+				ContentLength: aws.Int64(int64(len(body))),
+				ETag:          aws.String(`"` + *etag + `"`),
+			}, nil
+		},
+		headObjectFn: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			heads.Add(1)
+			if aws.ToString(params.IfNoneMatch) == `"`+*etag+`"` {
+				return nil, &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+					Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotModified}},
+					Err:      errors.New("not modified"),
+				}}
+			}
+			return &s3.HeadObjectOutput{ETag: aws.String(`"` + *etag + `"`)}, nil
+		},
+	}, &mockPresignClient{})
+	t.Cleanup(cleanup)
+	return gets, heads
+}
+
+func TestFileCache_HitAndMiss(t *testing.T) {
+	etag := "v1"
+	gets, _ := cacheS3(t, []byte("hello"), &etag)
+	c := NewFileCache(10, 0, 0)
+	ctx := context.Background()
+
+	f1, err := c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	if err != nil {
+		t.Fatalf("GetOrFetch: %v", err)
+	}
+	f2, err := c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	if err != nil {
+		t.Fatalf("GetOrFetch: %v", err)
+	}
+	if _, err := c.GetOrFetch(ctx, "s3://bucket/b.txt"); err != nil {
+		t.Fatalf("GetOrFetch: %v", err)
+	}
+	if n := gets.Load(); n != 2 {
+		t.Errorf("GetObject calls = %d, want 2", n)
+	}
+	if f1 == f2 {
+		t.Error("hits share one *File handle")
+	}
+	if d1, _ := f1.Read(); string(d1) != "hello" {
+		t.Errorf("Read = %q", d1)
+	}
+	if !f1.Frozen() || f1.Hash() != "v1" {
+		t.Errorf("Frozen = %v, Hash = %q", f1.Frozen(), f1.Hash())
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestFileCache_FrozenViewsAreIsolated(t *testing.T) {
+	etag := "v1"
+	cacheS3(t, []byte("hello"), &etag)
+	c := NewFileCache(0, 0, 0)
+	ctx := context.Background()
+
+	f1, _ := c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	f1.SetMetadata(MetadataHint{Name: "renamed.txt", Attributes: map[string]string{"k": "v"}})
+	f2, _ := c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	if f2.Name() != "a.txt" || f2.Metadata().Attributes["k"] != "" {
+		t.Errorf("metadata leaked between callers: name %q, attrs %v", f2.Name(), f2.Metadata().Attributes)
+	}
+	if err := f2.DownloadFromS3("bucket", "other"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("DownloadFromS3 err = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestFileCache_EvictsByEntriesAndBytes(t *testing.T) {
+	etag := "v1"
+	gets, _ := cacheS3(t, []byte("0123456789"), &etag)
+	ctx := context.Background()
+
+	byCount := NewFileCache(2, 0, 0)
+	for _, k := range []string{"a", "b", "a", "c"} {
+		if _, err := byCount.GetOrFetch(ctx, "s3://bucket/"+k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "b" was least recently used when "c" arrived.
+	gets.Store(0)
+	byCount.GetOrFetch(ctx, "s3://bucket/a")
+	byCount.GetOrFetch(ctx, "s3://bucket/b")
+	if n := gets.Load(); n != 1 {
+		t.Errorf("after eviction: GetObject calls = %d, want 1 (b only)", n)
+	}
+
+	byBytes := NewFileCache(0, 25, 0)
+	for _, k := range []string{"a", "b", "c"} {
+		byBytes.GetOrFetch(ctx, "s3://bucket/"+k)
+	}
+	if byBytes.Len() != 2 || byBytes.bytes != 20 {
+		t.Errorf("Len = %d, bytes = %d; want 2, 20", byBytes.Len(), byBytes.bytes)
+	}
+
+	tooSmall := NewFileCache(0, 5, 0)
+	if f, err := tooSmall.GetOrFetch(ctx, "s3://bucket/a"); err != nil || f.Size() != 10 {
+		t.Fatalf("oversized GetOrFetch = %v, %v", f, err)
+	}
+	if tooSmall.Len() != 0 {
+		t.Errorf("oversized File was cached")
+	}
+}
+
+func TestFileCache_RevalidatesAfterTTL(t *testing.T) {
+	etag := "v1"
+	gets, heads := cacheS3(t, []byte("hello"), &etag)
+	c := NewFileCache(0, 0, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	if _, ok := c.entries["s3://bucket/a.txt@v1"]; !ok {
+		t.Errorf("entries = %v, want one keyed by URI and ETag", c.entries)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := c.GetOrFetch(ctx, "s3://bucket/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if gets.Load() != 1 || heads.Load() != 1 {
+		t.Errorf("unchanged: gets = %d, heads = %d; want 1, 1", gets.Load(), heads.Load())
+	}
+	// Revalidation restarted the TTL.
+	c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	if heads.Load() != 1 {
+		t.Errorf("heads = %d within TTL, want 1", heads.Load())
+	}
+
+	etag = "v2"
+	now = now.Add(2 * time.Minute)
+	f, err := c.GetOrFetch(ctx, "s3://bucket/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets.Load() != 2 || f.Hash() != "v2" {
+		t.Errorf("changed: gets = %d, Hash = %q; want 2, v2", gets.Load(), f.Hash())
+	}
+	if _, ok := c.entries["s3://bucket/a.txt@v2"]; !ok || c.Len() != 1 {
+		t.Errorf("entries = %v, want only the v2 entry", c.entries)
+	}
+}
+
+func TestFileCache_RevalidatesURL(t *testing.T) {
+	var gets, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		gets.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	c := NewFileCache(0, 0, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.GetOrFetch(context.Background(), srv.URL+"/a.txt")
+	now = now.Add(2 * time.Minute)
+	f, err := c.GetOrFetch(context.Background(), srv.URL+"/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("gets = %d, 304s = %d; want 1, 1", gets.Load(), notModified.Load())
+	}
+	if d, _ := f.Read(); string(d) != "hello" {
+		t.Errorf("Read = %q", d)
+	}
+}
+
+func TestFileCache_ConcurrentMissFetchesOnce(t *testing.T) {
+	var gets atomic.Int32
+	release := make(chan struct{})
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			gets.Add(1)
+			<-release
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("shared")))}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	c := NewFileCache(0, 0, 0)
+	const callers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := c.GetOrFetch(context.Background(), "s3://bucket/hot.txt")
+			if err == nil {
+				if d, _ := f.Read(); string(d) != "shared" {
+					err = fmt.Errorf("Read = %q", d)
+				}
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := gets.Load(); n != 1 {
+		t.Errorf("GetObject calls = %d, want 1", n)
+	}
+}
+
+func TestFileCache_PanickingFetchReleasesWaiters(t *testing.T) {
+	var gets atomic.Int32
+	release := make(chan struct{})
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if gets.Add(1) == 1 {
+				<-release
+				panic("boom")
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("ok")))}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	c := NewFileCache(0, 0, 0)
+	panicked := make(chan any, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.GetOrFetch(context.Background(), "s3://bucket/k")
+	}()
+	for gets.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrFetch(context.Background(), "s3://bucket/k")
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Errorf("fetching caller recovered %v, want the panic", r)
+	}
+	select {
+	case err := <-waiter:
+		if !errors.Is(err, ErrPanic) {
+			t.Errorf("waiter err = %v, want ErrPanic", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter still blocked after the fetch panicked")
+	}
+	if _, err := c.GetOrFetch(context.Background(), "s3://bucket/k"); err != nil {
+		t.Errorf("fetch after the panic: %v", err)
+	}
+}
+
+func TestFileCache_RejectsOtherSchemes(t *testing.T) {
+	c := NewFileCache(0, 0, 0)
+	for _, uri := range []string{"/tmp/a.txt", "gs://bucket/key", "s3://bucket/"} {
+		if _, err := c.GetOrFetch(context.Background(), uri); !errors.Is(err, ErrInvalidSource) {
+			t.Errorf("GetOrFetch(%q) err = %v, want ErrInvalidSource", uri, err)
+		}
+	}
+}
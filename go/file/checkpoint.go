@@ -0,0 +1,231 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Checkpoint records the progress of a resumable S3 multipart upload.
+type Checkpoint struct {
+	// ID is the transfer ID the checkpoint is stored under.
+	ID string `json:"id"`
+	// Bucket and Key identify the destination object.
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// UploadID is the S3 multipart upload ID.
+	UploadID string `json:"uploadId"`
+	// PartSize is the part size the upload was started with. A resume with
+	// a different part size starts over.
+	PartSize int64 `json:"partSize"`
+	// ChecksumAlgorithm is the S3 checksum algorithm the upload was created
+	// with, if any (see S3Sink.TrailingChecksum). A resume with a
+	// different setting starts over.
+	ChecksumAlgorithm string `json:"checksumAlgorithm,omitempty"`
+	// Parts lists the parts uploaded so far.
+	Parts []CheckpointPart `json:"parts"`
+	// UpdatedAt is when the checkpoint was last saved.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CheckpointPart is one uploaded part of a Checkpoint.
+type CheckpointPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// CheckpointStore persists Checkpoints keyed by transfer ID. Load reports a
+// missing checkpoint with an error matching ErrNotFound. Implementations
+// must be safe for concurrent use by different transfer IDs.
+type CheckpointStore interface {
+	Load(ctx context.Context, id string) (Checkpoint, error)
+	Save(ctx context.Context, cp Checkpoint) error
+	Delete(ctx context.Context, id string) error
+}
+
+// CheckpointLister is implemented by CheckpointStores that can enumerate
+// their checkpoints, which CleanupCheckpoints requires.
+type CheckpointLister interface {
+	List(ctx context.Context) ([]Checkpoint, error)
+}
+
+// WithCheckpoint makes S3Sink multipart uploads resumable: each completed
+// part is recorded in store, and a later transfer with the same ID (see
+// WithTransferID; by default the destination's s3:// URI) skips parts that
+// are already uploaded. A recorded part is reused only if the re-read bytes
+// hash to its ETag and S3 still lists it with that ETag; otherwise it is
+// uploaded again. Uploads with a checkpoint are not aborted on failure, so
+// they can be resumed; use CleanupCheckpoints to abort abandoned ones.
+// Because the content is re-read on resume, the source must produce the
+// same bytes again. Other sinks ignore this option.
+func WithCheckpoint(store CheckpointStore) TransferOption {
+	return func(o *transferOptions) {
+		if store == nil {
+			o.reject("WithCheckpoint", "nil store")
+			return
+		}
+		o.checkpoints = store
+	}
+}
+
+// WithTransferID sets the ID WithCheckpoint stores progress under. It must
+// be combined with WithCheckpoint.
+func WithTransferID(id string) TransferOption {
+	return func(o *transferOptions) {
+		if id == "" {
+			o.reject("WithTransferID", "empty ID")
+			return
+		}
+		o.transferID = id
+	}
+}
+
+// CleanupCheckpoints aborts the multipart uploads of checkpoints not
+// updated within olderThan and deletes those checkpoints. It returns how
+// many were cleaned up. store must implement CheckpointLister.
+func CleanupCheckpoints(ctx context.Context, store CheckpointStore, olderThan time.Duration) (int, error) {
+	lister, ok := store.(CheckpointLister)
+	if !ok {
+		return 0, newError(ErrInvalidSource, "CleanupCheckpoints", fmt.Errorf("%T cannot list checkpoints", store))
+	}
+	cps, err := lister.List(ctx)
+	if err != nil {
+		return 0, newError(ErrRead, "CleanupCheckpoints", err)
+	}
+
+	s3Client, _ := s3Clients()
+	cutoff := time.Now().Add(-olderThan)
+	var (
+		cleaned int
+		errs    []error
+	)
+	for _, cp := range cps {
+		if !cp.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if cp.UploadID != "" {
+			_, err := s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(cp.Bucket),
+				Key:      aws.String(cp.Key),
+				UploadId: aws.String(cp.UploadID),
+			})
+			if err != nil && !isNoSuchUpload(err) {
+				errs = append(errs, newError(ErrS3, "CleanupCheckpoints", fmt.Errorf("%s: %w", cp.ID, err)))
+				continue
+			}
+		}
+		if err := store.Delete(ctx, cp.ID); err != nil {
+			errs = append(errs, newError(ErrWrite, "CleanupCheckpoints", fmt.Errorf("%s: %w", cp.ID, err)))
+			continue
+		}
+		cleaned++
+	}
+	return cleaned, errors.Join(errs...)
+}
+
+// isNoSuchUpload reports whether err says the multipart upload is gone.
+func isNoSuchUpload(err error) bool {
+	var noSuch *types.NoSuchUpload
+	return errors.As(err, &noSuch)
+}
+
+// FileCheckpointStore keeps checkpoints as JSON files in Dir, one per
+// transfer ID. Writes are atomic (temp file plus rename), so a crash never
+// leaves a torn checkpoint.
+type FileCheckpointStore struct {
+	Dir string
+}
+
+// Load implements CheckpointStore.
+func (s FileCheckpointStore) Load(ctx context.Context, id string) (Checkpoint, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, newError(ErrNotFound, "Checkpoint", fmt.Errorf("no checkpoint for %q", id))
+	}
+	if err != nil {
+		return Checkpoint{}, newError(ErrRead, "Checkpoint", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, newError(ErrRead, "Checkpoint", err)
+	}
+	return cp, nil
+}
+
+// Save implements CheckpointStore.
+func (s FileCheckpointStore) Save(ctx context.Context, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return newError(ErrWrite, "Checkpoint", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return newError(ErrWrite, "Checkpoint", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".checkpoint-*")
+	if err != nil {
+		return newError(ErrWrite, "Checkpoint", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(cp.ID))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return newError(ErrWrite, "Checkpoint", err)
+	}
+	return nil
+}
+
+// Delete implements CheckpointStore. Deleting a missing checkpoint is not
+// an error.
+func (s FileCheckpointStore) Delete(ctx context.Context, id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return newError(ErrWrite, "Checkpoint", err)
+	}
+	return nil
+}
+
+// List implements CheckpointLister.
+func (s FileCheckpointStore) List(ctx context.Context) ([]Checkpoint, error) {
+	matches, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, newError(ErrRead, "Checkpoint", err)
+	}
+	var cps []Checkpoint
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			continue // removed concurrently
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			continue
+		}
+		cps = append(cps, cp)
+	}
+	return cps, nil
+}
+
+// path maps a transfer ID to its file. IDs are hashed because they are
+// usually URIs.
+func (s FileCheckpointStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:16])+".json")
+}
@@ -0,0 +1,147 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fivePartPayload spans five minimum-size parts plus a short tail.
+func fivePartPayload() []byte {
+	data := make([]byte, 5*minPartSize+1234)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestCheckpoint_ResumesAfterInterruption(t *testing.T) {
+	fake := newFakeMultipartS3()
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	store := FileCheckpointStore{Dir: t.TempDir()}
+	payload := fivePartPayload()
+	sink := &S3Sink{Bucket: "bucket", Key: "big.bin", PartSize: minPartSize}
+
+	// First attempt: preempted after three parts.
+	ctx, cancel := context.WithCancel(context.Background())
+	fake.afterPart = func(n int) {
+		if n == 3 {
+			cancel()
+		}
+	}
+	src, _ := NewFromBytes(payload)
+	if _, err := Pipe(ctx, src, sink, WithCheckpoint(store)); err == nil {
+		t.Fatal("expected the interrupted transfer to fail")
+	}
+	if len(fake.aborted) != 0 {
+		t.Fatalf("checkpointed upload was aborted: %v", fake.aborted)
+	}
+	cp, err := store.Load(context.Background(), "s3://bucket/big.bin")
+	if err != nil || len(cp.Parts) != 3 {
+		t.Fatalf("checkpoint = %+v, %v", cp, err)
+	}
+
+	// Second attempt, as a fresh process would: only the rest is uploaded.
+	fake.afterPart = nil
+	fake.uploaded = nil
+	src, _ = NewFromBytes(payload)
+	res, err := Pipe(context.Background(), src, sink, WithCheckpoint(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Bytes != int64(len(payload)) || !bytes.Equal(fake.completed, payload) {
+		t.Fatalf("completed object differs (Bytes = %d)", res.Bytes)
+	}
+	if fmt.Sprint(fake.uploaded) != "[4 5 6]" {
+		t.Errorf("parts uploaded on resume = %v, want [4 5 6]", fake.uploaded)
+	}
+	if fake.created != 1 {
+		t.Errorf("CreateMultipartUpload called %d times, want 1", fake.created)
+	}
+	if _, err := store.Load(context.Background(), "s3://bucket/big.bin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("checkpoint not deleted after completion: %v", err)
+	}
+}
+
+func TestCheckpoint_ReuploadsPartsWithStaleETags(t *testing.T) {
+	fake := newFakeMultipartS3()
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	store := FileCheckpointStore{Dir: t.TempDir()}
+	payload := fivePartPayload()
+	sink := &S3Sink{Bucket: "bucket", Key: "k", PartSize: minPartSize}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fake.afterPart = func(n int) {
+		if n == 2 {
+			cancel()
+		}
+	}
+	src, _ := NewFromBytes(payload)
+	_, _ = Pipe(ctx, src, sink, WithCheckpoint(store), WithTransferID("job-42"))
+
+	// Corrupt the record for part 1; part 2 stays valid.
+	cp, _ := store.Load(context.Background(), "job-42")
+	cp.Parts[0].ETag = `"00000000000000000000000000000000"`
+	if err := store.Save(context.Background(), cp); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.afterPart = nil
+	fake.uploaded = nil
+	src, _ = NewFromBytes(payload)
+	if _, err := Pipe(context.Background(), src, sink, WithCheckpoint(store), WithTransferID("job-42")); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(fake.uploaded) != "[1 3 4 5 6]" {
+		t.Errorf("parts uploaded on resume = %v, want [1 3 4 5 6]", fake.uploaded)
+	}
+	if !bytes.Equal(fake.completed, payload) {
+		t.Error("completed object differs")
+	}
+}
+
+func TestCheckpoint_StartsOverWhenUploadIsGone(t *testing.T) {
+	fake := newFakeMultipartS3()
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	store := FileCheckpointStore{Dir: t.TempDir()}
+	_ = store.Save(context.Background(), Checkpoint{
+		ID: "s3://bucket/k", Bucket: "bucket", Key: "k", UploadID: "expired", PartSize: minPartSize,
+		Parts: []CheckpointPart{{Number: 1, ETag: `"x"`, Size: minPartSize}},
+	})
+
+	src, _ := NewFromBytes(fivePartPayload())
+	if _, err := Pipe(context.Background(), src, &S3Sink{Bucket: "bucket", Key: "k", PartSize: minPartSize}, WithCheckpoint(store)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.created != 1 || len(fake.uploaded) != 6 {
+		t.Errorf("created = %d, uploaded = %v", fake.created, fake.uploaded)
+	}
+}
+
+func TestCleanupCheckpoints_AbortsOldUploads(t *testing.T) {
+	fake := newFakeMultipartS3()
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	store := FileCheckpointStore{Dir: t.TempDir()}
+	ctx := context.Background()
+
+	fake.uploads["old"] = map[int32][]byte{}
+	_ = store.Save(ctx, Checkpoint{ID: "old", Bucket: "bucket", Key: "old", UploadID: "old", UpdatedAt: time.Now().Add(-48 * time.Hour)})
+	_ = store.Save(ctx, Checkpoint{ID: "fresh", Bucket: "bucket", Key: "fresh", UploadID: "fresh", UpdatedAt: time.Now()})
+
+	n, err := CleanupCheckpoints(ctx, store, 24*time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("CleanupCheckpoints = %d, %v", n, err)
+	}
+	if fmt.Sprint(fake.aborted) != "[old]" {
+		t.Errorf("aborted = %v", fake.aborted)
+	}
+	if _, err := store.Load(ctx, "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old checkpoint still present: %v", err)
+	}
+	if _, err := store.Load(ctx, "fresh"); err != nil {
+		t.Errorf("fresh checkpoint removed: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package file
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPDoer performs HTTP requests. *http.Client satisfies it.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// S3ClientFactory creates the S3 clients used by every S3 operation.
+//
+// Deprecated: Assigning this variable races with in-flight operations. Use
+// SetS3ClientFactory instead. Direct assignment keeps working until
+// SetS3ClientFactory is first called, after which the setter's value is
+// used; the variable will be removed in a future release.
+var S3ClientFactory = defaultS3ClientFactory
+
+// HTTPClient performs the HTTP requests of URL operations.
+//
+// Deprecated: Assigning this variable races with in-flight operations. Use
+// SetHTTPClient instead. Direct assignment keeps working until
+// SetHTTPClient is first called, after which the setter's value is used;
+// the variable will be removed in a future release.
+var HTTPClient HTTPDoer = http.DefaultClient
+
+// Atomic holders behind SetHTTPClient and SetS3ClientFactory. Until a
+// setter has been called, reads fall back to the deprecated variables.
+var (
+	httpClientSet    atomic.Bool
+	httpClientHolder atomic.Pointer[httpClientBox]
+
+	s3FactorySet    atomic.Bool
+	s3FactoryHolder atomic.Pointer[s3FactoryBox]
+)
+
+type httpClientBox struct{ c HTTPDoer }
+
+type s3FactoryBox struct {
+	fn func() (S3API, S3PresignAPI)
+	// plainHTTP records that ConfigureS3 pointed the clients at an http://
+	// endpoint, over which the AWS SDK refuses unseekable request bodies.
+	plainHTTP bool
+}
+
+// SetHTTPClient replaces the client used for HTTP requests. It is safe to
+// call while operations are running: each request uses the client current
+// when it starts. A nil c restores http.DefaultClient.
+func SetHTTPClient(c HTTPDoer) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	httpClientHolder.Store(&httpClientBox{c: c})
+	httpClientSet.Store(true)
+}
+
+// CurrentHTTPClient returns the client HTTP requests currently use.
+func CurrentHTTPClient() HTTPDoer {
+	if httpClientSet.Load() {
+		return httpClientHolder.Load().c
+	}
+	return HTTPClient
+}
+
+// WithHTTPClient makes a single NewFromURL call send its requests with c
+// instead of the package-wide client, so one fetch can use its own
+// transport or timeout without changing it for every caller. A nil c is
+// rejected.
+func WithHTTPClient(c *http.Client) ReadOption {
+	return readOption(func(o *readOptions) {
+		if c == nil {
+			o.reject("WithHTTPClient", "nil client")
+			return
+		}
+		o.client = c
+	})
+}
+
+// SetS3ClientFactory replaces the function that creates S3 clients, for
+// example to inject a mock in tests. It is safe to call while operations
+// are running: each operation uses the factory current when it starts. A
+// nil fn restores the default, which loads the AWS config from the
+// environment.
+func SetS3ClientFactory(fn func() (S3API, S3PresignAPI)) {
+	setS3ClientFactory(fn, false)
+}
+
+// setS3ClientFactory implements SetS3ClientFactory, recording whether the
+// clients talk plain HTTP.
+func setS3ClientFactory(fn func() (S3API, S3PresignAPI), plainHTTP bool) {
+	if fn == nil {
+		fn = defaultS3ClientFactory
+	}
+	s3FactoryHolder.Store(&s3FactoryBox{fn: fn, plainHTTP: plainHTTP})
+	s3FactorySet.Store(true)
+}
+
+// s3StreamsBodies reports whether the current S3 clients can send an
+// unseekable body. The AWS SDK only does so over TLS (with a trailing
+// checksum, its default), so not to a plain-http endpoint set with
+// ConfigureS3.
+func s3StreamsBodies() bool {
+	return !s3FactorySet.Load() || !s3FactoryHolder.Load().plainHTTP
+}
+
+// CurrentS3ClientFactory returns the factory S3 operations currently use.
+func CurrentS3ClientFactory() func() (S3API, S3PresignAPI) {
+	if s3FactorySet.Load() {
+		return s3FactoryHolder.Load().fn
+	}
+	return S3ClientFactory
+}
+
+// HTTPConfig tunes the connection pool and timeouts of the transport built
+// by ConfigureHTTP and NewHTTPClient. Zero fields keep the values of
+// http.DefaultTransport; negative values are rejected.
+type HTTPConfig struct {
+	// MaxConnsPerHost caps connections per host, including those in use.
+	// It also raises the idle pool per host to match, which otherwise
+	// keeps only two connections and makes parallel downloads reconnect.
+	MaxConnsPerHost int
+	// MaxIdleConns caps idle connections across all hosts.
+	MaxIdleConns int
+	// IdleConnTimeout is how long an idle connection is kept open.
+	IdleConnTimeout time.Duration
+	// ForceAttemptHTTP2 negotiates HTTP/2 even when other settings would
+	// otherwise disable it.
+	ForceAttemptHTTP2 bool
+	// TLSHandshakeTimeout limits the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the wait for response headers after
+	// the request is written. It does not bound reading the body.
+	ResponseHeaderTimeout time.Duration
+}
+
+// validate reports negative fields.
+func (c HTTPConfig) validate() error {
+	var e optionErrors
+	for _, f := range []struct {
+		name string
+		v    int64
+	}{
+		{"MaxConnsPerHost", int64(c.MaxConnsPerHost)},
+		{"MaxIdleConns", int64(c.MaxIdleConns)},
+		{"IdleConnTimeout", int64(c.IdleConnTimeout)},
+		{"TLSHandshakeTimeout", int64(c.TLSHandshakeTimeout)},
+		{"ResponseHeaderTimeout", int64(c.ResponseHeaderTimeout)},
+	} {
+		if f.v < 0 {
+			e.reject("HTTPConfig."+f.name, "negative value %d", f.v)
+		}
+	}
+	return errors.Join(e.errs...)
+}
+
+// transport returns a clone of http.DefaultTransport with c applied.
+func (c HTTPConfig) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if c.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = c.MaxConnsPerHost
+		t.MaxIdleConnsPerHost = c.MaxConnsPerHost
+	}
+	if c.MaxIdleConns > 0 {
+		t.MaxIdleConns = c.MaxIdleConns
+	}
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.ForceAttemptHTTP2 {
+		t.ForceAttemptHTTP2 = true
+	}
+	if c.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	}
+	if c.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = c.ResponseHeaderTimeout
+	}
+	return t
+}
+
+// NewHTTPClient returns a new *http.Client whose transport is configured
+// by cfg, for use with SetHTTPClient or on its own. Invalid values fail
+// with ErrInvalidOption.
+func NewHTTPClient(cfg HTTPConfig) (*http.Client, error) {
+	if err := checkOptions("NewHTTPClient", cfg.validate()); err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: cfg.transport()}, nil
+}
+
+// configuredHTTPClient is the client last installed by ConfigureHTTP;
+// configureHTTPMu serializes ConfigureHTTP's check-and-replace.
+var (
+	configuredHTTPClient atomic.Pointer[http.Client]
+	configureHTTPMu      sync.Mutex
+)
+
+// ConfigureHTTP rebuilds the package's default HTTP client with a
+// transport configured by cfg. It only replaces the default: when the
+// current client was installed with SetHTTPClient (or assigned to
+// HTTPClient), it is left alone and ConfigureHTTP reports false. Calling
+// it again replaces the previous configuration. Invalid values fail with
+// ErrInvalidOption.
+func ConfigureHTTP(cfg HTTPConfig) (bool, error) {
+	if err := checkOptions("ConfigureHTTP", cfg.validate()); err != nil {
+		return false, err
+	}
+	c := &http.Client{Transport: cfg.transport()}
+	configureHTTPMu.Lock()
+	defer configureHTTPMu.Unlock()
+	if cur := CurrentHTTPClient(); cur != HTTPDoer(http.DefaultClient) && cur != HTTPDoer(configuredHTTPClient.Load()) {
+		return false, nil
+	}
+	configuredHTTPClient.Store(c)
+	SetHTTPClient(c)
+	return true, nil
+}
+
+// s3Clients creates S3 clients with the current factory.
+func s3Clients() (S3API, S3PresignAPI) {
+	return CurrentS3ClientFactory()()
+}
@@ -0,0 +1,231 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// resetClientSetters returns the client accessors to their pre-setter state
+// so the deprecated variables are honored again.
+func resetClientSetters(t *testing.T) {
+	t.Helper()
+	httpWasSet, s3WasSet := httpClientSet.Load(), s3FactorySet.Load()
+	httpClientSet.Store(false)
+	s3FactorySet.Store(false)
+	t.Cleanup(func() {
+		httpClientSet.Store(httpWasSet)
+		s3FactorySet.Store(s3WasSet)
+	})
+}
+
+func TestSetHTTPClient_ConcurrentWithRequests(t *testing.T) {
+	var servers []*httptest.Server
+	for i := 0; i < 2; i++ {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "hello")
+		}))
+		defer srv.Close()
+		servers = append(servers, srv)
+	}
+	defer setMockHTTP(servers[0].Client())()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				SetHTTPClient(servers[i%2].Client())
+			}
+		}
+	}()
+
+	var reqs sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		reqs.Add(1)
+		go func(i int) {
+			defer reqs.Done()
+			f, err := NewFromURL(servers[i%2].URL + "/hello.txt")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got, _ := f.ReadText(); got != "hello" {
+				t.Errorf("content = %q", got)
+			}
+		}(i)
+	}
+	reqs.Wait()
+	close(stop)
+	wg.Wait()
+}
+
+func TestSetS3ClientFactory_ConcurrentWithOperations(t *testing.T) {
+	mock := &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetS3ClientFactory(func() (S3API, S3PresignAPI) { return mock, &mockPresignClient{} })
+		}()
+		go func() {
+			defer wg.Done()
+			f, _ := NewFromBytes([]byte("x"))
+			if err := f.UploadToS3WithContext(context.Background(), "bucket", "k"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDeprecatedVariables_HonoredUntilSetterUsed(t *testing.T) {
+	resetClientSetters(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "legacy")
+	}))
+	defer srv.Close()
+	orig := HTTPClient
+	HTTPClient = srv.Client()
+	defer func() { HTTPClient = orig }()
+
+	if CurrentHTTPClient() != HTTPClient {
+		t.Fatal("direct assignment should be honored before SetHTTPClient is called")
+	}
+	f, err := NewFromURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.ReadText(); got != "legacy" {
+		t.Errorf("content = %q", got)
+	}
+
+	SetHTTPClient(http.DefaultClient)
+	if CurrentHTTPClient() != http.DefaultClient {
+		t.Error("SetHTTPClient should take over from the variable")
+	}
+}
+
+func TestNewHTTPClient_Transport(t *testing.T) {
+	c, err := NewHTTPClient(HTTPConfig{
+		MaxConnsPerHost:       32,
+		MaxIdleConns:          256,
+		IdleConnTimeout:       45 * time.Second,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 7 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := c.Transport.(*http.Transport)
+	if tr.MaxConnsPerHost != 32 || tr.MaxIdleConnsPerHost != 32 {
+		t.Errorf("per host = %d conns, %d idle; want 32, 32", tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost)
+	}
+	if tr.MaxIdleConns != 256 || tr.IdleConnTimeout != 45*time.Second {
+		t.Errorf("idle = %d, %v", tr.MaxIdleConns, tr.IdleConnTimeout)
+	}
+	if !tr.ForceAttemptHTTP2 || tr.TLSHandshakeTimeout != 3*time.Second || tr.ResponseHeaderTimeout != 7*time.Second {
+		t.Errorf("transport = %+v", tr)
+	}
+	if tr == http.DefaultTransport {
+		t.Error("http.DefaultTransport should be cloned, not modified")
+	}
+
+	// Zero fields keep the default transport's values.
+	c, _ = NewHTTPClient(HTTPConfig{})
+	def := http.DefaultTransport.(*http.Transport)
+	if tr := c.Transport.(*http.Transport); tr.MaxIdleConns != def.MaxIdleConns || tr.IdleConnTimeout != def.IdleConnTimeout {
+		t.Errorf("zero config changed defaults: %+v", tr)
+	}
+
+	if _, err := NewHTTPClient(HTTPConfig{MaxConnsPerHost: -1}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("negative MaxConnsPerHost: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestConfigureHTTP(t *testing.T) {
+	orig := CurrentHTTPClient()
+	defer SetHTTPClient(orig)
+
+	SetHTTPClient(nil)
+	ok, err := ConfigureHTTP(HTTPConfig{MaxConnsPerHost: 16})
+	if err != nil || !ok {
+		t.Fatalf("ConfigureHTTP = %v, %v; want true", ok, err)
+	}
+	c, isClient := CurrentHTTPClient().(*http.Client)
+	if !isClient || c.Transport.(*http.Transport).MaxConnsPerHost != 16 {
+		t.Fatalf("client = %#v, want the configured transport", CurrentHTTPClient())
+	}
+
+	// Reconfiguring replaces the package's own client.
+	if ok, _ := ConfigureHTTP(HTTPConfig{MaxConnsPerHost: 4}); !ok {
+		t.Error("reconfiguring the package client should apply")
+	}
+	if got := CurrentHTTPClient().(*http.Client).Transport.(*http.Transport).MaxConnsPerHost; got != 4 {
+		t.Errorf("MaxConnsPerHost = %d, want 4", got)
+	}
+
+	// A client the user installed is not clobbered.
+	user := &http.Client{Timeout: time.Second}
+	SetHTTPClient(user)
+	if ok, err := ConfigureHTTP(HTTPConfig{MaxConnsPerHost: 8}); ok || err != nil {
+		t.Errorf("ConfigureHTTP over a user client = %v, %v; want false, nil", ok, err)
+	}
+	if CurrentHTTPClient() != HTTPDoer(user) {
+		t.Error("user-supplied client was replaced")
+	}
+
+	if _, err := ConfigureHTTP(HTTPConfig{IdleConnTimeout: -time.Second}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("negative IdleConnTimeout: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestWithHTTPClient_PerCall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	global := &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("global client used")
+	})}
+	defer setMockHTTP(global)()
+
+	f, err := NewFromURL(srv.URL, WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+	if data, _ := f.Read(); string(data) != "ok" {
+		t.Errorf("content = %q", data)
+	}
+	if _, err := NewFromURL(srv.URL); err == nil || !strings.Contains(err.Error(), "global client used") {
+		t.Errorf("without the option the global client should be used: err = %v", err)
+	}
+	if CurrentHTTPClient() != global {
+		t.Error("WithHTTPClient must not replace the global client")
+	}
+	if _, err := NewFromURL(srv.URL, WithHTTPClient(nil)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("nil client: err = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,189 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+// ErrorCode is a coarse, machine-readable classification of an error,
+// meant for translating failures into API responses without matching on
+// message text. Obtain it with CodeOf or (*FileError).Code.
+//
+// The set of codes and their string values are stable: a code is never
+// renamed or removed, and an error that maps to a code keeps mapping to it
+// unless the classification was a bug. New codes may be added, so callers
+// should treat unrecognized values like CodeUnknown.
+type ErrorCode string
+
+const (
+	// CodeNotFound means the file, object or URL does not exist: ErrNotFound,
+	// fs.ErrNotExist, S3 NoSuchKey/NotFound, or an HTTP 404 or 410.
+	CodeNotFound ErrorCode = "not_found"
+	// CodeAccessDenied means the caller lacks permission: fs.ErrPermission,
+	// ErrNetworkDisallowed, an S3 AccessDenied-style error, or an HTTP 401
+	// or 403.
+	CodeAccessDenied ErrorCode = "access_denied"
+	// CodeTooLarge means a size or count limit was exceeded: a KindSize
+	// validation failure, ErrTooLarge, ErrTooManyObjects,
+	// ErrMetadataTooLarge, S3 EntityTooLarge or MetadataTooLarge, or an HTTP
+	// 413.
+	CodeTooLarge ErrorCode = "too_large"
+	// CodeTimeout means a deadline passed or a transfer stalled: ErrTimeout,
+	// ErrStalled, context.DeadlineExceeded, or a network timeout.
+	CodeTimeout ErrorCode = "timeout"
+	// CodeChecksumMismatch means content did not match its expected digest.
+	CodeChecksumMismatch ErrorCode = "checksum_mismatch"
+	// CodeInvalidSource means the operation is not supported for the file's
+	// source (ErrInvalidSource) or for a frozen File (ErrReadOnly).
+	CodeInvalidSource ErrorCode = "invalid_source"
+	// CodeRemote5xx means a remote server failed or could not be reached:
+	// an HTTP or S3 5xx status, or a network error other than a timeout.
+	CodeRemote5xx ErrorCode = "remote_5xx"
+	// CodeRemote4xx means a remote server rejected the request with a 4xx
+	// status not covered by a more specific code.
+	CodeRemote4xx ErrorCode = "remote_4xx"
+	// CodeValidation means the caller's input was rejected before or
+	// instead of doing the work: validation failures other than size,
+	// invalid options, arguments and templates, unexpected or unhandled
+	// content types, content that is not text or PDF, and existing
+	// destinations.
+	CodeValidation ErrorCode = "validation"
+	// CodeUnknown covers everything else, including context.Canceled.
+	CodeUnknown ErrorCode = "unknown"
+)
+
+// Code returns the ErrorCode for e. See CodeOf.
+func (e *FileError) Code() ErrorCode {
+	return CodeOf(e)
+}
+
+// CodeOf classifies err, walking its whole wrapped chain (including
+// errors.Join trees). It returns "" for a nil err. The first matching
+// rule wins, in this order: timeout, validation kind, checksum mismatch,
+// not found, access denied, too large, invalid source, other validation,
+// remote status (5xx, then 4xx), network failure, unknown. A FileError's
+// sentinel and its underlying cause are both considered, so an ErrS3
+// wrapping NoSuchKey is CodeNotFound.
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	if isTimeoutError(err) {
+		return CodeTimeout
+	}
+	var vErr *FileValidationError
+	if errors.As(err, &vErr) {
+		if vErr.Kind == KindSize {
+			return CodeTooLarge
+		}
+		return CodeValidation
+	}
+	if errors.Is(err, ErrChecksumMismatch) || hasAPIErrorCode(err, "BadDigest", "InvalidDigest") {
+		return CodeChecksumMismatch
+	}
+
+	status := remoteStatus(err)
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, fs.ErrNotExist), isS3NotFound(err),
+		status == http.StatusNotFound, status == http.StatusGone:
+		return CodeNotFound
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, ErrNetworkDisallowed),
+		hasAPIErrorCode(err, "AccessDenied", "AllAccessDisabled", "Forbidden",
+			"InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken"),
+		status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return CodeAccessDenied
+	case errors.Is(err, ErrTooLarge), errors.Is(err, ErrTooManyObjects), errors.Is(err, ErrMetadataTooLarge),
+		hasAPIErrorCode(err, "EntityTooLarge", "MetadataTooLarge"),
+		status == http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case errors.Is(err, ErrInvalidSource), errors.Is(err, ErrReadOnly):
+		return CodeInvalidSource
+	case errors.Is(err, ErrInvalidOption), errors.Is(err, ErrInvalidArgument),
+		errors.Is(err, ErrTemplate), errors.Is(err, ErrUnexpectedType),
+		errors.Is(err, ErrNotText), errors.Is(err, ErrNotPDF), errors.Is(err, ErrExists),
+		errors.Is(err, ErrNoHandler), errors.Is(err, ErrFileValidation):
+		return CodeValidation
+	case status >= 500:
+		return CodeRemote5xx
+	case status >= 400:
+		return CodeRemote4xx
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return CodeRemote5xx
+	}
+	return CodeUnknown
+}
+
+// isTimeoutError reports whether err is a spent deadline or a stall.
+func isTimeoutError(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrStalled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// hasAPIErrorCode reports whether err wraps a smithy API error (as returned
+// by the AWS SDK) with one of codes.
+func hasAPIErrorCode(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, c := range codes {
+		if apiErr.ErrorCode() == c {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteStatus returns the first HTTP status found in err's chain: a
+// FileError's or ProbeError's StatusCode, or an AWS SDK response error's.
+// It returns 0 when there is none.
+func remoteStatus(err error) int {
+	status := 0
+	walkErrors(err, func(e error) bool {
+		switch e := e.(type) {
+		case *FileError:
+			status = e.StatusCode
+		case *ProbeError:
+			status = e.StatusCode
+		case *awshttp.ResponseError:
+			if e.ResponseError != nil && e.Response != nil {
+				status = e.HTTPStatusCode()
+			}
+		}
+		return status != 0
+	})
+	return status
+}
+
+// walkErrors calls fn on err and every error it wraps, depth first, until
+// fn returns true.
+func walkErrors(err error, fn func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if fn(err) {
+		return true
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return walkErrors(u.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			if walkErrors(e, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
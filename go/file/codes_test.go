@@ -0,0 +1,128 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestCodeOf_Sentinels(t *testing.T) {
+	tests := []struct {
+		sentinel error
+		want     ErrorCode
+	}{
+		{ErrInvalidSource, CodeInvalidSource},
+		{ErrNotFound, CodeNotFound},
+		{ErrS3, CodeUnknown},
+		{ErrHTTP, CodeUnknown},
+		{ErrRead, CodeUnknown},
+		{ErrWrite, CodeUnknown},
+		{ErrPanic, CodeUnknown},
+		{ErrChecksumMismatch, CodeChecksumMismatch},
+		{ErrTimeout, CodeTimeout},
+		{ErrNotText, CodeValidation},
+		{ErrNotPDF, CodeValidation},
+		{ErrExists, CodeValidation},
+		{ErrNoHandler, CodeValidation},
+		{ErrCompression, CodeUnknown},
+		{ErrInvalidOption, CodeValidation},
+		{ErrUnexpectedType, CodeValidation},
+		{ErrTemplate, CodeValidation},
+		{ErrTooManyObjects, CodeTooLarge},
+		{ErrInvalidArgument, CodeValidation},
+		{ErrStalled, CodeTimeout},
+		{ErrNetworkDisallowed, CodeAccessDenied},
+		{ErrReadOnly, CodeInvalidSource},
+		{ErrShuttingDown, CodeUnknown},
+		{ErrMetadataTooLarge, CodeTooLarge},
+		{ErrTooLarge, CodeTooLarge},
+		{ErrFileValidation, CodeValidation},
+	}
+	for _, tt := range tests {
+		fe := newError(tt.sentinel, "Op", errors.New("cause"))
+		if got := fe.Code(); got != tt.want {
+			t.Errorf("Code() for %v = %q, want %q", tt.sentinel, got, tt.want)
+		}
+		if got := CodeOf(fmt.Errorf("wrapped: %w", fe)); got != tt.want {
+			t.Errorf("CodeOf(wrapped %v) = %q, want %q", tt.sentinel, got, tt.want)
+		}
+	}
+	if got := CodeOf(nil); got != "" {
+		t.Errorf("CodeOf(nil) = %q, want empty", got)
+	}
+}
+
+func TestCodeOf_WrappedCauses(t *testing.T) {
+	apiErr := func(code string) error { return &smithy.GenericAPIError{Code: code, Message: "m"} }
+	respErr := func(status int, err error) error {
+		return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      err,
+		}}
+	}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	dialTimeout := &net.OpError{Op: "dial", Net: "tcp", Err: &timeoutErr{}}
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"S3 NoSuchKey", newError(ErrS3, "NewFromS3", &types.NoSuchKey{}), CodeNotFound},
+		{"S3 404 response", newError(ErrS3, "Exists", respErr(404, apiErr("NotFound"))), CodeNotFound},
+		{"S3 AccessDenied", newError(ErrS3, "UploadToS3", respErr(403, apiErr("AccessDenied"))), CodeAccessDenied},
+		{"S3 EntityTooLarge", newError(ErrS3, "UploadToS3", respErr(400, apiErr("EntityTooLarge"))), CodeTooLarge},
+		{"S3 BadDigest", newError(ErrS3, "UploadToS3", respErr(400, apiErr("BadDigest"))), CodeChecksumMismatch},
+		{"S3 SlowDown 503", newError(ErrS3, "UploadToS3", respErr(503, apiErr("SlowDown"))), CodeRemote5xx},
+		{"S3 other 400", newError(ErrS3, "UploadToS3", respErr(400, apiErr("InvalidRequest"))), CodeRemote4xx},
+		{"bare API error", apiErr("InvalidAccessKeyId"), CodeAccessDenied},
+		{"HTTP 404", &FileError{Sentinel: ErrHTTP, Op: "NewFromURL", StatusCode: 404}, CodeNotFound},
+		{"HTTP 401", &FileError{Sentinel: ErrHTTP, Op: "NewFromURL", StatusCode: 401}, CodeAccessDenied},
+		{"HTTP 413", &FileError{Sentinel: ErrHTTP, Op: "UploadToURL", StatusCode: 413}, CodeTooLarge},
+		{"HTTP 502", &FileError{Sentinel: ErrHTTP, Op: "NewFromURL", StatusCode: 502}, CodeRemote5xx},
+		{"HTTP 422", &FileError{Sentinel: ErrHTTP, Op: "NewFromURL", StatusCode: 422}, CodeRemote4xx},
+		{"retries wrapping 503", newError(ErrHTTP, "UploadToURL", fmt.Errorf("giving up: %w",
+			&FileError{Sentinel: ErrHTTP, Op: "UploadToURL", StatusCode: 503})), CodeRemote5xx},
+		{"probe 410", &ProbeError{Kind: ProbeHTTPStatus, StatusCode: 410}, CodeNotFound},
+		{"connection refused", newError(ErrHTTP, "NewFromURL", refused), CodeRemote5xx},
+		{"dial timeout", newError(ErrHTTP, "NewFromURL", dialTimeout), CodeTimeout},
+		{"deadline exceeded", newError(ErrS3, "NewFromS3", context.DeadlineExceeded), CodeTimeout},
+		{"canceled", newError(ErrS3, "NewFromS3", context.Canceled), CodeUnknown},
+		{"os not exist", newError(ErrRead, "NewFromFile", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}), CodeNotFound},
+		{"os permission", newError(ErrWrite, "Save", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}), CodeAccessDenied},
+		{"size validation", &FileValidationError{Kind: KindSize, ActualSize: 10, MaxSize: 1}, CodeTooLarge},
+		{"mime validation", fmt.Errorf("upload: %w", &FileValidationError{Kind: KindMime}), CodeValidation},
+		{"joined", errors.Join(errors.New("other"), newError(ErrNotFound, "Read", nil)), CodeNotFound},
+		{"plain error", errors.New("boom"), CodeUnknown},
+	}
+	for _, tt := range tests {
+		if got := CodeOf(tt.err); got != tt.want {
+			t.Errorf("%s: CodeOf = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPStatusError_RecordsStatusCode(t *testing.T) {
+	resp := &http.Response{StatusCode: 404, Body: http.NoBody, Header: http.Header{}}
+	if e := httpStatusError("NewFromURL", resp); e.StatusCode != 404 || e.Code() != CodeNotFound {
+		t.Errorf("StatusCode = %d, Code = %q", e.StatusCode, e.Code())
+	}
+}
+
+// timeoutErr is a net.Error that reports a timeout.
+type timeoutErr struct{}
+
+func (*timeoutErr) Error() string   { return "i/o timeout" }
+func (*timeoutErr) Timeout() bool   { return true }
+func (*timeoutErr) Temporary() bool { return true }
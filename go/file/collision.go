@@ -0,0 +1,102 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CollisionStrategy decides what a save does when its destination path is
+// already taken.
+type CollisionStrategy int
+
+const (
+	// CollisionOverwrite replaces the existing file. This is the default.
+	CollisionOverwrite CollisionStrategy = iota
+	// CollisionErrorOut leaves the existing file alone and fails with
+	// ErrExists.
+	CollisionErrorOut
+	// CollisionRenameWithSuffix writes to the first free name of the form
+	// "report (1).pdf", "report (2).pdf", ... The chosen name is reported by
+	// the returned File.
+	CollisionRenameWithSuffix
+)
+
+// maxCollisionSuffix bounds the search for a free suffixed name.
+const maxCollisionSuffix = 10000
+
+// SaveToDir saves the file into dir under its own Name, made safe with
+// SanitizeFilename (so an overlong name is shortened, keeping its
+// extension). It accepts the same options as Save; with
+// CollisionRenameWithSuffix the returned File's Name is the name actually
+// used. Fails with ErrWrite when the file has no usable Name.
+//
+// With WithDirTemplate, dir is expanded with ExpandTemplate first, so
+// "archive/{{.Year}}/{{.Month}}" files by modification date; a template
+// error is returned before anything is written.
+func (f *File) SaveToDir(dir string, opts ...SaveOption) (*File, error) {
+	name := SanitizeFilename(f.meta.Name)
+	if name == "" {
+		return nil, newError(ErrWrite, "SaveToDir", fmt.Errorf("file has no name to save under"))
+	}
+	o := newSaveOptions(opts)
+	if o.dirTemplate {
+		expanded, err := f.expandTemplate(dir, "", "SaveToDir")
+		if err != nil {
+			return nil, err
+		}
+		dir = expanded
+	}
+	return f.save(filepath.Join(dir, name), o, "SaveToDir")
+}
+
+// claimPath resolves destPath under strategy s. For the non-overwriting
+// strategies the returned path is created empty with O_EXCL, so concurrent
+// saves into the same directory can never pick the same name.
+func claimPath(destPath string, s CollisionStrategy, op string) (string, error) {
+	switch s {
+	case CollisionErrorOut:
+		if err := createExclusive(destPath); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return "", newError(ErrExists, op, err)
+			}
+			return "", newError(ErrWrite, op, err)
+		}
+		return destPath, nil
+	case CollisionRenameWithSuffix:
+		for i := 0; i < maxCollisionSuffix; i++ {
+			candidate := suffixedPath(destPath, i)
+			err := createExclusive(candidate)
+			if err == nil {
+				return candidate, nil
+			}
+			if !errors.Is(err, os.ErrExist) {
+				return "", newError(ErrWrite, op, err)
+			}
+		}
+		return "", newError(ErrExists, op, fmt.Errorf("no free name for %s after %d attempts", destPath, maxCollisionSuffix))
+	default:
+		return destPath, nil
+	}
+}
+
+// createExclusive creates p empty, failing if it already exists.
+func createExclusive(p string) error {
+	fl, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return fl.Close()
+}
+
+// suffixedPath returns p with " (n)" inserted before its extension; n == 0
+// returns p unchanged. Dotfiles such as ".env" are treated as having no
+// extension.
+func suffixedPath(p string, n int) string {
+	if n == 0 {
+		return p
+	}
+	dir, base := filepath.Split(p)
+	return filepath.Join(dir, fitFilename(base, fmt.Sprintf(" (%d)", n)))
+}
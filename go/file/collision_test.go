@@ -0,0 +1,99 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveToDir_Overwrite(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := NewFromBytes([]byte("first"), MetadataHint{Name: "report.pdf"})
+	b, _ := NewFromBytes([]byte("second"), MetadataHint{Name: "report.pdf"})
+
+	if _, err := a.SaveToDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := b.SaveToDir(dir, WithCollisionStrategy(CollisionOverwrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Name() != "report.pdf" {
+		t.Errorf("Name = %q", saved.Name())
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "report.pdf"))
+	if string(got) != "second" {
+		t.Errorf("content = %q, want overwritten", got)
+	}
+}
+
+func TestSaveToDir_ErrorOut(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := NewFromBytes([]byte("first"), MetadataHint{Name: "report.pdf"})
+	b, _ := NewFromBytes([]byte("second"), MetadataHint{Name: "report.pdf"})
+
+	if _, err := a.SaveToDir(dir, WithCollisionStrategy(CollisionErrorOut)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := b.SaveToDir(dir, WithCollisionStrategy(CollisionErrorOut))
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "report.pdf"))
+	if string(got) != "first" {
+		t.Errorf("content = %q, want original left alone", got)
+	}
+}
+
+func TestSaveToDir_RenameWithSuffix_ThreeWay(t *testing.T) {
+	dir := t.TempDir()
+	want := []string{"report.pdf", "report (1).pdf", "report (2).pdf"}
+	for i, name := range want {
+		f, _ := NewFromBytes([]byte{byte('a' + i)}, MetadataHint{Name: "report.pdf"})
+		saved, err := f.SaveToDir(dir, WithCollisionStrategy(CollisionRenameWithSuffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Name() != name || saved.Path() != filepath.Join(dir, name) {
+			t.Errorf("save %d: Name = %q, Path = %q, want %q", i, saved.Name(), saved.Path(), name)
+		}
+		got, _ := os.ReadFile(filepath.Join(dir, name))
+		if string(got) != string(rune('a'+i)) {
+			t.Errorf("%s content = %q", name, got)
+		}
+	}
+}
+
+func TestSave_CollisionStrategyAppliesToExplicitPath(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), ".env")
+	for i := 0; i < 2; i++ {
+		f, _ := NewFromBytes([]byte("X=1"))
+		if _, err := f.Save(dest, WithCollisionStrategy(CollisionRenameWithSuffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(dest + " (1)"); err != nil {
+		t.Errorf("expected dotfile suffixed as %q: %v", ".env (1)", err)
+	}
+}
+
+func TestSaveToDir_RequiresName(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	f.meta.Name = ""
+	if _, err := f.SaveToDir(t.TempDir()); !errors.Is(err, ErrWrite) {
+		t.Errorf("expected ErrWrite, got %v", err)
+	}
+}
+
+func TestSaveToDir_StripsDirectoryFromName(t *testing.T) {
+	dir := t.TempDir()
+	f, _ := NewFromBytes([]byte("x"), MetadataHint{Name: "../../escape.txt"})
+	saved, err := f.SaveToDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Path() != filepath.Join(dir, "escape.txt") {
+		t.Errorf("Path = %q, want inside %s", saved.Path(), dir)
+	}
+}
@@ -0,0 +1,217 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// commitRename moves a staged file into place. Tests replace it to inject
+// failures.
+var commitRename = os.Rename
+
+// CommitAll writes a group of files into destDir so that either all of them
+// land or none do. Keys of files are slash-separated paths relative to
+// destDir; values are the content to write there.
+//
+// Every file is first written and fsync'd into a staging directory inside
+// destDir (so the final renames never cross filesystems). Only once all of
+// them are durable are they renamed into place, in sorted key order. If a
+// rename fails, the files already moved are removed, any files they
+// replaced are restored, and directories CommitAll created are removed
+// again, leaving destDir as it was. The staging directory is always
+// removed.
+//
+// The group is not atomic to concurrent readers: between the first and last
+// rename, a reader can observe some new files alongside old ones. A crash in
+// that window leaves the renamed files in place and the remainder (plus any
+// replaced originals) in a ".commit-*" directory inside destDir.
+//
+// A file that fails to stage does not stop the others from staging, so
+// the returned *BatchError lists every file that could not be written;
+// nothing is renamed into place when any fails.
+//
+// SaveOption values apply to each staged write (e.g. WithVerify). With
+// CollisionErrorOut, CommitAll fails with ErrExists before writing anything
+// if any destination already exists; every other strategy overwrites.
+func CommitAll(files map[string]*File, destDir string, opts ...SaveOption) error {
+	o := newSaveOptions(opts)
+	if err := checkOptions("CommitAll", o.validate()); err != nil {
+		return err
+	}
+	destDir, err := o.resolveDest(destDir, "CommitAll")
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		clean, err := commitName(name)
+		if err != nil {
+			return newError(ErrWrite, "CommitAll", err)
+		}
+		if files[name] == nil {
+			return newError(ErrWrite, "CommitAll", fmt.Errorf("nil file for %q", name))
+		}
+		if clean != name {
+			return newError(ErrWrite, "CommitAll", fmt.Errorf("path %q is not clean (want %q)", name, clean))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if o.collision == CollisionErrorOut {
+		for _, name := range names {
+			if _, err := os.Lstat(filepath.Join(destDir, filepath.FromSlash(name))); err == nil {
+				return newError(ErrExists, "CommitAll", fmt.Errorf("%s already exists", name))
+			}
+		}
+	}
+
+	if err := o.mkdirAll(destDir); err != nil {
+		return newError(ErrWrite, "CommitAll", err)
+	}
+	staging, err := os.MkdirTemp(destDir, ".commit-*")
+	if err != nil {
+		return newError(ErrWrite, "CommitAll", err)
+	}
+	defer os.RemoveAll(staging)
+
+	// Stage and fsync every file, reporting every one that fails.
+	if err := os.MkdirAll(filepath.Join(staging, "new"), 0o755); err != nil {
+		return newError(ErrWrite, "CommitAll", err)
+	}
+	var failed []*ItemError
+	for i, name := range names {
+		if err := stageCommit(files[name], filepath.Join(staging, "new", fmt.Sprint(i)), o); err != nil {
+			failed = append(failed, itemError(i, name, "", "CommitAll", ErrWrite, err))
+		}
+	}
+	if err := newBatchError("CommitAll", len(names), 0, failed); err != nil {
+		return err
+	}
+	if err := syncPath(filepath.Join(staging, "new")); err != nil {
+		return newError(ErrWrite, "CommitAll", err)
+	}
+
+	// Rename into place, remembering enough to undo each step.
+	var (
+		committed   []string              // destinations renamed into place
+		backups     = map[string]string{} // destination -> backed-up original
+		createdDirs []string              // directories created, parents first
+	)
+	rollback := func() {
+		for i := len(committed) - 1; i >= 0; i-- {
+			dest := committed[i]
+			_ = os.Remove(dest)
+			if b, ok := backups[dest]; ok {
+				_ = os.Rename(b, dest)
+			}
+		}
+		for i := len(createdDirs) - 1; i >= 0; i-- {
+			_ = os.Remove(createdDirs[i])
+		}
+	}
+
+	for i, name := range names {
+		dest := filepath.Join(destDir, filepath.FromSlash(name))
+		dirs, err := mkdirAllTracked(filepath.Dir(dest), o.dirMode)
+		createdDirs = append(createdDirs, dirs...)
+		if err != nil {
+			rollback()
+			return newError(ErrWrite, "CommitAll", err)
+		}
+		if _, err := os.Lstat(dest); err == nil {
+			backup := filepath.Join(staging, "old", fmt.Sprint(i))
+			if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+				rollback()
+				return newError(ErrWrite, "CommitAll", err)
+			}
+			if err := os.Rename(dest, backup); err != nil {
+				rollback()
+				return newError(ErrWrite, "CommitAll", err)
+			}
+			backups[dest] = backup
+		}
+		if err := commitRename(filepath.Join(staging, "new", fmt.Sprint(i)), dest); err != nil {
+			if b, ok := backups[dest]; ok {
+				_ = os.Rename(b, dest)
+				delete(backups, dest)
+			}
+			rollback()
+			return newBatchError("CommitAll", len(names), 0,
+				[]*ItemError{itemError(i, name, "", "CommitAll", ErrWrite, fmt.Errorf("commit: %w", err))})
+		}
+		committed = append(committed, dest)
+	}
+	return nil
+}
+
+// stageCommit writes f to staged and fsyncs it.
+func stageCommit(f *File, staged string, o saveOptions) error {
+	r, err := f.contentReader(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := writeFromReader(r, staged, o, f.sourceDigests[o.algo], "CommitAll"); err != nil {
+		return err
+	}
+	if err := syncPath(staged); err != nil {
+		return newError(ErrWrite, "CommitAll", err)
+	}
+	return nil
+}
+
+// commitName validates a CommitAll key and returns its cleaned form.
+func commitName(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("empty file name")
+	}
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(name)))
+	if filepath.IsAbs(filepath.FromSlash(name)) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("path %q escapes the destination directory", name)
+	}
+	return clean, nil
+}
+
+// mkdirAllTracked is os.MkdirAll that also returns the directories it
+// created, outermost first.
+func mkdirAllTracked(dir string, perm fs.FileMode) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	var created []string
+	for i := len(missing) - 1; i >= 0; i-- {
+		err := os.Mkdir(missing[i], perm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return created, err
+		}
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
+
+// syncPath fsyncs the file or directory at p.
+func syncPath(p string) error {
+	fl, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer fl.Close()
+	return fl.Sync()
+}
@@ -0,0 +1,123 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dirSnapshot maps every path under dir to its content ("<dir>" for
+// directories).
+func dirSnapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	snap := map[string]string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if info.IsDir() {
+			snap[rel] = "<dir>"
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		snap[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func exportFiles(t *testing.T) map[string]*File {
+	t.Helper()
+	files := map[string]*File{}
+	for _, name := range []string{"a.csv", "b.csv", "meta/c.json", "meta/d.json", "e.txt"} {
+		f, err := NewFromBytes([]byte("new " + name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = f
+	}
+	return files
+}
+
+func TestCommitAll_WritesEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := CommitAll(exportFiles(t), dir, WithVerify(AlgorithmSHA256)); err != nil {
+		t.Fatal(err)
+	}
+	snap := dirSnapshot(t, dir)
+	for _, name := range []string{"a.csv", "b.csv", "meta/c.json", "meta/d.json", "e.txt"} {
+		if snap[filepath.FromSlash(name)] != "new "+name {
+			t.Errorf("%s = %q", name, snap[filepath.FromSlash(name)])
+		}
+	}
+	// ".", "meta" and the five files; the staging directory is gone.
+	if len(snap) != 7 {
+		t.Errorf("unexpected entries left in destination: %v", snap)
+	}
+}
+
+func TestCommitAll_RollsBackOnNthRename(t *testing.T) {
+	for n := 1; n <= 5; n++ {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "b.csv"), []byte("old b"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "e.txt"), []byte("old e"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		before := dirSnapshot(t, dir)
+
+		calls := 0
+		commitRename = func(from, to string) error {
+			calls++
+			if calls == n {
+				return errors.New("disk full")
+			}
+			return os.Rename(from, to)
+		}
+		err := CommitAll(exportFiles(t), dir)
+		commitRename = os.Rename
+
+		if !errors.Is(err, ErrWrite) {
+			t.Fatalf("n=%d: expected ErrWrite, got %v", n, err)
+		}
+		after := dirSnapshot(t, dir)
+		if len(after) != len(before) {
+			t.Errorf("n=%d: dir changed: before %v, after %v", n, before, after)
+			continue
+		}
+		for p, v := range before {
+			if after[p] != v {
+				t.Errorf("n=%d: %s = %q, want %q", n, p, after[p], v)
+			}
+		}
+	}
+}
+
+func TestCommitAll_ErrorOutChecksBeforeWriting(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "e.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := CommitAll(exportFiles(t), dir, WithCollisionStrategy(CollisionErrorOut))
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	if snap := dirSnapshot(t, dir); len(snap) != 2 {
+		t.Errorf("dir changed: %v", snap)
+	}
+}
+
+func TestCommitAll_RejectsEscapingNames(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	for _, name := range []string{"../x", "/abs", "", ".", "a/../../x"} {
+		if err := CommitAll(map[string]*File{name: f}, t.TempDir()); !errors.Is(err, ErrWrite) {
+			t.Errorf("%q: expected ErrWrite, got %v", name, err)
+		}
+	}
+}
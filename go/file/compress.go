@@ -0,0 +1,261 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Codec names a compression format. Its value is the HTTP Content-Encoding
+// token for the format.
+type Codec string
+
+const (
+	// CodecGzip is gzip (RFC 1952). Always available.
+	CodecGzip Codec = "gzip"
+	// CodecZstd is Zstandard. Available once the zstdcodec module is
+	// registered.
+	CodecZstd Codec = "zstd"
+	// CodecBrotli is Brotli. Available once the brotlicodec module is
+	// registered.
+	CodecBrotli Codec = "br"
+)
+
+// AttrContentEncoding is the Metadata.Attributes key recording the codec a
+// File's content is compressed with.
+const AttrContentEncoding = "content-encoding"
+
+// Compressor implements a Codec. Implementations other than gzip live in
+// subpackages so their dependencies are only linked in when used, and
+// register themselves with RegisterCodec.
+type Compressor interface {
+	// NewWriter returns a writer that compresses into w. level 0 selects
+	// the codec's default; other values use the codec's own scale.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+	// NewReader returns a reader that decompresses r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// codecInfo describes how a codec's output is recognized and labelled.
+type codecInfo struct {
+	ext      string
+	mimeType string
+	magic    []byte // nil when the format has no signature
+}
+
+// codecInfos covers the codecs this package knows how to label. Brotli
+// streams have no signature, so they are recognized by extension or
+// AttrContentEncoding only.
+var codecInfos = map[Codec]codecInfo{
+	CodecGzip:   {ext: "gz", mimeType: "application/gzip", magic: []byte{0x1f, 0x8b}},
+	CodecZstd:   {ext: "zst", mimeType: "application/zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	CodecBrotli: {ext: "br", mimeType: "application/x-brotli"},
+}
+
+var (
+	codecMu     sync.RWMutex
+	compressors = map[Codec]Compressor{CodecGzip: gzipCompressor{}}
+)
+
+// RegisterCodec makes c the implementation of codec, replacing any previous
+// registration. Passing a nil c removes it. Registered codecs are also
+// advertised in NewFromURL's Accept-Encoding header. Safe for concurrent
+// use.
+func RegisterCodec(codec Codec, c Compressor) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	if c == nil {
+		delete(compressors, codec)
+		return
+	}
+	compressors[codec] = c
+}
+
+// compressorFor returns the registered implementation of codec.
+func compressorFor(codec Codec, op string) (Compressor, error) {
+	codecMu.RLock()
+	c, ok := compressors[codec]
+	codecMu.RUnlock()
+	if !ok {
+		return nil, newError(ErrCompression, op, fmt.Errorf("codec %q is not registered", codec))
+	}
+	return c, nil
+}
+
+// acceptEncoding lists the registered codecs for an Accept-Encoding header,
+// gzip first.
+func acceptEncoding() string {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	enc := []string{string(CodecGzip)}
+	for _, c := range []Codec{CodecZstd, CodecBrotli} {
+		if _, ok := compressors[c]; ok {
+			enc = append(enc, string(c))
+		}
+	}
+	return strings.Join(enc, ", ")
+}
+
+// Compress returns a new in-memory File holding the content compressed with
+// codec at level (0 for the codec's default). The result is named after the
+// original plus the codec's extension ("report.csv" becomes
+// "report.csv.zst"), carries the codec's MIME type, and records codec under
+// AttrContentEncoding.
+func (f *File) Compress(codec Codec, level int) (*File, error) {
+	c, err := compressorFor(codec, "Compress")
+	if err != nil {
+		return nil, err
+	}
+	r, err := f.openStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	var buf bytes.Buffer
+	w, err := c.NewWriter(&buf, level)
+	if err != nil {
+		return nil, newError(ErrCompression, "Compress", err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return nil, newError(ErrCompression, "Compress", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, newError(ErrCompression, "Compress", err)
+	}
+
+	info := codecInfos[codec]
+	hint := MetadataHint{
+		MimeType:   info.mimeType,
+		Extension:  info.ext,
+		Attributes: map[string]string{AttrContentEncoding: string(codec)},
+	}
+	if f.meta.Name != "" && info.ext != "" {
+		hint.Name = f.meta.Name + "." + info.ext
+	}
+	out, err := NewFromBytes(buf.Bytes(), hint)
+	if err != nil {
+		return nil, err
+	}
+	out.inheritProvenance(f)
+	out.inheritCreatedAt(f)
+	return out, nil
+}
+
+// Decompress returns a new in-memory File holding the decompressed content.
+// The codec is chosen from the content's magic bytes when the format has a
+// signature (gzip, zstd), otherwise from AttrContentEncoding or the file
+// extension (.gz, .zst, .br). The codec's extension is stripped from the
+// name and the MIME type is re-detected from the decompressed bytes. Fails
+// with ErrCompression when no codec applies, the codec is not registered,
+// or the content is not valid for it.
+func (f *File) Decompress() (*File, error) {
+	head, err := f.prefixReader()
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, 4)
+	n, _ := io.ReadFull(head, prefix)
+	codec, ok := f.detectCodec(prefix[:n])
+	if !ok {
+		return nil, newError(ErrCompression, "Decompress", fmt.Errorf("cannot tell how %s is compressed", describeFile(f)))
+	}
+	c, err := compressorFor(codec, "Decompress")
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := f.openStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	zr, err := c.NewReader(r)
+	if err != nil {
+		return nil, newError(ErrCompression, "Decompress", fmt.Errorf("%s: %w", codec, err))
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, newError(ErrCompression, "Decompress", fmt.Errorf("%s: %w", codec, err))
+	}
+
+	var hint MetadataHint
+	if ext := codecInfos[codec].ext; ext != "" {
+		hint.Name = strings.TrimSuffix(f.meta.Name, "."+ext)
+	}
+	if hint.Name == f.meta.Name {
+		hint.Name = ""
+	}
+	out, err := NewFromBytes(data, hint)
+	if err != nil {
+		return nil, err
+	}
+	out.inheritProvenance(f)
+	out.inheritCreatedAt(f)
+	return out, nil
+}
+
+// detectCodec picks the codec for f given the first bytes of its content.
+func (f *File) detectCodec(prefix []byte) (Codec, bool) {
+	for codec, info := range codecInfos {
+		if info.magic != nil && bytes.HasPrefix(prefix, info.magic) {
+			return codec, true
+		}
+	}
+	if enc := Codec(strings.ToLower(f.meta.Attributes[AttrContentEncoding])); enc != "" {
+		if _, ok := codecInfos[enc]; ok {
+			return enc, true
+		}
+	}
+	for codec, info := range codecInfos {
+		if strings.EqualFold(f.meta.Extension, info.ext) {
+			return codec, true
+		}
+	}
+	return "", false
+}
+
+// decodeContentEncoding wraps r to undo an HTTP Content-Encoding. It
+// returns ok=false for identity or an unregistered encoding, leaving the
+// body as is.
+func decodeContentEncoding(r io.Reader, encoding string) (io.ReadCloser, bool, error) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || encoding == "identity" {
+		return nil, false, nil
+	}
+	codecMu.RLock()
+	c, ok := compressors[Codec(encoding)]
+	codecMu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	zr, err := c.NewReader(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return zr, true, nil
+}
+
+// gzipCompressor implements CodecGzip with compress/gzip.
+type gzipCompressor struct{}
+
+func (gzipCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
@@ -0,0 +1,95 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var csvPayload = []byte(strings.Repeat("id,name,amount\n1,alice,10.50\n", 200))
+
+func TestCompress_GzipRoundTrip(t *testing.T) {
+	f, _ := NewFromBytes(csvPayload, MetadataHint{Name: "report.csv"})
+	gz, err := f.Compress(CodecGzip, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gz.Name() != "report.csv.gz" || gz.Extension() != "gz" || gz.MimeType() != "application/gzip" {
+		t.Errorf("compressed metadata = %+v", gz.Metadata())
+	}
+	if gz.Metadata().Attributes[AttrContentEncoding] != "gzip" {
+		t.Errorf("Attributes = %v", gz.Metadata().Attributes)
+	}
+	if gz.Size() >= f.Size() {
+		t.Errorf("compressed size %d not smaller than %d", gz.Size(), f.Size())
+	}
+
+	back, err := gz.Decompress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := back.Read()
+	if !bytes.Equal(data, csvPayload) {
+		t.Error("round trip changed the content")
+	}
+	if back.Name() != "report.csv" || back.MimeType() != "text/csv" {
+		t.Errorf("decompressed Name = %q, MimeType = %q", back.Name(), back.MimeType())
+	}
+}
+
+func TestDecompress_DetectsGzipByMagicBytes(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	// Mislabelled as Brotli: the gzip signature wins.
+	f, _ := NewFromBytes(buf.Bytes(), MetadataHint{Name: "hello.txt.br", Extension: "br"})
+	back, err := f.Decompress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := back.ReadText(); got != "hello" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	plain, _ := NewFromBytes([]byte("not compressed"), MetadataHint{Name: "a.txt"})
+	if _, err := plain.Decompress(); !errors.Is(err, ErrCompression) {
+		t.Errorf("plain text: expected ErrCompression, got %v", err)
+	}
+
+	corrupt, _ := NewFromBytes([]byte{0x1f, 0x8b, 0x00, 0x01, 0x02}, MetadataHint{Name: "a.gz"})
+	if _, err := corrupt.Decompress(); !errors.Is(err, ErrCompression) {
+		t.Errorf("corrupt gzip: expected ErrCompression, got %v", err)
+	}
+
+	if _, err := plain.Compress(Codec("lz4"), 0); !errors.Is(err, ErrCompression) {
+		t.Errorf("unknown codec: expected ErrCompression, got %v", err)
+	}
+}
+
+func TestNewFromURL_AdvertisesRegisteredEncodings(t *testing.T) {
+	RegisterCodec(CodecZstd, gzipCompressor{})
+	defer RegisterCodec(CodecZstd, nil)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Accept-Encoding")
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	if _, err := NewFromURL(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got != "gzip, zstd" {
+		t.Errorf("Accept-Encoding = %q", got)
+	}
+}
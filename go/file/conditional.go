@@ -0,0 +1,93 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RefreshFromURL revalidates a URL-sourced File against its URL and
+// reports whether the content changed. The request carries If-None-Match
+// with the stored ETag (Hash) and If-Modified-Since with LastModified,
+// whichever are set. On 304, or a 200 whose ETag matches the stored one
+// under weak comparison (W/"x" matches "x"), the File is left as it is and
+// changed is false. Otherwise the new body replaces the content and the
+// metadata is resolved from the new response, as NewFromURL would without
+// hints. Files not from a URL, or with neither validator, fail with
+// ErrInvalidSource. DownloadTimeout applies when ctx has no deadline.
+func (f *File) RefreshFromURL(ctx context.Context) (bool, error) {
+	if err := f.checkWritable("RefreshFromURL"); err != nil {
+		return false, err
+	}
+	if f.source != SourceURL || f.meta.URL == "" {
+		return false, newError(ErrInvalidSource, "RefreshFromURL", fmt.Errorf("cannot refresh non-URL source %s", f.source))
+	}
+	cond := http.Header{}
+	if f.meta.Hash != "" {
+		cond.Set("If-None-Match", quoteETag(f.meta.Hash))
+	}
+	if !f.meta.LastModified.IsZero() {
+		cond.Set("If-Modified-Since", f.meta.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if len(cond) == 0 {
+		return false, newError(ErrInvalidSource, "RefreshFromURL", errors.New("file has no ETag or Last-Modified to revalidate against"))
+	}
+	if err := checkNetwork(ctx, "RefreshFromURL"); err != nil {
+		return false, err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return false, dl.newError(ctx, ErrHTTP, "RefreshFromURL", err)
+	}
+	defer release()
+
+	_, ro := resolveHints(nil)
+	res, _, err := fetchURL(ctx, dl, "RefreshFromURL", f.meta.URL, ro, cond)
+	if err != nil {
+		return false, err
+	}
+	if res.notModified {
+		return false, nil
+	}
+	if etag := res.resp.Header.Get("ETag"); etag != "" && f.meta.Hash != "" && weakETagMatch(etag, f.meta.Hash) {
+		return false, nil
+	}
+
+	fresh, err := newURLFile(res, f.meta.URL, MetadataHint{}, ro, "RefreshFromURL")
+	if err != nil {
+		return false, err
+	}
+	f.meta = fresh.meta
+	f.data, f.loaded = fresh.data, true
+	f.sourceDigests = fresh.sourceDigests
+	f.transferSize = fresh.transferSize
+	f.redirects = fresh.redirects
+	f.missingErr = nil
+	return true, nil
+}
+
+// quoteETag restores the quotes metadata resolution trims from an ETag,
+// keeping a weak validator's W/ prefix: `W/"x` and `x` become `W/"x"` and
+// `"x"`.
+func quoteETag(hash string) string {
+	if rest, weak := strings.CutPrefix(hash, "W/"); weak {
+		return `W/"` + strings.Trim(rest, `"`) + `"`
+	}
+	return `"` + strings.Trim(hash, `"`) + `"`
+}
+
+// weakETagMatch compares two ETags by their opaque tags, ignoring quotes
+// and any W/ prefix, as RFC 9110's weak comparison does.
+func weakETagMatch(a, b string) bool {
+	opaque := func(s string) string {
+		s, _ = strings.CutPrefix(strings.TrimSpace(s), "W/")
+		return strings.Trim(s, `"`)
+	}
+	return opaque(a) == opaque(b)
+}
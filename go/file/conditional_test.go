@@ -0,0 +1,124 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// etagServer serves body with etag, answering 304 to a matching
+// If-None-Match. bodies counts the responses that carried a body.
+type etagServer struct {
+	body, etag string
+	bodies     atomic.Int32
+	lastINM    atomic.Value
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	inm := r.Header.Get("If-None-Match")
+	s.lastINM.Store(inm)
+	w.Header().Set("ETag", s.etag)
+	if inm != "" && weakETagMatch(inm, s.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.bodies.Add(1)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(s.body))
+}
+
+func TestRefreshFromURL_NotModified(t *testing.T) {
+	for _, etag := range []string{`"v1"`, `W/"v1"`} {
+		srv := &etagServer{body: "hello", etag: etag}
+		ts := httptest.NewServer(srv)
+		defer setMockHTTP(ts.Client())()
+
+		f, err := NewFromURL(ts.URL + "/a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed, err := f.RefreshFromURL(context.Background())
+		if err != nil {
+			t.Fatalf("%s: RefreshFromURL: %v", etag, err)
+		}
+		if changed {
+			t.Errorf("%s: changed = true on 304", etag)
+		}
+		if got := srv.lastINM.Load(); got != etag {
+			t.Errorf("If-None-Match = %q, want %q", got, etag)
+		}
+		if n := srv.bodies.Load(); n != 1 {
+			t.Errorf("%s: server sent %d bodies, want 1", etag, n)
+		}
+		if data, _ := f.Read(); string(data) != "hello" {
+			t.Errorf("%s: content after 304 = %q", etag, data)
+		}
+		ts.Close()
+	}
+}
+
+func TestRefreshFromURL_Changed(t *testing.T) {
+	srv := &etagServer{body: "hello", etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	f, err := NewFromURL(ts.URL + "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.body, srv.etag = "hello, world", `"v2"`
+	changed, err := f.RefreshFromURL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("changed = false after the content changed")
+	}
+	if data, _ := f.Read(); string(data) != "hello, world" {
+		t.Errorf("content = %q", data)
+	}
+	if f.Metadata().Hash != "v2" || f.Size() != 12 {
+		t.Errorf("metadata not replaced: %+v", f.Metadata())
+	}
+	if changed, err := f.RefreshFromURL(context.Background()); err != nil || changed {
+		t.Errorf("second refresh = %v, %v; want unchanged", changed, err)
+	}
+}
+
+func TestRefreshFromURL_IgnoredConditionalSameWeakETag(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if hits.Load() == 1 {
+			w.Header().Set("ETag", `"v1"`)
+		} else {
+			w.Header().Set("ETag", `W/"v1"`)
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	f, err := NewFromURL(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := f.RefreshFromURL(context.Background()); err != nil || changed {
+		t.Errorf("RefreshFromURL = %v, %v; want unchanged", changed, err)
+	}
+}
+
+func TestRefreshFromURL_InvalidSource(t *testing.T) {
+	b, _ := NewFromBytes([]byte("x"))
+	if _, err := b.RefreshFromURL(context.Background()); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("bytes File: err = %v, want ErrInvalidSource", err)
+	}
+	u := &File{source: SourceURL, meta: Metadata{URL: "http://example.invalid/"}}
+	if _, err := u.RefreshFromURL(context.Background()); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("no validators: err = %v, want ErrInvalidSource", err)
+	}
+}
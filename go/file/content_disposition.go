@@ -1,8 +1,11 @@
 package file
 
-import (
-	"strings"
-)
+import "strings"
+
+// AttrRawContentDisposition is the Metadata.Attributes key recording the
+// original Content-Disposition value when its filename had to be
+// sanitized, for forensics.
+const AttrRawContentDisposition = "raw-content-disposition"
 
 // ParseContentDisposition extracts the filename from a Content-Disposition header value.
 // It handles both RFC 6266 forms:
@@ -20,20 +23,22 @@ func ParseContentDisposition(header string) string {
 	var filename string
 	var filenameStar string
 
-	// Normalize and split on semicolons.
-	parts := strings.Split(header, ";")
-	for _, part := range parts {
+	// Walk the semicolon-separated parameters without splitting, so a
+	// hostile header of many empty parameters costs no allocations.
+	for rest := header; rest != ""; {
+		var part string
+		part, rest, _ = strings.Cut(rest, ";")
 		part = strings.TrimSpace(part)
 
 		// Check for filename*= (RFC 5987 extended parameter).
-		if strings.HasPrefix(strings.ToLower(part), "filename*=") {
-			val := part[len("filename*="):]
+		if hasPrefixFold(part, "filename*=") {
+			val := unquote(part[len("filename*="):])
 			// Format is: charset'language'value (e.g., UTF-8''example%20file.txt)
 			if idx := strings.LastIndex(val, "'"); idx >= 0 {
 				val = val[idx+1:]
 			}
+			// Decode last: an encoded quote is part of the name.
 			val = decodePercent(val)
-			val = unquote(val)
 			if val != "" {
 				filenameStar = val
 			}
@@ -41,7 +46,7 @@ func ParseContentDisposition(header string) string {
 		}
 
 		// Check for filename=.
-		if strings.HasPrefix(strings.ToLower(part), "filename=") {
+		if hasPrefixFold(part, "filename=") {
 			val := part[len("filename="):]
 			val = unquote(val)
 			if val != "" {
@@ -57,6 +62,90 @@ func ParseContentDisposition(header string) string {
 	return filename
 }
 
+// BuildContentDisposition returns a Content-Disposition value of the given
+// disposition type ("attachment" when empty) naming filename. The result
+// is always ASCII, as S3 and HTTP headers require. A plain ASCII name is
+// sent as a quoted filename parameter. Any other name also gets an
+// RFC 5987 filename* parameter carrying the exact UTF-8 name, which
+// ParseContentDisposition and browsers prefer, plus an ASCII fallback
+// filename in which each run of other characters becomes "_".
+func BuildContentDisposition(disposition, filename string) string {
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	if filename == "" {
+		return disposition
+	}
+	fallback := asciiFilename(filename)
+	if fallback == filename {
+		return disposition + `; filename="` + filename + `"`
+	}
+	return disposition + `; filename="` + fallback + `"; filename*=UTF-8''` + encodeRFC5987(filename)
+}
+
+// asciiFilename replaces each run of characters that cannot appear in a
+// quoted header parameter (non-ASCII, controls, quote, backslash, and the
+// ";" naive parsers split on) with "_".
+func asciiFilename(name string) string {
+	var b strings.Builder
+	replaced := false
+	for _, r := range name {
+		if r < 0x20 || r >= 0x7f || strings.ContainsRune(`"\;`, r) {
+			if !replaced {
+				b.WriteByte('_')
+			}
+			replaced = true
+			continue
+		}
+		b.WriteRune(r)
+		replaced = false
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes s as an RFC 5987 value-chars string.
+func encodeRFC5987(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0xf])
+	}
+	return b.String()
+}
+
+// contentDispositionName returns the filename in a Content-Disposition
+// value reduced to a safe base name, and whether sanitizing changed it.
+// Servers control this header, so a name like "../../.bashrc" or
+// `C:\temp\x.exe` must not reach Metadata.Name, where it could later be
+// joined onto a directory: control characters (including NUL) are dropped,
+// everything up to the last "/" or "\" is removed, and "." and ".." become
+// empty.
+func contentDispositionName(header string) (name string, sanitized bool) {
+	raw := ParseContentDisposition(header)
+	name = untrustedBaseName(raw)
+	return name, name != raw
+}
+
+// noteContentDisposition records header under AttrRawContentDisposition
+// when its filename was sanitized.
+func noteContentDisposition(m *Metadata, header string, sanitized bool) {
+	if sanitized {
+		m.Attributes = mergeAttributes(m.Attributes, map[string]string{AttrRawContentDisposition: header})
+	}
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring ASCII case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
 // unquote removes surrounding double quotes from a string.
 func unquote(s string) string {
 	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
@@ -1,71 +1,235 @@
 package file
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// parseContentDispositionTests also seeds FuzzParseContentDisposition.
+var parseContentDispositionTests = []struct {
+	name   string
+	header string
+	want   string
+}{
+	{
+		name:   "empty header",
+		header: "",
+		want:   "",
+	},
+	{
+		name:   "attachment with quoted filename",
+		header: `attachment; filename="example.txt"`,
+		want:   "example.txt",
+	},
+	{
+		name:   "attachment with unquoted filename",
+		header: `attachment; filename=example.txt`,
+		want:   "example.txt",
+	},
+	{
+		name:   "inline with filename",
+		header: `inline; filename="report.pdf"`,
+		want:   "report.pdf",
+	},
+	{
+		name:   "no filename parameter",
+		header: `attachment`,
+		want:   "",
+	},
+	{
+		name:   "filename star with UTF-8 encoding",
+		header: `attachment; filename*=UTF-8''example%20file.txt`,
+		want:   "example file.txt",
+	},
+	{
+		name:   "filename star takes precedence when last",
+		header: `attachment; filename="fallback.txt"; filename*=UTF-8''preferred%20name.txt`,
+		want:   "preferred name.txt",
+	},
+	{
+		name:   "filename with spaces in quotes",
+		header: `attachment; filename="my document.pdf"`,
+		want:   "my document.pdf",
+	},
+	{
+		name:   "mixed case Filename",
+		header: `attachment; Filename="CaseTest.doc"`,
+		want:   "CaseTest.doc",
+	},
+	{
+		name:   "percent encoded characters",
+		header: `attachment; filename*=UTF-8''hello%20world%21.txt`,
+		want:   "hello world!.txt",
+	},
+	{
+		name:   "encoded quotes are part of the name",
+		header: `attachment; filename*=UTF-8''%22quoted%22.txt`,
+		want:   `"quoted".txt`,
+	},
+	{
+		name:   "quoted filename star",
+		header: `attachment; filename*="UTF-8''a%20b.txt"`,
+		want:   "a b.txt",
+	},
+	{
+		name:   "truncated percent escape",
+		header: `attachment; filename*=UTF-8''a%2`,
+		want:   "a%2",
+	},
+}
 
 func TestParseContentDisposition(t *testing.T) {
+	for _, tt := range parseContentDispositionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseContentDisposition(tt.header)
+			if got != tt.want {
+				t.Errorf("ParseContentDisposition(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParseContentDisposition(f *testing.F) {
+	for _, tt := range parseContentDispositionTests {
+		f.Add(tt.header)
+	}
+	f.Add(`attachment; filename="../../.bashrc"`)
+	f.Add("attachment; filename*=UTF-8''a%00b%2F..%5Cc.txt")
+	f.Add("attachment" + strings.Repeat(";", 1<<12) + `filename="x"`)
+	f.Fuzz(func(t *testing.T, header string) {
+		raw := ParseContentDisposition(header)
+		if len(raw) > len(header) {
+			t.Errorf("ParseContentDisposition(%q) = %q, longer than its input", header, raw)
+		}
+
+		name, sanitized := contentDispositionName(header)
+		if strings.ContainsAny(name, `/\`) || strings.ContainsFunc(name, unicode.IsControl) {
+			t.Fatalf("contentDispositionName(%q) = %q, contains a separator or control character", header, name)
+		}
+		if name == "." || name == ".." || !utf8.ValidString(name) {
+			t.Fatalf("contentDispositionName(%q) = %q", header, name)
+		}
+		if sanitized != (name != raw) {
+			t.Errorf("contentDispositionName(%q): sanitized = %v, but %q -> %q", header, sanitized, raw, name)
+		}
+
+		// A safe name survives a trip through BuildContentDisposition.
+		if name != "" {
+			cd := BuildContentDisposition("attachment", name)
+			if got := ParseContentDisposition(cd); got != name {
+				t.Errorf("round trip of %q via %q = %q", name, cd, got)
+			}
+		}
+	})
+}
+
+func TestContentDispositionName_Sanitizes(t *testing.T) {
 	tests := []struct {
-		name   string
-		header string
-		want   string
+		name      string
+		header    string
+		want      string
+		sanitized bool
 	}{
-		{
-			name:   "empty header",
-			header: "",
-			want:   "",
-		},
-		{
-			name:   "attachment with quoted filename",
-			header: `attachment; filename="example.txt"`,
-			want:   "example.txt",
-		},
-		{
-			name:   "attachment with unquoted filename",
-			header: `attachment; filename=example.txt`,
-			want:   "example.txt",
-		},
-		{
-			name:   "inline with filename",
-			header: `inline; filename="report.pdf"`,
-			want:   "report.pdf",
-		},
-		{
-			name:   "no filename parameter",
-			header: `attachment`,
-			want:   "",
-		},
-		{
-			name:   "filename star with UTF-8 encoding",
-			header: `attachment; filename*=UTF-8''example%20file.txt`,
-			want:   "example file.txt",
-		},
-		{
-			name:   "filename star takes precedence when last",
-			header: `attachment; filename="fallback.txt"; filename*=UTF-8''preferred%20name.txt`,
-			want:   "preferred name.txt",
-		},
-		{
-			name:   "filename with spaces in quotes",
-			header: `attachment; filename="my document.pdf"`,
-			want:   "my document.pdf",
-		},
-		{
-			name:   "mixed case Filename",
-			header: `attachment; Filename="CaseTest.doc"`,
-			want:   "CaseTest.doc",
-		},
-		{
-			name:   "percent encoded characters",
-			header: `attachment; filename*=UTF-8''hello%20world%21.txt`,
-			want:   "hello world!.txt",
-		},
+		{"plain", `attachment; filename="report.pdf"`, "report.pdf", false},
+		{"traversal", `attachment; filename="../../.bashrc"`, ".bashrc", true},
+		{"absolute", `attachment; filename="/etc/passwd"`, "passwd", true},
+		{"windows path", `attachment; filename="C:\Users\x\evil.exe"`, "evil.exe", true},
+		{"mixed separators", `attachment; filename="..\../a\b/c.txt"`, "c.txt", true},
+		{"encoded traversal", `attachment; filename*=UTF-8''..%2F..%2Fetc%2Fpasswd`, "passwd", true},
+		{"NUL byte", "attachment; filename=\"safe.txt\x00.exe\"", "safe.txt.exe", true},
+		{"encoded NUL", `attachment; filename*=UTF-8''a%00b.txt`, "ab.txt", true},
+		{"control characters", "attachment; filename=\"a\x1b[31mb\r\n.txt\"", "a[31mb.txt", true},
+		{"dot dot only", `attachment; filename=".."`, "", true},
+		{"trailing slash", `attachment; filename="dir/"`, "", true},
+		{"no filename", `attachment`, "", false},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ParseContentDisposition(tt.header)
-			if got != tt.want {
-				t.Errorf("ParseContentDisposition(%q) = %q, want %q", tt.header, got, tt.want)
+			got, sanitized := contentDispositionName(tt.header)
+			if got != tt.want || sanitized != tt.sanitized {
+				t.Errorf("contentDispositionName(%q) = %q, %v; want %q, %v", tt.header, got, sanitized, tt.want, tt.sanitized)
 			}
 		})
 	}
 }
+
+func TestNewFromURL_SanitizesContentDispositionName(t *testing.T) {
+	const cd = `attachment; filename="../../.bashrc"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", cd)
+		fmt.Fprint(w, "export PATH=/tmp")
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	f, err := NewFromURL(srv.URL + "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != ".bashrc" {
+		t.Errorf("Name = %q, want %q", f.Name(), ".bashrc")
+	}
+	if got := f.Metadata().Attributes[AttrRawContentDisposition]; got != cd {
+		t.Errorf("raw attribute = %q, want %q", got, cd)
+	}
+
+	saved, err := f.SaveToDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(saved.Path()) != ".bashrc" || strings.Contains(saved.Path(), "..") {
+		t.Errorf("saved to %q", saved.Path())
+	}
+}
+
+func TestNewFromS3_SanitizesContentDispositionName(t *testing.T) {
+	const cd = "attachment; filename=\"..\\..\\evil\x00.exe\""
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:               io.NopCloser(strings.NewReader("MZ")),
+				ContentDisposition: aws.String(cd),
+			}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	f, err := NewFromS3("bucket", "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "evil.exe" {
+		t.Errorf("Name = %q, want %q", f.Name(), "evil.exe")
+	}
+	if got := f.Metadata().Attributes[AttrRawContentDisposition]; got != cd {
+		t.Errorf("raw attribute = %q, want %q", got, cd)
+	}
+}
+
+func TestNewFromURL_CleanContentDispositionNotRecorded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+		fmt.Fprint(w, "a,b")
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	f, err := NewFromURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Metadata().Attributes[AttrRawContentDisposition]; ok {
+		t.Error("raw Content-Disposition recorded for a clean filename")
+	}
+}
@@ -0,0 +1,104 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WithTransferRetry makes CopyURI retry a transient failure fetching an
+// http(s) source, as WithRetry does for NewFromURL. Other sources, and the
+// write to the destination, are attempted once; Pipe ignores it.
+// maxAttempts below 1 or a negative baseDelay is rejected.
+func WithTransferRetry(maxAttempts int, baseDelay time.Duration) TransferOption {
+	return func(o *transferOptions) {
+		if maxAttempts < 1 {
+			o.reject("WithTransferRetry", "maxAttempts %d is below 1", maxAttempts)
+			return
+		}
+		if baseDelay < 0 {
+			o.reject("WithTransferRetry", "negative base delay %s", baseDelay)
+			return
+		}
+		o.retry = retryPolicy{attempts: maxAttempts, base: baseDelay}
+	}
+}
+
+// CopyURI copies srcURI to dstURI, whatever either is. The source is
+// opened as New opens it, except that local files are streamed from disk
+// rather than read into memory. The destination may be s3://bucket/key
+// (S3Sink), an http(s) URL to PUT to (URLSink), or a file:// URI or plain
+// path (PathSink). A destination with another scheme, or one ending in
+// "/" so that it names a prefix or directory rather than an object, fails
+// with ErrInvalidArgument before the source is touched. The content is
+// moved with Pipe, so WithProgress, WithBandwidthLimit,
+// WithTransferChecksum and the other TransferOptions apply, and
+// WithTransferRetry retries fetching an http(s) source.
+func CopyURI(ctx context.Context, srcURI, dstURI string, opts ...TransferOption) (TransferResult, error) {
+	o := newTransferOptions(opts)
+	if err := checkOptions("CopyURI", o.validate()); err != nil {
+		return TransferResult{}, err
+	}
+	sink, err := resolveSink(dstURI)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	src, err := openCopySource(ctx, srcURI, o)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	return Pipe(ctx, src, sink, opts...)
+}
+
+// openCopySource opens uri for CopyURI.
+func openCopySource(ctx context.Context, uri string, o transferOptions) (*File, error) {
+	if p, ok := localPath(uri); ok {
+		return NewFromFileLazy(p)
+	}
+	var opts []ReadOption
+	if o.retry.attempts > 0 {
+		opts = append(opts, WithRetry(o.retry.attempts, o.retry.base))
+	}
+	return New(ctx, uri, opts...)
+}
+
+// resolveSink returns the Sink CopyURI writes dstURI through.
+func resolveSink(uri string) (Sink, error) {
+	if uri == "" {
+		return nil, newError(ErrInvalidArgument, "CopyURI", fmt.Errorf("empty destination"))
+	}
+	if strings.HasSuffix(uri, "/") {
+		return nil, newError(ErrInvalidArgument, "CopyURI", fmt.Errorf("destination %q names a directory or prefix, not an object", uri))
+	}
+	if p, ok := localPath(uri); ok {
+		return &PathSink{Path: p}, nil
+	}
+	u, _ := url.Parse(uri)
+	switch strings.ToLower(u.Scheme) {
+	case "s3":
+		bucket, key, ok := parseS3URI(uri)
+		if !ok {
+			return nil, newError(ErrInvalidArgument, "CopyURI", fmt.Errorf("malformed S3 URI %q", uri))
+		}
+		return &S3Sink{Bucket: bucket, Key: key}, nil
+	case "http", "https":
+		return &URLSink{URL: uri}, nil
+	default:
+		return nil, newError(ErrInvalidArgument, "CopyURI", fmt.Errorf("unsupported destination scheme %q", u.Scheme))
+	}
+}
+
+// localPath reports whether uri is a plain path or file:// URI, as New
+// decides, and returns the path.
+func localPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		return uri, true
+	}
+	if strings.EqualFold(u.Scheme, "file") {
+		return u.Path, true
+	}
+	return "", false
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// memBucketS3 installs a mock S3 client backed by a map of "bucket/key"
+// to object bodies.
+func memBucketS3(t *testing.T, objects map[string][]byte) {
+	t.Helper()
+	var mu sync.Mutex
+	cleanup := setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			body, err := io.ReadAll(in.Body)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			objects[aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key)] = body
+			return &s3.PutObjectOutput{}, nil
+		},
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			body, ok := objects[aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key)]
+			if !ok {
+				return nil, &types.NoSuchKey{}
+			}
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: aws.Int64(int64(len(body))),
+			}, nil
+		},
+	}, &mockPresignClient{})
+	t.Cleanup(cleanup)
+}
+
+func TestCopyURI_Combinations(t *testing.T) {
+	content := generateRandomBytes(t, 100*1024)
+	objects := map[string][]byte{"src/in.bin": content}
+	memBucketS3(t, objects)
+
+	var failures int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures < 1 {
+			failures++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(content)
+	}))
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.bin")
+	if err := os.WriteFile(local, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		src, dst string
+		got      func() []byte
+	}{
+		{"URL to S3", ts.URL + "/in.bin", "s3://dst/from-url.bin", func() []byte { return objects["dst/from-url.bin"] }},
+		{"S3 to local", "s3://src/in.bin", filepath.Join(dir, "sub", "from-s3.bin"), func() []byte {
+			b, _ := os.ReadFile(filepath.Join(dir, "sub", "from-s3.bin"))
+			return b
+		}},
+		{"local to S3", "file://" + local, "s3://dst/from-local.bin", func() []byte { return objects["dst/from-local.bin"] }},
+		{"S3 to S3", "s3://src/in.bin", "s3://dst/copy.bin", func() []byte { return objects["dst/copy.bin"] }},
+	} {
+		var progress int64
+		res, err := CopyURI(context.Background(), tc.src, tc.dst,
+			WithProgress(func(n int64) { progress = n }),
+			WithTransferRetry(3, time.Millisecond))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if res.Bytes != int64(len(content)) || progress != res.Bytes {
+			t.Errorf("%s: Bytes = %d, progress = %d, want %d", tc.name, res.Bytes, progress, len(content))
+		}
+		if !bytes.Equal(tc.got(), content) {
+			t.Errorf("%s: destination differs from the source", tc.name)
+		}
+	}
+}
+
+func TestCopyURI_RejectsBadDestination(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(src, []byte("x"), 0o644)
+	for _, dst := range []string{"", "s3://bucket/prefix/", "s3://bucket", "ftp://host/a.txt", t.TempDir() + "/"} {
+		if _, err := CopyURI(context.Background(), src, dst); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("CopyURI to %q: err = %v, want ErrInvalidArgument", dst, err)
+		}
+	}
+	if _, err := CopyURI(context.Background(), src, "s3://b/k", WithTransferRetry(0, 0)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("WithTransferRetry(0, 0): err = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,75 @@
+package file
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCreatedAtHeaders are the response headers NewFromURL reads
+// Metadata.CreatedAt from unless SetCreatedAtHeaders changes them.
+var DefaultCreatedAtHeaders = []string{"X-Created-At"}
+
+var createdAtHeaders atomic.Pointer[[]string]
+
+func init() {
+	SetCreatedAtHeaders(DefaultCreatedAtHeaders...)
+}
+
+// SetCreatedAtHeaders sets the HTTP response headers, in priority order,
+// that NewFromURL maps into Metadata.CreatedAt when no hint provides it.
+// The first header present with a parseable value wins. Values may be HTTP
+// dates, RFC 3339 timestamps or Unix seconds. "Date" is accepted but is
+// when the response was sent, so only list it for origins that generate
+// content per request. Call with no names to disable the mapping. Safe for
+// concurrent use.
+func SetCreatedAtHeaders(names ...string) {
+	names = slices.Clone(names)
+	createdAtHeaders.Store(&names)
+}
+
+// CreatedAtHeaders returns the headers set by SetCreatedAtHeaders.
+func CreatedAtHeaders() []string {
+	return slices.Clone(*createdAtHeaders.Load())
+}
+
+// createdAtFromHeader returns the creation time declared by the first
+// configured header in h with a parseable value, or the zero time.
+func createdAtFromHeader(h http.Header) time.Time {
+	for _, name := range *createdAtHeaders.Load() {
+		if t, ok := parseCreatedAt(h.Get(name)); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseCreatedAt parses an HTTP date, an RFC 3339 timestamp or Unix
+// seconds.
+func parseCreatedAt(v string) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, false
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, true
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs > 0 {
+		return time.Unix(secs, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// inheritCreatedAt carries from's CreatedAt over to f, a File derived
+// from it, unless f already has its own.
+func (f *File) inheritCreatedAt(from *File) {
+	if f.meta.CreatedAt.IsZero() {
+		f.meta.CreatedAt = from.meta.CreatedAt
+	}
+}
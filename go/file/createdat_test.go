@@ -0,0 +1,126 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestCreatedAtFromHeaders(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		config  []string // nil keeps the default
+		hint    time.Time
+		want    time.Time
+	}{
+		{"default header, HTTP date", map[string]string{"X-Created-At": created.Format(http.TimeFormat)}, nil, time.Time{}, created},
+		{"default header, RFC 3339", map[string]string{"X-Created-At": created.Format(time.RFC3339)}, nil, time.Time{}, created},
+		{"default header, Unix seconds", map[string]string{"X-Created-At": "1709294400"}, nil, time.Time{}, created},
+		{"unparseable value", map[string]string{"X-Created-At": "yesterday"}, nil, time.Time{}, time.Time{}},
+		{"custom header list in priority order",
+			map[string]string{"X-Upload-Time": "garbage", "X-Origin-Created": created.Format(time.RFC3339)},
+			[]string{"X-Upload-Time", "X-Origin-Created"}, time.Time{}, created},
+		{"mapping disabled", map[string]string{"X-Created-At": created.Format(time.RFC3339)}, []string{}, time.Time{}, time.Time{}},
+		{"hint wins", map[string]string{"X-Created-At": created.Format(time.RFC3339)}, nil, created.Add(-time.Hour), created.Add(-time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config != nil {
+				SetCreatedAtHeaders(tt.config...)
+				defer SetCreatedAtHeaders(DefaultCreatedAtHeaders...)
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.Write([]byte("hello"))
+			}))
+			defer srv.Close()
+			defer setMockHTTP(srv.Client())()
+
+			f, err := NewFromURL(srv.URL, MetadataHint{CreatedAt: tt.hint})
+			if err != nil {
+				t.Fatalf("NewFromURL: %v", err)
+			}
+			if !f.CreatedAt().Equal(tt.want) {
+				t.Errorf("CreatedAt() = %v, want %v", f.CreatedAt(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatedAtFromS3LastModified(t *testing.T) {
+	modified := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:         io.NopCloser(bytes.NewReader([]byte("data"))),
+				LastModified: aws.Time(modified),
+			}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f, err := NewFromS3("bucket", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.CreatedAt().Equal(modified) {
+		t.Errorf("CreatedAt() = %v, want LastModified %v", f.CreatedAt(), modified)
+	}
+
+	hinted := modified.Add(-24 * time.Hour)
+	f, err = NewFromS3("bucket", "key", MetadataHint{CreatedAt: hinted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.CreatedAt().Equal(hinted) {
+		t.Errorf("CreatedAt() = %v, want hint %v", f.CreatedAt(), hinted)
+	}
+}
+
+func TestCreatedAtSurvivesTransformations(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	f, err := NewFromBytes([]byte("some text content"), MetadataHint{Name: "a.txt", CreatedAt: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := f.Save(filepath.Join(t.TempDir(), "a.txt"))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if !saved.CreatedAt().Equal(created) {
+		t.Errorf("after Save: CreatedAt() = %v, want %v", saved.CreatedAt(), created)
+	}
+	if err := saved.Append([]byte(" more")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if !saved.CreatedAt().Equal(created) {
+		t.Errorf("after Append: CreatedAt() = %v, want %v", saved.CreatedAt(), created)
+	}
+
+	gz, err := saved.Compress(CodecGzip, 0)
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	if !gz.CreatedAt().Equal(created) {
+		t.Errorf("after Compress: CreatedAt() = %v, want %v", gz.CreatedAt(), created)
+	}
+	plain, err := gz.Decompress()
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if !plain.CreatedAt().Equal(created) {
+		t.Errorf("after Decompress: CreatedAt() = %v, want %v", plain.CreatedAt(), created)
+	}
+}
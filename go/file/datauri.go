@@ -0,0 +1,182 @@
+package file
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultDataURIMaxSize is the content size, in bytes, above which
+// ToDataURI refuses to inline a file. See SetDataURIMaxSize.
+const DefaultDataURIMaxSize = 1 << 20
+
+var dataURIMaxSize atomic.Int64
+
+func init() {
+	dataURIMaxSize.Store(DefaultDataURIMaxSize)
+}
+
+// SetDataURIMaxSize sets the largest content, in bytes, ToDataURI inlines.
+// Pass 0 to remove the limit. Safe for concurrent use.
+func SetDataURIMaxSize(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	dataURIMaxSize.Store(n)
+}
+
+// DataURIMaxSize returns the current limit set by SetDataURIMaxSize.
+func DataURIMaxSize() int64 {
+	return dataURIMaxSize.Load()
+}
+
+// ToDataURI returns the content as a base64 data: URI,
+// "data:<mimetype>;base64,<payload>", for inlining small files such as
+// icons in HTML or JSON. The MIME type, with any parameters, is the
+// resolved MimeType, or application/octet-stream when that is empty.
+// Content over DataURIMaxSize fails with ErrTooLarge; a known Size is
+// checked before anything is read. NewFromDataURI reverses it.
+func (f *File) ToDataURI() (string, error) {
+	limit := DataURIMaxSize()
+	if limit > 0 && f.Size() > limit {
+		return "", dataURITooLarge(f.Size(), limit)
+	}
+	data, err := f.Read()
+	if err != nil {
+		return "", err
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return "", dataURITooLarge(int64(len(data)), limit)
+	}
+	return "data:" + dataURIMediaType(f.meta.MimeType) + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// dataURITooLarge is the error ToDataURI returns for size bytes of content.
+func dataURITooLarge(size, limit int64) error {
+	return newError(ErrTooLarge, "ToDataURI", fmt.Errorf("content is %d bytes, over the %d-byte data URI limit", size, limit))
+}
+
+// dataURIMediaType formats mimeType for a data: URI header, which allows
+// no spaces between parameters.
+func dataURIMediaType(mimeType string) string {
+	base, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || base == "" {
+		return "application/octet-stream"
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		b.WriteString(";" + k + "=" + params[k])
+	}
+	return b.String()
+}
+
+// NewFromDataURI creates a File from an RFC 2397 data: URI, such as the
+// "data:image/png;base64,iVBOR..." a canvas export produces. The payload
+// is percent-decoded and, with ";base64", base64-decoded (padded or not,
+// standard or URL-safe alphabet, whitespace ignored). The declared media
+// type and charset become the MIME type, which magic-byte detection still
+// confirms as for NewFromBytes (a detected charset never replaces the
+// declared one); a URI without one is text/plain with
+// charset US-ASCII, as the RFC specifies. A MetadataHint MimeType takes
+// precedence over the declared one. A malformed URI fails with ErrRead.
+func NewFromDataURI(uri string, opts ...ReadOption) (*File, error) {
+	mimeType, data, err := parseDataURI(uri)
+	if err != nil {
+		return nil, newError(ErrRead, "NewFromDataURI", err)
+	}
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromDataURI", ro.validate()); err != nil {
+		return nil, err
+	}
+	declared := !hint.hasMimeType()
+	if declared {
+		hint.MimeType = declaredMimeType(mimeType)
+	}
+	meta := resolveMetadataFromBytes(data, hint)
+	if declared && strings.Contains(mimeType, "charset=") && mediaTypeBase(meta.MimeType) == mediaTypeBase(mimeType) {
+		// Detection only guesses a charset; the URI states one.
+		meta.MimeType = hint.MimeType
+	}
+	return withProvenance(ro.normalizeName(&File{
+		source: SourceBytes,
+		meta:   meta,
+		data:   data,
+		loaded: true,
+	})), nil
+}
+
+// parseDataURI splits a data: URI into its media type, with any charset
+// parameter, and its decoded payload.
+func parseDataURI(uri string) (string, []byte, error) {
+	scheme, rest, ok := strings.Cut(uri, ":")
+	if !ok || !strings.EqualFold(scheme, "data") {
+		return "", nil, errors.New(`not a data URI: missing "data:" prefix`)
+	}
+	header, payload, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", nil, errors.New(`malformed data URI: missing "," before the payload`)
+	}
+
+	params := strings.Split(header, ";")
+	isBase64 := false
+	if last := len(params) - 1; last > 0 && strings.EqualFold(strings.TrimSpace(params[last]), "base64") {
+		isBase64 = true
+		params = params[:last]
+	}
+	mediaType := "text/plain; charset=US-ASCII"
+	if header := strings.Join(params, ";"); strings.TrimSpace(header) != "" {
+		if strings.HasPrefix(header, ";") {
+			// Parameters without a type, as in "data:;charset=utf-8,...".
+			header = "text/plain" + header
+		}
+		base, kv, err := mime.ParseMediaType(header)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed data URI media type %q: %w", header, err)
+		}
+		mediaType = base
+		if cs := kv["charset"]; cs != "" {
+			mediaType = mime.FormatMediaType(base, map[string]string{"charset": cs})
+		}
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed data URI payload: %w", err)
+	}
+	if !isBase64 {
+		return mediaType, []byte(decoded), nil
+	}
+	data, err := decodeBase64Loose(decoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed data URI base64 payload: %w", err)
+	}
+	return mediaType, data, nil
+}
+
+// decodeBase64Loose decodes s in the standard or URL-safe alphabet, with
+// or without padding, ignoring whitespace.
+func decodeBase64Loose(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,158 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+func TestNewFromDataURI_PNG(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	pngData := buf.Bytes()
+	for _, uri := range []string{
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData),
+		"data:image/png;base64," + base64.RawURLEncoding.EncodeToString(pngData),
+		"DATA:image/png;BASE64," + url.PathEscape(base64.StdEncoding.EncodeToString(pngData)),
+		"data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(pngData),
+	} {
+		f, err := NewFromDataURI(uri)
+		if err != nil {
+			t.Fatalf("%.40s: %v", uri, err)
+		}
+		if data, _ := f.Read(); !bytes.Equal(data, pngData) {
+			t.Errorf("%.40s: payload differs", uri)
+		}
+		if f.MimeType() != "image/png" || f.Extension() != "png" || f.Source() != SourceBytes {
+			t.Errorf("%.40s: mime %q ext %q source %v", uri, f.MimeType(), f.Extension(), f.Source())
+		}
+	}
+}
+
+func TestNewFromDataURI_Text(t *testing.T) {
+	for _, tc := range []struct {
+		uri, want, mime string
+	}{
+		{"data:,Hello%2C%20World!", "Hello, World!", "text/plain; charset=US-ASCII"},
+		{"data:text/plain;charset=utf-8,caf%C3%A9", "café", "text/plain; charset=utf-8"},
+		{"data:;charset=utf-8;base64,Y2Fmw6k=", "café", "text/plain; charset=utf-8"},
+		{"data:text/csv;base64,YSxiCjEsMgo=", "a,b\n1,2\n", "text/csv"},
+	} {
+		f, err := NewFromDataURI(tc.uri)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.uri, err)
+		}
+		if data, _ := f.Read(); string(data) != tc.want {
+			t.Errorf("%s: content = %q, want %q", tc.uri, data, tc.want)
+		}
+		if f.MimeType() != tc.mime {
+			t.Errorf("%s: MimeType = %q, want %q", tc.uri, f.MimeType(), tc.mime)
+		}
+	}
+
+	f, err := NewFromDataURI("data:text/plain,hi", MetadataHint{Name: "note.md", MimeType: "text/markdown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "note.md" || f.MimeType() != "text/markdown" {
+		t.Errorf("hints not applied: %s", f)
+	}
+}
+
+func TestNewFromDataURI_Malformed(t *testing.T) {
+	for _, uri := range []string{
+		"image/png;base64,AAAA",
+		"data:image/png;base64",
+		"data:image/png;base64,!!!not base64!!!",
+		"data:text/plain,%zz",
+		"data:text/plain;charset,hi",
+	} {
+		if _, err := NewFromDataURI(uri); !errors.Is(err, ErrRead) {
+			t.Errorf("%q: err = %v, want ErrRead", uri, err)
+		}
+	}
+}
+
+func TestNew_DataURI(t *testing.T) {
+	f, err := New(context.Background(), "data:text/plain;base64,aGVsbG8=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := f.Read(); string(data) != "hello" {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestToDataURI_RoundTrip(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+		hint MetadataHint
+	}{
+		{"png", buf.Bytes(), MetadataHint{}},
+		{"text", []byte("héllo, wörld\n"), MetadataHint{Name: "greeting.txt"}},
+		{"json", []byte(`{"a":1}`), MetadataHint{MimeType: "application/json"}},
+	} {
+		f, _ := NewFromBytes(tc.data, tc.hint)
+		uri, err := f.ToDataURI()
+		if err != nil {
+			t.Fatalf("%s: ToDataURI: %v", tc.name, err)
+		}
+		back, err := NewFromDataURI(uri)
+		if err != nil {
+			t.Fatalf("%s: NewFromDataURI(%q): %v", tc.name, uri, err)
+		}
+		if got, _ := back.Read(); !bytes.Equal(got, tc.data) {
+			t.Errorf("%s: round-tripped bytes differ", tc.name)
+		}
+		if back.MimeType() != f.MimeType() {
+			t.Errorf("%s: MimeType = %q, want %q (uri %.60s)", tc.name, back.MimeType(), f.MimeType(), uri)
+		}
+	}
+}
+
+func TestToDataURI_OctetStreamFallback(t *testing.T) {
+	f := &File{source: SourceBytes, data: []byte{1, 2, 3}, loaded: true, meta: Metadata{Size: 3}}
+	uri, err := f.ToDataURI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != "data:application/octet-stream;base64,AQID" {
+		t.Errorf("ToDataURI = %q", uri)
+	}
+}
+
+func TestToDataURI_SizeLimit(t *testing.T) {
+	defer SetDataURIMaxSize(DataURIMaxSize())
+	SetDataURIMaxSize(10)
+
+	f, _ := NewFromBytes(bytes.Repeat([]byte("a"), 11))
+	_, err := f.ToDataURI()
+	if !errors.Is(err, ErrTooLarge) || CodeOf(err) != CodeTooLarge {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	small, _ := NewFromBytes(bytes.Repeat([]byte("a"), 10))
+	if _, err := small.ToDataURI(); err != nil {
+		t.Fatalf("at the limit: %v", err)
+	}
+
+	SetDataURIMaxSize(0)
+	if _, err := f.ToDataURI(); err != nil {
+		t.Fatalf("without a limit: %v", err)
+	}
+}
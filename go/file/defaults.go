@@ -0,0 +1,189 @@
+package file
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Defaults holds package-wide option defaults, set once at startup with
+// SetDefaults. Each field mirrors an option constructor and applies as if
+// that option were passed first to every call, so per-call options win.
+// The zero value of every field means "no default" and leaves today's
+// behavior unchanged.
+//
+// Out-of-range values are reported the way the matching option reports
+// them: operations fail with ErrInvalidOption.
+type Defaults struct {
+	// Verify is WithVerify's algorithm; empty disables verification.
+	Verify Algorithm
+	// VerifyMode is the mode verification uses when it is enabled, by
+	// Verify or per call. Unlike WithVerifyMode it does not require
+	// verification to be on.
+	VerifyMode VerifyMode
+	// CollisionStrategy is WithCollisionStrategy's strategy.
+	CollisionStrategy CollisionStrategy
+
+	// Retention, RetentionTagKey and ObjectLock are WithRetention,
+	// WithRetentionTagKey and WithObjectLock for UploadToS3.
+	Retention       time.Duration
+	RetentionTagKey string
+	ObjectLock      types.ObjectLockMode
+	// TrailingChecksum is WithTrailingChecksum.
+	TrailingChecksum bool
+	// StorageClass, SSE with SSEKMSKeyID, and ACL are WithStorageClass,
+	// WithSSE and WithACL for UploadToS3.
+	StorageClass types.StorageClass
+	SSE          types.ServerSideEncryption
+	SSEKMSKeyID  string
+	ACL          types.ObjectCannedACL
+	// PartSize and MultipartThreshold are WithPartSize and
+	// WithMultipartThreshold; zero leaves DefaultPartSize and
+	// DefaultMultipartThreshold in effect.
+	PartSize           int64
+	MultipartThreshold int64
+
+	// BandwidthLimit and TransferChecksum are WithBandwidthLimit and
+	// WithTransferChecksum for Pipe.
+	BandwidthLimit   int64
+	TransferChecksum Algorithm
+	// Priority is the priority every transfer queues at under the default
+	// scheduler unless its context (ContextWithPriority) or Pipe's
+	// WithPriority says otherwise.
+	Priority Priority
+
+	// FetchTags and Accept are WithFetchTags and WithAccept for the
+	// constructors.
+	FetchTags bool
+	Accept    []string
+	// Retry and RetryDelay are WithRetry's maxAttempts and baseDelay for
+	// NewFromURL, and WithTransferRetry's for CopyURI. Zero Retry makes a
+	// single attempt; RetryDelay without Retry is rejected.
+	Retry      int
+	RetryDelay time.Duration
+	// Timeout is WithTimeout for NewFromURL; zero leaves DownloadTimeout
+	// in effect.
+	Timeout time.Duration
+
+	// MaxSize and AllowedMimes are the ValidateOptions fields File.Validate
+	// uses when its argument leaves them zero (nil, for AllowedMimes).
+	MaxSize      int64
+	AllowedMimes []string
+}
+
+var defaults atomic.Pointer[Defaults]
+
+// SetDefaults replaces the package-wide defaults. Each operation takes a
+// snapshot when it resolves its options, so later calls do not affect
+// operations already running. Safe for concurrent use.
+func SetDefaults(d Defaults) {
+	d.Accept = slices.Clone(d.Accept)
+	d.AllowedMimes = slices.Clone(d.AllowedMimes)
+	defaults.Store(&d)
+}
+
+// CurrentDefaults returns a copy of the defaults set by SetDefaults.
+func CurrentDefaults() Defaults {
+	d := loadDefaults()
+	d.Accept = slices.Clone(d.Accept)
+	d.AllowedMimes = slices.Clone(d.AllowedMimes)
+	return d
+}
+
+// loadDefaults returns the current defaults. The result shares Accept and
+// AllowedMimes with the stored value and must not be modified.
+func loadDefaults() Defaults {
+	if d := defaults.Load(); d != nil {
+		return *d
+	}
+	return Defaults{}
+}
+
+// applySave applies the save defaults to o.
+func (d Defaults) applySave(o *saveOptions) {
+	if d.Verify != "" {
+		WithVerify(d.Verify)(o)
+	}
+	if d.VerifyMode != VerifyReread {
+		if d.VerifyMode < VerifyReread || d.VerifyMode > VerifySourceDigest {
+			o.reject("Defaults.VerifyMode", "unknown mode %d", d.VerifyMode)
+		} else {
+			o.verifyMode = d.VerifyMode
+		}
+	}
+	if d.CollisionStrategy != CollisionOverwrite {
+		WithCollisionStrategy(d.CollisionStrategy)(o)
+	}
+}
+
+// applyUpload applies the upload defaults to o.
+func (d Defaults) applyUpload(o *uploadOptions) {
+	if d.Retention != 0 {
+		WithRetention(d.Retention)(o)
+	}
+	WithRetentionTagKey(d.RetentionTagKey)(o)
+	if d.ObjectLock != "" {
+		WithObjectLock(d.ObjectLock)(o)
+	}
+	o.trailingChecksum = d.TrailingChecksum
+	if d.StorageClass != "" {
+		WithStorageClass(d.StorageClass)(o)
+	}
+	if d.SSE != "" || d.SSEKMSKeyID != "" {
+		WithSSE(d.SSE, d.SSEKMSKeyID)(o)
+	}
+	if d.ACL != "" {
+		WithACL(d.ACL)(o)
+	}
+	if d.PartSize != 0 {
+		WithPartSize(d.PartSize)(o)
+	}
+	if d.MultipartThreshold != 0 {
+		WithMultipartThreshold(d.MultipartThreshold)(o)
+	}
+}
+
+// applyTransfer applies the transfer defaults to o.
+func (d Defaults) applyTransfer(o *transferOptions) {
+	if d.BandwidthLimit != 0 {
+		WithBandwidthLimit(d.BandwidthLimit)(o)
+	}
+	if d.TransferChecksum != "" {
+		WithTransferChecksum(d.TransferChecksum)(o)
+	}
+	if d.Retry != 0 || d.RetryDelay != 0 {
+		WithTransferRetry(d.Retry, d.RetryDelay)(o)
+	}
+	if d.Priority != PriorityInteractive {
+		WithPriority(d.Priority)(o)
+		// A default is not an explicit choice: leave a priority set with
+		// ContextWithPriority in charge.
+		o.prioritySet = false
+	}
+}
+
+// applyRead applies the read defaults to o.
+func (d Defaults) applyRead(o *readOptions) {
+	o.fetchTags = d.FetchTags
+	if len(d.Accept) > 0 {
+		WithAccept(d.Accept...).applyRead(&MetadataHint{}, o)
+	}
+	if d.Retry != 0 || d.RetryDelay != 0 {
+		WithRetry(d.Retry, d.RetryDelay).applyRead(&MetadataHint{}, o)
+	}
+	if d.Timeout != 0 {
+		WithTimeout(d.Timeout).applyRead(&MetadataHint{}, o)
+	}
+}
+
+// applyValidate fills the fields of opts that the call left zero.
+func (d Defaults) applyValidate(opts *ValidateOptions) {
+	if opts.MaxSize == 0 {
+		opts.MaxSize = d.MaxSize
+	}
+	if opts.AllowedMimes == nil {
+		opts.AllowedMimes = d.AllowedMimes
+	}
+}
@@ -0,0 +1,204 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// withDefaults installs d for the duration of the test.
+func withDefaults(t *testing.T, d Defaults) {
+	t.Helper()
+	orig := CurrentDefaults()
+	SetDefaults(d)
+	t.Cleanup(func() { SetDefaults(orig) })
+}
+
+func TestDefaults_ZeroValueMatchesNoDefaults(t *testing.T) {
+	withDefaults(t, Defaults{})
+	if got := newSaveOptions(nil); !reflect.DeepEqual(got, saveOptions{dirMode: defaultDirMode}) {
+		t.Errorf("save options = %+v", got)
+	}
+	if got := newTransferOptions(nil); !reflect.DeepEqual(got, transferOptions{algo: AlgorithmSHA256, window: defaultThroughputWindow}) {
+		t.Errorf("transfer options = %+v", got)
+	}
+	up := newUploadOptions(nil)
+	if up.retention != 0 || up.retentionTagKey != DefaultRetentionTagKey || up.lockMode != "" || up.trailingChecksum {
+		t.Errorf("upload options = %+v", up)
+	}
+	if _, ro := resolveHints(nil); !reflect.DeepEqual(ro, readOptions{}) {
+		t.Errorf("read options = %+v", ro)
+	}
+}
+
+func TestDefaults_Precedence(t *testing.T) {
+	withDefaults(t, Defaults{
+		CollisionStrategy: CollisionErrorOut,
+		Retention:         time.Hour,
+		RetentionTagKey:   "org-expiry",
+		BandwidthLimit:    1 << 20,
+		Priority:          PriorityBatch,
+		Accept:            []string{"application/json"},
+		Retry:             3,
+		RetryDelay:        time.Second,
+		Timeout:           time.Minute,
+	})
+
+	t.Run("default beats zero", func(t *testing.T) {
+		if o := newSaveOptions(nil); o.collision != CollisionErrorOut {
+			t.Errorf("collision = %v", o.collision)
+		}
+		if o := newUploadOptions(nil); o.retention != time.Hour || o.retentionTagKey != "org-expiry" {
+			t.Errorf("retention = %v, key = %q", o.retention, o.retentionTagKey)
+		}
+		if o := newTransferOptions(nil); o.bytesPerSecond != 1<<20 || o.priority != PriorityBatch {
+			t.Errorf("bandwidth = %d, priority = %v", o.bytesPerSecond, o.priority)
+		}
+		if _, ro := resolveHints(nil); !reflect.DeepEqual(ro.accept, []string{"application/json"}) {
+			t.Errorf("accept = %v", ro.accept)
+		}
+		want := retryPolicy{attempts: 3, base: time.Second}
+		if _, ro := resolveHints(nil); ro.retry != want || ro.timeout != time.Minute {
+			t.Errorf("retry = %+v, timeout = %v", ro.retry, ro.timeout)
+		}
+		if o := newTransferOptions(nil); o.retry != want {
+			t.Errorf("transfer retry = %+v", o.retry)
+		}
+	})
+
+	t.Run("per-call beats default", func(t *testing.T) {
+		if o := newSaveOptions([]SaveOption{WithCollisionStrategy(CollisionRenameWithSuffix)}); o.collision != CollisionRenameWithSuffix {
+			t.Errorf("collision = %v", o.collision)
+		}
+		if o := newUploadOptions([]UploadOption{WithRetention(time.Minute), WithRetentionTagKey("call")}); o.retention != time.Minute || o.retentionTagKey != "call" {
+			t.Errorf("retention = %v, key = %q", o.retention, o.retentionTagKey)
+		}
+		if o := newTransferOptions([]TransferOption{WithBandwidthLimit(0), WithPriority(PriorityInteractive)}); o.bytesPerSecond != 0 || o.priority != PriorityInteractive {
+			t.Errorf("bandwidth = %d, priority = %v", o.bytesPerSecond, o.priority)
+		}
+		if _, ro := resolveHints([]ReadOption{WithAccept("text/csv")}); !reflect.DeepEqual(ro.accept, []string{"text/csv"}) {
+			t.Errorf("accept = %v", ro.accept)
+		}
+		if _, ro := resolveHints([]ReadOption{WithRetry(1, 0), WithTimeout(time.Second)}); ro.retry.attempts != 1 || ro.timeout != time.Second {
+			t.Errorf("retry = %+v, timeout = %v", ro.retry, ro.timeout)
+		}
+	})
+
+	t.Run("save honors default collision strategy", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "out.txt")
+		os.WriteFile(dest, []byte("existing"), 0o644)
+		f, _ := NewFromBytes([]byte("new"))
+		if _, err := f.Save(dest); !errors.Is(err, ErrExists) {
+			t.Fatalf("err = %v, want ErrExists", err)
+		}
+		if _, err := f.Save(dest, WithCollisionStrategy(CollisionOverwrite)); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(dest); string(data) != "new" {
+			t.Errorf("content = %q", data)
+		}
+	})
+}
+
+func TestDefaults_UploadAndValidate(t *testing.T) {
+	withDefaults(t, Defaults{
+		StorageClass:       types.StorageClassStandardIa,
+		SSE:                types.ServerSideEncryptionAwsKms,
+		SSEKMSKeyID:        "org-key",
+		ACL:                types.ObjectCannedACLBucketOwnerFullControl,
+		PartSize:           2 * minPartSize,
+		MultipartThreshold: 3 * minPartSize,
+		MaxSize:            4,
+		AllowedMimes:       []string{"text/plain"},
+	})
+
+	t.Run("default beats zero", func(t *testing.T) {
+		o := newUploadOptions(nil)
+		if o.storageClass != types.StorageClassStandardIa || o.sse != types.ServerSideEncryptionAwsKms ||
+			o.sseKMSKeyID != "org-key" || o.acl != types.ObjectCannedACLBucketOwnerFullControl {
+			t.Errorf("class = %q, sse = %q, key = %q, acl = %q", o.storageClass, o.sse, o.sseKMSKeyID, o.acl)
+		}
+		if o.partSize != 2*minPartSize || o.multipartThreshold != 3*minPartSize {
+			t.Errorf("part size = %d, threshold = %d", o.partSize, o.multipartThreshold)
+		}
+		f, _ := NewFromBytes([]byte("too long"))
+		var vErr *FileValidationError
+		if err := f.Validate(ValidateOptions{}); !errors.As(err, &vErr) || vErr.Kind != KindSize {
+			t.Errorf("Validate err = %v, want the default MaxSize to apply", err)
+		}
+	})
+
+	t.Run("per-call beats default", func(t *testing.T) {
+		o := newUploadOptions([]UploadOption{
+			WithStorageClass(types.StorageClassGlacierIr),
+			WithSSE(types.ServerSideEncryptionAes256, ""),
+			WithACL(types.ObjectCannedACLPrivate),
+			WithPartSize(minPartSize),
+			WithMultipartThreshold(0),
+		})
+		if o.storageClass != types.StorageClassGlacierIr || o.sse != types.ServerSideEncryptionAes256 ||
+			o.sseKMSKeyID != "" || o.acl != types.ObjectCannedACLPrivate {
+			t.Errorf("class = %q, sse = %q, key = %q, acl = %q", o.storageClass, o.sse, o.sseKMSKeyID, o.acl)
+		}
+		if o.partSize != minPartSize || o.multipartThreshold != 0 {
+			t.Errorf("part size = %d, threshold = %d", o.partSize, o.multipartThreshold)
+		}
+		f, _ := NewFromBytes([]byte("plain text, longer than four bytes"))
+		if err := f.Validate(ValidateOptions{MaxSize: -1, AllowedMimes: []string{}}); err != nil {
+			t.Errorf("Validate with both checks disabled per call: %v", err)
+		}
+		var vErr *FileValidationError
+		if err := f.Validate(ValidateOptions{MaxSize: 1 << 10, AllowedMimes: []string{"image/png"}}); !errors.As(err, &vErr) || vErr.Kind != KindMime {
+			t.Errorf("Validate err = %v, want the per-call AllowedMimes to apply", err)
+		}
+	})
+
+	t.Run("invalid default", func(t *testing.T) {
+		SetDefaults(Defaults{PartSize: 1})
+		if err := newUploadOptions(nil).validate(); err == nil {
+			t.Error("a part size below S3's minimum was accepted as a default")
+		}
+	})
+}
+
+func TestDefaults_Snapshot(t *testing.T) {
+	accept := []string{"text/csv"}
+	withDefaults(t, Defaults{Accept: accept, BandwidthLimit: 10})
+	accept[0] = "changed"
+
+	got := CurrentDefaults()
+	if got.Accept[0] != "text/csv" {
+		t.Errorf("SetDefaults kept the caller's slice: %v", got.Accept)
+	}
+	got.Accept[0] = "mutated"
+	if CurrentDefaults().Accept[0] != "text/csv" {
+		t.Error("CurrentDefaults returned the stored slice")
+	}
+
+	o := newTransferOptions(nil)
+	SetDefaults(Defaults{BandwidthLimit: 99})
+	if o.bytesPerSecond != 10 {
+		t.Errorf("resolved options changed after SetDefaults: %d", o.bytesPerSecond)
+	}
+}
+
+func TestDefaults_InvalidValues(t *testing.T) {
+	withDefaults(t, Defaults{BandwidthLimit: -1, VerifyMode: VerifyMode(42)})
+	f, _ := NewFromBytes([]byte("x"))
+	if _, err := f.Save(filepath.Join(t.TempDir(), "x")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Save err = %v, want ErrInvalidOption", err)
+	}
+	if err := newTransferOptions(nil).validate(); err == nil {
+		t.Error("negative default bandwidth was accepted")
+	}
+
+	SetDefaults(Defaults{RetryDelay: time.Second, Timeout: -time.Second})
+	if _, err := NewFromURL("http://127.0.0.1:1/x"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("NewFromURL err = %v, want ErrInvalidOption", err)
+	}
+}
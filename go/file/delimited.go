@@ -0,0 +1,245 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DelimitedInfo describes the layout of a delimited text file as guessed by
+// SniffDelimited.
+type DelimitedInfo struct {
+	// Delimiter is the detected field separator: ',', '\t', ';' or '|'. Zero
+	// when no candidate splits the sample into more than one column.
+	Delimiter rune
+	// ColumnCount is the most common number of fields per record (1 when no
+	// delimiter was detected).
+	ColumnCount int
+	// HasHeader reports whether the first record looks like a header: it has
+	// a non-numeric value in a column whose other values are all numeric.
+	HasHeader bool
+	// QuotedFields reports whether any field in the sample is double-quoted.
+	QuotedFields bool
+}
+
+// delimiterCandidates is the set of separators SniffDelimited considers, in
+// tie-break order.
+var delimiterCandidates = []rune{',', '\t', ';', '|'}
+
+// defaultSniffLines is the number of lines sampled when SniffDelimited is
+// given a non-positive sampleLines.
+const defaultSniffLines = 20
+
+// maxSniffBytes caps how much SniffDelimited reads, however long the lines.
+const maxSniffBytes = 64 * 1024
+
+// SniffDelimited inspects up to sampleLines lines from the start of the
+// content and guesses the delimiter, column count and header presence of a
+// CSV/TSV-style file. Only the needed prefix is read: lazy streams are not
+// drained and ReaderAt-backed files are not buffered. The delimiter is the
+// candidate that splits the sampled records into the most consistent number
+// of columns. Binary content returns ErrNotText.
+func (f *File) SniffDelimited(sampleLines int) (DelimitedInfo, error) {
+	if sampleLines <= 0 {
+		sampleLines = defaultSniffLines
+	}
+	r, err := f.prefixReader()
+	if err != nil {
+		return DelimitedInfo{}, err
+	}
+	sample, err := readLines(r, sampleLines, maxSniffBytes)
+	if err != nil {
+		return DelimitedInfo{}, newError(ErrRead, "SniffDelimited", err)
+	}
+	if !isText(sample) {
+		return DelimitedInfo{}, newError(ErrNotText, "SniffDelimited", fmt.Errorf("content looks binary (%s)", f.meta.MimeType))
+	}
+	return sniffDelimited(string(sample)), nil
+}
+
+// readLines reads up to n lines (and at most maxBytes) from r.
+func readLines(r io.Reader, n, maxBytes int) ([]byte, error) {
+	br := bufio.NewReader(io.LimitReader(r, int64(maxBytes)))
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		line, err := br.ReadBytes('\n')
+		buf.Write(line)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// isText reports whether sample looks like text: no NUL bytes and valid
+// UTF-8, ignoring a rune cut off at the end of the sample.
+func isText(sample []byte) bool {
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		sample = sample[:len(sample)-1]
+	}
+	return utf8.Valid(sample)
+}
+
+// sniffDelimited scores each candidate delimiter over the sample's records.
+func sniffDelimited(sample string) DelimitedInfo {
+	records := splitRecords(sample)
+	info := DelimitedInfo{ColumnCount: 1}
+	if len(records) == 0 {
+		return info
+	}
+
+	bestScore := 0.0
+	for _, d := range delimiterCandidates {
+		mode, share := columnConsistency(records, d)
+		if mode < 2 {
+			continue
+		}
+		// Prefer consistency; break near-ties by column count.
+		score := share*1000 + float64(mode)
+		if score > bestScore {
+			bestScore = score
+			info.Delimiter = d
+			info.ColumnCount = mode
+		}
+	}
+
+	for _, rec := range records {
+		if _, quoted := splitFields(rec, delimiterOrNone(info.Delimiter)); quoted {
+			info.QuotedFields = true
+			break
+		}
+	}
+	if info.Delimiter != 0 {
+		info.HasHeader = looksLikeHeader(records, info.Delimiter)
+	}
+	return info
+}
+
+// delimiterOrNone returns d, or a rune that never occurs in text when d is 0.
+func delimiterOrNone(d rune) rune {
+	if d == 0 {
+		return utf8.RuneError
+	}
+	return d
+}
+
+// columnConsistency returns the most common field count for delimiter d and
+// the share of records that have it.
+func columnConsistency(records []string, d rune) (mode int, share float64) {
+	counts := map[int]int{}
+	for _, rec := range records {
+		fields, _ := splitFields(rec, d)
+		counts[len(fields)]++
+	}
+	best := 0
+	for n, c := range counts {
+		if c > best || (c == best && n > mode) {
+			mode, best = n, c
+		}
+	}
+	return mode, float64(best) / float64(len(records))
+}
+
+// splitRecords splits sample into records on newlines outside double quotes,
+// dropping blank lines and a trailing CR.
+func splitRecords(sample string) []string {
+	var (
+		records  []string
+		inQuotes bool
+		start    int
+	)
+	for i := 0; i < len(sample); i++ {
+		switch sample[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case '\n':
+			if !inQuotes {
+				if rec := strings.TrimSuffix(sample[start:i], "\r"); rec != "" {
+					records = append(records, rec)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if rec := strings.TrimSuffix(sample[start:], "\r"); rec != "" {
+		records = append(records, rec)
+	}
+	return records
+}
+
+// splitFields splits a record on d outside double quotes, returning the
+// unquoted fields and whether any field was quoted.
+func splitFields(rec string, d rune) (fields []string, quoted bool) {
+	var (
+		field    strings.Builder
+		inQuotes bool
+	)
+	for _, c := range rec {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case c == d && !inQuotes:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	return append(fields, field.String()), quoted
+}
+
+// looksLikeHeader reports whether some column has a non-numeric first value
+// and only numeric values below it.
+func looksLikeHeader(records []string, d rune) bool {
+	if len(records) < 2 {
+		return false
+	}
+	first, _ := splitFields(records[0], d)
+	rest := make([][]string, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		fields, _ := splitFields(rec, d)
+		rest = append(rest, fields)
+	}
+	for col, head := range first {
+		if isNumeric(head, d) {
+			continue
+		}
+		numeric := true
+		for _, fields := range rest {
+			if col >= len(fields) || !isNumeric(fields[col], d) {
+				numeric = false
+				break
+			}
+		}
+		if numeric {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumeric reports whether s parses as a number. When the delimiter is not
+// a comma, a decimal comma ("3,14") is accepted too, as in European exports.
+func isNumeric(s string, d rune) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	if d != ',' {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
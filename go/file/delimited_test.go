@@ -0,0 +1,102 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSniffDelimited(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want DelimitedInfo
+	}{
+		{
+			name: "csv with header",
+			data: "id,name,price\n1,apple,0.5\n2,pear,0.75\n3,plum,1.25\n",
+			want: DelimitedInfo{Delimiter: ',', ColumnCount: 3, HasHeader: true},
+		},
+		{
+			name: "csv without header",
+			data: "1,2,3\n4,5,6\n7,8,9\n",
+			want: DelimitedInfo{Delimiter: ',', ColumnCount: 3},
+		},
+		{
+			name: "tsv",
+			data: "sku\tqty\nA-1\t4\nB-2\t10\n",
+			want: DelimitedInfo{Delimiter: '\t', ColumnCount: 2, HasHeader: true},
+		},
+		{
+			name: "semicolon european export",
+			data: "Datum;Betrag;Menge\r\n01.02.2024;12,50;3\r\n02.02.2024;7,25;1\r\n",
+			want: DelimitedInfo{Delimiter: ';', ColumnCount: 3, HasHeader: true},
+		},
+		{
+			name: "pipe",
+			data: "a|b|c|d\nw|x|y|z\n",
+			want: DelimitedInfo{Delimiter: '|', ColumnCount: 4},
+		},
+		{
+			name: "quoted field with embedded delimiter",
+			data: "name,address,zip\n\"Smith, J\",\"1 Main St, Apt 2\",12345\n\"Doe, A\",\"9 Elm, Unit 4\",67890\n",
+			want: DelimitedInfo{Delimiter: ',', ColumnCount: 3, HasHeader: true, QuotedFields: true},
+		},
+		{
+			name: "quoted field with embedded newline",
+			data: "note,n\n\"line one\nline two\",1\n\"x\",2\n",
+			want: DelimitedInfo{Delimiter: ',', ColumnCount: 2, HasHeader: true, QuotedFields: true},
+		},
+		{
+			name: "single column",
+			data: "alpha\nbeta\ngamma\n",
+			want: DelimitedInfo{ColumnCount: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewFromBytes([]byte(tt.data))
+			got, err := f.SniffDelimited(0)
+			if err != nil {
+				t.Fatalf("SniffDelimited: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SniffDelimited = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffDelimited_SampleLinesLimitsRead(t *testing.T) {
+	// Only the first two lines are sampled, so the ragged rows below do not
+	// affect the result.
+	f, _ := NewFromBytes([]byte("a;b\n1;2\nx\ny\nz\n"))
+	got, err := f.SniffDelimited(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Delimiter != ';' || got.ColumnCount != 2 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestSniffDelimited_LazyStreamNotDrained(t *testing.T) {
+	data := []byte("a,b\n1,2\n3,4\n")
+	f, _ := NewFromStreamLazy(bytes.NewReader(data))
+	if _, err := f.SniffDelimited(0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := f.Read()
+	if !bytes.Equal(got, data) {
+		t.Errorf("content after sniffing = %q, want %q", got, data)
+	}
+}
+
+func TestSniffDelimited_BinaryIsNotText(t *testing.T) {
+	f, _ := NewFromBytes(pngBytes)
+	_, err := f.SniffDelimited(0)
+	if !errors.Is(err, ErrNotText) {
+		t.Errorf("expected ErrNotText, got %v", err)
+	}
+}
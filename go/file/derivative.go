@@ -0,0 +1,65 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+)
+
+// AddDerivative attaches d to f under name, replacing any derivative
+// already there, so thumbnails, previews and transcodes travel with their
+// original. A nil d removes the derivative.
+func (f *File) AddDerivative(name string, d *File) {
+	if d == nil {
+		delete(f.derivatives, name)
+		return
+	}
+	if f.derivatives == nil {
+		f.derivatives = map[string]*File{}
+	}
+	f.derivatives[name] = d
+}
+
+// Derivative returns the derivative attached under name.
+func (f *File) Derivative(name string) (*File, bool) {
+	d, ok := f.derivatives[name]
+	return d, ok
+}
+
+// Derivatives returns a copy of f's derivatives by name, or nil when it
+// has none.
+func (f *File) Derivatives() map[string]*File {
+	return maps.Clone(f.derivatives)
+}
+
+// UploadWithDerivativesToS3 uploads f and each of its derivatives to
+// bucket with UploadToS3WithContext and opts. keyFn names every object:
+// it is called with f and "" for the original, then with f and each
+// derivative's name, in name order. All uploads are attempted; the
+// failures are returned together as a *BatchError naming each key, and
+// the objects that did upload are left in place.
+func (f *File) UploadWithDerivativesToS3(ctx context.Context, bucket string, keyFn func(original *File, derivName string) string, opts ...UploadOption) error {
+	if keyFn == nil {
+		return newError(ErrInvalidArgument, "UploadWithDerivativesToS3", errors.New("keyFn is nil"))
+	}
+	if err := checkS3Bucket("UploadWithDerivativesToS3", bucket); err != nil {
+		return err
+	}
+	if err := checkNetwork(ctx, "UploadWithDerivativesToS3"); err != nil {
+		return err
+	}
+	names := append([]string{""}, slices.Sorted(maps.Keys(f.derivatives))...)
+	var failed []*ItemError
+	for i, name := range names {
+		item := f
+		if name != "" {
+			item = f.derivatives[name]
+		}
+		key := keyFn(f, name)
+		if err := item.UploadToS3WithContext(ctx, bucket, key, opts...); err != nil {
+			failed = append(failed, itemError(i, key, "s3://"+bucket+"/"+key, "UploadWithDerivativesToS3", ErrS3, err))
+		}
+	}
+	return newBatchError("UploadWithDerivativesToS3", len(names), len(names)-len(failed), failed)
+}
@@ -0,0 +1,117 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestDerivatives_AddAndLookup(t *testing.T) {
+	orig, _ := NewFromBytes(pngBytes, MetadataHint{Name: "photo.png"})
+	thumb, _ := NewFromBytes(pngBytes, MetadataHint{Name: "thumb.png"})
+	preview, _ := NewFromBytes(pngBytes, MetadataHint{Name: "preview.png"})
+
+	if orig.Derivatives() != nil {
+		t.Error("Derivatives of a plain File is not nil")
+	}
+	orig.AddDerivative("thumb", thumb)
+	orig.AddDerivative("preview", preview)
+	if d, ok := orig.Derivative("thumb"); !ok || d != thumb {
+		t.Errorf("Derivative(thumb) = %v, %v", d, ok)
+	}
+	all := orig.Derivatives()
+	if len(all) != 2 {
+		t.Fatalf("Derivatives = %v", all)
+	}
+	delete(all, "thumb")
+	if _, ok := orig.Derivative("thumb"); !ok {
+		t.Error("Derivatives exposes the internal map")
+	}
+	orig.AddDerivative("preview", nil)
+	if _, ok := orig.Derivative("preview"); ok {
+		t.Error("AddDerivative(nil) did not remove the derivative")
+	}
+}
+
+func TestDerivatives_FrozenViewCopiesMap(t *testing.T) {
+	orig, _ := NewFromBytes([]byte("original"))
+	thumb, _ := NewFromBytes([]byte("thumb"))
+	orig.AddDerivative("thumb", thumb)
+
+	view := orig.frozenView()
+	view.AddDerivative("extra", thumb)
+	if _, ok := orig.Derivative("extra"); ok {
+		t.Error("a frozen view shares the derivative map with its original")
+	}
+	d, _ := view.Derivative("thumb")
+	if d == thumb || !d.Frozen() {
+		t.Error("a frozen view's derivatives are not frozen copies")
+	}
+}
+
+func TestUploadWithDerivativesToS3_KeyLayout(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			keys = append(keys, aws.ToString(in.Key))
+			if strings.Contains(aws.ToString(in.Key), "broken") {
+				return nil, errors.New("boom")
+			}
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	orig, _ := NewFromBytes(pngBytes, MetadataHint{Name: "photo.png"})
+	for _, name := range []string{"thumb", "preview"} {
+		d, _ := NewFromBytes(pngBytes, MetadataHint{Name: name + ".png"})
+		orig.AddDerivative(name, d)
+	}
+	keyFn := func(original *File, deriv string) string {
+		if deriv == "" {
+			return "media/" + original.Name()
+		}
+		return "media/derived/" + deriv + "/" + original.Name()
+	}
+	if err := orig.UploadWithDerivativesToS3(context.Background(), "bucket", keyFn); err != nil {
+		t.Fatalf("UploadWithDerivativesToS3: %v", err)
+	}
+	want := []string{"media/photo.png", "media/derived/preview/photo.png", "media/derived/thumb/photo.png"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	keys = nil
+	broken, _ := NewFromBytes([]byte("x"))
+	orig.AddDerivative("broken", broken)
+	err := orig.UploadWithDerivativesToS3(context.Background(), "bucket", keyFn)
+	var batch *BatchError
+	if !errors.As(err, &batch) || batch.Total != 4 || batch.Succeeded() != 3 {
+		t.Fatalf("err = %v, want a BatchError with 1 of 4 failed", err)
+	}
+	if failed := batch.Failed(); len(failed) != 1 || path.Base(path.Dir(failed[0].Key)) != "broken" {
+		t.Errorf("Failed = %+v", failed)
+	}
+	if len(keys) != 4 || !slices.Contains(keys, "media/derived/thumb/photo.png") {
+		t.Errorf("a failure stopped the other uploads: %v", keys)
+	}
+}
+
+func TestUploadWithDerivativesToS3_NilKeyFn(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	if err := f.UploadWithDerivativesToS3(context.Background(), "bucket", nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("err = %v, want ErrInvalidArgument", err)
+	}
+}
@@ -4,44 +4,184 @@ import (
 	"mime"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"unicode"
 
 	"github.com/gabriel-vasile/mimetype"
 )
 
-// DetectMimeTypeFromBytes uses magic-byte detection to determine the MIME type
-// of the given data. Returns an empty string if detection fails.
-func DetectMimeTypeFromBytes(data []byte) string {
+// DefaultDetectionMinSize is the content size, in bytes, below which
+// magic-byte detection is not trusted on its own. See SetDetectionMinSize.
+const DefaultDetectionMinSize = 16
+
+var detectionMinSize atomic.Int64
+
+func init() {
+	detectionMinSize.Store(DefaultDetectionMinSize)
+}
+
+// SetDetectionMinSize sets the content size below which magic-byte detection
+// is treated as unreliable. A two-byte "MZ" or "BM" body matches executable
+// and bitmap signatures, so for content smaller than n bytes the metadata
+// pipeline prefers declared types (hints, headers, filename) and only
+// accepts a detected type when it is textual and either agrees with the
+// declared type or nothing useful was declared. DetectExtensionFromBytes
+// likewise only reports textual extensions for such inputs. Pass 0 to
+// always trust detection. Safe for concurrent use.
+func SetDetectionMinSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	detectionMinSize.Store(int64(n))
+}
+
+// DetectionMinSize returns the current threshold set by SetDetectionMinSize.
+func DetectionMinSize() int {
+	return int(detectionMinSize.Load())
+}
+
+// belowDetectionMinSize reports whether content of size n is too small for
+// magic-byte detection to be trusted on its own.
+func belowDetectionMinSize(n int64) bool {
+	return n < detectionMinSize.Load()
+}
+
+// magicDetect runs magic-byte detection once and returns the MIME type and
+// extension (no leading dot). Either is empty when detection is
+// inconclusive.
+func magicDetect(data []byte) (mimeType, ext string) {
 	if len(data) == 0 {
-		return ""
+		return "", ""
 	}
 	mtype := mimetype.Detect(data)
 	if mtype == nil {
-		return ""
+		return "", ""
 	}
-	result := mtype.String()
-	// mimetype sometimes returns "application/octet-stream" when it cannot detect,
-	// which is not very useful, so treat it as unknown.
-	if result == "application/octet-stream" {
+	mimeType = mtype.String()
+	// mimetype sometimes returns "application/octet-stream" when it cannot
+	// detect, which is not very useful, so treat it as unknown.
+	if mimeType == "application/octet-stream" {
+		mimeType = ""
+	}
+	ext = strings.TrimPrefix(mtype.Extension(), ".")
+	if ext == "bin" {
+		ext = ""
+	}
+	return mimeType, ext
+}
+
+// applyMagicDetection overlays magic-byte detection of data onto m.
+//
+// A declared type (hint, header, stored S3 ContentType or filename) must
+// survive a round trip through S3 and back, so at or above the detection
+// threshold the detected type replaces it only when detection is confident
+// and contradicts it; see detectionOverrides. Below the threshold, only a
+// textual detection is considered, and it only replaces the declared type
+// when that is missing, an octet-stream type, or the same media type (so
+// "text/plain" is still refined to carry a charset).
+func applyMagicDetection(m *Metadata, data []byte) {
+	detectedMime, detectedExt := magicDetect(data)
+	if detectedMime == "" {
+		if !belowDetectionMinSize(int64(len(data))) && detectedExt != "" {
+			m.Extension = detectedExt
+		}
+		return
+	}
+	if belowDetectionMinSize(int64(len(data))) {
+		if !isTextualMimeType(detectedMime) {
+			return
+		}
+		declared := mediaTypeBase(m.MimeType)
+		if declared != "" && !isOctetStream(declared) && declared != mediaTypeBase(detectedMime) {
+			return
+		}
+	} else if !detectionOverrides(m.MimeType, detectedMime) {
+		return
+	}
+	m.MimeType = detectedMime
+	if detectedExt != "" {
+		m.Extension = detectedExt
+	}
+}
+
+// detectionOverrides reports whether a detected type should replace the
+// declared one. It does when nothing useful was declared or both name the
+// same media type (detection adds parameters such as charset). It does not
+// when detection is not confident: textual types are recognized by
+// heuristics rather than signatures, so text/plain or text/csv is no
+// reason to doubt a CSV served as application/vnd.ms-excel, or JSON served
+// as application/vnd.api+json. Nor does it when the declared type refines
+// the detected one: a DOCX is detected as at least application/zip and an
+// .xls as OLE storage.
+func detectionOverrides(declared, detected string) bool {
+	d, g := mediaTypeBase(declared), mediaTypeBase(detected)
+	if d == "" || isOctetStream(d) || d == g {
+		return true
+	}
+	if isTextualMimeType(g) {
+		return false
+	}
+	for node := mimetype.Lookup(d); node != nil; node = node.Parent() {
+		if node.Is(g) {
+			return false
+		}
+	}
+	return true
+}
+
+// octetStream is the generic binary media type.
+const octetStream = "application/octet-stream"
+
+// isOctetStream reports whether m is application/octet-stream or S3's legacy
+// binary/octet-stream default — types that say nothing about the content.
+func isOctetStream(m string) bool {
+	base := mediaTypeBase(m)
+	return base == octetStream || base == "binary/octet-stream"
+}
+
+// declaredMimeType returns a server-declared Content-Type, or "" when it is
+// empty or an octet-stream variant, so filename and magic-byte detection
+// get a chance to find something more useful.
+func declaredMimeType(ct string) string {
+	if isOctetStream(ct) {
 		return ""
 	}
-	return result
+	return strings.TrimSpace(ct)
+}
+
+// isTextualMimeType reports whether m is a text-like media type — the only
+// kind magic-byte detection can identify reliably from a few bytes.
+func isTextualMimeType(m string) bool {
+	base := mediaTypeBase(m)
+	return strings.HasPrefix(base, "text/") ||
+		base == "application/json" || base == "application/xml" ||
+		strings.HasSuffix(base, "+json") || strings.HasSuffix(base, "+xml")
+}
+
+// mediaTypeBase returns the lowercased media type of m without parameters.
+func mediaTypeBase(m string) string {
+	if idx := strings.Index(m, ";"); idx >= 0 {
+		m = m[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(m))
+}
+
+// DetectMimeTypeFromBytes uses magic-byte detection to determine the MIME type
+// of the given data. Returns an empty string if detection fails.
+func DetectMimeTypeFromBytes(data []byte) string {
+	mimeType, _ := magicDetect(data)
+	return mimeType
 }
 
 // DetectExtensionFromBytes uses magic-byte detection to determine the file extension
 // of the given data. Returns an empty string if detection fails.
 // The returned extension has no leading dot (e.g., "png", not ".png").
+// For inputs smaller than DetectionMinSize only textual extensions (txt,
+// json, html, ...) are returned, since binary signatures that short are
+// mostly coincidence.
 func DetectExtensionFromBytes(data []byte) string {
-	if len(data) == 0 {
-		return ""
-	}
-	mtype := mimetype.Detect(data)
-	if mtype == nil {
-		return ""
-	}
-	ext := mtype.Extension()
-	// mimetype returns ".ext" format; strip the leading dot.
-	ext = strings.TrimPrefix(ext, ".")
-	if ext == "" || ext == "bin" {
+	mimeType, ext := magicDetect(data)
+	if belowDetectionMinSize(int64(len(data))) && !isTextualMimeType(mimeType) {
 		return ""
 	}
 	return ext
@@ -119,8 +259,31 @@ func MimeTypeFromFilename(name string) string {
 }
 
 // ExtensionFromFilename extracts the extension from a filename.
-// Returns the extension without a leading dot (e.g., "txt").
+// Returns the extension without a leading dot (e.g., "txt"). Everything
+// after the last dot counts; see StrictExtensionFromFilename for the rule
+// Metadata.Extension follows.
 func ExtensionFromFilename(name string) string {
 	ext := filepath.Ext(name)
 	return strings.TrimPrefix(ext, ".")
 }
+
+// StrictExtensionFromFilename is ExtensionFromFilename for names that may
+// be long or unicode, as Files derive Metadata.Extension. Only a short
+// ASCII suffix counts: in "v1.0 最終版" or a name with no ASCII dot at all
+// there is no extension, and "" is returned.
+func StrictExtensionFromFilename(name string) string {
+	i := strings.LastIndexAny(name, `./\`)
+	if i < 0 || name[i] != '.' {
+		return ""
+	}
+	ext := name[i+1:]
+	if ext == "" || len(ext) > maxExtensionBytes {
+		return ""
+	}
+	for _, r := range ext {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '+') {
+			return ""
+		}
+	}
+	return ext
+}
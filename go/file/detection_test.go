@@ -1,9 +1,20 @@
 package file
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 func TestDetectMimeTypeFromBytes(t *testing.T) {
@@ -200,6 +211,7 @@ func TestExtensionFromFilename(t *testing.T) {
 		{"photo.png", "png"},
 		{"archive.tar.gz", "gz"},
 		{"noext", ""},
+		{"v1.0 最終版", "0 最終版"},
 		{"", ""},
 	}
 
@@ -220,3 +232,269 @@ func TestExtensionFromMimeType_StripParams(t *testing.T) {
 		t.Error("ExtensionFromMimeType with params should return a non-empty extension")
 	}
 }
+
+// smallPayloads are sub-threshold bodies whose leading bytes happen to match
+// binary signatures (or nothing at all).
+var smallPayloads = map[string][]byte{
+	"1 byte":   {0x01},
+	"3 bytes":  {0xFF, 0xD8, 0xFF},                                         // JPEG SOI
+	"10 bytes": {'M', 'Z', 0x90, 0x00, 0x03, 0x00, 0x00, 0x00, 0x04, 0x00}, // PE header
+}
+
+func TestDetectExtensionFromBytes_SubThreshold(t *testing.T) {
+	for name, data := range smallPayloads {
+		t.Run(name, func(t *testing.T) {
+			if got := DetectExtensionFromBytes(data); got != "" {
+				t.Errorf("DetectExtensionFromBytes = %q, want empty", got)
+			}
+		})
+	}
+	if got := DetectExtensionFromBytes([]byte("{}")); got != "json" {
+		t.Errorf("textual sub-threshold input: got %q, want json", got)
+	}
+}
+
+func TestSetDetectionMinSize(t *testing.T) {
+	defer SetDetectionMinSize(DefaultDetectionMinSize)
+
+	jpeg := smallPayloads["3 bytes"]
+	SetDetectionMinSize(0)
+	if got := DetectExtensionFromBytes(jpeg); got != "jpg" {
+		t.Errorf("threshold 0: got %q, want jpg", got)
+	}
+	SetDetectionMinSize(-5)
+	if DetectionMinSize() != 0 {
+		t.Errorf("negative threshold should clamp to 0, got %d", DetectionMinSize())
+	}
+	SetDetectionMinSize(DefaultDetectionMinSize)
+	if got := DetectExtensionFromBytes(jpeg); got != "" {
+		t.Errorf("default threshold: got %q, want empty", got)
+	}
+}
+
+func TestSmallPayloads_RespectHintsAcrossConstructors(t *testing.T) {
+	hint := MetadataHint{Name: "report.csv"}
+
+	for name, data := range smallPayloads {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "report.csv")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/csv")
+				w.Write(data)
+			}))
+			defer srv.Close()
+			restoreHTTP := setMockHTTP(srv.Client())
+			defer restoreHTTP()
+
+			restoreS3 := setMockS3(&mockS3Client{
+				getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{
+						Body:        io.NopCloser(bytes.NewReader(data)),
+						ContentType: aws.String("text/csv"),
+					}, nil
+				},
+			}, &mockPresignClient{})
+			defer restoreS3()
+
+			constructors := map[string]func() (*File, error){
+				"bytes":    func() (*File, error) { return NewFromBytes(data, hint) },
+				"stream":   func() (*File, error) { return NewFromStream(bytes.NewReader(data), hint) },
+				"lazy":     func() (*File, error) { return NewFromStreamLazy(bytes.NewReader(data), hint) },
+				"file":     func() (*File, error) { return NewFromFile(path) },
+				"url":      func() (*File, error) { return NewFromURL(srv.URL + "/report.csv") },
+				"s3":       func() (*File, error) { return NewFromS3("bucket", "report.csv") },
+				"readerAt": func() (*File, error) { return NewFromReaderAt(bytes.NewReader(data), int64(len(data)), hint) },
+			}
+			for cname, ctor := range constructors {
+				f, err := ctor()
+				if err != nil {
+					t.Fatalf("%s: %v", cname, err)
+				}
+				if !strings.HasPrefix(f.MimeType(), "text/csv") {
+					t.Errorf("%s: MimeType = %q, want text/csv", cname, f.MimeType())
+				}
+				if f.Extension() != "csv" {
+					t.Errorf("%s: Extension = %q, want csv", cname, f.Extension())
+				}
+			}
+		})
+	}
+}
+
+func TestSmallPayloads_NoJunkWithoutHints(t *testing.T) {
+	for name, data := range smallPayloads {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewFromBytes(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.MimeType() != "" || f.Extension() != "" {
+				t.Errorf("got %q / %q, want no type for an unidentifiable tiny payload", f.MimeType(), f.Extension())
+			}
+		})
+	}
+}
+
+func TestSmallPayloads_TextIsStillRefined(t *testing.T) {
+	f, err := NewFromBytes([]byte("hi"), MetadataHint{MimeType: "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.MimeType() != "text/plain; charset=utf-8" {
+		t.Errorf("MimeType = %q, want charset refinement of the declared type", f.MimeType())
+	}
+}
+
+// --- Content-type fidelity ---
+
+// roundTripStore backs a mock S3 client with an in-memory object map that
+// keeps ContentType and Content-Disposition like S3 does.
+func roundTripStore() *mockS3Client {
+	objects := map[string]*s3.PutObjectInput{}
+	bodies := map[string][]byte{}
+	return &mockS3Client{
+		putObjectFn: func(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			body, err := io.ReadAll(in.Body)
+			if err != nil {
+				return nil, err
+			}
+			k := aws.ToString(in.Bucket) + "/" + aws.ToString(in.Key)
+			objects[k], bodies[k] = in, body
+			return &s3.PutObjectOutput{}, nil
+		},
+		getObjectFn: func(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			k := aws.ToString(in.Bucket) + "/" + aws.ToString(in.Key)
+			obj := objects[k]
+			return &s3.GetObjectOutput{
+				Body:               io.NopCloser(bytes.NewReader(bodies[k])),
+				ContentType:        obj.ContentType,
+				ContentDisposition: obj.ContentDisposition,
+				ContentLength:      aws.Int64(int64(len(bodies[k]))),
+			}, nil
+		},
+	}
+}
+
+func zipWith(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, n := range names {
+		w, err := zw.Create(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, "<xml/>")
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipOf(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestContentTypeRoundTripFidelity is the regression gate for the MIME
+// resolver: a type declared by a URL's Content-Type header must survive
+// NewFromURL -> UploadToS3 -> NewFromS3 unless magic-byte detection is
+// confident and contradicts it.
+func TestContentTypeRoundTripFidelity(t *testing.T) {
+	csv := []byte("id,name,amount\n1,widget,9.99\n2,gadget,19.50\n")
+	jpeg := append([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}, bytes.Repeat([]byte{0}, 32)...)
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\ntrailer << >>\n%%EOF\n")
+
+	tests := []struct {
+		name     string
+		data     []byte
+		declared string
+		want     string // media type without parameters
+	}{
+		// Declared types detection cannot contradict.
+		{"csv served as excel", csv, "application/vnd.ms-excel", "application/vnd.ms-excel"},
+		{"csv", csv, "text/csv", "text/csv"},
+		{"markdown", []byte("# Title\n\nSome *markdown* text here.\n"), "text/markdown", "text/markdown"},
+		{"yaml", []byte("name: widget\nversion: 1.2.3\ntags: [a, b]\n"), "application/yaml", "application/yaml"},
+		{"json api", []byte(`{"data":{"type":"widgets","id":"1"}}`), "application/vnd.api+json", "application/vnd.api+json"},
+		{"atom feed", []byte(`<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"></feed>`), "application/atom+xml", "application/atom+xml"},
+		{"docx as plain zip", zipWith(t, "a.xml", "b.xml"), "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"gzip alias", gzipOf(t, "hello hello hello"), "application/x-gzip", "application/x-gzip"},
+		// Declared types detection agrees with.
+		{"png", pngBytes, "image/png", "image/png"},
+		{"pdf", pdf, "application/pdf", "application/pdf"},
+		{"json", []byte(`{"id":1,"name":"widget","tags":["a","b"]}`), "application/json", "application/json"},
+		{"html", []byte("<!DOCTYPE html><html><body><p>hi</p></body></html>"), "text/html", "text/html"},
+		// Confident detection that contradicts the declared type wins.
+		{"png declared as text", pngBytes, "text/plain", "image/png"},
+		{"jpeg declared as png", jpeg, "image/png", "image/jpeg"},
+		{"pdf declared as excel", pdf, "application/vnd.ms-excel", "application/pdf"},
+		// Nothing useful declared: detection decides.
+		{"octet-stream png", pngBytes, "application/octet-stream", "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.declared)
+				w.Write(tt.data)
+			}))
+			defer srv.Close()
+			defer setMockHTTP(srv.Client())()
+			defer setMockS3(roundTripStore(), &mockPresignClient{})()
+
+			fromURL, err := NewFromURL(srv.URL + "/download")
+			if err != nil {
+				t.Fatalf("NewFromURL: %v", err)
+			}
+			if got := mediaTypeBase(fromURL.MimeType()); got != tt.want {
+				t.Errorf("after NewFromURL: MimeType = %q, want %q", fromURL.MimeType(), tt.want)
+			}
+			if err := fromURL.UploadToS3("bucket", "object"); err != nil {
+				t.Fatalf("UploadToS3: %v", err)
+			}
+			fromS3, err := NewFromS3("bucket", "object")
+			if err != nil {
+				t.Fatalf("NewFromS3: %v", err)
+			}
+			if got := mediaTypeBase(fromS3.MimeType()); got != tt.want {
+				t.Errorf("after NewFromS3: MimeType = %q, want %q", fromS3.MimeType(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectionOverrides(t *testing.T) {
+	tests := []struct {
+		declared, detected string
+		want               bool
+	}{
+		{"", "image/png", true},
+		{"application/octet-stream", "image/png", true},
+		{"text/plain", "text/plain; charset=utf-8", true},
+		{"text/plain", "image/png", true},
+		{"application/vnd.ms-excel", "text/plain; charset=utf-8", false},
+		{"application/vnd.ms-excel", "text/csv", false},
+		{"application/vnd.ms-excel", "application/x-ole-storage", false},
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/zip", false},
+		{"application/ld+json", "application/json", false},
+		{"image/svg+xml", "text/xml; charset=utf-8", false},
+		{"image/png", "image/jpeg", true},
+	}
+	for _, tt := range tests {
+		if got := detectionOverrides(tt.declared, tt.detected); got != tt.want {
+			t.Errorf("detectionOverrides(%q, %q) = %v, want %v", tt.declared, tt.detected, got, tt.want)
+		}
+	}
+}
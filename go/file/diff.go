@@ -0,0 +1,159 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// FieldDiff is one metadata field that differs between two Files. A and B
+// are the values formatted as strings (sizes and counts in decimal, times
+// in RFC 3339 with nanoseconds, empty when unset); an attribute is named
+// "Attributes[key]" and a tag "Tags[key]". Significant marks fields that
+// describe the content itself (Size, Hash, MimeType, VersionID,
+// EntryCount, attributes) as opposed to where or under what name it is
+// stored, or how it is tagged.
+type FieldDiff struct {
+	Field       string `json:"field"`
+	A           string `json:"a"`
+	B           string `json:"b"`
+	Significant bool   `json:"significant"`
+}
+
+// Diff is what DiffAgainst returns. ContentEqual is set only when the
+// content was compared.
+type Diff struct {
+	Fields       []FieldDiff `json:"fields"`
+	ContentEqual *bool       `json:"content_equal,omitempty"`
+}
+
+// DiffMetadata returns the fields that differ between a and b, in the
+// order they are declared on Metadata, with attributes and then tags last
+// in key order, so the result is stable. It is empty, not nil, when nothing differs.
+func DiffMetadata(a, b Metadata) []FieldDiff {
+	diffs := []FieldDiff{}
+	add := func(field, av, bv string, significant bool) {
+		if av != bv {
+			diffs = append(diffs, FieldDiff{Field: field, A: av, B: bv, Significant: significant})
+		}
+	}
+	add("Name", a.Name, b.Name, false)
+	add("MimeType", a.MimeType, b.MimeType, true)
+	add("Size", strconv.FormatInt(a.Size, 10), strconv.FormatInt(b.Size, 10), true)
+	add("Extension", a.Extension, b.Extension, false)
+	add("URL", a.URL, b.URL, false)
+	add("Path", a.Path, b.Path, false)
+	add("Hash", a.Hash, b.Hash, true)
+	add("LastModified", formatDiffTime(a.LastModified), formatDiffTime(b.LastModified), false)
+	add("CreatedAt", formatDiffTime(a.CreatedAt), formatDiffTime(b.CreatedAt), false)
+	add("VersionID", a.VersionID, b.VersionID, true)
+	add("EntryCount", strconv.Itoa(a.EntryCount), strconv.Itoa(b.EntryCount), true)
+
+	diffs = diffMap(diffs, "Attributes", a.Attributes, b.Attributes, true)
+	diffs = diffMap(diffs, "Tags", a.Tags, b.Tags, false)
+	return diffs
+}
+
+// diffMap appends a FieldDiff named field[key] for each key whose value
+// differs between a and b, in key order.
+func diffMap(diffs []FieldDiff, field string, a, b map[string]string, significant bool) []FieldDiff {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		av, aok := a[k]
+		bv, bok := b[k]
+		if aok != bok || av != bv {
+			diffs = append(diffs, FieldDiff{Field: field + "[" + k + "]", A: av, B: bv, Significant: significant})
+		}
+	}
+	return diffs
+}
+
+// formatDiffTime formats t for a FieldDiff, or "" when it is zero. Times
+// are compared by instant, so the same moment in two zones formats alike.
+func formatDiffTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// DiffAgainst compares f's metadata with other's, as DiffMetadata does.
+// With compareContent it also streams both contents in chunks and reports
+// whether they are byte-for-byte equal, stopping at the first difference;
+// this reads remote content as Read does.
+func (f *File) DiffAgainst(other *File, compareContent bool) (Diff, error) {
+	d := Diff{Fields: DiffMetadata(f.Metadata(), other.Metadata())}
+	if !compareContent {
+		return d, nil
+	}
+	equal, err := contentEqual(f, other)
+	if err != nil {
+		return Diff{}, err
+	}
+	d.ContentEqual = &equal
+	return d, nil
+}
+
+// contentEqual reports whether a and b have the same content, reading
+// both in defaultChunkSize chunks.
+func contentEqual(a, b *File) (bool, error) {
+	ra, err := a.contentReader(context.Background())
+	if err != nil {
+		return false, err
+	}
+	if c, ok := ra.(io.Closer); ok {
+		defer c.Close()
+	}
+	rb, err := b.contentReader(context.Background())
+	if err != nil {
+		return false, err
+	}
+	if c, ok := rb.(io.Closer); ok {
+		defer c.Close()
+	}
+	bufA := make([]byte, defaultChunkSize)
+	bufB := make([]byte, defaultChunkSize)
+	for {
+		na, errA := io.ReadFull(ra, bufA)
+		nb, errB := io.ReadFull(rb, bufB)
+		if err := chunkReadError(errA); err != nil {
+			return false, err
+		}
+		if err := chunkReadError(errB); err != nil {
+			return false, err
+		}
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA != nil || errB != nil {
+			// Equal chunks and at least one side ended, so both did.
+			return true, nil
+		}
+	}
+}
+
+// chunkReadError returns err as an ErrRead error unless it only marks the
+// end of the content.
+func chunkReadError(err error) error {
+	if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	var fe *FileError
+	if errors.As(err, &fe) {
+		return err
+	}
+	return newError(ErrRead, "DiffAgainst", err)
+}
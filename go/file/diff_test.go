@@ -0,0 +1,138 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffMetadata_EveryField(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := Metadata{
+		Name: "a.txt", MimeType: "text/plain", Size: 10, Extension: "txt",
+		URL: "https://a.example/a.txt", Path: "/tmp/a.txt", Hash: "h1",
+		LastModified: t0, CreatedAt: t0, VersionID: "v1", EntryCount: 1,
+		Attributes: map[string]string{"both": "1", "onlyA": "x", "same": "s"},
+		Tags:       map[string]string{"team": "data"},
+	}
+	b := Metadata{
+		Name: "b.csv", MimeType: "text/csv", Size: 11, Extension: "csv",
+		URL: "https://b.example/b.csv", Path: "/tmp/b.csv", Hash: "h2",
+		LastModified: t0.Add(time.Second), CreatedAt: time.Time{}, VersionID: "v2", EntryCount: 2,
+		Attributes: map[string]string{"both": "2", "onlyB": "y", "same": "s"},
+		Tags:       map[string]string{"team": "ops"},
+	}
+	want := []FieldDiff{
+		{"Name", "a.txt", "b.csv", false},
+		{"MimeType", "text/plain", "text/csv", true},
+		{"Size", "10", "11", true},
+		{"Extension", "txt", "csv", false},
+		{"URL", "https://a.example/a.txt", "https://b.example/b.csv", false},
+		{"Path", "/tmp/a.txt", "/tmp/b.csv", false},
+		{"Hash", "h1", "h2", true},
+		{"LastModified", "2024-05-01T12:00:00Z", "2024-05-01T12:00:01Z", false},
+		{"CreatedAt", "2024-05-01T12:00:00Z", "", false},
+		{"VersionID", "v1", "v2", true},
+		{"EntryCount", "1", "2", true},
+		{"Attributes[both]", "1", "2", true},
+		{"Attributes[onlyA]", "x", "", true},
+		{"Attributes[onlyB]", "", "y", true},
+		{"Tags[team]", "data", "ops", false},
+	}
+	got := DiffMetadata(a, b)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DiffMetadata =\n%+v\nwant\n%+v", got, want)
+	}
+	for range 5 {
+		if again := DiffMetadata(a, b); !reflect.DeepEqual(again, got) {
+			t.Fatal("DiffMetadata is not stable across calls")
+		}
+	}
+}
+
+func TestDiffMetadata_Identical(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := Metadata{Name: "a", Size: 3, LastModified: at, Attributes: map[string]string{"k": "v"}}
+	b := a
+	b.LastModified = at.In(time.FixedZone("X", 3600))
+	got := DiffMetadata(a, b)
+	if got == nil || len(got) != 0 {
+		t.Fatalf("DiffMetadata of equal metadata = %#v, want empty non-nil", got)
+	}
+	data, _ := json.Marshal(Diff{Fields: got})
+	if string(data) != `{"fields":[]}` {
+		t.Errorf("JSON = %s", data)
+	}
+}
+
+func TestDiffAgainst_Content(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20000) // spans several chunks
+	changed := bytes.Clone(content)
+	changed[len(changed)-1] = 'x'
+
+	dir := t.TempDir()
+	onDisk := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(onDisk, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := NewFromFileLazy(onDisk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, _ := NewFromBytes(content, MetadataHint{Name: "a.txt"})
+	diff, _ := NewFromBytes(changed, MetadataHint{Name: "a.txt"})
+	short, _ := NewFromBytes(content[:len(content)-1], MetadataHint{Name: "a.txt"})
+
+	for _, tc := range []struct {
+		name  string
+		other *File
+		equal bool
+	}{
+		{"same", same, true},
+		{"last byte differs", diff, false},
+		{"prefix", short, false},
+	} {
+		d, err := lazy.DiffAgainst(tc.other, true)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if d.ContentEqual == nil || *d.ContentEqual != tc.equal {
+			t.Errorf("%s: ContentEqual = %v, want %v", tc.name, d.ContentEqual, tc.equal)
+		}
+	}
+
+	d, err := same.DiffAgainst(diff, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ContentEqual != nil {
+		t.Error("ContentEqual set without compareContent")
+	}
+	data, _ := json.Marshal(d)
+	if bytes.Contains(data, []byte("content_equal")) {
+		t.Errorf("JSON without content comparison = %s", data)
+	}
+}
+
+func TestDiffAgainst_ReadError(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "gone.txt")
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(p)
+	other, _ := NewFromBytes([]byte("x"))
+	if _, err := lazy.DiffAgainst(other, true); err == nil {
+		t.Fatal("DiffAgainst of a removed file succeeded")
+	}
+	if _, err := lazy.DiffAgainst(other, false); err != nil {
+		t.Fatalf("metadata-only DiffAgainst: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// MimeTypeDirectory is the MIME type of directory-mode Files.
+const MimeTypeDirectory = "inode/directory"
+
+// newDirFile builds the directory-mode File NewFromFile returns for a
+// directory. Only the direct entries are counted up front; Size walks the
+// tree on first use.
+func newDirFile(dirPath string, info os.FileInfo, hint MetadataHint) (*File, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, newError(ErrRead, "NewFromFile", err)
+	}
+
+	m := Metadata{}
+	applyHint(&m, hint)
+	m.Path = dirPath
+	if m.Name == "" {
+		m.Name = filepath.Base(dirPath)
+	}
+	if m.MimeType == "" {
+		m.MimeType = MimeTypeDirectory
+	}
+	m.LastModified = info.ModTime()
+	m.EntryCount = len(entries)
+
+	return withProvenance(&File{
+		source:   SourceFile,
+		meta:     m,
+		dir:      true,
+		dirTotal: &dirTotal{},
+	}), nil
+}
+
+// dirTotal is a directory's aggregated size, computed once and shared by
+// copies of the File, so concurrent Size calls are safe.
+type dirTotal struct {
+	once sync.Once
+	n    int64
+}
+
+// get returns the total size of the regular files beneath dir, walking
+// the tree on the first call.
+func (t *dirTotal) get(dir string) int64 {
+	t.once.Do(func() { t.n = dirSize(dir) })
+	return t.n
+}
+
+// IsDir reports whether f is a directory-mode File. Directory Files have no
+// content of their own: Read and everything built on it fail with
+// ErrInvalidSource. Use Children, Walk, ZipTo or UploadDirToS3 instead.
+func (f *File) IsDir() bool { return f.dir }
+
+// Children returns a File for each direct entry of a directory, sorted by
+// name. Subdirectories are directory-mode Files; regular files are opened
+// as by NewFromFileLazy, so their content is only read when used.
+func (f *File) Children() ([]*File, error) {
+	if !f.dir {
+		return nil, newError(ErrInvalidSource, "Children", fmt.Errorf("%s is not a directory", describeFile(f)))
+	}
+	entries, err := os.ReadDir(f.meta.Path)
+	if err != nil {
+		return nil, newError(ErrRead, "Children", err)
+	}
+	children := make([]*File, 0, len(entries))
+	for _, e := range entries {
+		child, err := NewFromFileLazy(filepath.Join(f.meta.Path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+// WalkFunc is called by Walk for each entry beneath a directory. rel is the
+// entry's slash-separated path relative to the directory. Returning
+// fs.SkipDir from a directory entry skips its contents; any other error
+// stops the walk and is returned by Walk.
+type WalkFunc func(rel string, f *File) error
+
+// Walk calls fn for every file and directory beneath f, in lexical order,
+// parents before their contents. f itself is not visited. Files are opened
+// as by NewFromFileLazy, so walking a large tree reads only what fn uses.
+func (f *File) Walk(fn WalkFunc) error {
+	if !f.dir {
+		return newError(ErrInvalidSource, "Walk", fmt.Errorf("%s is not a directory", describeFile(f)))
+	}
+	root := f.meta.Path
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return newError(ErrRead, "Walk", err)
+		}
+		if p == root {
+			return nil
+		}
+		child, err := NewFromFileLazy(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		return fn(filepath.ToSlash(rel), child)
+	})
+}
+
+// ZipTo writes the directory tree to a zip archive at destPath, creating
+// parent directories as needed, and returns a File for the archive. Entry
+// names are relative to the directory; empty directories are kept. An
+// archive written inside the directory is not added to itself. Use
+// WithDeterministic for reproducible output.
+func (f *File) ZipTo(destPath string, opts ...ArchiveOption) (*File, error) {
+	o := newArchiveOptions(opts)
+	if err := checkOptions("ZipTo", o.validate()); err != nil {
+		return nil, err
+	}
+	if !f.dir {
+		return nil, newError(ErrInvalidSource, "ZipTo", fmt.Errorf("%s is not a directory", describeFile(f)))
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return nil, newError(ErrWrite, "ZipTo", err)
+	}
+	out, err := os.Create(destPath)
+	if err != nil {
+		return nil, newError(ErrWrite, "ZipTo", err)
+	}
+	err = f.writeZip(out, destPath, o)
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = newError(ErrWrite, "ZipTo", cerr)
+	}
+	if err != nil {
+		_ = os.Remove(destPath)
+		return nil, err
+	}
+	return NewFromFile(destPath)
+}
+
+// writeZip streams the tree into w, skipping the archive at self.
+func (f *File) writeZip(w io.Writer, self string, o archiveOptions) error {
+	selfInfo, _ := os.Stat(self)
+	zw := o.newZipWriter(w)
+	root := f.meta.Path
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return newError(ErrRead, "ZipTo", err)
+		}
+		if p == root {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return newError(ErrRead, "ZipTo", err)
+		}
+		if selfInfo != nil && os.SameFile(info, selfInfo) {
+			return nil
+		}
+		if !d.IsDir() && !info.Mode().IsRegular() {
+			return nil // sockets, devices and symlinks have no portable form
+		}
+		rel, _ := filepath.Rel(root, p)
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			name += "/"
+		}
+		hdr, err := o.zipHeader(name, info)
+		if err != nil {
+			return newError(ErrWrite, "ZipTo", err)
+		}
+		dst, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return newError(ErrWrite, "ZipTo", err)
+		}
+		if d.IsDir() {
+			return nil
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return newError(ErrRead, "ZipTo", err)
+		}
+		defer src.Close()
+		if _, err := io.Copy(dst, src); err != nil {
+			return newError(ErrWrite, "ZipTo", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return newError(ErrWrite, "ZipTo", err)
+	}
+	return nil
+}
+
+// UploadDirToS3 uploads every regular file beneath the directory to bucket,
+// keyed by prefix plus the file's slash-separated relative path
+// ("assets/" + "css/site.css"). Files are uploaded one at a time with
+// UploadToS3WithContext and opts. A file that fails does not stop the
+// rest: the failures are returned together as a *BatchError naming each
+// key, and the objects that did upload are left in place. An error
+// walking the directory stops the upload.
+//
+// With WithPrefixTemplate the prefix is a template expanded per file with
+// ExpandTemplate, its Path field set to the relative path. It is parsed
+// before anything is uploaded, so a malformed template fails fast.
+func (f *File) UploadDirToS3(ctx context.Context, bucket, prefix string, opts ...UploadOption) error {
+	if !f.dir {
+		return newError(ErrInvalidSource, "UploadDirToS3", fmt.Errorf("%s is not a directory", describeFile(f)))
+	}
+	if err := checkS3Bucket("UploadDirToS3", bucket); err != nil {
+		return err
+	}
+	if err := checkNetwork(ctx, "UploadDirToS3"); err != nil {
+		return err
+	}
+	templated := newUploadOptions(opts).prefixTemplate
+	if templated {
+		if _, err := template.New("path").Funcs(templateFuncs).Parse(prefix); err != nil {
+			return newError(ErrTemplate, "UploadDirToS3", err)
+		}
+	}
+	var (
+		total  int
+		failed []*ItemError
+	)
+	err := f.Walk(func(rel string, child *File) error {
+		if child.IsDir() {
+			return nil
+		}
+		index := total
+		total++
+		p := prefix
+		if templated {
+			expanded, err := child.expandTemplate(prefix, rel, "UploadDirToS3")
+			if err != nil {
+				failed = append(failed, itemError(index, rel, "", "UploadDirToS3", ErrTemplate, err))
+				return nil
+			}
+			p = expanded
+		}
+		if p != "" && !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+		key := p + rel
+		if err := child.UploadToS3WithContext(ctx, bucket, key, opts...); err != nil {
+			failed = append(failed, itemError(index, key, "s3://"+bucket+"/"+key, "UploadDirToS3", ErrS3, err))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return newBatchError("UploadDirToS3", total, total-len(failed), failed)
+}
+
+// dirSize totals the sizes of the regular files beneath dir. Entries that
+// cannot be read are skipped.
+func dirSize(dir string) int64 {
+	var total int64
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,267 @@
+package file
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// dirFixture builds:
+//
+//	root/
+//	  a.txt        "alpha"
+//	  empty/
+//	  sub/
+//	    b.json     `{"b":1}`
+//	    deep/
+//	      c.txt    "charlie!"
+func dirFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	files := map[string]string{
+		"a.txt":          "alpha",
+		"sub/b.json":     `{"b":1}`,
+		"sub/deep/c.txt": "charlie!",
+	}
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestNewFromFile_Directory(t *testing.T) {
+	root := dirFixture(t)
+
+	f, err := NewFromFile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsDir() {
+		t.Fatal("IsDir = false")
+	}
+	if f.Name() != "root" || f.MimeType() != MimeTypeDirectory || f.Source() != SourceFile {
+		t.Errorf("got name=%q mime=%q source=%s", f.Name(), f.MimeType(), f.Source())
+	}
+	if got := f.Metadata().EntryCount; got != 3 {
+		t.Errorf("EntryCount = %d, want 3", got)
+	}
+	if got, want := f.Size(), int64(len("alpha")+len(`{"b":1}`)+len("charlie!")); got != want {
+		t.Errorf("Size = %d, want %d", got, want)
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := f.Metadata().Size; got != f.Size() {
+				t.Errorf("Metadata().Size = %d, Size = %d", got, f.Size())
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := f.Read(); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("Read err = %v, want ErrInvalidSource", err)
+	}
+	if _, err := f.Checksum(); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("Checksum err = %v, want ErrInvalidSource", err)
+	}
+
+	regular, _ := NewFromFile(filepath.Join(root, "a.txt"))
+	if regular.IsDir() {
+		t.Error("regular file reported as directory")
+	}
+	if _, err := regular.Children(); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("Children on file err = %v, want ErrInvalidSource", err)
+	}
+}
+
+func TestFile_Children(t *testing.T) {
+	f, _ := NewFromFile(dirFixture(t))
+
+	children, err := f.Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range children {
+		names = append(names, c.Name())
+	}
+	if want := []string{"a.txt", "empty", "sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if children[0].IsDir() || !children[1].IsDir() || !children[2].IsDir() {
+		t.Error("IsDir mismatch among children")
+	}
+	if children[0].Loaded() {
+		t.Error("Children read a.txt before it was used")
+	}
+	if text, _ := children[0].ReadText(); text != "alpha" {
+		t.Errorf("a.txt = %q", text)
+	}
+	if got := children[2].Metadata().EntryCount; got != 2 {
+		t.Errorf("sub EntryCount = %d, want 2", got)
+	}
+}
+
+func TestFile_Walk(t *testing.T) {
+	f, _ := NewFromFile(dirFixture(t))
+
+	var visited []string
+	err := f.Walk(func(rel string, c *File) error {
+		entry := rel
+		if c.IsDir() {
+			entry += "/"
+		}
+		visited = append(visited, entry)
+		if c.Loaded() {
+			t.Errorf("Walk read %s before it was used", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.txt", "empty/", "sub/", "sub/b.json", "sub/deep/", "sub/deep/c.txt"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited = %v, want %v", visited, want)
+	}
+
+	t.Run("skip dir", func(t *testing.T) {
+		var got []string
+		err := f.Walk(func(rel string, c *File) error {
+			if rel == "sub/deep" {
+				return fs.SkipDir
+			}
+			got = append(got, rel)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"a.txt", "empty", "sub", "sub/b.json"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("visited = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		stop := errors.New("stop")
+		err := f.Walk(func(rel string, c *File) error { return stop })
+		if !errors.Is(err, stop) {
+			t.Errorf("err = %v, want stop", err)
+		}
+	})
+}
+
+func TestFile_ZipTo_RoundTrip(t *testing.T) {
+	root := dirFixture(t)
+	f, _ := NewFromFile(root)
+
+	// Writing the archive inside the tree must not add it to itself.
+	zipped, err := f.ZipTo(filepath.Join(root, "out", "tree.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zipped.IsDir() || zipped.Size() == 0 {
+		t.Fatalf("archive File = %v", zipped)
+	}
+
+	zr, err := zip.OpenReader(zipped.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	got := map[string]string{}
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			got[zf.Name] = "<dir>"
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[zf.Name] = string(data)
+	}
+	want := map[string]string{
+		"a.txt":          "alpha",
+		"empty/":         "<dir>",
+		"out/":           "<dir>",
+		"sub/":           "<dir>",
+		"sub/b.json":     `{"b":1}`,
+		"sub/deep/":      "<dir>",
+		"sub/deep/c.txt": "charlie!",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("archive = %v, want %v", got, want)
+	}
+}
+
+func TestFile_UploadDirToS3(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+		body = map[string]string{}
+	)
+	mock := &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			data, _ := io.ReadAll(params.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			key := aws.ToString(params.Key)
+			keys = append(keys, key)
+			body[key] = string(data)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	f, _ := NewFromFile(dirFixture(t))
+	if err := f.UploadDirToS3(context.Background(), "bucket", "site"); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if want := []string{"site/a.txt", "site/sub/b.json", "site/sub/deep/c.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	if body["site/sub/deep/c.txt"] != "charlie!" {
+		t.Errorf("c.txt body = %q", body["site/sub/deep/c.txt"])
+	}
+
+	t.Run("failure names key", func(t *testing.T) {
+		mock.putObjectFn = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			return nil, errors.New("denied")
+		}
+		err := f.UploadDirToS3(context.Background(), "bucket", "site/")
+		if !errors.Is(err, ErrS3) {
+			t.Fatalf("err = %v, want ErrS3", err)
+		}
+		if want := "site/a.txt"; !strings.Contains(err.Error(), want) {
+			t.Errorf("err %q does not name %s", err, want)
+		}
+	})
+}
@@ -3,6 +3,12 @@ package file
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Sentinel errors for the file package.
@@ -24,6 +30,89 @@ var (
 
 	// ErrWrite is returned when writing file content fails.
 	ErrWrite = errors.New("file: write operation failed")
+
+	// ErrPanic is returned when a caller-supplied callback panics while
+	// processing a file.
+	ErrPanic = errors.New("file: callback panicked")
+
+	// ErrChecksumMismatch is returned when written or received content does
+	// not match its expected digest.
+	ErrChecksumMismatch = errors.New("file: checksum mismatch")
+
+	// ErrTimeout is returned when an operation's deadline passes, either the
+	// caller's or the default from DownloadTimeout, UploadTimeout or
+	// MetadataTimeout.
+	ErrTimeout = errors.New("file: operation timed out")
+
+	// ErrNotText is returned when a text-only helper is given binary content.
+	ErrNotText = errors.New("file: content is not text")
+
+	// ErrNotPDF is returned when a PDF helper is given content that is not a
+	// well-formed PDF.
+	ErrNotPDF = errors.New("file: content is not a PDF")
+
+	// ErrExists is returned when a write would replace an existing file and
+	// the caller asked for CollisionErrorOut.
+	ErrExists = errors.New("file: destination already exists")
+
+	// ErrNoHandler is returned by HandlerRegistry.Dispatch when no handler
+	// matches a file's MIME type and no fallback is registered.
+	ErrNoHandler = errors.New("file: no handler for MIME type")
+
+	// ErrCompression is returned when compressing or decompressing fails,
+	// including when the codec is unknown or not registered.
+	ErrCompression = errors.New("file: compression failed")
+
+	// ErrInvalidOption is returned, before any I/O, when an operation is
+	// given an out-of-range option value or options that conflict. See
+	// CheckOptions.
+	ErrInvalidOption = errors.New("file: invalid option")
+
+	// ErrUnexpectedType is returned when a server answers WithAccept with a
+	// Content-Type that was not accepted.
+	ErrUnexpectedType = errors.New("file: unexpected content type")
+
+	// ErrTemplate is returned when a path or key template does not parse,
+	// refers to a missing field or attribute, or expands to nothing.
+	ErrTemplate = errors.New("file: invalid template")
+
+	// ErrTooManyObjects is returned when an operation would touch more
+	// objects than its safety cap allows (see WithMaxKeys).
+	ErrTooManyObjects = errors.New("file: too many objects")
+
+	// ErrInvalidArgument is returned, before any request is made, when a
+	// bucket name or object key breaks S3's rules (see ValidateBucketName
+	// and ValidateKey), a destination path cannot be expanded (see
+	// WithExpandPath), or a byte range is negative or starts past the end
+	// of the content (see ReadRange).
+	ErrInvalidArgument = errors.New("file: invalid argument")
+
+	// ErrStalled is returned when a transfer's rolling throughput stays
+	// below the WithMinThroughput floor for a whole window. Unlike
+	// ErrTimeout it signals a degraded connection rather than a spent
+	// budget, so retrying is usually worthwhile.
+	ErrStalled = errors.New("file: transfer stalled")
+
+	// ErrNetworkDisallowed is returned when StrictLocal is set and a File
+	// method would reach the network without a context from
+	// WithAllowNetwork.
+	ErrNetworkDisallowed = errors.New("file: network access disallowed")
+
+	// ErrReadOnly is returned when an operation would rewrite the content
+	// of a frozen File shared through a FileCache.
+	ErrReadOnly = errors.New("file: file is read-only")
+
+	// ErrShuttingDown is returned by transfers started after Shutdown, and
+	// by those Shutdown cancelled because its deadline passed first.
+	ErrShuttingDown = errors.New("file: shutting down")
+
+	// ErrTooLarge is returned when content is too large for the requested
+	// form, such as a data: URI longer than DataURIMaxSize allows.
+	ErrTooLarge = errors.New("file: content too large")
+
+	// ErrMetadataTooLarge is returned, before any request is made, when an
+	// object's user metadata would exceed MaxS3UserMetadataSize.
+	ErrMetadataTooLarge = errors.New("file: metadata too large")
 )
 
 // FileError wraps an underlying error with a sentinel from this package.
@@ -34,14 +123,69 @@ type FileError struct {
 	Op string
 	// Err is the underlying error.
 	Err error
+	// Elapsed is how long the operation ran before timing out. Set only for
+	// ErrTimeout.
+	Elapsed time.Duration
+	// Budget is the deadline the operation ran under. Set only for
+	// ErrTimeout.
+	Budget time.Duration
+	// BodyPreview holds up to the first 4 KB of the response body when an
+	// HTTP request failed with a non-2xx status.
+	BodyPreview []byte
+	// BodyContentType is the Content-Type of the response BodyPreview came
+	// from.
+	BodyContentType string
+	// StatusCode is the response status when an HTTP request failed with a
+	// non-2xx status.
+	StatusCode int
+	// Attempts is how many requests were made when WithRetry was in
+	// effect; zero otherwise.
+	Attempts int
 }
 
-// Error returns the formatted error string.
+// Error returns the formatted error string. Credentials are masked: URL
+// query strings and user info (scheme, host and path are kept),
+// Authorization header values and bearer tokens. See Unredacted.
 func (e *FileError) Error() string {
-	if e.Err != nil {
-		return fmt.Sprintf("%s: %s: %v", e.Sentinel, e.Op, e.Err)
+	return e.format(true)
+}
+
+// Unredacted returns the error string without masking, for privileged
+// debugging. Never log it where the redacted form is expected. A FileError
+// wrapped inside another error type by fmt.Errorf keeps its redacted text.
+func (e *FileError) Unredacted() string {
+	return e.format(false)
+}
+
+// format renders the error, masking credentials when redact is set.
+func (e *FileError) format(redact bool) string {
+	var cause string
+	if inner, ok := e.Err.(*FileError); ok {
+		cause = inner.format(redact)
+	} else if e.Err != nil {
+		cause = e.Err.Error()
 	}
-	return fmt.Sprintf("%s: %s", e.Sentinel, e.Op)
+	var s string
+	switch {
+	case e.Budget > 0:
+		s = fmt.Sprintf("%s: %s: %s (elapsed %s of %s budget)", e.Sentinel, e.Op, cause, e.Elapsed.Round(time.Millisecond), e.Budget)
+	case len(e.BodyPreview) > 0:
+		s = fmt.Sprintf("%s: %s: %s: body: %s", e.Sentinel, e.Op, cause, formatBodyPreview(e.BodyPreview))
+	case e.Err != nil:
+		s = fmt.Sprintf("%s: %s: %s", e.Sentinel, e.Op, cause)
+	default:
+		s = fmt.Sprintf("%s: %s", e.Sentinel, e.Op)
+	}
+	switch {
+	case e.Attempts == 1:
+		s += " (after 1 attempt)"
+	case e.Attempts > 1:
+		s += fmt.Sprintf(" (after %d attempts)", e.Attempts)
+	}
+	if redact {
+		s = redactText(s)
+	}
+	return s
 }
 
 // Unwrap returns the underlying error so errors.Is and errors.As work correctly.
@@ -54,6 +198,75 @@ func (e *FileError) Is(target error) bool {
 	return errors.Is(e.Sentinel, target)
 }
 
+// bodyPreviewLimit caps how much of an error response body is kept.
+const bodyPreviewLimit = 4 << 10
+
+// Limits on how much of BodyPreview Error() prints.
+const (
+	bodyPreviewTextChars = 512
+	bodyPreviewBinaryLen = 64
+)
+
+// httpStatusError returns an ErrHTTP error for a non-2xx response, keeping
+// up to bodyPreviewLimit bytes of its body. The caller still owns (and must
+// close) resp.Body; anything past the preview is left unread.
+func httpStatusError(op string, resp *http.Response) *FileError {
+	e := newError(ErrHTTP, op, fmt.Errorf("status %d", resp.StatusCode))
+	e.StatusCode = resp.StatusCode
+	preview, _ := io.ReadAll(io.LimitReader(resp.Body, bodyPreviewLimit))
+	if len(preview) > 0 {
+		e.BodyPreview = preview
+		e.BodyContentType = resp.Header.Get("Content-Type")
+	}
+	return e
+}
+
+// formatBodyPreview renders a body preview for Error(): printable text as
+// is (shortened to bodyPreviewTextChars), anything else as a hex-escaped
+// snippet of its first bodyPreviewBinaryLen bytes.
+func formatBodyPreview(b []byte) string {
+	if isPrintableText(b) {
+		s := strings.TrimSpace(string(b))
+		if r := []rune(s); len(r) > bodyPreviewTextChars {
+			s = string(r[:bodyPreviewTextChars]) + "…"
+		}
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i, c := range b {
+		if i == bodyPreviewBinaryLen {
+			sb.WriteString(`"…`)
+			return sb.String()
+		}
+		if c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// isPrintableText reports whether b is valid UTF-8 made of printable
+// characters and ordinary whitespace. A truncated final rune is allowed.
+func isPrintableText(b []byte) bool {
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size <= 1 {
+			// A rune cut off by the preview limit is fine; anything else
+			// is invalid UTF-8.
+			return !utf8.FullRune(b[i:])
+		}
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
 // newError creates a new FileError.
 func newError(sentinel error, op string, err error) *FileError {
 	return &FileError{
@@ -0,0 +1,131 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Exists reports whether the file still exists at its source: os.Stat for
+// file sources, HeadObject for S3, the backend's Stat for Storage sources,
+// and a HEAD request (falling back to a one-byte ranged GET when HEAD is not
+// allowed) for URLs. Bytes and stream sources hold their content and always
+// exist.
+//
+// A definite absence — IsNotExist, NotFound/NoSuchKey, HTTP 404/410 —
+// returns (false, nil). Anything that prevents an answer returns false with
+// a wrapped error, so cleanup jobs never mistake an outage for a deletion.
+// MetadataTimeout applies to the remote checks when ctx has no deadline.
+func (f *File) Exists(ctx context.Context) (bool, error) {
+	switch f.source {
+	case SourceFile:
+		if _, err := os.Stat(f.meta.Path); err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, newError(ErrRead, "Exists", err)
+		}
+		return true, nil
+	case SourceS3, SourceURL, SourceStorage:
+		if err := checkNetwork(ctx, "Exists"); err != nil {
+			return false, err
+		}
+		switch f.source {
+		case SourceS3:
+			return f.existsS3(ctx)
+		case SourceURL:
+			return f.existsURL(ctx)
+		}
+		return f.existsStorage(ctx)
+	default:
+		return true, nil
+	}
+}
+
+// existsS3 checks the S3 object with HeadObject.
+func (f *File) existsS3(ctx context.Context) (bool, error) {
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return false, newError(ErrInvalidSource, "Exists", fmt.Errorf("file has no S3 location"))
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	s3Client, _ := s3Clients()
+	_, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return true, nil
+	}
+	if isS3NotFound(err) {
+		return false, nil
+	}
+	return false, dl.newError(ctx, ErrS3, "Exists", err)
+}
+
+// isS3NotFound reports whether err means the object does not exist.
+func isS3NotFound(err error) bool {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
+
+// existsStorage checks the object with its backend's Stat.
+func (f *File) existsStorage(ctx context.Context) (bool, error) {
+	s, err := StorageFor(f.meta.URL)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	if _, err := s.Stat(ctx, f.meta.URL); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, dl.newError(ctx, ErrRead, "Exists", err)
+	}
+	return true, nil
+}
+
+// existsURL checks the URL with HEAD, falling back to a ranged GET.
+func (f *File) existsURL(ctx context.Context) (bool, error) {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	res, err := probeOnce(ctx, http.MethodHead, f.meta.URL)
+	if err != nil {
+		return false, dl.newError(ctx, ErrHTTP, "Exists", err)
+	}
+	if res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented {
+		res, err = probeOnce(ctx, http.MethodGet, f.meta.URL)
+		if err != nil {
+			return false, dl.newError(ctx, ErrHTTP, "Exists", err)
+		}
+	}
+	switch status := res.StatusCode; {
+	case status >= 200 && status < 300:
+		return true, nil
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return false, nil
+	default:
+		e := newError(ErrHTTP, "Exists", fmt.Errorf("status %d", status))
+		e.StatusCode = status
+		return false, e
+	}
+}
@@ -0,0 +1,131 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestExists_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "here.txt")
+	os.WriteFile(path, []byte("x"), 0o644)
+	f, err := NewFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := f.Exists(context.Background()); !ok || err != nil {
+		t.Errorf("Exists = %v, %v; want true, nil", ok, err)
+	}
+	os.Remove(path)
+	if ok, err := f.Exists(context.Background()); ok || err != nil {
+		t.Errorf("after removal Exists = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestExists_S3(t *testing.T) {
+	tests := []struct {
+		name    string
+		headErr error
+		want    bool
+		wantErr error
+	}{
+		{name: "present", want: true},
+		{name: "not found", headErr: &types.NotFound{}},
+		{name: "no such key", headErr: &types.NoSuchKey{}},
+		{name: "access denied", headErr: fmt.Errorf("AccessDenied"), wantErr: ErrS3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBucket, gotKey string
+			cleanup := setMockS3(&mockS3Client{
+				headObjectFn: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					gotBucket, gotKey = *params.Bucket, *params.Key
+					if tt.headErr != nil {
+						return nil, tt.headErr
+					}
+					return &s3.HeadObjectOutput{}, nil
+				},
+			}, &mockPresignClient{})
+			defer cleanup()
+
+			f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "dir/key"}
+			ok, err := f.Exists(context.Background())
+			if gotBucket != "bucket" || gotKey != "dir/key" {
+				t.Errorf("HeadObject(%q, %q)", gotBucket, gotKey)
+			}
+			if tt.wantErr != nil {
+				if ok || !errors.Is(err, tt.wantErr) {
+					t.Errorf("Exists = %v, %v; want false, %v", ok, err, tt.wantErr)
+				}
+				return
+			}
+			if ok != tt.want || err != nil {
+				t.Errorf("Exists = %v, %v; want %v, nil", ok, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExists_URL(t *testing.T) {
+	tests := []struct {
+		name       string
+		headStatus int
+		getStatus  int
+		want       bool
+		wantErr    bool
+	}{
+		{name: "head ok", headStatus: http.StatusOK, want: true},
+		{name: "head not found", headStatus: http.StatusNotFound},
+		{name: "gone", headStatus: http.StatusGone},
+		{name: "head not allowed, ranged get ok", headStatus: http.StatusMethodNotAllowed, getStatus: http.StatusPartialContent, want: true},
+		{name: "head not allowed, get not found", headStatus: http.StatusMethodNotAllowed, getStatus: http.StatusNotFound},
+		{name: "server error", headStatus: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRange string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.WriteHeader(tt.headStatus)
+					return
+				}
+				gotRange = r.Header.Get("Range")
+				w.WriteHeader(tt.getStatus)
+			}))
+			defer srv.Close()
+			cleanup := setMockHTTP(srv.Client())
+			defer cleanup()
+
+			f := &File{source: SourceURL, meta: Metadata{URL: srv.URL + "/a.txt"}}
+			ok, err := f.Exists(context.Background())
+			if tt.wantErr {
+				if ok || !errors.Is(err, ErrHTTP) {
+					t.Errorf("Exists = %v, %v; want false, ErrHTTP", ok, err)
+				}
+				return
+			}
+			if ok != tt.want || err != nil {
+				t.Errorf("Exists = %v, %v; want %v, nil", ok, err, tt.want)
+			}
+			if tt.getStatus != 0 && gotRange != "bytes=0-0" {
+				t.Errorf("fallback GET Range = %q", gotRange)
+			}
+		})
+	}
+}
+
+func TestExists_InMemorySources(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	if ok, err := f.Exists(context.Background()); !ok || err != nil {
+		t.Errorf("Exists = %v, %v; want true, nil", ok, err)
+	}
+}
@@ -0,0 +1,186 @@
+package file
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataOrigin identifies where a metadata field's value came from.
+type MetadataOrigin int
+
+const (
+	// OriginNone means no source supplied a value.
+	OriginNone MetadataOrigin = iota
+	// OriginHint is a caller-supplied MetadataHint.
+	OriginHint
+	// OriginContentDisposition is the filename in a Content-Disposition
+	// header or S3 ContentDisposition.
+	OriginContentDisposition
+	// OriginURL is the last segment of the URL path or object key.
+	OriginURL
+	// OriginPath is the base name of a local path.
+	OriginPath
+	// OriginHeader is an HTTP response header (Content-Type,
+	// Content-Length, ETag, Last-Modified), including the type negotiated
+	// with WithAccept.
+	OriginHeader
+	// OriginS3 is S3 object metadata (ContentType, ContentLength, ETag,
+	// LastModified).
+	OriginS3
+	// OriginStorage is metadata reported by a registered storage backend.
+	OriginStorage
+	// OriginStat is the local filesystem's stat information.
+	OriginStat
+	// OriginFilename is a MIME type or extension looked up from the name.
+	OriginFilename
+	// OriginMagicBytes is content sniffing.
+	OriginMagicBytes
+	// OriginContent is the length of the content actually read.
+	OriginContent
+	// OriginFallback is a derived default: an extension from the MIME type,
+	// or application/octet-stream when nothing identified the type.
+	OriginFallback
+)
+
+var originNames = [...]string{
+	OriginNone:               "none",
+	OriginHint:               "hint",
+	OriginContentDisposition: "content-disposition",
+	OriginURL:                "url",
+	OriginPath:               "path",
+	OriginHeader:             "header",
+	OriginS3:                 "s3",
+	OriginStorage:            "storage",
+	OriginStat:               "stat",
+	OriginFilename:           "filename",
+	OriginMagicBytes:         "magic-bytes",
+	OriginContent:            "content",
+	OriginFallback:           "fallback",
+}
+
+// String returns the origin's name ("magic-bytes").
+func (o MetadataOrigin) String() string {
+	if o < 0 || int(o) >= len(originNames) {
+		return "MetadataOrigin(" + strconv.Itoa(int(o)) + ")"
+	}
+	return originNames[o]
+}
+
+// OriginCandidate is a value a source supplied for a field.
+type OriginCandidate struct {
+	Origin MetadataOrigin
+	Value  string
+}
+
+// FieldOrigin explains one metadata field: the value it was given during
+// resolution, the source that supplied it, and the values it replaced,
+// in the order they were set. Values are formatted as strings (sizes in
+// decimal, times in RFC 3339).
+type FieldOrigin struct {
+	Origin     MetadataOrigin
+	Value      string
+	Overridden []OriginCandidate
+}
+
+// MetadataExplanation records how each metadata field was determined when
+// a File was created. See File.Explain.
+type MetadataExplanation struct {
+	Name         FieldOrigin
+	MimeType     FieldOrigin
+	Extension    FieldOrigin
+	Size         FieldOrigin
+	Hash         FieldOrigin
+	LastModified FieldOrigin
+}
+
+// Explain reports where each of the File's metadata fields came from when
+// it was created, and which competing values lost: why a download is
+// "text/html" when the server said so but the bytes say otherwise, say.
+// Later changes (SetName and the like) are not tracked, and Files not
+// built by a constructor report OriginNone throughout.
+func (f *File) Explain() MetadataExplanation {
+	if f.meta.explanation == nil {
+		return MetadataExplanation{}
+	}
+	return *f.meta.explanation
+}
+
+// String renders the explanation one field per line:
+//
+//	MimeType: "image/png" from magic-bytes, over header "text/html"
+func (x MetadataExplanation) String() string {
+	var b strings.Builder
+	for _, field := range []struct {
+		name string
+		fo   FieldOrigin
+	}{
+		{"Name", x.Name},
+		{"MimeType", x.MimeType},
+		{"Extension", x.Extension},
+		{"Size", x.Size},
+		{"Hash", x.Hash},
+		{"LastModified", x.LastModified},
+	} {
+		b.WriteString(field.name)
+		b.WriteString(": ")
+		if field.fo.Origin == OriginNone {
+			b.WriteString("unset\n")
+			continue
+		}
+		b.WriteString(strconv.Quote(field.fo.Value))
+		b.WriteString(" from ")
+		b.WriteString(field.fo.Origin.String())
+		for i, c := range field.fo.Overridden {
+			if i == 0 {
+				b.WriteString(", over ")
+			} else {
+				b.WriteString(", ")
+			}
+			b.WriteString(c.Origin.String())
+			b.WriteByte(' ')
+			b.WriteString(strconv.Quote(c.Value))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// noteOrigin attributes every explained field of m whose value changed
+// since the last call to origin. Resolvers call it after each step; it
+// only formats and compares six fields, so it is cheap enough to run
+// always.
+func (m *Metadata) noteOrigin(origin MetadataOrigin) {
+	if m.explanation == nil {
+		m.explanation = &MetadataExplanation{}
+	}
+	x := m.explanation
+	x.Name.note(origin, m.Name)
+	x.MimeType.note(origin, m.MimeType)
+	x.Extension.note(origin, m.Extension)
+	var size, modified string
+	if m.Size != 0 {
+		size = strconv.FormatInt(m.Size, 10)
+	}
+	if !m.LastModified.IsZero() {
+		modified = m.LastModified.Format(time.RFC3339)
+	}
+	x.Size.note(origin, size)
+	x.Hash.note(origin, m.Hash)
+	x.LastModified.note(origin, modified)
+}
+
+// note records value as coming from origin when it differs from the
+// current value, keeping the replaced value as a losing candidate.
+func (fo *FieldOrigin) note(origin MetadataOrigin, value string) {
+	if value == fo.Value {
+		return
+	}
+	if fo.Origin != OriginNone {
+		fo.Overridden = append(fo.Overridden, OriginCandidate{Origin: fo.Origin, Value: fo.Value})
+	}
+	fo.Origin, fo.Value = origin, value
+	if value == "" {
+		fo.Origin = OriginNone
+	}
+}
@@ -0,0 +1,85 @@
+package file
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFile_Explain_URLConflict(t *testing.T) {
+	png := append([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0x00, 0x00, 0x0D, 0x49, 0x48, 0x44, 0x52}, bytes.Repeat([]byte{0}, 64)...)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="chart.png"`)
+		w.Header().Set("ETag", `"abc123"`)
+		w.Write(png)
+	}))
+	defer srv.Close()
+
+	f, err := NewFromURL(srv.URL+"/download", MetadataHint{Name: "hinted.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := f.Explain()
+
+	want := FieldOrigin{
+		Origin:     OriginMagicBytes,
+		Value:      "image/png",
+		Overridden: []OriginCandidate{{OriginHeader, "text/html; charset=utf-8"}},
+	}
+	if !reflect.DeepEqual(x.MimeType, want) {
+		t.Errorf("MimeType = %+v, want %+v", x.MimeType, want)
+	}
+	want = FieldOrigin{
+		Origin:     OriginContentDisposition,
+		Value:      "chart.png",
+		Overridden: []OriginCandidate{{OriginHint, "hinted.bin"}},
+	}
+	if !reflect.DeepEqual(x.Name, want) {
+		t.Errorf("Name = %+v, want %+v", x.Name, want)
+	}
+	if x.Extension.Origin != OriginMagicBytes || x.Extension.Value != "png" {
+		t.Errorf("Extension = %+v", x.Extension)
+	}
+	if x.Size.Origin != OriginHeader || x.Size.Value != "80" {
+		t.Errorf("Size = %+v", x.Size)
+	}
+	if x.Hash.Origin != OriginHeader || x.Hash.Value != "abc123" {
+		t.Errorf("Hash = %+v", x.Hash)
+	}
+	if x.LastModified.Origin != OriginNone {
+		t.Errorf("LastModified = %+v", x.LastModified)
+	}
+
+	s := x.String()
+	for _, line := range []string{
+		`MimeType: "image/png" from magic-bytes, over header "text/html; charset=utf-8"`,
+		`Name: "chart.png" from content-disposition, over hint "hinted.bin"`,
+		`LastModified: unset`,
+	} {
+		if !strings.Contains(s, line+"\n") {
+			t.Errorf("String() missing %q:\n%s", line, s)
+		}
+	}
+}
+
+func TestFile_Explain_Fallbacks(t *testing.T) {
+	f, _ := NewFromBytes([]byte{0x01, 0x02, 0x03})
+	x := f.Explain()
+	if x.Size.Origin != OriginContent || x.Size.Value != "3" {
+		t.Errorf("Size = %+v", x.Size)
+	}
+	if x.Name.Origin != OriginNone {
+		t.Errorf("Name = %+v", x.Name)
+	}
+
+	if got := (&File{}).Explain(); !reflect.DeepEqual(got, MetadataExplanation{}) {
+		t.Errorf("zero File Explain = %+v", got)
+	}
+	if got := MetadataOrigin(99).String(); got != "MetadataOrigin(99)" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -23,20 +23,29 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-// S3ClientFactory is a function that creates an S3 client. It can be replaced
-// in tests to inject a mock client.
-var S3ClientFactory = defaultS3ClientFactory
-
 // S3API defines the subset of S3 client methods used by this package.
 // This enables mocking in tests.
 type S3API interface {
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	UploadPartCopy(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
 }
 
 // S3PresignAPI defines the subset of S3 presign client methods used by this package.
@@ -45,21 +54,15 @@ type S3PresignAPI interface {
 	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 }
 
-// HTTPClient is an interface for performing HTTP requests. It can be replaced
-// in tests with an httptest server-backed client.
-var HTTPClient httpDoer = http.DefaultClient
-
-type httpDoer interface {
-	Do(req *http.Request) (*http.Response, error)
-}
-
+// defaultS3ClientFactory builds clients from the AWS config in the
+// environment. When it cannot be loaded, every S3 operation fails with
+// ErrS3 saying why.
 func defaultS3ClientFactory() (S3API, S3PresignAPI) {
-	cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	client, presignClient, err := newS3Clients(S3Config{})
 	if err != nil {
-		panic(fmt.Sprintf("file: unable to load AWS config: %v", err))
+		u := unavailableS3{err: err}
+		return u, u
 	}
-	client := s3.NewFromConfig(cfg)
-	presignClient := s3.NewPresignClient(client)
 	return client, presignClient
 }
 
@@ -73,6 +76,15 @@ type File struct {
 	s3Bucket string // set when source is S3
 	s3Key    string // set when source is S3
 
+	// sourceDigests holds hex digests published by the source (HTTP
+	// Content-MD5, S3 ChecksumSHA256), used by WithVerify to detect
+	// corruption in transit.
+	sourceDigests map[Algorithm]string
+
+	// provenance is the chain of sources this File's content passed through,
+	// oldest first. The last entry always matches source.
+	provenance []SourceRef
+
 	// Lazy streaming state. When `lazy` is set, NewFromStream did NOT buffer
 	// the whole payload. Magic-byte detection ran against `streamHead` (first
 	// few KB), and the remainder is still in `streamTail` — drained chunk-by-
@@ -81,6 +93,44 @@ type File struct {
 	lazy       bool
 	streamHead []byte
 	streamTail io.Reader
+	// consumedBy names the operation a lazy stream was handed over to, so
+	// a later Read can say where the content went.
+	consumedBy string
+
+	// ReaderAt-backed state (NewFromReaderAt). Content is served through
+	// io.SectionReader views until Read() buffers it; the caller owns
+	// readerAt and must keep it valid for the File's lifetime.
+	readerAt     io.ReaderAt
+	readerAtSize int64
+
+	// transferSize is the number of bytes that crossed the wire in the most
+	// recent download or upload of this File (after content encoding), or 0
+	// when it has not been transferred. See TransferSize.
+	transferSize int64
+
+	// missingErr is the ErrNotFound from the last attempt to fetch a File
+	// without content from its source, and missingAt when it happened. See
+	// missingSourceTTL.
+	missingErr error
+	missingAt  time.Time
+
+	// dir marks a directory-mode File (see NewFromFile). dirTotal
+	// aggregates its size on first use; meta.Size is left alone so the
+	// getters never write to f.
+	dir      bool
+	dirTotal *dirTotal
+
+	// redirects lists the URLs NewFromURL requested when it was
+	// redirected, original first and final last. See RedirectChain.
+	redirects []string
+
+	// frozen marks a read-only view handed out by a FileCache; its content
+	// buffer is shared with other callers.
+	frozen bool
+
+	// derivatives are Files produced from this one (thumbnails, previews),
+	// by name. See AddDerivative.
+	derivatives map[string]*File
 }
 
 // streamHeadBytes is the size of the head buffer read up-front for magic-byte
@@ -92,64 +142,202 @@ const streamHeadBytes = 64 * 1024
 // --- Constructors ---
 
 // NewFromURL fetches a file from the given URL and returns a File.
-func NewFromURL(rawURL string, hints ...MetadataHint) (*File, error) {
-	var hint MetadataHint
-	if len(hints) > 0 {
-		hint = hints[0]
+func NewFromURL(rawURL string, opts ...ReadOption) (*File, error) {
+	return NewFromURLWithContext(context.Background(), rawURL, opts...)
+}
+
+// NewFromURLWithContext fetches a file from the given URL using the given
+// context. DownloadTimeout applies when ctx has no deadline. Cancelling
+// ctx aborts the request, even mid-body, with an ErrHTTP error that also
+// matches context.Canceled.
+func NewFromURLWithContext(ctx context.Context, rawURL string, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromURL", ro.validate()); err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, rawURL, nil)
+	if ro.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ro.timeout)
+		defer cancel()
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
 	if err != nil {
-		return nil, newError(ErrHTTP, "NewFromURL", err)
+		return nil, dl.newError(ctx, ErrHTTP, "NewFromURL", err)
 	}
-	resp, err := HTTPClient.Do(req)
+	defer release()
+
+	var res *urlResponse
+	err = ro.retry.do(ctx, dl, "NewFromURL", func() (rh retryHint, err error) {
+		res, rh, err = fetchURL(ctx, dl, "NewFromURL", rawURL, ro, nil)
+		return rh, err
+	})
 	if err != nil {
-		return nil, newError(ErrHTTP, "NewFromURL", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	f, err := newURLFile(res, rawURL, hint, ro, "NewFromURL")
+	if err != nil {
+		return nil, err
+	}
+	return withProvenance(ro.normalizeName(f)), nil
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, newError(ErrHTTP, "NewFromURL", fmt.Errorf("status %d", resp.StatusCode))
+// newURLFile builds the File for a response fetchURL read in full,
+// resolving its metadata against hint.
+func newURLFile(res *urlResponse, rawURL string, hint MetadataHint, ro readOptions, op string) (*File, error) {
+	resp, data, decoded, negotiated := res.resp, res.data, res.decoded, res.negotiated
+
+	meta := resolveMetadataFromHTTPResponse(resp, rawURL, data, hint)
+	if len(ro.accept) > 0 {
+		applyNegotiatedExtension(&meta, negotiated)
+		meta.noteOrigin(OriginHeader)
+	}
+	if decoded {
+		// Content-Length described the encoded body.
+		meta.Size = int64(len(data))
+		meta.noteOrigin(OriginContent)
+	}
+
+	f := &File{
+		source:       SourceURL,
+		meta:         meta,
+		data:         data,
+		loaded:       true,
+		transferSize: res.wire,
+		redirects:    redirectChain(resp),
+	}
+	// Content-MD5 and published SHA-256 digests cover the encoded body, so
+	// they are only comparable to the content when nothing was decoded.
+	if !decoded {
+		if d := base64DigestToHex(resp.Header.Get("Content-MD5")); d != "" {
+			f.sourceDigests = map[Algorithm]string{AlgorithmMD5: d}
+		}
+		d, err := verifyPublishedSHA256(resp, data, op)
+		if err != nil {
+			return nil, err
+		}
+		if d != "" {
+			if f.sourceDigests == nil {
+				f.sourceDigests = map[Algorithm]string{}
+			}
+			f.sourceDigests[AlgorithmSHA256] = d
+		}
 	}
+	return f, nil
+}
+
+// urlResponse is a response fetchURL read in full.
+type urlResponse struct {
+	resp       *http.Response // body already read and closed
+	data       []byte         // decoded body
+	wire       int64          // bytes received on the wire
+	decoded    bool           // a Content-Encoding was decoded
+	negotiated string         // the response Content-Type
+	// notModified is set when a conditional request got 304; only resp is
+	// set then.
+	notModified bool
+}
 
-	data, err := io.ReadAll(resp.Body)
+// fetchURL makes one GET request for rawURL on behalf of op and reads the
+// whole body. cond holds conditional request headers, if any; a 304 answer
+// to them is returned with notModified set rather than as an error. A
+// failure's retryHint says whether WithRetry may try again.
+func fetchURL(ctx context.Context, dl opDeadline, op, rawURL string, ro readOptions, cond http.Header) (*urlResponse, retryHint, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return nil, newError(ErrRead, "NewFromURL", err)
+		return nil, retryHint{}, newError(ErrHTTP, op, err)
+	}
+	// Ask for the registered encodings explicitly and decode them here
+	// rather than in the transport, so the encoded (on-the-wire) size can
+	// be recorded.
+	req.Header.Set("Accept-Encoding", acceptEncoding())
+	if len(ro.accept) > 0 {
+		req.Header.Set("Accept", acceptHeader(ro.accept))
+	}
+	for k, v := range cond {
+		req.Header[k] = v
+	}
+	client := ro.client
+	if client == nil {
+		client = CurrentHTTPClient()
+	}
+	if ro.noRedirects {
+		client = withoutRedirects(client)
 	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, retryTransport(ctx, err), dl.newError(ctx, ErrHTTP, op, err)
+	}
+	defer resp.Body.Close()
 
-	meta := resolveMetadataFromHTTPResponse(resp, rawURL, data, hint)
+	if ro.noRedirects {
+		if err := checkNotRedirected(resp, op); err != nil {
+			return nil, retryHint{}, err
+		}
+	}
+	if resp.StatusCode == http.StatusNotModified && cond != nil {
+		return &urlResponse{resp: resp, notModified: true}, retryHint{}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, retryStatus(resp), httpStatusError(op, resp)
+	}
+	negotiated := resp.Header.Get("Content-Type")
+	if err := ro.checkNegotiated(negotiated, op); err != nil {
+		return nil, retryHint{}, err
+	}
 
-	return &File{
-		source: SourceURL,
-		meta:   meta,
-		data:   data,
-		loaded: true,
-	}, nil
+	wire := &countingReader{r: resp.Body}
+	var body io.Reader = wire
+	var decoded bool
+	if !resp.Uncompressed {
+		zr, ok, err := decodeContentEncoding(wire, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			return nil, retryHint{}, newError(ErrRead, op, err)
+		}
+		if ok {
+			defer zr.Close()
+			body, decoded = zr, true
+		}
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The transport cut the body short because ctx ended.
+			return nil, retryHint{}, dl.newError(ctx, ErrHTTP, op, err)
+		}
+		return nil, retryTransport(ctx, err), dl.newError(ctx, ErrRead, op, err)
+	}
+	return &urlResponse{resp: resp, data: data, wire: wire.n, decoded: decoded, negotiated: negotiated}, retryHint{}, nil
 }
 
 // NewFromBytes creates a File from raw bytes.
-func NewFromBytes(data []byte, hints ...MetadataHint) (*File, error) {
-	var hint MetadataHint
-	if len(hints) > 0 {
-		hint = hints[0]
+func NewFromBytes(data []byte, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromBytes", ro.validate()); err != nil {
+		return nil, err
 	}
 
 	meta := resolveMetadataFromBytes(data, hint)
 
-	return &File{
+	return withProvenance(ro.normalizeName(&File{
 		source: SourceBytes,
 		meta:   meta,
 		data:   data,
 		loaded: true,
-	}, nil
+	})), nil
 }
 
 // NewFromFile creates a File from a local filesystem path. The file content
-// is read eagerly into memory.
-func NewFromFile(filePath string, hints ...MetadataHint) (*File, error) {
-	var hint MetadataHint
-	if len(hints) > 0 {
-		hint = hints[0]
+// is read eagerly into memory; see NewFromFileLazy to defer that. A
+// directory yields a directory-mode File; see IsDir.
+func NewFromFile(filePath string, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromFile", ro.validate()); err != nil {
+		return nil, err
 	}
 
 	info, err := os.Stat(filePath)
@@ -160,6 +348,10 @@ func NewFromFile(filePath string, hints ...MetadataHint) (*File, error) {
 		return nil, newError(ErrRead, "NewFromFile", err)
 	}
 
+	if info.IsDir() {
+		return newDirFile(filePath, info, hint)
+	}
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, newError(ErrRead, "NewFromFile", err)
@@ -167,12 +359,12 @@ func NewFromFile(filePath string, hints ...MetadataHint) (*File, error) {
 
 	meta := resolveMetadataFromFile(filePath, info, data, hint)
 
-	return &File{
+	return withProvenance(ro.normalizeName(&File{
 		source: SourceFile,
 		meta:   meta,
 		data:   data,
 		loaded: true,
-	}, nil
+	})), nil
 }
 
 // NewFromMultipartFile creates a File from a stdlib `*multipart.FileHeader`,
@@ -180,63 +372,54 @@ func NewFromFile(filePath string, hints ...MetadataHint) (*File, error) {
 // `createFromWebFile` for the same scenario; this is the Go-native equivalent
 // for multipart upload routes.
 //
-// Filename and Content-Type from the part headers are preserved as metadata
-// hints unless overridden by `hints`.
-func NewFromMultipartFile(fh *multipart.FileHeader, hints ...MetadataHint) (*File, error) {
+// The filename, the part's Content-Type and fh.Size seed Name, MimeType
+// and Size, and `opts` are layered on top, before magic-byte detection
+// runs as for NewFromBytes. The part is closed once read; failures to open
+// or read it are ErrRead.
+func NewFromMultipartFile(fh *multipart.FileHeader, opts ...ReadOption) (*File, error) {
 	if fh == nil {
 		return nil, newError(ErrInvalidSource, "NewFromMultipartFile", fmt.Errorf("file header is nil"))
 	}
 
 	src, err := fh.Open()
 	if err != nil {
-		return nil, newError(ErrRead, "NewFromMultipartFile", err)
+		return nil, newError(ErrRead, "NewFromMultipartFile", fmt.Errorf("open part %q: %w", fh.Filename, err))
 	}
 	defer src.Close()
 
 	data, err := io.ReadAll(src)
 	if err != nil {
-		return nil, newError(ErrRead, "NewFromMultipartFile", err)
+		return nil, newError(ErrRead, "NewFromMultipartFile", fmt.Errorf("read part %q: %w", fh.Filename, err))
 	}
 
-	// Build hint: start from the multipart headers, layer caller overrides on top.
-	hint := MetadataHint{
+	// The multipart headers come first, so caller hints override them.
+	hint, ro := resolveHints(append([]ReadOption{MetadataHint{
 		Name:     fh.Filename,
 		MimeType: fh.Header.Get("Content-Type"),
-	}
-	if len(hints) > 0 {
-		override := hints[0]
-		if override.Name != "" {
-			hint.Name = override.Name
-		}
-		if override.MimeType != "" {
-			hint.MimeType = override.MimeType
-		}
-		if override.Size != 0 {
-			hint.Size = override.Size
-		}
-		if override.Extension != "" {
-			hint.Extension = override.Extension
-		}
+		Size:     fh.Size,
+	}}, opts...))
+	if err := checkOptions("NewFromMultipartFile", ro.validate()); err != nil {
+		return nil, err
 	}
 
 	meta := resolveMetadataFromBytes(data, hint)
 
-	return &File{
+	return withProvenance(ro.normalizeName(&File{
 		source: SourceStream,
 		meta:   meta,
 		data:   data,
 		loaded: true,
-	}, nil
+	})), nil
 }
 
 // NewFromStream creates a File from an io.Reader. The stream content is read
 // eagerly into memory. Use NewFromStreamLazy when you need to upload a large
 // payload through a memory-constrained process — it keeps the tail of the
 // stream un-buffered.
-func NewFromStream(r io.Reader, hints ...MetadataHint) (*File, error) {
-	var hint MetadataHint
-	if len(hints) > 0 {
-		hint = hints[0]
+func NewFromStream(r io.Reader, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromStream", ro.validate()); err != nil {
+		return nil, err
 	}
 
 	data, err := io.ReadAll(r)
@@ -246,12 +429,12 @@ func NewFromStream(r io.Reader, hints ...MetadataHint) (*File, error) {
 
 	meta := resolveMetadataFromBytes(data, hint)
 
-	return &File{
+	return withProvenance(ro.normalizeName(&File{
 		source: SourceStream,
 		meta:   meta,
 		data:   data,
 		loaded: true,
-	}, nil
+	})), nil
 }
 
 // NewFromStreamLazy creates a File from an io.Reader without buffering the
@@ -264,10 +447,10 @@ func NewFromStream(r io.Reader, hints ...MetadataHint) (*File, error) {
 //
 // Iterating a lazy stream consumes the tail. Subsequent calls to Read() or
 // IterBytes() will only see what's already cached.
-func NewFromStreamLazy(r io.Reader, hints ...MetadataHint) (*File, error) {
-	var hint MetadataHint
-	if len(hints) > 0 {
-		hint = hints[0]
+func NewFromStreamLazy(r io.Reader, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromStreamLazy", ro.validate()); err != nil {
+		return nil, err
 	}
 
 	// Pull the head buffer for magic-byte detection. io.ReadFull returns
@@ -286,12 +469,12 @@ func NewFromStreamLazy(r io.Reader, hints ...MetadataHint) (*File, error) {
 		// We have the complete payload; behave like the eager path so size
 		// etc. is exact.
 		meta := resolveMetadataFromBytes(head, hint)
-		return &File{
+		return withProvenance(ro.normalizeName(&File{
 			source: SourceStream,
 			meta:   meta,
 			data:   head,
 			loaded: true,
-		}, nil
+		})), nil
 	}
 
 	// Lazy path: detection on the head, keep r as the tail.
@@ -300,65 +483,137 @@ func NewFromStreamLazy(r io.Reader, hints ...MetadataHint) (*File, error) {
 	// content-length.
 	if !hint.hasSize() {
 		meta.Size = 0
+		meta.noteOrigin(OriginNone)
 	}
 
-	return &File{
+	return withProvenance(ro.normalizeName(&File{
 		source:     SourceStream,
 		meta:       meta,
 		lazy:       true,
 		streamHead: head,
 		streamTail: r,
 		loaded:     false,
-	}, nil
+	})), nil
 }
 
 // NewFromS3 downloads a file from S3 and returns a File.
-func NewFromS3(bucket, key string, hints ...MetadataHint) (*File, error) {
-	return NewFromS3WithContext(context.Background(), bucket, key, hints...)
+func NewFromS3(bucket, key string, opts ...ReadOption) (*File, error) {
+	return NewFromS3WithContext(context.Background(), bucket, key, opts...)
 }
 
 // NewFromS3WithContext downloads a file from S3 using the given context.
-func NewFromS3WithContext(ctx context.Context, bucket, key string, hints ...MetadataHint) (*File, error) {
-	var hint MetadataHint
-	if len(hints) > 0 {
-		hint = hints[0]
+// DownloadTimeout applies when ctx has no deadline.
+//
+// The object's user metadata is loaded into Metadata.Attributes, as
+// UploadToS3 describes. Pass WithFetchTags to also load its tags into
+// Metadata.Tags (and Attributes), or call LoadS3Tags later, and
+// WithVersionID to read an older version of the object.
+//
+// An object stored with a registered Content-Encoding (gzip, or one added
+// with RegisterCodec) is decoded, and Size is the decoded length; pass
+// WithRawBody to keep the stored bytes.
+func NewFromS3WithContext(ctx context.Context, bucket, key string, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromS3", ro.validate()); err != nil {
+		return nil, err
 	}
+	key, err := checkS3Object("NewFromS3", bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
 
-	s3Client, _ := S3ClientFactory()
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, "NewFromS3", err)
+	}
+	defer release()
+
+	s3Client, _ := s3Clients()
 
 	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		VersionId:    nilIfEmpty(ro.versionID),
+		ChecksumMode: types.ChecksumModeEnabled,
+	}, ro.s3OptFns()...)
 	if err != nil {
-		return nil, newError(ErrS3, "NewFromS3", err)
+		return nil, dl.newError(ctx, ErrS3, "NewFromS3", err)
 	}
 	defer out.Body.Close()
 
 	data, err := io.ReadAll(out.Body)
 	if err != nil {
-		return nil, newError(ErrRead, "NewFromS3", err)
+		return nil, dl.newError(ctx, ErrRead, "NewFromS3", err)
+	}
+	if err := verifyS3SHA256(out, data, "NewFromS3"); err != nil {
+		return nil, err
+	}
+	wire := int64(len(data))
+	var decoded bool
+	if !ro.rawBody {
+		if data, decoded, err = decodeS3Body(out, data); err != nil {
+			return nil, err
+		}
 	}
 
-	meta := resolveMetadataFromS3(bucket, key, out, data, hint)
+	f := fileFromS3Output(bucket, key, out, data, hint)
+	switch enc := strings.ToLower(aws.ToString(out.ContentEncoding)); {
+	case decoded:
+		// ContentLength and any stored checksum describe the encoded body.
+		f.meta.Size = int64(len(data))
+		f.meta.noteOrigin(OriginContent)
+		f.sourceDigests = nil
+		f.transferSize = wire
+	case ro.rawBody && enc != "" && enc != "identity":
+		f.meta.Attributes = mergeAttributes(f.meta.Attributes, map[string]string{AttrContentEncoding: enc})
+	}
+
+	if ro.fetchTags {
+		tags, err := getS3Tags(ctx, s3Client, bucket, key, ro.versionID, ro.s3OptFns()...)
+		if err != nil {
+			return nil, dl.newError(ctx, ErrS3, "NewFromS3", err)
+		}
+		f.meta.Attributes = mergeAttributes(f.meta.Attributes, tags)
+		f.meta.Tags = mergeAttributes(f.meta.Tags, tags)
+	}
+	return withProvenance(ro.normalizeName(f)), nil
+}
 
-	return &File{
-		source:   SourceS3,
-		meta:     meta,
-		data:     data,
-		loaded:   true,
-		s3Bucket: bucket,
-		s3Key:    key,
-	}, nil
+// fileFromS3Output builds the File for a GetObject response whose body has
+// been read into data.
+func fileFromS3Output(bucket, key string, out *s3.GetObjectOutput, data []byte, hint MetadataHint) *File {
+	f := &File{
+		source:       SourceS3,
+		meta:         resolveMetadataFromS3(bucket, key, out, data, hint),
+		data:         data,
+		loaded:       true,
+		s3Bucket:     bucket,
+		s3Key:        key,
+		transferSize: int64(len(data)),
+	}
+	if d := s3FullObjectSHA256(out); d != "" {
+		f.sourceDigests = map[Algorithm]string{AlgorithmSHA256: d}
+	}
+	return f
 }
 
 // --- Accessors ---
 
 // Source returns the FileSource indicating where the file was loaded from.
+// This is always the Source of the last Provenance entry.
 func (f *File) Source() FileSource { return f.source }
 
 // Metadata returns a copy of the file's metadata.
-func (f *File) Metadata() Metadata { return f.meta }
+func (f *File) Metadata() Metadata {
+	m := f.meta.clone()
+	if f.dir {
+		m.Size = f.Size()
+	}
+	return m
+}
 
 // Name returns the filename (may be empty).
 func (f *File) Name() string { return f.meta.Name }
@@ -366,8 +621,14 @@ func (f *File) Name() string { return f.meta.Name }
 // MimeType returns the MIME type (may be empty).
 func (f *File) MimeType() string { return f.meta.MimeType }
 
-// Size returns the file size in bytes.
-func (f *File) Size() int64 { return f.meta.Size }
+// Size returns the file size in bytes. For a directory it is the total
+// size of the regular files beneath it, computed on first use.
+func (f *File) Size() int64 {
+	if f.dir {
+		return f.dirTotal.get(f.meta.Path)
+	}
+	return f.meta.Size
+}
 
 // Extension returns the file extension without a leading dot (may be empty).
 func (f *File) Extension() string { return f.meta.Extension }
@@ -417,6 +678,12 @@ func (f *File) SetMetadata(hint MetadataHint) {
 	if hint.hasCreatedAt() {
 		f.meta.CreatedAt = hint.CreatedAt
 	}
+	if hint.hasAttributes() {
+		f.meta.Attributes = mergeAttributes(f.meta.Attributes, hint.Attributes)
+	}
+	if hint.hasTags() {
+		f.meta.Tags = mergeAttributes(f.meta.Tags, hint.Tags)
+	}
 }
 
 // --- Read Operations ---
@@ -425,34 +692,19 @@ func (f *File) SetMetadata(hint MetadataHint) {
 // first call. For lazy streams this drains the remaining tail into memory and
 // caches it — subsequent calls return the cached buffer. Use IterBytes() to
 // avoid loading the whole payload into RAM.
+//
+// A File that holds no content yet is fetched from its source as by
+// EnsureLoaded with a background context, so DownloadTimeout bounds it and
+// a missing source fails with ErrNotFound.
 func (f *File) Read() ([]byte, error) {
-	if f.loaded && f.data != nil {
-		return f.data, nil
-	}
-	if f.lazy && f.streamHead != nil {
-		// Drain the tail into memory.
-		tail, err := io.ReadAll(f.streamTail)
-		if err != nil {
-			return nil, newError(ErrRead, "Read", err)
-		}
-		combined := make([]byte, 0, len(f.streamHead)+len(tail))
-		combined = append(combined, f.streamHead...)
-		combined = append(combined, tail...)
-		f.data = combined
-		f.loaded = true
-		f.streamHead = nil
-		f.streamTail = nil
-		f.lazy = false
-		f.meta.Size = int64(len(combined))
-		return f.data, nil
-	}
-	return nil, newError(ErrRead, "Read", fmt.Errorf("no data available"))
+	return f.load(context.Background(), "Read")
 }
 
 // IterBytes yields the file contents in chunks without buffering the full
 // payload. For lazy streams, the head is yielded first, then the tail is
-// drained chunk-by-chunk so peak memory stays bounded to a single chunk. For
-// non-lazy files, the cached buffer is yielded once.
+// drained chunk-by-chunk so peak memory stays bounded to a single chunk.
+// Loaded files yield their cached buffer once; ReaderAt files and lazy
+// local files are streamed in chunks, and other sources are fetched first.
 //
 // Iterating a lazy stream consumes the tail — subsequent IterBytes() or Read()
 // calls will see an exhausted stream. The channel is closed when the source
@@ -518,6 +770,39 @@ func (f *File) IterBytes(ctx context.Context) (<-chan []byte, <-chan error) {
 				errc <- ctx.Err()
 				return
 			}
+			return
+		}
+
+		// Everything else (ReaderAt views, lazy local files, remote
+		// sources not fetched yet) streams through openStream.
+		r, err := f.openStream(ctx)
+		if err != nil {
+			errc <- err
+			return
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				chunk := make([]byte, n)
+				copy(chunk, buf[:n])
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errc <- newError(ErrRead, "IterBytes", err)
+				return
+			}
 		}
 	}()
 
@@ -536,23 +821,55 @@ func (f *File) ReadText() (string, error) {
 // --- Write Operations ---
 
 // Save writes the file to the given filesystem path. Returns a new File
-// representing the saved file.
-func (f *File) Save(destPath string) (*File, error) {
-	data, err := f.Read()
+// representing the saved file. Pass WithVerify to check the written bytes
+// against their digest, and WithCollisionStrategy to control what happens
+// when destPath already exists; the returned File's Path and Name reflect
+// the path actually written.
+func (f *File) Save(destPath string, opts ...SaveOption) (*File, error) {
+	return f.save(destPath, newSaveOptions(opts), "Save")
+}
+
+// save implements Save and SaveToDir.
+func (f *File) save(destPath string, o saveOptions, op string) (*File, error) {
+	if err := checkOptions(op, o.validate()); err != nil {
+		return nil, err
+	}
+	destPath, err := o.resolveDest(destPath, op)
+	if err != nil {
+		return nil, err
+	}
+	r, err := f.contentReader(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
 	dir := filepath.Dir(destPath)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return nil, newError(ErrWrite, "Save", err)
+	if err := o.mkdirAll(dir); err != nil {
+		return nil, newError(ErrWrite, op, err)
 	}
 
-	if err := os.WriteFile(destPath, data, 0o644); err != nil {
-		return nil, newError(ErrWrite, "Save", err)
+	destPath, err = claimPath(destPath, o.collision, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFromReader(r, destPath, o, f.sourceDigests[o.algo], op); err != nil {
+		if o.collision != CollisionOverwrite {
+			_ = os.Remove(destPath)
+		}
+		return nil, err
 	}
 
-	return NewFromFile(destPath)
+	newFromFile := NewFromFile
+	if f.onDisk() {
+		newFromFile = NewFromFileLazy
+	}
+	saved, err := newFromFile(destPath)
+	if err != nil {
+		return nil, err
+	}
+	saved.inheritProvenance(f)
+	saved.inheritCreatedAt(f)
+	return saved, nil
 }
 
 // Move writes the file to a new location and deletes the original if it was
@@ -571,8 +888,25 @@ func (f *File) Move(destPath string) (*File, error) {
 	return newFile, nil
 }
 
-// Delete removes the file from the filesystem. Only works for file-sourced files.
+// Delete removes the file from the filesystem, or its object from S3.
+// Only works for file- and S3-sourced files.
 func (f *File) Delete() error {
+	return f.DeleteWithContext(context.Background())
+}
+
+// DeleteWithContext is Delete using the given context. An S3-sourced
+// file's object is removed with DeleteObject; on a versioned bucket that
+// adds a delete marker rather than removing the File's version. S3 reports
+// success for a key that does not exist, but a NoSuchKey error, where a
+// store returns one, fails with ErrNotFound as a missing local file does.
+// MetadataTimeout applies to S3 when ctx has no deadline.
+func (f *File) DeleteWithContext(ctx context.Context) error {
+	if err := f.checkWritable("Delete"); err != nil {
+		return err
+	}
+	if f.source == SourceS3 {
+		return f.deleteS3(ctx)
+	}
 	if f.source != SourceFile || f.meta.Path == "" {
 		return newError(ErrInvalidSource, "Delete", fmt.Errorf("cannot delete non-file source %s", f.source))
 	}
@@ -585,16 +919,46 @@ func (f *File) Delete() error {
 	return nil
 }
 
+// deleteS3 removes the object behind an S3-sourced file.
+func (f *File) deleteS3(ctx context.Context) error {
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return newError(ErrInvalidSource, "Delete", fmt.Errorf("file is not S3-sourced"))
+	}
+	if err := checkNetwork(ctx, "Delete"); err != nil {
+		return err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	s3Client, _ := s3Clients()
+	_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return newError(ErrNotFound, "Delete", err)
+		}
+		return dl.newError(ctx, ErrS3, "Delete", err)
+	}
+	return nil
+}
+
 // --- Checksum ---
 
 // Checksum calculates and returns the SHA-256 hex digest of the file contents.
 func (f *File) Checksum() (string, error) {
-	data, err := f.Read()
+	r, err := f.contentReader(context.Background())
 	if err != nil {
 		return "", err
 	}
-	h := sha256.Sum256(data)
-	return hex.EncodeToString(h[:]), nil
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", newError(ErrRead, "Checksum", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // --- Base64 ---
@@ -664,15 +1028,18 @@ func (f *File) ToFormData(attrName string) (*FormData, error) {
 // --- Validation ---
 
 // ValidateOptions configures the rules applied by File.Validate. Any subset of
-// fields may be set; zero-valued fields are skipped.
+// fields may be set; zero-valued fields take Defaults.MaxSize and
+// Defaults.AllowedMimes and are otherwise skipped.
 type ValidateOptions struct {
-	// MaxSize is the maximum allowed size in bytes. A value <= 0 disables the
-	// size check. On failure, Validate returns a *FileValidationError with
-	// Kind == KindSize.
+	// MaxSize is the maximum allowed size in bytes. Zero uses
+	// Defaults.MaxSize; a negative value, or zero with no default,
+	// disables the size check. On failure, Validate returns a
+	// *FileValidationError with Kind == KindSize.
 	MaxSize int64
 
-	// AllowedMimes is an allowlist of acceptable mime types. An empty or nil
-	// slice disables the check. On failure, Validate returns a
+	// AllowedMimes is an allowlist of acceptable mime types. Nil uses
+	// Defaults.AllowedMimes; an empty slice, or nil with no default,
+	// disables the check. On failure, Validate returns a
 	// *FileValidationError with Kind == KindMime.
 	AllowedMimes []string
 
@@ -702,6 +1069,7 @@ type ValidateOptions struct {
 //	    return err
 //	}
 func (f *File) Validate(opts ValidateOptions) error {
+	loadDefaults().applyValidate(&opts)
 	if opts.MaxSize > 0 {
 		size := f.meta.Size
 		if size <= 0 {
@@ -798,6 +1166,10 @@ func CreatePresignedUploadURL(ctx context.Context, bucket, key string, opts *Pre
 	if key == "" {
 		return "", newError(ErrInvalidSource, "CreatePresignedUploadURL", fmt.Errorf("key is required"))
 	}
+	key, err := checkS3Object("CreatePresignedUploadURL", bucket, key)
+	if err != nil {
+		return "", err
+	}
 
 	var o PresignedUploadOptions
 	if opts != nil {
@@ -807,7 +1179,10 @@ func CreatePresignedUploadURL(ctx context.Context, bucket, key string, opts *Pre
 		o.ExpiresIn = 1 * time.Hour
 	}
 
-	_, presignClient := S3ClientFactory()
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	_, presignClient := s3Clients()
 
 	input := &s3.PutObjectInput{
 		Bucket:             aws.String(bucket),
@@ -823,7 +1198,7 @@ func CreatePresignedUploadURL(ctx context.Context, bucket, key string, opts *Pre
 		po.Expires = o.ExpiresIn
 	})
 	if err != nil {
-		return "", newError(ErrS3, "CreatePresignedUploadURL", err)
+		return "", dl.newError(ctx, ErrS3, "CreatePresignedUploadURL", err)
 	}
 	return req.URL, nil
 }
@@ -831,86 +1206,168 @@ func CreatePresignedUploadURL(ctx context.Context, bucket, key string, opts *Pre
 // --- S3 Operations ---
 
 // UploadToS3 uploads the file to the given S3 bucket and key.
-func (f *File) UploadToS3(bucket, key string) error {
-	return f.UploadToS3WithContext(context.Background(), bucket, key)
+func (f *File) UploadToS3(bucket, key string, opts ...UploadOption) error {
+	return f.UploadToS3WithContext(context.Background(), bucket, key, opts...)
 }
 
 // UploadToS3WithContext uploads the file to S3 using the given context.
 //
-// For lazy streams, the head + tail are spooled through a temp file so the
-// upload can stream from disk rather than buffering the full payload in RAM.
-// PutObject requires a seekable body for retries; a temp-file spool keeps
-// peak memory bounded to one chunk + the buffer Go uses for io.Copy.
-func (f *File) UploadToS3WithContext(ctx context.Context, bucket, key string) error {
-	s3Client, _ := S3ClientFactory()
+// Content is never buffered whole when it can be streamed: a lazy local
+// file (NewFromFileLazy) is sent from its *os.File and a NewFromReaderAt
+// File through a section view. A lazy stream (NewFromStreamLazy) whose
+// size was hinted is sent as it is read; because the stream cannot be
+// rewound, the SDK cannot retry that request. A lazy stream of unknown
+// size is buffered in memory up to UploadBufferThreshold bytes and beyond
+// that spooled through a temp file, so PutObject gets a seekable body of
+// known length while peak memory stays bounded. The spool lives in the
+// process's managed temp directory, so ReapOrphanedTemp removes it if the
+// process dies mid-upload.
+//
+// Content larger than DefaultMultipartThreshold (see WithMultipartThreshold)
+// is sent as a multipart upload with the same headers and metadata, several
+// parts at a time (see WithPartSize and WithPartConcurrency). A failed
+// multipart upload is aborted.
+//
+// Attributes are stored as user metadata, except keys under
+// ReservedAttrPrefix; NewFromS3 reads them back. Keys are lowercased, as S3
+// stores them, and values that are not printable ASCII are RFC 2047
+// encoded. Metadata over MaxS3UserMetadataSize fails with
+// ErrMetadataTooLarge before anything is sent.
+//
+// Tags become the object's tag set, URL-encoded into the Tagging header
+// (merged with WithRetention's expiry tag); a set over S3's limits fails
+// with ErrInvalidArgument. LoadS3Tags reads them back.
+//
+// When ctx has no deadline, UploadTimeout bounds each request rather than
+// the whole upload: the PutObject, or each part of a multipart upload.
+func (f *File) UploadToS3WithContext(ctx context.Context, bucket, key string, opts ...UploadOption) error {
+	_, err := f.UploadToS3WithResult(ctx, bucket, key, opts...)
+	return err
+}
 
-	input := &s3.PutObjectInput{
-		Bucket:      aws.String(bucket),
-		Key:         aws.String(key),
-		ContentType: nilIfEmpty(f.meta.MimeType),
+// UploadToS3WithResult is UploadToS3WithContext, reporting what was sent.
+// Bytes is the number of bytes sent (compressed, with
+// WithTransparentGzip). With WithSkipUnchanged, Digest is the SHA-256 of
+// the content and Skipped reports whether the upload was suppressed.
+func (f *File) UploadToS3WithResult(ctx context.Context, bucket, key string, opts ...UploadOption) (TransferResult, error) {
+	start := time.Now()
+	o := newUploadOptions(opts)
+	if err := checkOptions("UploadToS3", o.validate()); err != nil {
+		return TransferResult{}, err
+	}
+	key, err := checkS3Object("UploadToS3", bucket, key)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	input, err := s3PutInput("UploadToS3", bucket, key, f.meta)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	if err := o.applyToPutInput("UploadToS3", input); err != nil {
+		return TransferResult{}, err
 	}
-	if f.meta.Name != "" {
-		input.ContentDisposition = aws.String(fmt.Sprintf(`attachment; filename="%s"`, f.meta.Name))
+	if err := checkNetwork(ctx, "UploadToS3"); err != nil {
+		return TransferResult{}, err
 	}
 
-	// Lazy streaming path: spool head + tail through a temp file so PutObject
-	// can stream from a seekable source without RAM-buffering the payload.
-	if f.lazy && f.streamHead != nil {
-		spool, err := os.CreateTemp("", "smooai-file-upload-*")
+	ctx, cancel, dl, watch := withStreamTimeout(ctx, UploadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassUpload)
+	if err != nil {
+		return TransferResult{}, dl.newError(ctx, ErrS3, "UploadToS3", err)
+	}
+	defer release()
+	// Hashing and spooling the content run at the caller's pace; the
+	// watchdog restarts with each request.
+	watch.pause()
+
+	s3Client, _ := s3Clients()
+
+	var res TransferResult
+	if o.skipUnchanged {
+		digest, unchanged, err := f.matchS3Object(ctx, dl, watch, s3Client, bucket, key)
 		if err != nil {
-			return newError(ErrWrite, "UploadToS3", err)
+			return TransferResult{}, err
 		}
-		spoolPath := spool.Name()
-		defer func() {
-			_ = spool.Close()
-			_ = os.Remove(spoolPath)
-		}()
-
-		if _, err := spool.Write(f.streamHead); err != nil {
-			return newError(ErrWrite, "UploadToS3", err)
+		res.Algorithm, res.Digest = AlgorithmSHA256, digest
+		if unchanged {
+			f.transferSize = 0
+			res.Skipped = true
+			return f.uploadResult(start, res), nil
 		}
-		written, err := io.Copy(spool, f.streamTail)
-		if err != nil {
-			return newError(ErrRead, "UploadToS3", err)
+		if input.Metadata == nil {
+			input.Metadata = map[string]string{}
 		}
-		f.streamHead = nil
-		f.streamTail = nil
-		f.lazy = false
-		total := int64(len(f.streamHead)) + written
-		_ = total // size recorded below
-		size, err := spool.Seek(0, io.SeekEnd)
-		if err != nil {
-			return newError(ErrRead, "UploadToS3", err)
-		}
-		if _, err := spool.Seek(0, io.SeekStart); err != nil {
-			return newError(ErrRead, "UploadToS3", err)
+		input.Metadata[S3MetaSHA256] = digest
+		if err := checkS3UserMetadataSize("UploadToS3", input.Metadata); err != nil {
+			return TransferResult{}, err
 		}
-		f.meta.Size = size
-
-		input.Body = spool
-		input.ContentLength = aws.Int64(size)
+	}
 
-		if _, err := s3Client.PutObject(ctx, input); err != nil {
-			return newError(ErrS3, "UploadToS3", err)
+	if o.transparentGzip {
+		if err := f.putGzipped(ctx, dl, watch, s3Client, input); err != nil {
+			return TransferResult{}, err
 		}
-		return nil
+		return f.uploadResult(start, res), nil
 	}
 
-	// Eager path: bytes already in memory.
-	data, err := f.Read()
+	body, size, done, err := f.uploadBody(ctx, "UploadToS3")
 	if err != nil {
-		return err
+		return TransferResult{}, err
+	}
+
+	if size > o.multipartThreshold {
+		m := &multipartUpload{
+			client:      s3Client,
+			input:       input,
+			op:          "UploadToS3",
+			dl:          dl,
+			watch:       watch,
+			partSize:    o.partSize,
+			concurrency: o.partConcurrency,
+		}
+		_, err = m.run(ctx, body, size)
+	} else {
+		watch.kick()
+		input.Body = body
+		input.ContentLength = aws.Int64(size)
+		if _, err = s3Client.PutObject(ctx, input); err != nil {
+			err = dl.newError(ctx, ErrS3, "UploadToS3", err)
+		}
 	}
-
-	input.Body = bytes.NewReader(data)
-	if f.meta.Size > 0 {
-		input.ContentLength = aws.Int64(f.meta.Size)
+	if err := done(err); err != nil {
+		return TransferResult{}, err
 	}
+	f.transferSize = size
+	return f.uploadResult(start, res), nil
+}
 
-	if _, err := s3Client.PutObject(ctx, input); err != nil {
-		return newError(ErrS3, "UploadToS3", err)
+// uploadBody returns the content as an upload body, its length and a done
+// func to call with the upload's error once it is over, which releases the
+// body and returns the error to report. Content that can be read in place
+// is not buffered: a lazy stream goes through lazyUploadBody,
+// and everything else through a Handle, which is seekable for retries and
+// an io.ReaderAt for parallel multipart parts. Content not loaded yet is
+// fetched under ctx.
+func (f *File) uploadBody(ctx context.Context, op string) (io.Reader, int64, func(error) error, error) {
+	if f.lazy && f.streamHead != nil {
+		body, size, done, err := f.lazyUploadBody(op)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		f.meta.Size = size
+		return body, size, done, nil
 	}
-	return nil
+	h, err := f.openHandle(ctx, op)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	done := func(err error) error {
+		_ = h.Close()
+		return err
+	}
+	return h, h.Size(), done, nil
 }
 
 // DownloadFromS3 downloads a file from S3 and replaces this File's content
@@ -921,6 +1378,12 @@ func (f *File) DownloadFromS3(bucket, key string) error {
 
 // DownloadFromS3WithContext downloads from S3 using the given context.
 func (f *File) DownloadFromS3WithContext(ctx context.Context, bucket, key string) error {
+	if err := f.checkWritable("DownloadFromS3"); err != nil {
+		return err
+	}
+	if err := checkNetwork(ctx, "DownloadFromS3"); err != nil {
+		return err
+	}
 	newFile, err := NewFromS3WithContext(ctx, bucket, key)
 	if err != nil {
 		return err
@@ -932,33 +1395,50 @@ func (f *File) DownloadFromS3WithContext(ctx context.Context, bucket, key string
 // GetSignedURL generates a presigned GET URL for the file's S3 object.
 // expiresIn specifies how long the URL remains valid.
 // The file must have been loaded from S3 (or have s3Bucket/s3Key set).
-func (f *File) GetSignedURL(expiresIn time.Duration) (string, error) {
-	return f.GetSignedURLWithContext(context.Background(), expiresIn)
+// WithDownloadFilename and WithResponseContentType override the headers
+// the object is served with.
+func (f *File) GetSignedURL(expiresIn time.Duration, opts ...SignOption) (string, error) {
+	return f.GetSignedURLWithContext(context.Background(), expiresIn, opts...)
 }
 
 // GetSignedURLWithContext generates a presigned URL using the given context.
-func (f *File) GetSignedURLWithContext(ctx context.Context, expiresIn time.Duration) (string, error) {
-	bucket, key := f.s3Bucket, f.s3Key
-
-	// If not set directly, try to parse from the s3:// URL.
-	if bucket == "" || key == "" {
-		var ok bool
-		bucket, key, ok = parseS3URI(f.meta.URL)
-		if !ok {
-			return "", newError(ErrInvalidSource, "GetSignedURL", fmt.Errorf("file is not S3-sourced"))
+// Storage-sourced files are signed by their backend when it implements
+// SignedURLStorage; such URLs cannot override response headers, so opts
+// that do fail with ErrInvalidSource.
+func (f *File) GetSignedURLWithContext(ctx context.Context, expiresIn time.Duration, opts ...SignOption) (string, error) {
+	o := newSignOptions(opts)
+	if err := checkOptions("GetSignedURL", o.validate()); err != nil {
+		return "", err
+	}
+	if err := checkNetwork(ctx, "GetSignedURL"); err != nil {
+		return "", err
+	}
+	if f.source == SourceStorage {
+		if o.overrides() {
+			return "", newError(ErrInvalidSource, "GetSignedURL", fmt.Errorf("storage backend URLs cannot override response headers"))
 		}
+		return f.storageSignedURL(ctx, expiresIn)
 	}
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return "", newError(ErrInvalidSource, "GetSignedURL", fmt.Errorf("file is not S3-sourced"))
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
 
-	_, presignClient := S3ClientFactory()
+	_, presignClient := s3Clients()
 
-	req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
-	}, func(o *s3.PresignOptions) {
-		o.Expires = expiresIn
+	}
+	o.applyToGetInput(input)
+	req, err := presignClient.PresignGetObject(ctx, input, func(po *s3.PresignOptions) {
+		po.Expires = expiresIn
 	})
 	if err != nil {
-		return "", newError(ErrS3, "GetSignedURL", err)
+		return "", dl.newError(ctx, ErrS3, "GetSignedURL", err)
 	}
 	return req.URL, nil
 }
@@ -968,6 +1448,9 @@ func (f *File) GetSignedURLWithContext(ctx context.Context, expiresIn time.Durat
 // Append adds content to the end of the file. Only works for file-sourced files
 // (writes directly to the filesystem path).
 func (f *File) Append(content []byte) error {
+	if err := f.checkWritable("Append"); err != nil {
+		return err
+	}
 	if f.source != SourceFile || f.meta.Path == "" {
 		return newError(ErrInvalidSource, "Append", fmt.Errorf("cannot append to non-file source %s", f.source))
 	}
@@ -987,6 +1470,9 @@ func (f *File) Append(content []byte) error {
 
 // Prepend inserts content at the beginning of the file. Only works for file-sourced files.
 func (f *File) Prepend(content []byte) error {
+	if err := f.checkWritable("Prepend"); err != nil {
+		return err
+	}
 	if f.source != SourceFile || f.meta.Path == "" {
 		return newError(ErrInvalidSource, "Prepend", fmt.Errorf("cannot prepend to non-file source %s", f.source))
 	}
@@ -1009,6 +1495,9 @@ func (f *File) Prepend(content []byte) error {
 
 // Truncate truncates the file to the given size in bytes. Only works for file-sourced files.
 func (f *File) Truncate(size int64) error {
+	if err := f.checkWritable("Truncate"); err != nil {
+		return err
+	}
 	if f.source != SourceFile || f.meta.Path == "" {
 		return newError(ErrInvalidSource, "Truncate", fmt.Errorf("cannot truncate non-file source %s", f.source))
 	}
@@ -1022,14 +1511,66 @@ func (f *File) Truncate(size int64) error {
 
 // --- String ---
 
-// String returns a human-readable representation of the file.
+// String returns a human-readable representation of the file. Under
+// RedactNames the name is replaced by a hash.
 func (f *File) String() string {
+	name := f.meta.Name
+	if RedactNames {
+		name = redactedName(name)
+	}
 	return fmt.Sprintf("File{source=%s, name=%q, mime=%q, size=%d, ext=%q}",
-		f.source, f.meta.Name, f.meta.MimeType, f.meta.Size, f.meta.Extension)
+		f.source, name, f.meta.MimeType, f.meta.Size, f.meta.Extension)
 }
 
 // --- Internal helpers ---
 
+// contentReader returns a reader over the full content. ReaderAt-backed
+// files get a fresh section view and lazy local files are read from disk,
+// so the content is not buffered; everything else is loaded under ctx.
+func (f *File) contentReader(ctx context.Context) (io.Reader, error) {
+	if f.readerAt != nil && !f.loaded {
+		return io.NewSectionReader(f.readerAt, 0, f.readerAtSize), nil
+	}
+	if f.onDisk() {
+		fh, err := f.openOnDisk("Read")
+		if err != nil {
+			return nil, err
+		}
+		return &closeAtEOF{fh: fh}, nil
+	}
+	data, err := f.load(ctx, "Read")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// openStream returns a reader over the full content that avoids
+// materializing it where the source allows: ReaderAt files get a section
+// view and lazy streams hand over their head and tail, consuming the stream
+// as UploadToS3 does. The reader is an io.Closer when closing it releases
+// something (a lazy stream's tail or a lazy file's handle). Buffered content is served from memory, and content
+// not loaded yet is fetched under ctx.
+func (f *File) openStream(ctx context.Context) (io.Reader, error) {
+	if f.lazy && f.streamHead != nil {
+		r := io.MultiReader(bytes.NewReader(f.streamHead), f.streamTail)
+		closer, _ := f.streamTail.(io.Closer)
+		f.streamHead, f.streamTail, f.lazy = nil, nil, false
+		return &streamBody{Reader: r, closer: closer}, nil
+	}
+	return f.contentReader(ctx)
+}
+
+// prefixReader returns a reader over the start of the content for helpers
+// that only inspect a prefix. Unlike contentReader it never drains a lazy
+// stream: for those it covers the buffered head only.
+func (f *File) prefixReader() (io.Reader, error) {
+	if f.lazy && f.streamHead != nil {
+		return bytes.NewReader(f.streamHead), nil
+	}
+	return f.contentReader(context.Background())
+}
+
 // refresh re-reads the file from disk after a modification.
 func (f *File) refresh() error {
 	if f.source != SourceFile || f.meta.Path == "" {
@@ -1039,6 +1580,7 @@ func (f *File) refresh() error {
 	if err != nil {
 		return err
 	}
+	newFile.inheritCreatedAt(f)
 	*f = *newFile
 	return nil
 }
@@ -1051,17 +1593,25 @@ func resolveMetadataFromHTTPResponse(resp *http.Response, rawURL string, data []
 
 	// Start with hints as baseline.
 	applyHint(&m, hint)
+	m.noteOrigin(OriginHint)
 
 	// Parse response headers (may override hints).
 	if resp != nil {
 		cd := resp.Header.Get("Content-Disposition")
-		if cdName := ParseContentDisposition(cd); cdName != "" {
+		cdName, sanitized := contentDispositionName(cd)
+		noteContentDisposition(&m, cd, sanitized)
+		if cdName != "" {
 			m.Name = cdName
+			m.noteOrigin(OriginContentDisposition)
+		} else if urlName := filenameFromURL(finalURL(resp, rawURL)); urlName != "" && m.Name == "" {
+			m.Name = urlName
+			m.noteOrigin(OriginURL)
 		} else if urlName := filenameFromURL(rawURL); urlName != "" && m.Name == "" {
 			m.Name = urlName
+			m.noteOrigin(OriginURL)
 		}
 
-		if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if ct := declaredMimeType(resp.Header.Get("Content-Type")); ct != "" {
 			m.MimeType = ct
 		}
 		if cl := resp.Header.Get("Content-Length"); cl != "" {
@@ -1079,39 +1629,49 @@ func resolveMetadataFromHTTPResponse(resp *http.Response, rawURL string, data []
 				m.LastModified = t
 			}
 		}
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = createdAtFromHeader(resp.Header)
+		}
+		m.noteOrigin(OriginHeader)
 	}
 
 	// Override size from hint if hint provided it and response did not.
 	if m.Size == 0 && hint.hasSize() {
 		m.Size = hint.Size
+		m.noteOrigin(OriginHint)
 	}
 
-	// Set URL.
-	if rawURL != "" {
-		m.URL = rawURL
+	// Set URL: where the content was finally served from.
+	if u := finalURL(resp, rawURL); u != "" {
+		m.URL = u
 	}
 
 	// Detect from name if MIME not set.
 	if m.MimeType == "" && m.Name != "" {
 		m.MimeType = MimeTypeFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
 	}
 
 	// Magic-byte detection from data.
-	if detected := DetectMimeTypeFromBytes(data); detected != "" {
-		m.MimeType = detected
-	}
-	if detected := DetectExtensionFromBytes(data); detected != "" {
-		m.Extension = detected
-	}
+	applyMagicDetection(&m, data)
+	m.noteOrigin(OriginMagicBytes)
 
 	// Fallback: derive extension from MIME type.
 	if m.Extension == "" && m.MimeType != "" {
 		m.Extension = ExtensionFromMimeType(m.MimeType)
+		m.noteOrigin(OriginFallback)
 	}
 
 	// Fallback: derive extension from name.
 	if m.Extension == "" && m.Name != "" {
-		m.Extension = ExtensionFromFilename(m.Name)
+		m.Extension = StrictExtensionFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
+	}
+
+	// Last resort: record a definite, if generic, type.
+	if m.MimeType == "" {
+		m.MimeType = octetStream
+		m.noteOrigin(OriginFallback)
 	}
 
 	return m
@@ -1121,32 +1681,33 @@ func resolveMetadataFromHTTPResponse(resp *http.Response, rawURL string, data []
 func resolveMetadataFromBytes(data []byte, hint MetadataHint) Metadata {
 	m := Metadata{}
 	applyHint(&m, hint)
+	m.noteOrigin(OriginHint)
 
 	if m.Size == 0 {
 		m.Size = int64(len(data))
+		m.noteOrigin(OriginContent)
 	}
 
 	// Detect from name.
 	if m.MimeType == "" && m.Name != "" {
 		m.MimeType = MimeTypeFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
 	}
 
 	// Magic-byte detection.
-	if detected := DetectMimeTypeFromBytes(data); detected != "" {
-		m.MimeType = detected
-	}
-	if detected := DetectExtensionFromBytes(data); detected != "" {
-		m.Extension = detected
-	}
+	applyMagicDetection(&m, data)
+	m.noteOrigin(OriginMagicBytes)
 
 	// Fallback extension from MIME type.
 	if m.Extension == "" && m.MimeType != "" {
 		m.Extension = ExtensionFromMimeType(m.MimeType)
+		m.noteOrigin(OriginFallback)
 	}
 
 	// Fallback extension from name.
 	if m.Extension == "" && m.Name != "" {
-		m.Extension = ExtensionFromFilename(m.Name)
+		m.Extension = StrictExtensionFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
 	}
 
 	return m
@@ -1156,43 +1717,60 @@ func resolveMetadataFromBytes(data []byte, hint MetadataHint) Metadata {
 func resolveMetadataFromFile(filePath string, info os.FileInfo, data []byte, hint MetadataHint) Metadata {
 	m := Metadata{}
 	applyHint(&m, hint)
+	m.noteOrigin(OriginHint)
 
 	// Set path and name from the filesystem.
 	m.Path = filePath
 	if m.Name == "" {
 		m.Name = filepath.Base(filePath)
+		m.noteOrigin(OriginPath)
 	}
 
 	// Stat info.
 	m.Size = info.Size()
 	m.LastModified = info.ModTime()
-
-	// Magic-byte detection from file path.
-	if detected := DetectMimeTypeFromFilePath(filePath); detected != "" {
-		m.MimeType = detected
-	}
-	if detected := DetectExtensionFromFilePath(filePath); detected != "" {
-		m.Extension = detected
-	}
-
-	// Fallback: magic-byte from data.
-	if m.MimeType == "" {
-		if detected := DetectMimeTypeFromBytes(data); detected != "" {
+	m.noteOrigin(OriginStat)
+
+	if belowDetectionMinSize(m.Size) {
+		// Too small for magic bytes to be trusted on their own: let the
+		// filename declare the type and only refine it from the content.
+		if m.MimeType == "" && m.Name != "" {
+			m.MimeType = MimeTypeFromFilename(m.Name)
+			m.noteOrigin(OriginFilename)
+		}
+		applyMagicDetection(&m, data)
+	} else {
+		// Magic-byte detection from file path.
+		if detected := DetectMimeTypeFromFilePath(filePath); detected != "" {
 			m.MimeType = detected
 		}
+		if detected := DetectExtensionFromFilePath(filePath); detected != "" {
+			m.Extension = detected
+		}
+
+		// Fallback: magic-byte from data.
+		if m.MimeType == "" {
+			if detected := DetectMimeTypeFromBytes(data); detected != "" {
+				m.MimeType = detected
+			}
+		}
 	}
+	m.noteOrigin(OriginMagicBytes)
 
 	// Fallback: from name.
 	if m.MimeType == "" && m.Name != "" {
 		m.MimeType = MimeTypeFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
 	}
 
 	// Fallback extension.
 	if m.Extension == "" && m.MimeType != "" {
 		m.Extension = ExtensionFromMimeType(m.MimeType)
+		m.noteOrigin(OriginFallback)
 	}
 	if m.Extension == "" && m.Name != "" {
-		m.Extension = ExtensionFromFilename(m.Name)
+		m.Extension = StrictExtensionFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
 	}
 
 	return m
@@ -1202,22 +1780,27 @@ func resolveMetadataFromFile(filePath string, info os.FileInfo, data []byte, hin
 func resolveMetadataFromS3(bucket, key string, out *s3.GetObjectOutput, data []byte, hint MetadataHint) Metadata {
 	m := Metadata{}
 	applyHint(&m, hint)
+	m.noteOrigin(OriginHint)
 
 	// S3 URI.
 	m.URL = fmt.Sprintf("s3://%s/%s", bucket, key)
 	if m.Name == "" {
 		m.Name = path.Base(key)
+		m.noteOrigin(OriginURL)
 	}
 
 	// S3 response metadata.
 	if out != nil {
 		if out.ContentDisposition != nil {
-			if cdName := ParseContentDisposition(*out.ContentDisposition); cdName != "" {
+			cdName, sanitized := contentDispositionName(*out.ContentDisposition)
+			noteContentDisposition(&m, *out.ContentDisposition, sanitized)
+			if cdName != "" {
 				m.Name = cdName
+				m.noteOrigin(OriginContentDisposition)
 			}
 		}
-		if out.ContentType != nil && *out.ContentType != "" {
-			m.MimeType = *out.ContentType
+		if ct := declaredMimeType(aws.ToString(out.ContentType)); ct != "" {
+			m.MimeType = ct
 		}
 		if out.ContentLength != nil {
 			m.Size = *out.ContentLength
@@ -1227,34 +1810,51 @@ func resolveMetadataFromS3(bucket, key string, out *s3.GetObjectOutput, data []b
 		}
 		if out.LastModified != nil {
 			m.LastModified = *out.LastModified
+			// S3 keeps no creation time. LastModified is when the object
+			// was last PUT, which for write-once objects is its creation.
+			if m.CreatedAt.IsZero() {
+				m.CreatedAt = *out.LastModified
+			}
+		}
+		if out.VersionId != nil {
+			m.VersionID = *out.VersionId
 		}
+		// Hinted attributes win over the stored user metadata.
+		m.Attributes = mergeAttributes(decodeS3UserMetadata(out.Metadata), m.Attributes)
+		m.noteOrigin(OriginS3)
 	}
 
 	if m.Size == 0 {
 		m.Size = int64(len(data))
+		m.noteOrigin(OriginContent)
 	}
 
 	// Detect from name.
 	if m.MimeType == "" && m.Name != "" {
 		m.MimeType = MimeTypeFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
 	}
 
 	// Magic-byte detection.
-	if detected := DetectMimeTypeFromBytes(data); detected != "" {
-		m.MimeType = detected
-	}
-	if detected := DetectExtensionFromBytes(data); detected != "" {
-		m.Extension = detected
-	}
+	applyMagicDetection(&m, data)
+	m.noteOrigin(OriginMagicBytes)
 
 	// Fallback extension from MIME type.
 	if m.Extension == "" && m.MimeType != "" {
 		m.Extension = ExtensionFromMimeType(m.MimeType)
+		m.noteOrigin(OriginFallback)
 	}
 
 	// Fallback extension from name.
 	if m.Extension == "" && m.Name != "" {
-		m.Extension = ExtensionFromFilename(m.Name)
+		m.Extension = StrictExtensionFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
+	}
+
+	// Last resort: record a definite, if generic, type.
+	if m.MimeType == "" {
+		m.MimeType = octetStream
+		m.noteOrigin(OriginFallback)
 	}
 
 	return m
@@ -1289,10 +1889,18 @@ func applyHint(m *Metadata, hint MetadataHint) {
 	if hint.hasCreatedAt() {
 		m.CreatedAt = hint.CreatedAt
 	}
+	if hint.hasAttributes() {
+		m.Attributes = mergeAttributes(m.Attributes, hint.Attributes)
+	}
+	if hint.hasTags() {
+		m.Tags = mergeAttributes(m.Tags, hint.Tags)
+	}
 }
 
 // filenameFromURL extracts the filename from a URL path, returning empty if
-// it cannot be determined.
+// it cannot be determined. The path is percent-decoded, so the result is
+// reduced as in untrustedBaseName: "%5C" or "%00" must not smuggle a
+// backslash or NUL into Metadata.Name.
 func filenameFromURL(rawURL string) string {
 	if rawURL == "" {
 		return ""
@@ -1301,14 +1909,20 @@ func filenameFromURL(rawURL string) string {
 	if err != nil {
 		return ""
 	}
-	base := path.Base(u.Path)
-	if base == "" || base == "/" || base == "." {
-		return ""
+	return untrustedBaseName(path.Base(u.Path))
+}
+
+// s3Location returns the S3 bucket and key backing the file, falling back to
+// parsing an s3:// URL from the metadata.
+func (f *File) s3Location() (bucket, key string, ok bool) {
+	if f.s3Bucket != "" && f.s3Key != "" {
+		return f.s3Bucket, f.s3Key, true
 	}
-	return base
+	return parseS3URI(f.meta.URL)
 }
 
-// parseS3URI extracts bucket and key from an s3://bucket/key URI.
+// parseS3URI extracts bucket and key from an s3://bucket/key URI. ok is
+// false unless both are non-empty.
 func parseS3URI(uri string) (bucket, key string, ok bool) {
 	if !strings.HasPrefix(uri, "s3://") {
 		return "", "", false
@@ -1318,7 +1932,8 @@ func parseS3URI(uri string) (bucket, key string, ok bool) {
 	if idx < 0 {
 		return rest, "", false
 	}
-	return rest[:idx], rest[idx+1:], true
+	bucket, key = rest[:idx], rest[idx+1:]
+	return bucket, key, bucket != "" && key != ""
 }
 
 // nilIfEmpty returns a pointer to s if non-empty, or nil.
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -15,9 +16,13 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // --- Mock S3 client ---
@@ -26,6 +31,21 @@ type mockS3Client struct {
 	getObjectFn    func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	putObjectFn    func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	deleteObjectFn func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	headObjectFn   func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+
+	createMultipartUploadFn   func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	uploadPartFn              func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	uploadPartCopyFn          func(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	completeMultipartUploadFn func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	abortMultipartUploadFn    func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	listPartsFn               func(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
+	listObjectsV2Fn           func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	listObjectVersionsFn      func(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	deleteObjectsFn           func(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	copyObjectFn              func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+
+	getObjectTaggingFn func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	putObjectTaggingFn func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
 }
 
 func (m *mockS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
@@ -49,6 +69,97 @@ func (m *mockS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObject
 	return nil, fmt.Errorf("mock: DeleteObject not implemented")
 }
 
+func (m *mockS3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if m.headObjectFn != nil {
+		return m.headObjectFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: HeadObject not implemented")
+}
+
+func (m *mockS3Client) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if m.createMultipartUploadFn != nil {
+		return m.createMultipartUploadFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: CreateMultipartUpload not implemented")
+}
+
+func (m *mockS3Client) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if m.uploadPartFn != nil {
+		return m.uploadPartFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: UploadPart not implemented")
+}
+
+func (m *mockS3Client) UploadPartCopy(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	if m.uploadPartCopyFn != nil {
+		return m.uploadPartCopyFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: UploadPartCopy not implemented")
+}
+
+func (m *mockS3Client) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	if m.completeMultipartUploadFn != nil {
+		return m.completeMultipartUploadFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: CompleteMultipartUpload not implemented")
+}
+
+func (m *mockS3Client) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if m.abortMultipartUploadFn != nil {
+		return m.abortMultipartUploadFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: AbortMultipartUpload not implemented")
+}
+
+func (m *mockS3Client) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	if m.listPartsFn != nil {
+		return m.listPartsFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: ListParts not implemented")
+}
+
+func (m *mockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if m.listObjectsV2Fn != nil {
+		return m.listObjectsV2Fn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: ListObjectsV2 not implemented")
+}
+
+func (m *mockS3Client) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	if m.listObjectVersionsFn != nil {
+		return m.listObjectVersionsFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: ListObjectVersions not implemented")
+}
+
+func (m *mockS3Client) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if m.deleteObjectsFn != nil {
+		return m.deleteObjectsFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: DeleteObjects not implemented")
+}
+
+func (m *mockS3Client) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	if m.copyObjectFn != nil {
+		return m.copyObjectFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: CopyObject not implemented")
+}
+
+func (m *mockS3Client) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if m.getObjectTaggingFn != nil {
+		return m.getObjectTaggingFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: GetObjectTagging not implemented")
+}
+
+func (m *mockS3Client) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	if m.putObjectTaggingFn != nil {
+		return m.putObjectTaggingFn(ctx, params, optFns...)
+	}
+	return nil, fmt.Errorf("mock: PutObjectTagging not implemented")
+}
+
 // --- Mock presign client ---
 
 type mockPresignClient struct {
@@ -70,20 +181,20 @@ func (m *mockPresignClient) PresignPutObject(ctx context.Context, params *s3.Put
 	return nil, fmt.Errorf("mock: PresignPutObject not implemented")
 }
 
-// setMockS3 replaces the S3ClientFactory with a mock and returns a cleanup function.
+// setMockS3 replaces the S3 client factory with a mock and returns a cleanup function.
 func setMockS3(s3c *mockS3Client, presign *mockPresignClient) func() {
-	orig := S3ClientFactory
-	S3ClientFactory = func() (S3API, S3PresignAPI) {
+	orig := CurrentS3ClientFactory()
+	SetS3ClientFactory(func() (S3API, S3PresignAPI) {
 		return s3c, presign
-	}
-	return func() { S3ClientFactory = orig }
+	})
+	return func() { SetS3ClientFactory(orig) }
 }
 
-// setMockHTTP replaces the HTTPClient with the given httptest server's client and returns cleanup.
+// setMockHTTP replaces the HTTP client with the given httptest server's client and returns cleanup.
 func setMockHTTP(client *http.Client) func() {
-	orig := HTTPClient
-	HTTPClient = client
-	return func() { HTTPClient = orig }
+	orig := CurrentHTTPClient()
+	SetHTTPClient(client)
+	return func() { SetHTTPClient(orig) }
 }
 
 // --- TestNewFromURL ---
@@ -92,12 +203,14 @@ func TestNewFromURL(t *testing.T) {
 	tests := []struct {
 		name       string
 		handler    http.HandlerFunc
-		hints      []MetadataHint
+		hints      []ReadOption
 		wantName   string
 		wantMime   string
 		wantSize   int64
 		wantSource FileSource
 		wantErr    bool
+		// wantPreview, when set, is the expected FileError.BodyPreview.
+		wantPreview string
 	}{
 		{
 			name: "basic text file from URL",
@@ -119,7 +232,7 @@ func TestNewFromURL(t *testing.T) {
 				// Note: Go httptest auto-sets Content-Length from body.
 				fmt.Fprint(w, "data")
 			},
-			hints:      []MetadataHint{{Name: "custom.bin"}},
+			hints:      []ReadOption{MetadataHint{Name: "custom.bin"}},
 			wantName:   "custom.bin",
 			wantMime:   "text/plain; charset=utf-8", // magic-byte detects text
 			wantSize:   4,                           // Content-Length from response takes precedence
@@ -132,6 +245,16 @@ func TestNewFromURL(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "server returns 400 with JSON error body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(400)
+				fmt.Fprint(w, `{"error":"invalid_token"}`)
+			},
+			wantErr:     true,
+			wantPreview: `{"error":"invalid_token"}`,
+		},
 		{
 			name: "response with ETag and Last-Modified",
 			handler: func(w http.ResponseWriter, r *http.Request) {
@@ -171,6 +294,16 @@ func TestNewFromURL(t *testing.T) {
 				if err == nil {
 					t.Fatal("expected error, got nil")
 				}
+				var fErr *FileError
+				if !errors.As(err, &fErr) {
+					t.Fatalf("expected *FileError, got %T", err)
+				}
+				if string(fErr.BodyPreview) != tt.wantPreview {
+					t.Errorf("BodyPreview = %q, want %q", fErr.BodyPreview, tt.wantPreview)
+				}
+				if tt.wantPreview != "" && !strings.Contains(err.Error(), tt.wantPreview) {
+					t.Errorf("Error() = %q, want it to include the body preview", err)
+				}
 				return
 			}
 			if err != nil {
@@ -203,13 +336,74 @@ func TestNewFromURL_InvalidURL(t *testing.T) {
 	}
 }
 
+// slowBodyServer writes the first chunk of a body, flushes, then stalls
+// until the client goes away. Its channel receives once the handler sees
+// the request aborted.
+func slowBodyServer(t *testing.T) (*httptest.Server, <-chan struct{}) {
+	t.Helper()
+	aborted := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		w.Write(bytes.Repeat([]byte("x"), 1000))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, aborted
+}
+
+func TestNewFromURLWithContext_CancelMidBody(t *testing.T) {
+	srv, aborted := slowBodyServer(t)
+	defer setMockHTTP(srv.Client())()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := NewFromURLWithContext(ctx, srv.URL)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !errors.Is(err, ErrHTTP) || !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want ErrHTTP wrapping context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Error("server never saw the request aborted")
+	}
+}
+
+func TestNewFromURLWithContext_DeadlineMidBody(t *testing.T) {
+	srv, aborted := slowBodyServer(t)
+	defer setMockHTTP(srv.Client())()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := NewFromURLWithContext(ctx, srv.URL)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want ErrTimeout wrapping context.DeadlineExceeded", err)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Error("server never saw the request aborted")
+	}
+}
+
 // --- TestNewFromBytes ---
 
 func TestNewFromBytes(t *testing.T) {
 	tests := []struct {
 		name     string
 		data     []byte
-		hints    []MetadataHint
+		hints    []ReadOption
 		wantSize int64
 		wantName string
 	}{
@@ -226,7 +420,7 @@ func TestNewFromBytes(t *testing.T) {
 		{
 			name:     "bytes with name hint",
 			data:     []byte("content"),
-			hints:    []MetadataHint{{Name: "readme.txt"}},
+			hints:    []ReadOption{MetadataHint{Name: "readme.txt"}},
 			wantSize: 7,
 			wantName: "readme.txt",
 		},
@@ -264,7 +458,7 @@ func TestNewFromFile(t *testing.T) {
 	tests := []struct {
 		name     string
 		setup    func() string // returns file path
-		hints    []MetadataHint
+		hints    []ReadOption
 		wantName string
 		wantSize int64
 		wantErr  bool
@@ -293,7 +487,7 @@ func TestNewFromFile(t *testing.T) {
 				os.WriteFile(p, []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0x00, 0x00, 0x0D, 0x49, 0x48, 0x44, 0x52}, 0o644)
 				return p
 			},
-			hints:    []MetadataHint{{Name: "photo.png"}},
+			hints:    []ReadOption{MetadataHint{Name: "photo.png"}},
 			wantName: "photo.png",
 			wantSize: 16,
 		},
@@ -597,6 +791,48 @@ func TestDelete_NonFileSource(t *testing.T) {
 	}
 }
 
+func TestDelete_S3Source(t *testing.T) {
+	var deleted []string
+	defer setMockS3(&mockS3Client{
+		deleteObjectFn: func(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			deleted = append(deleted, aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key))
+			return &s3.DeleteObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "docs/old.pdf"}
+	if err := f.Delete(); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if err := f.DeleteWithContext(context.Background()); err != nil {
+		t.Fatalf("DeleteWithContext() error: %v", err)
+	}
+	if len(deleted) != 2 || deleted[0] != "bucket/docs/old.pdf" || deleted[1] != "bucket/docs/old.pdf" {
+		t.Errorf("DeleteObject calls = %v", deleted)
+	}
+}
+
+func TestDelete_S3Errors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err  error
+		want error
+	}{
+		"no such key":   {&types.NoSuchKey{}, ErrNotFound},
+		"access denied": {errors.New("access denied"), ErrS3},
+	} {
+		cleanup := setMockS3(&mockS3Client{
+			deleteObjectFn: func(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return nil, tc.err
+			},
+		}, &mockPresignClient{})
+		err := (&File{source: SourceS3, s3Bucket: "bucket", s3Key: "k"}).Delete()
+		cleanup()
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
 // --- TestChecksum ---
 
 func TestChecksum(t *testing.T) {
@@ -890,6 +1126,21 @@ func TestSetMetadata(t *testing.T) {
 	}
 }
 
+func TestSetMetadata_MergesAttributes(t *testing.T) {
+	var input *s3.PutObjectInput
+	defer setMockS3(capturePut(&input), &mockPresignClient{})()
+
+	f, _ := NewFromBytes([]byte("data"), MetadataHint{Attributes: map[string]string{"Uploaded-By": "ci"}})
+	f.SetMetadata(MetadataHint{Attributes: map[string]string{"tenant-id": "t-42"}})
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"uploaded-by": "ci", "tenant-id": "t-42"}
+	if !maps.Equal(input.Metadata, want) {
+		t.Errorf("user metadata = %v, want %v", input.Metadata, want)
+	}
+}
+
 // --- TestString ---
 
 func TestString(t *testing.T) {
@@ -921,6 +1172,7 @@ func TestFileSource_Valid(t *testing.T) {
 		{SourceFile, true},
 		{SourceStream, true},
 		{SourceS3, true},
+		{SourceFS, true},
 		{FileSource("Unknown"), false},
 	}
 
@@ -964,22 +1216,57 @@ func TestFileError_String(t *testing.T) {
 	}
 }
 
-// --- Test helpers ---
+func TestNewFromURL_BodyPreviewIsCapped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, strings.Repeat("<p>upstream down</p>", 1000))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
 
-func TestFilenameFromURL(t *testing.T) {
-	tests := []struct {
-		rawURL string
-		want   string
-	}{
-		{"https://example.com/files/report.pdf", "report.pdf"},
-		{"https://example.com/", ""},
-		{"https://example.com", ""},
-		{"", ""},
-		{"not a valid url ://", ""},
-		{"https://example.com/path/to/image.png?v=123", "image.png"},
+	_, err := NewFromURL(srv.URL)
+	var fErr *FileError
+	if !errors.As(err, &fErr) {
+		t.Fatalf("expected *FileError, got %v", err)
+	}
+	if len(fErr.BodyPreview) != bodyPreviewLimit {
+		t.Errorf("len(BodyPreview) = %d, want %d", len(fErr.BodyPreview), bodyPreviewLimit)
+	}
+	if fErr.BodyContentType != "text/html" {
+		t.Errorf("BodyContentType = %q", fErr.BodyContentType)
 	}
+}
 
-	for _, tt := range tests {
+func TestFileError_BinaryBodyPreviewIsHexEscaped(t *testing.T) {
+	err := newError(ErrHTTP, "NewFromURL", fmt.Errorf("status 500"))
+	err.BodyPreview = []byte{0x1f, 0x8b, 'o', 'k', 0x00}
+	want := `body: "\x1f\x8bok\x00"`
+	if s := err.Error(); !strings.HasSuffix(s, want) {
+		t.Errorf("Error() = %q, want suffix %q", s, want)
+	}
+}
+
+// --- Test helpers ---
+
+// filenameFromURLTests also seeds FuzzFilenameFromURL.
+var filenameFromURLTests = []struct {
+	rawURL string
+	want   string
+}{
+	{"https://example.com/files/report.pdf", "report.pdf"},
+	{"https://example.com/", ""},
+	{"https://example.com", ""},
+	{"", ""},
+	{"not a valid url ://", ""},
+	{"https://example.com/path/to/image.png?v=123", "image.png"},
+	{"https://example.com/a%5C..%5Cevil.exe", "evil.exe"},
+	{"https://example.com/a%00b.txt", "ab.txt"},
+	{"https://example.com/a/..", ""},
+}
+
+func TestFilenameFromURL(t *testing.T) {
+	for _, tt := range filenameFromURLTests {
 		t.Run(tt.rawURL, func(t *testing.T) {
 			got := filenameFromURL(tt.rawURL)
 			if got != tt.want {
@@ -989,21 +1276,24 @@ func TestFilenameFromURL(t *testing.T) {
 	}
 }
 
-func TestParseS3URI(t *testing.T) {
-	tests := []struct {
-		uri        string
-		wantBucket string
-		wantKey    string
-		wantOk     bool
-	}{
-		{"s3://mybucket/path/to/file.txt", "mybucket", "path/to/file.txt", true},
-		{"s3://bucket/key", "bucket", "key", true},
-		{"s3://bucket", "bucket", "", false},
-		{"https://not-s3.com/file", "", "", false},
-		{"", "", "", false},
-	}
+// parseS3URITests also seeds FuzzParseS3URI.
+var parseS3URITests = []struct {
+	uri        string
+	wantBucket string
+	wantKey    string
+	wantOk     bool
+}{
+	{"s3://mybucket/path/to/file.txt", "mybucket", "path/to/file.txt", true},
+	{"s3://bucket/key", "bucket", "key", true},
+	{"s3://bucket", "bucket", "", false},
+	{"https://not-s3.com/file", "", "", false},
+	{"", "", "", false},
+	{"s3://bucket/", "bucket", "", false},
+	{"s3:///key", "", "key", false},
+}
 
-	for _, tt := range tests {
+func TestParseS3URI(t *testing.T) {
+	for _, tt := range parseS3URITests {
 		t.Run(tt.uri, func(t *testing.T) {
 			bucket, key, ok := parseS3URI(tt.uri)
 			if bucket != tt.wantBucket || key != tt.wantKey || ok != tt.wantOk {
@@ -1014,6 +1304,39 @@ func TestParseS3URI(t *testing.T) {
 	}
 }
 
+func FuzzFilenameFromURL(f *testing.F) {
+	for _, tt := range filenameFromURLTests {
+		f.Add(tt.rawURL)
+	}
+	f.Fuzz(func(t *testing.T, rawURL string) {
+		name := filenameFromURL(rawURL)
+		if strings.ContainsAny(name, `/\`) || strings.ContainsFunc(name, unicode.IsControl) {
+			t.Errorf("filenameFromURL(%q) = %q, contains a separator or control character", rawURL, name)
+		}
+		if name == "." || name == ".." || !utf8.ValidString(name) {
+			t.Errorf("filenameFromURL(%q) = %q", rawURL, name)
+		}
+	})
+}
+
+func FuzzParseS3URI(f *testing.F) {
+	for _, tt := range parseS3URITests {
+		f.Add(tt.uri)
+	}
+	f.Fuzz(func(t *testing.T, uri string) {
+		bucket, key, ok := parseS3URI(uri)
+		if !ok {
+			return
+		}
+		if bucket == "" || key == "" || strings.Contains(bucket, "/") {
+			t.Errorf("parseS3URI(%q) = (%q, %q, true)", uri, bucket, key)
+		}
+		if got := "s3://" + bucket + "/" + key; got != uri {
+			t.Errorf("parseS3URI(%q) does not round-trip: %q", uri, got)
+		}
+	})
+}
+
 // --- Test MetadataHint helpers ---
 
 func TestMetadataHint_Has(t *testing.T) {
@@ -1062,3 +1385,83 @@ func TestMetadataHint_Has(t *testing.T) {
 		t.Error("fully populated hint should report all has* as true")
 	}
 }
+
+// --- Octet-stream normalization ---
+
+func TestNewFromURL_OctetStreamTreatedAsAbsent(t *testing.T) {
+	noise := bytes.Repeat([]byte{0x00, 0xFE, 0x13, 0x80}, 64)
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        []byte
+		hints       []ReadOption
+		wantMime    string
+	}{
+		{name: "octet-stream defers to filename", path: "/report.csv", contentType: "application/octet-stream", body: []byte("a,b\n1,2\n"), wantMime: "text/csv"},
+		{name: "binary/octet-stream defers to detection", path: "/download", contentType: "binary/octet-stream", body: pngBytes, wantMime: "image/png"},
+		{name: "octet-stream does not override hint", path: "/blob", contentType: "application/octet-stream", body: noise, hints: []ReadOption{MetadataHint{MimeType: "application/x-custom"}}, wantMime: "application/x-custom"},
+		{name: "empty header and undetectable bytes", path: "/blob", body: noise, wantMime: "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Setting the key to nil stops net/http sniffing a type.
+				w.Header()["Content-Type"] = nil
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+			cleanup := setMockHTTP(srv.Client())
+			defer cleanup()
+
+			f, err := NewFromURL(srv.URL+tt.path, tt.hints...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.MimeType() != tt.wantMime {
+				t.Errorf("MimeType = %q, want %q", f.MimeType(), tt.wantMime)
+			}
+		})
+	}
+}
+
+func TestNewFromS3_OctetStreamTreatedAsAbsent(t *testing.T) {
+	noise := bytes.Repeat([]byte{0x00, 0xFE, 0x13, 0x80}, 64)
+	tests := []struct {
+		name        string
+		key         string
+		contentType string
+		body        []byte
+		wantMime    string
+	}{
+		{name: "binary/octet-stream defers to key name", key: "data/config.json", contentType: "binary/octet-stream", body: []byte(`{"a":1}`), wantMime: "application/json"},
+		{name: "octet-stream defers to detection", key: "data/upload", contentType: "application/octet-stream", body: pngBytes, wantMime: "image/png"},
+		{name: "missing content type and undetectable bytes", key: "data/blob", body: noise, wantMime: "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanup := setMockS3(&mockS3Client{
+				getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					out := &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(tt.body))}
+					if tt.contentType != "" {
+						ct := tt.contentType
+						out.ContentType = &ct
+					}
+					return out, nil
+				},
+			}, &mockPresignClient{})
+			defer cleanup()
+
+			f, err := NewFromS3("bucket", tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.MimeType() != tt.wantMime {
+				t.Errorf("MimeType = %q, want %q", f.MimeType(), tt.wantMime)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FileFS is a read-only fs.FS over a set of Files, for APIs that take an
+// fs.FS such as html/template.ParseFS and http.FileServer (through
+// http.FS). It also implements fs.StatFS and fs.ReadFileFS. Each File is
+// a regular file at its path; the directories above the paths exist
+// implicitly. fs.FileInfo reports a File's Size and LastModified, and its
+// Sys method returns the *File.
+//
+// Opening a File reads its content as AsReadSeekCloser does, buffering
+// content that cannot be seeked in place the first time. A FileFS is safe
+// for concurrent use as long as its Files are not modified.
+type FileFS struct {
+	files map[string]*File
+	dirs  map[string][]string // directory path to sorted child names
+
+	mu sync.Mutex // serializes loading content
+}
+
+// Interface checks.
+var (
+	_ fs.StatFS     = (*FileFS)(nil)
+	_ fs.ReadFileFS = (*FileFS)(nil)
+)
+
+// NewFileFS returns a FileFS serving each of files at its Name. Names must
+// be valid fs.FS paths (see fs.ValidPath) and unique, or NewFileFS fails
+// with ErrInvalidArgument.
+func NewFileFS(files ...*File) (*FileFS, error) {
+	byPath := make(map[string]*File, len(files))
+	for _, f := range files {
+		if f == nil {
+			return nil, newError(ErrInvalidArgument, "NewFileFS", errors.New("file is nil"))
+		}
+		if _, dup := byPath[f.meta.Name]; dup {
+			return nil, newError(ErrInvalidArgument, "NewFileFS", fmt.Errorf("two files are named %q", f.meta.Name))
+		}
+		byPath[f.meta.Name] = f
+	}
+	return newFileFS(byPath, "NewFileFS")
+}
+
+// NewFileFSFromMap returns a FileFS serving each File at its key, such as
+// "css/site.css", rather than its Name. Keys must be valid fs.FS paths, or
+// NewFileFSFromMap fails with ErrInvalidArgument.
+func NewFileFSFromMap(files map[string]*File) (*FileFS, error) {
+	return newFileFS(maps.Clone(files), "NewFileFSFromMap")
+}
+
+// newFileFS builds the directory tree over files.
+func newFileFS(files map[string]*File, op string) (*FileFS, error) {
+	fsys := &FileFS{files: files, dirs: map[string][]string{".": nil}}
+	for p, f := range files {
+		if p == "." || !fs.ValidPath(p) {
+			return nil, newError(ErrInvalidArgument, op, fmt.Errorf("%q is not a valid fs.FS path for a file", p))
+		}
+		if f == nil {
+			return nil, newError(ErrInvalidArgument, op, fmt.Errorf("file for %q is nil", p))
+		}
+		for child := p; child != "."; child = path.Dir(child) {
+			dir := path.Dir(child)
+			if !slices.Contains(fsys.dirs[dir], path.Base(child)) {
+				fsys.dirs[dir] = append(fsys.dirs[dir], path.Base(child))
+			}
+		}
+	}
+	for dir := range fsys.dirs {
+		if _, clash := files[dir]; clash {
+			return nil, newError(ErrInvalidArgument, op, fmt.Errorf("%q is both a file and a directory", dir))
+		}
+		slices.Sort(fsys.dirs[dir])
+	}
+	return fsys, nil
+}
+
+// Open opens the named file or directory, as fs.FS describes.
+func (fsys *FileFS) Open(name string) (fs.File, error) {
+	info, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &fileFSDir{fsys: fsys, info: info, path: name}, nil
+	}
+	fsys.mu.Lock()
+	body, _, err := info.file.readSeekCloser("Open")
+	fsys.mu.Unlock()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &fileFSFile{info: info, body: body}, nil
+}
+
+// Stat returns the fs.FileInfo for name, as fs.StatFS describes.
+func (fsys *FileFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fsys.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ReadFile returns a copy of the named file's content, as fs.ReadFileFS
+// describes.
+func (fsys *FileFS) ReadFile(name string) ([]byte, error) {
+	info, err := fsys.stat("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	fsys.mu.Lock()
+	body, _, err := info.file.readSeekCloser("ReadFile")
+	fsys.mu.Unlock()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	defer body.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, body); err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return buf.Bytes(), nil
+}
+
+// stat looks up name for op.
+func (fsys *FileFS) stat(op, name string) (*fileFSInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := fsys.files[name]; ok {
+		return &fileFSInfo{name: path.Base(name), file: f}, nil
+	}
+	if _, ok := fsys.dirs[name]; ok {
+		return &fileFSInfo{name: path.Base(name)}, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// fileFSInfo is the fs.FileInfo and fs.DirEntry of a FileFS entry: a File,
+// or an implicit directory when file is nil.
+type fileFSInfo struct {
+	name string
+	file *File
+}
+
+func (i *fileFSInfo) Name() string { return i.name }
+func (i *fileFSInfo) IsDir() bool  { return i.file == nil }
+
+func (i *fileFSInfo) Size() int64 {
+	if i.file == nil {
+		return 0
+	}
+	return i.file.Size()
+}
+
+func (i *fileFSInfo) Mode() fs.FileMode {
+	if i.file == nil {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i *fileFSInfo) ModTime() time.Time {
+	if i.file == nil {
+		return time.Time{}
+	}
+	return i.file.meta.LastModified
+}
+
+// Sys returns the *File, or nil for a directory.
+func (i *fileFSInfo) Sys() any {
+	if i.file == nil {
+		return nil
+	}
+	return i.file
+}
+
+func (i *fileFSInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *fileFSInfo) Info() (fs.FileInfo, error) { return i, nil }
+func (i *fileFSInfo) String() string             { return fs.FormatFileInfo(i) }
+
+// fileFSFile is an open FileFS file. It is seekable, as http.FS requires.
+type fileFSFile struct {
+	info *fileFSInfo
+	body io.ReadSeekCloser
+}
+
+func (f *fileFSFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *fileFSFile) Read(p []byte) (int, error) { return f.body.Read(p) }
+func (f *fileFSFile) Close() error               { return f.body.Close() }
+
+func (f *fileFSFile) Seek(offset int64, whence int) (int64, error) {
+	return f.body.Seek(offset, whence)
+}
+
+// fileFSDir is an open FileFS directory.
+type fileFSDir struct {
+	fsys   *FileFS
+	info   *fileFSInfo
+	path   string
+	offset int
+}
+
+func (d *fileFSDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *fileFSDir) Close() error               { return nil }
+
+func (d *fileFSDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the directory's entries in name order, as
+// fs.ReadDirFile describes.
+func (d *fileFSDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	names := d.fsys.dirs[d.path][d.offset:]
+	if n > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(names) {
+		names = names[:n]
+	}
+	entries := make([]fs.DirEntry, len(names))
+	for i, name := range names {
+		child := name
+		if d.path != "." {
+			child = d.path + "/" + name
+		}
+		info, _ := d.fsys.stat("readdir", child)
+		entries[i] = info
+	}
+	d.offset += len(names)
+	return entries, nil
+}
@@ -0,0 +1,96 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFileFS_HTTPFileServer(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	page, _ := NewFromBytes([]byte("<h1>hello</h1>"), MetadataHint{Name: "index.html", LastModified: modified})
+	style, _ := NewFromBytes([]byte("h1{color:red}"), MetadataHint{Name: "site.css"})
+	fsys, err := NewFileFSFromMap(map[string]*File{"index.html": page, "css/site.css": style})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/css/site.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "h1{color:red}" {
+		t.Fatalf("GET /css/site.css = %d %q", resp.StatusCode, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/", nil)
+	req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("GET / with If-Modified-Since = %d, want 304", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/missing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /missing.txt = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestFileFS_Conformance(t *testing.T) {
+	a, _ := NewFromBytes([]byte("alpha"), MetadataHint{Name: "a.txt"})
+	b, _ := NewFromBytes(pngBytes, MetadataHint{Name: "img/b.png", LastModified: time.Unix(1700000000, 0)})
+	fsys, err := NewFileFS(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "a.txt", "img/b.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(fsys, "img/b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(pngBytes)) || !info.ModTime().Equal(time.Unix(1700000000, 0)) || info.Sys() != b {
+		t.Errorf("Stat = size %d, modtime %v, sys %v", info.Size(), info.ModTime(), info.Sys())
+	}
+	if _, err := fsys.Open("nope.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(missing) err = %v, want fs.ErrNotExist", err)
+	}
+	data, _ := fsys.ReadFile("a.txt")
+	data[0] = 'X'
+	if got, _ := a.Read(); string(got) != "alpha" {
+		t.Error("ReadFile returned the File's own buffer")
+	}
+}
+
+func TestFileFS_RejectsBadPaths(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"), MetadataHint{Name: "a.txt"})
+	g, _ := NewFromBytes([]byte("y"), MetadataHint{Name: "a.txt"})
+	for name, build := range map[string]func() (*FileFS, error){
+		"duplicate name": func() (*FileFS, error) { return NewFileFS(f, g) },
+		"invalid path":   func() (*FileFS, error) { return NewFileFSFromMap(map[string]*File{"../a.txt": f}) },
+		"file and dir":   func() (*FileFS, error) { return NewFileFSFromMap(map[string]*File{"a": f, "a/b": g}) },
+	} {
+		if _, err := build(); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
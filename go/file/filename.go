@@ -0,0 +1,95 @@
+package file
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilenameBytes is the longest file name, in bytes, that common
+// filesystems (ext4, APFS, NTFS in UTF-8 terms) accept.
+const maxFilenameBytes = 255
+
+// maxExtensionBytes bounds what StrictExtensionFromFilename accepts as an
+// extension; anything longer is part of the name ("v1.0 final report").
+const maxExtensionBytes = 16
+
+// SanitizeFilename turns name, which may come from a remote source, into a
+// single safe file name for a local write. Directory components are
+// removed, control characters dropped, and characters that are reserved on
+// common filesystems (/ \ : * ? " < > |) replaced with "_". Trailing dots
+// and spaces, which Windows strips, are trimmed. Unicode is kept: a name
+// longer than 255 bytes is cut on a character boundary, never inside a
+// UTF-8 sequence, and keeps its extension. Returns "" when nothing usable
+// is left.
+func SanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), r == utf8.RuneError:
+			return -1
+		case strings.ContainsRune(`:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" || name == "." || name == ".." {
+		return ""
+	}
+	return fitFilename(name, "")
+}
+
+// untrustedBaseName reduces a name from an untrusted source to a single
+// path element: control characters (including NUL) are dropped, invalid
+// UTF-8 becomes U+FFFD, everything up to the last "/" or "\" is removed,
+// and "." and ".." become empty.
+func untrustedBaseName(raw string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, raw)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// fitFilename returns name with insert placed between its stem and
+// extension, cutting the stem on a rune boundary so the result is at most
+// maxFilenameBytes long.
+func fitFilename(name, insert string) string {
+	stem, ext := splitExt(name)
+	room := maxFilenameBytes - len(insert) - len(ext)
+	if room < 1 {
+		// An extension this long cannot be kept; treat it as name.
+		stem, ext = name, ""
+		room = maxFilenameBytes - len(insert)
+	}
+	if len(stem) > room {
+		cut := room
+		for cut > 0 && !utf8.RuneStart(stem[cut]) {
+			cut--
+		}
+		stem = stem[:cut]
+	}
+	return stem + insert + ext
+}
+
+// splitExt splits name into its stem and its extension (with the dot), as
+// recognized by StrictExtensionFromFilename. A dotfile such as ".env" is all
+// stem.
+func splitExt(name string) (stem, ext string) {
+	e := StrictExtensionFromFilename(name)
+	if e == "" || len(e)+1 == len(name) {
+		return name, ""
+	}
+	return name[:len(name)-len(e)-1], name[len(name)-len(e)-1:]
+}
@@ -0,0 +1,168 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// longUnicodeName is a 300-character Japanese title with emoji: far over
+// 255 bytes in UTF-8, with no ASCII dot before the extension.
+var longUnicodeName = strings.Repeat("四半期報告書🎉", 50) + ".pdf"
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\x\a.txt`, "a.txt"},
+		{"a\x00b\x1f.txt", "ab.txt"},
+		{`what?<>:"|*.txt`, "what_______.txt"},
+		{"trailing. . ", "trailing"},
+		{"写真 🎉.jpeg", "写真 🎉.jpeg"},
+		{"..", ""},
+		{"", ""},
+		{"bad\xffutf8.txt", "badutf8.txt"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.in); got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFilename_TruncatesByteWise(t *testing.T) {
+	got := SanitizeFilename(longUnicodeName)
+	if len(got) > maxFilenameBytes {
+		t.Fatalf("len = %d bytes, want at most %d", len(got), maxFilenameBytes)
+	}
+	if !utf8.ValidString(got) {
+		t.Error("truncation split a UTF-8 sequence")
+	}
+	if !strings.HasSuffix(got, ".pdf") || !strings.HasPrefix(got, "四半期報告書🎉") {
+		t.Errorf("got %q, want the name's start and its .pdf extension", got)
+	}
+	// Every cut point in a multi-byte stem yields valid UTF-8.
+	for n := 1; n <= 12; n++ {
+		name := strings.Repeat("x", maxFilenameBytes-n) + "🎉🎉.txt"
+		if got := SanitizeFilename(name); !utf8.ValidString(got) || len(got) > maxFilenameBytes || !strings.HasSuffix(got, ".txt") {
+			t.Errorf("padding %d: got %q (%d bytes)", n, got, len(got))
+		}
+	}
+}
+
+func TestStrictExtensionFromFilename(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"四半期報告書.pdf", "pdf"},
+		{"写真", ""},
+		{"報告書．pdf", ""}, // full-width dot
+		{"v1.0 最終版", ""},
+		{"notes.md/", ""},
+		{"dir.d/file", ""},
+		{"archive.tar.gz", "gz"},
+		{"trailing.", ""},
+	}
+	for _, tt := range tests {
+		if got := StrictExtensionFromFilename(tt.name); got != tt.want {
+			t.Errorf("StrictExtensionFromFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildContentDisposition(t *testing.T) {
+	tests := []struct {
+		disposition, name, want string
+	}{
+		{"", "report.pdf", `attachment; filename="report.pdf"`},
+		{"inline", "a b.txt", `inline; filename="a b.txt"`},
+		{"attachment", "", "attachment"},
+		{"attachment", "写真 1.jpg", `attachment; filename="_ 1.jpg"; filename*=UTF-8''%E5%86%99%E7%9C%9F%201.jpg`},
+		{"attachment", `say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+	}
+	for _, tt := range tests {
+		got := BuildContentDisposition(tt.disposition, tt.name)
+		if got != tt.want {
+			t.Errorf("BuildContentDisposition(%q, %q) = %q, want %q", tt.disposition, tt.name, got, tt.want)
+		}
+		if tt.name != "" && ParseContentDisposition(got) != tt.name {
+			t.Errorf("round trip of %q = %q", tt.name, ParseContentDisposition(got))
+		}
+	}
+
+	cd := BuildContentDisposition("attachment", longUnicodeName)
+	for i := 0; i < len(cd); i++ {
+		if cd[i] >= 0x80 {
+			t.Fatalf("header is not ASCII at byte %d", i)
+		}
+	}
+	if got := ParseContentDisposition(cd); got != longUnicodeName {
+		t.Errorf("round trip lost the name: %q", got)
+	}
+}
+
+func TestLongUnicodeName_URLToSaveToS3(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", BuildContentDisposition("attachment", longUnicodeName))
+		fmt.Fprint(w, "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<<>>\nendobj\n")
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	var put *s3.PutObjectInput
+	defer setMockS3(capturePut(&put), &mockPresignClient{})()
+
+	f, err := NewFromURL(srv.URL + "/download?id=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != longUnicodeName {
+		t.Fatalf("Name = %q, want the full unicode name", f.Name())
+	}
+	if f.Extension() != "pdf" {
+		t.Errorf("Extension = %q, want pdf", f.Extension())
+	}
+
+	dir := t.TempDir()
+	saved, err := f.SaveToDir(dir)
+	if err != nil {
+		t.Fatalf("SaveToDir: %v", err)
+	}
+	base := filepath.Base(saved.Path())
+	if len(base) > maxFilenameBytes || !utf8.ValidString(base) || !strings.HasSuffix(base, ".pdf") {
+		t.Errorf("saved as %q (%d bytes)", base, len(base))
+	}
+	if _, err := os.Stat(filepath.Join(dir, base)); err != nil {
+		t.Error(err)
+	}
+	// A second save with renaming still fits the limit.
+	again, err := f.SaveToDir(dir, WithCollisionStrategy(CollisionRenameWithSuffix))
+	if err != nil {
+		t.Fatalf("SaveToDir again: %v", err)
+	}
+	if b := filepath.Base(again.Path()); len(b) > maxFilenameBytes || !strings.Contains(b, " (1).pdf") {
+		t.Errorf("renamed to %q (%d bytes)", b, len(b))
+	}
+
+	if err := f.UploadToS3WithContext(context.Background(), "bucket", "docs/report.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	cd := aws.ToString(put.ContentDisposition)
+	if strings.ContainsFunc(cd, func(r rune) bool { return r >= 0x80 }) {
+		t.Errorf("ContentDisposition is not ASCII: %q", cd)
+	}
+	if got := ParseContentDisposition(cd); got != longUnicodeName {
+		t.Errorf("ContentDisposition names %q, want the full unicode name", got)
+	}
+}
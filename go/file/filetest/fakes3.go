@@ -0,0 +1,618 @@
+package filetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// FakeObject is an object stored in a FakeS3.
+type FakeObject struct {
+	Bucket             string
+	Key                string
+	Body               []byte
+	ContentType        string
+	ContentDisposition string
+	ContentEncoding    string
+	CacheControl       string
+	// Metadata is the user metadata, keys lowercased as S3 returns them.
+	Metadata map[string]string
+	Tags     map[string]string
+	// ETag is the hex MD5 of Body (or a multipart ETag), without quotes.
+	ETag         string
+	LastModified time.Time
+}
+
+// FakeS3 is an in-memory implementation of file.S3API and
+// file.S3PresignAPI for tests. It covers what the file package uses:
+// object reads (including "bytes=a-b" ranges), writes, conditional
+// requests, copies, tags, multipart uploads, and listing by prefix. Presigned
+// URLs are well-formed but point nowhere. Safe for concurrent use.
+type FakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]*FakeObject
+	uploads map[string]*fakeUpload
+	nextID  int
+	now     func() time.Time
+}
+
+// fakeUpload is a multipart upload in progress.
+type fakeUpload struct {
+	input *s3.CreateMultipartUploadInput
+	parts map[int32][]byte
+}
+
+var (
+	_ file.S3API        = (*FakeS3)(nil)
+	_ file.S3PresignAPI = (*FakeS3)(nil)
+)
+
+// NewFakeS3 returns an empty FakeS3.
+func NewFakeS3() *FakeS3 {
+	return &FakeS3{
+		objects: map[string]*FakeObject{},
+		uploads: map[string]*fakeUpload{},
+		now:     time.Now,
+	}
+}
+
+// Install makes every S3 operation of the file package use s until the
+// test ends, when the previous client factory is restored.
+func (s *FakeS3) Install(t testing.TB) {
+	t.Helper()
+	prev := file.CurrentS3ClientFactory()
+	file.SetS3ClientFactory(func() (file.S3API, file.S3PresignAPI) { return s, s })
+	t.Cleanup(func() { file.SetS3ClientFactory(prev) })
+}
+
+// Put stores body under bucket/key, for seeding a test.
+func (s *FakeS3) Put(bucket, key string, body []byte, contentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(&FakeObject{Bucket: bucket, Key: key, Body: slices.Clone(body), ContentType: contentType})
+}
+
+// Object returns a copy of the object at bucket/key.
+func (s *FakeS3) Object(bucket, key string) (FakeObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[objectID(bucket, key)]
+	if !ok {
+		return FakeObject{}, false
+	}
+	return obj.clone(), true
+}
+
+// Keys returns the keys stored in bucket, sorted.
+func (s *FakeS3) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for _, obj := range s.objects {
+		if obj.Bucket == bucket {
+			keys = append(keys, obj.Key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// GetObject implements file.S3API.
+func (s *FakeS3) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[objectID(aws.ToString(in.Bucket), aws.ToString(in.Key))]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	if err := checkConditions(obj, in.IfMatch, in.IfNoneMatch); err != nil {
+		return nil, err
+	}
+	body := obj.Body
+	if r := aws.ToString(in.Range); r != "" {
+		start, end, err := parseRange(r, int64(len(body)))
+		if err != nil {
+			return nil, err
+		}
+		body = body[start : end+1]
+	}
+	return &s3.GetObjectOutput{
+		Body:               io.NopCloser(bytes.NewReader(slices.Clone(body))),
+		ContentLength:      aws.Int64(int64(len(body))),
+		ContentType:        nilIfEmpty(obj.ContentType),
+		ContentDisposition: nilIfEmpty(obj.ContentDisposition),
+		ContentEncoding:    nilIfEmpty(obj.ContentEncoding),
+		CacheControl:       nilIfEmpty(obj.CacheControl),
+		ETag:               aws.String(quote(obj.ETag)),
+		LastModified:       aws.Time(obj.LastModified),
+		Metadata:           maps.Clone(obj.Metadata),
+	}, nil
+}
+
+// PutObject implements file.S3API. IfNoneMatch "*" fails with
+// PreconditionFailed when the key exists.
+func (s *FakeS3) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var body []byte
+	if in.Body != nil {
+		var err error
+		if body, err = io.ReadAll(in.Body); err != nil {
+			return nil, err
+		}
+	}
+	tags, err := url.ParseQuery(aws.ToString(in.Tagging))
+	if err != nil {
+		return nil, apiError(http.StatusBadRequest, "InvalidArgument", "malformed Tagging")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := objectID(aws.ToString(in.Bucket), aws.ToString(in.Key))
+	if _, exists := s.objects[id]; exists && aws.ToString(in.IfNoneMatch) == "*" {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "object exists")
+	}
+	obj := &FakeObject{
+		Bucket:             aws.ToString(in.Bucket),
+		Key:                aws.ToString(in.Key),
+		Body:               body,
+		ContentType:        aws.ToString(in.ContentType),
+		ContentDisposition: aws.ToString(in.ContentDisposition),
+		ContentEncoding:    aws.ToString(in.ContentEncoding),
+		CacheControl:       aws.ToString(in.CacheControl),
+		Metadata:           lowerKeys(in.Metadata),
+		Tags:               flattenQuery(tags),
+	}
+	s.store(obj)
+	return &s3.PutObjectOutput{ETag: aws.String(quote(obj.ETag))}, nil
+}
+
+// DeleteObject implements file.S3API.
+func (s *FakeS3) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, objectID(aws.ToString(in.Bucket), aws.ToString(in.Key)))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// HeadObject implements file.S3API.
+func (s *FakeS3) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[objectID(aws.ToString(in.Bucket), aws.ToString(in.Key))]
+	if !ok {
+		return nil, &types.NotFound{Message: aws.String("Not Found")}
+	}
+	if err := checkConditions(obj, in.IfMatch, in.IfNoneMatch); err != nil {
+		return nil, err
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength:      aws.Int64(int64(len(obj.Body))),
+		ContentType:        nilIfEmpty(obj.ContentType),
+		ContentDisposition: nilIfEmpty(obj.ContentDisposition),
+		ContentEncoding:    nilIfEmpty(obj.ContentEncoding),
+		CacheControl:       nilIfEmpty(obj.CacheControl),
+		ETag:               aws.String(quote(obj.ETag)),
+		LastModified:       aws.Time(obj.LastModified),
+		Metadata:           maps.Clone(obj.Metadata),
+	}, nil
+}
+
+// CreateMultipartUpload implements file.S3API.
+func (s *FakeS3) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := "upload-" + strconv.Itoa(s.nextID)
+	s.uploads[id] = &fakeUpload{input: in, parts: map[int32][]byte{}}
+	return &s3.CreateMultipartUploadOutput{Bucket: in.Bucket, Key: in.Key, UploadId: aws.String(id)}, nil
+}
+
+// UploadPart implements file.S3API.
+func (s *FakeS3) UploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	up, ok := s.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	up.parts[aws.ToInt32(in.PartNumber)] = body
+	return &s3.UploadPartOutput{ETag: aws.String(quote(md5Hex(body)))}, nil
+}
+
+// UploadPartCopy implements file.S3API, honoring CopySourceRange and
+// CopySourceIfMatch.
+func (s *FakeS3) UploadPartCopy(ctx context.Context, in *s3.UploadPartCopyInput, _ ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	src, err := url.PathUnescape(aws.ToString(in.CopySource))
+	if err != nil {
+		return nil, apiError(http.StatusBadRequest, "InvalidArgument", "malformed CopySource")
+	}
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(src, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	up, ok := s.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	from, ok := s.objects[objectID(srcBucket, srcKey)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	if m := aws.ToString(in.CopySourceIfMatch); m != "" && strings.Trim(m, `"`) != from.ETag {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "CopySourceIfMatch")
+	}
+	body := from.Body
+	if r := aws.ToString(in.CopySourceRange); r != "" {
+		start, end, err := parseRange(r, int64(len(body)))
+		if err != nil {
+			return nil, err
+		}
+		body = body[start : end+1]
+	}
+	up.parts[aws.ToInt32(in.PartNumber)] = slices.Clone(body)
+	return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{
+		ETag:         aws.String(quote(md5Hex(body))),
+		LastModified: aws.Time(s.now()),
+	}}, nil
+}
+
+// CompleteMultipartUpload implements file.S3API.
+func (s *FakeS3) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := aws.ToString(in.UploadId)
+	up, ok := s.uploads[id]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	var body []byte
+	if in.MultipartUpload != nil {
+		for _, p := range in.MultipartUpload.Parts {
+			part, ok := up.parts[aws.ToInt32(p.PartNumber)]
+			if !ok {
+				return nil, apiError(http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d was not uploaded", aws.ToInt32(p.PartNumber)))
+			}
+			body = append(body, part...)
+		}
+	}
+	delete(s.uploads, id)
+	tags, _ := url.ParseQuery(aws.ToString(up.input.Tagging))
+	obj := &FakeObject{
+		Bucket:             aws.ToString(up.input.Bucket),
+		Key:                aws.ToString(up.input.Key),
+		Body:               body,
+		ContentType:        aws.ToString(up.input.ContentType),
+		ContentDisposition: aws.ToString(up.input.ContentDisposition),
+		ContentEncoding:    aws.ToString(up.input.ContentEncoding),
+		CacheControl:       aws.ToString(up.input.CacheControl),
+		Metadata:           lowerKeys(up.input.Metadata),
+		Tags:               flattenQuery(tags),
+	}
+	s.store(obj)
+	return &s3.CompleteMultipartUploadOutput{Bucket: up.input.Bucket, Key: up.input.Key, ETag: aws.String(quote(obj.ETag))}, nil
+}
+
+// AbortMultipartUpload implements file.S3API.
+func (s *FakeS3) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, aws.ToString(in.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// ListParts implements file.S3API.
+func (s *FakeS3) ListParts(ctx context.Context, in *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	up, ok := s.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	out := &s3.ListPartsOutput{Bucket: in.Bucket, Key: in.Key, UploadId: in.UploadId}
+	for _, n := range slices.Sorted(maps.Keys(up.parts)) {
+		out.Parts = append(out.Parts, types.Part{
+			PartNumber: aws.Int32(n),
+			Size:       aws.Int64(int64(len(up.parts[n]))),
+			ETag:       aws.String(quote(md5Hex(up.parts[n]))),
+		})
+	}
+	return out, nil
+}
+
+// ListObjectsV2 implements file.S3API, honoring Prefix, StartAfter,
+// ContinuationToken and MaxKeys (default 1000). Delimiter is ignored.
+func (s *FakeS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucket, prefix := aws.ToString(in.Bucket), aws.ToString(in.Prefix)
+	after := aws.ToString(in.StartAfter)
+	if tok := aws.ToString(in.ContinuationToken); tok != "" {
+		after = tok
+	}
+	limit := int(aws.ToInt32(in.MaxKeys))
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	var keys []string
+	for _, obj := range s.objects {
+		if obj.Bucket == bucket && strings.HasPrefix(obj.Key, prefix) && obj.Key > after {
+			keys = append(keys, obj.Key)
+		}
+	}
+	slices.Sort(keys)
+	out := &s3.ListObjectsV2Output{Name: in.Bucket, Prefix: in.Prefix, IsTruncated: aws.Bool(len(keys) > limit)}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		out.NextContinuationToken = aws.String(keys[limit-1])
+	}
+	for _, k := range keys {
+		obj := s.objects[objectID(bucket, k)]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(int64(len(obj.Body))),
+			ETag:         aws.String(quote(obj.ETag)),
+			LastModified: aws.Time(obj.LastModified),
+		})
+	}
+	out.KeyCount = aws.Int32(int32(len(out.Contents)))
+	return out, nil
+}
+
+// ListObjectVersions implements file.S3API. FakeS3 does not keep old
+// versions, so each object under Prefix is listed as its only, latest
+// version, with the ID "null" S3 gives objects in unversioned buckets.
+func (s *FakeS3) ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucket, prefix := aws.ToString(in.Bucket), aws.ToString(in.Prefix)
+
+	var keys []string
+	for _, obj := range s.objects {
+		if obj.Bucket == bucket && strings.HasPrefix(obj.Key, prefix) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	slices.Sort(keys)
+	out := &s3.ListObjectVersionsOutput{Name: in.Bucket, Prefix: in.Prefix, IsTruncated: aws.Bool(false)}
+	for _, k := range keys {
+		obj := s.objects[objectID(bucket, k)]
+		out.Versions = append(out.Versions, types.ObjectVersion{
+			Key:          aws.String(k),
+			VersionId:    aws.String("null"),
+			IsLatest:     aws.Bool(true),
+			Size:         aws.Int64(int64(len(obj.Body))),
+			ETag:         aws.String(quote(obj.ETag)),
+			LastModified: aws.Time(obj.LastModified),
+		})
+	}
+	return out, nil
+}
+
+// DeleteObjects implements file.S3API.
+func (s *FakeS3) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := &s3.DeleteObjectsOutput{}
+	if in.Delete == nil {
+		return out, nil
+	}
+	for _, id := range in.Delete.Objects {
+		delete(s.objects, objectID(aws.ToString(in.Bucket), aws.ToString(id.Key)))
+		out.Deleted = append(out.Deleted, types.DeletedObject{Key: id.Key})
+	}
+	return out, nil
+}
+
+// CopyObject implements file.S3API, honoring CopySourceIfMatch and
+// MetadataDirective REPLACE.
+func (s *FakeS3) CopyObject(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	src, err := url.PathUnescape(aws.ToString(in.CopySource))
+	if err != nil {
+		return nil, apiError(http.StatusBadRequest, "InvalidArgument", "malformed CopySource")
+	}
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(src, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, ok := s.objects[objectID(srcBucket, srcKey)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	if m := aws.ToString(in.CopySourceIfMatch); m != "" && strings.Trim(m, `"`) != from.ETag {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "CopySourceIfMatch")
+	}
+	obj := from.clone()
+	obj.Bucket, obj.Key = aws.ToString(in.Bucket), aws.ToString(in.Key)
+	if in.MetadataDirective == types.MetadataDirectiveReplace {
+		obj.ContentType = aws.ToString(in.ContentType)
+		obj.ContentDisposition = aws.ToString(in.ContentDisposition)
+		obj.ContentEncoding = aws.ToString(in.ContentEncoding)
+		obj.CacheControl = aws.ToString(in.CacheControl)
+		obj.Metadata = lowerKeys(in.Metadata)
+	}
+	s.store(&obj)
+	return &s3.CopyObjectOutput{CopyObjectResult: &types.CopyObjectResult{
+		ETag:         aws.String(quote(obj.ETag)),
+		LastModified: aws.Time(obj.LastModified),
+	}}, nil
+}
+
+// GetObjectTagging implements file.S3API.
+func (s *FakeS3) GetObjectTagging(ctx context.Context, in *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[objectID(aws.ToString(in.Bucket), aws.ToString(in.Key))]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	out := &s3.GetObjectTaggingOutput{TagSet: []types.Tag{}}
+	for _, k := range slices.Sorted(maps.Keys(obj.Tags)) {
+		out.TagSet = append(out.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(obj.Tags[k])})
+	}
+	return out, nil
+}
+
+// PutObjectTagging implements file.S3API.
+func (s *FakeS3) PutObjectTagging(ctx context.Context, in *s3.PutObjectTaggingInput, _ ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[objectID(aws.ToString(in.Bucket), aws.ToString(in.Key))]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	obj.Tags = map[string]string{}
+	if in.Tagging != nil {
+		for _, tag := range in.Tagging.TagSet {
+			obj.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+// PresignGetObject implements file.S3PresignAPI.
+func (s *FakeS3) PresignGetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return presign(http.MethodGet, aws.ToString(in.Bucket), aws.ToString(in.Key), optFns), nil
+}
+
+// PresignPutObject implements file.S3PresignAPI.
+func (s *FakeS3) PresignPutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return presign(http.MethodPut, aws.ToString(in.Bucket), aws.ToString(in.Key), optFns), nil
+}
+
+// presign builds a fake presigned request for bucket/key.
+func presign(method, bucket, key string, optFns []func(*s3.PresignOptions)) *v4.PresignedHTTPRequest {
+	var o s3.PresignOptions
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	u := url.URL{
+		Scheme:   "https",
+		Host:     bucket + ".s3.fake.test",
+		Path:     "/" + key,
+		RawQuery: url.Values{"X-Amz-Expires": {strconv.Itoa(int(o.Expires.Seconds()))}}.Encode(),
+	}
+	return &v4.PresignedHTTPRequest{URL: u.String(), Method: method, SignedHeader: http.Header{}}
+}
+
+// store saves obj, stamping its ETag and LastModified. s.mu must be held.
+func (s *FakeS3) store(obj *FakeObject) {
+	obj.ETag = md5Hex(obj.Body)
+	obj.LastModified = s.now().UTC().Truncate(time.Second)
+	s.objects[objectID(obj.Bucket, obj.Key)] = obj
+}
+
+// clone returns a deep copy of o.
+func (o *FakeObject) clone() FakeObject {
+	c := *o
+	c.Body = slices.Clone(o.Body)
+	c.Metadata = maps.Clone(o.Metadata)
+	c.Tags = maps.Clone(o.Tags)
+	return c
+}
+
+// checkConditions evaluates If-Match and If-None-Match against obj.
+func checkConditions(obj *FakeObject, ifMatch, ifNoneMatch *string) error {
+	if m := aws.ToString(ifMatch); m != "" && strings.Trim(m, `"`) != obj.ETag {
+		return apiError(http.StatusPreconditionFailed, "PreconditionFailed", "If-Match")
+	}
+	if m := aws.ToString(ifNoneMatch); m == "*" || (m != "" && strings.Trim(m, `"`) == obj.ETag) {
+		return apiError(http.StatusNotModified, "NotModified", "If-None-Match")
+	}
+	return nil
+}
+
+// parseRange parses a "bytes=start-end" header against an object of size
+// n, returning inclusive offsets.
+func parseRange(r string, n int64) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(r, "bytes=")
+	from, to, ok2 := strings.Cut(spec, "-")
+	start, err1 := strconv.ParseInt(from, 10, 64)
+	if !ok || !ok2 || err1 != nil || start >= n {
+		return 0, 0, apiError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange", r)
+	}
+	end = n - 1
+	if to != "" {
+		if e, err := strconv.ParseInt(to, 10, 64); err == nil && e < end {
+			end = e
+		}
+	}
+	if end < start {
+		return 0, 0, apiError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange", r)
+	}
+	return start, end, nil
+}
+
+// apiError returns an error shaped like the SDK's: an API error carrying
+// code, wrapped in a response error with the HTTP status.
+func apiError(status int, code, msg string) error {
+	return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      &smithy.GenericAPIError{Code: code, Message: msg},
+	}}
+}
+
+func objectID(bucket, key string) string { return bucket + "/" + key }
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func quote(etag string) string { return `"` + etag + `"` }
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// lowerKeys copies m with its keys lowercased, as S3 stores user metadata.
+func lowerKeys(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+// flattenQuery keeps the first value of each key.
+func flattenQuery(q url.Values) map[string]string {
+	if len(q) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(q))
+	for k, v := range q {
+		out[k] = v[0]
+	}
+	return out
+}
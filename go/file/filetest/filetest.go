@@ -0,0 +1,401 @@
+// Package filetest provides assertions and an in-memory S3 fake for
+// testing code that uses the file package.
+//
+// It lives in its own package so the testing import and the fake stay out
+// of production binaries. A typical test installs the fake, runs the code
+// under test, and checks what it uploaded:
+//
+//	fake := filetest.NewFakeS3()
+//	fake.Install(t)
+//	// ... code that calls f.UploadToS3("bucket", "report.pdf") ...
+//	filetest.RequireUploaded(t, fake, "bucket", "report.pdf",
+//		filetest.WithContentType("application/pdf"))
+//
+// Assertions report through t.Errorf and return whether they passed;
+// RequireUploaded stops the test instead. Content mismatches are reported
+// with a unified diff for text and a hex dump around the first differing
+// byte for binary data.
+package filetest
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// AssertContent checks that f's content is want.
+func AssertContent(t testing.TB, f *file.File, want []byte) bool {
+	t.Helper()
+	got, err := f.Read()
+	if err != nil {
+		t.Errorf("filetest: reading %s: %v", describe(f), err)
+		return false
+	}
+	if bytes.Equal(got, want) {
+		return true
+	}
+	t.Errorf("filetest: content of %s does not match:\n%s", describe(f), ContentDiff(want, got))
+	return false
+}
+
+// AssertMime checks that f's MIME type, without parameters, matches glob
+// (path.Match syntax, so "image/*" matches any image type).
+func AssertMime(t testing.TB, f *file.File, glob string) bool {
+	t.Helper()
+	got := f.MimeType()
+	base, _, _ := strings.Cut(got, ";")
+	base = strings.ToLower(strings.TrimSpace(base))
+	ok, err := path.Match(strings.ToLower(glob), base)
+	if err != nil {
+		t.Errorf("filetest: bad MIME pattern %q: %v", glob, err)
+		return false
+	}
+	if !ok {
+		t.Errorf("filetest: MIME type of %s is %q, want %q", describe(f), got, glob)
+	}
+	return ok
+}
+
+// AssertSize checks that f's size is want bytes.
+func AssertSize(t testing.TB, f *file.File, want int64) bool {
+	t.Helper()
+	if got := f.Size(); got != want {
+		t.Errorf("filetest: size of %s is %d, want %d", describe(f), got, want)
+		return false
+	}
+	return true
+}
+
+// AssertMetadataEqual checks that got and want agree on every exported
+// Metadata field except those named in ignore (e.g. "LastModified",
+// "Hash"). A nil and an empty Attributes map are equal. All differing
+// fields are reported together.
+func AssertMetadataEqual(t testing.TB, got, want file.Metadata, ignore ...string) bool {
+	t.Helper()
+	typ := reflect.TypeOf(want)
+	for _, name := range ignore {
+		if _, ok := typ.FieldByName(name); !ok {
+			t.Errorf("filetest: Metadata has no field %q to ignore", name)
+			return false
+		}
+	}
+
+	gv, wv := reflect.ValueOf(got), reflect.ValueOf(want)
+	var diffs []string
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() || slices.Contains(ignore, field.Name) {
+			continue
+		}
+		g, w := gv.Field(i).Interface(), wv.Field(i).Interface()
+		if field.Name == "Attributes" {
+			if !maps.Equal(got.Attributes, want.Attributes) {
+				diffs = append(diffs, fmt.Sprintf("  Attributes: got %v, want %v", got.Attributes, want.Attributes))
+			}
+			continue
+		}
+		if !reflect.DeepEqual(g, w) {
+			diffs = append(diffs, fmt.Sprintf("  %s: got %#v, want %#v", field.Name, g, w))
+		}
+	}
+	if len(diffs) > 0 {
+		t.Errorf("filetest: metadata differs:\n%s", strings.Join(diffs, "\n"))
+		return false
+	}
+	return true
+}
+
+// UploadExpectation checks one property of an uploaded object, returning a
+// description of the mismatch or "" when it holds.
+type UploadExpectation func(obj FakeObject) string
+
+// WithContent expects the object's body to be want.
+func WithContent(want []byte) UploadExpectation {
+	return func(obj FakeObject) string {
+		if bytes.Equal(obj.Body, want) {
+			return ""
+		}
+		return "content does not match:\n" + ContentDiff(want, obj.Body)
+	}
+}
+
+// WithContentType expects the object's Content-Type, without parameters,
+// to match glob as in AssertMime.
+func WithContentType(glob string) UploadExpectation {
+	return func(obj FakeObject) string {
+		base, _, _ := strings.Cut(obj.ContentType, ";")
+		if ok, _ := path.Match(strings.ToLower(glob), strings.ToLower(strings.TrimSpace(base))); ok {
+			return ""
+		}
+		return fmt.Sprintf("Content-Type is %q, want %q", obj.ContentType, glob)
+	}
+}
+
+// WithContentDisposition expects the object's Content-Disposition to be
+// want exactly.
+func WithContentDisposition(want string) UploadExpectation {
+	return func(obj FakeObject) string {
+		if obj.ContentDisposition == want {
+			return ""
+		}
+		return fmt.Sprintf("Content-Disposition is %q, want %q", obj.ContentDisposition, want)
+	}
+}
+
+// WithMetadataValue expects user metadata key (case-insensitive) to be
+// value.
+func WithMetadataValue(key, value string) UploadExpectation {
+	return func(obj FakeObject) string {
+		got, ok := obj.Metadata[strings.ToLower(key)]
+		switch {
+		case !ok:
+			return fmt.Sprintf("metadata %q is missing, want %q (have %v)", key, value, obj.Metadata)
+		case got != value:
+			return fmt.Sprintf("metadata %q is %q, want %q", key, got, value)
+		}
+		return ""
+	}
+}
+
+// WithTag expects the object to carry tag key=value.
+func WithTag(key, value string) UploadExpectation {
+	return func(obj FakeObject) string {
+		got, ok := obj.Tags[key]
+		switch {
+		case !ok:
+			return fmt.Sprintf("tag %q is missing, want %q (have %v)", key, value, obj.Tags)
+		case got != value:
+			return fmt.Sprintf("tag %q is %q, want %q", key, got, value)
+		}
+		return ""
+	}
+}
+
+// RequireUploaded checks that fake holds an object at bucket/key meeting
+// every expectation, and stops the test with t.FailNow otherwise. It
+// returns the object for further checks.
+func RequireUploaded(t testing.TB, fake *FakeS3, bucket, key string, expect ...UploadExpectation) FakeObject {
+	t.Helper()
+	obj, ok := fake.Object(bucket, key)
+	if !ok {
+		t.Fatalf("filetest: nothing uploaded to s3://%s/%s (bucket has %q)", bucket, key, fake.Keys(bucket))
+		return FakeObject{}
+	}
+	var problems []string
+	for _, e := range expect {
+		if msg := e(obj); msg != "" {
+			problems = append(problems, msg)
+		}
+	}
+	if len(problems) > 0 {
+		t.Fatalf("filetest: upload to s3://%s/%s: %s", bucket, key, strings.Join(problems, "; "))
+	}
+	return obj
+}
+
+// describe names f in failure messages.
+func describe(f *file.File) string {
+	m := f.Metadata()
+	switch {
+	case m.URL != "":
+		return m.URL
+	case m.Path != "":
+		return m.Path
+	case m.Name != "":
+		return fmt.Sprintf("%q", m.Name)
+	}
+	return "file"
+}
+
+// ContentDiff describes how got differs from want: a unified diff when
+// both are text, otherwise the sizes and a hex dump of both around the
+// first differing byte. It returns "" when they are equal.
+func ContentDiff(want, got []byte) string {
+	if bytes.Equal(want, got) {
+		return ""
+	}
+	if isText(want) && isText(got) {
+		if d, ok := unifiedDiff(string(want), string(got)); ok {
+			return d
+		}
+	}
+	return hexDiff(want, got)
+}
+
+// isText reports whether b looks like text: valid UTF-8 without NULs or
+// control characters other than whitespace.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	return !bytes.ContainsFunc(b, func(r rune) bool {
+		return r < 0x20 && r != '\n' && r != '\r' && r != '\t'
+	})
+}
+
+// maxDiffLines bounds the inputs unifiedDiff compares line by line; its
+// table is quadratic in the line counts.
+const maxDiffLines = 2000
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// unifiedDiff renders a line diff of want against got. It reports false
+// when the inputs are too long to diff.
+func unifiedDiff(want, got string) (string, bool) {
+	if strings.HasSuffix(want, "\n") && strings.HasSuffix(got, "\n") {
+		want, got = want[:len(want)-1], got[:len(got)-1]
+	}
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return "", false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte // ' ', '-' or '+'
+		line string
+		ai   int // line index in a before this edit
+		bi   int // line index in b before this edit
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- want\n+++ got\n")
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		// Extend the hunk while changes are within 2*diffContext lines.
+		start := max(0, k-diffContext)
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(edits))
+				break
+			}
+			end = run
+		}
+
+		var na, nb int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				na++
+			}
+			if e.op != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", edits[start].ai+1, na, edits[start].bi+1, nb)
+		for _, e := range edits[start:end] {
+			sb.WriteByte(e.op)
+			sb.WriteString(e.line)
+			sb.WriteByte('\n')
+		}
+		k = end
+	}
+	return sb.String(), true
+}
+
+// hexRows is the number of 16-byte rows hexDiff shows for each side.
+const hexRows = 4
+
+// hexDiff reports the sizes of want and got and dumps both around the
+// first offset where they differ.
+func hexDiff(want, got []byte) string {
+	off := 0
+	for off < len(want) && off < len(got) && want[off] == got[off] {
+		off++
+	}
+	start := max(0, off/16*16-16)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "want %d bytes, got %d bytes; first difference at offset %d (0x%x)\n", len(want), len(got), off, off)
+	for _, side := range []struct {
+		label string
+		data  []byte
+	}{{"want", want}, {"got", got}} {
+		fmt.Fprintf(&sb, "%s:\n", side.label)
+		if start >= len(side.data) {
+			sb.WriteString("  (ends before this offset)\n")
+			continue
+		}
+		for row := start; row < len(side.data) && row < start+hexRows*16; row += 16 {
+			writeHexRow(&sb, side.data, row, off)
+		}
+	}
+	return sb.String()
+}
+
+// writeHexRow writes the 16 bytes of data at row like hexdump -C, marking
+// the byte at mark with a '>'.
+func writeHexRow(sb *strings.Builder, data []byte, row, mark int) {
+	fmt.Fprintf(sb, "  %08x ", row)
+	for i := row; i < row+16; i++ {
+		sep := byte(' ')
+		if i == mark {
+			sep = '>'
+		}
+		sb.WriteByte(sep)
+		if i < len(data) {
+			fmt.Fprintf(sb, "%02x", data[i])
+		} else {
+			sb.WriteString("  ")
+		}
+	}
+	sb.WriteString("  |")
+	for i := row; i < row+16 && i < len(data); i++ {
+		c := data[i]
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		sb.WriteByte(c)
+	}
+	sb.WriteString("|\n")
+}
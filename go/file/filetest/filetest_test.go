@@ -0,0 +1,278 @@
+package filetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// recordingTB captures failures instead of failing the real test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	r.fatal = true
+}
+
+func (r *recordingTB) FailNow() { r.fatal = true }
+
+func (r *recordingTB) output() string { return strings.Join(r.errors, "\n") }
+
+func mustBytes(t *testing.T, data []byte, opts ...file.ReadOption) *file.File {
+	t.Helper()
+	f, err := file.NewFromBytes(data, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestAssertContent(t *testing.T) {
+	f := mustBytes(t, []byte("hello\n"))
+	if !AssertContent(t, f, []byte("hello\n")) {
+		t.Fatal("equal content failed")
+	}
+
+	rec := &recordingTB{TB: t}
+	if AssertContent(rec, f, []byte("goodbye\n")) {
+		t.Fatal("different content passed")
+	}
+	if len(rec.errors) != 1 || rec.fatal {
+		t.Fatalf("want one non-fatal error, got %q (fatal %v)", rec.errors, rec.fatal)
+	}
+}
+
+func TestContentDiffText(t *testing.T) {
+	want := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	got := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\n"
+	d := ContentDiff([]byte(want), []byte(got))
+	for _, line := range []string{
+		"--- want\n+++ got\n",
+		"@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n",
+		"@@ -12,3 +12,4 @@\n l\n m\n n\n+o\n",
+	} {
+		if !strings.Contains(d, line) {
+			t.Errorf("diff missing %q:\n%s", line, d)
+		}
+	}
+	if ContentDiff([]byte(want), []byte(want)) != "" {
+		t.Error("equal content produced a diff")
+	}
+}
+
+func TestContentDiffMergesNearbyChanges(t *testing.T) {
+	d := ContentDiff([]byte("1\n2\n3\n4\n5\n"), []byte("1\nX\n3\nY\n5\n"))
+	if n := strings.Count(d, "@@ -"); n != 1 {
+		t.Errorf("want one hunk, got %d:\n%s", n, d)
+	}
+}
+
+func TestContentDiffBinary(t *testing.T) {
+	want := bytes.Repeat([]byte{0x00, 0x01, 0x02, 0x03}, 20)
+	got := bytes.Clone(want)
+	got[37] = 0xff
+	d := ContentDiff(want, got)
+	for _, s := range []string{
+		"want 80 bytes, got 80 bytes; first difference at offset 37 (0x25)",
+		"want:\n  00000010 ",
+		"  00000020  00 01 02 03 00>01 02",
+		"  00000020  00 01 02 03 00>ff 02",
+	} {
+		if !strings.Contains(d, s) {
+			t.Errorf("hex diff missing %q:\n%s", s, d)
+		}
+	}
+}
+
+func TestContentDiffBinaryTruncated(t *testing.T) {
+	want := []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0x0d}
+	d := ContentDiff(want, want[:4])
+	if !strings.Contains(d, "want 8 bytes, got 4 bytes; first difference at offset 4") {
+		t.Errorf("unexpected hex diff:\n%s", d)
+	}
+}
+
+func TestAssertMime(t *testing.T) {
+	f := mustBytes(t, []byte("x"), file.MetadataHint{MimeType: "image/png"})
+	for _, glob := range []string{"image/png", "image/*", "IMAGE/PNG"} {
+		if !AssertMime(t, f, glob) {
+			t.Errorf("%q did not match", glob)
+		}
+	}
+
+	rec := &recordingTB{TB: t}
+	if AssertMime(rec, f, "text/*") {
+		t.Fatal("text/* matched image/png")
+	}
+	if !strings.Contains(rec.output(), `is "image/png", want "text/*"`) {
+		t.Errorf("unexpected message %q", rec.output())
+	}
+}
+
+func TestAssertMetadataEqual(t *testing.T) {
+	base := file.Metadata{Name: "a.txt", MimeType: "text/plain", Size: 3, LastModified: time.Unix(1, 0)}
+	other := base
+	other.LastModified = time.Unix(2, 0)
+	other.Attributes = map[string]string{}
+	if !AssertMetadataEqual(t, other, base, "LastModified") {
+		t.Fatal("ignored field or empty Attributes failed the comparison")
+	}
+
+	rec := &recordingTB{TB: t}
+	other.Size = 4
+	other.Attributes = map[string]string{"k": "v"}
+	if AssertMetadataEqual(rec, other, base) {
+		t.Fatal("different metadata passed")
+	}
+	out := rec.output()
+	for _, s := range []string{"Size: got 4, want 3", "Attributes: got map[k:v], want map[]", "LastModified:"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("message missing %q:\n%s", s, out)
+		}
+	}
+
+	rec = &recordingTB{TB: t}
+	if AssertMetadataEqual(rec, base, base, "Nope") || !strings.Contains(rec.output(), `no field "Nope"`) {
+		t.Errorf("unknown ignore field not reported: %q", rec.output())
+	}
+}
+
+func TestRequireUploaded(t *testing.T) {
+	fake := NewFakeS3()
+	fake.Install(t)
+
+	f := mustBytes(t, []byte("%PDF-1.4 report"), file.MetadataHint{Name: "report.pdf", MimeType: "application/pdf"})
+	if err := f.UploadToS3("bucket", "reports/report.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	obj := RequireUploaded(t, fake, "bucket", "reports/report.pdf",
+		WithContent([]byte("%PDF-1.4 report")),
+		WithContentType("application/*"))
+	if obj.ETag == "" || obj.LastModified.IsZero() {
+		t.Errorf("stored object missing ETag or LastModified: %+v", obj)
+	}
+
+	rec := &recordingTB{TB: t}
+	RequireUploaded(rec, fake, "bucket", "reports/report.pdf",
+		WithContentType("image/*"), WithMetadataValue("Owner", "me"))
+	if !rec.fatal {
+		t.Fatal("mismatched upload did not stop the test")
+	}
+	for _, s := range []string{`Content-Type is "application/pdf", want "image/*"`, `metadata "Owner" is missing`} {
+		if !strings.Contains(rec.output(), s) {
+			t.Errorf("message missing %q:\n%s", s, rec.output())
+		}
+	}
+
+	rec = &recordingTB{TB: t}
+	RequireUploaded(rec, fake, "bucket", "missing.pdf")
+	if !rec.fatal || !strings.Contains(rec.output(), `nothing uploaded to s3://bucket/missing.pdf (bucket has ["reports/report.pdf"])`) {
+		t.Errorf("missing object: fatal %v, message %q", rec.fatal, rec.output())
+	}
+}
+
+func TestFakeS3RoundTrip(t *testing.T) {
+	fake := NewFakeS3()
+	fake.Install(t)
+	fake.Put("bucket", "in.txt", []byte("0123456789"), "text/plain")
+
+	f, err := file.NewFromS3("bucket", "in.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertContent(t, f, []byte("0123456789"))
+	AssertMime(t, f, "text/plain")
+
+	ctx := context.Background()
+	out, err := fake.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("in.txt"), Range: aws.String("bytes=2-4")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.ToInt64(out.ContentLength); got != 3 {
+		t.Errorf("range length = %d, want 3", got)
+	}
+
+	_, err = fake.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("nope")})
+	var noKey *types.NoSuchKey
+	if !errors.As(err, &noKey) {
+		t.Errorf("missing key: got %v, want NoSuchKey", err)
+	}
+	if _, err := file.NewFromS3("bucket", "nope"); file.CodeOf(err) != file.CodeNotFound {
+		t.Errorf("NewFromS3 of missing key: code %q (%v)", file.CodeOf(err), err)
+	}
+
+	_, err = fake.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("in.txt"), IfNoneMatch: aws.String("*")})
+	if file.CodeOf(err) != file.CodeRemote4xx {
+		t.Errorf("conditional put over existing key: %v", err)
+	}
+}
+
+func TestFakeS3Multipart(t *testing.T) {
+	fake := NewFakeS3()
+	ctx := context.Background()
+	up, err := fake.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String("b"), Key: aws.String("k"), ContentType: aws.String("text/plain")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, part := range []string{"hello ", "world"} {
+		if _, err := fake.UploadPart(ctx, &s3.UploadPartInput{UploadId: up.UploadId, PartNumber: aws.Int32(int32(n + 1)), Body: strings.NewReader(part)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = fake.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		UploadId: up.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: []types.CompletedPart{
+			{PartNumber: aws.Int32(1)}, {PartNumber: aws.Int32(2)},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	RequireUploaded(t, fake, "b", "k", WithContent([]byte("hello world")), WithContentType("text/plain"))
+}
+
+func TestFakeS3ListObjectsV2(t *testing.T) {
+	fake := NewFakeS3()
+	for _, k := range []string{"a/1", "a/2", "a/3", "b/1"} {
+		fake.Put("bucket", k, []byte(k), "")
+	}
+	ctx := context.Background()
+	var keys []string
+	in := &s3.ListObjectsV2Input{Bucket: aws.String("bucket"), Prefix: aws.String("a/"), MaxKeys: aws.Int32(2)}
+	for {
+		out, err := fake.ListObjectsV2(ctx, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range out.Contents {
+			keys = append(keys, aws.ToString(o.Key))
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
+	if got := strings.Join(keys, ","); got != "a/1,a/2,a/3" {
+		t.Errorf("listed %s, want a/1,a/2,a/3", got)
+	}
+}
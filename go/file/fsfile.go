@@ -0,0 +1,109 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// NewFromFS creates a File from the named file in fsys, such as an
+// embed.FS or an fstest.MapFS. The content is read eagerly with
+// fs.ReadFile and run through the same metadata pipeline as NewFromFile;
+// Path is the fs-relative name ("fixtures/report.csv"), not an OS path,
+// so operations that act on local files in place (Delete, Append) do not
+// apply. Directories are rejected with ErrInvalidSource; use WalkFS.
+func NewFromFS(fsys fs.FS, name string, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromFS", ro.validate()); err != nil {
+		return nil, err
+	}
+
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, newError(ErrNotFound, "NewFromFS", err)
+		}
+		return nil, newError(ErrRead, "NewFromFS", err)
+	}
+	if info.IsDir() {
+		return nil, newError(ErrInvalidSource, "NewFromFS", fmt.Errorf("%s is a directory", name))
+	}
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, newError(ErrRead, "NewFromFS", err)
+	}
+
+	return withProvenance(ro.normalizeName(&File{
+		source: SourceFS,
+		meta:   resolveMetadataFromFS(name, info, data, hint),
+		data:   data,
+		loaded: true,
+	})), nil
+}
+
+// WalkFS calls fn with a File for every regular file beneath root in fsys,
+// in lexical order. Returning fs.SkipDir from fn skips the rest of the
+// file's directory and fs.SkipAll ends the walk without error; any other
+// error stops the walk and is returned.
+func WalkFS(fsys fs.FS, root string, fn func(*File) error) error {
+	return fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return newError(ErrRead, "WalkFS", err)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := NewFromFS(fsys, p)
+		if err != nil {
+			return err
+		}
+		return fn(f)
+	})
+}
+
+// resolveMetadataFromFS builds Metadata from an fs.FS entry, its stat info,
+// and hints. Embedded files report a zero modification time, which is
+// left unset.
+func resolveMetadataFromFS(name string, info fs.FileInfo, data []byte, hint MetadataHint) Metadata {
+	m := Metadata{}
+	applyHint(&m, hint)
+	m.noteOrigin(OriginHint)
+
+	m.Path = name
+	if m.Name == "" {
+		m.Name = path.Base(name)
+		m.noteOrigin(OriginPath)
+	}
+
+	m.Size = info.Size()
+	if !info.ModTime().IsZero() {
+		m.LastModified = info.ModTime()
+	}
+	m.noteOrigin(OriginStat)
+
+	// Detect from name.
+	if m.MimeType == "" && m.Name != "" {
+		m.MimeType = MimeTypeFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
+	}
+
+	// Magic-byte detection.
+	applyMagicDetection(&m, data)
+	m.noteOrigin(OriginMagicBytes)
+
+	// Fallback extension from MIME type.
+	if m.Extension == "" && m.MimeType != "" {
+		m.Extension = ExtensionFromMimeType(m.MimeType)
+		m.noteOrigin(OriginFallback)
+	}
+
+	// Fallback extension from name.
+	if m.Extension == "" && m.Name != "" {
+		m.Extension = StrictExtensionFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
+	}
+
+	return m
+}
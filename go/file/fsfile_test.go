@@ -0,0 +1,103 @@
+package file
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+//go:embed testdata/fsfixture
+var fsFixture embed.FS
+
+func TestNewFromFS_Embed(t *testing.T) {
+	f, err := NewFromFS(fsFixture, "testdata/fsfixture/items.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Source() != SourceFS {
+		t.Errorf("source = %v", f.Source())
+	}
+	if f.Name() != "items.csv" || f.Path() != "testdata/fsfixture/items.csv" {
+		t.Errorf("name = %q, path = %q", f.Name(), f.Path())
+	}
+	if f.MimeType() != "text/csv" || f.Size() != 17 {
+		t.Errorf("mime = %q, size = %d", f.MimeType(), f.Size())
+	}
+	if !f.Metadata().LastModified.IsZero() {
+		t.Errorf("embedded file has a modification time: %v", f.Metadata().LastModified)
+	}
+	if text, _ := f.ReadText(); text != "id,name\n1,widget\n" {
+		t.Errorf("content = %q", text)
+	}
+	if p := f.Provenance(); len(p) != 1 || p[0].Path != "testdata/fsfixture/items.csv" {
+		t.Errorf("provenance = %+v", p)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := NewFromFS(fsFixture, "testdata/missing.txt"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing: err = %v, want ErrNotFound", err)
+		}
+		if _, err := NewFromFS(fsFixture, "testdata/fsfixture/docs"); !errors.Is(err, ErrInvalidSource) {
+			t.Errorf("directory: err = %v, want ErrInvalidSource", err)
+		}
+	})
+}
+
+func TestNewFromFS_MapFS(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"img/logo": {Data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ModTime: modified},
+	}
+	f, err := NewFromFS(fsys, "img/logo", MetadataHint{Name: "brand"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "brand" || f.MimeType() != "image/png" || f.Extension() != "png" {
+		t.Errorf("name = %q, mime = %q, ext = %q", f.Name(), f.MimeType(), f.Extension())
+	}
+	if !f.Metadata().LastModified.Equal(modified) {
+		t.Errorf("modified = %v", f.Metadata().LastModified)
+	}
+}
+
+func TestWalkFS(t *testing.T) {
+	var got []string
+	err := WalkFS(fsFixture, "testdata/fsfixture", func(f *File) error {
+		got = append(got, f.Path()+" "+mediaTypeBase(f.MimeType()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"testdata/fsfixture/docs/notes.md text/markdown",
+		"testdata/fsfixture/docs/status.json application/json",
+		"testdata/fsfixture/items.csv text/csv",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walk = %v, want %v", got, want)
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		stop := errors.New("stop")
+		n := 0
+		err := WalkFS(fsFixture, ".", func(*File) error { n++; return stop })
+		if !errors.Is(err, stop) || n != 1 {
+			t.Errorf("err = %v after %d files", err, n)
+		}
+		n = 0
+		if err := WalkFS(fsFixture, ".", func(*File) error { n++; return fs.SkipAll }); err != nil || n != 1 {
+			t.Errorf("SkipAll: err = %v after %d files", err, n)
+		}
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		if err := WalkFS(fsFixture, "nope", func(*File) error { return nil }); !errors.Is(err, ErrRead) {
+			t.Errorf("err = %v, want ErrRead", err)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+// Package gcsstorage is a file.Storage backend for gs:// URIs over Google
+// Cloud Storage.
+//
+// It is a separate module (github.com/SmooAI/file/go/file/gcsstorage) so
+// programs that never talk to GCS neither link the Google Cloud client nor
+// carry it and its test server in their module graph. Register it once at
+// startup:
+//
+//	client, err := storage.NewClient(ctx)
+//	...
+//	gcsstorage.Register(gcsstorage.New(client))
+//
+// after which file.New, file.NewFromStorage and (*file.File).UploadToStorage
+// accept gs://bucket/object URIs. Append ?generation=N to read a specific
+// object generation.
+package gcsstorage
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// Scheme is the URI scheme this backend registers for.
+const Scheme = "gs"
+
+// Attribute keys for object attributes that have no dedicated Metadata
+// field. Custom object metadata is copied into Attributes under its own
+// keys.
+const (
+	AttrCRC32C = "gcs-crc32c"
+	AttrMD5    = "gcs-md5"
+)
+
+// Storage implements file.Storage and file.SignedURLStorage over a GCS
+// client.
+type Storage struct {
+	client *storage.Client
+
+	// SignOptions supplies the signing identity for SignedURL
+	// (GoogleAccessID, PrivateKey or SignBytes, Scheme). Method and Expires
+	// are set per call. When left empty the client's credentials are used.
+	SignOptions storage.SignedURLOptions
+}
+
+// New returns a Storage using client.
+func New(client *storage.Client) *Storage {
+	return &Storage{client: client}
+}
+
+// Register registers s for gs:// URIs and returns it.
+func Register(s *Storage) *Storage {
+	file.RegisterStorage(Scheme, s)
+	return s
+}
+
+// Get implements file.Storage.
+func (s *Storage) Get(ctx context.Context, uri string) (io.ReadCloser, file.Metadata, error) {
+	obj, err := s.object(uri)
+	if err != nil {
+		return nil, file.Metadata{}, err
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, file.Metadata{}, wrap("Get", err)
+	}
+	// Pin the generation so content and metadata describe the same object.
+	r, err := obj.Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, file.Metadata{}, wrap("Get", err)
+	}
+	return r, metadataFor(uri, attrs), nil
+}
+
+// Put implements file.Storage. meta.MimeType becomes the object's
+// ContentType and meta.Attributes its custom metadata.
+func (s *Storage) Put(ctx context.Context, uri string, r io.Reader, meta file.Metadata) error {
+	obj, err := s.object(uri)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := obj.NewWriter(ctx)
+	w.ContentType = meta.MimeType
+	if len(meta.Attributes) > 0 {
+		w.Metadata = meta.Attributes
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		// Cancelling before Close aborts the upload.
+		cancel()
+		_ = w.Close()
+		return wrap("Put", err)
+	}
+	return wrap("Put", w.Close())
+}
+
+// Stat implements file.Storage.
+func (s *Storage) Stat(ctx context.Context, uri string) (file.Metadata, error) {
+	obj, err := s.object(uri)
+	if err != nil {
+		return file.Metadata{}, err
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return file.Metadata{}, wrap("Stat", err)
+	}
+	return metadataFor(uri, attrs), nil
+}
+
+// List implements file.Storage, returning every object whose name starts
+// with the URI's object path (gs://bucket/ lists the whole bucket).
+func (s *Storage) List(ctx context.Context, uri string) ([]file.Metadata, error) {
+	bucket, prefix, err := parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	it := s.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	var out []file.Metadata
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, wrap("List", err)
+		}
+		out = append(out, metadataFor(fmt.Sprintf("gs://%s/%s", bucket, attrs.Name), attrs))
+	}
+}
+
+// Delete implements file.Storage.
+func (s *Storage) Delete(ctx context.Context, uri string) error {
+	obj, err := s.object(uri)
+	if err != nil {
+		return err
+	}
+	return wrap("Delete", obj.Delete(ctx))
+}
+
+// SignedURL implements file.SignedURLStorage with a V4 GET URL.
+func (s *Storage) SignedURL(ctx context.Context, uri string, expiresIn time.Duration) (string, error) {
+	bucket, key, err := parse(uri)
+	if err != nil {
+		return "", err
+	}
+	opts := s.SignOptions
+	opts.Method = "GET"
+	opts.Expires = time.Now().Add(expiresIn)
+	if opts.Scheme == storage.SigningSchemeDefault {
+		opts.Scheme = storage.SigningSchemeV4
+	}
+	signed, err := s.client.Bucket(bucket).SignedURL(key, &opts)
+	return signed, wrap("SignedURL", err)
+}
+
+// object resolves uri to an object handle, honoring ?generation=N.
+func (s *Storage) object(uri string) (*storage.ObjectHandle, error) {
+	bucket, key, err := parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, &file.FileError{Sentinel: file.ErrInvalidSource, Op: "gcs", Err: fmt.Errorf("URI %q names a bucket, not an object", uri)}
+	}
+	obj := s.client.Bucket(bucket).Object(key)
+	u, _ := url.Parse(uri)
+	if g := u.Query().Get("generation"); g != "" {
+		gen, err := strconv.ParseInt(g, 10, 64)
+		if err != nil {
+			return nil, &file.FileError{Sentinel: file.ErrInvalidSource, Op: "gcs", Err: fmt.Errorf("bad generation %q", g)}
+		}
+		obj = obj.Generation(gen)
+	}
+	return obj, nil
+}
+
+// parse splits a gs:// URI into bucket and object name.
+func parse(uri string) (bucket, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != Scheme || u.Host == "" {
+		return "", "", &file.FileError{Sentinel: file.ErrInvalidSource, Op: "gcs", Err: fmt.Errorf("want gs://bucket/object, got %q", uri)}
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// metadataFor converts object attributes into file.Metadata.
+func metadataFor(uri string, attrs *storage.ObjectAttrs) file.Metadata {
+	m := file.Metadata{
+		Name:         path.Base(attrs.Name),
+		MimeType:     attrs.ContentType,
+		Size:         attrs.Size,
+		URL:          uri,
+		LastModified: attrs.Updated,
+		CreatedAt:    attrs.Created,
+		VersionID:    strconv.FormatInt(attrs.Generation, 10),
+		Attributes:   make(map[string]string, len(attrs.Metadata)+2),
+	}
+	for k, v := range attrs.Metadata {
+		m.Attributes[k] = v
+	}
+	if len(attrs.MD5) > 0 {
+		m.Hash = hex.EncodeToString(attrs.MD5)
+		m.Attributes[AttrMD5] = m.Hash
+	}
+	m.Attributes[AttrCRC32C] = fmt.Sprintf("%08x", attrs.CRC32C)
+	return m
+}
+
+// wrap maps storage.ErrObjectNotExist and storage.ErrBucketNotExist to
+// file.ErrNotFound, and other failures to file.ErrWrite for mutations or
+// file.ErrRead otherwise.
+func wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	sentinel := file.ErrRead
+	switch {
+	case errors.Is(err, storage.ErrObjectNotExist), errors.Is(err, storage.ErrBucketNotExist):
+		sentinel = file.ErrNotFound
+	case op == "Put" || op == "Delete":
+		sentinel = file.ErrWrite
+	}
+	return &file.FileError{Sentinel: sentinel, Op: "gcs." + op, Err: err}
+}
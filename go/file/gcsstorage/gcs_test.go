@@ -0,0 +1,174 @@
+package gcsstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+func newFakeServer(t *testing.T, objects ...fakestorage.Object) *fakestorage.Server {
+	t.Helper()
+	srv, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		InitialObjects: objects,
+		NoListener:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	srv.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "bucket"})
+	return srv
+}
+
+func TestStorage_NewAcceptsGSURIs(t *testing.T) {
+	srv := newFakeServer(t, fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{
+			BucketName:  "bucket",
+			Name:        "reports/q1.csv",
+			ContentType: "text/csv",
+			Metadata:    map[string]string{"owner": "finance"},
+		},
+		Content: []byte("a,b\n1,2\n"),
+	})
+	Register(New(srv.Client()))
+	defer file.RegisterStorage(Scheme, nil)
+	if !slices.Contains(file.Capabilities(), "storage-gcs") {
+		t.Errorf("Capabilities = %v, want storage-gcs once registered", file.Capabilities())
+	}
+
+	f, err := file.New(context.Background(), "gs://bucket/reports/q1.csv")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if f.Source() != file.SourceStorage {
+		t.Errorf("Source = %s", f.Source())
+	}
+	got, _ := f.ReadText()
+	if got != "a,b\n1,2\n" {
+		t.Errorf("content = %q", got)
+	}
+	m := f.Metadata()
+	if m.Name != "q1.csv" || m.MimeType != "text/csv" || m.Size != 8 {
+		t.Errorf("metadata = %+v", m)
+	}
+	if m.VersionID == "" || m.VersionID == "0" {
+		t.Errorf("VersionID = %q, want the object generation", m.VersionID)
+	}
+	if m.Attributes["owner"] != "finance" || m.Attributes[AttrCRC32C] == "" {
+		t.Errorf("Attributes = %v", m.Attributes)
+	}
+	if m.Hash == "" || m.Attributes[AttrMD5] != m.Hash {
+		t.Errorf("Hash = %q, md5 attr = %q", m.Hash, m.Attributes[AttrMD5])
+	}
+}
+
+func TestStorage_PutStatListDelete(t *testing.T) {
+	srv := newFakeServer(t)
+	s := New(srv.Client())
+	ctx := context.Background()
+
+	for _, name := range []string{"in/a.json", "in/b.json", "other/c.json"} {
+		err := s.Put(ctx, "gs://bucket/"+name, bytes.NewReader([]byte(`{}`)), file.Metadata{
+			MimeType:   "application/json",
+			Attributes: map[string]string{"source": "test"},
+		})
+		if err != nil {
+			t.Fatalf("Put %s: %v", name, err)
+		}
+	}
+
+	meta, err := s.Stat(ctx, "gs://bucket/in/a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.MimeType != "application/json" || meta.Size != 2 || meta.Attributes["source"] != "test" {
+		t.Errorf("Stat = %+v", meta)
+	}
+
+	list, err := s.List(ctx, "gs://bucket/in/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].URL != "gs://bucket/in/a.json" {
+		t.Errorf("List = %+v", list)
+	}
+
+	if err := s.Delete(ctx, "gs://bucket/in/a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat(ctx, "gs://bucket/in/a.json"); !errors.Is(err, file.ErrNotFound) {
+		t.Errorf("Stat after delete: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStorage_UploadToStorageAndExists(t *testing.T) {
+	srv := newFakeServer(t)
+	Register(New(srv.Client()))
+	defer file.RegisterStorage(Scheme, nil)
+	ctx := context.Background()
+
+	src, _ := file.NewFromBytes([]byte("hello gcs"), file.MetadataHint{Name: "hello.txt"})
+	if err := src.UploadToStorage(ctx, "gs://bucket/hello.txt"); err != nil {
+		t.Fatalf("UploadToStorage: %v", err)
+	}
+	f, err := file.NewFromStorage(ctx, "gs://bucket/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := f.Exists(ctx); !ok || err != nil {
+		t.Errorf("Exists = %v, %v", ok, err)
+	}
+	if !strings.HasPrefix(f.MimeType(), "text/plain") {
+		t.Errorf("MimeType = %q", f.MimeType())
+	}
+}
+
+func TestStorage_SignedURL(t *testing.T) {
+	srv := newFakeServer(t, fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "bucket", Name: "doc.pdf"},
+		Content:     []byte("%PDF-1.4"),
+	})
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	s := New(srv.Client())
+	s.SignOptions = storage.SignedURLOptions{GoogleAccessID: "signer@example.iam.gserviceaccount.com", PrivateKey: pemKey}
+	Register(s)
+	defer file.RegisterStorage(Scheme, nil)
+
+	f, err := file.NewFromStorage(context.Background(), "gs://bucket/doc.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := f.GetSignedURL(15 * time.Minute)
+	if err != nil {
+		t.Fatalf("GetSignedURL: %v", err)
+	}
+	if !strings.Contains(signed, "doc.pdf") || !strings.Contains(signed, "X-Goog-Signature=") {
+		t.Errorf("signed URL = %s", signed)
+	}
+}
+
+func TestStorage_MissingObject(t *testing.T) {
+	srv := newFakeServer(t)
+	Register(New(srv.Client()))
+	defer file.RegisterStorage(Scheme, nil)
+
+	_, err := file.NewFromStorage(context.Background(), "gs://bucket/nope")
+	if !errors.Is(err, file.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync/atomic"
+)
+
+// Handle is an open, random-access view of a File's content, for APIs that
+// need an io.ReaderAt or io.Seeker, such as archive/zip.NewReader and
+// image decoders. It is an io.Reader, io.ReaderAt, io.Seeker and io.Closer.
+// ReadAt does not move the offset Read and Seek share and may be called
+// concurrently. Once closed, every method fails with fs.ErrClosed.
+type Handle struct {
+	r interface {
+		io.ReadSeeker
+		io.ReaderAt
+	}
+	size   int64
+	close  func() error
+	closed atomic.Bool
+}
+
+// Open returns a Handle over the file's content. The caller must close it.
+// Each call returns an independent Handle with its own offset.
+//
+// Buffered content is read from the buffer, a NewFromReaderAt File through
+// a section view of its ReaderAt, and a lazy local file (NewFromFileLazy)
+// through its own *os.File. Content that cannot be read in place (a lazy
+// stream, or an S3 or Storage File not yet loaded) is first buffered with
+// Read. Directories fail with ErrInvalidSource.
+func (f *File) Open() (*Handle, error) {
+	return f.openHandle(context.Background(), "Open")
+}
+
+// openHandle implements Open, reporting errors under op. Content not
+// loaded yet is fetched under ctx.
+func (f *File) openHandle(ctx context.Context, op string) (*Handle, error) {
+	switch {
+	case f.dir:
+		return nil, newError(ErrInvalidSource, op, fmt.Errorf("%s is a directory", shownPath(f.meta.Path)))
+	case f.readerAt != nil && !f.loaded:
+		return &Handle{r: io.NewSectionReader(f.readerAt, 0, f.readerAtSize), size: f.readerAtSize}, nil
+	case f.onDisk():
+		fh, err := f.openOnDisk(op)
+		if err != nil {
+			return nil, err
+		}
+		info, err := fh.Stat()
+		if err != nil {
+			fh.Close()
+			return nil, newError(ErrRead, op, err)
+		}
+		return &Handle{r: fh, size: info.Size(), close: fh.Close}, nil
+	}
+	data, err := f.load(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{r: bytes.NewReader(data), size: int64(len(data))}, nil
+}
+
+// Size returns the length of the content, as archive/zip.NewReader takes.
+func (h *Handle) Size() int64 { return h.size }
+
+// Read reads from the current offset, as io.Reader describes.
+func (h *Handle) Read(p []byte) (int, error) {
+	if h.closed.Load() {
+		return 0, fs.ErrClosed
+	}
+	return h.r.Read(p)
+}
+
+// ReadAt reads from offset off without moving the current offset, as
+// io.ReaderAt describes.
+func (h *Handle) ReadAt(p []byte, off int64) (int, error) {
+	if h.closed.Load() {
+		return 0, fs.ErrClosed
+	}
+	return h.r.ReadAt(p, off)
+}
+
+// Seek sets the offset for the next Read, as io.Seeker describes.
+func (h *Handle) Seek(offset int64, whence int) (int64, error) {
+	if h.closed.Load() {
+		return 0, fs.ErrClosed
+	}
+	return h.r.Seek(offset, whence)
+}
+
+// Close releases the handle. Closing it again fails with fs.ErrClosed.
+func (h *Handle) Close() error {
+	if h.closed.Swap(true) {
+		return fs.ErrClosed
+	}
+	if h.close != nil {
+		return h.close()
+	}
+	return nil
+}
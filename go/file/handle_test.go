@@ -0,0 +1,89 @@
+package file
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen_ZipReader(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{"a.txt": "alpha", "dir/b.txt": "bravo"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffered, _ := NewFromBytes(buf.Bytes())
+	lazy, _ := NewFromFileLazy(p)
+	at, _ := NewFromReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	for name, f := range map[string]*File{"buffered": buffered, "lazy file": lazy, "reader at": at} {
+		h, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: Open: %v", name, err)
+		}
+		zr, err := zip.NewReader(h, h.Size())
+		if err != nil {
+			t.Fatalf("%s: zip.NewReader: %v", name, err)
+		}
+		rc, err := zr.Open("dir/b.txt")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != "bravo" {
+			t.Errorf("%s: dir/b.txt = %q", name, got)
+		}
+		if err := h.Close(); err != nil {
+			t.Errorf("%s: Close: %v", name, err)
+		}
+		if _, err := h.ReadAt(make([]byte, 1), 0); !errors.Is(err, fs.ErrClosed) {
+			t.Errorf("%s: ReadAt after Close err = %v, want fs.ErrClosed", name, err)
+		}
+		if f.Loaded() != (name == "buffered") {
+			t.Errorf("%s: Open should not buffer the content", name)
+		}
+	}
+}
+
+func TestOpen_IndependentHandles(t *testing.T) {
+	f, _ := NewFromBytes([]byte("0123456789"))
+	h1, _ := f.Open()
+	h2, _ := f.Open()
+	defer h1.Close()
+	defer h2.Close()
+
+	h1.Seek(6, io.SeekStart)
+	rest, _ := io.ReadAll(h1)
+	head := make([]byte, 3)
+	io.ReadFull(h2, head)
+	if string(rest) != "6789" || string(head) != "012" {
+		t.Errorf("handles share an offset: h1 read %q, h2 read %q", rest, head)
+	}
+	if err := h1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h2.Read(head); err != nil {
+		t.Errorf("closing one handle affected another: %v", err)
+	}
+}
+
+func TestOpen_Directory(t *testing.T) {
+	dir, _ := NewFromFile(t.TempDir())
+	if _, err := dir.Open(); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("err = %v, want ErrInvalidSource", err)
+	}
+}
@@ -0,0 +1,98 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// HandlerFunc processes a File. It has the same shape as the ProcessFiles
+// callback, so HandlerRegistry.Dispatch can be passed to ProcessFiles.
+type HandlerFunc func(ctx context.Context, f *File) error
+
+// HandlerRegistry routes Files to handlers by MIME type. Register handlers
+// with On and Fallback, then call Dispatch. The zero value is ready to use,
+// and a registry is safe for concurrent use.
+//
+//	var reg file.HandlerRegistry
+//	reg.On("image/*", resizeImage)
+//	reg.On("application/pdf", extractText)
+//	reg.Fallback(archiveRaw)
+//	err := reg.Dispatch(ctx, f)
+type HandlerRegistry struct {
+	mu       sync.RWMutex
+	exact    map[string]HandlerFunc // "type/subtype"
+	globs    map[string]HandlerFunc // "type" for "type/*"
+	fallback HandlerFunc            // "*/*" or Fallback
+}
+
+// On registers fn for pattern: an exact media type ("application/pdf"), a
+// type glob ("image/*"), or "*/*" (equivalent to Fallback). Patterns are
+// parsed as media types, so case and parameters are ignored. Registering
+// the same pattern again replaces the earlier handler. On panics if pattern
+// is not a valid media type or fn is nil, like http.ServeMux.Handle.
+func (r *HandlerRegistry) On(pattern string, fn HandlerFunc) {
+	if fn == nil {
+		panic("file: HandlerRegistry.On: nil handler for " + pattern)
+	}
+	mt, _, err := mime.ParseMediaType(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("file: HandlerRegistry.On: invalid pattern %q: %v", pattern, err))
+	}
+	typ, sub, ok := strings.Cut(mt, "/")
+	if !ok || typ == "" || sub == "" || (typ == "*" && sub != "*") {
+		panic(fmt.Sprintf("file: HandlerRegistry.On: invalid pattern %q", pattern))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case typ == "*":
+		r.fallback = fn
+	case sub == "*":
+		if r.globs == nil {
+			r.globs = make(map[string]HandlerFunc)
+		}
+		r.globs[typ] = fn
+	default:
+		if r.exact == nil {
+			r.exact = make(map[string]HandlerFunc)
+		}
+		r.exact[mt] = fn
+	}
+}
+
+// Fallback registers fn for Files no other pattern matches, including Files
+// with no or an unparseable MIME type.
+func (r *HandlerRegistry) Fallback(fn HandlerFunc) {
+	r.On("*/*", fn)
+}
+
+// Dispatch runs the handler that most specifically matches f's MIME type:
+// an exact match beats a type glob, which beats the fallback. It returns
+// the handler's error, or an ErrNoHandler error when nothing matches.
+func (r *HandlerRegistry) Dispatch(ctx context.Context, f *File) error {
+	fn := r.match(f.MimeType())
+	if fn == nil {
+		return newError(ErrNoHandler, "Dispatch", fmt.Errorf("no handler for MIME type %q", f.MimeType()))
+	}
+	return fn(ctx, f)
+}
+
+// match returns the most specific handler for mimeType, or nil.
+func (r *HandlerRegistry) match(mimeType string) HandlerFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if mt, _, err := mime.ParseMediaType(mimeType); err == nil {
+		if fn := r.exact[mt]; fn != nil {
+			return fn
+		}
+		typ, _, _ := strings.Cut(mt, "/")
+		if fn := r.globs[typ]; fn != nil {
+			return fn
+		}
+	}
+	return r.fallback
+}
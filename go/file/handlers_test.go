@@ -0,0 +1,144 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// routeTo returns a handler that records name into *got.
+func routeTo(name string, got *string) HandlerFunc {
+	return func(ctx context.Context, f *File) error {
+		*got = name
+		return nil
+	}
+}
+
+func fileWithMime(t *testing.T, mimeType string) *File {
+	t.Helper()
+	f, err := NewFromBytes([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.meta.MimeType = mimeType
+	return f
+}
+
+func TestHandlerRegistry_Specificity(t *testing.T) {
+	var got string
+	var reg HandlerRegistry
+	reg.Fallback(routeTo("fallback", &got))
+	reg.On("image/*", routeTo("image glob", &got))
+	reg.On("image/png", routeTo("png", &got))
+
+	tests := []struct {
+		mime string
+		want string
+	}{
+		{"image/png", "png"},
+		{"image/jpeg", "image glob"},
+		{"application/pdf", "fallback"},
+		{"", "fallback"},
+		{"not a mime type", "fallback"},
+	}
+	for _, tt := range tests {
+		got = ""
+		if err := reg.Dispatch(context.Background(), fileWithMime(t, tt.mime)); err != nil {
+			t.Fatalf("%q: %v", tt.mime, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q routed to %q, want %q", tt.mime, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerRegistry_GlobAndParameterSemantics(t *testing.T) {
+	var got string
+	var reg HandlerRegistry
+	reg.On("image/*", routeTo("image", &got))
+	reg.On("Text/Plain; charset=utf-8", routeTo("text", &got))
+
+	tests := []struct {
+		mime string
+		want string
+	}{
+		{"IMAGE/SVG+XML", "image"},
+		{"text/plain; charset=iso-8859-1", "text"},
+		{"text/plain", "text"},
+		{"imagex/png", ""},
+		{"application/image", ""},
+		{"text/csv", ""},
+	}
+	for _, tt := range tests {
+		got = ""
+		err := reg.Dispatch(context.Background(), fileWithMime(t, tt.mime))
+		if tt.want == "" {
+			if !errors.Is(err, ErrNoHandler) {
+				t.Errorf("%q: expected ErrNoHandler, got %v (routed to %q)", tt.mime, err, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q routed to %q (err %v), want %q", tt.mime, got, err, tt.want)
+		}
+	}
+}
+
+func TestHandlerRegistry_ReturnsHandlerError(t *testing.T) {
+	boom := errors.New("boom")
+	var reg HandlerRegistry
+	reg.On("application/pdf", func(ctx context.Context, f *File) error { return boom })
+	if err := reg.Dispatch(context.Background(), fileWithMime(t, "application/pdf")); !errors.Is(err, boom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+}
+
+func TestHandlerRegistry_InvalidPatternPanics(t *testing.T) {
+	for _, pattern := range []string{"", "image", "*/png", "image/png;;"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("On(%q) did not panic", pattern)
+				}
+			}()
+			var reg HandlerRegistry
+			reg.On(pattern, func(context.Context, *File) error { return nil })
+		}()
+	}
+}
+
+func TestHandlerRegistry_ConcurrentDispatch(t *testing.T) {
+	var reg HandlerRegistry
+	var mu sync.Mutex
+	counts := map[string]int{}
+	count := func(name string) HandlerFunc {
+		return func(ctx context.Context, f *File) error {
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+			return nil
+		}
+	}
+	reg.On("image/*", count("image"))
+	reg.Fallback(count("fallback"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = reg.Dispatch(context.Background(), fileWithMime(t, "image/gif"))
+		}()
+		go func(i int) {
+			defer wg.Done()
+			// Registering while dispatching must be safe too.
+			reg.On(fmt.Sprintf("application/x-test-%d", i), count("other"))
+		}(i)
+	}
+	wg.Wait()
+	if counts["image"] != 50 || counts["fallback"] != 0 {
+		t.Errorf("counts = %v", counts)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"io"
 	"mime"
 	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"net/textproto"
 	"strings"
 	"testing"
@@ -295,6 +297,26 @@ func TestFileValidationError_ErrorMessages(t *testing.T) {
 
 // --- NewFromMultipartFile ---
 
+// multipartFileHeader returns the *multipart.FileHeader a form upload of
+// data under filename parses to.
+func multipartFileHeader(t *testing.T, filename string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("upload", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["upload"][0]
+}
+
 func TestNewFromMultipartFile_ExtractsFilenameAndContentType(t *testing.T) {
 	// Build a real multipart body and parse it back to get a *FileHeader,
 	// then feed it to NewFromMultipartFile.
@@ -369,6 +391,90 @@ func TestNewFromMultipartFile_HintsOverrideMultipartMetadata(t *testing.T) {
 	}
 }
 
+// TestNewFromMultipartFile_HTTPUpload posts a real multipart form to an
+// httptest server whose handler builds Files from the parsed parts. The
+// small maxMemory makes the large part spill to a temp file.
+func TestNewFromMultipartFile_HTTPUpload(t *testing.T) {
+	photo := append(bytes.Clone(pngBytes), bytes.Repeat([]byte{0}, 8192)...)
+	type result struct {
+		name, mimeType string
+		size           int64
+		data           []byte
+		err            error
+	}
+	results := map[string]result{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1024); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		for field, fhs := range r.MultipartForm.File {
+			f, err := NewFromMultipartFile(fhs[0])
+			if err != nil {
+				results[field] = result{err: err}
+				continue
+			}
+			data, _ := f.Read()
+			results[field] = result{f.Name(), f.MimeType(), f.Size(), data, nil}
+		}
+	}))
+	defer srv.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, p := range []struct {
+		field, filename, contentType string
+		data                         []byte
+	}{
+		{"photo", "avatar.bin", "application/octet-stream", photo},
+		{"notes", "notes.txt", "text/plain", []byte("hello")},
+	} {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, p.field, p.filename))
+		header.Set("Content-Type", p.contentType)
+		part, _ := writer.CreatePart(header)
+		part.Write(p.data)
+	}
+	writer.Close()
+	resp, err := http.Post(srv.URL, writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got := results["photo"]
+	if got.err != nil || got.name != "avatar.bin" || got.mimeType != "image/png" || got.size != int64(len(photo)) {
+		t.Errorf("photo = %q %q %d (err %v), want avatar.bin image/png %d", got.name, got.mimeType, got.size, got.err, len(photo))
+	}
+	if !bytes.Equal(got.data, photo) {
+		t.Error("photo bytes do not round-trip")
+	}
+	got = results["notes"]
+	if got.err != nil || got.name != "notes.txt" || !strings.HasPrefix(got.mimeType, "text/plain") || got.size != 5 {
+		t.Errorf("notes = %q %q %d (err %v)", got.name, got.mimeType, got.size, got.err)
+	}
+}
+
+func TestNewFromMultipartFile_OpenErrorIsErrRead(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("upload", "big.bin")
+	part.Write(bytes.Repeat([]byte("x"), 4096))
+	writer.Close()
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh := form.File["upload"][0]
+	form.RemoveAll() // the part was spilled to disk; its temp file is now gone
+
+	if _, err := NewFromMultipartFile(fh); !errors.Is(err, ErrRead) {
+		t.Fatalf("err = %v, want ErrRead", err)
+	}
+}
+
 // --- ToFormData ---
 
 func TestToFormData_RoundTripsViaMultipartReader(t *testing.T) {
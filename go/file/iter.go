@@ -0,0 +1,201 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultChunkSize is the chunk size Chunks uses when given a non-positive
+// size. It matches IterBytes.
+const defaultChunkSize = 64 * 1024
+
+// Chunks returns an iterator over the content in chunks of size bytes (64
+// KiB when size <= 0); only the last chunk may be shorter. Content is
+// streamed as by Pipe, so a lazy stream is consumed and never buffered
+// whole, and the underlying file or stream is closed when the loop ends,
+// early or not.
+//
+// The yielded slice is reused: it is only valid until the next iteration,
+// so copy it to keep it. Breaking out of the loop stops reading
+// immediately. A read error is yielded once, with a nil chunk, and ends
+// the sequence.
+func (f *File) Chunks(size int) iter.Seq2[[]byte, error] {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	return func(yield func([]byte, error) bool) {
+		r, err := f.openStream(context.Background())
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+		buf := make([]byte, size)
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 && !yield(buf[:n], nil) {
+				return
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return
+			}
+			if err != nil {
+				yield(nil, newError(ErrRead, "Chunks", err))
+				return
+			}
+		}
+	}
+}
+
+// Lines returns an iterator over the content's lines, without their "\n"
+// or "\r\n" terminators. A final line without a terminator is still
+// yielded. Lines have no length limit. Like Chunks, it streams the content
+// and stops reading as soon as the loop breaks.
+func (f *File) Lines() iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		r, err := f.openStream(context.Background())
+		if err != nil {
+			yield("", err)
+			return
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if len(line) > 0 && (err == nil || err == io.EOF) {
+				line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+				if !yield(line, nil) {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield("", newError(ErrRead, "Lines", err))
+				return
+			}
+		}
+	}
+}
+
+// NewFromDirIter returns an iterator over the regular files beneath dir, in
+// lexical order, each read as by NewFromFile. Files are only read as the
+// loop reaches them, and breaking out of the loop ends the directory walk.
+// An error for one file is yielded with a nil File and the walk goes on if
+// the loop continues; a dir that is missing or not a directory yields a
+// single error. With WithJournal, files the journal has marked done are
+// skipped.
+func NewFromDirIter(dir string, opts ...DirOption) iter.Seq2[*File, error] {
+	return func(yield func(*File, error) bool) {
+		o := newDirOptions(opts)
+		if err := checkOptions("NewFromDirIter", o.validate()); err != nil {
+			yield(nil, err)
+			return
+		}
+		root, err := NewFromFile(dir)
+		if err == nil && !root.IsDir() {
+			err = newError(ErrInvalidSource, "NewFromDirIter", errors.New(dir+" is not a directory"))
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// An unreadable subdirectory is skipped.
+				if !yield(nil, newError(ErrRead, "NewFromDirIter", err)) {
+					return fs.SkipAll
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			f, err := NewFromFile(p)
+			if err == nil && f.IsDir() {
+				// A symlink to a directory is not followed.
+				return nil
+			}
+			if err == nil && o.journal != nil {
+				if done, _ := o.journal.Done(f); done {
+					return nil
+				}
+			}
+			if !yield(f, err) {
+				return fs.SkipAll
+			}
+			return nil
+		})
+	}
+}
+
+// ListS3PrefixIter returns an iterator over the objects under prefix in
+// bucket, each downloaded with NewFromS3WithContext (opts apply to every
+// object). Listing is paginated on demand: a page is only requested once
+// the loop has consumed the previous one, and each object is downloaded
+// only when the loop reaches it, so breaking out early costs neither the
+// rest of the listing nor any further downloads. Keys ending in "/"
+// (folder markers) are skipped.
+//
+// A failed download is yielded with a nil File and the listing goes on if
+// the loop continues; a failed listing request is yielded and ends the
+// sequence.
+func ListS3PrefixIter(ctx context.Context, bucket, prefix string, opts ...ReadOption) iter.Seq2[*File, error] {
+	return func(yield func(*File, error) bool) {
+		prefix, err := checkS3Prefix("ListS3PrefixIter", bucket, prefix)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		s3Client, _ := s3Clients()
+		input := &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: nilIfEmpty(prefix),
+		}
+		for {
+			page, err := listS3Page(ctx, s3Client, input, "ListS3PrefixIter")
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, obj := range page.Contents {
+				key := aws.ToString(obj.Key)
+				if strings.HasSuffix(key, "/") {
+					continue
+				}
+				if !yield(NewFromS3WithContext(ctx, bucket, key, opts...)) {
+					return
+				}
+			}
+			if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+				return
+			}
+			input.ContinuationToken = page.NextContinuationToken
+		}
+	}
+}
+
+// listS3Page fetches one ListObjectsV2 page under MetadataTimeout.
+func listS3Page(ctx context.Context, s3Client S3API, input *s3.ListObjectsV2Input, op string) (*s3.ListObjectsV2Output, error) {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+	page, err := s3Client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, op, err)
+	}
+	return page, nil
+}
@@ -0,0 +1,300 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// countingSource counts how many bytes have been pulled from it.
+type countingSource struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingSource) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestFile_Chunks(t *testing.T) {
+	content := []byte("0123456789abcdefghij") // 20 bytes
+	f, _ := NewFromBytes(content)
+
+	var (
+		got   []string
+		first *byte
+	)
+	for chunk, err := range f.Chunks(8) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = &chunk[0]
+		} else if &chunk[0] != first {
+			t.Error("chunk buffer was not reused")
+		}
+		got = append(got, string(chunk))
+	}
+	if want := []string{"01234567", "89abcdef", "ghij"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+}
+
+func TestFile_Chunks_BufferReuseContract(t *testing.T) {
+	f, _ := NewFromBytes([]byte("aaaabbbb"))
+
+	// Holding on to a chunk without copying sees it overwritten.
+	var kept, copied [][]byte
+	for chunk, err := range f.Chunks(4) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, chunk)
+		copied = append(copied, bytes.Clone(chunk))
+	}
+	if string(kept[0]) != "bbbb" {
+		t.Errorf("kept[0] = %q, want it overwritten with the next chunk", kept[0])
+	}
+	if string(copied[0]) != "aaaa" || string(copied[1]) != "bbbb" {
+		t.Errorf("copied = %q", copied)
+	}
+}
+
+func TestFile_Chunks_EarlyBreakStopsReading(t *testing.T) {
+	src := &countingSource{r: bytes.NewReader(bytes.Repeat([]byte("x"), 1<<20))}
+	f, err := NewFromStreamLazy(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := src.n
+
+	for _, err := range f.Chunks(1024) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	// Only what was needed for the first chunk (plus the lazy head) may
+	// have been pulled from the source.
+	if pulled := src.n - before; pulled > 64*1024 {
+		t.Errorf("pulled %d bytes after breaking on the first chunk", pulled)
+	}
+}
+
+func TestFile_Chunks_Error(t *testing.T) {
+	dir, _ := NewFromFile(t.TempDir())
+	var errs []error
+	for chunk, err := range dir.Chunks(0) {
+		if chunk != nil {
+			t.Errorf("chunk = %q, want nil", chunk)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrInvalidSource) {
+		t.Errorf("errs = %v, want one ErrInvalidSource", errs)
+	}
+}
+
+func TestFile_Lines(t *testing.T) {
+	f, _ := NewFromBytes([]byte("alpha\r\nbeta\n\ngamma"))
+	var got []string
+	for line, err := range f.Lines() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, line)
+	}
+	if want := []string{"alpha", "beta", "", "gamma"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+
+	t.Run("early break", func(t *testing.T) {
+		src := &countingSource{r: strings.NewReader("first\n" + strings.Repeat("y", 1<<20) + "\n")}
+		f, _ := NewFromStreamLazy(src)
+		for line, err := range f.Lines() {
+			if err != nil || line != "first" {
+				t.Fatalf("line = %q, err = %v", line, err)
+			}
+			break
+		}
+		if src.n >= 1<<20 {
+			t.Errorf("read %d bytes; the long second line should not have been consumed", src.n)
+		}
+	})
+}
+
+func TestNewFromDirIter(t *testing.T) {
+	root := dirFixture(t)
+
+	var got []string
+	for f, err := range NewFromDirIter(root) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(root, f.Path())
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := []string{"a.txt", "sub/b.json", "sub/deep/c.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+
+	t.Run("early break", func(t *testing.T) {
+		n := 0
+		for range NewFromDirIter(root) {
+			n++
+			break
+		}
+		if n != 1 {
+			t.Errorf("iterations = %d", n)
+		}
+	})
+
+	t.Run("unreadable entry", func(t *testing.T) {
+		// A dangling symlink cannot be opened; the walk reports it and
+		// carries on.
+		if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "sub", "broken.txt")); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+		defer os.Remove(filepath.Join(root, "sub", "broken.txt"))
+		var (
+			files []string
+			errs  int
+		)
+		for f, err := range NewFromDirIter(root) {
+			if err != nil {
+				errs++
+				continue
+			}
+			files = append(files, f.Name())
+		}
+		if errs != 1 || len(files) != 3 {
+			t.Errorf("files = %v, errors = %d; want 3 files and 1 error", files, errs)
+		}
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "f.txt")
+		_ = os.WriteFile(p, []byte("x"), 0o644)
+		for f, err := range NewFromDirIter(p) {
+			if f != nil || !errors.Is(err, ErrInvalidSource) {
+				t.Errorf("got %v, %v", f, err)
+			}
+		}
+	})
+}
+
+func TestListS3PrefixIter(t *testing.T) {
+	pages := [][]string{{"logs/", "logs/1.txt", "logs/2.txt"}, {"logs/3.txt"}}
+	var listed, fetched []string
+	mock := &mockS3Client{
+		listObjectsV2Fn: func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			if aws.ToString(params.Prefix) != "logs/" {
+				t.Errorf("Prefix = %q", aws.ToString(params.Prefix))
+			}
+			page := 0
+			if params.ContinuationToken != nil {
+				fmt.Sscanf(*params.ContinuationToken, "page-%d", &page)
+			}
+			listed = append(listed, fmt.Sprint(page))
+			out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(page+1 < len(pages))}
+			for _, k := range pages[page] {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(k)})
+			}
+			if page+1 < len(pages) {
+				out.NextContinuationToken = aws.String(fmt.Sprintf("page-%d", page+1))
+			}
+			return out, nil
+		},
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			key := aws.ToString(params.Key)
+			fetched = append(fetched, key)
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("body of " + key))}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	var keys []string
+	for f, err := range ListS3PrefixIter(context.Background(), "bucket", "logs/") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := f.ReadText()
+		keys = append(keys, text)
+	}
+	if want := []string{"body of logs/1.txt", "body of logs/2.txt", "body of logs/3.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("files = %v, want %v", keys, want)
+	}
+
+	t.Run("early break stops pagination and downloads", func(t *testing.T) {
+		listed, fetched = nil, nil
+		for _, err := range ListS3PrefixIter(context.Background(), "bucket", "logs/") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if !reflect.DeepEqual(listed, []string{"0"}) {
+			t.Errorf("pages listed = %v, want only the first", listed)
+		}
+		if !reflect.DeepEqual(fetched, []string{"logs/1.txt"}) {
+			t.Errorf("objects fetched = %v, want only the first", fetched)
+		}
+	})
+
+	t.Run("listing error ends the sequence", func(t *testing.T) {
+		mock.listObjectsV2Fn = func(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return nil, errors.New("access denied")
+		}
+		n := 0
+		for f, err := range ListS3PrefixIter(context.Background(), "bucket", "logs/") {
+			n++
+			if f != nil || !errors.Is(err, ErrS3) {
+				t.Errorf("got %v, %v", f, err)
+			}
+		}
+		if n != 1 {
+			t.Errorf("iterations = %d, want 1", n)
+		}
+	})
+}
+
+func TestFile_ChunksAndLines_CloseOnEarlyBreak(t *testing.T) {
+	content := strings.Repeat("line\n", 50000)
+	for name, loop := range map[string]func(*File){
+		"Chunks": func(f *File) {
+			for range f.Chunks(16) {
+				break
+			}
+		},
+		"Lines": func(f *File) {
+			for range f.Lines() {
+				break
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			src := &closeTracker{Reader: strings.NewReader(content)}
+			f, err := NewFromStreamLazy(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loop(f)
+			if !src.closed {
+				t.Error("stream tail not closed after break")
+			}
+		})
+	}
+}
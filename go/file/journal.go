@@ -0,0 +1,381 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JournalFingerprint selects how a Journal recognizes a file it has seen.
+type JournalFingerprint int
+
+const (
+	// FingerprintStat identifies a file by its path (or URL, or name), size
+	// and modification time, so a file that changes is processed again.
+	// It needs no content.
+	FingerprintStat JournalFingerprint = iota
+	// FingerprintChecksum identifies a file by the SHA-256 of its content,
+	// so a copy or rename of a processed file is not processed again.
+	FingerprintChecksum
+)
+
+// defaultJournalCompactEvery is how many entries a Journal appends between
+// compactions unless WithCompactEvery says otherwise.
+const defaultJournalCompactEvery = 1000
+
+// JournalOption configures NewJournal.
+type JournalOption func(*journalOptions)
+
+func (JournalOption) isOption() {}
+
+// journalOptions is the resolved set of JournalOption values.
+type journalOptions struct {
+	optionErrors
+	fingerprint  JournalFingerprint
+	compactEvery int
+}
+
+// newJournalOptions applies opts over the defaults.
+func newJournalOptions(opts []JournalOption) journalOptions {
+	o := journalOptions{compactEvery: defaultJournalCompactEvery}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values.
+func (o journalOptions) validate() error {
+	return errors.Join(o.errs...)
+}
+
+// WithFingerprint selects how the Journal identifies files. The default is
+// FingerprintStat. A journal file should always be opened with the same
+// fingerprint; entries of the other kind never match.
+func WithFingerprint(fp JournalFingerprint) JournalOption {
+	return func(o *journalOptions) {
+		if fp != FingerprintStat && fp != FingerprintChecksum {
+			o.reject("WithFingerprint", "unknown fingerprint %d", fp)
+			return
+		}
+		o.fingerprint = fp
+	}
+}
+
+// WithCompactEvery makes the Journal compact its file after every n
+// appended entries (default 1000). Zero disables periodic compaction; a
+// negative n is rejected.
+func WithCompactEvery(n int) JournalOption {
+	return func(o *journalOptions) {
+		if n < 0 {
+			o.reject("WithCompactEvery", "negative count %d", n)
+			return
+		}
+		o.compactEvery = n
+	}
+}
+
+// DirOption configures NewFromDirIter.
+type DirOption func(*dirOptions)
+
+func (DirOption) isOption() {}
+
+// dirOptions is the resolved set of DirOption values.
+type dirOptions struct {
+	optionErrors
+	journal *Journal
+}
+
+// newDirOptions applies opts over the defaults.
+func newDirOptions(opts []DirOption) dirOptions {
+	var o dirOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values.
+func (o dirOptions) validate() error {
+	return errors.Join(o.errs...)
+}
+
+// WithJournal makes NewFromDirIter skip the files j has marked done, so an
+// ingestion loop that calls j.MarkDone after handling each file resumes
+// where it left off after a restart.
+func WithJournal(j *Journal) DirOption {
+	return func(o *dirOptions) {
+		if j == nil {
+			o.reject("WithJournal", "nil journal")
+			return
+		}
+		o.journal = j
+	}
+}
+
+// Journal remembers which files have been processed, across restarts, so
+// an ingestion loop handles each file once. Entries are appended to a file,
+// one JSON line each, and fsync'd before MarkDone returns; the file is
+// rewritten without duplicates or damaged lines when it is opened with
+// any, and every WithCompactEvery appends. A Journal is safe for
+// concurrent use; a journal file must not be shared between processes.
+type Journal struct {
+	mu       sync.Mutex
+	path     string
+	o        journalOptions
+	done     map[string]time.Time // fingerprint -> when it was marked done
+	order    []string             // fingerprints in the order they were marked done
+	fh       *os.File
+	appended int
+	corrupt  int
+}
+
+// journalEntry is one line of a journal file.
+type journalEntry struct {
+	Fingerprint string    `json:"fp"`
+	At          time.Time `json:"at"`
+}
+
+// NewJournal opens the journal at path, creating it if it does not exist.
+// Lines that cannot be parsed, such as one torn by a crash mid-append, are
+// skipped rather than failing the open; Corrupt reports how many. Close the
+// Journal when done.
+func NewJournal(path string, opts ...JournalOption) (*Journal, error) {
+	o := newJournalOptions(opts)
+	if err := checkOptions("NewJournal", o.validate()); err != nil {
+		return nil, err
+	}
+	j := &Journal{path: path, o: o, done: map[string]time.Time{}}
+
+	lines, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	if lines > len(j.done) {
+		if err := j.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	fh, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, newError(ErrWrite, "NewJournal", err)
+	}
+	j.fh = fh
+	return j, nil
+}
+
+// load reads the journal file into j.done and returns how many lines it
+// held.
+func (j *Journal) load() (int, error) {
+	fh, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, newError(ErrRead, "NewJournal", err)
+	}
+	defer fh.Close()
+
+	var lines int
+	sc := bufio.NewScanner(fh)
+	sc.Buffer(make([]byte, 0, 4096), 1<<20)
+	for sc.Scan() {
+		lines++
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.Fingerprint == "" {
+			j.corrupt++
+			continue
+		}
+		j.add(e.Fingerprint, e.At)
+	}
+	if err := sc.Err(); err != nil {
+		return 0, newError(ErrRead, "NewJournal", err)
+	}
+	return lines, nil
+}
+
+// add records fp as done at at.
+func (j *Journal) add(fp string, at time.Time) {
+	if _, ok := j.done[fp]; !ok {
+		j.done[fp] = at
+		j.order = append(j.order, fp)
+	}
+}
+
+// Filter returns the files the journal has not seen, in order. Files
+// whose fingerprint cannot be computed are kept, so they are not silently
+// dropped; processing will surface their error.
+func (j *Journal) Filter(files []*File) []*File {
+	var out []*File
+	for _, f := range files {
+		if done, _ := j.Done(f); !done {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Done reports whether f has been marked done.
+func (j *Journal) Done(f *File) (bool, error) {
+	fp, err := j.fingerprint(f)
+	if err != nil {
+		return false, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.done[fp]
+	return ok, nil
+}
+
+// MarkDone records that f has been processed. The entry is durable when
+// MarkDone returns. Marking a file twice is a no-op.
+func (j *Journal) MarkDone(f *File) error {
+	fp, err := j.fingerprint(f)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.fh == nil {
+		return newError(ErrWrite, "MarkDone", errors.New("journal is closed"))
+	}
+	if _, ok := j.done[fp]; ok {
+		return nil
+	}
+	at := time.Now().UTC()
+	line, err := json.Marshal(journalEntry{Fingerprint: fp, At: at})
+	if err != nil {
+		return newError(ErrWrite, "MarkDone", err)
+	}
+	if _, err := j.fh.Write(append(line, '\n')); err != nil {
+		return newError(ErrWrite, "MarkDone", err)
+	}
+	if err := j.fh.Sync(); err != nil {
+		return newError(ErrWrite, "MarkDone", err)
+	}
+	j.add(fp, at)
+	j.appended++
+	if j.o.compactEvery > 0 && j.appended >= j.o.compactEvery {
+		return j.compactLocked()
+	}
+	return nil
+}
+
+// Compact rewrites the journal file with one line per processed file.
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.fh == nil {
+		return newError(ErrWrite, "Compact", errors.New("journal is closed"))
+	}
+	return j.compactLocked()
+}
+
+// compactLocked is Compact with j.mu held.
+func (j *Journal) compactLocked() error {
+	if err := j.rewrite(); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return newError(ErrWrite, "Compact", err)
+	}
+	_ = j.fh.Close()
+	j.fh = fh
+	j.appended = 0
+	return nil
+}
+
+// rewrite replaces the journal file with one line per fingerprint, in the
+// order they were marked done, through a temp file renamed over it.
+func (j *Journal) rewrite() error {
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), ".journal-*")
+	if err != nil {
+		return newError(ErrWrite, "Compact", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, fp := range j.order {
+		line, _ := json.Marshal(journalEntry{Fingerprint: fp, At: j.done[fp]})
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return newError(ErrWrite, "Compact", err)
+	}
+	return nil
+}
+
+// Corrupt returns how many lines NewJournal skipped because they could not
+// be parsed.
+func (j *Journal) Corrupt() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.corrupt
+}
+
+// Len returns how many files the journal has marked done.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.done)
+}
+
+// Close closes the journal file. Closing twice is a no-op.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.fh == nil {
+		return nil
+	}
+	err := j.fh.Close()
+	j.fh = nil
+	if err != nil {
+		return newError(ErrWrite, "Close", err)
+	}
+	return nil
+}
+
+// fingerprint identifies f under the journal's FingerprintStat or
+// FingerprintChecksum scheme.
+func (j *Journal) fingerprint(f *File) (string, error) {
+	if j.o.fingerprint == FingerprintChecksum {
+		sum, err := f.Checksum()
+		if err != nil {
+			return "", err
+		}
+		return "sha256:" + sum, nil
+	}
+	id := f.meta.Path
+	if id == "" {
+		id = f.meta.URL
+	}
+	if id == "" {
+		id = f.meta.Name
+	}
+	if id == "" {
+		return "", newError(ErrInvalidSource, "Journal", fmt.Errorf("file has no path, URL or name to fingerprint"))
+	}
+	return "stat:" + strconv.Quote(id) + ":" + strconv.FormatInt(f.Size(), 10) + ":" +
+		strconv.FormatInt(f.meta.LastModified.UnixNano(), 10), nil
+}
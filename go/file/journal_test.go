@@ -0,0 +1,211 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// journalFiles writes n files into a fresh directory and returns them.
+func journalFiles(t *testing.T, n int) []*File {
+	t.Helper()
+	dir := t.TempDir()
+	files := make([]*File, n)
+	for i := range files {
+		p := filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(p, []byte(strings.Repeat("x", i+1)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := NewFromFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[i] = f
+	}
+	return files
+}
+
+func TestJournal_ResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ingest.journal")
+	files := journalFiles(t, 4)
+
+	j, err := NewJournal(path)
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	if got := j.Filter(files); len(got) != 4 {
+		t.Fatalf("fresh journal filtered to %d files, want 4", len(got))
+	}
+	for _, f := range files[:2] {
+		if err := j.MarkDone(f); err != nil {
+			t.Fatalf("MarkDone: %v", err)
+		}
+	}
+	if err := j.MarkDone(files[0]); err != nil {
+		t.Fatalf("second MarkDone: %v", err)
+	}
+	j.Close()
+
+	j, err = NewJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	got := j.Filter(files)
+	if len(got) != 2 || got[0] != files[2] || got[1] != files[3] {
+		t.Errorf("after restart Filter = %v, want the last two files in order", got)
+	}
+	if j.Len() != 2 || j.Corrupt() != 0 {
+		t.Errorf("Len() = %d, Corrupt() = %d, want 2 and 0", j.Len(), j.Corrupt())
+	}
+
+	// A changed file is no longer the file that was processed.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(files[0].Path(), future, future); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := NewFromFile(files[0].Path())
+	if done, _ := j.Done(changed); done {
+		t.Error("a modified file should not count as done")
+	}
+}
+
+func TestJournal_ChecksumFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ingest.journal")
+	j, err := NewJournal(path, WithFingerprint(FingerprintChecksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	a, _ := NewFromBytes([]byte("same content"), MetadataHint{Name: "a.txt"})
+	b, _ := NewFromBytes([]byte("same content"), MetadataHint{Name: "renamed.txt"})
+	if err := j.MarkDone(a); err != nil {
+		t.Fatal(err)
+	}
+	if done, _ := j.Done(b); !done {
+		t.Error("identical content under another name should count as done")
+	}
+}
+
+func TestJournal_ToleratesCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ingest.journal")
+	files := journalFiles(t, 3)
+
+	j, err := NewJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files[:2] {
+		if err := j.MarkDone(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j.Close()
+
+	// A garbage line in the middle and a line torn by a crash at the end.
+	data, _ := os.ReadFile(path)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	corrupted := append(append(append([]byte{}, lines[0]...), "not json\n"...), lines[1]...)
+	corrupted = append(corrupted, `{"fp":"stat:\"/tmp/x`...)
+	if err := os.WriteFile(path, corrupted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = NewJournal(path)
+	if err != nil {
+		t.Fatalf("NewJournal on a corrupted journal: %v", err)
+	}
+	defer j.Close()
+	if j.Corrupt() != 2 {
+		t.Errorf("Corrupt() = %d, want 2", j.Corrupt())
+	}
+	if got := j.Filter(files); len(got) != 1 || got[0] != files[2] {
+		t.Errorf("Filter = %v, want only the unprocessed file", got)
+	}
+	data, _ = os.ReadFile(path)
+	if n := bytes.Count(data, []byte("\n")); n != 2 || bytes.Contains(data, []byte("not json")) {
+		t.Errorf("opening should compact away damaged lines, file is:\n%s", data)
+	}
+	if err := j.MarkDone(files[2]); err != nil {
+		t.Fatalf("MarkDone after recovery: %v", err)
+	}
+}
+
+func TestJournal_Compaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ingest.journal")
+	files := journalFiles(t, 5)
+
+	j, err := NewJournal(path, WithCompactEvery(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	for _, f := range files {
+		if err := j.MarkDone(f); err != nil {
+			t.Fatalf("MarkDone: %v", err)
+		}
+	}
+	// The append handle must follow the file compaction renamed into place.
+	data, _ := os.ReadFile(path)
+	if n := bytes.Count(data, []byte("\n")); n != 5 {
+		t.Errorf("journal has %d lines after compactions, want 5:\n%s", n, data)
+	}
+	if err := j.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	j2, err := NewJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j2.Close()
+	if j2.Len() != 5 || len(j2.Filter(files)) != 0 {
+		t.Errorf("reopened journal has %d entries", j2.Len())
+	}
+
+	if _, err := NewJournal(path, WithCompactEvery(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("negative WithCompactEvery: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestNewFromDirIter_WithJournal(t *testing.T) {
+	dir := dirFixture(t)
+	j, err := NewJournal(filepath.Join(t.TempDir(), "ingest.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	var first []string
+	for f, err := range NewFromDirIter(dir, WithJournal(j)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		first = append(first, f.Name())
+		if f.Name() == "a.txt" {
+			if err := j.MarkDone(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var second []string
+	for f, err := range NewFromDirIter(dir, WithJournal(j)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		second = append(second, f.Name())
+	}
+	if len(second) != len(first)-1 || strings.Contains(strings.Join(second, ","), "a.txt") {
+		t.Errorf("second pass = %v, want %v without a.txt", second, first)
+	}
+
+	for _, err := range NewFromDirIter(dir, WithJournal(nil)) {
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("nil journal: err = %v, want ErrInvalidOption", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// NewFromFileLazy creates a File from a local filesystem path without
+// reading its content. The file is stat'ed and only its first few KB are
+// read for magic-byte detection, so metadata resolves exactly as with
+// NewFromFile while memory use stays flat for files of any size.
+//
+// Read (and EnsureLoaded) buffers the whole file on first use and caches
+// it. Until then, Save, Checksum, UploadToS3 and the other streaming
+// operations read the file from disk each time instead of buffering it, so
+// it must stay in place, unmodified, while the File is in use. Loaded
+// reports false until the content is buffered. A directory yields a
+// directory-mode File, as with NewFromFile.
+func NewFromFileLazy(filePath string, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromFileLazy", ro.validate()); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, newError(ErrNotFound, "NewFromFileLazy", err)
+		}
+		return nil, newError(ErrRead, "NewFromFileLazy", err)
+	}
+	if info.IsDir() {
+		return newDirFile(filePath, info, hint)
+	}
+
+	head, err := readFileHead(filePath, min(info.Size(), streamHeadBytes))
+	if err != nil {
+		return nil, newError(ErrRead, "NewFromFileLazy", err)
+	}
+
+	return withProvenance(ro.normalizeName(&File{
+		source: SourceFile,
+		meta:   resolveMetadataFromFile(filePath, info, head, hint),
+	})), nil
+}
+
+// readFileHead reads up to n bytes from the start of the file at path.
+func readFileHead(path string, n int64) ([]byte, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	head := make([]byte, n)
+	m, err := io.ReadFull(fh, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:m], nil
+}
+
+// onDisk reports whether f's content lives in a local file that has not
+// been buffered, as for NewFromFileLazy, so it should be streamed from
+// there.
+func (f *File) onDisk() bool {
+	return f.source == SourceFile && !f.loaded && !f.dir && f.meta.Path != ""
+}
+
+// openOnDisk opens the file behind an onDisk File, reporting errors under
+// op.
+func (f *File) openOnDisk(op string) (*os.File, error) {
+	fh, err := os.Open(f.meta.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, newError(ErrNotFound, op, err)
+		}
+		return nil, newError(ErrRead, op, err)
+	}
+	return fh, nil
+}
+
+// closeAtEOF reads a file and closes it once the content is exhausted or a
+// read fails. Readers that stop early leave the file for its finalizer.
+type closeAtEOF struct {
+	fh  *os.File
+	err error
+}
+
+func (r *closeAtEOF) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.fh.Read(p)
+	if err != nil {
+		r.err = err
+		_ = r.fh.Close()
+	}
+	return n, err
+}
+
+// Close closes the file early, for readers abandoned before EOF.
+func (r *closeAtEOF) Close() error {
+	if r.err != nil {
+		return nil
+	}
+	r.err = os.ErrClosed
+	return r.fh.Close()
+}
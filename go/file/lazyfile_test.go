@@ -0,0 +1,191 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestNewFromFileLazy(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "icon.png")
+	payload := append(append([]byte{}, pngBytes...), generateRandomBytes(t, 200*1024)...)
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatalf("NewFromFileLazy: %v", err)
+	}
+	eager, err := NewFromFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Metadata(), eager.Metadata(); got.MimeType != want.MimeType ||
+		got.Extension != want.Extension || got.Size != want.Size || got.Name != want.Name {
+		t.Errorf("metadata = %+v, want it to match NewFromFile's %+v", got, want)
+	}
+	if f.Loaded() || f.data != nil {
+		t.Fatal("content should not be buffered at construction")
+	}
+
+	sum := sha256.Sum256(payload)
+	got, err := f.Checksum()
+	if err != nil {
+		t.Fatalf("Checksum: %v", err)
+	}
+	if got != hex.EncodeToString(sum[:]) {
+		t.Error("Checksum mismatch")
+	}
+
+	saved, err := f.Save(filepath.Join(t.TempDir(), "copy.png"))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if f.data != nil {
+		t.Error("Checksum and Save should stream without buffering")
+	}
+	if saved.Loaded() {
+		t.Error("a lazy File should save to a lazy File")
+	}
+	if written, _ := os.ReadFile(saved.Path()); !bytes.Equal(written, payload) {
+		t.Error("saved content mismatch")
+	}
+
+	data, err := f.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(data, payload) || !f.Loaded() {
+		t.Error("Read should load and cache the content")
+	}
+}
+
+func TestNewFromFileLazy_Errors(t *testing.T) {
+	if _, err := NewFromFileLazy(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing file: err = %v, want ErrNotFound", err)
+	}
+
+	p := filepath.Join(t.TempDir(), "gone.txt")
+	if err := os.WriteFile(p, []byte("soon gone"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(p)
+	if _, err := f.Checksum(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Checksum after removal: err = %v, want ErrNotFound", err)
+	}
+	if _, err := f.Read(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read after removal: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewFromFileLazy_IterBytes(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10_000)
+	p := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(p, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	chunks, errc := f.IterBytes(context.Background())
+	for c := range chunks {
+		got = append(got, c...)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("IterBytes: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("IterBytes yielded %d bytes, want %d", len(got), len(content))
+	}
+	if f.Loaded() {
+		t.Error("IterBytes loaded the file into memory")
+	}
+
+	os.Remove(p)
+	chunks, errc = f.IterBytes(context.Background())
+	for range chunks {
+	}
+	if err := <-errc; !errors.Is(err, ErrNotFound) {
+		t.Errorf("IterBytes after removal: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewFromFileLazy_LargeFileStaysSmall(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "big.bin")
+	fh, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sparse: no disk blocks are written.
+	if err := fh.Truncate(2 << 30); err != nil {
+		fh.Close()
+		t.Skipf("cannot create sparse file: %v", err)
+	}
+	fh.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f, err := NewFromFileLazy(p)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("NewFromFileLazy: %v", err)
+	}
+	if f.Size() != 2<<30 {
+		t.Errorf("Size() = %d, want %d", f.Size(), int64(2<<30))
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Errorf("constructing allocated %d bytes, want well under 1 MiB", alloc)
+	}
+}
+
+func TestNewFromFileLazy_UploadToS3Streams(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "doc.txt")
+	payload := bytes.Repeat([]byte("lazy upload\n"), 10000)
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uploaded []byte
+	var seekable bool
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			_, seekable = in.Body.(io.Seeker)
+			uploaded, _ = io.ReadAll(in.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	if err := f.UploadToS3("bucket", "doc.txt"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if !bytes.Equal(uploaded, payload) {
+		t.Error("uploaded content mismatch")
+	}
+	if !seekable {
+		t.Error("upload body should be seekable so PutObject can retry")
+	}
+	if f.Loaded() {
+		t.Error("UploadToS3 should stream without buffering")
+	}
+}
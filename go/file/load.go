@@ -0,0 +1,210 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// missingSourceTTL is how long a File remembers that its source was
+// missing. Read in a retry loop then fails fast with the same ErrNotFound
+// instead of hitting the filesystem or S3 on every call.
+const missingSourceTTL = 2 * time.Second
+
+// loadNow is the clock behind missingSourceTTL; tests replace it.
+var loadNow = time.Now
+
+// Loaded reports whether the file's content is buffered in memory, so that
+// Read returns it without I/O. It is false for lazy streams and ReaderAt
+// Files until Read buffers them, for directories, and for a File that only
+// records where its content lives (a path, S3 object or Storage URI).
+func (f *File) Loaded() bool {
+	return f.loaded && f.data != nil
+}
+
+// EnsureLoaded buffers the file's content now, so callers decide when the
+// fetch happens and under which context, and a later Read does no I/O. It
+// is a no-op when Loaded already reports true.
+//
+// A File without content is fetched from its source: the file at Path, its
+// S3 object, or its Storage URI. DownloadTimeout applies when ctx has no
+// deadline. A source that no longer exists fails with ErrNotFound, and that
+// answer is remembered for a couple of seconds so a hot loop does not
+// hammer a dead source.
+func (f *File) EnsureLoaded(ctx context.Context) error {
+	_, err := f.load(ctx, "EnsureLoaded")
+	return err
+}
+
+// load returns the file's content, buffering it on first use. It backs
+// Read and EnsureLoaded, reporting errors under op.
+func (f *File) load(ctx context.Context, op string) ([]byte, error) {
+	if f.dir {
+		return nil, newError(ErrInvalidSource, op, fmt.Errorf("%s is a directory", shownPath(f.meta.Path)))
+	}
+	if f.loaded && f.data != nil {
+		return f.data, nil
+	}
+	if f.lazy && f.streamHead != nil {
+		// Drain the tail into memory.
+		tail, err := io.ReadAll(f.streamTail)
+		if err != nil {
+			return nil, newError(ErrRead, op, err)
+		}
+		combined := make([]byte, 0, len(f.streamHead)+len(tail))
+		combined = append(combined, f.streamHead...)
+		combined = append(combined, tail...)
+		f.data = combined
+		f.loaded = true
+		f.streamHead = nil
+		f.streamTail = nil
+		f.lazy = false
+		f.meta.Size = int64(len(combined))
+		return f.data, nil
+	}
+	if f.readerAt != nil {
+		data := make([]byte, f.readerAtSize)
+		if _, err := io.ReadFull(io.NewSectionReader(f.readerAt, 0, f.readerAtSize), data); err != nil {
+			return nil, newError(ErrRead, op, err)
+		}
+		f.data = data
+		f.loaded = true
+		return f.data, nil
+	}
+	if !f.loaded && f.hasSourceLocation() {
+		return f.fetchSource(ctx, op)
+	}
+	if f.consumedBy != "" {
+		return nil, newError(ErrRead, op, fmt.Errorf("no data available: the lazy stream was consumed by %s", f.consumedBy))
+	}
+	return nil, newError(ErrRead, op, fmt.Errorf("no data available"))
+}
+
+// hasSourceLocation reports whether f records where its content can be
+// fetched from.
+func (f *File) hasSourceLocation() bool {
+	switch f.source {
+	case SourceFile:
+		return f.meta.Path != ""
+	case SourceS3:
+		_, _, ok := f.s3Location()
+		return ok
+	case SourceStorage:
+		return f.meta.URL != ""
+	}
+	return false
+}
+
+// fetchSource downloads the content of a File that has none yet and
+// buffers it. A missing source is cached for missingSourceTTL.
+func (f *File) fetchSource(ctx context.Context, op string) ([]byte, error) {
+	if f.missingErr != nil && loadNow().Sub(f.missingAt) < missingSourceTTL {
+		return nil, f.missingErr
+	}
+	if f.source != SourceFile {
+		if err := checkNetwork(ctx, op); err != nil {
+			return nil, err
+		}
+	}
+	f.missingErr = nil
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrRead, op, err)
+	}
+	defer release()
+
+	var data []byte
+	sentinel := ErrRead
+	switch f.source {
+	case SourceFile:
+		data, err = os.ReadFile(f.meta.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, f.noteMissing(op, err)
+		}
+	case SourceS3:
+		data, err = f.fetchS3(ctx)
+		if isS3NotFound(err) {
+			return nil, f.noteMissing(op, err)
+		}
+		sentinel = ErrS3
+	case SourceStorage:
+		data, err = f.fetchStorage(ctx)
+		if errors.Is(err, ErrNotFound) {
+			return nil, f.noteMissing(op, err)
+		}
+	}
+	if err != nil {
+		return nil, dl.newError(ctx, sentinel, op, err)
+	}
+
+	f.data = data
+	f.loaded = true
+	f.transferSize = int64(len(data))
+	if f.meta.Size == 0 {
+		f.meta.Size = int64(len(data))
+	}
+	return f.data, nil
+}
+
+// noteMissing records that the source does not exist and returns the
+// ErrNotFound that Read reports until missingSourceTTL passes.
+func (f *File) noteMissing(op string, err error) error {
+	f.missingErr = newError(ErrNotFound, op, err)
+	f.missingAt = loadNow()
+	return f.missingErr
+}
+
+// fetchS3 reads the file's S3 object.
+func (f *File) fetchS3(ctx context.Context) ([]byte, error) {
+	return readAllClose(f.openS3(ctx))
+}
+
+// fetchStorage reads the file's object through its Storage backend.
+func (f *File) fetchStorage(ctx context.Context) ([]byte, error) {
+	return readAllClose(f.openStorage(ctx))
+}
+
+// openS3 opens the body of the file's S3 object.
+func (f *File) openS3(ctx context.Context) (io.ReadCloser, error) {
+	bucket, key, _ := f.s3Location()
+	s3Client, _ := s3Clients()
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return verifyS3Body(out, "Read"), nil
+}
+
+// openStorage opens the file's object through its Storage backend.
+func (f *File) openStorage(ctx context.Context) (io.ReadCloser, error) {
+	s, err := StorageFor(f.meta.URL)
+	if err != nil {
+		return nil, err
+	}
+	rc, _, err := s.Get(ctx, f.meta.URL)
+	return rc, err
+}
+
+// readAllClose reads rc to the end and closes it.
+func readAllClose(rc io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
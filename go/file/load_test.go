@@ -0,0 +1,130 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestRead_FileDeletedBeforeFirstRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gone.txt")
+	f := &File{source: SourceFile, meta: Metadata{Path: path, Name: "gone.txt"}}
+	if f.Loaded() {
+		t.Fatal("Loaded = true before any read")
+	}
+
+	_, err := f.Read()
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Read err = %v, want ErrNotFound", err)
+	}
+
+	// Within the TTL the cached answer is returned even though the file
+	// now exists; after it the source is fetched again.
+	if err := os.WriteFile(path, []byte("back"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Read(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Read err = %v, want cached ErrNotFound", err)
+	}
+	now := time.Now()
+	loadNow = func() time.Time { return now.Add(missingSourceTTL) }
+	defer func() { loadNow = time.Now }()
+	data, err := f.Read()
+	if err != nil || string(data) != "back" {
+		t.Fatalf("Read after TTL = %q, %v", data, err)
+	}
+	if !f.Loaded() || f.Size() != 4 {
+		t.Errorf("Loaded = %v, Size = %d", f.Loaded(), f.Size())
+	}
+}
+
+func TestRead_S3NoSuchKeyOnFirstRead(t *testing.T) {
+	calls := 0
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			calls++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("fetch ran without a deadline")
+			}
+			return nil, &types.NoSuchKey{}
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "missing.txt"}
+	for range 3 {
+		if _, err := f.Read(); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Read err = %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("GetObject calls = %d, want 1 (negative result cached)", calls)
+	}
+}
+
+func TestEnsureLoaded_S3(t *testing.T) {
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("hello")))}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "a.txt"}
+	if err := f.EnsureLoaded(context.Background()); err != nil {
+		t.Fatalf("EnsureLoaded: %v", err)
+	}
+	if !f.Loaded() {
+		t.Error("Loaded = false after EnsureLoaded")
+	}
+	if data, _ := f.Read(); string(data) != "hello" {
+		t.Errorf("Read = %q", data)
+	}
+}
+
+func TestEnsureLoaded_S3OtherErrorsNotCached(t *testing.T) {
+	calls := 0
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			calls++
+			return nil, errors.New("throttled")
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "a.txt"}
+	for range 2 {
+		err := f.EnsureLoaded(context.Background())
+		if !errors.Is(err, ErrS3) || errors.Is(err, ErrNotFound) {
+			t.Fatalf("err = %v, want ErrS3", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("GetObject calls = %d, want 2", calls)
+	}
+}
+
+func TestEnsureLoaded_LazyStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), streamHeadBytes+10)
+	f, err := NewFromStreamLazy(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Loaded() {
+		t.Fatal("lazy stream Loaded = true")
+	}
+	if err := f.EnsureLoaded(context.Background()); err != nil {
+		t.Fatalf("EnsureLoaded: %v", err)
+	}
+	if !f.Loaded() || f.Size() != int64(len(payload)) {
+		t.Errorf("Loaded = %v, Size = %d", f.Loaded(), f.Size())
+	}
+}
@@ -1,6 +1,9 @@
 package file
 
-import "time"
+import (
+	"maps"
+	"time"
+)
 
 // Metadata holds information about a file's properties and attributes.
 type Metadata struct {
@@ -12,7 +15,8 @@ type Metadata struct {
 	Size int64
 	// Extension is the file extension without a leading dot (e.g., "txt").
 	Extension string
-	// URL is the source URL for URL-sourced files or the S3 URI for S3-sourced files.
+	// URL is the source URL for URL-sourced files, after any redirects (see
+	// File.RedirectChain), or the S3 URI for S3-sourced files.
 	URL string
 	// Path is the local filesystem path for file-sourced files.
 	Path string
@@ -20,8 +24,43 @@ type Metadata struct {
 	Hash string
 	// LastModified is the last modification time.
 	LastModified time.Time
-	// CreatedAt is the creation time (birthtime).
+	// CreatedAt is the creation time (birthtime). A hint sets it; otherwise
+	// URL sources read it from the headers named by SetCreatedAtHeaders,
+	// and S3 sources approximate it with LastModified (the time of the last
+	// PUT). Files derived by Save, Move, Compress and Decompress keep it.
 	CreatedAt time.Time
+	// VersionID identifies the object version at the source: the S3
+	// VersionId or the GCS generation number. Empty when unversioned.
+	VersionID string
+	// EntryCount is the number of direct entries in a directory-mode File;
+	// zero otherwise.
+	EntryCount int
+	// Attributes holds free-form key/value metadata from the source or set
+	// by the caller. UploadToS3 writes them as S3 user metadata
+	// (x-amz-meta-*), lowercasing keys as S3 does, and NewFromS3 reads them
+	// back; WithFetchTags adds the object's tags too.
+	Attributes map[string]string
+	// Tags holds the S3 object tags: written by UploadToS3, and read by
+	// LoadS3Tags or WithFetchTags.
+	Tags map[string]string
+
+	// explanation records where the fields above came from; see
+	// File.Explain. Resolvers fill it in and it is read-only afterwards,
+	// so copies share it.
+	explanation *MetadataExplanation
+}
+
+// clone returns a copy of m that shares no maps with the original.
+func (m Metadata) clone() Metadata {
+	if m.Attributes != nil {
+		attrs := make(map[string]string, len(m.Attributes))
+		for k, v := range m.Attributes {
+			attrs[k] = v
+		}
+		m.Attributes = attrs
+	}
+	m.Tags = maps.Clone(m.Tags)
+	return m
 }
 
 // MetadataHint provides optional hints for metadata resolution.
@@ -36,6 +75,10 @@ type MetadataHint struct {
 	Hash         string
 	LastModified time.Time
 	CreatedAt    time.Time
+	// Attributes are merged key-by-key into Metadata.Attributes.
+	Attributes map[string]string
+	// Tags are merged key-by-key into Metadata.Tags.
+	Tags map[string]string
 }
 
 // hasName returns true if the hint has a non-empty Name.
@@ -64,3 +107,23 @@ func (h MetadataHint) hasLastModified() bool { return !h.LastModified.IsZero() }
 
 // hasCreatedAt returns true if the hint has a non-zero CreatedAt.
 func (h MetadataHint) hasCreatedAt() bool { return !h.CreatedAt.IsZero() }
+
+// hasAttributes returns true if the hint has at least one attribute.
+func (h MetadataHint) hasAttributes() bool { return len(h.Attributes) > 0 }
+
+// hasTags returns true if the hint has at least one tag.
+func (h MetadataHint) hasTags() bool { return len(h.Tags) > 0 }
+
+// mergeAttributes copies src into dst, allocating dst if needed.
+func mergeAttributes(dst map[string]string, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
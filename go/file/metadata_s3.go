@@ -0,0 +1,77 @@
+package file
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// NewMetadataFromS3 resolves an S3 object's metadata with HeadObject,
+// without downloading it. Name, MIME type, size, ETag (as Hash),
+// modification time, version and user metadata are resolved with the same
+// priority as NewFromS3; since no content is read, fields NewFromS3 would
+// take from magic bytes come only from the headers and name. An object
+// stored with WithTransparentGzip reports its uncompressed size, as
+// NewFromS3 would after decoding it. WithVersionID and WithFetchTags
+// apply. A missing object fails with ErrNotFound. MetadataTimeout applies
+// when ctx has no deadline.
+func NewMetadataFromS3(ctx context.Context, bucket, key string, opts ...ReadOption) (Metadata, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewMetadataFromS3", ro.validate()); err != nil {
+		return Metadata{}, err
+	}
+	key, err := checkS3Object("NewMetadataFromS3", bucket, key)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	s3Client, _ := s3Clients()
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: nilIfEmpty(ro.versionID),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return Metadata{}, newError(ErrNotFound, "NewMetadataFromS3", err)
+		}
+		return Metadata{}, dl.newError(ctx, ErrS3, "NewMetadataFromS3", err)
+	}
+
+	m := resolveMetadataFromS3(bucket, key, headAsGetOutput(head), nil, hint)
+	if v, ok := head.Metadata[S3MetaUncompressedSize]; ok && head.ContentEncoding != nil && !hint.hasSize() {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			m.Size = n
+			m.noteOrigin(OriginS3)
+		}
+	}
+	if ro.fetchTags {
+		tags, err := getS3Tags(ctx, s3Client, bucket, key, ro.versionID)
+		if err != nil {
+			return Metadata{}, dl.newError(ctx, ErrS3, "NewMetadataFromS3", err)
+		}
+		m.Attributes = mergeAttributes(m.Attributes, tags)
+		m.Tags = mergeAttributes(m.Tags, tags)
+	}
+	return m, nil
+}
+
+// headAsGetOutput carries the metadata fields of a HeadObject response
+// over to a bodiless GetObjectOutput, for resolveMetadataFromS3.
+func headAsGetOutput(head *s3.HeadObjectOutput) *s3.GetObjectOutput {
+	return &s3.GetObjectOutput{
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLength:      head.ContentLength,
+		ContentType:        head.ContentType,
+		ETag:               head.ETag,
+		LastModified:       head.LastModified,
+		Metadata:           head.Metadata,
+		VersionId:          head.VersionId,
+	}
+}
@@ -0,0 +1,93 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestNewMetadataFromS3_Head(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var heads int
+	defer setMockS3(&mockS3Client{
+		headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			heads++
+			if aws.ToString(in.Key) != "exports/q1" || aws.ToString(in.VersionId) != "v2" {
+				t.Errorf("HeadObject(%s, version %s)", aws.ToString(in.Key), aws.ToString(in.VersionId))
+			}
+			return &s3.HeadObjectOutput{
+				ContentDisposition: aws.String(`attachment; filename="q1.csv"`),
+				ContentType:        aws.String("text/csv"),
+				ContentLength:      aws.Int64(123456),
+				ETag:               aws.String(`"abc"`),
+				LastModified:       aws.Time(modified),
+				VersionId:          aws.String("v2"),
+				Metadata:           map[string]string{"owner": "finance"},
+			}, nil
+		},
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			t.Error("NewMetadataFromS3 called GetObject")
+			return nil, errors.New("unexpected")
+		},
+	}, &mockPresignClient{})()
+
+	m, err := NewMetadataFromS3(context.Background(), "bucket", "exports/q1", WithVersionID("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "q1.csv" || m.MimeType != "text/csv" || m.Extension != "csv" || m.Size != 123456 ||
+		m.Hash != "abc" || !m.LastModified.Equal(modified) || m.VersionID != "v2" ||
+		m.URL != "s3://bucket/exports/q1" || m.Attributes["owner"] != "finance" {
+		t.Errorf("Metadata = %+v", m)
+	}
+	if heads != 1 {
+		t.Errorf("HeadObject called %d times", heads)
+	}
+}
+
+func TestNewMetadataFromS3_UncompressedSize(t *testing.T) {
+	defer setMockS3(&mockS3Client{
+		headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				ContentEncoding: aws.String("gzip"),
+				ContentLength:   aws.Int64(40),
+				Metadata:        map[string]string{S3MetaUncompressedSize: "1000"},
+			}, nil
+		},
+	}, &mockPresignClient{})()
+
+	m, err := NewMetadataFromS3(context.Background(), "bucket", "app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size != 1000 {
+		t.Errorf("Size = %d, want the uncompressed 1000", m.Size)
+	}
+}
+
+func TestNewMetadataFromS3_Errors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err  error
+		want error
+	}{
+		"not found":     {&types.NotFound{}, ErrNotFound},
+		"no such key":   {&types.NoSuchKey{}, ErrNotFound},
+		"access denied": {errors.New("access denied"), ErrS3},
+	} {
+		cleanup := setMockS3(&mockS3Client{
+			headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, tc.err
+			},
+		}, &mockPresignClient{})
+		_, err := NewMetadataFromS3(context.Background(), "bucket", "k")
+		cleanup()
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package file
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NewMetadataFromURL resolves rawURL's metadata without downloading it.
+func NewMetadataFromURL(rawURL string, opts ...ReadOption) (Metadata, error) {
+	return NewMetadataFromURLWithContext(context.Background(), rawURL, opts...)
+}
+
+// NewMetadataFromURLWithContext sends a HEAD request for rawURL and resolves
+// name, MIME type, size, hash and modification time from its headers with
+// the same priority as NewFromURL. Servers that reject HEAD (405 or 501)
+// get a one-byte ranged GET instead, whose body is discarded and whose
+// Content-Range supplies the size. Since no content is read, fields that
+// NewFromURL would take from magic bytes come only from the headers and
+// name. WithTimeout, WithHTTPClient and WithNoRedirects apply.
+// MetadataTimeout applies when ctx has no deadline.
+func NewMetadataFromURLWithContext(ctx context.Context, rawURL string, opts ...ReadOption) (Metadata, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewMetadataFromURL", ro.validate()); err != nil {
+		return Metadata{}, err
+	}
+	if ro.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ro.timeout)
+		defer cancel()
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	resp, err := headURL(ctx, dl, http.MethodHead, rawURL, ro)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		if resp, err = headURL(ctx, dl, http.MethodGet, rawURL, ro); err != nil {
+			return Metadata{}, err
+		}
+	}
+	if ro.noRedirects {
+		if err := checkNotRedirected(resp, "NewMetadataFromURL"); err != nil {
+			return Metadata{}, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Metadata{}, httpStatusError("NewMetadataFromURL", resp)
+	}
+
+	m := resolveMetadataFromHTTPResponse(resp, rawURL, nil, hint)
+	if resp.StatusCode == http.StatusPartialContent {
+		// Content-Length is the length of the range, not of the content.
+		m.Size = 0
+		if total, ok := contentRangeTotal(resp.Header.Get("Content-Range")); ok {
+			m.Size = total
+		}
+		m.noteOrigin(OriginHeader)
+	}
+	return m, nil
+}
+
+// headURL sends a bodiless metadata request: HEAD, or GET for the first
+// byte only. The response body is closed unread.
+func headURL(ctx context.Context, dl opDeadline, method, rawURL string, ro readOptions) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, newError(ErrHTTP, "NewMetadataFromURL", err)
+	}
+	// Content-Length should describe the content, not an encoding of it.
+	req.Header.Set("Accept-Encoding", "identity")
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	client := ro.client
+	if client == nil {
+		client = CurrentHTTPClient()
+	}
+	if ro.noRedirects {
+		client = withoutRedirects(client)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrHTTP, "NewMetadataFromURL", err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// contentRangeTotal parses the complete length of a Content-Range value
+// such as "bytes 0-0/5000". It reports false when the length is unknown
+// ("*").
+func contentRangeTotal(v string) (int64, bool) {
+	_, total, ok := strings.Cut(v, "/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,83 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewMetadataFromURL_Head(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Disposition", `attachment; filename="report.pdf"`)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Length", "123456")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	}))
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	m, err := NewMetadataFromURL(ts.URL + "/download?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "report.pdf" || m.MimeType != "application/pdf" || m.Extension != "pdf" ||
+		m.Size != 123456 || m.Hash != "abc" || !m.LastModified.Equal(modified) {
+		t.Errorf("Metadata = %+v", m)
+	}
+	if !strings.HasPrefix(m.URL, ts.URL) {
+		t.Errorf("URL = %q", m.URL)
+	}
+	if len(methods) != 1 || methods[0] != http.MethodHead {
+		t.Errorf("requests = %v, want a single HEAD", methods)
+	}
+}
+
+func TestNewMetadataFromURL_FallbackRangedGet(t *testing.T) {
+	content := bytes.Repeat([]byte("a,b\n"), 1000)
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "text/csv")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	m, err := NewMetadataFromURL(ts.URL+"/data.csv", MetadataHint{Attributes: map[string]string{"k": "v"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=0-0" {
+		t.Errorf("GET ranges = %v, want one bytes=0-0", ranges)
+	}
+	if m.Size != int64(len(content)) {
+		t.Errorf("Size = %d, want %d from Content-Range", m.Size, len(content))
+	}
+	if m.Name != "data.csv" || m.MimeType != "text/csv" || m.Attributes["k"] != "v" {
+		t.Errorf("Metadata = %+v", m)
+	}
+}
+
+func TestNewMetadataFromURL_HTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	_, err := NewMetadataFromURL(ts.URL + "/missing")
+	var fe *FileError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrHTTP) || fe.StatusCode != http.StatusNotFound {
+		t.Fatalf("err = %v, want ErrHTTP with status 404", err)
+	}
+}
@@ -0,0 +1,47 @@
+package file
+
+import (
+	"context"
+	"errors"
+)
+
+// StrictLocal makes File methods that would reach the network fail with
+// ErrNetworkDisallowed instead, unless their context comes from
+// WithAllowNetwork. It guards against a File silently going remote where
+// only local handling is expected, say a location-only S3 File read inside
+// a template renderer.
+//
+// The guarded methods are UploadToS3, UploadDirToS3,
+// UploadWithDerivativesToS3, UploadToURL, UploadToStorage, CopyToS3,
+// DownloadFromS3, RefreshFromURL, GetSignedURL, SetS3Tags, LoadS3Tags,
+// UpdateS3Metadata, Exists for remote sources, Delete for S3 sources, and
+// fetching a File's content from S3 or a Storage backend on first Read
+// (see EnsureLoaded), through Reader or WriteTo, or in part with ReadRange
+// and Sample. Prewarm is guarded too, for the S3 and Storage Files it
+// would refresh.
+// Buffered content and the local filesystem are unaffected, and so are
+// the other package-level functions (NewFromURL, NewFromS3, Pipe, ...),
+// whose network use is never implicit.
+var StrictLocal bool
+
+// allowNetworkKey marks a context that may reach the network under
+// StrictLocal.
+type allowNetworkKey struct{}
+
+// WithAllowNetwork returns a copy of ctx that lets a single call, and
+// everything it does with that context, reach the network while
+// StrictLocal is set. Non-context variants such as Read and UploadToS3 use
+// context.Background() and so are always refused under StrictLocal; use
+// EnsureLoaded or the WithContext variant with an allowed context.
+func WithAllowNetwork(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowNetworkKey{}, true)
+}
+
+// checkNetwork fails op with ErrNetworkDisallowed when StrictLocal is set
+// and ctx does not come from WithAllowNetwork.
+func checkNetwork(ctx context.Context, op string) error {
+	if !StrictLocal || ctx.Value(allowNetworkKey{}) != nil {
+		return nil
+	}
+	return newError(ErrNetworkDisallowed, op, errors.New("StrictLocal is set and the context does not allow network access"))
+}
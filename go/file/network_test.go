@@ -0,0 +1,204 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// networkOps calls each File method that may reach the network on a File
+// it would go remote for. Every exported method must appear here or in
+// localMethods; TestStrictLocal_EveryMethodClassified keeps that honest.
+func networkOps(t *testing.T, srvURL string) map[string]func(ctx context.Context) error {
+	s3File := func() *File {
+		return &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k.txt", meta: Metadata{Name: "k.txt"}}
+	}
+	buffered := func() *File {
+		f, _ := NewFromBytes([]byte("data"))
+		return f
+	}
+	return map[string]func(ctx context.Context) error{
+		"Read": func(ctx context.Context) error {
+			_, err := s3File().Read()
+			return err
+		},
+		"EnsureLoaded": func(ctx context.Context) error { return s3File().EnsureLoaded(ctx) },
+		"Reader": func(ctx context.Context) error {
+			_, err := s3File().Reader()
+			return err
+		},
+		"ReaderWithContext": func(ctx context.Context) error {
+			_, err := s3File().ReaderWithContext(ctx)
+			return err
+		},
+		"Sample": func(ctx context.Context) error {
+			_, err := s3File().Sample(DefaultSampleSpec())
+			return err
+		},
+		"ReadRange": func(ctx context.Context) error {
+			_, err := s3File().ReadRange(0, 4)
+			return err
+		},
+		"WriteTo": func(ctx context.Context) error {
+			_, err := s3File().WriteTo(io.Discard)
+			return err
+		},
+		"UploadToS3": func(ctx context.Context) error { return buffered().UploadToS3("bucket", "k") },
+		"UploadToS3WithContext": func(ctx context.Context) error {
+			return buffered().UploadToS3WithContext(ctx, "bucket", "k")
+		},
+		"UploadToS3WithResult": func(ctx context.Context) error {
+			_, err := buffered().UploadToS3WithResult(ctx, "bucket", "k")
+			return err
+		},
+		"UploadWithDerivativesToS3": func(ctx context.Context) error {
+			return buffered().UploadWithDerivativesToS3(ctx, "bucket", func(*File, string) string { return "k" })
+		},
+		"UploadDirToS3": func(ctx context.Context) error {
+			dir, _ := NewFromFile(t.TempDir())
+			return dir.UploadDirToS3(ctx, "bucket", "p/")
+		},
+		"UploadToURL": func(ctx context.Context) error { return buffered().UploadToURL(srvURL) },
+		"UploadToURLWithContext": func(ctx context.Context) error {
+			return buffered().UploadToURLWithContext(ctx, srvURL)
+		},
+		"UploadToStorage": func(ctx context.Context) error { return buffered().UploadToStorage(ctx, "mem://b/k") },
+		"DownloadFromS3":  func(ctx context.Context) error { return buffered().DownloadFromS3("bucket", "k") },
+		"DownloadFromS3WithContext": func(ctx context.Context) error {
+			return buffered().DownloadFromS3WithContext(ctx, "bucket", "k")
+		},
+		"GetSignedURL": func(ctx context.Context) error {
+			_, err := s3File().GetSignedURL(0)
+			return err
+		},
+		"GetSignedURLWithContext": func(ctx context.Context) error {
+			_, err := s3File().GetSignedURLWithContext(ctx, 0)
+			return err
+		},
+		"SetS3Tags": func(ctx context.Context) error { return s3File().SetS3Tags(ctx, map[string]string{"a": "b"}) },
+		"UpdateS3Metadata": func(ctx context.Context) error {
+			return s3File().UpdateS3Metadata(ctx, MetadataHint{MimeType: "text/plain"})
+		},
+		"RefreshFromURL": func(ctx context.Context) error {
+			f := &File{source: SourceURL, meta: Metadata{URL: srvURL, Hash: "v1"}}
+			_, err := f.RefreshFromURL(ctx)
+			return err
+		},
+		"Exists": func(ctx context.Context) error {
+			_, err := s3File().Exists(ctx)
+			return err
+		},
+		"LoadS3Tags": func(ctx context.Context) error {
+			return s3File().LoadS3Tags(ctx)
+		},
+		"Delete":            func(ctx context.Context) error { return s3File().Delete() },
+		"DeleteWithContext": func(ctx context.Context) error { return s3File().DeleteWithContext(ctx) },
+		"CopyToS3": func(ctx context.Context) error {
+			_, err := s3File().CopyToS3(ctx, "dest", "k")
+			return err
+		},
+	}
+}
+
+// localMethods never reach the network themselves. Those that need content
+// get it through Read, which networkOps covers.
+var localMethods = []string{
+	"AddDerivative", "Append", "ApplyS3GetOutput", "AsReadSeekCloser", "AsS3PutInput", "Checksum", "Children", "Chunks", "Compress", "CreatedAt", "Decompress",
+	"Derivative", "Derivatives", "DiffAgainst", "DiffText", "ExpandTemplate", "Explain", "Extension", "Frozen", "Hash", "IsDir", "IterBytes",
+	"LastModified", "Lines", "Loaded", "MemoryFootprint", "Metadata", "MimeType", "Move",
+	"Name", "NormalizeUnicode", "Open", "PDFInfo", "Path", "Prepend", "Provenance", "ReadText", "RedirectChain", "Save", "SaveToDir",
+	"SetMetadata", "Size", "SniffDelimited", "Source", "String", "ToBase64", "ToDataURI", "ToFormData",
+	"TransferSize", "Truncate", "URL", "Validate", "ValidateUTF8", "Walk", "WriteHTTPResponse", "ZipTo",
+}
+
+func TestStrictLocal_EveryMethodClassified(t *testing.T) {
+	ops := networkOps(t, "")
+	typ := reflect.TypeOf(&File{})
+	for i := range typ.NumMethod() {
+		name := typ.Method(i).Name
+		_, network := ops[name]
+		local := slices.Contains(localMethods, name)
+		switch {
+		case network && local:
+			t.Errorf("%s is listed as both a network and a local method", name)
+		case !network && !local:
+			t.Errorf("%s is not classified; add it to networkOps (and guard it with checkNetwork) or to localMethods", name)
+		}
+	}
+}
+
+func TestStrictLocal_RefusesNetworkOps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+	defer setMockS3(&mockS3Client{}, &mockPresignClient{})()
+	RegisterStorage("mem", newMemStorage())
+	defer RegisterStorage("mem", nil)
+
+	StrictLocal = true
+	defer func() { StrictLocal = false }()
+
+	for name, op := range networkOps(t, srv.URL) {
+		err := op(context.Background())
+		if !errors.Is(err, ErrNetworkDisallowed) {
+			t.Errorf("%s: err = %v, want ErrNetworkDisallowed", name, err)
+		}
+		if err := op(WithAllowNetwork(context.Background())); errors.Is(err, ErrNetworkDisallowed) && !usesBackground(name) {
+			t.Errorf("%s with WithAllowNetwork: err = %v", name, err)
+		}
+	}
+}
+
+// usesBackground reports whether the named op has no context parameter,
+// so WithAllowNetwork cannot reach it.
+func usesBackground(name string) bool {
+	switch name {
+	case "Read", "Reader", "ReadRange", "Sample", "WriteTo", "UploadToS3", "UploadToURL", "DownloadFromS3", "GetSignedURL", "Delete":
+		return true
+	}
+	return false
+}
+
+func TestStrictLocal_LocalOpsUnaffected(t *testing.T) {
+	StrictLocal = true
+	defer func() { StrictLocal = false }()
+
+	buffered, _ := NewFromBytes([]byte("data"), MetadataHint{Name: "a.txt"})
+	if _, err := buffered.Read(); err != nil {
+		t.Errorf("Read of buffered File: %v", err)
+	}
+	if _, err := buffered.Exists(context.Background()); err != nil {
+		t.Errorf("Exists of buffered File: %v", err)
+	}
+	path := t.TempDir() + "/a.txt"
+	if _, err := buffered.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	onDisk := &File{source: SourceFile, meta: Metadata{Path: path}}
+	if data, err := onDisk.Read(); err != nil || string(data) != "data" {
+		t.Errorf("Read of file-backed File = %q, %v", data, err)
+	}
+	if ok, err := onDisk.Exists(context.Background()); !ok || err != nil {
+		t.Errorf("Exists of file-backed File = %v, %v", ok, err)
+	}
+}
+
+func TestStrictLocal_Prewarm(t *testing.T) {
+	defer setMockS3(&mockS3Client{}, &mockPresignClient{})()
+	StrictLocal = true
+	defer func() { StrictLocal = false }()
+
+	remote := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k.txt"}
+	if err := Prewarm(context.Background(), []*File{remote}, PrewarmMetadataOnly, 1); !errors.Is(err, ErrNetworkDisallowed) {
+		t.Errorf("Prewarm of an S3 File: err = %v, want ErrNetworkDisallowed", err)
+	}
+	buffered, _ := NewFromBytes([]byte("data"))
+	if err := Prewarm(context.Background(), []*File{buffered}, PrewarmFullContent, 1); err != nil {
+		t.Errorf("Prewarm of a buffered File: %v", err)
+	}
+}
@@ -0,0 +1,698 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Option is implemented by every functional option type in the package
+// (SaveOption, UploadOption, TransferOption, ReadOption, ArchiveOption,
+// DeleteOption, AuditOption, CopyOption, SignOption, JournalOption and
+// DirOption), so a mixed set can be checked up front with CheckOptions.
+type Option interface {
+	isOption()
+}
+
+func (SaveOption) isOption()     {}
+func (UploadOption) isOption()   {}
+func (TransferOption) isOption() {}
+func (MetadataHint) isOption()   {}
+
+// CheckOptions reports whether opts would be accepted, without doing any
+// I/O. Every operation runs the same checks before it starts and fails
+// with ErrInvalidOption, so calling CheckOptions is only needed to catch
+// mistakes early (say, while loading configuration). Each option type is
+// checked on its own; the conflicts detected are:
+//
+//   - WithVerifyMode without WithVerify
+//   - WithObjectLock without a positive WithRetention
+//   - WithTransferID without WithCheckpoint
+//   - WithAcceptLenient without WithAccept
+//   - WithCopyContentType with WithMetadataDirective COPY
+//
+// Out-of-range values given to an option constructor (a negative
+// bandwidth, an unknown algorithm or priority) are reported here too.
+//
+// It is not named ValidateOptions because that type configures
+// File.Validate.
+func CheckOptions(opts ...Option) error {
+	var (
+		save     []SaveOption
+		upload   []UploadOption
+		transfer []TransferOption
+		reads    []ReadOption
+		archive  []ArchiveOption
+		deletes  []DeleteOption
+		audits   []AuditOption
+		journals []JournalOption
+		dirs     []DirOption
+		copies   []CopyOption
+		signs    []SignOption
+	)
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case SaveOption:
+			save = append(save, o)
+		case UploadOption:
+			upload = append(upload, o)
+		case TransferOption:
+			transfer = append(transfer, o)
+		case ReadOption:
+			reads = append(reads, o)
+		case ArchiveOption:
+			archive = append(archive, o)
+		case DeleteOption:
+			deletes = append(deletes, o)
+		case AuditOption:
+			audits = append(audits, o)
+		case JournalOption:
+			journals = append(journals, o)
+		case DirOption:
+			dirs = append(dirs, o)
+		case CopyOption:
+			copies = append(copies, o)
+		case SignOption:
+			signs = append(signs, o)
+		}
+	}
+	var errs []error
+	if len(save) > 0 {
+		errs = append(errs, newSaveOptions(save).validate())
+	}
+	if len(upload) > 0 {
+		errs = append(errs, newUploadOptions(upload).validate())
+	}
+	if len(transfer) > 0 {
+		errs = append(errs, newTransferOptions(transfer).validate())
+	}
+	if len(reads) > 0 {
+		_, ro := resolveHints(reads)
+		errs = append(errs, ro.validate())
+	}
+	if len(archive) > 0 {
+		errs = append(errs, newArchiveOptions(archive).validate())
+	}
+	if len(deletes) > 0 {
+		errs = append(errs, newDeleteOptions(deletes).validate())
+	}
+	if len(audits) > 0 {
+		errs = append(errs, newAuditOptions(audits).validate())
+	}
+	if len(journals) > 0 {
+		errs = append(errs, newJournalOptions(journals).validate())
+	}
+	if len(dirs) > 0 {
+		errs = append(errs, newDirOptions(dirs).validate())
+	}
+	if len(copies) > 0 {
+		errs = append(errs, newCopyOptions(copies).validate())
+	}
+	if len(signs) > 0 {
+		errs = append(errs, newSignOptions(signs).validate())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return newError(ErrInvalidOption, "CheckOptions", err)
+	}
+	return nil
+}
+
+// optionErrors collects the problems found while applying options. Option
+// constructors cannot return errors, so they record out-of-range values
+// here and the owning options type reports them from validate, before the
+// operation does any I/O.
+type optionErrors struct {
+	errs []error
+}
+
+// reject records that option was given an unusable value.
+func (e *optionErrors) reject(option, format string, args ...any) {
+	e.errs = append(e.errs, fmt.Errorf("%s: %s", option, fmt.Sprintf(format, args...)))
+}
+
+// checkOptions wraps the error from an options type's validate for op.
+func checkOptions(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return newError(ErrInvalidOption, op, err)
+}
+
+// SaveOption configures Save and the other operations that write a File to
+// the local filesystem (DownloadS3ToFile).
+type SaveOption func(*saveOptions)
+
+// saveOptions is the resolved set of SaveOption values.
+type saveOptions struct {
+	optionErrors
+	verify        bool
+	algo          Algorithm
+	verifyMode    VerifyMode
+	verifyModeSet bool
+	collision     CollisionStrategy
+	expandPath    bool
+	dirTemplate   bool
+	dirMode       fs.FileMode
+}
+
+// newSaveOptions applies opts over the package defaults.
+func newSaveOptions(opts []SaveOption) saveOptions {
+	o := saveOptions{dirMode: defaultDirMode}
+	loadDefaults().applySave(&o)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values and conflicting options.
+func (o saveOptions) validate() error {
+	errs := o.errs
+	if o.verifyModeSet && !o.verify {
+		errs = append(errs, errors.New("WithVerifyMode requires WithVerify"))
+	}
+	if o.verify {
+		if _, err := o.algo.newHash(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithVerify enables integrity verification of the written file using the
+// given digest algorithm. See VerifyMode for how the digest is checked.
+// On failure the destination is removed and an ErrChecksumMismatch error is
+// returned.
+func WithVerify(algo Algorithm) SaveOption {
+	return func(o *saveOptions) {
+		if _, err := algo.newHash(); err != nil {
+			o.reject("WithVerify", "%v", err)
+			return
+		}
+		o.verify = true
+		o.algo = algo
+	}
+}
+
+// WithVerifyMode selects how WithVerify checks the written file. It must be
+// combined with WithVerify.
+func WithVerifyMode(mode VerifyMode) SaveOption {
+	return func(o *saveOptions) {
+		if mode < VerifyReread || mode > VerifySourceDigest {
+			o.reject("WithVerifyMode", "unknown mode %d", mode)
+			return
+		}
+		o.verifyMode = mode
+		o.verifyModeSet = true
+	}
+}
+
+// WithCollisionStrategy selects what Save and SaveToDir do when the
+// destination already exists (default CollisionOverwrite).
+func WithCollisionStrategy(s CollisionStrategy) SaveOption {
+	return func(o *saveOptions) {
+		if s < CollisionOverwrite || s > CollisionRenameWithSuffix {
+			o.reject("WithCollisionStrategy", "unknown strategy %d", s)
+			return
+		}
+		o.collision = s
+	}
+}
+
+// WithExpandPath makes Save, SaveToDir, DownloadS3ToFile and CommitAll
+// expand their destination before writing: a leading "~" becomes the
+// user's home directory and $VAR or ${VAR} references are replaced from
+// the environment, so "~/exports/report.csv" and "$DATA_DIR/out/x.bin"
+// from a config file work as written. A reference to an unset variable
+// fails with ErrInvalidArgument instead of silently becoming "".
+//
+// Expansion is off by default and should stay off for paths built from
+// untrusted input, which could otherwise reach into the environment.
+func WithExpandPath() SaveOption {
+	return func(o *saveOptions) { o.expandPath = true }
+}
+
+// WithDirMode sets the permission bits of the parent directories created
+// for the destination (default 0o755, subject to the umask). Directories
+// that already exist are left unchanged. mode must only hold permission
+// bits and grant the owner write and search permission, or nested
+// directories could not be created inside it.
+func WithDirMode(mode fs.FileMode) SaveOption {
+	return func(o *saveOptions) {
+		if mode&^fs.ModePerm != 0 {
+			o.reject("WithDirMode", "mode %v has bits other than permissions", mode)
+			return
+		}
+		if mode&0o300 != 0o300 {
+			o.reject("WithDirMode", "mode %v denies the owner write or search permission", mode)
+			return
+		}
+		o.dirMode = mode
+	}
+}
+
+// --- Read options ---
+
+// readOptions is the resolved set of ReadOption values passed to a
+// constructor.
+type readOptions struct {
+	optionErrors
+	fetchTags     bool
+	accept        []string
+	acceptLenient bool
+	noRedirects   bool
+	retry         retryPolicy
+	timeout       time.Duration
+	client        HTTPDoer
+	nameForm      *norm.Form
+	versionID     string
+	rawBody       bool
+	region        string // set by NewFromS3URI from the URL's host
+}
+
+// validate reports rejected values and conflicting options.
+func (o readOptions) validate() error {
+	errs := o.errs
+	if o.acceptLenient && len(o.accept) == 0 {
+		errs = append(errs, errors.New("WithAcceptLenient requires WithAccept"))
+	}
+	return errors.Join(errs...)
+}
+
+// ReadOption configures a constructor that reads a source: NewFromURL,
+// NewFromS3, New and the rest. MetadataHint is a ReadOption, so hints and
+// read options such as WithRetry and WithTimeout mix in one argument list;
+// the read options are the With... constructors returning ReadOption.
+type ReadOption interface {
+	Option
+	// applyRead folds the option into the merged hint or the read options.
+	applyRead(merged *MetadataHint, o *readOptions)
+}
+
+// readOption is the ReadOption the With... read-option constructors
+// return.
+type readOption func(*readOptions)
+
+func (readOption) isOption() {}
+
+func (fn readOption) applyRead(_ *MetadataHint, o *readOptions) { fn(o) }
+
+// applyRead merges h into merged, its non-zero fields winning.
+func (h MetadataHint) applyRead(merged *MetadataHint, _ *readOptions) {
+	if h.hasName() {
+		merged.Name = h.Name
+	}
+	if h.hasMimeType() {
+		merged.MimeType = h.MimeType
+	}
+	if h.hasSize() {
+		merged.Size = h.Size
+	}
+	if h.hasExtension() {
+		merged.Extension = h.Extension
+	}
+	if h.hasURL() {
+		merged.URL = h.URL
+	}
+	if h.hasPath() {
+		merged.Path = h.Path
+	}
+	if h.hasHash() {
+		merged.Hash = h.Hash
+	}
+	if h.hasLastModified() {
+		merged.LastModified = h.LastModified
+	}
+	if h.hasCreatedAt() {
+		merged.CreatedAt = h.CreatedAt
+	}
+	if h.hasAttributes() {
+		merged.Attributes = mergeAttributes(merged.Attributes, h.Attributes)
+	}
+	if h.hasTags() {
+		merged.Tags = mergeAttributes(merged.Tags, h.Tags)
+	}
+}
+
+// resolveHints merges every hint in opts into one, later non-zero fields
+// winning, and applies the read options over the package defaults.
+func resolveHints(opts []ReadOption) (MetadataHint, readOptions) {
+	var (
+		merged MetadataHint
+		ro     readOptions
+	)
+	loadDefaults().applyRead(&ro)
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyRead(&merged, &ro)
+		}
+	}
+	return merged, ro
+}
+
+// WithFetchTags makes NewFromS3 also fetch the object's tags (one extra
+// GetObjectTagging call) and surface them in Metadata.Tags and, as before
+// Tags existed, Metadata.Attributes.
+func WithFetchTags() ReadOption {
+	return readOption(func(o *readOptions) { o.fetchTags = true })
+}
+
+// --- Upload options ---
+
+// UploadOption configures UploadToS3 and UploadToS3WithContext. UploadToURL
+// accepts WithTrailingChecksum only and rejects the rest with
+// ErrInvalidOption.
+type UploadOption func(*uploadOptions)
+
+// uploadOptions is the resolved set of UploadOption values.
+type uploadOptions struct {
+	optionErrors
+	retention          time.Duration
+	retentionTagKey    string
+	lockMode           types.ObjectLockMode
+	trailingChecksum   bool
+	transparentGzip    bool
+	skipUnchanged      bool
+	prefixTemplate     bool
+	multipartThreshold int64
+	partSize           int64
+	partConcurrency    int
+	storageClass       types.StorageClass
+	sse                types.ServerSideEncryption
+	sseKMSKeyID        string
+	acl                types.ObjectCannedACL
+	contentType        string // CopyToS3's WithCopyContentType
+	now                func() time.Time
+}
+
+// DefaultRetentionTagKey is the object tag key WithRetention writes the
+// expiry timestamp under unless WithRetentionTagKey overrides it.
+const DefaultRetentionTagKey = "x-smoo-expires-at"
+
+// newUploadOptions applies opts over the defaults.
+func newUploadOptions(opts []UploadOption) uploadOptions {
+	o := uploadOptions{
+		retentionTagKey:    DefaultRetentionTagKey,
+		multipartThreshold: DefaultMultipartThreshold,
+		partSize:           DefaultPartSize,
+		partConcurrency:    DefaultPartConcurrency,
+		now:                time.Now,
+	}
+	loadDefaults().applyUpload(&o)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values and conflicting options.
+func (o uploadOptions) validate() error {
+	errs := o.errs
+	if o.lockMode != "" && o.retention <= 0 {
+		errs = append(errs, errors.New("WithObjectLock requires WithRetention"))
+	}
+	return errors.Join(errs...)
+}
+
+// rejectUnsupported records an error for each option in opts that op
+// cannot honour, that is, each one not named in supported. It looks at
+// opts alone, so Defaults never trip it.
+func (o *uploadOptions) rejectUnsupported(op string, opts []UploadOption, supported ...string) {
+	var given uploadOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&given)
+		}
+	}
+	for _, name := range given.names() {
+		if !slices.Contains(supported, name) {
+			o.reject(name, "not supported by %s", op)
+		}
+	}
+}
+
+// names lists the options set in o by their constructors' names.
+func (o uploadOptions) names() []string {
+	var names []string
+	add := func(set bool, name string) {
+		if set {
+			names = append(names, name)
+		}
+	}
+	add(o.retention != 0, "WithRetention")
+	add(o.retentionTagKey != "", "WithRetentionTagKey")
+	add(o.lockMode != "", "WithObjectLock")
+	add(o.trailingChecksum, "WithTrailingChecksum")
+	add(o.transparentGzip, "WithTransparentGzip")
+	add(o.skipUnchanged, "WithSkipUnchanged")
+	add(o.prefixTemplate, "WithPrefixTemplate")
+	add(o.multipartThreshold != 0, "WithMultipartThreshold")
+	add(o.partSize != 0, "WithPartSize")
+	add(o.partConcurrency != 0, "WithPartConcurrency")
+	add(o.storageClass != "", "WithStorageClass")
+	add(o.sse != "" || o.sseKMSKeyID != "", "WithSSE")
+	add(o.acl != "", "WithACL")
+	return names
+}
+
+// WithRetention tags the uploaded object with an expiry timestamp of now+d
+// (RFC 3339, UTC) so tag-based lifecycle rules can expire it. Combine with
+// WithObjectLock to also set ObjectLockRetainUntilDate on buckets that have
+// object lock enabled. A zero d is ignored; a negative one is rejected.
+func WithRetention(d time.Duration) UploadOption {
+	return func(o *uploadOptions) {
+		if d < 0 {
+			o.reject("WithRetention", "negative duration %s", d)
+			return
+		}
+		o.retention = d
+	}
+}
+
+// WithRetentionTagKey overrides the tag key used by WithRetention.
+func WithRetentionTagKey(key string) UploadOption {
+	return func(o *uploadOptions) {
+		if key != "" {
+			o.retentionTagKey = key
+		}
+	}
+}
+
+// WithObjectLock sets the object lock mode applied alongside WithRetention,
+// which then also sets ObjectLockRetainUntilDate to the same expiry. Only use
+// this on buckets with object lock enabled — S3 rejects the request
+// otherwise.
+func WithObjectLock(mode types.ObjectLockMode) UploadOption {
+	return func(o *uploadOptions) {
+		if !slices.Contains(mode.Values(), mode) {
+			o.reject("WithObjectLock", "unknown mode %q", mode)
+			return
+		}
+		o.lockMode = mode
+	}
+}
+
+// WithStorageClass stores the uploaded object in class (STANDARD_IA,
+// GLACIER_IR, ...) instead of the bucket's default. Unknown classes are
+// rejected.
+func WithStorageClass(class types.StorageClass) UploadOption {
+	return func(o *uploadOptions) {
+		if !slices.Contains(class.Values(), class) {
+			o.reject("WithStorageClass", "unknown storage class %q", class)
+			return
+		}
+		o.storageClass = class
+	}
+}
+
+// WithSSE encrypts the uploaded object with sse instead of the bucket's
+// default encryption. kmsKeyID names the KMS key for aws:kms and
+// aws:kms:dsse; empty uses the AWS managed key. Unknown algorithms, and a
+// key ID given with AES256, are rejected.
+func WithSSE(sse types.ServerSideEncryption, kmsKeyID string) UploadOption {
+	return func(o *uploadOptions) {
+		if !slices.Contains(sse.Values(), sse) {
+			o.reject("WithSSE", "unknown server-side encryption %q", sse)
+			return
+		}
+		if kmsKeyID != "" && sse == types.ServerSideEncryptionAes256 {
+			o.reject("WithSSE", "KMS key ID given with %s", sse)
+			return
+		}
+		o.sse, o.sseKMSKeyID = sse, kmsKeyID
+	}
+}
+
+// WithACL applies a canned ACL to the uploaded object. Buckets with
+// ACLs disabled (the S3 default since 2023) reject any ACL but
+// bucket-owner-full-control. Unknown ACLs are rejected.
+func WithACL(acl types.ObjectCannedACL) UploadOption {
+	return func(o *uploadOptions) {
+		if !slices.Contains(acl.Values(), acl) {
+			o.reject("WithACL", "unknown canned ACL %q", acl)
+			return
+		}
+		o.acl = acl
+	}
+}
+
+// applyToPutInput copies the resolved options onto a PutObjectInput. It
+// fails with ErrInvalidArgument, reported under op, when WithRetention's
+// expiry tag takes the tag set over S3's limits.
+func (o uploadOptions) applyToPutInput(op string, input *s3.PutObjectInput) error {
+	if o.contentType != "" {
+		input.ContentType = aws.String(o.contentType)
+	}
+	input.StorageClass = o.storageClass
+	input.ServerSideEncryption = o.sse
+	input.SSEKMSKeyId = nilIfEmpty(o.sseKMSKeyID)
+	input.ACL = o.acl
+	if o.trailingChecksum {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+	if o.retention > 0 {
+		expiry := o.now().Add(o.retention).UTC().Truncate(time.Second)
+		tags := map[string]string{}
+		if input.Tagging != nil {
+			// Keep the File's own Tags alongside the expiry tag.
+			q, _ := url.ParseQuery(*input.Tagging)
+			for k := range q {
+				tags[k] = q.Get(k)
+			}
+		}
+		tags[o.retentionTagKey] = expiry.Format(time.RFC3339)
+		if err := checkS3Tags(op, tags); err != nil {
+			return err
+		}
+		input.Tagging = aws.String(encodeTagging(tags))
+		if o.lockMode != "" {
+			input.ObjectLockMode = o.lockMode
+			input.ObjectLockRetainUntilDate = aws.Time(expiry)
+		}
+	}
+	return nil
+}
+
+// --- Transfer options ---
+
+// TransferOption configures streaming transfers such as Pipe.
+type TransferOption func(*transferOptions)
+
+// transferOptions is the resolved set of TransferOption values.
+type transferOptions struct {
+	optionErrors
+	progress       func(transferred int64)
+	bytesPerSecond int64
+	algo           Algorithm
+	priority       Priority
+	prioritySet    bool
+	checkpoints    CheckpointStore
+	transferID     string
+	minThroughput  int64
+	window         time.Duration
+	retry          retryPolicy
+}
+
+// newTransferOptions applies opts over the defaults.
+func newTransferOptions(opts []TransferOption) transferOptions {
+	o := transferOptions{algo: AlgorithmSHA256, window: defaultThroughputWindow}
+	loadDefaults().applyTransfer(&o)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values and conflicting options.
+func (o transferOptions) validate() error {
+	errs := o.errs
+	if o.transferID != "" && o.checkpoints == nil {
+		errs = append(errs, errors.New("WithTransferID requires WithCheckpoint"))
+	}
+	if _, err := o.algo.newHash(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// WithProgress calls fn with the running byte count as data moves through a
+// transfer. fn runs on the transfer goroutine and should return quickly.
+func WithProgress(fn func(transferred int64)) TransferOption {
+	return func(o *transferOptions) {
+		o.progress = fn
+	}
+}
+
+// WithBandwidthLimit caps a transfer's average throughput at bytesPerSecond.
+// Zero means unlimited; a negative value is rejected.
+func WithBandwidthLimit(bytesPerSecond int64) TransferOption {
+	return func(o *transferOptions) {
+		if bytesPerSecond < 0 {
+			o.reject("WithBandwidthLimit", "negative rate %d", bytesPerSecond)
+			return
+		}
+		o.bytesPerSecond = bytesPerSecond
+	}
+}
+
+// WithMinThroughput aborts a transfer with ErrStalled when its average
+// throughput over the last window drops below bytesPerSecond, catching
+// connections that stay open but barely move data. No check is made until
+// one full window has passed. The abort takes effect at the source's next
+// read; a read that never returns cannot be interrupted. Both values must
+// be positive.
+func WithMinThroughput(bytesPerSecond int64, window time.Duration) TransferOption {
+	return func(o *transferOptions) {
+		if bytesPerSecond <= 0 || window <= 0 {
+			o.reject("WithMinThroughput", "rate %d and window %v must be positive", bytesPerSecond, window)
+			return
+		}
+		o.minThroughput = bytesPerSecond
+		o.window = window
+	}
+}
+
+// WithTransferChecksum selects the digest algorithm computed over the
+// transferred bytes (default AlgorithmSHA256). When the source published a
+// digest for the same algorithm, the transfer fails with ErrChecksumMismatch
+// if the bytes do not match it.
+func WithTransferChecksum(algo Algorithm) TransferOption {
+	return func(o *transferOptions) {
+		if _, err := algo.newHash(); err != nil {
+			o.reject("WithTransferChecksum", "%v", err)
+			return
+		}
+		o.algo = algo
+	}
+}
+
+// WithPriority sets the queueing priority Pipe uses under the default
+// scheduler (SetDefaultScheduler), for the transfer itself and for
+// fetching a source that is not loaded yet. It overrides
+// ContextWithPriority and Defaults.Priority for that call, and has no
+// effect when no scheduler is installed. Other operations take their
+// priority from the context; see ContextWithPriority.
+func WithPriority(p Priority) TransferOption {
+	return func(o *transferOptions) {
+		if p < PriorityInteractive || p > PriorityBatch {
+			o.reject("WithPriority", "unknown priority %d", p)
+			return
+		}
+		o.priority = p
+		o.prioritySet = true
+	}
+}
@@ -0,0 +1,200 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestCheckOptions(t *testing.T) {
+	store := FileCheckpointStore{Dir: t.TempDir()}
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr string // substring; empty means valid
+	}{
+		{"none", nil, ""},
+		{"valid mix", []Option{
+			WithVerify(AlgorithmMD5),
+			WithVerifyMode(VerifySourceDigest),
+			WithCollisionStrategy(CollisionRenameWithSuffix),
+			WithRetention(time.Hour),
+			WithObjectLock(types.ObjectLockModeCompliance),
+			WithBandwidthLimit(0),
+			WithPriority(PriorityBatch),
+			WithCheckpoint(store),
+			WithTransferID("job-1"),
+			MetadataHint{Name: "a.txt"},
+			WithRetry(3, time.Second),
+		}, ""},
+
+		// Documented conflicts.
+		{"verify mode without verify", []Option{WithVerifyMode(VerifySourceDigest)}, "WithVerifyMode requires WithVerify"},
+		{"object lock without retention", []Option{WithObjectLock(types.ObjectLockModeGovernance)}, "WithObjectLock requires WithRetention"},
+		{"object lock with zero retention", []Option{WithRetention(0), WithObjectLock(types.ObjectLockModeGovernance)}, "WithObjectLock requires WithRetention"},
+		{"transfer ID without checkpoint", []Option{WithTransferID("job-1")}, "WithTransferID requires WithCheckpoint"},
+
+		// Out-of-range values.
+		{"unknown verify algorithm", []Option{WithVerify("crc32")}, "WithVerify"},
+		{"unknown verify mode", []Option{WithVerify(AlgorithmSHA256), WithVerifyMode(VerifyMode(7))}, "WithVerifyMode: unknown mode 7"},
+		{"unknown collision strategy", []Option{WithCollisionStrategy(-1)}, "WithCollisionStrategy: unknown strategy -1"},
+		{"negative retention", []Option{WithRetention(-time.Second)}, "WithRetention: negative duration"},
+		{"unknown lock mode", []Option{WithRetention(time.Hour), WithObjectLock("FOREVER")}, `WithObjectLock: unknown mode "FOREVER"`},
+		{"negative bandwidth", []Option{WithBandwidthLimit(-1)}, "WithBandwidthLimit: negative rate -1"},
+		{"unknown transfer checksum", []Option{WithTransferChecksum("sha1")}, "WithTransferChecksum"},
+		{"unknown priority", []Option{WithPriority(Priority(9))}, "WithPriority: unknown priority 9"},
+		{"nil checkpoint store", []Option{WithCheckpoint(nil)}, "WithCheckpoint: nil store"},
+		{"empty transfer ID", []Option{WithCheckpoint(store), WithTransferID("")}, "WithTransferID: empty ID"},
+		{"unknown storage class", []Option{WithStorageClass("COLD")}, `WithStorageClass: unknown storage class "COLD"`},
+		{"unknown SSE", []Option{WithSSE("rot13", "")}, `WithSSE: unknown server-side encryption "rot13"`},
+		{"KMS key with AES256", []Option{WithSSE(types.ServerSideEncryptionAes256, "key-1")}, "WithSSE: KMS key ID given with AES256"},
+		{"unknown ACL", []Option{WithACL("everyone")}, `WithACL: unknown canned ACL "everyone"`},
+		{"read option next to a hint", []Option{MetadataHint{Name: "a.txt"}, WithTimeout(-time.Second)}, "WithTimeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckOptions(tt.opts...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidOption) {
+				t.Fatalf("err = %v, want ErrInvalidOption", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckOptions_ReportsEveryProblem(t *testing.T) {
+	err := CheckOptions(WithVerifyMode(VerifySourceDigest), WithBandwidthLimit(-1), WithRetention(-time.Hour))
+	for _, want := range []string{"WithVerifyMode", "WithBandwidthLimit", "WithRetention"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+// Operations reject invalid options before touching the filesystem or the
+// network.
+func TestInvalidOptions_FailBeforeIO(t *testing.T) {
+	s3Calls := 0
+	mock := &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			s3Calls++
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	f, _ := NewFromBytes([]byte("hello"), MetadataHint{Name: "a.txt"})
+	dir := t.TempDir()
+
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"Save", func() error {
+			_, err := f.Save(filepath.Join(dir, "save.txt"), WithVerifyMode(VerifySourceDigest))
+			return err
+		}},
+		{"SaveToDir", func() error {
+			_, err := f.SaveToDir(dir, WithCollisionStrategy(42))
+			return err
+		}},
+		{"CommitAll", func() error {
+			return CommitAll(map[string]*File{"c.txt": f}, dir, WithVerify("crc32"))
+		}},
+		{"DownloadS3ToFile", func() error {
+			_, err := DownloadS3ToFile(context.Background(), "bucket", "k", filepath.Join(dir, "dl.txt"), WithVerifyMode(VerifyReread))
+			return err
+		}},
+		{"UploadToS3", func() error {
+			return f.UploadToS3("bucket", "k", WithObjectLock(types.ObjectLockModeGovernance))
+		}},
+		{"Pipe", func() error {
+			_, err := Pipe(context.Background(), f, &PathSink{Path: filepath.Join(dir, "pipe.txt")}, WithTransferID("x"))
+			return err
+		}},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.run()
+			if !errors.Is(err, ErrInvalidOption) {
+				t.Fatalf("err = %v, want ErrInvalidOption", err)
+			}
+			var fe *FileError
+			if !errors.As(err, &fe) || fe.Op != c.name {
+				t.Errorf("Op = %v, want %s", fe, c.name)
+			}
+		})
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("files written despite invalid options: %v", entries)
+	}
+	if s3Calls != 0 {
+		t.Errorf("S3 called %d times", s3Calls)
+	}
+}
+
+// Every constructor rejects invalid read options before reading anything.
+func TestConstructors_RejectInvalidReadOptions(t *testing.T) {
+	defer setMockS3(&mockS3Client{}, &mockPresignClient{})()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fh := multipartFileHeader(t, "a.txt", []byte("hello"))
+	bad := []ReadOption{WithRetry(0, 0), WithTimeout(-time.Second)}
+
+	constructors := []struct {
+		name string
+		run  func(opts ...ReadOption) (*File, error)
+	}{
+		{"NewFromBytes", func(opts ...ReadOption) (*File, error) { return NewFromBytes([]byte("hello"), opts...) }},
+		{"NewFromFile", func(opts ...ReadOption) (*File, error) { return NewFromFile(path, opts...) }},
+		{"NewFromFileLazy", func(opts ...ReadOption) (*File, error) { return NewFromFileLazy(path, opts...) }},
+		{"NewFromMultipartFile", func(opts ...ReadOption) (*File, error) { return NewFromMultipartFile(fh, opts...) }},
+		{"NewFromStream", func(opts ...ReadOption) (*File, error) { return NewFromStream(strings.NewReader("hello"), opts...) }},
+		{"NewFromStreamLazy", func(opts ...ReadOption) (*File, error) {
+			return NewFromStreamLazy(strings.NewReader("hello"), opts...)
+		}},
+		{"NewFromS3", func(opts ...ReadOption) (*File, error) { return NewFromS3("bucket", "k", opts...) }},
+		{"NewFromFS", func(opts ...ReadOption) (*File, error) { return NewFromFS(os.DirFS(dir), "a.txt", opts...) }},
+		{"NewFromReaderAt", func(opts ...ReadOption) (*File, error) {
+			return NewFromReaderAt(strings.NewReader("hello"), 5, opts...)
+		}},
+		{"NewFromStorage", func(opts ...ReadOption) (*File, error) {
+			return NewFromStorage(context.Background(), "mem://bucket/a.txt", opts...)
+		}},
+		{"NewFromDataURI", func(opts ...ReadOption) (*File, error) { return NewFromDataURI("data:,hello", opts...) }},
+	}
+	for _, c := range constructors {
+		for _, opt := range bad {
+			t.Run(c.name, func(t *testing.T) {
+				_, err := c.run(opt)
+				if !errors.Is(err, ErrInvalidOption) {
+					t.Fatalf("err = %v, want ErrInvalidOption", err)
+				}
+				var fe *FileError
+				if !errors.As(err, &fe) || fe.Op != c.name {
+					t.Errorf("Op = %v, want %s", fe, c.name)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// PDFInfo holds cheap structural facts about a PDF, gathered without a full
+// parser.
+type PDFInfo struct {
+	// PageCount is the number of pages: the /Count of the page tree root
+	// when it can be found, otherwise the number of /Type /Page objects.
+	// Approximate for PDFs that keep their page tree in compressed object
+	// streams.
+	PageCount int
+	// Encrypted reports whether the trailer references an /Encrypt
+	// dictionary.
+	Encrypted bool
+	// PDFVersion is the version from the %PDF-x.y header, e.g. "1.7".
+	PDFVersion string
+}
+
+// pdfTailBytes bounds how much of the end of the file is read to find the
+// trailer.
+const pdfTailBytes = 32 * 1024
+
+// pdfScanChunk is the chunk size used when scanning for page objects.
+const pdfScanChunk = 64 * 1024
+
+// pdfScanOverlap is carried between chunks so a match split across a chunk
+// boundary is still found. Must exceed the longest match of the scan
+// patterns.
+const pdfScanOverlap = 1024
+
+var (
+	pdfHeaderRe  = regexp.MustCompile(`^%PDF-(\d+\.\d+)`)
+	pdfPageRe    = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfPagesRe   = regexp.MustCompile(`<<[^<>]{0,400}/Type\s*/Pages\b[^<>]{0,400}>>`)
+	pdfCountRe   = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfEncryptRe = regexp.MustCompile(`/Encrypt\s`)
+	pdfEOFMarker = []byte("%%EOF")
+)
+
+// PDFInfo returns the page count, encryption flag and header version of a
+// PDF. The content is scanned in chunks and the trailer is found with a
+// bounded read of the tail, so ReaderAt-backed files are never buffered in
+// full (lazy streams are drained, as with Checksum). Content without a %PDF
+// header, or truncated before its %%EOF marker, returns ErrNotPDF.
+func (f *File) PDFInfo() (PDFInfo, error) {
+	head, err := f.prefixReader()
+	if err != nil {
+		return PDFInfo{}, err
+	}
+	buf := make([]byte, 16)
+	n, err := io.ReadFull(head, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return PDFInfo{}, newError(ErrRead, "PDFInfo", err)
+	}
+	m := pdfHeaderRe.FindSubmatch(buf[:n])
+	if m == nil {
+		return PDFInfo{}, newError(ErrNotPDF, "PDFInfo", fmt.Errorf("missing %%PDF header"))
+	}
+	info := PDFInfo{PDFVersion: string(m[1])}
+
+	tail, err := f.tailBytes(pdfTailBytes, "PDFInfo")
+	if err != nil {
+		return PDFInfo{}, err
+	}
+	if !bytes.Contains(tail, pdfEOFMarker) {
+		return PDFInfo{}, newError(ErrNotPDF, "PDFInfo", fmt.Errorf("truncated: no %%%%EOF marker"))
+	}
+	info.Encrypted = pdfEncryptRe.Match(tail)
+
+	r, err := f.contentReader(context.Background())
+	if err != nil {
+		return PDFInfo{}, err
+	}
+	pages, treeCount, err := scanPDFPages(r)
+	if err != nil {
+		return PDFInfo{}, newError(ErrRead, "PDFInfo", err)
+	}
+	info.PageCount = pages
+	if treeCount > 0 {
+		info.PageCount = treeCount
+	}
+	return info, nil
+}
+
+// scanPDFPages counts /Type /Page objects and finds the largest page tree
+// /Count (the root's) in r.
+func scanPDFPages(r io.Reader) (pages, treeCount int, err error) {
+	var carry []byte
+	chunk := make([]byte, pdfScanChunk)
+	for {
+		n, readErr := r.Read(chunk)
+		if n > 0 {
+			window := append(carry, chunk[:n]...)
+			// Only count matches that end past the carried-over prefix;
+			// the others were counted with the previous window.
+			for _, loc := range pdfPageRe.FindAllIndex(window, -1) {
+				if loc[1] > len(carry) {
+					pages++
+				}
+			}
+			for _, loc := range pdfPagesRe.FindAllIndex(window, -1) {
+				if loc[1] <= len(carry) {
+					continue
+				}
+				if c := pdfCountRe.FindSubmatch(window[loc[0]:loc[1]]); c != nil {
+					if v, convErr := strconv.Atoi(string(c[1])); convErr == nil && v > treeCount {
+						treeCount = v
+					}
+				}
+			}
+			keep := min(len(window), pdfScanOverlap)
+			carry = append(carry[:0:0], window[len(window)-keep:]...)
+		}
+		if readErr == io.EOF {
+			return pages, treeCount, nil
+		}
+		if readErr != nil {
+			return 0, 0, readErr
+		}
+	}
+}
+
+// tailBytes returns up to the last n bytes of the content. ReaderAt-backed
+// files read just the tail; other sources use the buffered content.
+func (f *File) tailBytes(n int64, op string) ([]byte, error) {
+	if f.readerAt != nil && !f.loaded {
+		off := max(f.readerAtSize-n, 0)
+		buf := make([]byte, f.readerAtSize-off)
+		if _, err := f.readerAt.ReadAt(buf, off); err != nil && err != io.EOF {
+			return nil, newError(ErrRead, op, err)
+		}
+		return buf, nil
+	}
+	data, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	return data[max(int64(len(data))-n, 0):], nil
+}
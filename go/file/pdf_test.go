@@ -0,0 +1,107 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a minimal PDF with the given number of pages. The
+// xref offsets are not accurate; PDFInfo does not use them.
+func buildPDF(version string, pages int, encrypted bool) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+	b.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	kids := make([]string, pages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", i+3)
+	}
+	fmt.Fprintf(&b, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), pages)
+	for i := 0; i < pages; i++ {
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>\nendobj\n", i+3)
+	}
+	b.WriteString("xref\n0 1\n0000000000 65535 f \ntrailer\n<< /Size 5 /Root 1 0 R")
+	if encrypted {
+		b.WriteString(" /Encrypt 9 0 R /ID [<ab> <ab>]")
+	}
+	b.WriteString(" >>\nstartxref\n0\n%%EOF\n")
+	return []byte(b.String())
+}
+
+func TestPDFInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want PDFInfo
+	}{
+		{name: "two pages", data: buildPDF("1.7", 2, false), want: PDFInfo{PageCount: 2, PDFVersion: "1.7"}},
+		{name: "encrypted", data: buildPDF("1.4", 1, true), want: PDFInfo{PageCount: 1, Encrypted: true, PDFVersion: "1.4"}},
+		{
+			name: "no page tree falls back to page objects",
+			data: bytes.Replace(buildPDF("2.0", 3, false), []byte("/Type /Pages"), []byte("/Type /Unknown"), 1),
+			want: PDFInfo{PageCount: 3, PDFVersion: "2.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewFromBytes(tt.data)
+			got, err := f.PDFInfo()
+			if err != nil {
+				t.Fatalf("PDFInfo: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PDFInfo = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFInfo_ReaderAtChunkBoundaries(t *testing.T) {
+	// Enough pages to span several scan chunks, read through a ReaderAt so
+	// the tail is fetched with a bounded read.
+	data := buildPDF("1.6", 1500, false)
+	f, err := NewFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.PDFInfo()
+	if err != nil {
+		t.Fatalf("PDFInfo: %v", err)
+	}
+	if got.PageCount != 1500 {
+		t.Errorf("PageCount = %d, want 1500", got.PageCount)
+	}
+
+	stripped := bytes.Replace(data, []byte("/Type /Pages"), []byte("/Type /Unknown"), 1)
+	f, _ = NewFromReaderAt(bytes.NewReader(stripped), int64(len(stripped)))
+	got, err = f.PDFInfo()
+	if err != nil {
+		t.Fatalf("PDFInfo: %v", err)
+	}
+	if got.PageCount != 1500 {
+		t.Errorf("page-object count = %d, want 1500", got.PageCount)
+	}
+}
+
+func TestPDFInfo_Errors(t *testing.T) {
+	full := buildPDF("1.7", 2, false)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated", data: full[:len(full)/2]},
+		{name: "not a pdf", data: []byte("hello, world")},
+		{name: "empty", data: []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewFromBytes(tt.data)
+			_, err := f.PDFInfo()
+			if !errors.Is(err, ErrNotPDF) {
+				t.Errorf("expected ErrNotPDF, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Sink is the destination side of Pipe. WriteFrom consumes r until EOF,
+// pulling data only as fast as it can store it, and returns the number of
+// bytes written. meta describes the content (name, MIME type) for sinks
+// that record it. When r fails, WriteFrom must not leave the content
+// behind as if it had been written.
+type Sink interface {
+	WriteFrom(ctx context.Context, r io.Reader, meta Metadata) (int64, error)
+}
+
+// TransferResult summarizes a completed transfer.
+type TransferResult struct {
+	// Bytes is the number of content bytes transferred.
+	Bytes int64
+	// Algorithm is the digest algorithm used for Digest.
+	Algorithm Algorithm
+	// Digest is the hex digest of the transferred bytes.
+	Digest string
+	// Duration is how long the transfer took.
+	Duration time.Duration
+	// AvgThroughput is Bytes over Duration, in bytes per second.
+	AvgThroughput int64
+	// MinThroughput is the lowest rolling average observed, in bytes per
+	// second, over the WithMinThroughput window (one second by default).
+	// It equals AvgThroughput for transfers shorter than the window.
+	MinThroughput int64
+	// Skipped reports that nothing was sent because the destination
+	// already held the content (see WithSkipUnchanged).
+	Skipped bool
+}
+
+// Pipe streams src's content into sink without materializing it: bytes flow
+// through a bounded buffer, so a lazy stream (say, an HTTP response body
+// wrapped with NewFromStreamLazy) can be copied to S3 in constant memory.
+// The digest of the streamed bytes is always computed; see
+// WithTransferChecksum, WithProgress and WithBandwidthLimit. When src
+// carries a digest for the algorithm and the bytes do not match it, the
+// sink's read fails at EOF, so the built-in sinks abort instead of
+// committing, and Pipe returns ErrChecksumMismatch. Lazy sources are
+// consumed by the transfer.
+func Pipe(ctx context.Context, src *File, sink Sink, opts ...TransferOption) (TransferResult, error) {
+	o := newTransferOptions(opts)
+	if err := checkOptions("Pipe", o.validate()); err != nil {
+		return TransferResult{}, err
+	}
+	start := time.Now()
+
+	h, err := o.algo.newHash()
+	if err != nil {
+		return TransferResult{}, newError(ErrInvalidOption, "Pipe", err)
+	}
+	if o.prioritySet {
+		ctx = ContextWithPriority(ctx, o.priority)
+	}
+	ctx, release, err := acquireTransfer(ctx, ClassUpload)
+	if err != nil {
+		return TransferResult{}, newError(ErrWrite, "Pipe", err)
+	}
+	defer release()
+
+	// A source that is not loaded yet is fetched under ctx, inside the
+	// slot just acquired.
+	r, err := src.openStream(ctx)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	// With a source digest to check, the mismatch surfaces as a read error
+	// at EOF, before the sink commits anything.
+	checked := &digestReader{r: io.TeeReader(r, h), h: h, algo: o.algo, want: src.sourceDigests[o.algo], op: "Pipe"}
+	var body io.Reader = &progressReader{r: checked, fn: o.progress}
+	if o.bytesPerSecond > 0 {
+		body = &rateLimitedReader{ctx: ctx, r: body, bytesPerSecond: o.bytesPerSecond, start: start}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	meter := newThroughputMeter(start, o.window)
+	body = &meteredReader{ctx: ctx, r: body, m: meter}
+	if o.minThroughput > 0 {
+		go meter.watch(ctx, cancel, o.minThroughput)
+	}
+
+	var n int64
+	if cs, ok := sink.(*S3Sink); ok && o.checkpoints != nil {
+		n, err = cs.writeWithCheckpoint(ctx, body, src.meta.clone(), o.checkpoints, o.transferID)
+	} else {
+		n, err = sink.WriteFrom(ctx, body, src.meta.clone())
+	}
+	if err != nil {
+		if checked.mismatch != nil {
+			return TransferResult{}, checked.mismatch
+		}
+		var stall *stallError
+		if errors.As(context.Cause(ctx), &stall) {
+			return TransferResult{}, newError(ErrStalled, "Pipe", stall)
+		}
+		if errors.Is(context.Cause(ctx), ErrShuttingDown) {
+			return TransferResult{}, newError(ErrShuttingDown, "Pipe", err)
+		}
+		return TransferResult{}, err
+	}
+	end := time.Now()
+	res := TransferResult{
+		Bytes:     n,
+		Algorithm: o.algo,
+		Digest:    hex.EncodeToString(h.Sum(nil)),
+		Duration:  end.Sub(start),
+	}
+	res.AvgThroughput, res.MinThroughput = meter.stats(end)
+	if want := src.sourceDigests[o.algo]; want != "" && want != res.Digest {
+		return res, newError(ErrChecksumMismatch, "Pipe", fmt.Errorf("%s of transferred bytes %s does not match source digest %s", o.algo, res.Digest, want))
+	}
+	return res, nil
+}
+
+// --- Sinks ---
+
+// DefaultPartSize is the multipart part size S3Sink uses when PartSize is
+// unset. S3Sink buffers one part at a time.
+const DefaultPartSize = 8 * 1024 * 1024
+
+// minPartSize is S3's minimum size for every part but the last.
+const minPartSize = 5 * 1024 * 1024
+
+// S3Sink writes to an S3 object. Content that fits in one part is sent with
+// a single PutObject; anything larger streams through a multipart upload,
+// one part in memory at a time, and the upload is aborted on failure. The
+// object gets the same headers, user metadata and tags from the source's
+// Metadata as UploadToS3 would give it.
+type S3Sink struct {
+	Bucket string
+	Key    string
+	// PartSize is the multipart part size; values below S3's 5 MiB minimum
+	// are raised to it. Defaults to WithPartSize from Options, or
+	// DefaultPartSize. Parts double in size every thousand parts, up to
+	// S3's 5 GiB maximum, so a long stream does not run out of parts.
+	PartSize int64
+	// TrailingChecksum sends each request as an aws-chunked body with a
+	// trailing x-amz-checksum-sha256 computed as the bytes flow, which S3
+	// verifies before storing them. See WithTrailingChecksum.
+	TrailingChecksum bool
+	// Options are UploadToS3's options for the object. WithRetention,
+	// WithRetentionTagKey, WithObjectLock, WithTrailingChecksum,
+	// WithStorageClass, WithSSE, WithACL and WithPartSize apply; any other
+	// fails the transfer with ErrInvalidOption.
+	Options []UploadOption
+}
+
+// WriteFrom implements Sink.
+func (s *S3Sink) WriteFrom(ctx context.Context, r io.Reader, meta Metadata) (int64, error) {
+	return s.write(ctx, r, meta, nil, "")
+}
+
+// writeWithCheckpoint is WriteFrom with progress recorded in store under
+// id (the s3:// URI when empty). See WithCheckpoint.
+func (s *S3Sink) writeWithCheckpoint(ctx context.Context, r io.Reader, meta Metadata, store CheckpointStore, id string) (int64, error) {
+	if id == "" {
+		id = fmt.Sprintf("s3://%s/%s", s.Bucket, s.Key)
+	}
+	return s.write(ctx, r, meta, store, id)
+}
+
+// write implements WriteFrom; store is nil when not checkpointing.
+func (s *S3Sink) write(ctx context.Context, r io.Reader, meta Metadata, store CheckpointStore, id string) (int64, error) {
+	o := newUploadOptions(s.Options)
+	o.rejectUnsupported("S3Sink", s.Options, "WithRetention", "WithRetentionTagKey", "WithObjectLock",
+		"WithTrailingChecksum", "WithStorageClass", "WithSSE", "WithACL", "WithPartSize")
+	if err := checkOptions("Pipe", o.validate()); err != nil {
+		return 0, err
+	}
+	key, err := checkS3Object("Pipe", s.Bucket, s.Key)
+	if err != nil {
+		return 0, err
+	}
+	if key != s.Key {
+		normalized := *s
+		normalized.Key = key
+		s = &normalized
+	}
+	input, err := s3PutInput("Pipe", s.Bucket, s.Key, meta)
+	if err != nil {
+		return 0, err
+	}
+	if err := o.applyToPutInput("Pipe", input); err != nil {
+		return 0, err
+	}
+	if s.TrailingChecksum {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+
+	partSize := s.PartSize
+	if partSize <= 0 {
+		partSize = o.partSize
+	}
+	partSize = max(partSize, minPartSize)
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, 0)
+	defer cancel()
+	s3Client, _ := s3Clients()
+	buf := make([]byte, partSize)
+
+	// A resumable checkpoint means the content spans several parts, so
+	// skip the single-PutObject shortcut.
+	cp, resumed := s.resumeCheckpoint(ctx, s3Client, store, id, partSize, input.ChecksumAlgorithm)
+
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, newError(ErrRead, "Pipe", err)
+	}
+	if err != nil && !resumed {
+		// Everything fit in one part.
+		input.Body = bytes.NewReader(buf[:n])
+		input.ContentLength = aws.Int64(int64(n))
+		if _, err := s3Client.PutObject(ctx, input); err != nil {
+			return 0, dl.newError(ctx, ErrS3, "Pipe", err)
+		}
+		return int64(n), nil
+	}
+
+	m := &multipartUpload{
+		client:      s3Client,
+		input:       input,
+		op:          "Pipe",
+		dl:          dl,
+		partSize:    partSize,
+		concurrency: 1,
+		store:       store,
+		id:          id,
+		cp:          cp,
+		resumed:     resumed,
+	}
+	return m.run(ctx, io.MultiReader(bytes.NewReader(buf[:n]), r), -1)
+}
+
+// resumeCheckpoint loads the checkpoint for id and keeps only the parts S3
+// still lists with the recorded ETag. It reports false when there is
+// nothing to resume (no store, no checkpoint, a different destination or
+// part size or checksum setting, or an upload S3 no longer knows).
+func (s *S3Sink) resumeCheckpoint(ctx context.Context, s3Client S3API, store CheckpointStore, id string, partSize int64, algo types.ChecksumAlgorithm) (Checkpoint, bool) {
+	if store == nil {
+		return Checkpoint{}, false
+	}
+	cp, err := store.Load(ctx, id)
+	if err != nil || cp.UploadID == "" || cp.Bucket != s.Bucket || cp.Key != s.Key || cp.PartSize != partSize ||
+		cp.ChecksumAlgorithm != string(algo) {
+		return Checkpoint{}, false
+	}
+
+	remote := map[int32]string{}
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(s.Key),
+		UploadId: aws.String(cp.UploadID),
+	}
+	for {
+		out, err := s3Client.ListParts(ctx, input)
+		if err != nil {
+			return Checkpoint{}, false
+		}
+		for _, p := range out.Parts {
+			remote[aws.ToInt32(p.PartNumber)] = aws.ToString(p.ETag)
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		input.PartNumberMarker = out.NextPartNumberMarker
+	}
+
+	kept := cp.Parts[:0]
+	for _, p := range cp.Parts {
+		if remote[p.Number] == p.ETag {
+			kept = append(kept, p)
+		}
+	}
+	cp.Parts = kept
+	return cp, true
+}
+
+// etagMatches reports whether a part ETag is the MD5 of data, which holds
+// for parts uploaded without SSE-KMS.
+func etagMatches(etag string, data []byte) bool {
+	sum := md5.Sum(data)
+	return strings.Trim(etag, `"`) == hex.EncodeToString(sum[:])
+}
+
+// sortedParts returns the recorded parts ordered by part number.
+func sortedParts(m map[int32]CheckpointPart) []CheckpointPart {
+	out := make([]CheckpointPart, 0, len(m))
+	for _, p := range m {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Number < out[j].Number })
+	return out
+}
+
+// PathSink writes to a local file, creating parent directories as needed.
+type PathSink struct {
+	Path string
+}
+
+// WriteFrom implements Sink.
+func (s *PathSink) WriteFrom(ctx context.Context, r io.Reader, meta Metadata) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return 0, newError(ErrWrite, "Pipe", err)
+	}
+	counted := &countingReader{r: r}
+	if err := writeFromReader(counted, s.Path, saveOptions{}, "", "Pipe"); err != nil {
+		return 0, err
+	}
+	return counted.n, nil
+}
+
+// URLSink PUTs the content to a URL, such as one from
+// CreatePresignedUploadURL. The body is streamed with chunked encoding, so
+// unlike UploadToURL it is sent once and never retried.
+type URLSink struct {
+	URL string
+}
+
+// WriteFrom implements Sink.
+func (s *URLSink) WriteFrom(ctx context.Context, r io.Reader, meta Metadata) (int64, error) {
+	counted := &countingReader{r: r}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.URL, io.NopCloser(counted))
+	if err != nil {
+		return 0, newError(ErrHTTP, "Pipe", err)
+	}
+	if meta.MimeType != "" {
+		req.Header.Set("Content-Type", meta.MimeType)
+	}
+	resp, err := CurrentHTTPClient().Do(req)
+	if err != nil {
+		return 0, newError(ErrHTTP, "Pipe", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, httpStatusError("Pipe", resp)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return counted.n, nil
+}
+
+// --- Transfer readers ---
+
+// digestReader passes r through and, when r reaches EOF, checks the digest
+// h has accumulated against want. A mismatch replaces io.EOF with an
+// ErrChecksumMismatch error for op, also kept in mismatch. h must see
+// every byte r yields.
+type digestReader struct {
+	r        io.Reader
+	h        hash.Hash
+	algo     Algorithm
+	want     string
+	op       string
+	mismatch error
+}
+
+func (d *digestReader) Read(b []byte) (int, error) {
+	n, err := d.r.Read(b)
+	if err == io.EOF && d.want != "" {
+		if got := hex.EncodeToString(d.h.Sum(nil)); got != d.want {
+			d.mismatch = newError(ErrChecksumMismatch, d.op, fmt.Errorf("%s of transferred bytes %s does not match source digest %s", d.algo, got, d.want))
+			return n, d.mismatch
+		}
+	}
+	return n, err
+}
+
+// progressReader reports the running byte count after every read.
+type progressReader struct {
+	r  io.Reader
+	n  int64
+	fn func(int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		if p.fn != nil {
+			p.fn(p.n)
+		}
+	}
+	return n, err
+}
+
+// rateLimitedReader throttles reads so the average rate since start stays
+// at or below bytesPerSecond.
+type rateLimitedReader struct {
+	ctx            context.Context
+	r              io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	n              int64
+}
+
+func (l *rateLimitedReader) Read(b []byte) (int, error) {
+	// Keep individual reads to at most ~1/10 s worth of data so pacing is
+	// smooth even with large buffers.
+	if chunk := max(l.bytesPerSecond/10, 1); int64(len(b)) > chunk {
+		b = b[:chunk]
+	}
+	n, err := l.r.Read(b)
+	l.n += int64(n)
+	due := l.start.Add(time.Duration(float64(l.n) / float64(l.bytesPerSecond) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-l.ctx.Done():
+			return n, l.ctx.Err()
+		}
+	}
+	return n, err
+}
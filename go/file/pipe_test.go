@@ -0,0 +1,343 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/text/unicode/norm"
+)
+
+// syntheticBody returns a deterministic pseudo-random stream of n bytes.
+func syntheticBody(n int64) io.Reader {
+	return io.LimitReader(rand.New(rand.NewSource(42)), n)
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+func TestPipe_HTTPToS3MultipartConstantMemory(t *testing.T) {
+	const size = 50 << 20
+
+	want := sha256.New()
+	io.Copy(want, syntheticBody(size))
+	wantDigest := hex.EncodeToString(want.Sum(nil))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, syntheticBody(size))
+	}))
+	defer srv.Close()
+
+	received := sha256.New()
+	var (
+		parts     int
+		completed bool
+		peak      uint64
+	)
+	cleanup := setMockS3(&mockS3Client{
+		createMultipartUploadFn: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+		},
+		uploadPartFn: func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			parts++
+			if *params.PartNumber != int32(parts) {
+				t.Errorf("part number %d, want %d", *params.PartNumber, parts)
+			}
+			io.Copy(received, params.Body)
+			peak = max(peak, heapAlloc())
+			return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf(`"etag-%d"`, parts))}, nil
+		},
+		completeMultipartUploadFn: func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			completed = len(params.MultipartUpload.Parts) == parts
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	src, err := NewFromStreamLazy(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseline := heapAlloc()
+	res, err := Pipe(context.Background(), src, &S3Sink{Bucket: "bucket", Key: "big.bin"})
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+
+	if res.Bytes != size {
+		t.Errorf("Bytes = %d, want %d", res.Bytes, size)
+	}
+	if res.Digest != wantDigest {
+		t.Errorf("Digest = %s, want %s", res.Digest, wantDigest)
+	}
+	if got := hex.EncodeToString(received.Sum(nil)); got != wantDigest {
+		t.Errorf("S3 received digest %s, want %s", got, wantDigest)
+	}
+	if wantParts := (size + DefaultPartSize - 1) / DefaultPartSize; parts != wantParts || !completed {
+		t.Errorf("parts = %d (completed %v), want %d", parts, completed, wantParts)
+	}
+	if grew := int64(peak) - int64(baseline); grew > 3*DefaultPartSize {
+		t.Errorf("heap grew by %d bytes during a %d-byte pipe; expected a bounded buffer", grew, size)
+	}
+}
+
+func TestPipe_S3SinkAbortsOnFailure(t *testing.T) {
+	var aborted bool
+	cleanup := setMockS3(&mockS3Client{
+		createMultipartUploadFn: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("u")}, nil
+		},
+		uploadPartFn: func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			return nil, errors.New("part failed")
+		},
+		abortMultipartUploadFn: func(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			aborted = *params.UploadId == "u"
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	src, _ := NewFromReaderAt(bytes.NewReader(make([]byte, minPartSize+1)), minPartSize+1)
+	_, err := Pipe(context.Background(), src, &S3Sink{Bucket: "bucket", Key: "k", PartSize: 1})
+	if !errors.Is(err, ErrS3) {
+		t.Fatalf("expected ErrS3, got %v", err)
+	}
+	if !aborted {
+		t.Error("multipart upload should be aborted")
+	}
+}
+
+func TestPipe_S3SinkSmallContentUsesPutObject(t *testing.T) {
+	var input *s3.PutObjectInput
+	cleanup := setMockS3(capturePut(&input), &mockPresignClient{})
+	defer cleanup()
+
+	src, _ := NewFromBytes([]byte("small"), MetadataHint{MimeType: "text/plain"})
+	res, err := Pipe(context.Background(), src, &S3Sink{Bucket: "bucket", Key: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input == nil || res.Bytes != 5 || !strings.HasPrefix(aws.ToString(input.ContentType), "text/plain") {
+		t.Errorf("PutObject input = %+v, result = %+v", input, res)
+	}
+}
+
+func TestPipe_S3SinkCarriesUploadSettings(t *testing.T) {
+	var created *s3.CreateMultipartUploadInput
+	cleanup := setMockS3(&mockS3Client{
+		createMultipartUploadFn: func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			created = params
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("u")}, nil
+		},
+		uploadPartFn: func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			io.Copy(io.Discard, params.Body)
+			return &s3.UploadPartOutput{ETag: aws.String(`"e"`)}, nil
+		},
+		completeMultipartUploadFn: func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	src, _ := NewFromReaderAt(bytes.NewReader(make([]byte, minPartSize+1)), minPartSize+1, MetadataHint{
+		Name:       "report.pdf",
+		Attributes: map[string]string{"tenant": "acme"},
+		Tags:       map[string]string{"team": "ops"},
+	})
+	sink := &S3Sink{Bucket: "bucket", Key: "k", PartSize: minPartSize, Options: []UploadOption{
+		WithStorageClass(types.StorageClassStandardIa),
+		WithSSE(types.ServerSideEncryptionAwsKms, "key-1"),
+		WithRetention(time.Hour),
+		WithObjectLock(types.ObjectLockModeGovernance),
+	}}
+	if _, err := Pipe(context.Background(), src, sink); err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case created == nil:
+		t.Fatal("no multipart upload was created")
+	case created.StorageClass != types.StorageClassStandardIa,
+		created.ServerSideEncryption != types.ServerSideEncryptionAwsKms || aws.ToString(created.SSEKMSKeyId) != "key-1",
+		created.ObjectLockMode != types.ObjectLockModeGovernance || created.ObjectLockRetainUntilDate == nil,
+		created.Metadata["tenant"] != "acme",
+		!strings.Contains(aws.ToString(created.ContentDisposition), "report.pdf"),
+		!strings.Contains(aws.ToString(created.Tagging), "team=ops"):
+		t.Errorf("CreateMultipartUpload input = %+v", created)
+	}
+
+	src, _ = NewFromBytes([]byte("x"))
+	_, err := Pipe(context.Background(), src, &S3Sink{Bucket: "bucket", Key: "k", Options: []UploadOption{WithSkipUnchanged()}})
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("WithSkipUnchanged: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestPipe_PathSinkWithProgressAndBandwidthLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("z"), 200*1024)
+	src, _ := NewFromBytes(data)
+	dest := filepath.Join(t.TempDir(), "nested", "out.bin")
+
+	var last atomic.Int64
+	start := time.Now()
+	res, err := Pipe(context.Background(), src, &PathSink{Path: dest},
+		WithProgress(func(n int64) { last.Store(n) }),
+		WithBandwidthLimit(1024*1024),
+		WithTransferChecksum(AlgorithmMD5),
+	)
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("200 KiB at 1 MiB/s took %s; bandwidth limit not applied", elapsed)
+	}
+	if last.Load() != int64(len(data)) {
+		t.Errorf("last progress = %d, want %d", last.Load(), len(data))
+	}
+	sum := md5.Sum(data)
+	if res.Algorithm != AlgorithmMD5 || res.Digest != hex.EncodeToString(sum[:]) {
+		t.Errorf("result = %+v", res)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, data) {
+		t.Error("written content differs")
+	}
+}
+
+func TestPipe_URLSink(t *testing.T) {
+	var got []byte
+	var gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("Content-Type")
+		got, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	src, _ := NewFromBytes([]byte(`{"a":1}`), MetadataHint{MimeType: "application/json"})
+	if _, err := Pipe(context.Background(), src, &URLSink{URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"a":1}` || gotType != "application/json" {
+		t.Errorf("server got %q (%s)", got, gotType)
+	}
+}
+
+func TestPipe_SourceDigestMismatch(t *testing.T) {
+	other := md5.Sum([]byte("not the body"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(other[:]))
+		w.Write([]byte("the body"))
+	}))
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	src, err := NewFromURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "x")
+	_, err = Pipe(context.Background(), src, &PathSink{Path: dest}, WithTransferChecksum(AlgorithmMD5))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("mismatched content left at the sink path: %v", err)
+	}
+
+	var puts int
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			puts++
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+	src, _ = NewFromURL(srv.URL)
+	_, err = Pipe(context.Background(), src, &S3Sink{Bucket: "bucket", Key: "x"}, WithTransferChecksum(AlgorithmMD5))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("S3Sink: expected ErrChecksumMismatch, got %v", err)
+	}
+	if puts != 0 {
+		t.Errorf("S3Sink stored mismatched content with %d PutObject calls", puts)
+	}
+}
+
+// failingSink reads part of the stream and then fails.
+type failingSink struct{}
+
+func (failingSink) WriteFrom(ctx context.Context, r io.Reader, meta Metadata) (int64, error) {
+	n, _ := io.CopyN(io.Discard, r, 1024)
+	return n, errors.New("sink failed")
+}
+
+// Operations that stream a lazy source close its tail however they end.
+func TestOpenStream_ConsumersCloseLazyTail(t *testing.T) {
+	RegisterStorage("mem", newMemStorage())
+	defer RegisterStorage("mem", nil)
+	content := strings.Repeat("line\n", 50000)
+
+	for name, run := range map[string]func(*File) error{
+		"Pipe with failing sink": func(f *File) error {
+			_, err := Pipe(context.Background(), f, failingSink{})
+			if err == nil {
+				return errors.New("Pipe succeeded")
+			}
+			return nil
+		},
+		"Compress": func(f *File) error {
+			_, err := f.Compress(CodecGzip, 0)
+			return err
+		},
+		"NormalizeUnicode": func(f *File) error {
+			_, err := f.NormalizeUnicode(norm.NFC)
+			return err
+		},
+		"UploadToStorage": func(f *File) error {
+			return f.UploadToStorage(context.Background(), "mem://bucket/k")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			src := &closeTracker{Reader: strings.NewReader(content)}
+			f, err := NewFromStreamLazy(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := run(f); err != nil {
+				t.Fatal(err)
+			}
+			if !src.closed {
+				t.Error("stream tail not closed")
+			}
+		})
+	}
+}
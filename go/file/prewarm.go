@@ -0,0 +1,194 @@
+package file
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PrewarmLevel selects how much of each File Prewarm fetches.
+type PrewarmLevel int
+
+const (
+	// PrewarmMetadataOnly refreshes metadata without reading content: a
+	// stat for local files, HeadObject for S3, and the backend's Stat for
+	// Storage sources, under MetadataTimeout.
+	PrewarmMetadataOnly PrewarmLevel = iota
+	// PrewarmFullContent buffers each File's content as EnsureLoaded does.
+	PrewarmFullContent
+)
+
+// Prewarm fetches the metadata or content of files ahead of use, at most
+// concurrency at a time, so a listing's first access to each File does not
+// pay for the fetch serially. Files whose content is already buffered, and
+// directories, are skipped. Each File must appear in files once; a File is
+// not safe for concurrent use.
+//
+// Files that fail do not stop the rest; the failures are returned together
+// as a *BatchError keyed by each File's String. When ctx ends Prewarm stops
+// starting fetches and returns once those in flight finish, with an error
+// matching ctx's; Files warmed by then stay warm. A concurrency value below
+// 1 is treated as 1, and an unknown level fails with ErrInvalidOption.
+func Prewarm(ctx context.Context, files []*File, level PrewarmLevel, concurrency int) error {
+	if level != PrewarmMetadataOnly && level != PrewarmFullContent {
+		return newError(ErrInvalidOption, "Prewarm", fmt.Errorf("unknown level %d", level))
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu        sync.Mutex
+		failed    []*ItemError
+		succeeded int
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, concurrency)
+	)
+dispatch:
+	for i, f := range files {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			<-sem
+			break dispatch
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			err := f.prewarm(ctx, level)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case ctx.Err() == nil:
+				failed = append(failed, itemError(i, describeFile(f), "", "Prewarm", ErrRead, err))
+			}
+			// A fetch cut short by ctx counts as not completed.
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(failed, func(a, b *ItemError) int { return cmp.Compare(a.Index, b.Index) })
+	batch := newBatchError("Prewarm", len(files), succeeded, failed)
+	if ctx.Err() != nil && succeeded+len(failed) < len(files) {
+		return errors.Join(newError(ErrRead, "Prewarm", ctx.Err()), batch)
+	}
+	return batch
+}
+
+// prewarm fetches f's metadata or content for Prewarm.
+func (f *File) prewarm(ctx context.Context, level PrewarmLevel) error {
+	if f.dir || f.Loaded() {
+		return nil
+	}
+	if level == PrewarmFullContent {
+		_, err := f.load(ctx, "Prewarm")
+		return err
+	}
+	switch f.source {
+	case SourceFile:
+		if f.meta.Path == "" {
+			return nil
+		}
+		info, err := os.Stat(f.meta.Path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return newError(ErrNotFound, "Prewarm", err)
+			}
+			return newError(ErrRead, "Prewarm", err)
+		}
+		f.meta.Size = info.Size()
+		f.meta.LastModified = info.ModTime()
+	case SourceS3:
+		if _, _, ok := f.s3Location(); ok {
+			return f.prewarmS3(ctx)
+		}
+	case SourceStorage:
+		if f.meta.URL != "" {
+			return f.prewarmStorage(ctx)
+		}
+	}
+	return nil
+}
+
+// prewarmS3 refreshes f's metadata from HeadObject.
+func (f *File) prewarmS3(ctx context.Context) error {
+	if err := checkNetwork(ctx, "Prewarm"); err != nil {
+		return err
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	bucket, key, _ := f.s3Location()
+	s3Client, _ := s3Clients()
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		if isS3NotFound(err) {
+			return newError(ErrNotFound, "Prewarm", err)
+		}
+		return dl.newError(ctx, ErrS3, "Prewarm", err)
+	}
+	if head.ContentLength != nil {
+		f.meta.Size = *head.ContentLength
+	}
+	if ct := declaredMimeType(aws.ToString(head.ContentType)); ct != "" {
+		f.meta.MimeType = ct
+	}
+	if etag := strings.Trim(aws.ToString(head.ETag), `"`); etag != "" {
+		f.meta.Hash = etag
+	}
+	if head.LastModified != nil {
+		f.meta.LastModified = *head.LastModified
+	}
+	if head.VersionId != nil {
+		f.meta.VersionID = *head.VersionId
+	}
+	f.meta.noteOrigin(OriginS3)
+	return nil
+}
+
+// prewarmStorage refreshes f's metadata from its backend's Stat.
+func (f *File) prewarmStorage(ctx context.Context) error {
+	if err := checkNetwork(ctx, "Prewarm"); err != nil {
+		return err
+	}
+	s, err := StorageFor(f.meta.URL)
+	if err != nil {
+		return err
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	remote, err := s.Stat(ctx, f.meta.URL)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return newError(ErrNotFound, "Prewarm", err)
+		}
+		return dl.newError(ctx, ErrRead, "Prewarm", err)
+	}
+	if remote.Size > 0 {
+		f.meta.Size = remote.Size
+	}
+	if !remote.LastModified.IsZero() {
+		f.meta.LastModified = remote.LastModified
+	}
+	if remote.MimeType != "" {
+		f.meta.MimeType = remote.MimeType
+	}
+	if remote.Hash != "" {
+		f.meta.Hash = remote.Hash
+	}
+	return nil
+}
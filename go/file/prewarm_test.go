@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3Refs returns n S3 Files that record only where their object lives.
+func s3Refs(n int) []*File {
+	files := make([]*File, n)
+	for i := range files {
+		files[i] = &File{source: SourceS3, s3Bucket: "bucket", s3Key: fmt.Sprintf("k/%02d.txt", i)}
+	}
+	return files
+}
+
+// inflight tracks the peak number of concurrent calls.
+type inflight struct {
+	cur, peak atomic.Int32
+}
+
+func (c *inflight) enter() {
+	n := c.cur.Add(1)
+	for {
+		p := c.peak.Load()
+		if n <= p || c.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+}
+
+func (c *inflight) leave() { c.cur.Add(-1) }
+
+func TestPrewarm_MetadataBoundedConcurrency(t *testing.T) {
+	var calls inflight
+	modified := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	defer setMockS3(&mockS3Client{
+		headObjectFn: func(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			calls.enter()
+			defer calls.leave()
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(int64(len(aws.ToString(in.Key)))),
+				ContentType:   aws.String("text/plain"),
+				ETag:          aws.String(`"etag"`),
+				LastModified:  aws.Time(modified),
+			}, nil
+		},
+	}, &mockPresignClient{})()
+
+	files := s3Refs(20)
+	if err := Prewarm(context.Background(), files, PrewarmMetadataOnly, 4); err != nil {
+		t.Fatalf("Prewarm: %v", err)
+	}
+	if peak := calls.peak.Load(); peak > 4 || peak < 2 {
+		t.Errorf("peak concurrency = %d, want between 2 and 4", peak)
+	}
+	for _, f := range files {
+		m := f.Metadata()
+		if m.Size != 8 || m.MimeType != "text/plain" || m.Hash != "etag" || !m.LastModified.Equal(modified) {
+			t.Fatalf("metadata not refreshed: %+v", m)
+		}
+		if f.Loaded() {
+			t.Fatal("PrewarmMetadataOnly should not load content")
+		}
+	}
+}
+
+func TestPrewarm_FullContentPartialFailure(t *testing.T) {
+	var calls inflight
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			calls.enter()
+			defer calls.leave()
+			key := aws.ToString(in.Key)
+			switch key {
+			case "k/03.txt":
+				return nil, &types.NoSuchKey{}
+			case "k/07.txt":
+				return nil, errors.New("connection reset")
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("body of " + key))}, nil
+		},
+	}, &mockPresignClient{})()
+
+	files := s3Refs(10)
+	err := Prewarm(context.Background(), files, PrewarmFullContent, 3)
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("err = %v, want a *BatchError", err)
+	}
+	if be.Total != 10 || be.Succeeded() != 8 || len(be.Failed()) != 2 {
+		t.Fatalf("Total %d, Succeeded %d, Failed %d", be.Total, be.Succeeded(), len(be.Failed()))
+	}
+	if got := be.Failed(); got[0].Index != 3 || got[1].Index != 7 || !errors.Is(got[0].Err, ErrNotFound) {
+		t.Errorf("failures = %+v", got)
+	}
+	if calls.peak.Load() > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", calls.peak.Load())
+	}
+	for i, f := range files {
+		if (i == 3 || i == 7) == f.Loaded() {
+			t.Errorf("file %d: Loaded() = %v", i, f.Loaded())
+		}
+	}
+	if data, _ := files[0].Read(); string(data) != "body of k/00.txt" {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestPrewarm_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		mu      sync.Mutex
+		fetched int
+	)
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			mu.Lock()
+			fetched++
+			n := fetched
+			mu.Unlock()
+			if n == 2 {
+				cancel()
+				return nil, ctx.Err()
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("x"))}, nil
+		},
+	}, &mockPresignClient{})()
+
+	files := s3Refs(10)
+	err := Prewarm(ctx, files, PrewarmFullContent, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	var be *BatchError
+	if errors.As(err, &be) {
+		t.Errorf("a fetch cut short by cancellation is not a failure: %v", be)
+	}
+	if !files[0].Loaded() || files[1].Loaded() || fetched != 2 {
+		t.Errorf("fetched %d; the first file should stay warm and nothing start after cancel", fetched)
+	}
+}
+
+func TestPrewarm_SkipsAndRejects(t *testing.T) {
+	loaded, _ := NewFromBytes([]byte("x"))
+	dir, _ := NewFromFile(t.TempDir())
+	if err := Prewarm(context.Background(), []*File{loaded, dir}, PrewarmFullContent, 0); err != nil {
+		t.Errorf("nothing to fetch: err = %v", err)
+	}
+	if err := Prewarm(context.Background(), nil, PrewarmLevel(9), 1); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unknown level: err = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,173 @@
+package file
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ProbeFailure enumerates why ProbeURL could not confirm a URL is
+// available. Callers branch on it after an errors.As against *ProbeError.
+type ProbeFailure string
+
+const (
+	// ProbeDNS means the host name could not be resolved.
+	ProbeDNS ProbeFailure = "dns"
+	// ProbeTLS means the TLS handshake failed, typically an untrusted,
+	// expired or mismatched certificate.
+	ProbeTLS ProbeFailure = "tls"
+	// ProbeConnectionRefused means the host actively refused the
+	// connection: nothing is listening on that port.
+	ProbeConnectionRefused ProbeFailure = "connection_refused"
+	// ProbeTimeout means the probe's deadline passed before a response
+	// arrived.
+	ProbeTimeout ProbeFailure = "timeout"
+	// ProbeHTTPStatus means the server answered with a non-2xx status.
+	ProbeHTTPStatus ProbeFailure = "http_status"
+	// ProbeNetwork covers any other transport failure.
+	ProbeNetwork ProbeFailure = "network"
+)
+
+// ProbeError describes a failed ProbeURL. It matches ErrHTTP with
+// errors.Is, and also ErrTimeout when Kind is ProbeTimeout.
+type ProbeError struct {
+	// Kind is the category of failure.
+	Kind ProbeFailure
+	// URL is the probed URL.
+	URL string
+	// StatusCode is the response status. Set only for ProbeHTTPStatus.
+	StatusCode int
+	// Err is the underlying transport error, if any.
+	Err error
+}
+
+// Error returns an operator-facing description of the failure, with the
+// URL's query string and credentials masked as in FileError.Error.
+func (e *ProbeError) Error() string {
+	return redactText(e.message())
+}
+
+// message renders the error before redaction.
+func (e *ProbeError) message() string {
+	switch e.Kind {
+	case ProbeDNS:
+		return fmt.Sprintf("file: probe %s: DNS lookup failed, check the host name: %v", e.URL, e.Err)
+	case ProbeTLS:
+		return fmt.Sprintf("file: probe %s: TLS handshake failed, check the server certificate: %v", e.URL, e.Err)
+	case ProbeConnectionRefused:
+		return fmt.Sprintf("file: probe %s: connection refused, check the port and that the server is running: %v", e.URL, e.Err)
+	case ProbeTimeout:
+		return fmt.Sprintf("file: probe %s: timed out, the host may be unreachable or firewalled: %v", e.URL, e.Err)
+	case ProbeHTTPStatus:
+		return fmt.Sprintf("file: probe %s: server returned %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	default:
+		return fmt.Sprintf("file: probe %s: %v", e.URL, e.Err)
+	}
+}
+
+// Unwrap returns the underlying transport error.
+func (e *ProbeError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrHTTP, or ErrTimeout for timeouts.
+func (e *ProbeError) Is(target error) bool {
+	return target == ErrHTTP || (target == ErrTimeout && e.Kind == ProbeTimeout)
+}
+
+// ProbeResult describes a URL probe.
+type ProbeResult struct {
+	// URL is the final URL after redirects.
+	URL string
+	// Method is the request method of the final probe, HEAD or GET.
+	Method string
+	// StatusCode is the response status.
+	StatusCode int
+	// Latency is the total time until response headers arrived, including
+	// any HEAD-to-GET fallback.
+	Latency time.Duration
+	// Header holds the server's response headers.
+	Header http.Header
+}
+
+// ProbeURL checks that rawURL is reachable without downloading it. It sends
+// a HEAD request, falling back to a one-byte ranged GET for servers that
+// reject HEAD, and fails with a *ProbeError classifying what went wrong.
+// For HTTP status failures the ProbeResult is returned alongside the error.
+// MetadataTimeout applies when ctx has no deadline.
+func ProbeURL(ctx context.Context, rawURL string) (ProbeResult, error) {
+	ctx, cancel, _ := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	start := time.Now()
+	res, err := probeOnce(ctx, http.MethodHead, rawURL)
+	if err == nil && (res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented) {
+		res, err = probeOnce(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return ProbeResult{}, &ProbeError{Kind: classifyProbeError(ctx, err), URL: rawURL, Err: err}
+	}
+	res.Latency = time.Since(start)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res, &ProbeError{Kind: ProbeHTTPStatus, URL: rawURL, StatusCode: res.StatusCode}
+	}
+	return res, nil
+}
+
+// probeOnce sends one bodiless probe request.
+func probeOnce(ctx context.Context, method, rawURL string) (ProbeResult, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := CurrentHTTPClient().Do(req)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	resp.Body.Close()
+	return ProbeResult{
+		URL:        resp.Request.URL.String(),
+		Method:     method,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}, nil
+}
+
+// classifyProbeError maps a transport error to a ProbeFailure.
+func classifyProbeError(ctx context.Context, err error) ProbeFailure {
+	var (
+		dnsErr      *net.DNSError
+		netErr      net.Error
+		certErr     *tls.CertificateVerificationError
+		unknownAuth x509.UnknownAuthorityError
+		hostErr     x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+	)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return ProbeTimeout
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return ProbeTimeout
+		}
+		return ProbeDNS
+	case errors.As(err, &certErr), errors.As(err, &unknownAuth), errors.As(err, &hostErr),
+		errors.As(err, &invalidErr), errors.As(err, &recordErr), errors.As(err, &alertErr):
+		return ProbeTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ProbeConnectionRefused
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ProbeTimeout
+	default:
+		return ProbeNetwork
+	}
+}
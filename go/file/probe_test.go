@@ -0,0 +1,171 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// probeFailure runs ProbeURL and returns the ProbeError it fails with.
+func probeFailure(t *testing.T, ctx context.Context, rawURL string) *ProbeError {
+	t.Helper()
+	_, err := ProbeURL(ctx, rawURL)
+	var pErr *ProbeError
+	if !errors.As(err, &pErr) {
+		t.Fatalf("expected *ProbeError, got %v", err)
+	}
+	if !errors.Is(err, ErrHTTP) {
+		t.Errorf("ProbeError should match ErrHTTP")
+	}
+	return pErr
+}
+
+func TestProbeURL_Success(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		w.Header().Set("Content-Length", "12345")
+		w.Header().Set("Server", "probe-test")
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	res, err := ProbeURL(context.Background(), srv.URL+"/big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || res.Method != http.MethodHead {
+		t.Errorf("result = %+v", res)
+	}
+	if res.Header.Get("Server") != "probe-test" || res.Header.Get("Content-Length") != "12345" {
+		t.Errorf("Header = %v", res.Header)
+	}
+	if res.Latency <= 0 {
+		t.Errorf("Latency = %v", res.Latency)
+	}
+}
+
+func TestProbeURL_FallsBackToRangedGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("Range = %q", r.Header.Get("Range"))
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte("x"))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	res, err := ProbeURL(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Method != http.MethodGet || res.StatusCode != http.StatusPartialContent {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestProbeURL_HTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	res, err := ProbeURL(context.Background(), srv.URL)
+	var pErr *ProbeError
+	if !errors.As(err, &pErr) || pErr.Kind != ProbeHTTPStatus || pErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected http_status 404, got %v", err)
+	}
+	if res.Header.Get("X-Request-Id") != "abc" {
+		t.Errorf("headers not returned with status failure: %v", res.Header)
+	}
+}
+
+func TestProbeURL_UntrustedCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	// A client that does not trust the test server's self-signed cert.
+	defer setMockHTTP(&http.Client{})()
+
+	if pErr := probeFailure(t, context.Background(), srv.URL); pErr.Kind != ProbeTLS {
+		t.Errorf("Kind = %s, want tls (%v)", pErr.Kind, pErr)
+	}
+}
+
+func TestProbeURL_ConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	defer setMockHTTP(&http.Client{})()
+
+	if pErr := probeFailure(t, context.Background(), "http://"+addr); pErr.Kind != ProbeConnectionRefused {
+		t.Errorf("Kind = %s, want connection_refused (%v)", pErr.Kind, pErr)
+	}
+}
+
+func TestProbeURL_DNS(t *testing.T) {
+	defer setMockHTTP(&http.Client{})()
+
+	if pErr := probeFailure(t, context.Background(), "http://no-such-host.invalid/"); pErr.Kind != ProbeDNS {
+		t.Errorf("Kind = %s, want dns (%v)", pErr.Kind, pErr)
+	}
+}
+
+func TestProbeURL_Timeout(t *testing.T) {
+	defer setMockHTTP(&http.Client{})()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// 10.255.255.1 is a non-routable address: the SYN goes nowhere.
+	_, err := ProbeURL(ctx, "http://10.255.255.1:81/")
+	var pErr *ProbeError
+	if !errors.As(err, &pErr) {
+		t.Fatalf("expected *ProbeError, got %v", err)
+	}
+	if pErr.Kind == ProbeNetwork {
+		t.Skipf("sandbox rejected the unroutable address immediately: %v", err)
+	}
+	if pErr.Kind != ProbeTimeout || !errors.Is(err, ErrTimeout) {
+		t.Errorf("Kind = %s, want timeout (%v)", pErr.Kind, err)
+	}
+}
+
+func TestProbeURL_TimeoutOnSilentServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// Accept connections but never answer.
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	defer setMockHTTP(&http.Client{})()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	pErr := probeFailure(t, ctx, "http://"+ln.Addr().String())
+	if pErr.Kind != ProbeTimeout || !errors.Is(pErr, ErrTimeout) {
+		t.Errorf("Kind = %s, want timeout (%v)", pErr.Kind, pErr)
+	}
+}
@@ -0,0 +1,87 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ProcessFiles consumes Files from in and runs fn on each with at most
+// `workers` calls in flight. It returns once in is closed and every started
+// call has finished, or once ctx is cancelled and the in-flight calls have
+// drained — fn is never invoked after ProcessFiles returns.
+//
+// Errors from fn are aggregated with errors.Join. A panic inside fn is
+// recovered and reported as an ErrPanic FileError naming the file, so one bad
+// item can't take down the whole batch. When ctx is cancelled before in is
+// drained, ctx.Err() is included in the returned error.
+//
+// A workers value below 1 is treated as 1.
+func ProcessFiles(ctx context.Context, in <-chan *File, workers int, fn func(ctx context.Context, f *File) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	record := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case f, ok := <-in:
+					if !ok {
+						return
+					}
+					// select picks randomly between ready cases, so a
+					// receive can win even after cancellation. Re-check
+					// before handing the file to fn.
+					if ctx.Err() != nil {
+						return
+					}
+					if err := runProcessFn(ctx, f, fn); err != nil {
+						record(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// runProcessFn invokes fn, converting a panic into an ErrPanic FileError that
+// identifies the file being processed.
+func runProcessFn(ctx context.Context, f *File, fn func(ctx context.Context, f *File) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newError(ErrPanic, "ProcessFiles", fmt.Errorf("%s: %v", describeFile(f), r))
+		}
+	}()
+	return fn(ctx, f)
+}
+
+// describeFile returns a short identifier for f suitable for error messages.
+func describeFile(f *File) string {
+	if f == nil {
+		return "File(nil)"
+	}
+	return f.String()
+}
@@ -0,0 +1,171 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// feedFiles returns a closed channel pre-loaded with n named byte Files.
+func feedFiles(t *testing.T, n int) <-chan *File {
+	t.Helper()
+	ch := make(chan *File, n)
+	for i := 0; i < n; i++ {
+		f, err := NewFromBytes([]byte("data"), MetadataHint{Name: fmt.Sprintf("f%03d.txt", i)})
+		if err != nil {
+			t.Fatalf("NewFromBytes: %v", err)
+		}
+		ch <- f
+	}
+	close(ch)
+	return ch
+}
+
+func TestProcessFiles_ProcessesEveryFileWithBoundedParallelism(t *testing.T) {
+	const n, workers = 50, 4
+	in := feedFiles(t, n)
+
+	var (
+		mu       sync.Mutex
+		seen     []string
+		inFlight int32
+		maxSeen  int32
+	)
+	err := ProcessFiles(context.Background(), in, workers, func(ctx context.Context, f *File) error {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			prev := atomic.LoadInt32(&maxSeen)
+			if cur <= prev || atomic.CompareAndSwapInt32(&maxSeen, prev, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		seen = append(seen, f.Name())
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessFiles: %v", err)
+	}
+
+	if len(seen) != n {
+		t.Fatalf("processed %d files, want %d", len(seen), n)
+	}
+	// Completion order is unspecified; compare as a set.
+	sort.Strings(seen)
+	for i, name := range seen {
+		if want := fmt.Sprintf("f%03d.txt", i); name != want {
+			t.Fatalf("seen[%d] = %q, want %q", i, name, want)
+		}
+	}
+	if maxSeen > workers {
+		t.Errorf("max in-flight = %d, want <= %d", maxSeen, workers)
+	}
+}
+
+func TestProcessFiles_AggregatesErrors(t *testing.T) {
+	in := feedFiles(t, 6)
+	errBoom := errors.New("boom")
+
+	err := ProcessFiles(context.Background(), in, 3, func(ctx context.Context, f *File) error {
+		if strings.HasSuffix(f.Name(), "1.txt") || strings.HasSuffix(f.Name(), "4.txt") {
+			return fmt.Errorf("%s: %w", f.Name(), errBoom)
+		}
+		return nil
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("expected errBoom in %v", err)
+	}
+	if got := strings.Count(err.Error(), "boom"); got != 2 {
+		t.Errorf("expected 2 joined errors, got %d in %q", got, err)
+	}
+}
+
+func TestProcessFiles_CancellationStopsNewWork(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan *File)
+
+	var (
+		calls    int32
+		returned atomic.Bool
+		late     atomic.Bool
+	)
+	// Producer keeps offering files until the consumer stops taking them.
+	go func() {
+		for i := 0; ; i++ {
+			f, _ := NewFromBytes([]byte("x"), MetadataHint{Name: fmt.Sprintf("p%d", i)})
+			select {
+			case in <- f:
+			case <-time.After(200 * time.Millisecond):
+				return
+			}
+		}
+	}()
+
+	err := ProcessFiles(ctx, in, 2, func(ctx context.Context, f *File) error {
+		if returned.Load() {
+			late.Store(true)
+		}
+		if atomic.AddInt32(&calls, 1) == 5 {
+			cancel()
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+	returned.Store(true)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if late.Load() {
+		t.Error("fn was called after ProcessFiles returned")
+	}
+	if c := atomic.LoadInt32(&calls); c > 7 {
+		t.Errorf("fn called %d times; expected work to stop shortly after cancellation", c)
+	}
+}
+
+func TestProcessFiles_RecoversPanics(t *testing.T) {
+	in := feedFiles(t, 3)
+
+	var ok int32
+	err := ProcessFiles(context.Background(), in, 2, func(ctx context.Context, f *File) error {
+		if f.Name() == "f001.txt" {
+			panic("kaboom")
+		}
+		atomic.AddInt32(&ok, 1)
+		return nil
+	})
+	if !errors.Is(err, ErrPanic) {
+		t.Fatalf("expected ErrPanic, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "f001.txt") || !strings.Contains(err.Error(), "kaboom") {
+		t.Errorf("panic error %q should name the file and the panic value", err)
+	}
+	if ok != 2 {
+		t.Errorf("other files processed = %d, want 2", ok)
+	}
+}
+
+func TestProcessFiles_ZeroWorkersTreatedAsOne(t *testing.T) {
+	in := feedFiles(t, 3)
+	var calls int32
+	if err := ProcessFiles(context.Background(), in, 0, func(ctx context.Context, f *File) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}); err != nil {
+		t.Fatalf("ProcessFiles: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
@@ -0,0 +1,69 @@
+package file
+
+import (
+	"fmt"
+	"time"
+)
+
+// SourceRef records one hop in a File's provenance chain: where the content
+// was at that point and when it got there.
+type SourceRef struct {
+	// Source is the kind of source for this hop.
+	Source FileSource `json:"source"`
+	// URL is the fetch URL for URL sources, the s3:// URI for S3 sources,
+	// or the backend URI for Storage sources.
+	URL string `json:"url,omitempty"`
+	// Path is the local filesystem path for file sources, or the
+	// fs-relative name for fs.FS sources.
+	Path string `json:"path,omitempty"`
+	// Bucket is the S3 bucket for S3 sources.
+	Bucket string `json:"bucket,omitempty"`
+	// Key is the S3 object key for S3 sources.
+	Key string `json:"key,omitempty"`
+	// Timestamp is when this hop was recorded.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Provenance returns the chain of sources this File's content passed
+// through, oldest first. Constructors start the chain and operations that
+// produce a new File (Save, Move, DownloadS3ToFile) extend it, so a File
+// saved from a URL still reports the URL it originally came from. The last
+// entry always matches Source().
+func (f *File) Provenance() []SourceRef {
+	out := make([]SourceRef, len(f.provenance))
+	copy(out, f.provenance)
+	return out
+}
+
+// withProvenance records f's current source as the start of its provenance
+// chain and returns f, so constructors can wrap their return value.
+func withProvenance(f *File) *File {
+	f.provenance = append(f.provenance, f.sourceRef())
+	return f
+}
+
+// inheritProvenance prefixes f's chain with the chain of the File it was
+// derived from.
+func (f *File) inheritProvenance(from *File) {
+	chain := make([]SourceRef, 0, len(from.provenance)+len(f.provenance))
+	chain = append(chain, from.provenance...)
+	chain = append(chain, f.provenance...)
+	f.provenance = chain
+}
+
+// sourceRef describes f's current source as a SourceRef stamped with the
+// current time.
+func (f *File) sourceRef() SourceRef {
+	ref := SourceRef{Source: f.source, Timestamp: time.Now().UTC()}
+	switch f.source {
+	case SourceURL, SourceStorage:
+		ref.URL = f.meta.URL
+	case SourceFile, SourceFS:
+		ref.Path = f.meta.Path
+	case SourceS3:
+		ref.Bucket = f.s3Bucket
+		ref.Key = f.s3Key
+		ref.URL = fmt.Sprintf("s3://%s/%s", f.s3Bucket, f.s3Key)
+	}
+	return ref
+}
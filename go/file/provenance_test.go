@@ -0,0 +1,121 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestProvenance_Constructors(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	chain := f.Provenance()
+	if len(chain) != 1 || chain[0].Source != SourceBytes {
+		t.Fatalf("bytes chain = %+v", chain)
+	}
+	if chain[0].Timestamp.IsZero() {
+		t.Error("timestamp should be set")
+	}
+
+	// The returned slice is a copy.
+	chain[0].Source = SourceS3
+	if f.Provenance()[0].Source != SourceBytes {
+		t.Error("Provenance() must return a copy")
+	}
+}
+
+func TestProvenance_URLSaveMoveChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	rawURL := srv.URL + "/report.txt"
+	f, err := NewFromURL(rawURL)
+	if err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+
+	dir := t.TempDir()
+	savedPath := filepath.Join(dir, "saved.txt")
+	saved, err := f.Save(savedPath)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	movedPath := filepath.Join(dir, "moved.txt")
+	moved, err := saved.Move(movedPath)
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+
+	chain := moved.Provenance()
+	if len(chain) != 3 {
+		t.Fatalf("chain length = %d, want 3: %+v", len(chain), chain)
+	}
+	if chain[0].Source != SourceURL || chain[0].URL != rawURL {
+		t.Errorf("chain[0] = %+v, want URL %s", chain[0], rawURL)
+	}
+	if chain[1].Source != SourceFile || chain[1].Path != savedPath {
+		t.Errorf("chain[1] = %+v, want file %s", chain[1], savedPath)
+	}
+	if chain[2].Source != SourceFile || chain[2].Path != movedPath {
+		t.Errorf("chain[2] = %+v, want file %s", chain[2], movedPath)
+	}
+	if moved.Source() != chain[len(chain)-1].Source {
+		t.Errorf("Source() = %s, want last provenance entry %s", moved.Source(), chain[len(chain)-1].Source)
+	}
+	// The original is untouched.
+	if got := len(f.Provenance()); got != 1 {
+		t.Errorf("original chain length = %d, want 1", got)
+	}
+}
+
+func TestProvenance_DownloadS3ToFile(t *testing.T) {
+	mockS3 := &mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("obj"))}, nil
+		},
+	}
+	cleanup := setMockS3(mockS3, &mockPresignClient{})
+	defer cleanup()
+
+	dest := filepath.Join(t.TempDir(), "obj.txt")
+	f, err := DownloadS3ToFile(context.Background(), "bkt", "dir/obj.txt", dest)
+	if err != nil {
+		t.Fatalf("DownloadS3ToFile: %v", err)
+	}
+
+	chain := f.Provenance()
+	if len(chain) != 2 {
+		t.Fatalf("chain = %+v", chain)
+	}
+	if chain[0].Source != SourceS3 || chain[0].Bucket != "bkt" || chain[0].Key != "dir/obj.txt" || chain[0].URL != "s3://bkt/dir/obj.txt" {
+		t.Errorf("chain[0] = %+v", chain[0])
+	}
+	if chain[1].Source != SourceFile || chain[1].Path != dest {
+		t.Errorf("chain[1] = %+v", chain[1])
+	}
+}
+
+func TestSourceRef_JSON(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	b, err := json.Marshal(f.Provenance())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	s := string(b)
+	if !strings.Contains(s, `"source":"Bytes"`) || !strings.Contains(s, `"timestamp"`) {
+		t.Errorf("json = %s", s)
+	}
+	if strings.Contains(s, `"bucket"`) {
+		t.Errorf("empty fields should be omitted: %s", s)
+	}
+}
@@ -0,0 +1,216 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ReadRange returns length bytes of the content starting at offset, without
+// reading the rest where the source allows: buffered content is sliced, a
+// NewFromReaderAt File or lazy local file is read with ReadAt, and an S3
+// File without content sends a ranged GetObject under DownloadTimeout.
+// Other sources (lazy streams, Storage URIs) are buffered with Read first.
+// NewFromURL Files hold their content already; use ReadURLRange to fetch a
+// slice of a URL without downloading the rest.
+//
+// A range that runs past the end is clipped, so the result may be shorter
+// than length; one that starts past the end, or a negative offset or
+// length, fails with ErrInvalidArgument. Directories fail with
+// ErrInvalidSource.
+func (f *File) ReadRange(offset, length int64) ([]byte, error) {
+	if err := checkRange("ReadRange", offset, length); err != nil {
+		return nil, err
+	}
+	if f.dir {
+		return nil, newError(ErrInvalidSource, "ReadRange", fmt.Errorf("%s is a directory", shownPath(f.meta.Path)))
+	}
+	if f.source == SourceS3 && !f.loaded && !f.lazy {
+		if bucket, key, ok := f.s3Location(); ok {
+			return f.readS3Range(context.Background(), bucket, key, offset, length)
+		}
+	}
+
+	var (
+		ra   io.ReaderAt
+		size int64
+	)
+	switch {
+	case f.loaded && f.data != nil:
+		ra, size = bytes.NewReader(f.data), int64(len(f.data))
+	case f.readerAt != nil:
+		ra, size = f.readerAt, f.readerAtSize
+	case f.onDisk():
+		fh, err := f.openOnDisk("ReadRange")
+		if err != nil {
+			return nil, err
+		}
+		defer fh.Close()
+		info, err := fh.Stat()
+		if err != nil {
+			return nil, newError(ErrRead, "ReadRange", err)
+		}
+		ra, size = fh, info.Size()
+	default:
+		data, err := f.Read()
+		if err != nil {
+			return nil, err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+	if offset > size {
+		return nil, rangeError("ReadRange", offset, size)
+	}
+	buf := make([]byte, min(length, size-offset))
+	if n, err := ra.ReadAt(buf, offset); n < len(buf) {
+		return nil, newError(ErrRead, "ReadRange", err)
+	}
+	return buf, nil
+}
+
+// readS3Range fetches a byte range of the S3 object with a ranged GetObject.
+func (f *File) readS3Range(ctx context.Context, bucket, key string, offset, length int64) ([]byte, error) {
+	if f.meta.Size > 0 {
+		if offset > f.meta.Size {
+			return nil, rangeError("ReadRange", offset, f.meta.Size)
+		}
+		length = min(length, f.meta.Size-offset)
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+	if err := checkNetwork(ctx, "ReadRange"); err != nil {
+		return nil, err
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, "ReadRange", err)
+	}
+	defer release()
+
+	s3Client, _ := s3Clients()
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange(offset, length)),
+	})
+	if err != nil {
+		if hasAPIErrorCode(err, "InvalidRange") {
+			return nil, newError(ErrInvalidArgument, "ReadRange", fmt.Errorf("offset %d is past the end of s3://%s/%s: %w", offset, bucket, key, err))
+		}
+		if isS3NotFound(err) {
+			return nil, newError(ErrNotFound, "ReadRange", err)
+		}
+		return nil, dl.newError(ctx, ErrS3, "ReadRange", err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(out.Body, length))
+	if err != nil {
+		return nil, dl.newError(ctx, ErrRead, "ReadRange", err)
+	}
+	return data, nil
+}
+
+// ReadURLRange fetches length bytes of rawURL starting at offset with an
+// HTTP Range request, so a slice of a large resource can be read without
+// downloading the rest. The server must answer 206 Partial Content for the
+// requested start: a server that ignores Range and sends the whole body
+// (200) fails with ErrHTTP rather than having the slice cut from it. A
+// range that runs past the end is clipped by the server; one that starts
+// past it (416), or a negative offset or length, fails with
+// ErrInvalidArgument. DownloadTimeout applies when ctx has no deadline.
+func ReadURLRange(ctx context.Context, rawURL string, offset, length int64) ([]byte, error) {
+	if err := checkRange("ReadURLRange", offset, length); err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrHTTP, "ReadURLRange", err)
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, newError(ErrHTTP, "ReadURLRange", err)
+	}
+	req.Header.Set("Range", byteRange(offset, length))
+	// A transparently decoded body would not match the requested bytes.
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := CurrentHTTPClient().Do(req)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrHTTP, "ReadURLRange", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		e := newError(ErrInvalidArgument, "ReadURLRange", fmt.Errorf("offset %d is past the end (Content-Range %q)", offset, resp.Header.Get("Content-Range")))
+		e.StatusCode = resp.StatusCode
+		return nil, e
+	case http.StatusOK:
+		e := newError(ErrHTTP, "ReadURLRange", errors.New("server ignored the Range header and sent the whole body"))
+		e.StatusCode = resp.StatusCode
+		return nil, e
+	default:
+		return nil, httpStatusError("ReadURLRange", resp)
+	}
+	if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); ok && start != offset {
+		e := newError(ErrHTTP, "ReadURLRange", fmt.Errorf("server sent a range starting at %d, not %d", start, offset))
+		e.StatusCode = resp.StatusCode
+		return nil, e
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, length))
+	if err != nil {
+		return nil, dl.newError(ctx, ErrRead, "ReadURLRange", err)
+	}
+	return data, nil
+}
+
+// checkRange rejects a negative offset or length.
+func checkRange(op string, offset, length int64) error {
+	if offset < 0 || length < 0 {
+		return newError(ErrInvalidArgument, op, fmt.Errorf("invalid range: offset %d, length %d", offset, length))
+	}
+	return nil
+}
+
+// rangeError reports a range starting past the end of size bytes.
+func rangeError(op string, offset, size int64) error {
+	return newError(ErrInvalidArgument, op, fmt.Errorf("offset %d is past the end of %d bytes", offset, size))
+}
+
+// byteRange formats an HTTP Range value for length bytes from offset.
+func byteRange(offset, length int64) string {
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// contentRangeStart parses the first byte position of a Content-Range
+// value such as "bytes 100-199/5000".
+func contentRangeStart(v string) (int64, bool) {
+	spec, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(first, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,134 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func TestReadRange_LocalSources(t *testing.T) {
+	payload := []byte("0123456789")
+	p := filepath.Join(t.TempDir(), "digits.txt")
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffered, _ := NewFromBytes(payload)
+	lazy, _ := NewFromFileLazy(p)
+	at, _ := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+	stream, _ := NewFromStreamLazy(strings.NewReader(string(payload)))
+
+	for name, f := range map[string]*File{"buffered": buffered, "lazy file": lazy, "reader at": at, "lazy stream": stream} {
+		cases := []struct {
+			offset, length int64
+			want           string
+		}{
+			{2, 3, "234"},
+			{7, 100, "789"}, // clipped at the end
+			{10, 5, ""},
+			{0, 0, ""},
+		}
+		for _, tc := range cases {
+			got, err := f.ReadRange(tc.offset, tc.length)
+			if err != nil || string(got) != tc.want {
+				t.Errorf("%s: ReadRange(%d, %d) = %q, %v; want %q", name, tc.offset, tc.length, got, err, tc.want)
+			}
+		}
+		for _, bad := range [][2]int64{{-1, 3}, {0, -3}, {11, 1}} {
+			if _, err := f.ReadRange(bad[0], bad[1]); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("%s: ReadRange(%d, %d): err = %v, want ErrInvalidArgument", name, bad[0], bad[1], err)
+			}
+		}
+	}
+	if lazy.Loaded() || at.Loaded() {
+		t.Error("ReadRange should not buffer lazy files or ReaderAt Files")
+	}
+	dir, _ := NewFromFile(t.TempDir())
+	if _, err := dir.ReadRange(0, 1); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("directory: err = %v, want ErrInvalidSource", err)
+	}
+}
+
+func TestReadRange_S3(t *testing.T) {
+	payload := "the quick brown fox"
+	var ranges []string
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			r := aws.ToString(in.Range)
+			ranges = append(ranges, r)
+			var start, end int
+			if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil {
+				t.Fatalf("bad Range %q", r)
+			}
+			if start >= len(payload) {
+				return nil, &smithy.GenericAPIError{Code: "InvalidRange", Message: "not satisfiable"}
+			}
+			end = min(end, len(payload)-1)
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(payload[start : end+1]))}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "fox.txt"}
+	got, err := f.ReadRange(4, 5)
+	if err != nil || string(got) != "quick" {
+		t.Fatalf("ReadRange = %q, %v", got, err)
+	}
+	if ranges[0] != "bytes=4-8" || f.Loaded() {
+		t.Errorf("Range = %q, Loaded() = %v; want a ranged GET and no buffering", ranges[0], f.Loaded())
+	}
+	if _, err := f.ReadRange(100, 5); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("past the end: err = %v, want ErrInvalidArgument", err)
+	}
+
+	// A known size clips the request before it is sent.
+	sized := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "fox.txt", meta: Metadata{Size: int64(len(payload))}}
+	if got, _ := sized.ReadRange(16, 50); string(got) != "fox" || ranges[len(ranges)-1] != "bytes=16-18" {
+		t.Errorf("clipped read = %q with Range %q", got, ranges[len(ranges)-1])
+	}
+}
+
+func TestReadURLRange(t *testing.T) {
+	content := strings.Repeat("abcdefghij", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ignores-range" {
+			w.Write([]byte(content))
+			return
+		}
+		http.ServeContent(w, r, "media.bin", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	got, err := ReadURLRange(context.Background(), srv.URL+"/media.bin", 995, 10)
+	if err != nil || string(got) != "fghij" {
+		t.Errorf("ReadURLRange = %q, %v; want the clipped tail", got, err)
+	}
+	got, err = ReadURLRange(context.Background(), srv.URL+"/media.bin", 3, 4)
+	if err != nil || string(got) != "defg" {
+		t.Errorf("ReadURLRange = %q, %v", got, err)
+	}
+
+	_, err = ReadURLRange(context.Background(), srv.URL+"/ignores-range", 3, 4)
+	var fe *FileError
+	if !errors.Is(err, ErrHTTP) || !errors.As(err, &fe) || fe.StatusCode != http.StatusOK {
+		t.Errorf("server ignoring Range: err = %v, want ErrHTTP with status 200", err)
+	}
+	if _, err := ReadURLRange(context.Background(), srv.URL+"/media.bin", 5000, 4); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("past the end: err = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := ReadURLRange(context.Background(), srv.URL, -1, 4); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("negative offset: err = %v, want ErrInvalidArgument", err)
+	}
+}
@@ -0,0 +1,164 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Reader returns a reader over the file's content that does not copy it,
+// for io.Copy, multipart writers and hashing. See ReaderWithContext.
+func (f *File) Reader() (io.ReadCloser, error) {
+	return f.ReaderWithContext(context.Background())
+}
+
+// ReaderWithContext returns a reader over the file's content without
+// buffering or copying it. The caller must close it.
+//
+// Buffered content and ReaderAt Files give each call an independent reader
+// over the same bytes. Lazy local files (NewFromFileLazy) open the file
+// afresh each call. A File without content streams its S3 object or
+// Storage URI, holding a transfer slot until Close; when ctx has no
+// deadline, DownloadTimeout bounds opening it and each wait for more of the
+// body, not the whole read, and a missing source fails
+// with ErrNotFound as EnsureLoaded does. A lazy stream (NewFromStreamLazy)
+// can only be read once, so the first Reader takes it over and later calls
+// fail with ErrRead. Directories fail with ErrInvalidSource.
+func (f *File) ReaderWithContext(ctx context.Context) (io.ReadCloser, error) {
+	return f.openReader(ctx, "Reader")
+}
+
+// openReader implements ReaderWithContext, reporting errors under op.
+func (f *File) openReader(ctx context.Context, op string) (io.ReadCloser, error) {
+	switch {
+	case f.dir:
+		return nil, newError(ErrInvalidSource, op, fmt.Errorf("%s is a directory", shownPath(f.meta.Path)))
+	case f.loaded && f.data != nil:
+		return io.NopCloser(bytes.NewReader(f.data)), nil
+	case f.lazy && f.streamHead != nil:
+		r := io.MultiReader(bytes.NewReader(f.streamHead), f.streamTail)
+		closer, _ := f.streamTail.(io.Closer)
+		f.streamHead, f.streamTail, f.lazy = nil, nil, false
+		return &streamBody{Reader: r, closer: closer}, nil
+	case f.readerAt != nil:
+		return io.NopCloser(io.NewSectionReader(f.readerAt, 0, f.readerAtSize)), nil
+	case f.onDisk():
+		return f.openOnDisk(op)
+	case !f.loaded && f.hasSourceLocation():
+		return f.openSource(ctx, op)
+	}
+	return nil, newError(ErrRead, op, errors.New("no data available"))
+}
+
+// WriteTo writes the file's content to w and returns the number of bytes
+// written, so a File is an io.WriterTo. Buffered content is written in a
+// single call; otherwise the content is streamed from wherever it lives,
+// as ReaderWithContext streams it, without buffering it (a lazy stream is
+// consumed). A File with no content available fails with ErrRead, as does
+// a failed read; a failed write to w returns ErrWrite.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	if f.loaded && f.data != nil {
+		n, err := w.Write(f.data)
+		if err != nil {
+			return int64(n), newError(ErrWrite, "WriteTo", err)
+		}
+		return int64(n), nil
+	}
+	rc, err := f.openReader(context.Background(), "WriteTo")
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	src := &readErrReader{r: rc}
+	n, err := io.Copy(w, src)
+	switch {
+	case src.err != nil:
+		return n, newError(ErrRead, "WriteTo", src.err)
+	case err != nil:
+		return n, newError(ErrWrite, "WriteTo", err)
+	}
+	return n, nil
+}
+
+// readErrReader records the first error other than io.EOF its reader
+// returns, to tell read failures from write failures in io.Copy.
+type readErrReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// openSource opens a streaming reader over the S3 object or Storage URI
+// of a File without content, like fetchSource without the buffering.
+func (f *File) openSource(ctx context.Context, op string) (io.ReadCloser, error) {
+	if f.missingErr != nil && loadNow().Sub(f.missingAt) < missingSourceTTL {
+		return nil, f.missingErr
+	}
+	if err := checkNetwork(ctx, op); err != nil {
+		return nil, err
+	}
+	f.missingErr = nil
+
+	ctx, cancel, dl, watch := withStreamTimeout(ctx, DownloadTimeout())
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		cancel()
+		return nil, dl.newError(ctx, ErrRead, op, err)
+	}
+
+	var rc io.ReadCloser
+	var missing bool
+	sentinel := ErrRead
+	switch f.source {
+	case SourceS3:
+		rc, err = f.openS3(ctx)
+		missing, sentinel = isS3NotFound(err), ErrS3
+	case SourceStorage:
+		rc, err = f.openStorage(ctx)
+		missing = errors.Is(err, ErrNotFound)
+	}
+	if err != nil {
+		if missing {
+			err = f.noteMissing(op, err)
+		} else {
+			err = dl.newError(ctx, sentinel, op, err)
+		}
+		release()
+		cancel()
+		return nil, err
+	}
+	// The caller sets the pace from here on; the budget only covers each
+	// wait for more of the body.
+	watch.pause()
+	return &streamBody{Reader: watch.source(rc), closer: rc, done: func() { release(); cancel() }}, nil
+}
+
+// streamBody is a streaming Reader result. Close closes the underlying
+// body, if any, then runs done once.
+type streamBody struct {
+	io.Reader
+	closer io.Closer
+	done   func()
+	once   sync.Once
+}
+
+func (s *streamBody) Close() error {
+	var err error
+	if s.closer != nil {
+		err = s.closer.Close()
+	}
+	if s.done != nil {
+		s.once.Do(s.done)
+	}
+	return err
+}
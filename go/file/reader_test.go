@@ -0,0 +1,223 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func readAndClose(t *testing.T, rc io.ReadCloser, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Reader: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return data
+}
+
+func TestReader_BufferedIsIndependent(t *testing.T) {
+	f, err := NewFromBytes([]byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, err := f.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := f.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := make([]byte, 5)
+	io.ReadFull(r1, head)
+	if got, _ := io.ReadAll(r2); string(got) != "hello world" {
+		t.Errorf("second reader = %q, want the whole content", got)
+	}
+	if rest, _ := io.ReadAll(r1); string(rest) != " world" {
+		t.Errorf("first reader rest = %q", rest)
+	}
+}
+
+func TestReader_LazyFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "big.txt")
+	payload := bytes.Repeat([]byte("line\n"), 50000)
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		rc, err := f.Reader()
+		if got := readAndClose(t, rc, err); !bytes.Equal(got, payload) {
+			t.Fatal("content mismatch")
+		}
+	}
+	if f.Loaded() {
+		t.Error("Reader should not buffer a lazy file")
+	}
+}
+
+func TestReader_StreamIsSingleUse(t *testing.T) {
+	f, err := NewFromStreamLazy(strings.NewReader(strings.Repeat("s", 100*1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := f.Reader()
+	if got := readAndClose(t, rc, err); len(got) != 100*1024 {
+		t.Errorf("read %d bytes, want %d", len(got), 100*1024)
+	}
+	if _, err := f.Reader(); !errors.Is(err, ErrRead) {
+		t.Errorf("second Reader: err = %v, want ErrRead", err)
+	}
+}
+
+// closeTracker records whether the body was closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error { c.closed = true; return nil }
+
+func TestReader_S3Streams(t *testing.T) {
+	body := &closeTracker{Reader: strings.NewReader("streamed from s3")}
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: body}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k.txt"}
+	rc, err := f.Reader()
+	if got := readAndClose(t, rc, err); string(got) != "streamed from s3" {
+		t.Errorf("content = %q", got)
+	}
+	if !body.closed {
+		t.Error("Close should close the S3 body")
+	}
+	if f.Loaded() {
+		t.Error("Reader should not buffer an S3 object")
+	}
+}
+
+func TestReader_Errors(t *testing.T) {
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return nil, &types.NoSuchKey{}
+		},
+	}, &mockPresignClient{})()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "gone.txt"}
+	if _, err := f.Reader(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing object: err = %v, want ErrNotFound", err)
+	}
+
+	dir, err := NewFromFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Reader(); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("directory: err = %v, want ErrInvalidSource", err)
+	}
+}
+
+// writeCounter counts the Write calls made on it.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestWriteTo_MatchesRead(t *testing.T) {
+	payload := append(append([]byte{}, pngBytes...), generateRandomBytes(t, 200*1024)...)
+	p := filepath.Join(t.TempDir(), "img.png")
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(payload))}, nil
+		},
+	}, &mockPresignClient{})()
+
+	cases := map[string]func() *File{
+		"buffered":    func() *File { f, _ := NewFromBytes(payload); return f },
+		"lazy file":   func() *File { f, _ := NewFromFileLazy(p); return f },
+		"lazy stream": func() *File { f, _ := NewFromStreamLazy(bytes.NewReader(payload)); return f },
+		"reader at": func() *File {
+			f, _ := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+			return f
+		},
+		"s3": func() *File { return &File{source: SourceS3, s3Bucket: "bucket", s3Key: "img.png"} },
+	}
+	for name, newFile := range cases {
+		want, err := newFile().Read()
+		if err != nil {
+			t.Fatalf("%s: Read: %v", name, err)
+		}
+		var w io.WriterTo = newFile()
+		var buf bytes.Buffer
+		n, err := w.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("%s: WriteTo: %v", name, err)
+		}
+		if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: WriteTo wrote %d bytes that differ from Read's %d", name, n, len(want))
+		}
+		if name != "buffered" && w.(*File).Loaded() {
+			t.Errorf("%s: WriteTo should stream without buffering", name)
+		}
+	}
+
+	f, _ := NewFromBytes(payload)
+	var wc writeCounter
+	if _, err := f.WriteTo(&wc); err != nil {
+		t.Fatal(err)
+	}
+	if wc.writes != 1 {
+		t.Errorf("buffered content took %d writes, want 1", wc.writes)
+	}
+}
+
+// failingWriter rejects every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("pipe closed") }
+
+func TestWriteTo_Errors(t *testing.T) {
+	if _, err := (&File{}).WriteTo(io.Discard); !errors.Is(err, ErrRead) {
+		t.Errorf("no content: err = %v, want ErrRead", err)
+	}
+
+	buffered, _ := NewFromBytes([]byte("data"))
+	p := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(p, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lazy, _ := NewFromFileLazy(p)
+	for name, f := range map[string]*File{"buffered": buffered, "lazy file": lazy} {
+		if _, err := f.WriteTo(failingWriter{}); !errors.Is(err, ErrWrite) {
+			t.Errorf("%s: failed write: err = %v, want ErrWrite", name, err)
+		}
+	}
+}
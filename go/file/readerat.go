@@ -0,0 +1,45 @@
+package file
+
+import (
+	"fmt"
+	"io"
+)
+
+// NewFromReaderAt creates a File view over an existing io.ReaderAt (such as
+// an already-open *os.File) without re-opening or buffering it. Only the
+// first few KB are read, via ReadAt, for magic-byte detection; Checksum,
+// Save, IterBytes, and UploadToS3 then stream through io.SectionReader views,
+// so concurrent operations don't share a read offset.
+//
+// The caller retains ownership of r: the File never closes it, and r must
+// stay valid (open, unmodified) for as long as the File is in use. Read()
+// buffers the full content on first call, like every other source.
+func NewFromReaderAt(r io.ReaderAt, size int64, opts ...ReadOption) (*File, error) {
+	if r == nil {
+		return nil, newError(ErrInvalidSource, "NewFromReaderAt", fmt.Errorf("reader is nil"))
+	}
+	if size < 0 {
+		return nil, newError(ErrInvalidSource, "NewFromReaderAt", fmt.Errorf("negative size %d", size))
+	}
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromReaderAt", ro.validate()); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, min(size, streamHeadBytes))
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !(err == io.EOF && n == len(head)) {
+		return nil, newError(ErrRead, "NewFromReaderAt", err)
+	}
+
+	meta := resolveMetadataFromBytes(head, hint)
+	meta.Size = size
+	meta.noteOrigin(OriginContent)
+
+	return withProvenance(ro.normalizeName(&File{
+		source:       SourceStream,
+		meta:         meta,
+		readerAt:     r,
+		readerAtSize: size,
+	})), nil
+}
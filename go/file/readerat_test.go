@@ -0,0 +1,176 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestNewFromReaderAt_OSFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "icon.png")
+	payload := append(append([]byte{}, pngBytes...), generateRandomBytes(t, 100*1024)...)
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fl, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+
+	f, err := NewFromReaderAt(fl, int64(len(payload)), MetadataHint{Name: "icon.png"})
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	if f.MimeType() != "image/png" {
+		t.Errorf("MimeType() = %q, want image/png", f.MimeType())
+	}
+	if f.Size() != int64(len(payload)) {
+		t.Errorf("Size() = %d, want %d", f.Size(), len(payload))
+	}
+	if f.data != nil {
+		t.Fatal("content should not be buffered at construction")
+	}
+
+	sum := sha256.Sum256(payload)
+	got, err := f.Checksum()
+	if err != nil {
+		t.Fatalf("Checksum: %v", err)
+	}
+	if got != hex.EncodeToString(sum[:]) {
+		t.Error("Checksum mismatch")
+	}
+	if f.data != nil {
+		t.Error("Checksum should stream without buffering")
+	}
+
+	saved, err := f.Save(filepath.Join(t.TempDir(), "copy.png"))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if saved.Size() != int64(len(payload)) {
+		t.Errorf("saved size = %d", saved.Size())
+	}
+
+	data, err := f.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Error("Read content mismatch")
+	}
+}
+
+func TestNewFromReaderAt_ConcurrentSectionReads(t *testing.T) {
+	payload := generateRandomBytes(t, 300*1024)
+	f, err := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	sum := sha256.Sum256(payload)
+	want := hex.EncodeToString(sum[:])
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := f.Checksum()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if got != want {
+				errs <- errors.New("checksum mismatch under concurrency")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestNewFromReaderAt_IterBytes(t *testing.T) {
+	payload := generateRandomBytes(t, 150*1024)
+	f, _ := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+
+	chunks, errc := f.IterBytes(context.Background())
+	var got []byte
+	for c := range chunks {
+		got = append(got, c...)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("IterBytes: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("IterBytes len=%d, want %d", len(got), len(payload))
+	}
+}
+
+func TestNewFromReaderAt_UploadToS3StreamsSection(t *testing.T) {
+	payload := generateRandomBytes(t, 100*1024)
+	var (
+		body     []byte
+		isSeeker bool
+		length   int64
+	)
+	mock := &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			_, isSeeker = params.Body.(io.Seeker)
+			length = *params.ContentLength
+			body, _ = io.ReadAll(params.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	cleanup := setMockS3(mock, &mockPresignClient{})
+	defer cleanup()
+
+	f, _ := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if !isSeeker {
+		t.Error("upload body should be a seekable section reader")
+	}
+	if length != int64(len(payload)) || !bytes.Equal(body, payload) {
+		t.Errorf("uploaded %d bytes (ContentLength %d), want %d", len(body), length, len(payload))
+	}
+	if f.data != nil {
+		t.Error("upload should not buffer the content")
+	}
+}
+
+func TestNewFromReaderAt_InvalidArgs(t *testing.T) {
+	if _, err := NewFromReaderAt(nil, 1); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("nil reader: got %v", err)
+	}
+	if _, err := NewFromReaderAt(bytes.NewReader(nil), -1); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("negative size: got %v", err)
+	}
+	// Declared size larger than the underlying content fails at detection.
+	if _, err := NewFromReaderAt(bytes.NewReader([]byte("ab")), 10); !errors.Is(err, ErrRead) {
+		t.Errorf("short reader: got %v", err)
+	}
+}
+
+func TestNewFromReaderAt_Empty(t *testing.T) {
+	f, err := NewFromReaderAt(bytes.NewReader(nil), 0)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt: %v", err)
+	}
+	if f.Size() != 0 {
+		t.Errorf("Size() = %d", f.Size())
+	}
+}
@@ -0,0 +1,81 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RedactNames, when true, makes String() show a short hash of the file name
+// instead of the name itself, and error messages that describe a File show
+// it that way, or its path as a hash. For deployments where file names are
+// regulated data. Set it once at startup.
+//
+// Only the File's own name and path are covered. Paths, keys and URIs the
+// caller passes to an operation, such as a Save destination, and the paths
+// in errors from the operating system still appear as given.
+var RedactNames bool
+
+// redactedMarker replaces masked values in error text.
+const redactedMarker = "REDACTED"
+
+var (
+	// urlInText matches absolute URLs inside free-form error text, stopping
+	// at whitespace and the quotes net/http puts around them.
+	urlInText = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+	// authInText matches Authorization header values and bearer tokens,
+	// which can reach error text through echoed response bodies.
+	authInText   = regexp.MustCompile(`(?i)(authorization:\s*)(?:(?:bearer|basic)\s+)?[^\s"',;]+`)
+	bearerInText = regexp.MustCompile(`(?i)\b(bearer\s+)[^\s"',;]+`)
+)
+
+// redactText masks credentials in error text: URL query strings and user
+// info (presigned URL signatures live there), Authorization header values
+// and bearer tokens. Scheme, host and path are kept for debugging.
+func redactText(s string) string {
+	s = urlInText.ReplaceAllStringFunc(s, redactURL)
+	s = authInText.ReplaceAllString(s, "${1}"+redactedMarker)
+	return bearerInText.ReplaceAllString(s, "${1}"+redactedMarker)
+}
+
+// redactURL drops the user info, query string and fragment of raw, marking
+// a dropped query with "?REDACTED".
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if i := strings.IndexAny(raw, "?#"); i >= 0 {
+			return raw[:i] + "?" + redactedMarker
+		}
+		return raw
+	}
+	if u.User == nil && u.RawQuery == "" && u.Fragment == "" {
+		return raw
+	}
+	hadQuery := u.RawQuery != ""
+	u.User, u.RawQuery, u.Fragment, u.RawFragment, u.ForceQuery = nil, "", "", "", false
+	s := u.String()
+	if hadQuery {
+		s += "?" + redactedMarker
+	}
+	return s
+}
+
+// shownPath returns the path of a File as error messages show it: hashed
+// under RedactNames, as given otherwise.
+func shownPath(p string) string {
+	if RedactNames {
+		return redactedName(p)
+	}
+	return p
+}
+
+// redactedName returns the name String() shows under RedactNames.
+func redactedName(name string) string {
+	if name == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(name))
+	return "name-" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,80 @@
+package file
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// WithNoRedirects makes NewFromURL fail with ErrHTTP instead of following
+// a redirect; the error's StatusCode is the redirect's status. An
+// *http.Client is asked not to follow redirects. Another HTTPDoer may
+// still follow them, and then the response is rejected because it was
+// redirected.
+func WithNoRedirects() ReadOption {
+	return readOption(func(o *readOptions) { o.noRedirects = true })
+}
+
+// withoutRedirects returns a copy of c that does not follow redirects, or
+// c itself when it is not an *http.Client.
+func withoutRedirects(c HTTPDoer) HTTPDoer {
+	hc, ok := c.(*http.Client)
+	if !ok {
+		return c
+	}
+	cp := *hc
+	cp.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &cp
+}
+
+// checkNotRedirected fails when resp is a redirect or was reached through
+// one.
+func checkNotRedirected(resp *http.Response, op string) error {
+	// Find the first redirect response.
+	first := resp
+	for first.Request != nil && first.Request.Response != nil {
+		first = first.Request.Response
+	}
+	location := first.Header.Get("Location")
+	if first.StatusCode < 300 || first.StatusCode >= 400 || location == "" {
+		return nil
+	}
+	e := newError(ErrHTTP, op, fmt.Errorf("redirected to %s; WithNoRedirects disallows redirects", location))
+	e.StatusCode = first.StatusCode
+	return e
+}
+
+// RedirectChain returns the URLs NewFromURL requested to fetch the file
+// when the server redirected it: the original URL first and the final one,
+// which URL reports, last. It is nil when there was no redirect, or the
+// File did not come from NewFromURL.
+func (f *File) RedirectChain() []string {
+	return slices.Clone(f.redirects)
+}
+
+// finalURL returns the URL resp was served from after redirects, or rawURL
+// when there were none.
+func finalURL(resp *http.Response, rawURL string) string {
+	if resp == nil || resp.Request == nil || resp.Request.Response == nil {
+		return rawURL
+	}
+	return resp.Request.URL.String()
+}
+
+// redirectChain lists the URLs requested on the way to resp, oldest first,
+// or nil when resp was not redirected.
+func redirectChain(resp *http.Response) []string {
+	if resp == nil || resp.Request == nil || resp.Request.Response == nil {
+		return nil
+	}
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append(chain, req.URL.String())
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	slices.Reverse(chain)
+	return chain
+}
@@ -0,0 +1,83 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// redirectServer redirects /dl?id=7 through /hop to /cdn/report.pdf.
+func redirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dl":
+			http.Redirect(w, r, "/hop", http.StatusFound)
+		case "/hop":
+			http.Redirect(w, r, "/cdn/report.pdf", http.StatusMovedPermanently)
+		case "/cdn/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewFromURL_FollowsRedirects(t *testing.T) {
+	srv := redirectServer(t)
+	defer setMockHTTP(srv.Client())()
+
+	f, err := NewFromURL(srv.URL + "/dl?id=7")
+	if err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+	if got, want := f.URL(), srv.URL+"/cdn/report.pdf"; got != want {
+		t.Errorf("URL() = %q, want the final URL %q", got, want)
+	}
+	if f.Name() != "report.pdf" {
+		t.Errorf("Name() = %q, want the final URL's filename", f.Name())
+	}
+	want := []string{srv.URL + "/dl?id=7", srv.URL + "/hop", srv.URL + "/cdn/report.pdf"}
+	if got := f.RedirectChain(); !slices.Equal(got, want) {
+		t.Errorf("RedirectChain() = %v, want %v", got, want)
+	}
+
+	direct, err := NewFromURL(srv.URL + "/cdn/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.RedirectChain() != nil || direct.URL() != srv.URL+"/cdn/report.pdf" {
+		t.Errorf("no redirect: URL() = %q, RedirectChain() = %v", direct.URL(), direct.RedirectChain())
+	}
+}
+
+// plainDoer is an HTTPDoer that is not an *http.Client.
+type plainDoer struct{ c *http.Client }
+
+func (d plainDoer) Do(req *http.Request) (*http.Response, error) { return d.c.Do(req) }
+
+func TestNewFromURL_WithNoRedirects(t *testing.T) {
+	srv := redirectServer(t)
+	for name, doer := range map[string]HTTPDoer{"http.Client": srv.Client(), "other doer": plainDoer{srv.Client()}} {
+		orig := CurrentHTTPClient()
+		SetHTTPClient(doer)
+		_, err := NewFromURLWithContext(context.Background(), srv.URL+"/dl?id=7", WithNoRedirects())
+		SetHTTPClient(orig)
+
+		var fe *FileError
+		if !errors.Is(err, ErrHTTP) || !errors.As(err, &fe) || fe.StatusCode != http.StatusFound {
+			t.Errorf("%s: err = %v, want ErrHTTP with status 302", name, err)
+		}
+	}
+
+	defer setMockHTTP(srv.Client())()
+	if _, err := NewFromURL(srv.URL+"/cdn/report.pdf", WithNoRedirects()); err != nil {
+		t.Errorf("no redirect: %v", err)
+	}
+}
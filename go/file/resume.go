@@ -0,0 +1,272 @@
+package file
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resumeState is the sidecar DownloadURLToFile keeps next to a .part file.
+// It records which URL the partial bytes came from and the validator that
+// must still match before they may be extended.
+type resumeState struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// stateFromResponse returns the resume state for the body resp carries.
+func stateFromResponse(rawURL string, resp *http.Response) resumeState {
+	return resumeState{URL: rawURL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+}
+
+// validator returns the If-Range value for s: the ETag when it is strong,
+// otherwise Last-Modified. It is empty when neither can be used.
+func (s resumeState) validator() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+// matches reports whether resp describes the same content s was recorded
+// for.
+func (s resumeState) matches(resp *http.Response) bool {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return resp.Header.Get("ETag") == s.ETag
+	}
+	return s.LastModified != "" && resp.Header.Get("Last-Modified") == s.LastModified
+}
+
+// DownloadURLToFile streams rawURL to destPath without buffering it in
+// memory and returns a File for the written path whose provenance records
+// the URL. Bytes are written to destPath+".part", and the response's ETag
+// or Last-Modified is recorded in destPath+".part.json"; if the download
+// is interrupted, calling DownloadURLToFile again with the same URL and
+// path resumes from the end of the .part file with a Range request, using
+// If-Range so the server only continues when the content is unchanged.
+// When the content has changed, the server does not support ranges, or
+// the first response carried no validator, the download transparently
+// restarts from the beginning. On completion the .part file is renamed
+// into place, honouring WithCollisionStrategy, and the sidecar removed;
+// the returned File reads the written path lazily. Pass WithVerify to
+// check the finished file against the bytes written, including those from
+// earlier attempts. When ctx has no deadline, DownloadTimeout bounds each
+// request and each wait for more of the body, not the whole download.
+func DownloadURLToFile(ctx context.Context, rawURL, destPath string, opts ...SaveOption) (*File, error) {
+	o := newSaveOptions(opts)
+	if err := checkOptions("DownloadURLToFile", o.validate()); err != nil {
+		return nil, err
+	}
+	destPath, err := o.resolveDest(destPath, "DownloadURLToFile")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel, dl, watch := withStreamTimeout(ctx, DownloadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrHTTP, "DownloadURLToFile", err)
+	}
+	defer release()
+
+	if o.collision == CollisionErrorOut {
+		// Fail before fetching anything; claimPath checks again at the end.
+		if _, err := os.Lstat(destPath); err == nil {
+			return nil, newError(ErrExists, "DownloadURLToFile", fmt.Errorf("%s already exists", destPath))
+		}
+	}
+	if err := o.mkdirAll(filepath.Dir(destPath)); err != nil {
+		return nil, newError(ErrWrite, "DownloadURLToFile", err)
+	}
+	part := destPath + ".part"
+	statePath := part + ".json"
+
+	state, offset := loadResumeState(part, statePath, rawURL)
+	resp, offset, err := requestResume(ctx, dl, watch, rawURL, state, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	watch.pause()
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if offset == 0 {
+		flag |= os.O_TRUNC
+	}
+	out, err := os.OpenFile(part, flag, 0o644)
+	if err != nil {
+		return nil, newError(ErrWrite, "DownloadURLToFile", err)
+	}
+	if offset == 0 {
+		// A fresh body: record what it can be resumed against, or make
+		// sure a stale sidecar cannot vouch for it.
+		next := stateFromResponse(rawURL, resp)
+		if next.validator() != "" {
+			err = saveResumeState(statePath, next)
+		} else if rerr := os.Remove(statePath); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			err = rerr
+		}
+		if err != nil {
+			_ = out.Close()
+			return nil, newError(ErrWrite, "DownloadURLToFile", err)
+		}
+	}
+
+	var h hash.Hash
+	if o.verify {
+		if h, err = hashPrefix(part, offset, o.algo); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+	}
+	wire := &countingReader{r: watch.source(resp.Body)}
+	var src io.Reader = wire
+	if h != nil {
+		src = io.TeeReader(wire, h)
+	}
+	_, err = io.Copy(wrapVerifyWriter(out), src)
+	if cerr := out.Close(); err == nil && cerr != nil {
+		return nil, newError(ErrWrite, "DownloadURLToFile", cerr)
+	}
+	if err != nil {
+		// The .part file and its sidecar stay behind for the next attempt.
+		return nil, dl.newError(ctx, ErrRead, "DownloadURLToFile", err)
+	}
+
+	if h != nil {
+		streamed := hex.EncodeToString(h.Sum(nil))
+		written, err := hashFile(part, o.algo)
+		if err != nil {
+			return nil, newError(ErrRead, "DownloadURLToFile", err)
+		}
+		if written != streamed {
+			_ = os.Remove(part)
+			_ = os.Remove(statePath)
+			return nil, newError(ErrChecksumMismatch, "DownloadURLToFile", fmt.Errorf("%s of written file %s does not match streamed digest %s", o.algo, written, streamed))
+		}
+	}
+	// The finished download takes the name claimPath reserves, so the
+	// collision strategy is applied against the destination as it is now.
+	destPath, err = claimPath(destPath, o.collision, "DownloadURLToFile")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(part, destPath); err != nil {
+		if o.collision != CollisionOverwrite {
+			_ = os.Remove(destPath)
+		}
+		return nil, newError(ErrWrite, "DownloadURLToFile", err)
+	}
+	_ = os.Remove(statePath)
+
+	saved, err := NewFromFileLazy(destPath)
+	if err != nil {
+		return nil, err
+	}
+	saved.inheritProvenance(withProvenance(&File{source: SourceURL, meta: Metadata{URL: rawURL}}))
+	saved.transferSize = wire.n
+	return saved, nil
+}
+
+// requestResume issues the GET for rawURL, asking for the bytes from
+// offset on when the .part file can be resumed. It returns the response
+// and the offset its body starts at, which is 0 when the server sent the
+// whole content instead. A ranged response that cannot be trusted to
+// continue the .part file is discarded and the request repeated without a
+// range. Each request gets the full watchdog budget.
+func requestResume(ctx context.Context, dl opDeadline, watch *watchdog, rawURL string, state resumeState, offset int64) (*http.Response, int64, error) {
+	for {
+		watch.kick()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, 0, newError(ErrHTTP, "DownloadURLToFile", err)
+		}
+		// A transparently decoded body would not line up with byte offsets.
+		req.Header.Set("Accept-Encoding", "identity")
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", state.validator())
+		}
+		resp, err := CurrentHTTPClient().Do(req)
+		if err != nil {
+			return nil, 0, dl.newError(ctx, ErrHTTP, "DownloadURLToFile", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return resp, 0, nil
+		case offset > 0 && resp.StatusCode == http.StatusPartialContent && state.matches(resp):
+			if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); ok && start == offset {
+				return resp, offset, nil
+			}
+		case offset == 0:
+			e := httpStatusError("DownloadURLToFile", resp)
+			_ = resp.Body.Close()
+			return nil, 0, e
+		}
+		// The range came back for different content or from the wrong
+		// place, or was refused: start over.
+		_ = resp.Body.Close()
+		offset = 0
+	}
+}
+
+// loadResumeState returns the recorded state for part and how many bytes
+// of it may be resumed from, which is 0 when the sidecar is missing,
+// unreadable, for another URL, or has no usable validator.
+func loadResumeState(part, statePath, rawURL string) (resumeState, int64) {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return resumeState{}, 0
+	}
+	var s resumeState
+	if err := json.Unmarshal(data, &s); err != nil || s.URL != rawURL || s.validator() == "" {
+		return resumeState{}, 0
+	}
+	info, err := os.Stat(part)
+	if err != nil {
+		return resumeState{}, 0
+	}
+	return s, info.Size()
+}
+
+// saveResumeState writes s to statePath.
+func saveResumeState(statePath string, s resumeState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, data, 0o644)
+}
+
+// hashPrefix returns a hasher that has already consumed the first n bytes
+// of the file at p.
+func hashPrefix(p string, n int64, algo Algorithm) (hash.Hash, error) {
+	h, err := algo.newHash()
+	if err != nil {
+		return nil, newError(ErrInvalidOption, "DownloadURLToFile", err)
+	}
+	if n == 0 {
+		return h, nil
+	}
+	fh, err := os.Open(p)
+	if err != nil {
+		return nil, newError(ErrRead, "DownloadURLToFile", err)
+	}
+	defer fh.Close()
+	if _, err := io.CopyN(h, fh, n); err != nil {
+		return nil, newError(ErrRead, "DownloadURLToFile", err)
+	}
+	return h, nil
+}
@@ -0,0 +1,191 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resumeServer serves content with an ETag, honoring Range and If-Range
+// through http.ServeContent. While cut is set, it sends the full headers
+// but aborts after cut bytes of body.
+type resumeServer struct {
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	cut     int
+	ranges  []string
+}
+
+func (s *resumeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, etag, cut := s.content, s.etag, s.cut
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	if cut > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content[:cut])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+}
+
+func TestDownloadURLToFile_ResumesAfterInterruption(t *testing.T) {
+	content := generateRandomBytes(t, 64*1024)
+	srv := &resumeServer{content: content, etag: `"v1"`, cut: 20000}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	_, err := DownloadURLToFile(context.Background(), ts.URL, dest)
+	if !errors.Is(err, ErrRead) {
+		t.Fatalf("interrupted download: err = %v, want ErrRead", err)
+	}
+	info, err := os.Stat(dest + ".part")
+	if err != nil || info.Size() != 20000 {
+		t.Fatalf(".part after interruption = %v, %v; want 20000 bytes", info, err)
+	}
+
+	srv.cut = 0
+	f, err := DownloadURLToFile(context.Background(), ts.URL, dest)
+	if err != nil {
+		t.Fatalf("resumed download: %v", err)
+	}
+	if got := srv.ranges[1]; got != "bytes=20000-" {
+		t.Errorf("resume Range = %q, want bytes=20000-", got)
+	}
+	if got := f.TransferSize(); got != int64(len(content)-20000) {
+		t.Errorf("TransferSize = %d, want %d", got, len(content)-20000)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("destination differs from content (err %v)", err)
+	}
+	for _, leftover := range []string{dest + ".part", dest + ".part.json"} {
+		if _, err := os.Stat(leftover); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind: %v", filepath.Base(leftover), err)
+		}
+	}
+	if p := f.Provenance(); len(p) == 0 || p[0].URL != ts.URL {
+		t.Errorf("Provenance = %+v, want the URL first", p)
+	}
+}
+
+func TestDownloadURLToFile_RestartsWhenETagChanges(t *testing.T) {
+	srv := &resumeServer{content: generateRandomBytes(t, 10000), etag: `"v1"`, cut: 4000}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	if _, err := DownloadURLToFile(context.Background(), ts.URL, dest); err == nil {
+		t.Fatal("interrupted download succeeded")
+	}
+
+	updated := generateRandomBytes(t, 12000)
+	srv.content, srv.etag, srv.cut = updated, `"v2"`, 0
+	f, err := DownloadURLToFile(context.Background(), ts.URL, dest, WithVerify(AlgorithmSHA256))
+	if err != nil {
+		t.Fatalf("DownloadURLToFile: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, updated) {
+		t.Fatal("destination is not the updated content")
+	}
+	if f.TransferSize() != int64(len(updated)) {
+		t.Errorf("TransferSize = %d, want the full %d", f.TransferSize(), len(updated))
+	}
+}
+
+func TestDownloadURLToFile_RestartsWhenRangesUnsupported(t *testing.T) {
+	content := generateRandomBytes(t, 10000)
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if calls == 1 {
+			w.Write(content[:3000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Write(content)
+	}))
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	if _, err := DownloadURLToFile(context.Background(), ts.URL, dest); err == nil {
+		t.Fatal("interrupted download succeeded")
+	}
+	if _, err := DownloadURLToFile(context.Background(), ts.URL, dest, WithVerify(AlgorithmSHA256)); err != nil {
+		t.Fatalf("DownloadURLToFile: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("destination has %d bytes, want the %d-byte content", len(got), len(content))
+	}
+}
+
+func TestDownloadURLToFile_HTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	dest := filepath.Join(t.TempDir(), "out.bin")
+	_, err := DownloadURLToFile(context.Background(), ts.URL, dest)
+	var fe *FileError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrHTTP) || fe.StatusCode != http.StatusNotFound {
+		t.Fatalf("err = %v, want ErrHTTP with status 404", err)
+	}
+	if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("destination created on error: %v", err)
+	}
+}
+
+func TestDownloadURLToFile_CollisionStrategy(t *testing.T) {
+	srv := &resumeServer{content: []byte("fresh"), etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer setMockHTTP(ts.Client())()
+
+	dest := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(dest, []byte("existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := DownloadURLToFile(context.Background(), ts.URL, dest, WithCollisionStrategy(CollisionErrorOut))
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("CollisionErrorOut: err = %v, want ErrExists", err)
+	}
+	if len(srv.ranges) != 0 {
+		t.Errorf("CollisionErrorOut fetched the URL %d times", len(srv.ranges))
+	}
+
+	f, err := DownloadURLToFile(context.Background(), ts.URL, dest, WithCollisionStrategy(CollisionRenameWithSuffix))
+	if err != nil {
+		t.Fatalf("CollisionRenameWithSuffix: %v", err)
+	}
+	if want := filepath.Join(filepath.Dir(dest), "out (1).txt"); f.Path() != want {
+		t.Errorf("Path = %q, want %q", f.Path(), want)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "existing" {
+		t.Errorf("original = %q, want it untouched", got)
+	}
+	if got, _ := f.Read(); string(got) != "fresh" {
+		t.Errorf("downloaded content = %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryDelay caps the exponential backoff between attempts. A
+// Retry-After header may ask for longer.
+const maxRetryDelay = 30 * time.Second
+
+// WithRetry makes NewFromURL retry transient failures: connection errors,
+// a body cut short, and 5xx and 429 responses. It makes at most
+// maxAttempts requests, waiting baseDelay, then twice that, and so on
+// (capped at 30s, with jitter) between them, or as long as a Retry-After
+// header asks. Each retry is a fresh request; nothing read by a failed
+// attempt is kept. Retrying stops early when the next wait would outlast
+// ctx's deadline. The error returned records how many attempts were made
+// in FileError.Attempts. maxAttempts below 1 or a negative baseDelay is
+// rejected.
+func WithRetry(maxAttempts int, baseDelay time.Duration) ReadOption {
+	return readOption(func(o *readOptions) {
+		if maxAttempts < 1 {
+			o.reject("WithRetry", "maxAttempts %d is below 1", maxAttempts)
+			return
+		}
+		if baseDelay < 0 {
+			o.reject("WithRetry", "negative base delay %s", baseDelay)
+			return
+		}
+		o.retry = retryPolicy{attempts: maxAttempts, base: baseDelay}
+	})
+}
+
+// retryPolicy is the resolved WithRetry setting. The zero value makes a
+// single attempt.
+type retryPolicy struct {
+	attempts int
+	base     time.Duration
+}
+
+// retryHint tells the retry loop whether a failed attempt may be retried
+// and, when the server said, after how long.
+type retryHint struct {
+	ok    bool
+	after time.Duration
+}
+
+// do runs attempt until it succeeds, fails permanently, or the policy's
+// attempts are spent, sleeping between attempts. When retrying is enabled
+// the returned error records the attempts made.
+func (p retryPolicy) do(ctx context.Context, dl opDeadline, op string, attempt func() (retryHint, error)) error {
+	for n := 1; ; n++ {
+		hint, err := attempt()
+		if err == nil {
+			return nil
+		}
+		var fe *FileError
+		if p.attempts > 0 && errors.As(err, &fe) {
+			fe.Attempts = n
+		}
+		if !hint.ok || n >= p.attempts || ctx.Err() != nil {
+			return err
+		}
+		wait := p.backoff(n, hint.after)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			e := dl.newError(ctx, ErrHTTP, op, ctx.Err())
+			e.Attempts = n
+			return e
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns the wait after failed attempt n: the server's Retry-After
+// when it sent one, otherwise the base delay doubled per attempt and
+// jittered into its upper half.
+func (p retryPolicy) backoff(n int, after time.Duration) time.Duration {
+	if after > 0 {
+		return after
+	}
+	d := maxRetryDelay
+	if n-1 < 32 && p.base<<(n-1) < maxRetryDelay {
+		d = p.base << (n - 1)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryStatus reports whether a response status is worth retrying, and
+// the Retry-After delay it carries.
+func retryStatus(resp *http.Response) retryHint {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return retryHint{}
+	}
+	return retryHint{ok: true, after: retryAfter(resp.Header.Get("Retry-After"), time.Now())}
+}
+
+// retryAfter parses a Retry-After value, either delay seconds or an HTTP
+// date, into a duration from now. Zero means absent or unparsable.
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// retryTransport reports whether a failed request or body read may be
+// retried: it may unless ctx ended.
+func retryTransport(ctx context.Context, err error) retryHint {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return retryHint{}
+	}
+	return retryHint{ok: true}
+}
@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first fails requests with fail, then serves body.
+func flakyServer(t *testing.T, fails int32, fail func(w http.ResponseWriter)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= fails {
+			fail(w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestWithRetry_RecoversFromTransientStatus(t *testing.T) {
+	srv, calls := flakyServer(t, 2, func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) })
+	defer setMockHTTP(srv.Client())()
+
+	f, err := NewFromURL(srv.URL+"/a.txt", WithRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+	if data, _ := f.Read(); string(data) != "hello" || calls.Load() != 3 {
+		t.Errorf("content = %q after %d requests, want hello after 3", data, calls.Load())
+	}
+}
+
+func TestWithRetry_ReportsAttempts(t *testing.T) {
+	srv, calls := flakyServer(t, 10, func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) })
+	defer setMockHTTP(srv.Client())()
+
+	_, err := NewFromURL(srv.URL, WithRetry(3, time.Millisecond))
+	var fe *FileError
+	if !errors.As(err, &fe) || fe.StatusCode != http.StatusServiceUnavailable || fe.Attempts != 3 {
+		t.Fatalf("err = %v, want a 503 after 3 attempts", err)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") || calls.Load() != 3 {
+		t.Errorf("Error() = %q after %d requests", err, calls.Load())
+	}
+}
+
+func TestWithRetry_DoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := flakyServer(t, 10, func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) })
+	defer setMockHTTP(srv.Client())()
+
+	_, err := NewFromURL(srv.URL, WithRetry(5, time.Millisecond))
+	var fe *FileError
+	if !errors.As(err, &fe) || fe.Attempts != 1 || calls.Load() != 1 {
+		t.Errorf("404: err = %v after %d requests, want one attempt", err, calls.Load())
+	}
+
+	// Without WithRetry a transient failure is not retried and no attempt
+	// count is recorded.
+	srv, calls = flakyServer(t, 1, func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) })
+	defer setMockHTTP(srv.Client())()
+	_, err = NewFromURL(srv.URL)
+	if !errors.As(err, &fe) || fe.Attempts != 0 || calls.Load() != 1 {
+		t.Errorf("no retry: err = %v after %d requests", err, calls.Load())
+	}
+}
+
+func TestWithRetry_HonorsRetryAfter(t *testing.T) {
+	srv, calls := flakyServer(t, 1, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer setMockHTTP(srv.Client())()
+
+	start := time.Now()
+	if _, err := NewFromURL(srv.URL, WithRetry(2, time.Millisecond)); err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || calls.Load() != 2 {
+		t.Errorf("retried after %s with %d requests, want a 1s wait", elapsed, calls.Load())
+	}
+
+	// A Retry-After past the deadline ends the retries at once.
+	srv, calls = flakyServer(t, 10, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer setMockHTTP(srv.Client())()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start = time.Now()
+	_, err := NewFromURLWithContext(ctx, srv.URL, WithRetry(5, time.Millisecond))
+	var fe *FileError
+	if !errors.As(err, &fe) || fe.StatusCode != http.StatusTooManyRequests || fe.Attempts != 1 || time.Since(start) > time.Second {
+		t.Errorf("err = %v after %s, want the 429 without waiting", err, time.Since(start))
+	}
+}
+
+func TestWithRetry_RestartsTruncatedBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Promise more than is sent, then drop the connection.
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("partial"))
+			return
+		}
+		w.Write([]byte("complete body"))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	f, err := NewFromURL(srv.URL, WithRetry(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+	if data, _ := f.Read(); string(data) != "complete body" {
+		t.Errorf("content = %q, want only the second response", data)
+	}
+}
+
+func TestWithRetry_Options(t *testing.T) {
+	for _, opt := range []ReadOption{WithRetry(0, time.Second), WithRetry(3, -time.Second)} {
+		if _, err := NewFromURL("http://example.invalid", opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("err = %v, want ErrInvalidOption", err)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"-3":                            0,
+		"soon":                          0,
+		"Wed, 01 May 2024 12:00:30 GMT": 30 * time.Second,
+		"Wed, 01 May 2024 11:00:00 GMT": 0,
+	}
+	for in, want := range cases {
+		if got := retryAfter(in, now); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := retryPolicy{attempts: 10, base: 100 * time.Millisecond}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 60: maxRetryDelay} {
+		if got := p.backoff(n, 0); got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %s, want within [%s, %s]", n, got, want/2, want)
+		}
+	}
+	if got := p.backoff(1, 5*time.Second); got != 5*time.Second {
+		t.Errorf("Retry-After should win: backoff = %s", got)
+	}
+}
@@ -0,0 +1,305 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// auditSniffBytes is how much of each object AuditS3Prefix downloads for
+// magic-byte detection.
+const auditSniffBytes = 3 << 10
+
+// defaultAuditConcurrency is how many objects AuditS3Prefix inspects at
+// once unless WithAuditConcurrency says otherwise.
+const defaultAuditConcurrency = 8
+
+// AuditOption configures AuditS3Prefix.
+type AuditOption func(*auditOptions)
+
+func (AuditOption) isOption() {}
+
+// auditOptions is the resolved set of AuditOption values.
+type auditOptions struct {
+	optionErrors
+	concurrency int
+	startAfter  string
+}
+
+// newAuditOptions applies opts over the defaults.
+func newAuditOptions(opts []AuditOption) auditOptions {
+	o := auditOptions{concurrency: defaultAuditConcurrency}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values.
+func (o auditOptions) validate() error {
+	return errors.Join(o.errs...)
+}
+
+// WithAuditConcurrency sets how many objects AuditS3Prefix inspects (and
+// repairs) at once (default 8). n must be at least 1.
+func WithAuditConcurrency(n int) AuditOption {
+	return func(o *auditOptions) {
+		if n < 1 {
+			o.reject("WithAuditConcurrency", "concurrency %d is below 1", n)
+			return
+		}
+		o.concurrency = n
+	}
+}
+
+// WithAuditStartAfter resumes an audit after key, typically the LastKey of
+// a report from an interrupted run.
+func WithAuditStartAfter(key string) AuditOption {
+	return func(o *auditOptions) { o.startAfter = key }
+}
+
+// AuditEntry is AuditS3Prefix's finding for one object.
+type AuditEntry struct {
+	Key string
+	// StoredType is the object's ContentType; DetectedType and
+	// DetectedExtension come from magic-byte detection and are empty when
+	// it was inconclusive.
+	StoredType        string
+	DetectedType      string
+	DetectedExtension string
+	// TypeMismatch is set when StoredType is missing, generic or
+	// contradicted by the content. Two textual types are never a mismatch:
+	// a few KB cannot tell CSV from plain text reliably.
+	TypeMismatch bool
+	// ExtensionMismatch is set when the key's extension disagrees with
+	// DetectedExtension. Keys are never renamed.
+	ExtensionMismatch bool
+	// Fixed is set when the object's metadata was rewritten.
+	Fixed bool
+	// BytesScanned is how much of the object was downloaded.
+	BytesScanned int64
+	// Err is the error inspecting or repairing this object, if any.
+	Err error
+}
+
+// AuditReport is the result of AuditS3Prefix.
+type AuditReport struct {
+	// Entries holds one entry per object, in key order.
+	Entries []AuditEntry
+	// BytesScanned is the total downloaded across Entries.
+	BytesScanned int64
+	// Mismatched and Fixed count entries with TypeMismatch and Fixed set.
+	Mismatched int
+	Fixed      int
+	// LastKey is the last key covered by the report. Pass it to
+	// WithAuditStartAfter to resume a run that failed or was cancelled.
+	LastKey string
+}
+
+// AuditS3Prefix checks every object under prefix for a ContentType that
+// disagrees with its content. Only the first 3 KB of each object is
+// downloaded, with a ranged GET, for magic-byte detection; objects are
+// inspected WithAuditConcurrency at a time (default 8) and listed a page
+// at a time, so a long audit's report can be resumed from LastKey.
+//
+// With fix set, each mismatched object is copied onto itself with
+// MetadataDirective REPLACE to store the detected ContentType. Its other
+// system and user metadata are carried over, and a Content-Disposition
+// filename with the wrong extension gets the detected one. CopyObject
+// handles objects up to 5 GB, and on versioned buckets the copy is a new
+// version.
+//
+// Per-object failures are recorded in the entry's Err and do not stop the
+// audit. A failed listing stops it and is returned with the report so far.
+// MetadataTimeout applies to each request when ctx has no deadline.
+func AuditS3Prefix(ctx context.Context, bucket, prefix string, fix bool, opts ...AuditOption) (AuditReport, error) {
+	o := newAuditOptions(opts)
+	var report AuditReport
+	if err := checkOptions("AuditS3Prefix", o.validate()); err != nil {
+		return report, err
+	}
+	prefix, err := checkS3Prefix("AuditS3Prefix", bucket, prefix)
+	if err != nil {
+		return report, err
+	}
+
+	s3Client, _ := s3Clients()
+	input := &s3.ListObjectsV2Input{
+		Bucket:     aws.String(bucket),
+		Prefix:     nilIfEmpty(prefix),
+		StartAfter: nilIfEmpty(o.startAfter),
+	}
+	for {
+		page, err := listS3Page(ctx, s3Client, input, "AuditS3Prefix")
+		if err != nil {
+			return report, err
+		}
+
+		var keys []string
+		for _, obj := range page.Contents {
+			if key := aws.ToString(obj.Key); !strings.HasSuffix(key, "/") {
+				keys = append(keys, key)
+			}
+		}
+		entries := make([]AuditEntry, len(keys))
+		sem := make(chan struct{}, o.concurrency)
+		var wg sync.WaitGroup
+		for i, key := range keys {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				entries[i] = auditS3Object(ctx, s3Client, bucket, key, fix)
+			}()
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			// Entries of this page may be incomplete; leave LastKey at
+			// the previous page so a resumed run redoes it.
+			return report, newError(ErrS3, "AuditS3Prefix", err)
+		}
+
+		for _, e := range entries {
+			report.add(e)
+		}
+		if n := len(page.Contents); n > 0 {
+			report.LastKey = aws.ToString(page.Contents[n-1].Key)
+		}
+		if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+			return report, nil
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+}
+
+// add appends e and updates the totals.
+func (r *AuditReport) add(e AuditEntry) {
+	r.Entries = append(r.Entries, e)
+	r.BytesScanned += e.BytesScanned
+	if e.TypeMismatch {
+		r.Mismatched++
+	}
+	if e.Fixed {
+		r.Fixed++
+	}
+}
+
+// auditS3Object inspects one object and, when fix is set and its type is
+// wrong, repairs it.
+func auditS3Object(ctx context.Context, s3Client S3API, bucket, key string, fix bool) AuditEntry {
+	e := AuditEntry{Key: key}
+	out, head, err := sniffS3Object(ctx, s3Client, bucket, key)
+	if err != nil {
+		e.Err = err
+		return e
+	}
+	e.BytesScanned = int64(len(head))
+	e.StoredType = aws.ToString(out.ContentType)
+	e.DetectedType, e.DetectedExtension = magicDetect(head)
+	if e.DetectedType == "" {
+		return e
+	}
+	e.TypeMismatch = typeDrifted(e.StoredType, e.DetectedType)
+	if ext := StrictExtensionFromFilename(key); ext != "" && e.DetectedExtension != "" {
+		e.ExtensionMismatch = !strings.EqualFold(ext, e.DetectedExtension)
+	}
+	if fix && e.TypeMismatch {
+		if err := repairS3ContentType(ctx, s3Client, bucket, key, out, e.DetectedType, e.DetectedExtension); err != nil {
+			e.Err = err
+		} else {
+			e.Fixed = true
+		}
+	}
+	return e
+}
+
+// typeDrifted reports whether a stored ContentType should be replaced by
+// the detected one.
+func typeDrifted(stored, detected string) bool {
+	if stored == "" || isOctetStream(stored) {
+		return !isOctetStream(detected)
+	}
+	if isTextualMimeType(stored) && isTextualMimeType(detected) {
+		return false
+	}
+	return !strings.EqualFold(mediaTypeBase(stored), mediaTypeBase(detected))
+}
+
+// sniffS3Object fetches the first auditSniffBytes of an object with a
+// ranged GET.
+func sniffS3Object(ctx context.Context, s3Client S3API, bucket, key string) (*s3.GetObjectOutput, []byte, error) {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", auditSniffBytes-1)),
+	})
+	if err != nil {
+		return nil, nil, dl.newError(ctx, ErrS3, "AuditS3Prefix", fmt.Errorf("%s: %w", key, err))
+	}
+	defer out.Body.Close()
+	head, err := io.ReadAll(io.LimitReader(out.Body, auditSniffBytes))
+	if err != nil {
+		return nil, nil, dl.newError(ctx, ErrRead, "AuditS3Prefix", fmt.Errorf("%s: %w", key, err))
+	}
+	return out, head, nil
+}
+
+// repairS3ContentType copies an object onto itself with mimeType as its
+// ContentType, carrying the rest of the metadata from out.
+func repairS3ContentType(ctx context.Context, s3Client S3API, bucket, key string, out *s3.GetObjectOutput, mimeType, ext string) error {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+	_, err := s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(key),
+		CopySource:         aws.String(copySource(bucket, key)),
+		MetadataDirective:  types.MetadataDirectiveReplace,
+		ContentType:        aws.String(mimeType),
+		ContentDisposition: nilIfEmpty(fixDispositionExtension(aws.ToString(out.ContentDisposition), ext)),
+		CacheControl:       out.CacheControl,
+		ContentEncoding:    out.ContentEncoding,
+		ContentLanguage:    out.ContentLanguage,
+		Metadata:           out.Metadata,
+	})
+	if err != nil {
+		return dl.newError(ctx, ErrS3, "AuditS3Prefix", fmt.Errorf("%s: %w", key, err))
+	}
+	return nil
+}
+
+// copySource is the URL-encoded "bucket/key" CopyObject expects.
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
+
+// fixDispositionExtension rewrites the filename in a Content-Disposition
+// value to end in ext when it has a different extension.
+func fixDispositionExtension(cd, ext string) string {
+	name, _ := contentDispositionName(cd)
+	if name == "" || ext == "" {
+		return cd
+	}
+	old := StrictExtensionFromFilename(name)
+	if old == "" || strings.EqualFold(old, ext) {
+		return cd
+	}
+	fixed := strings.TrimSuffix(name, path.Ext(name)) + "." + ext
+	return BuildContentDisposition("attachment", fixed)
+}
@@ -0,0 +1,267 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type auditObject struct {
+	key, contentType, disposition string
+	body                          []byte
+}
+
+// auditBucket serves auditObjects two to a page, honoring Range on
+// GetObject, and records CopyObject calls.
+type auditBucket struct {
+	objects []auditObject
+	failAt  int // list call that fails, 1-based; 0 never
+
+	mu       sync.Mutex
+	lists    int
+	ranges   []string
+	copies   []*s3.CopyObjectInput
+	startArg []string
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (b *auditBucket) client() *mockS3Client {
+	return &mockS3Client{
+		listObjectsV2Fn: func(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			b.mu.Lock()
+			b.lists++
+			n := b.lists
+			b.startArg = append(b.startArg, aws.ToString(in.StartAfter))
+			b.mu.Unlock()
+			if n == b.failAt {
+				return nil, errors.New("slow down")
+			}
+			start := 0
+			if in.ContinuationToken != nil {
+				start = int(aws.ToString(in.ContinuationToken)[0] - '0')
+			}
+			for start < len(b.objects) && b.objects[start].key <= aws.ToString(in.StartAfter) {
+				start++
+			}
+			end := min(start+2, len(b.objects))
+			out := &s3.ListObjectsV2Output{}
+			for _, o := range b.objects[start:end] {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(o.key)})
+			}
+			if end < len(b.objects) {
+				out.IsTruncated = aws.Bool(true)
+				out.NextContinuationToken = aws.String(string(rune('0' + end)))
+			}
+			return out, nil
+		},
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if n := b.inFlight.Add(1); n > b.peak.Load() {
+				b.peak.Store(n)
+			}
+			time.Sleep(2 * time.Millisecond)
+			defer b.inFlight.Add(-1)
+
+			b.mu.Lock()
+			b.ranges = append(b.ranges, aws.ToString(in.Range))
+			b.mu.Unlock()
+			for _, o := range b.objects {
+				if o.key == aws.ToString(in.Key) {
+					body := o.body[:min(len(o.body), auditSniffBytes)]
+					return &s3.GetObjectOutput{
+						Body:               io.NopCloser(bytes.NewReader(body)),
+						ContentType:        nilIfEmpty(o.contentType),
+						ContentDisposition: nilIfEmpty(o.disposition),
+						CacheControl:       aws.String("max-age=60"),
+						Metadata:           map[string]string{"owner": "web"},
+					}, nil
+				}
+			}
+			return nil, errors.New("NoSuchKey")
+		},
+		copyObjectFn: func(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.copies = append(b.copies, in)
+			return &s3.CopyObjectOutput{}, nil
+		},
+	}
+}
+
+var (
+	auditPNG  = append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), bytes.Repeat([]byte{0}, 10<<10)...)
+	auditJPEG = append([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}, bytes.Repeat([]byte{1}, 64)...)
+	auditPDF  = []byte("%PDF-1.7\n1 0 obj\n<<>>\nendobj\n")
+)
+
+func newAuditBucket() *auditBucket {
+	return &auditBucket{objects: []auditObject{
+		{key: "assets/data.csv", contentType: "text/csv", body: []byte("id,name\n1,widget\n2,gadget\n")},
+		{key: "assets/logo.png", contentType: "application/octet-stream", body: auditPNG},
+		{key: "assets/photo 1.txt", contentType: "text/plain", disposition: `attachment; filename="photo 1.txt"`, body: auditJPEG},
+		{key: "assets/report.pdf", contentType: "application/pdf", body: auditPDF},
+	}}
+}
+
+func TestAuditS3Prefix_AuditOnly(t *testing.T) {
+	b := newAuditBucket()
+	defer setMockS3(b.client(), &mockPresignClient{})()
+
+	report, err := AuditS3Prefix(context.Background(), "bucket", "assets/", false, WithAuditConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Entries) != 4 || report.Mismatched != 2 || report.Fixed != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(b.copies) != 0 {
+		t.Errorf("audit-only run copied %d objects", len(b.copies))
+	}
+	for _, r := range b.ranges {
+		if r != "bytes=0-3071" {
+			t.Errorf("Range = %q", r)
+		}
+	}
+
+	byKey := map[string]AuditEntry{}
+	for _, e := range report.Entries {
+		byKey[e.Key] = e
+	}
+	if e := byKey["assets/logo.png"]; !e.TypeMismatch || e.DetectedType != "image/png" || e.ExtensionMismatch || e.BytesScanned != auditSniffBytes {
+		t.Errorf("logo.png = %+v", e)
+	}
+	if e := byKey["assets/photo 1.txt"]; !e.TypeMismatch || e.DetectedType != "image/jpeg" || !e.ExtensionMismatch {
+		t.Errorf("photo.txt = %+v", e)
+	}
+	if e := byKey["assets/data.csv"]; e.TypeMismatch {
+		t.Errorf("data.csv flagged: %+v", e)
+	}
+	if e := byKey["assets/report.pdf"]; e.TypeMismatch || e.StoredType != "application/pdf" {
+		t.Errorf("report.pdf = %+v", e)
+	}
+	want := int64(len("id,name\n1,widget\n2,gadget\n") + auditSniffBytes + len(auditJPEG) + len(auditPDF))
+	if report.BytesScanned != want {
+		t.Errorf("BytesScanned = %d, want %d", report.BytesScanned, want)
+	}
+	if report.LastKey != "assets/report.pdf" {
+		t.Errorf("LastKey = %q", report.LastKey)
+	}
+	if p := b.peak.Load(); p > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", p)
+	}
+}
+
+func TestAuditS3Prefix_Fix(t *testing.T) {
+	b := newAuditBucket()
+	defer setMockS3(b.client(), &mockPresignClient{})()
+
+	report, err := AuditS3Prefix(context.Background(), "bucket", "assets/", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Fixed != 2 || len(b.copies) != 2 {
+		t.Fatalf("fixed = %d, copies = %d", report.Fixed, len(b.copies))
+	}
+	copies := map[string]*s3.CopyObjectInput{}
+	for _, c := range b.copies {
+		copies[aws.ToString(c.Key)] = c
+		if c.MetadataDirective != types.MetadataDirectiveReplace {
+			t.Errorf("%s MetadataDirective = %q", aws.ToString(c.Key), c.MetadataDirective)
+		}
+		if aws.ToString(c.CacheControl) != "max-age=60" || c.Metadata["owner"] != "web" {
+			t.Errorf("%s lost metadata: %+v", aws.ToString(c.Key), c)
+		}
+	}
+	photo := copies["assets/photo 1.txt"]
+	if photo == nil {
+		t.Fatal("photo 1.txt not fixed")
+	}
+	if got := aws.ToString(photo.CopySource); got != "bucket/assets/photo%201.txt" {
+		t.Errorf("CopySource = %q", got)
+	}
+	if aws.ToString(photo.ContentType) != "image/jpeg" || aws.ToString(photo.ContentDisposition) != `attachment; filename="photo 1.jpg"` {
+		t.Errorf("photo: type = %q, disposition = %q", aws.ToString(photo.ContentType), aws.ToString(photo.ContentDisposition))
+	}
+	if logo := copies["assets/logo.png"]; logo == nil || aws.ToString(logo.ContentType) != "image/png" || logo.ContentDisposition != nil {
+		t.Errorf("logo copy = %+v", logo)
+	}
+}
+
+func TestAuditS3Prefix_Resume(t *testing.T) {
+	b := newAuditBucket()
+	b.failAt = 2
+	defer setMockS3(b.client(), &mockPresignClient{})()
+
+	report, err := AuditS3Prefix(context.Background(), "bucket", "assets/", false)
+	if !errors.Is(err, ErrS3) {
+		t.Fatalf("err = %v, want ErrS3", err)
+	}
+	if len(report.Entries) != 2 || report.LastKey != "assets/logo.png" {
+		t.Fatalf("partial report: %d entries, LastKey %q", len(report.Entries), report.LastKey)
+	}
+
+	rest, err := AuditS3Prefix(context.Background(), "bucket", "assets/", false, WithAuditStartAfter(report.LastKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest.Entries) != 2 || rest.Entries[0].Key != "assets/photo 1.txt" {
+		t.Errorf("resumed entries = %+v", rest.Entries)
+	}
+	if got := b.startArg[len(b.startArg)-1]; got != "assets/logo.png" {
+		t.Errorf("StartAfter = %q", got)
+	}
+}
+
+func TestAuditS3Prefix_PerObjectErrors(t *testing.T) {
+	b := newAuditBucket()
+	client := b.client()
+	get := client.getObjectFn
+	client.getObjectFn = func(ctx context.Context, in *s3.GetObjectInput, o ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		if strings.HasSuffix(aws.ToString(in.Key), ".pdf") {
+			return nil, errors.New("AccessDenied")
+		}
+		return get(ctx, in, o...)
+	}
+	defer setMockS3(client, &mockPresignClient{})()
+
+	report, err := AuditS3Prefix(context.Background(), "bucket", "assets/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := report.Entries[len(report.Entries)-1]
+	if last.Key != "assets/report.pdf" || !errors.Is(last.Err, ErrS3) {
+		t.Errorf("last entry = %+v", last)
+	}
+	if _, err := AuditS3Prefix(context.Background(), "bucket", "", false, WithAuditConcurrency(0)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestTypeDrifted(t *testing.T) {
+	tests := []struct {
+		stored, detected string
+		want             bool
+	}{
+		{"", "image/png", true},
+		{"binary/octet-stream", "image/png", true},
+		{"application/octet-stream", "application/octet-stream", false},
+		{"text/csv", "text/plain; charset=utf-8", false},
+		{"text/plain", "image/jpeg", true},
+		{"IMAGE/PNG", "image/png", false},
+	}
+	for _, tt := range tests {
+		if got := typeDrifted(tt.stored, tt.detected); got != tt.want {
+			t.Errorf("typeDrifted(%q, %q) = %v, want %v", tt.stored, tt.detected, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Config points the S3 clients built by ConfigureS3 at an S3-compatible
+// store such as MinIO or LocalStack. Zero fields keep what the AWS config
+// loaded from the environment says.
+type S3Config struct {
+	// Endpoint is the base URL of the store (e.g. "http://localhost:9000"),
+	// replacing the AWS endpoint for the region.
+	Endpoint string
+	// Region is the signing region. Most S3-compatible stores accept any
+	// region, commonly "us-east-1".
+	Region string
+	// UsePathStyle addresses buckets as http://endpoint/bucket/key rather
+	// than http://bucket.endpoint/key, as most self-hosted stores require.
+	UsePathStyle bool
+	// Credentials supplies the access keys, for example
+	// credentials.NewStaticCredentialsProvider.
+	Credentials aws.CredentialsProvider
+}
+
+// validate reports an Endpoint that is not an absolute http or https URL.
+func (c S3Config) validate() error {
+	var e optionErrors
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			e.reject("S3Config.Endpoint", "%q is not an http or https URL", c.Endpoint)
+		}
+	}
+	return errors.Join(e.errs...)
+}
+
+// ConfigureS3 installs S3 clients built from the AWS config in the
+// environment with cfg applied, shared by every later S3 operation. It
+// replaces any factory set with SetS3ClientFactory; SetS3ClientFactory(nil)
+// restores the default. An invalid Endpoint fails with ErrInvalidOption
+// and an AWS config that cannot be loaded with ErrS3.
+func ConfigureS3(cfg S3Config) error {
+	if err := checkOptions("ConfigureS3", cfg.validate()); err != nil {
+		return err
+	}
+	client, presignClient, err := newS3Clients(cfg)
+	if err != nil {
+		return newError(ErrS3, "ConfigureS3", err)
+	}
+	plainHTTP := strings.HasPrefix(strings.ToLower(cfg.Endpoint), "http:")
+	setS3ClientFactory(func() (S3API, S3PresignAPI) { return client, presignClient }, plainHTTP)
+	return nil
+}
+
+// newS3Clients loads the AWS config from the environment and builds
+// clients from it with cfg applied.
+func newS3Clients(cfg S3Config) (*s3.Client, *s3.PresignClient, error) {
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(cfg.Region))
+	}
+	if cfg.Credentials != nil {
+		loadOpts = append(loadOpts, awsconfig.WithCredentialsProvider(cfg.Credentials))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+	return client, s3.NewPresignClient(client), nil
+}
+
+// unavailableS3 stands in for the S3 clients when the AWS config cannot be
+// loaded: every call fails with err, which operations report as ErrS3.
+type unavailableS3 struct{ err error }
+
+func (u unavailableS3) GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) UploadPart(context.Context, *s3.UploadPartInput, ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) UploadPartCopy(context.Context, *s3.UploadPartCopyInput, ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) CompleteMultipartUpload(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) ListParts(context.Context, *s3.ListPartsInput, ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) ListObjectsV2(context.Context, *s3.ListObjectsV2Input, ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) ListObjectVersions(context.Context, *s3.ListObjectVersionsInput, ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) DeleteObjects(context.Context, *s3.DeleteObjectsInput, ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) CopyObject(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) GetObjectTagging(context.Context, *s3.GetObjectTaggingInput, ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) PutObjectTagging(context.Context, *s3.PutObjectTaggingInput, ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) PresignGetObject(context.Context, *s3.GetObjectInput, ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return nil, u.err
+}
+
+func (u unavailableS3) PresignPutObject(context.Context, *s3.PutObjectInput, ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return nil, u.err
+}
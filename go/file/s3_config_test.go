@@ -0,0 +1,101 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestConfigureS3_Endpoint(t *testing.T) {
+	var puts, gets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=minio-key/") {
+			t.Errorf("%s %s signed as %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		switch r.Method {
+		case http.MethodPut:
+			puts = append(puts, r.URL.Path)
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.Header().Set("ETag", `"abc"`)
+		case http.MethodGet:
+			gets = append(gets, r.URL.Path)
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = io.WriteString(w, "hello minio")
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	orig := CurrentS3ClientFactory()
+	defer SetS3ClientFactory(orig)
+	err := ConfigureS3(S3Config{
+		Endpoint:     srv.URL,
+		Region:       "us-east-1",
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("minio-key", "minio-secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := NewFromBytes([]byte("hello minio"), MetadataHint{Name: "hello.txt"})
+	if err := f.UploadToS3("bucket", "dir/hello.txt"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	got, err := NewFromS3("bucket", "dir/hello.txt")
+	if err != nil {
+		t.Fatalf("NewFromS3: %v", err)
+	}
+	if data, _ := got.Read(); string(data) != "hello minio" {
+		t.Errorf("content = %q", data)
+	}
+	if len(puts) != 1 || puts[0] != "/bucket/dir/hello.txt" || len(gets) != 1 || gets[0] != "/bucket/dir/hello.txt" {
+		t.Errorf("requests: PUT %v, GET %v; want path-style /bucket/dir/hello.txt", puts, gets)
+	}
+
+	// The SDK refuses unseekable bodies without TLS, so a size-hinted lazy
+	// stream must not be streamed to this endpoint.
+	body := strings.Repeat("s", 3*streamHeadBytes)
+	lazy, _ := NewFromStreamLazy(strings.NewReader(body), MetadataHint{Size: int64(len(body))})
+	if err := lazy.UploadToS3("bucket", "dir/lazy.txt"); err != nil {
+		t.Fatalf("UploadToS3 of a lazy stream: %v", err)
+	}
+}
+
+func TestConfigureS3_InvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"localhost:9000", "ftp://host", "http://"} {
+		if err := ConfigureS3(S3Config{Endpoint: endpoint}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Endpoint %q: err = %v, want ErrInvalidOption", endpoint, err)
+		}
+	}
+}
+
+func TestDefaultS3ClientFactory_ConfigError(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(config, []byte("[default]\nregion = us-east-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", config)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", config)
+	t.Setenv("AWS_PROFILE", "no-such-profile")
+
+	orig := CurrentS3ClientFactory()
+	defer SetS3ClientFactory(orig)
+	SetS3ClientFactory(nil)
+
+	_, err := NewFromS3("bucket", "k")
+	if !errors.Is(err, ErrS3) || !strings.Contains(err.Error(), "unable to load AWS config") {
+		t.Errorf("err = %v, want ErrS3 from the config failure", err)
+	}
+	if err := ConfigureS3(S3Config{}); !errors.Is(err, ErrS3) {
+		t.Errorf("ConfigureS3: err = %v, want ErrS3", err)
+	}
+}
@@ -0,0 +1,303 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxCopyObjectSize is the largest object a single CopyObject can copy;
+// CopyToS3 copies anything larger in parts.
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// copyPartSize is the part size of CopyToS3's multipart copies. Parts are
+// copied server-side, so they can be far larger than upload parts.
+const copyPartSize = 512 * 1024 * 1024
+
+// CopyOption configures CopyToS3.
+type CopyOption func(*copyOptions)
+
+func (CopyOption) isOption() {}
+
+// copyOptions is the resolved set of CopyOption values.
+type copyOptions struct {
+	optionErrors
+	directive   types.MetadataDirective
+	contentType string
+}
+
+// newCopyOptions applies opts over the defaults.
+func newCopyOptions(opts []CopyOption) copyOptions {
+	var o copyOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values and conflicting options.
+func (o copyOptions) validate() error {
+	errs := o.errs
+	if o.contentType != "" && o.directive == types.MetadataDirectiveCopy {
+		errs = append(errs, errors.New("WithCopyContentType conflicts with MetadataDirective COPY"))
+	}
+	return errors.Join(errs...)
+}
+
+// replace reports whether the copy writes new headers and user metadata.
+func (o copyOptions) replace() bool {
+	return o.directive == types.MetadataDirectiveReplace || o.contentType != ""
+}
+
+// WithMetadataDirective sets whether CopyToS3 keeps the source object's
+// headers and user metadata (COPY, the default) or replaces them with the
+// File's (REPLACE). Unknown directives are rejected.
+func WithMetadataDirective(d types.MetadataDirective) CopyOption {
+	return func(o *copyOptions) {
+		if !slices.Contains(d.Values(), d) {
+			o.reject("WithMetadataDirective", "unknown directive %q", d)
+			return
+		}
+		o.directive = d
+	}
+}
+
+// WithCopyContentType sets the ContentType of the object CopyToS3 writes.
+// It implies MetadataDirective REPLACE and conflicts with an explicit COPY.
+func WithCopyContentType(mimeType string) CopyOption {
+	return func(o *copyOptions) {
+		o.contentType = mimeType
+	}
+}
+
+// CopyToS3 copies the file to destBucket/destKey and returns a File for
+// the copy, with metadata read back from S3; its content is fetched on
+// first read.
+//
+// When the file is S3-sourced the copy is done server-side with
+// CopyObject, so the content never passes through this process. The copy
+// is conditional on the source's ETag, so a concurrent overwrite fails it
+// instead of copying a mix. Objects over 5 GB, CopyObject's limit, are
+// copied in parts with UploadPartCopy; a failed multipart copy is
+// aborted. With the default MetadataDirective COPY, the destination keeps
+// the source object's headers and user metadata; with REPLACE, it gets the
+// File's Name, MIME type and Attributes, as UploadToS3 would write them,
+// while Cache-Control, Content-Encoding, storage class and server-side
+// encryption are carried over. WithCopyContentType overrides the
+// ContentType either way.
+//
+// Any other file is uploaded with UploadToS3WithContext, with
+// WithCopyContentType overriding its MIME type. The directive does not
+// apply, since there is no source object.
+//
+// UploadTimeout applies when ctx has no deadline.
+func (f *File) CopyToS3(ctx context.Context, destBucket, destKey string, opts ...CopyOption) (*File, error) {
+	o := newCopyOptions(opts)
+	if err := checkOptions("CopyToS3", o.validate()); err != nil {
+		return nil, err
+	}
+	destKey, err := checkS3Object("CopyToS3", destBucket, destKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNetwork(ctx, "CopyToS3"); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, UploadTimeout())
+	defer cancel()
+
+	if bucket, key, ok := f.s3Location(); ok {
+		if err := f.copyS3Object(ctx, dl, bucket, key, destBucket, destKey, o); err != nil {
+			return nil, err
+		}
+	} else {
+		var uploadOpts []UploadOption
+		if o.contentType != "" {
+			uploadOpts = append(uploadOpts, func(u *uploadOptions) { u.contentType = o.contentType })
+		}
+		if err := f.UploadToS3WithContext(ctx, destBucket, destKey, uploadOpts...); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := NewMetadataFromS3(ctx, destBucket, destKey)
+	if err != nil {
+		return nil, err
+	}
+	nf := withProvenance(&File{source: SourceS3, s3Bucket: destBucket, s3Key: destKey, meta: m})
+	nf.inheritProvenance(f)
+	return nf, nil
+}
+
+// copyS3Object copies bucket/key (at the File's version, when known) to
+// destBucket/destKey server-side.
+func (f *File) copyS3Object(ctx context.Context, dl opDeadline, bucket, key, destBucket, destKey string, o copyOptions) error {
+	s3Client, _ := s3Clients()
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: nilIfEmpty(f.meta.VersionID),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return newError(ErrNotFound, "CopyToS3", err)
+		}
+		return dl.newError(ctx, ErrS3, "CopyToS3", err)
+	}
+
+	var changes MetadataHint
+	if o.replace() {
+		changes = MetadataHint{Name: f.meta.Name, MimeType: f.meta.MimeType}
+		if o.contentType != "" {
+			changes.MimeType = o.contentType
+		}
+	}
+	input := metadataCopyInput(bucket, key, head, changes)
+	input.Bucket, input.Key = aws.String(destBucket), aws.String(destKey)
+	if f.meta.VersionID != "" {
+		input.CopySource = aws.String(copySource(bucket, key) + "?versionId=" + url.QueryEscape(f.meta.VersionID))
+	}
+	if o.replace() {
+		input.Metadata = encodeS3UserMetadata(f.meta.Attributes)
+		if err := checkS3UserMetadataSize("CopyToS3", input.Metadata); err != nil {
+			return err
+		}
+	}
+
+	if size := aws.ToInt64(head.ContentLength); size > maxCopyObjectSize {
+		// CopyObject carries tags over itself; a multipart copy has to be
+		// given them.
+		tags, err := getS3Tags(ctx, s3Client, bucket, key, f.meta.VersionID)
+		if err != nil {
+			return dl.newError(ctx, ErrS3, "CopyToS3", err)
+		}
+		return copyMultipart(ctx, dl, s3Client, input, size, tags)
+	}
+	if !o.replace() {
+		input.MetadataDirective = types.MetadataDirectiveCopy
+		input.ContentType, input.ContentDisposition = nil, nil
+		input.CacheControl, input.ContentEncoding, input.ContentLanguage = nil, nil, nil
+		input.WebsiteRedirectLocation, input.Metadata = nil, nil
+	}
+	if _, err := s3Client.CopyObject(ctx, input); err != nil {
+		return dl.newError(ctx, ErrS3, "CopyToS3", err)
+	}
+	return nil
+}
+
+// copyMultipart copies the size-byte object input.CopySource names to
+// input.Bucket/input.Key in parts, with input's headers and tags. Up to
+// DefaultPartConcurrency parts are copied at once; any failure aborts the
+// upload.
+func copyMultipart(ctx context.Context, dl opDeadline, s3Client S3API, input *s3.CopyObjectInput, size int64, tags map[string]string) error {
+	var tagging *string
+	if len(tags) > 0 {
+		tagging = aws.String(encodeTagging(tags))
+	}
+	created, err := s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:                  input.Bucket,
+		Key:                     input.Key,
+		ContentType:             input.ContentType,
+		ContentDisposition:      input.ContentDisposition,
+		CacheControl:            input.CacheControl,
+		ContentEncoding:         input.ContentEncoding,
+		ContentLanguage:         input.ContentLanguage,
+		WebsiteRedirectLocation: input.WebsiteRedirectLocation,
+		Metadata:                input.Metadata,
+		Tagging:                 tagging,
+		StorageClass:            input.StorageClass,
+		ServerSideEncryption:    input.ServerSideEncryption,
+		SSEKMSKeyId:             input.SSEKMSKeyId,
+		BucketKeyEnabled:        input.BucketKeyEnabled,
+	})
+	if err != nil {
+		return dl.newError(ctx, ErrS3, "CopyToS3", err)
+	}
+	uploadID := created.UploadId
+	abort := func(cause error) error {
+		// Use a fresh context: ctx may be the reason we are aborting.
+		_, _ = s3Client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   input.Bucket,
+			Key:      input.Key,
+			UploadId: uploadID,
+		})
+		return cause
+	}
+
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	partSize := max(copyPartSize, (size+maxParts-1)/maxParts)
+	count := int((size + partSize - 1) / partSize)
+	parts := make([]types.CompletedPart, count)
+	var (
+		sem      = make(chan struct{}, DefaultPartConcurrency)
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	for i := range count {
+		sem <- struct{}{}
+		if partCtx.Err() != nil {
+			<-sem
+			break
+		}
+		off := int64(i) * partSize
+		end := min(off+partSize, size) - 1
+		num := int32(i + 1)
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			out, err := s3Client.UploadPartCopy(partCtx, &s3.UploadPartCopyInput{
+				Bucket:            input.Bucket,
+				Key:               input.Key,
+				UploadId:          uploadID,
+				PartNumber:        aws.Int32(num),
+				CopySource:        input.CopySource,
+				CopySourceIfMatch: input.CopySourceIfMatch,
+				CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", off, end)),
+			})
+			if err != nil {
+				fail(dl.newError(ctx, ErrS3, "CopyToS3", err))
+				return
+			}
+			var etag *string
+			if out.CopyPartResult != nil {
+				etag = out.CopyPartResult.ETag
+			}
+			parts[num-1] = types.CompletedPart{ETag: etag, PartNumber: aws.Int32(num)}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return abort(firstErr)
+	}
+
+	_, err = s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          input.Bucket,
+		Key:             input.Key,
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abort(dl.newError(ctx, ErrS3, "CopyToS3", err))
+	}
+	return nil
+}
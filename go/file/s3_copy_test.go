@@ -0,0 +1,239 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// copyS3 is a mock S3 holding one source object, "src/report.pdf" in
+// "bucket", that records the copies made of it.
+type copyS3 struct {
+	mu        sync.Mutex
+	size      int64
+	copied    *s3.CopyObjectInput
+	created   *s3.CreateMultipartUploadInput
+	ranges    map[int32]string
+	complete  *s3.CompleteMultipartUploadInput
+	aborted   bool
+	failPart  int32
+	putObject *s3.PutObjectInput
+}
+
+func newCopyS3(t *testing.T, size int64, failPart int32) *copyS3 {
+	t.Helper()
+	c := &copyS3{size: size, ranges: map[int32]string{}, failPart: failPart}
+	t.Cleanup(setMockS3(&mockS3Client{
+		headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			if aws.ToString(in.Bucket) == "bucket" {
+				return &s3.HeadObjectOutput{
+					ContentType:        aws.String("application/pdf"),
+					ContentDisposition: aws.String(`attachment; filename="report.pdf"`),
+					ContentLength:      aws.Int64(c.size),
+					CacheControl:       aws.String("max-age=60"),
+					ETag:               aws.String(`"src-etag"`),
+					Metadata:           map[string]string{"owner": "finance"},
+				}, nil
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.copied == nil && c.complete == nil && c.putObject == nil {
+				return nil, &types.NotFound{}
+			}
+			return &s3.HeadObjectOutput{
+				ContentType:   aws.String("application/pdf"),
+				ContentLength: aws.Int64(c.size),
+				ETag:          aws.String(`"dest-etag"`),
+			}, nil
+		},
+		copyObjectFn: func(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.copied = in
+			return &s3.CopyObjectOutput{}, nil
+		},
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.putObject = in
+			return &s3.PutObjectOutput{}, nil
+		},
+		getObjectTaggingFn: func(ctx context.Context, in *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+			return &s3.GetObjectTaggingOutput{TagSet: []types.Tag{{Key: aws.String("team"), Value: aws.String("ops")}}}, nil
+		},
+		createMultipartUploadFn: func(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			c.created = in
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("copy-1")}, nil
+		},
+		uploadPartCopyFn: func(ctx context.Context, in *s3.UploadPartCopyInput, _ ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+			num := aws.ToInt32(in.PartNumber)
+			if num == c.failPart {
+				return nil, errors.New("slow down")
+			}
+			if aws.ToString(in.CopySource) != "bucket/src/report.pdf" || aws.ToString(in.CopySourceIfMatch) != `"src-etag"` {
+				return nil, fmt.Errorf("part %d copies %s if-match %s", num, aws.ToString(in.CopySource), aws.ToString(in.CopySourceIfMatch))
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.ranges[num] = aws.ToString(in.CopySourceRange)
+			return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{ETag: aws.String(fmt.Sprintf(`"etag-%d"`, num))}}, nil
+		},
+		completeMultipartUploadFn: func(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			c.complete = in
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+		abortMultipartUploadFn: func(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			c.aborted = true
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+	}, &mockPresignClient{}))
+	return c
+}
+
+func copySourceFile() *File {
+	return &File{source: SourceS3, s3Bucket: "bucket", s3Key: "src/report.pdf", meta: Metadata{
+		Name:       "renamed.pdf",
+		MimeType:   "application/pdf",
+		Attributes: map[string]string{"stage": "final"},
+	}}
+}
+
+func TestCopyToS3_ServerSide(t *testing.T) {
+	c := newCopyS3(t, 1024, 0)
+	src := withProvenance(copySourceFile())
+
+	dst, err := src.CopyToS3(context.Background(), "archive", "2024/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := c.copied
+	if in == nil {
+		t.Fatal("CopyObject not called")
+	}
+	if aws.ToString(in.Bucket) != "archive" || aws.ToString(in.Key) != "2024/report.pdf" ||
+		aws.ToString(in.CopySource) != "bucket/src/report.pdf" || aws.ToString(in.CopySourceIfMatch) != `"src-etag"` {
+		t.Errorf("CopyObject(%s/%s from %s if-match %s)", aws.ToString(in.Bucket), aws.ToString(in.Key),
+			aws.ToString(in.CopySource), aws.ToString(in.CopySourceIfMatch))
+	}
+	if in.MetadataDirective != types.MetadataDirectiveCopy || in.ContentType != nil || in.Metadata != nil {
+		t.Errorf("COPY directive sent headers: %+v", in)
+	}
+	if c.putObject != nil {
+		t.Error("S3-sourced copy uploaded the content")
+	}
+
+	bucket, key, _ := dst.s3Location()
+	if bucket != "archive" || key != "2024/report.pdf" || dst.Hash() != "dest-etag" || dst.Size() != 1024 {
+		t.Errorf("copy = s3://%s/%s hash %s size %d", bucket, key, dst.Hash(), dst.Size())
+	}
+	if p := dst.Provenance(); len(p) != 2 || p[0].Key != "src/report.pdf" || p[1].Bucket != "archive" {
+		t.Errorf("Provenance = %+v", p)
+	}
+}
+
+func TestCopyToS3_Replace(t *testing.T) {
+	c := newCopyS3(t, 1024, 0)
+	_, err := copySourceFile().CopyToS3(context.Background(), "archive", "report.pdf",
+		WithMetadataDirective(types.MetadataDirectiveReplace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := c.copied
+	if in.MetadataDirective != types.MetadataDirectiveReplace || aws.ToString(in.ContentType) != "application/pdf" ||
+		aws.ToString(in.ContentDisposition) != `attachment; filename="renamed.pdf"` ||
+		aws.ToString(in.CacheControl) != "max-age=60" || in.Metadata["stage"] != "final" || in.Metadata["owner"] != "" {
+		t.Errorf("REPLACE input = %+v", in)
+	}
+}
+
+func TestCopyToS3_ContentType(t *testing.T) {
+	c := newCopyS3(t, 1024, 0)
+	if _, err := copySourceFile().CopyToS3(context.Background(), "archive", "report.bin", WithCopyContentType("application/octet-stream")); err != nil {
+		t.Fatal(err)
+	}
+	if c.copied.MetadataDirective != types.MetadataDirectiveReplace || aws.ToString(c.copied.ContentType) != "application/octet-stream" {
+		t.Errorf("CopyObject directive %s, ContentType %s", c.copied.MetadataDirective, aws.ToString(c.copied.ContentType))
+	}
+}
+
+func TestCopyToS3_Multipart(t *testing.T) {
+	size := int64(maxCopyObjectSize + copyPartSize/2)
+	c := newCopyS3(t, size, 0)
+	if _, err := copySourceFile().CopyToS3(context.Background(), "archive", "big.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if c.copied != nil {
+		t.Error("CopyObject called for an object over 5 GB")
+	}
+	want := int((size + copyPartSize - 1) / copyPartSize)
+	if c.complete == nil || len(c.complete.MultipartUpload.Parts) != want {
+		t.Fatalf("completed %v, want %d parts", c.complete, want)
+	}
+	for i, p := range c.complete.MultipartUpload.Parts {
+		num := int32(i + 1)
+		if aws.ToInt32(p.PartNumber) != num || aws.ToString(p.ETag) != fmt.Sprintf(`"etag-%d"`, num) {
+			t.Errorf("part %d listed as %d with ETag %s", num, aws.ToInt32(p.PartNumber), aws.ToString(p.ETag))
+		}
+	}
+	if got := c.ranges[int32(want)]; got != fmt.Sprintf("bytes=%d-%d", int64(want-1)*copyPartSize, size-1) {
+		t.Errorf("last part range = %s", got)
+	}
+	if aws.ToString(c.created.ContentType) != "application/pdf" || c.created.Metadata["owner"] != "finance" ||
+		aws.ToString(c.created.Tagging) != "team=ops" {
+		t.Errorf("CreateMultipartUpload lost the source's headers: %+v", c.created)
+	}
+}
+
+func TestCopyToS3_MultipartFailureAborts(t *testing.T) {
+	c := newCopyS3(t, maxCopyObjectSize+1, 2)
+	_, err := copySourceFile().CopyToS3(context.Background(), "archive", "big.pdf")
+	if !errors.Is(err, ErrS3) {
+		t.Fatalf("err = %v, want ErrS3", err)
+	}
+	if !c.aborted || c.complete != nil {
+		t.Errorf("aborted = %v, completed = %v", c.aborted, c.complete != nil)
+	}
+}
+
+func TestCopyToS3_UploadsOtherSources(t *testing.T) {
+	c := newCopyS3(t, 5, 0)
+	f, _ := NewFromBytes([]byte("hello"), MetadataHint{Name: "hello.txt"})
+	dst, err := f.CopyToS3(context.Background(), "archive", "hello.txt", WithCopyContentType("text/markdown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.copied != nil || c.putObject == nil {
+		t.Fatalf("copied = %v, uploaded = %v", c.copied != nil, c.putObject != nil)
+	}
+	if aws.ToString(c.putObject.ContentType) != "text/markdown" {
+		t.Errorf("ContentType = %s", aws.ToString(c.putObject.ContentType))
+	}
+	if dst.Source() != SourceS3 {
+		t.Errorf("Source = %v", dst.Source())
+	}
+}
+
+func TestCopyToS3_Errors(t *testing.T) {
+	newCopyS3(t, 1024, 0)
+	for name, tc := range map[string]struct {
+		f    *File
+		opts []CopyOption
+		want error
+	}{
+		"unknown directive": {copySourceFile(), []CopyOption{WithMetadataDirective("MOVE")}, ErrInvalidOption},
+		"content type with COPY": {copySourceFile(), []CopyOption{
+			WithMetadataDirective(types.MetadataDirectiveCopy), WithCopyContentType("text/plain"),
+		}, ErrInvalidOption},
+		"missing source": {&File{source: SourceS3, s3Bucket: "other", s3Key: "gone"}, nil, ErrNotFound},
+	} {
+		if _, err := tc.f.CopyToS3(context.Background(), "archive", "k", tc.opts...); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package file
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3MetaSHA256 is the S3 user metadata key WithSkipUnchanged records the
+// hex SHA-256 of the uploaded content under. With WithTransparentGzip it
+// is the digest of the uncompressed content.
+const S3MetaSHA256 = ReservedAttrPrefix + "sha256"
+
+// WithSkipUnchanged makes UploadToS3 skip the upload when the destination
+// object already holds the same content. The object is read with
+// HeadObject (checksum mode enabled) and compared with the SHA-256 of the
+// File, taken from the source's published digest when there is one and
+// computed otherwise. The object's S3MetaSHA256 metadata is compared
+// first, then its full-object ChecksumSHA256; composite checksums of
+// multipart uploads and ETags are never compared, since neither is a
+// digest of the whole content. An object with neither is compared by size
+// alone. When the upload does go ahead, the digest is stored under
+// S3MetaSHA256 for the next comparison.
+func WithSkipUnchanged() UploadOption {
+	return func(o *uploadOptions) { o.skipUnchanged = true }
+}
+
+// matchS3Object returns the SHA-256 of f's content and whether the object
+// at bucket/key already holds it, as WithSkipUnchanged describes. A
+// missing object does not match.
+func (f *File) matchS3Object(ctx context.Context, dl opDeadline, watch *watchdog, client S3API, bucket, key string) (string, bool, error) {
+	digest := f.sourceDigests[AlgorithmSHA256]
+	if digest == "" {
+		var err error
+		if digest, err = f.Checksum(); err != nil {
+			return "", false, err
+		}
+	}
+	watch.kick()
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	watch.pause()
+	if err != nil {
+		if isS3NotFound(err) {
+			return digest, false, nil
+		}
+		return "", false, dl.newError(ctx, ErrS3, "UploadToS3", err)
+	}
+	return digest, headMatches(head, digest, f.meta.Size), nil
+}
+
+// headMatches compares an existing object with content of the given
+// SHA-256 digest and size.
+func headMatches(head *s3.HeadObjectOutput, digest string, size int64) bool {
+	if stored := head.Metadata[S3MetaSHA256]; stored != "" {
+		return strings.EqualFold(stored, digest)
+	}
+	enc := strings.ToLower(aws.ToString(head.ContentEncoding))
+	encoded := enc != "" && enc != "identity"
+	if !encoded && head.ChecksumType != types.ChecksumTypeComposite {
+		// A composite checksum ("<base64>-<parts>") does not decode.
+		if remote := base64DigestToHex(aws.ToString(head.ChecksumSHA256)); remote != "" {
+			return remote == digest
+		}
+	}
+	if v, ok := head.Metadata[S3MetaUncompressedSize]; ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		return err == nil && n == size
+	}
+	return !encoded && head.ContentLength != nil && *head.ContentLength == size
+}
+
+// uploadResult completes res for an upload that started at start.
+func (f *File) uploadResult(start time.Time, res TransferResult) TransferResult {
+	res.Bytes = f.transferSize
+	res.Duration = time.Since(start)
+	if secs := res.Duration.Seconds(); secs > 0 {
+		res.AvgThroughput = int64(float64(res.Bytes) / secs)
+	}
+	res.MinThroughput = res.AvgThroughput
+	return res
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// dedupS3 answers HeadObject with head (or NotFound when nil) and records
+// PutObject inputs.
+func dedupS3(t *testing.T, head *s3.HeadObjectOutput) *[]*s3.PutObjectInput {
+	t.Helper()
+	var puts []*s3.PutObjectInput
+	t.Cleanup(setMockS3(&mockS3Client{
+		headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			if in.ChecksumMode != types.ChecksumModeEnabled {
+				t.Error("HeadObject without checksum mode")
+			}
+			if head == nil {
+				return nil, &types.NotFound{}
+			}
+			return head, nil
+		},
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			puts = append(puts, in)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{}))
+	return &puts
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	content := []byte("report,total\nq1,10\n")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	size := int64(len(content))
+
+	for _, tc := range []struct {
+		name string
+		head *s3.HeadObjectOutput
+		skip bool
+	}{
+		{"stored digest matches", &s3.HeadObjectOutput{Metadata: map[string]string{S3MetaSHA256: digest}, ContentLength: aws.Int64(size)}, true},
+		{"stored digest differs", &s3.HeadObjectOutput{Metadata: map[string]string{S3MetaSHA256: "00" + digest[2:]}, ContentLength: aws.Int64(size)}, false},
+		{"checksum matches", &s3.HeadObjectOutput{ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])), ContentLength: aws.Int64(size)}, true},
+		{"checksum differs", &s3.HeadObjectOutput{ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(make([]byte, 32))), ContentLength: aws.Int64(size)}, false},
+		{"composite checksum falls back to size", &s3.HeadObjectOutput{
+			ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:]) + "-2"),
+			ChecksumType:   types.ChecksumTypeComposite,
+			ContentLength:  aws.Int64(size + 1),
+			ETag:           aws.String(`"` + digest[:32] + `-2"`),
+		}, false},
+		{"no checksum, same size", &s3.HeadObjectOutput{ContentLength: aws.Int64(size), ETag: aws.String(`"abc"`)}, true},
+		{"no checksum, other size", &s3.HeadObjectOutput{ContentLength: aws.Int64(size + 5)}, false},
+		{"missing object", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			puts := dedupS3(t, tc.head)
+			f, _ := NewFromBytes(content, MetadataHint{Name: "report.csv"})
+			res, err := f.UploadToS3WithResult(context.Background(), "bucket", "report.csv", WithSkipUnchanged())
+			if err != nil {
+				t.Fatalf("UploadToS3WithResult: %v", err)
+			}
+			if res.Skipped != tc.skip {
+				t.Errorf("Skipped = %v, want %v", res.Skipped, tc.skip)
+			}
+			if res.Digest != digest || res.Algorithm != AlgorithmSHA256 {
+				t.Errorf("result digest = %s %s", res.Algorithm, res.Digest)
+			}
+			if tc.skip {
+				if len(*puts) != 0 || res.Bytes != 0 || f.TransferSize() != 0 {
+					t.Errorf("skipped upload sent %d puts, %d bytes", len(*puts), res.Bytes)
+				}
+				return
+			}
+			if len(*puts) != 1 {
+				t.Fatalf("PutObject called %d times, want 1", len(*puts))
+			}
+			if got := (*puts)[0].Metadata[S3MetaSHA256]; got != digest {
+				t.Errorf("stored digest = %q, want %q", got, digest)
+			}
+			if res.Bytes != size {
+				t.Errorf("Bytes = %d, want %d", res.Bytes, size)
+			}
+		})
+	}
+}
+
+func TestSkipUnchanged_UsesSourceDigest(t *testing.T) {
+	puts := dedupS3(t, &s3.HeadObjectOutput{Metadata: map[string]string{S3MetaSHA256: "published"}})
+	f, _ := NewFromBytes([]byte("x"))
+	f.sourceDigests = map[Algorithm]string{AlgorithmSHA256: "published"}
+	res, err := f.UploadToS3WithResult(context.Background(), "bucket", "k", WithSkipUnchanged())
+	if err != nil || !res.Skipped || len(*puts) != 0 {
+		t.Fatalf("res = %+v, err = %v, puts = %d; want a skip on the published digest", res, err, len(*puts))
+	}
+}
+
+func TestSkipUnchanged_HeadError(t *testing.T) {
+	defer setMockS3(&mockS3Client{
+		headObjectFn: func(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return nil, errors.New("access denied")
+		},
+	}, &mockPresignClient{})()
+	f, _ := NewFromBytes([]byte("x"))
+	if err := f.UploadToS3("bucket", "k", WithSkipUnchanged()); !errors.Is(err, ErrS3) {
+		t.Fatalf("err = %v, want ErrS3", err)
+	}
+}
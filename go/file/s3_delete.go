@@ -0,0 +1,206 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// deleteBatchSize is the most keys one DeleteObjects request accepts.
+const deleteBatchSize = 1000
+
+// DefaultDeleteMaxKeys is the cap DeleteS3Prefix applies when WithMaxKeys
+// is not given.
+const DefaultDeleteMaxKeys = 10000
+
+// DeleteOption configures DeleteS3Prefix.
+type DeleteOption func(*deleteOptions)
+
+func (DeleteOption) isOption() {}
+
+// deleteOptions is the resolved set of DeleteOption values.
+type deleteOptions struct {
+	optionErrors
+	dryRun           bool
+	maxKeys          int
+	allowEmptyPrefix bool
+}
+
+// newDeleteOptions applies opts over the defaults.
+func newDeleteOptions(opts []DeleteOption) deleteOptions {
+	o := deleteOptions{maxKeys: DefaultDeleteMaxKeys}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values.
+func (o deleteOptions) validate() error {
+	return errors.Join(o.errs...)
+}
+
+// WithDryRun makes DeleteS3Prefix list the prefix and return how many
+// objects it would delete, without deleting any.
+func WithDryRun() DeleteOption {
+	return func(o *deleteOptions) { o.dryRun = true }
+}
+
+// WithMaxKeys caps how many objects DeleteS3Prefix may delete. The whole
+// prefix is listed first, and when it holds more than n objects nothing is
+// deleted and ErrTooManyObjects is returned, so a mistyped prefix cannot
+// empty a bucket. The default is DefaultDeleteMaxKeys; zero removes the
+// cap and a negative n is rejected.
+func WithMaxKeys(n int) DeleteOption {
+	return func(o *deleteOptions) {
+		if n < 0 {
+			o.reject("WithMaxKeys", "negative cap %d", n)
+			return
+		}
+		o.maxKeys = n
+	}
+}
+
+// WithAllowEmptyPrefix lets DeleteS3Prefix run with an empty prefix,
+// deleting every object in the bucket (still subject to WithMaxKeys).
+func WithAllowEmptyPrefix() DeleteOption {
+	return func(o *deleteOptions) { o.allowEmptyPrefix = true }
+}
+
+// DeleteS3Prefix deletes every object in bucket whose key starts with
+// prefix and returns how many were deleted. Keys are listed page by page
+// and deleted in DeleteObjects batches of up to 1000, one batch at a time.
+// MetadataTimeout applies to each request when ctx has no deadline.
+//
+// Objects S3 declines to delete do not stop the run: the remaining batches
+// are still sent and the failures are returned together as a *BatchError,
+// alongside the count that did succeed. Each failed item is an ErrS3
+// FileError carrying S3's error code, so CodeOf reports CodeAccessDenied
+// for refused keys. A failed list or DeleteObjects request stops the run.
+//
+// An empty prefix fails with ErrInvalidArgument unless
+// WithAllowEmptyPrefix is given, and by default more than
+// DefaultDeleteMaxKeys matching objects fail with ErrTooManyObjects
+// before anything is deleted. See WithDryRun and WithMaxKeys.
+func DeleteS3Prefix(ctx context.Context, bucket, prefix string, opts ...DeleteOption) (int, error) {
+	o := newDeleteOptions(opts)
+	if err := checkOptions("DeleteS3Prefix", o.validate()); err != nil {
+		return 0, err
+	}
+	prefix, err := checkS3Prefix("DeleteS3Prefix", bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if prefix == "" && !o.allowEmptyPrefix {
+		return 0, newError(ErrInvalidArgument, "DeleteS3Prefix",
+			fmt.Errorf("empty prefix would delete all of bucket %q; pass WithAllowEmptyPrefix to mean it", bucket))
+	}
+
+	s3Client, _ := s3Clients()
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: nilIfEmpty(prefix),
+	}
+
+	// With a cap or a dry run, list everything before deleting anything;
+	// otherwise delete each page as it arrives.
+	listFirst := o.dryRun || o.maxKeys > 0
+	var (
+		pending []string
+		listed  int // keys listed before pending
+		deleted int
+		failed  []*ItemError
+	)
+	for {
+		page, err := listS3Page(ctx, s3Client, input, "DeleteS3Prefix")
+		if err != nil {
+			return deleted, err
+		}
+		for _, obj := range page.Contents {
+			pending = append(pending, aws.ToString(obj.Key))
+		}
+		if o.maxKeys > 0 && len(pending) > o.maxKeys {
+			return 0, newError(ErrTooManyObjects, "DeleteS3Prefix",
+				fmt.Errorf("s3://%s/%s holds more than %d objects", bucket, prefix, o.maxKeys))
+		}
+		if !listFirst {
+			n, items, err := deleteS3Keys(ctx, s3Client, bucket, pending, listed)
+			deleted += n
+			failed = append(failed, items...)
+			if err != nil {
+				return deleted, err
+			}
+			listed += len(pending)
+			pending = pending[:0]
+		}
+		if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+			break
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+
+	if o.dryRun {
+		return len(pending), nil
+	}
+	if listFirst {
+		n, items, err := deleteS3Keys(ctx, s3Client, bucket, pending, 0)
+		deleted += n
+		failed = append(failed, items...)
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, newBatchError("DeleteS3Prefix", deleted+len(failed), deleted, failed)
+}
+
+// deleteS3Keys deletes keys in batches of deleteBatchSize, returning how
+// many were deleted and which S3 declined, indexed from base. It stops at
+// the first failed request.
+func deleteS3Keys(ctx context.Context, s3Client S3API, bucket string, keys []string, base int) (int, []*ItemError, error) {
+	var (
+		deleted int
+		failed  []*ItemError
+	)
+	for start := 0; start < len(keys); start += deleteBatchSize {
+		batch := keys[start:min(start+deleteBatchSize, len(keys))]
+		objects := make([]types.ObjectIdentifier, len(batch))
+		index := make(map[string]int, len(batch))
+		for i, key := range batch {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+			index[key] = base + start + i
+		}
+
+		out, err := deleteS3Batch(ctx, s3Client, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, failed, err
+		}
+		for _, e := range out.Errors {
+			key := aws.ToString(e.Key)
+			failed = append(failed, itemError(index[key], key, "s3://"+bucket+"/"+key, "DeleteS3Prefix", ErrS3,
+				&smithy.GenericAPIError{Code: aws.ToString(e.Code), Message: aws.ToString(e.Message)}))
+		}
+		deleted += len(batch) - len(out.Errors)
+	}
+	return deleted, failed, nil
+}
+
+// deleteS3Batch sends one DeleteObjects request under MetadataTimeout.
+func deleteS3Batch(ctx context.Context, s3Client S3API, input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+	out, err := s3Client.DeleteObjects(ctx, input)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, "DeleteS3Prefix", err)
+	}
+	return out, nil
+}
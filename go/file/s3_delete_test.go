@@ -0,0 +1,229 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeBucket serves ListObjectsV2 over a fixed key list in pages of
+// pageSize and records DeleteObjects batches. Keys in refuse come back as
+// per-object AccessDenied errors.
+type fakeBucket struct {
+	keys     []string
+	pageSize int
+	refuse   map[string]bool
+	lists    int
+	batches  [][]string
+}
+
+func (b *fakeBucket) client(t *testing.T) *mockS3Client {
+	return &mockS3Client{
+		listObjectsV2Fn: func(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			b.lists++
+			if p := aws.ToString(in.Prefix); p != "tmp/2023/" {
+				t.Errorf("Prefix = %q", p)
+			}
+			start := 0
+			if in.ContinuationToken != nil {
+				fmt.Sscan(*in.ContinuationToken, &start)
+			}
+			end := min(start+b.pageSize, len(b.keys))
+			out := &s3.ListObjectsV2Output{}
+			for _, k := range b.keys[start:end] {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(k)})
+			}
+			if end < len(b.keys) {
+				out.IsTruncated = aws.Bool(true)
+				out.NextContinuationToken = aws.String(fmt.Sprint(end))
+			}
+			return out, nil
+		},
+		deleteObjectsFn: func(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			var batch []string
+			out := &s3.DeleteObjectsOutput{}
+			for _, obj := range in.Delete.Objects {
+				key := aws.ToString(obj.Key)
+				batch = append(batch, key)
+				if b.refuse[key] {
+					out.Errors = append(out.Errors, types.Error{Key: obj.Key, Code: aws.String("AccessDenied")})
+				}
+			}
+			b.batches = append(b.batches, batch)
+			return out, nil
+		},
+	}
+}
+
+func bucketKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("tmp/2023/%05d.json", i)
+	}
+	return keys
+}
+
+func TestDeleteS3Prefix_Paginates(t *testing.T) {
+	b := &fakeBucket{keys: bucketKeys(2500), pageSize: 1000}
+	defer setMockS3(b.client(t), &mockPresignClient{})()
+
+	n, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2500 || b.lists != 3 {
+		t.Errorf("deleted %d in %d list calls", n, b.lists)
+	}
+	if len(b.batches) != 3 || len(b.batches[0]) != 1000 || len(b.batches[2]) != 500 {
+		t.Errorf("batches = %d", len(b.batches))
+	}
+}
+
+func TestDeleteS3Prefix_BatchesCappedAt1000(t *testing.T) {
+	// Larger pages than a batch allows, as a list-first run accumulates.
+	b := &fakeBucket{keys: bucketKeys(2100), pageSize: 700}
+	defer setMockS3(b.client(t), &mockPresignClient{})()
+
+	n, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/", WithMaxKeys(5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2100 {
+		t.Errorf("deleted %d", n)
+	}
+	for i, batch := range b.batches {
+		if len(batch) > deleteBatchSize {
+			t.Errorf("batch %d has %d keys", i, len(batch))
+		}
+	}
+	if len(b.batches) != 3 {
+		t.Errorf("batches = %d, want 3", len(b.batches))
+	}
+}
+
+func TestDeleteS3Prefix_PartialFailures(t *testing.T) {
+	keys := bucketKeys(1500)
+	b := &fakeBucket{keys: keys, pageSize: 1000, refuse: map[string]bool{keys[3]: true, keys[1200]: true}}
+	defer setMockS3(b.client(t), &mockPresignClient{})()
+
+	n, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/")
+	if n != 1498 {
+		t.Errorf("deleted %d, want 1498", n)
+	}
+	if !errors.Is(err, ErrS3) {
+		t.Fatalf("err = %v, want ErrS3", err)
+	}
+	var be *BatchError
+	if !errors.As(err, &be) || len(be.Failed()) != 2 {
+		t.Fatalf("err = %v, want a BatchError with 2 failures", err)
+	}
+	failed := be.Failed()
+	if failed[0].Key != keys[3] || failed[0].Index != 3 || failed[1].Key != keys[1200] || failed[1].Index != 1200 {
+		t.Errorf("failures = %+v", failed)
+	}
+	if failed[1].URL != "s3://bucket/"+keys[1200] {
+		t.Errorf("URL = %q", failed[1].URL)
+	}
+	if be.Total != 1500 || be.Succeeded() != 1498 {
+		t.Errorf("Total = %d, Succeeded() = %d, want 1500 and 1498", be.Total, be.Succeeded())
+	}
+	if CodeOf(err) != CodeAccessDenied {
+		t.Errorf("CodeOf = %q, want %q", CodeOf(err), CodeAccessDenied)
+	}
+	if len(b.batches) != 2 {
+		t.Errorf("a failed object stopped the run after %d batches", len(b.batches))
+	}
+	if msg := err.Error(); !strings.Contains(msg, "2/1500 failed") || !strings.Contains(msg, keys[1200]) ||
+		!strings.Contains(msg, "AccessDenied") {
+		t.Errorf("err %q does not summarize the failed keys", err)
+	}
+}
+
+func TestDeleteS3Prefix_SafetyCapAndDryRun(t *testing.T) {
+	b := &fakeBucket{keys: bucketKeys(1200), pageSize: 500}
+	defer setMockS3(b.client(t), &mockPresignClient{})()
+
+	n, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/", WithMaxKeys(1000))
+	if !errors.Is(err, ErrTooManyObjects) || n != 0 {
+		t.Fatalf("n = %d, err = %v; want ErrTooManyObjects", n, err)
+	}
+	if len(b.batches) != 0 {
+		t.Errorf("deleted %d batches despite the cap", len(b.batches))
+	}
+
+	n, err = DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/", WithDryRun())
+	if err != nil || n != 1200 {
+		t.Errorf("dry run: n = %d, err = %v", n, err)
+	}
+	if len(b.batches) != 0 {
+		t.Errorf("dry run deleted %d batches", len(b.batches))
+	}
+
+	if _, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/", WithMaxKeys(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestDeleteS3Prefix_RequestFailure(t *testing.T) {
+	b := &fakeBucket{keys: bucketKeys(10), pageSize: 5}
+	client := b.client(t)
+	client.deleteObjectsFn = func(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+		return nil, errors.New("throttled")
+	}
+	defer setMockS3(client, &mockPresignClient{})()
+
+	// Without a cap each page is deleted as it is listed.
+	n, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/", WithMaxKeys(0))
+	if !errors.Is(err, ErrS3) || n != 0 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if b.lists != 1 {
+		t.Errorf("kept listing after a failed delete: %d lists", b.lists)
+	}
+}
+
+func TestDeleteS3Prefix_DefaultCap(t *testing.T) {
+	b := &fakeBucket{keys: bucketKeys(DefaultDeleteMaxKeys + 1), pageSize: 1000}
+	defer setMockS3(b.client(t), &mockPresignClient{})()
+
+	n, err := DeleteS3Prefix(context.Background(), "bucket", "tmp/2023/")
+	if !errors.Is(err, ErrTooManyObjects) || n != 0 {
+		t.Fatalf("n = %d, err = %v; want ErrTooManyObjects", n, err)
+	}
+	if len(b.batches) != 0 {
+		t.Errorf("deleted %d batches despite the default cap", len(b.batches))
+	}
+}
+
+func TestDeleteS3Prefix_EmptyPrefix(t *testing.T) {
+	var listed []string
+	client := &mockS3Client{
+		listObjectsV2Fn: func(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			listed = append(listed, aws.ToString(in.Prefix))
+			return &s3.ListObjectsV2Output{}, nil
+		},
+	}
+	defer setMockS3(client, &mockPresignClient{})()
+
+	if _, err := DeleteS3Prefix(context.Background(), "bucket", ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("err = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := DeleteS3Prefix(context.Background(), "bucket", "/"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf(`prefix "/": err = %v, want ErrInvalidArgument`, err)
+	}
+	if len(listed) != 0 {
+		t.Fatalf("listed %q for a rejected prefix", listed)
+	}
+	if _, err := DeleteS3Prefix(context.Background(), "bucket", "", WithAllowEmptyPrefix()); err != nil {
+		t.Errorf("WithAllowEmptyPrefix: %v", err)
+	}
+	if len(listed) != 1 || listed[0] != "" {
+		t.Errorf("listed = %q, want the whole bucket", listed)
+	}
+}
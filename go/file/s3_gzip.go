@@ -0,0 +1,115 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3MetaUncompressedSize is the S3 user metadata key WithTransparentGzip
+// records the uncompressed size under, in decimal bytes.
+const S3MetaUncompressedSize = ReservedAttrPrefix + "uncompressed-size"
+
+// WithTransparentGzip makes UploadToS3 gzip the content on the way up. The
+// object is stored with ContentEncoding gzip, the File's own (uncompressed)
+// ContentType, and the uncompressed size in S3MetaUncompressedSize, so
+// NewFromS3 and HTTP clients see the original bytes. The compressed body is
+// spooled through a temp file rather than memory.
+func WithTransparentGzip() UploadOption {
+	return func(o *uploadOptions) { o.transparentGzip = true }
+}
+
+// WithRawBody makes NewFromS3 keep an object stored with a Content-Encoding
+// (such as one uploaded with WithTransparentGzip) as stored instead of
+// decoding it. The encoding is recorded in Attributes under
+// AttrContentEncoding, so Decompress can undo it later.
+func WithRawBody() ReadOption {
+	return readOption(func(o *readOptions) { o.rawBody = true })
+}
+
+// putGzipped uploads f's content gzip-compressed with input, as
+// WithTransparentGzip describes.
+func (f *File) putGzipped(ctx context.Context, dl opDeadline, watch *watchdog, client S3API, input *s3.PutObjectInput) error {
+	r, err := f.openStream(ctx)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	spool, err := createTemp("upload-*")
+	if err != nil {
+		return newError(ErrWrite, "UploadToS3", err)
+	}
+	spoolPath := spool.Name()
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spoolPath)
+	}()
+
+	plain := &countingReader{r: r}
+	zw := gzip.NewWriter(spool)
+	if _, err := io.Copy(zw, plain); err != nil {
+		return newError(ErrRead, "UploadToS3", err)
+	}
+	if err := zw.Close(); err != nil {
+		return newError(ErrWrite, "UploadToS3", err)
+	}
+	size, err := spool.Seek(0, io.SeekEnd)
+	if err != nil {
+		return newError(ErrRead, "UploadToS3", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return newError(ErrRead, "UploadToS3", err)
+	}
+
+	watch.kick()
+	input.Body = spool
+	input.ContentLength = aws.Int64(size)
+	input.ContentEncoding = aws.String(string(CodecGzip))
+	if input.Metadata == nil {
+		input.Metadata = map[string]string{}
+	}
+	input.Metadata[S3MetaUncompressedSize] = strconv.FormatInt(plain.n, 10)
+	if err := checkS3UserMetadataSize("UploadToS3", input.Metadata); err != nil {
+		return err
+	}
+	if _, err := client.PutObject(ctx, input); err != nil {
+		return dl.newError(ctx, ErrS3, "UploadToS3", err)
+	}
+	f.meta.Size = plain.n
+	f.transferSize = size
+	return nil
+}
+
+// decodeS3Body undoes the Content-Encoding of a GetObject body read into
+// data. ok is false when the object has no encoding this package can
+// decode. When the object records S3MetaUncompressedSize, the decoded
+// length must match it.
+func decodeS3Body(out *s3.GetObjectOutput, data []byte) (decoded []byte, ok bool, err error) {
+	zr, ok, err := decodeContentEncoding(bytes.NewReader(data), aws.ToString(out.ContentEncoding))
+	if err != nil {
+		return nil, false, newError(ErrRead, "NewFromS3", err)
+	}
+	if !ok {
+		return data, false, nil
+	}
+	defer zr.Close()
+	decoded, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, false, newError(ErrRead, "NewFromS3", err)
+	}
+	if v, found := out.Metadata[S3MetaUncompressedSize]; found {
+		if n, perr := strconv.ParseInt(v, 10, 64); perr == nil && n != int64(len(decoded)) {
+			return nil, false, newError(ErrRead, "NewFromS3", fmt.Errorf("decoded %d bytes, but the object records an uncompressed size of %d", len(decoded), n))
+		}
+	}
+	return decoded, true, nil
+}
@@ -0,0 +1,137 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// gzipRoundTripS3 is a one-object mock bucket that keeps what PutObject
+// stored and serves it back from GetObject.
+func gzipRoundTripS3(t *testing.T) *s3.PutObjectInput {
+	t.Helper()
+	stored := &s3.PutObjectInput{}
+	var body []byte
+	cleanup := setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			*stored = *in
+			var err error
+			body, err = io.ReadAll(in.Body)
+			return &s3.PutObjectOutput{}, err
+		},
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:            io.NopCloser(bytes.NewReader(body)),
+				ContentLength:   aws.Int64(int64(len(body))),
+				ContentType:     stored.ContentType,
+				ContentEncoding: stored.ContentEncoding,
+				Metadata:        stored.Metadata,
+			}, nil
+		},
+	}, &mockPresignClient{})
+	t.Cleanup(cleanup)
+	return stored
+}
+
+func TestTransparentGzip_RoundTrip(t *testing.T) {
+	stored := gzipRoundTripS3(t)
+	plain := []byte(`{"items":[` + strings.Repeat(`{"k":"value"},`, 2000) + `{}]}`)
+	f, _ := NewFromBytes(plain, MetadataHint{Name: "blob.json", MimeType: "application/json"})
+
+	if err := f.UploadToS3("bucket", "blob.json", WithTransparentGzip()); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if got := aws.ToString(stored.ContentEncoding); got != "gzip" {
+		t.Errorf("ContentEncoding = %q, want gzip", got)
+	}
+	if got := aws.ToString(stored.ContentType); got != "application/json" {
+		t.Errorf("ContentType = %q, want the logical type", got)
+	}
+	if got := stored.Metadata[S3MetaUncompressedSize]; got != strconv.Itoa(len(plain)) {
+		t.Errorf("uncompressed size metadata = %q, want %d", got, len(plain))
+	}
+	compressed := aws.ToInt64(stored.ContentLength)
+	if compressed <= 0 || compressed >= int64(len(plain)) {
+		t.Errorf("stored ContentLength = %d, want a compressed size below %d", compressed, len(plain))
+	}
+	if f.TransferSize() != compressed {
+		t.Errorf("TransferSize = %d, want %d", f.TransferSize(), compressed)
+	}
+
+	got, err := NewFromS3("bucket", "blob.json")
+	if err != nil {
+		t.Fatalf("NewFromS3: %v", err)
+	}
+	data, _ := got.Read()
+	if !bytes.Equal(data, plain) {
+		t.Fatal("NewFromS3 did not return the original bytes")
+	}
+	if got.Size() != int64(len(plain)) {
+		t.Errorf("Size = %d, want %d", got.Size(), len(plain))
+	}
+	if got.TransferSize() != compressed {
+		t.Errorf("download TransferSize = %d, want %d", got.TransferSize(), compressed)
+	}
+	if got.MimeType() != "application/json" {
+		t.Errorf("MimeType = %q", got.MimeType())
+	}
+}
+
+func TestTransparentGzip_RawBody(t *testing.T) {
+	gzipRoundTripS3(t)
+	plain := []byte(strings.Repeat("text ", 1000))
+	f, _ := NewFromBytes(plain, MetadataHint{Name: "a.txt"})
+	if err := f.UploadToS3("bucket", "a.txt", WithTransparentGzip()); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := NewFromS3("bucket", "a.txt", WithRawBody())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := raw.Read()
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		t.Fatal("WithRawBody returned decoded bytes")
+	}
+	if raw.Size() != int64(len(data)) {
+		t.Errorf("raw Size = %d, want the stored %d", raw.Size(), len(data))
+	}
+	if enc := raw.Metadata().Attributes[AttrContentEncoding]; enc != "gzip" {
+		t.Errorf("AttrContentEncoding = %q, want gzip", enc)
+	}
+	inflated, err := raw.Decompress()
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if out, _ := inflated.Read(); !bytes.Equal(out, plain) {
+		t.Error("Decompress of the raw body differs from the original")
+	}
+}
+
+func TestTransparentGzip_SizeMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:            io.NopCloser(bytes.NewReader(buf.Bytes())),
+				ContentEncoding: aws.String("gzip"),
+				Metadata:        map[string]string{S3MetaUncompressedSize: "6"},
+			}, nil
+		},
+	}, &mockPresignClient{})()
+
+	if _, err := NewFromS3("bucket", "k"); !errors.Is(err, ErrRead) {
+		t.Fatalf("err = %v, want ErrRead for a size mismatch", err)
+	}
+}
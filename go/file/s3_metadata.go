@@ -0,0 +1,195 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// UpdateS3Metadata changes the content headers and user metadata of the
+// file's S3 object without re-uploading its content. Only these fields of
+// changes are honored:
+//
+//   - MimeType replaces the ContentType.
+//   - Name replaces the Content-Disposition filename, keeping an "inline"
+//     disposition inline.
+//   - Attributes are merged key-by-key into the user metadata, encoded as
+//     UploadToS3 encodes them; an empty value removes the key. Keys under
+//     ReservedAttrPrefix are rejected, and user metadata that would exceed
+//     MaxS3UserMetadataSize fails with ErrMetadataTooLarge.
+//
+// Any other non-zero field fails with ErrInvalidArgument, since it
+// describes the content rather than its headers (SetS3Tags changes Tags).
+// The object is read with HeadObject and copied onto itself with
+// MetadataDirective REPLACE, carrying over everything changes does not
+// mention (Cache-Control, Content-Encoding, Content-Language, storage
+// class, server-side encryption). The copy is conditional on the ETag
+// read, so a concurrent overwrite fails the update instead of being
+// clobbered. CopyObject handles objects up to 5 GB; on versioned buckets
+// the update creates a new version.
+//
+// On success the File's metadata is updated to match, including the new
+// ETag (Hash) and VersionID. The file must be S3-sourced. MetadataTimeout
+// applies when ctx has no deadline.
+func (f *File) UpdateS3Metadata(ctx context.Context, changes MetadataHint) error {
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return newError(ErrInvalidSource, "UpdateS3Metadata", fmt.Errorf("file is not S3-sourced"))
+	}
+	if err := checkMetadataChanges(changes); err != nil {
+		return newError(ErrInvalidArgument, "UpdateS3Metadata", err)
+	}
+	if !changes.hasName() && !changes.hasMimeType() && !changes.hasAttributes() {
+		return nil
+	}
+	if err := checkNetwork(ctx, "UpdateS3Metadata"); err != nil {
+		return err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	s3Client, _ := s3Clients()
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return dl.newError(ctx, ErrS3, "UpdateS3Metadata", err)
+	}
+
+	input := metadataCopyInput(bucket, key, head, changes)
+	if err := checkS3UserMetadataSize("UpdateS3Metadata", input.Metadata); err != nil {
+		return err
+	}
+	out, err := s3Client.CopyObject(ctx, input)
+	if err != nil {
+		return dl.newError(ctx, ErrS3, "UpdateS3Metadata", err)
+	}
+
+	if changes.hasMimeType() {
+		f.meta.MimeType = changes.MimeType
+	}
+	if changes.hasName() {
+		f.meta.Name = changes.Name
+	}
+	if changes.hasAttributes() {
+		f.meta.Attributes = mergeAttributes(f.meta.Attributes, changes.Attributes)
+		for k, v := range changes.Attributes {
+			if v == "" {
+				delete(f.meta.Attributes, k)
+			}
+		}
+	}
+	if r := out.CopyObjectResult; r != nil {
+		if etag := strings.Trim(aws.ToString(r.ETag), `"`); etag != "" {
+			f.meta.Hash = etag
+		}
+		if r.LastModified != nil {
+			f.meta.LastModified = *r.LastModified
+		}
+	}
+	if out.VersionId != nil {
+		f.meta.VersionID = *out.VersionId
+	}
+	return nil
+}
+
+// checkMetadataChanges rejects fields UpdateS3Metadata cannot apply,
+// reserved attribute keys, and names that cannot go in a Content-Disposition
+// header.
+func checkMetadataChanges(h MetadataHint) error {
+	var unsupported []string
+	for _, c := range []struct {
+		field string
+		set   bool
+	}{
+		{"Size", h.hasSize()},
+		{"Extension", h.hasExtension()},
+		{"URL", h.hasURL()},
+		{"Path", h.hasPath()},
+		{"Hash", h.hasHash()},
+		{"LastModified", h.hasLastModified()},
+		{"CreatedAt", h.hasCreatedAt()},
+		{"Tags", h.hasTags()},
+	} {
+		if c.set {
+			unsupported = append(unsupported, c.field)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("cannot change %s of an S3 object in place", strings.Join(unsupported, ", "))
+	}
+	for k := range h.Attributes {
+		if strings.HasPrefix(strings.ToLower(k), ReservedAttrPrefix) {
+			return fmt.Errorf("attribute %q uses the reserved prefix %q", k, ReservedAttrPrefix)
+		}
+	}
+	if strings.ContainsFunc(h.Name, func(r rune) bool {
+		return unicode.IsControl(r) || strings.ContainsRune(`"/\`, r)
+	}) {
+		return fmt.Errorf("name %q contains a quote, slash or control character", h.Name)
+	}
+	return nil
+}
+
+// metadataCopyInput builds the self-copy that applies changes over the
+// headers and user metadata in head.
+func metadataCopyInput(bucket, key string, head *s3.HeadObjectOutput, changes MetadataHint) *s3.CopyObjectInput {
+	contentType := aws.ToString(head.ContentType)
+	if changes.hasMimeType() {
+		contentType = changes.MimeType
+	}
+	disposition := aws.ToString(head.ContentDisposition)
+	if changes.hasName() {
+		disposition = dispositionWithFilename(disposition, changes.Name)
+	}
+	userMeta := maps.Clone(head.Metadata)
+	for k, v := range changes.Attributes {
+		k = strings.ToLower(k)
+		if v == "" {
+			delete(userMeta, k)
+			continue
+		}
+		if userMeta == nil {
+			userMeta = map[string]string{}
+		}
+		userMeta[k] = encodeS3MetaValue(v)
+	}
+
+	return &s3.CopyObjectInput{
+		Bucket:                  aws.String(bucket),
+		Key:                     aws.String(key),
+		CopySource:              aws.String(copySource(bucket, key)),
+		CopySourceIfMatch:       head.ETag,
+		MetadataDirective:       types.MetadataDirectiveReplace,
+		ContentType:             nilIfEmpty(contentType),
+		ContentDisposition:      nilIfEmpty(disposition),
+		CacheControl:            head.CacheControl,
+		ContentEncoding:         head.ContentEncoding,
+		ContentLanguage:         head.ContentLanguage,
+		WebsiteRedirectLocation: head.WebsiteRedirectLocation,
+		Metadata:                userMeta,
+		StorageClass:            types.StorageClass(head.StorageClass),
+		ServerSideEncryption:    head.ServerSideEncryption,
+		SSEKMSKeyId:             head.SSEKMSKeyId,
+		BucketKeyEnabled:        head.BucketKeyEnabled,
+	}
+}
+
+// dispositionWithFilename returns a Content-Disposition value naming name,
+// keeping cd's disposition type when it is "inline".
+func dispositionWithFilename(cd, name string) string {
+	typ := "attachment"
+	if t, _, _ := strings.Cut(cd, ";"); strings.EqualFold(strings.TrimSpace(t), "inline") {
+		typ = "inline"
+	}
+	return BuildContentDisposition(typ, name)
+}
@@ -0,0 +1,159 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// metadataObject serves a GetObject, HeadObject and CopyObject for one
+// object, recording the copy input.
+func metadataObject(copyInput **s3.CopyObjectInput) *mockS3Client {
+	head := &s3.HeadObjectOutput{
+		ContentType:          aws.String("application/octet-stream"),
+		ContentDisposition:   aws.String(`inline; filename="old.bin"`),
+		CacheControl:         aws.String("max-age=60"),
+		ContentEncoding:      aws.String("gzip"),
+		ContentLanguage:      aws.String("en"),
+		ETag:                 aws.String(`"etag-1"`),
+		Metadata:             map[string]string{"owner": "data-team", "stale": "yes"},
+		StorageClass:         types.StorageClassStandardIa,
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+		SSEKMSKeyId:          aws.String("kms-key"),
+	}
+	return &mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{
+				Body:               io.NopCloser(strings.NewReader("%PDF-1.7\n")),
+				ContentType:        head.ContentType,
+				ContentDisposition: head.ContentDisposition,
+				ETag:               head.ETag,
+				Metadata:           head.Metadata,
+			}, nil
+		},
+		headObjectFn: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return head, nil
+		},
+		copyObjectFn: func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			*copyInput = params
+			return &s3.CopyObjectOutput{
+				CopyObjectResult: &types.CopyObjectResult{
+					ETag:         aws.String(`"etag-2"`),
+					LastModified: aws.Time(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)),
+				},
+				VersionId: aws.String("v2"),
+			}, nil
+		},
+	}
+}
+
+func TestUpdateS3Metadata(t *testing.T) {
+	var in *s3.CopyObjectInput
+	defer setMockS3(metadataObject(&in), &mockPresignClient{})()
+
+	f, err := NewFromS3("bucket", "docs/report 1.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.UpdateS3Metadata(context.Background(), MetadataHint{
+		MimeType:   "application/pdf",
+		Name:       "report.pdf",
+		Attributes: map[string]string{"Reviewed": "true", "stale": ""},
+	})
+	if err != nil {
+		t.Fatalf("UpdateS3Metadata: %v", err)
+	}
+
+	if aws.ToString(in.Bucket) != "bucket" || aws.ToString(in.Key) != "docs/report 1.bin" {
+		t.Errorf("target = %s/%s", aws.ToString(in.Bucket), aws.ToString(in.Key))
+	}
+	if got := aws.ToString(in.CopySource); got != "bucket/docs/report%201.bin" {
+		t.Errorf("CopySource = %q", got)
+	}
+	if in.MetadataDirective != types.MetadataDirectiveReplace || aws.ToString(in.CopySourceIfMatch) != `"etag-1"` {
+		t.Errorf("directive %q, if-match %q", in.MetadataDirective, aws.ToString(in.CopySourceIfMatch))
+	}
+	if got := aws.ToString(in.ContentType); got != "application/pdf" {
+		t.Errorf("ContentType = %q", got)
+	}
+	if got := aws.ToString(in.ContentDisposition); got != `inline; filename="report.pdf"` {
+		t.Errorf("ContentDisposition = %q", got)
+	}
+	// Headers not mentioned in changes are preserved.
+	if aws.ToString(in.CacheControl) != "max-age=60" || aws.ToString(in.ContentEncoding) != "gzip" || aws.ToString(in.ContentLanguage) != "en" {
+		t.Errorf("headers not preserved: %+v", in)
+	}
+	if in.StorageClass != types.StorageClassStandardIa || in.ServerSideEncryption != types.ServerSideEncryptionAwsKms || aws.ToString(in.SSEKMSKeyId) != "kms-key" {
+		t.Errorf("storage class / encryption not preserved: %+v", in)
+	}
+	if want := map[string]string{"owner": "data-team", "reviewed": "true"}; !maps.Equal(in.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", in.Metadata, want)
+	}
+
+	if f.MimeType() != "application/pdf" || f.Name() != "report.pdf" {
+		t.Errorf("local metadata = %q, %q", f.MimeType(), f.Name())
+	}
+	m := f.Metadata()
+	if m.Hash != "etag-2" || m.VersionID != "v2" || !m.LastModified.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("hash %q, version %q, modified %v", m.Hash, m.VersionID, m.LastModified)
+	}
+	if m.Attributes["Reviewed"] != "true" {
+		t.Errorf("Attributes = %v", m.Attributes)
+	}
+	if _, ok := m.Attributes["stale"]; ok {
+		t.Error("removed attribute still present locally")
+	}
+}
+
+func TestUpdateS3Metadata_OnlyMimeTypeKeepsDisposition(t *testing.T) {
+	var in *s3.CopyObjectInput
+	defer setMockS3(metadataObject(&in), &mockPresignClient{})()
+
+	f, _ := NewFromS3("bucket", "old.bin")
+	if err := f.UpdateS3Metadata(context.Background(), MetadataHint{MimeType: "application/pdf"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.ToString(in.ContentDisposition); got != `inline; filename="old.bin"` {
+		t.Errorf("ContentDisposition = %q", got)
+	}
+	if want := map[string]string{"owner": "data-team", "stale": "yes"}; !maps.Equal(in.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", in.Metadata, want)
+	}
+}
+
+func TestUpdateS3Metadata_Rejected(t *testing.T) {
+	var in *s3.CopyObjectInput
+	defer setMockS3(metadataObject(&in), &mockPresignClient{})()
+
+	local, _ := NewFromBytes([]byte("x"))
+	if err := local.UpdateS3Metadata(context.Background(), MetadataHint{MimeType: "text/plain"}); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("non-S3 file: err = %v, want ErrInvalidSource", err)
+	}
+
+	f, _ := NewFromS3("bucket", "old.bin")
+	for _, changes := range []MetadataHint{
+		{Size: 10},
+		{MimeType: "text/plain", Hash: "abc"},
+		{Name: `evil"; filename="x.exe`},
+		{Name: "../x.txt"},
+	} {
+		if err := f.UpdateS3Metadata(context.Background(), changes); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("changes %+v: err = %v, want ErrInvalidArgument", changes, err)
+		}
+	}
+	if in != nil {
+		t.Error("CopyObject called for rejected changes")
+	}
+
+	if err := f.UpdateS3Metadata(context.Background(), MetadataHint{}); err != nil || in != nil {
+		t.Errorf("empty changes: err = %v, copy = %v", err, in)
+	}
+}
@@ -0,0 +1,340 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// DefaultMultipartThreshold is the content size above which UploadToS3
+// switches from a single PutObject to a multipart upload. See
+// WithMultipartThreshold.
+const DefaultMultipartThreshold = 100 * 1024 * 1024
+
+// DefaultPartConcurrency is how many parts UploadToS3 sends at once in a
+// multipart upload. See WithPartConcurrency.
+const DefaultPartConcurrency = 4
+
+// maxParts is S3's limit on the parts of one multipart upload.
+const maxParts = 10000
+
+// S3's limits on the size of one part and of a whole object.
+const (
+	maxPartSize     = 5 << 30
+	maxS3ObjectSize = 5 << 40
+)
+
+// partsPerDoubling is how many parts of an upload of unknown size go by
+// before its part size doubles.
+const partsPerDoubling = maxParts / 10
+
+// WithMultipartThreshold makes UploadToS3 use a multipart upload for
+// content larger than n bytes instead of DefaultMultipartThreshold.
+// Content of unknown size counts once its size is known, after it has
+// been buffered or spooled. A negative n is rejected.
+func WithMultipartThreshold(n int64) UploadOption {
+	return func(o *uploadOptions) {
+		if n < 0 {
+			o.reject("WithMultipartThreshold", "negative threshold %d", n)
+			return
+		}
+		o.multipartThreshold = n
+	}
+}
+
+// WithPartSize sets the part size of UploadToS3's multipart uploads
+// (DefaultPartSize by default). Sizes below S3's 5 MiB minimum are
+// rejected. Content that would need more than S3's 10,000 parts gets
+// larger parts.
+func WithPartSize(n int64) UploadOption {
+	return func(o *uploadOptions) {
+		if n < minPartSize {
+			o.reject("WithPartSize", "part size %d is below S3's minimum of %d", n, minPartSize)
+			return
+		}
+		o.partSize = n
+	}
+}
+
+// WithPartConcurrency sets how many parts of a multipart upload UploadToS3
+// sends at once (DefaultPartConcurrency by default). Content that can be
+// read in place is sent straight from its source; a lazy stream of hinted
+// size holds up to n parts in memory. n below 1 is rejected.
+func WithPartConcurrency(n int) UploadOption {
+	return func(o *uploadOptions) {
+		if n < 1 {
+			o.reject("WithPartConcurrency", "concurrency %d is below 1", n)
+			return
+		}
+		o.partConcurrency = n
+	}
+}
+
+// multipartUpload sends one object as a multipart upload. UploadToS3 and
+// S3Sink share it: the object's headers, metadata, tagging, object lock,
+// storage class, encryption and ACL settings come from input, and progress
+// can be recorded in a CheckpointStore so a later upload resumes it.
+type multipartUpload struct {
+	client S3API
+	input  *s3.PutObjectInput
+	op     string
+	dl     opDeadline
+	// watch, when set, is kicked as each request is sent and each part
+	// finishes.
+	watch *watchdog
+	// partSize is raised as needed to fit a known size into S3's part
+	// limit. Up to concurrency parts are in flight at once.
+	partSize    int64
+	concurrency int
+	// store records the upload under id when set; cp is the checkpoint
+	// being resumed, with resumed reporting whether there is one.
+	store   CheckpointStore
+	id      string
+	cp      Checkpoint
+	resumed bool
+}
+
+// run uploads body, which holds size bytes or, when size is negative, runs
+// to EOF, and returns the number of bytes sent. Parts are read in place
+// when body is an io.ReaderAt of known size and buffered otherwise, so at
+// most concurrency parts are in memory. A resumed part whose size and MD5
+// match the checkpoint is not sent again. Any failure aborts the upload,
+// unless it is checkpointed, in which case it is left for a resume.
+//
+// Content over S3's 5 TiB object limit fails with ErrInvalidArgument: at
+// once when size is known, and otherwise once the stream runs past
+// maxParts parts, which streamPartSize keeps to beyond 5 TiB.
+func (m *multipartUpload) run(ctx context.Context, body io.Reader, size int64) (int64, error) {
+	if size > maxS3ObjectSize {
+		return 0, newError(ErrInvalidArgument, m.op, fmt.Errorf("%d bytes is over S3's object limit of %d", size, int64(maxS3ObjectSize)))
+	}
+	if size >= 0 && !m.resumed {
+		m.partSize = max(m.partSize, (size+maxParts-1)/maxParts)
+	}
+	input := m.input
+	if !m.resumed {
+		m.watch.kick()
+		created, err := m.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:                    input.Bucket,
+			Key:                       input.Key,
+			ContentType:               input.ContentType,
+			ContentDisposition:        input.ContentDisposition,
+			ContentEncoding:           input.ContentEncoding,
+			Metadata:                  input.Metadata,
+			Tagging:                   input.Tagging,
+			ObjectLockMode:            input.ObjectLockMode,
+			ObjectLockRetainUntilDate: input.ObjectLockRetainUntilDate,
+			ChecksumAlgorithm:         input.ChecksumAlgorithm,
+			StorageClass:              input.StorageClass,
+			ServerSideEncryption:      input.ServerSideEncryption,
+			SSEKMSKeyId:               input.SSEKMSKeyId,
+			ACL:                       input.ACL,
+		})
+		if err != nil {
+			return 0, m.dl.newError(ctx, ErrS3, m.op, err)
+		}
+		m.cp = Checkpoint{
+			ID:                m.id,
+			Bucket:            aws.ToString(input.Bucket),
+			Key:               aws.ToString(input.Key),
+			UploadID:          aws.ToString(created.UploadId),
+			PartSize:          m.partSize,
+			ChecksumAlgorithm: string(input.ChecksumAlgorithm),
+		}
+		if m.store != nil {
+			m.cp.UpdatedAt = time.Now().UTC()
+			if err := m.store.Save(ctx, m.cp); err != nil {
+				return 0, err
+			}
+		}
+	}
+	abort := func(cause error) (int64, error) {
+		if m.store != nil {
+			// Leave the upload in place for a resume.
+			return 0, cause
+		}
+		// Use a fresh context: ctx may be the reason we are aborting.
+		_, _ = m.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   input.Bucket,
+			Key:      input.Key,
+			UploadId: aws.String(m.cp.UploadID),
+		})
+		return 0, cause
+	}
+
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// prior is only read by this goroutine; recorded is updated under mu
+	// as parts finish.
+	prior := make(map[int32]CheckpointPart, len(m.cp.Parts))
+	recorded := make(map[int32]CheckpointPart, len(m.cp.Parts))
+	for _, p := range m.cp.Parts {
+		prior[p.Number] = p
+		recorded[p.Number] = p
+	}
+	var (
+		sem      = make(chan struct{}, m.concurrency)
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		parts    []types.CompletedPart
+		total    int64
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	done := func(part types.CompletedPart, n int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		parts = append(parts, part)
+		total += n
+	}
+	ra, inPlace := body.(io.ReaderAt)
+	inPlace = inPlace && size >= 0
+	for num := int32(1); ; num++ {
+		off := int64(num-1) * m.partSize
+		if size >= 0 && off >= size {
+			break
+		}
+		sem <- struct{}{}
+		if partCtx.Err() != nil {
+			<-sem
+			break
+		}
+		n := m.partSize
+		if size >= 0 {
+			n = min(n, size-off)
+		} else {
+			n = m.streamPartSize(num)
+		}
+		var (
+			part io.ReadSeeker
+			data []byte
+			last = size >= 0 && off+n >= size
+		)
+		if inPlace {
+			part = io.NewSectionReader(ra, off, n)
+		} else {
+			data = make([]byte, n)
+			k, err := io.ReadFull(body, data)
+			if size < 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				data, n, last, err = data[:k], int64(k), true, nil
+			}
+			if err != nil {
+				<-sem
+				fail(newError(ErrRead, m.op, err))
+				break
+			}
+			if n == 0 && num > 1 {
+				<-sem
+				break
+			}
+			if num > maxParts {
+				<-sem
+				fail(newError(ErrInvalidArgument, m.op, fmt.Errorf("stream is longer than %d parts can hold", maxParts)))
+				break
+			}
+			part = bytes.NewReader(data)
+		}
+
+		if p, ok := prior[num]; ok && data != nil && p.Size == n && etagMatches(p.ETag, data) {
+			done(m.completedPart(num, aws.String(p.ETag), nil, data), n)
+			<-sem
+		} else {
+			wg.Add(1)
+			m.watch.kick()
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				out, err := m.client.UploadPart(partCtx, &s3.UploadPartInput{
+					Bucket:            input.Bucket,
+					Key:               input.Key,
+					UploadId:          aws.String(m.cp.UploadID),
+					PartNumber:        aws.Int32(num),
+					Body:              part,
+					ContentLength:     aws.Int64(n),
+					ChecksumAlgorithm: input.ChecksumAlgorithm,
+				})
+				if err != nil {
+					fail(m.dl.newError(ctx, ErrS3, m.op, err))
+					return
+				}
+				m.watch.kick()
+				done(m.completedPart(num, out.ETag, out.ChecksumSHA256, data), n)
+				if m.store != nil {
+					mu.Lock()
+					defer mu.Unlock()
+					recorded[num] = CheckpointPart{Number: num, ETag: aws.ToString(out.ETag), Size: n}
+					m.cp.Parts = sortedParts(recorded)
+					m.cp.UpdatedAt = time.Now().UTC()
+					if err := m.store.Save(ctx, m.cp); err != nil && firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				}
+			}()
+		}
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		// ctx ended between parts, so no part reported it.
+		firstErr = m.dl.newError(ctx, ErrS3, m.op, context.Cause(ctx))
+	}
+	if firstErr != nil {
+		return abort(firstErr)
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return aws.ToInt32(parts[i].PartNumber) < aws.ToInt32(parts[j].PartNumber) })
+	m.watch.kick()
+	_, err := m.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          input.Bucket,
+		Key:             input.Key,
+		UploadId:        aws.String(m.cp.UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abort(m.dl.newError(ctx, ErrS3, m.op, err))
+	}
+	if m.store != nil {
+		_ = m.store.Delete(ctx, m.id)
+	}
+	return total, nil
+}
+
+// streamPartSize returns the size of part num of an upload of unknown
+// size. It starts at partSize and doubles every partsPerDoubling parts, up
+// to S3's maximum part size, so that maxParts parts of the default size
+// hold more than S3's largest object. Past maxParts it is one byte, just
+// enough to find out whether the stream has more.
+func (m *multipartUpload) streamPartSize(num int32) int64 {
+	if num > maxParts {
+		return 1
+	}
+	return min(m.partSize<<((num-1)/partsPerDoubling), maxPartSize)
+}
+
+// completedPart describes part num for CompleteMultipartUpload. With a
+// SHA-256 checksum requested, a buffered part's checksum is computed from
+// data, which also covers parts skipped on resume; a part read in place
+// takes the one S3 returned.
+func (m *multipartUpload) completedPart(num int32, etag, checksum *string, data []byte) types.CompletedPart {
+	if m.input.ChecksumAlgorithm == types.ChecksumAlgorithmSha256 && data != nil {
+		checksum = aws.String(sha256Base64(data))
+	}
+	return types.CompletedPart{ETag: etag, PartNumber: aws.Int32(num), ChecksumSHA256: checksum}
+}
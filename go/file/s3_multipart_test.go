@@ -0,0 +1,289 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeMultipartS3 is an in-memory S3 multipart implementation.
+type fakeMultipartS3 struct {
+	mu        sync.Mutex
+	uploads   map[string]map[int32][]byte // upload ID -> part number -> bytes
+	created   int
+	uploaded  []int32
+	aborted   []string
+	completed []byte
+	// lastCreate is the latest CreateMultipartUpload input, and puts
+	// counts PutObject calls.
+	lastCreate *s3.CreateMultipartUploadInput
+	puts       int
+	// failPart, when non-zero, is a part number UploadPart fails for.
+	failPart int32
+	// afterPart, when set, runs after each successful UploadPart.
+	afterPart func(n int)
+}
+
+func newFakeMultipartS3() *fakeMultipartS3 {
+	return &fakeMultipartS3{uploads: map[string]map[int32][]byte{}}
+}
+
+func (f *fakeMultipartS3) client() *mockS3Client {
+	return &mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.puts++
+			return &s3.PutObjectOutput{}, nil
+		},
+		createMultipartUploadFn: func(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.created++
+			f.lastCreate = in
+			id := fmt.Sprintf("upload-%d", f.created)
+			f.uploads[id] = map[int32][]byte{}
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+		},
+		uploadPartFn: func(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if aws.ToInt32(in.PartNumber) == f.failPart {
+				return nil, errors.New("connection reset")
+			}
+			data, err := io.ReadAll(in.Body)
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(data)) != aws.ToInt64(in.ContentLength) {
+				return nil, fmt.Errorf("part %d: %d bytes, ContentLength %d", aws.ToInt32(in.PartNumber), len(data), aws.ToInt64(in.ContentLength))
+			}
+			f.mu.Lock()
+			parts, ok := f.uploads[aws.ToString(in.UploadId)]
+			if !ok {
+				f.mu.Unlock()
+				return nil, &types.NoSuchUpload{}
+			}
+			parts[aws.ToInt32(in.PartNumber)] = data
+			f.uploaded = append(f.uploaded, aws.ToInt32(in.PartNumber))
+			n := len(f.uploaded)
+			f.mu.Unlock()
+			if f.afterPart != nil {
+				f.afterPart(n)
+			}
+			return &s3.UploadPartOutput{ETag: aws.String(etagOf(data))}, nil
+		},
+		listPartsFn: func(ctx context.Context, in *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			parts, ok := f.uploads[aws.ToString(in.UploadId)]
+			if !ok {
+				return nil, &types.NoSuchUpload{}
+			}
+			out := &s3.ListPartsOutput{IsTruncated: aws.Bool(false)}
+			for num, data := range parts {
+				out.Parts = append(out.Parts, types.Part{PartNumber: aws.Int32(num), ETag: aws.String(etagOf(data))})
+			}
+			return out, nil
+		},
+		completeMultipartUploadFn: func(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			parts := f.uploads[aws.ToString(in.UploadId)]
+			var buf bytes.Buffer
+			for _, p := range in.MultipartUpload.Parts {
+				data := parts[aws.ToInt32(p.PartNumber)]
+				if etagOf(data) != aws.ToString(p.ETag) {
+					return nil, fmt.Errorf("part %d: ETag mismatch", aws.ToInt32(p.PartNumber))
+				}
+				buf.Write(data)
+			}
+			f.completed = buf.Bytes()
+			delete(f.uploads, aws.ToString(in.UploadId))
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+		abortMultipartUploadFn: func(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.aborted = append(f.aborted, aws.ToString(in.UploadId))
+			delete(f.uploads, aws.ToString(in.UploadId))
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+	}
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestUploadToS3_Multipart(t *testing.T) {
+	data := generateRandomBytes(t, 2*minPartSize+1234)
+	opts := []UploadOption{WithMultipartThreshold(minPartSize), WithPartSize(minPartSize), WithPartConcurrency(2)}
+
+	for name, build := range map[string]func() *File{
+		"buffered": func() *File {
+			f, _ := NewFromBytes(data, MetadataHint{Name: "video.bin", Attributes: map[string]string{"owner": "media"}})
+			return f
+		},
+		"stream of hinted size": func() *File {
+			f, _ := NewFromStreamLazy(bytes.NewReader(data), MetadataHint{Name: "video.bin", Size: int64(len(data)), Attributes: map[string]string{"owner": "media"}})
+			return f
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeMultipartS3()
+			defer setMockS3(fake.client(), &mockPresignClient{})()
+			f := build()
+			if err := f.UploadToS3("bucket", "videos/video.bin", opts...); err != nil {
+				t.Fatalf("UploadToS3: %v", err)
+			}
+			if fake.puts != 0 {
+				t.Errorf("PutObject called %d times", fake.puts)
+			}
+			if got := fake.completed; !bytes.Equal(got, data) {
+				t.Errorf("assembled %d bytes, want %d", len(got), len(data))
+			}
+			if len(fake.uploaded) != 3 {
+				t.Errorf("uploaded %d parts, want 3", len(fake.uploaded))
+			}
+			if c := fake.lastCreate; c.Metadata["owner"] != "media" || aws.ToString(c.ContentDisposition) == "" {
+				t.Errorf("CreateMultipartUpload lost the put headers: %+v", c)
+			}
+			if f.TransferSize() != int64(len(data)) {
+				t.Errorf("TransferSize = %d", f.TransferSize())
+			}
+		})
+	}
+}
+
+func TestUploadToS3_BelowThresholdUsesPutObject(t *testing.T) {
+	fake := newFakeMultipartS3()
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	f, _ := NewFromBytes(generateRandomBytes(t, 1024))
+	if err := f.UploadToS3("bucket", "k", WithMultipartThreshold(1024)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.puts != 1 || fake.created != 0 {
+		t.Errorf("puts = %d, multipart uploads created = %d", fake.puts, fake.created)
+	}
+}
+
+func TestUploadToS3_MultipartFailureAborts(t *testing.T) {
+	fake := newFakeMultipartS3()
+	fake.failPart = 2
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	f, _ := NewFromBytes(generateRandomBytes(t, 3*minPartSize))
+	err := f.UploadToS3("bucket", "k", WithMultipartThreshold(minPartSize), WithPartSize(minPartSize))
+	if !errors.Is(err, ErrS3) {
+		t.Fatalf("err = %v, want ErrS3", err)
+	}
+	if len(fake.aborted) != 1 || fake.completed != nil {
+		t.Errorf("aborted = %v, completed = %v", fake.aborted, fake.completed != nil)
+	}
+}
+
+func TestUploadToS3_MultipartOptionsRejected(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	for name, opt := range map[string]UploadOption{
+		"part size below 5 MiB": WithPartSize(minPartSize - 1),
+		"negative threshold":    WithMultipartThreshold(-1),
+		"zero concurrency":      WithPartConcurrency(0),
+	} {
+		if err := f.UploadToS3("bucket", "k", opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: err = %v, want ErrInvalidOption", name, err)
+		}
+	}
+}
+
+func TestUploadToS3_StorageClassSSEAndACL(t *testing.T) {
+	opts := []UploadOption{
+		WithStorageClass(types.StorageClassGlacierIr),
+		WithSSE(types.ServerSideEncryptionAwsKms, "arn:aws:kms:us-east-1:123456789012:key/archive"),
+		WithACL(types.ObjectCannedACLBucketOwnerFullControl),
+	}
+
+	var input *s3.PutObjectInput
+	defer setMockS3(capturePut(&input), &mockPresignClient{})()
+	f, _ := NewFromBytes([]byte("data"))
+	if err := f.UploadToS3("bucket", "k", opts...); err != nil {
+		t.Fatal(err)
+	}
+	if input.StorageClass != types.StorageClassGlacierIr || input.ServerSideEncryption != types.ServerSideEncryptionAwsKms ||
+		aws.ToString(input.SSEKMSKeyId) != "arn:aws:kms:us-east-1:123456789012:key/archive" ||
+		input.ACL != types.ObjectCannedACLBucketOwnerFullControl {
+		t.Errorf("PutObjectInput = %+v", input)
+	}
+
+	fake := newFakeMultipartS3()
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+	big, _ := NewFromBytes(generateRandomBytes(t, minPartSize+1))
+	if err := big.UploadToS3("bucket", "k", append(opts, WithMultipartThreshold(minPartSize))...); err != nil {
+		t.Fatal(err)
+	}
+	if c := fake.lastCreate; c == nil || c.StorageClass != types.StorageClassGlacierIr || c.ServerSideEncryption != types.ServerSideEncryptionAwsKms ||
+		aws.ToString(c.SSEKMSKeyId) == "" || c.ACL != types.ObjectCannedACLBucketOwnerFullControl {
+		t.Errorf("CreateMultipartUploadInput = %+v", c)
+	}
+}
+
+func TestMultipartUpload_PartLimit(t *testing.T) {
+	// With one-byte parts, doubling every thousand parts, maxParts parts
+	// hold 1000 * (1 + 2 + ... + 512) bytes.
+	const capacity = partsPerDoubling * 1023
+
+	for name, tc := range map[string]struct {
+		size    int
+		wantErr error
+	}{
+		"fills every part": {capacity, nil},
+		"one byte over":    {capacity + 1, ErrInvalidArgument},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeMultipartS3()
+			m := &multipartUpload{
+				client:      fake.client(),
+				input:       &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("k")},
+				op:          "Pipe",
+				partSize:    1,
+				concurrency: 8,
+			}
+			data := bytes.Repeat([]byte("x"), tc.size)
+			n, err := m.run(context.Background(), bytes.NewReader(data), -1)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				if len(fake.aborted) != 1 {
+					t.Errorf("aborted = %v, want the upload aborted", fake.aborted)
+				}
+				return
+			}
+			if n != int64(tc.size) || !bytes.Equal(fake.completed, data) || len(fake.uploaded) != maxParts {
+				t.Errorf("sent %d bytes in %d parts", n, len(fake.uploaded))
+			}
+		})
+	}
+
+	t.Run("known size over the object limit", func(t *testing.T) {
+		fake := newFakeMultipartS3()
+		m := &multipartUpload{client: fake.client(), input: &s3.PutObjectInput{}, op: "UploadToS3", partSize: DefaultPartSize, concurrency: 1}
+		if _, err := m.run(context.Background(), bytes.NewReader(nil), maxS3ObjectSize+1); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("err = %v, want ErrInvalidArgument", err)
+		}
+		if fake.created != 0 {
+			t.Error("a multipart upload was created")
+		}
+	})
+}
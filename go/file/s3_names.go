@@ -0,0 +1,151 @@
+package file
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+)
+
+// TrimS3KeySlashes makes S3 operations strip leading slashes from keys
+// and prefixes instead of rejecting them. It is off by default because
+// "/a.txt" is a legal key naming a different object from "a.txt"; turn it
+// on only when every leading slash in the program is a path joined by
+// mistake. A key or prefix that is nothing but slashes is rejected either
+// way.
+var TrimS3KeySlashes bool
+
+// maxS3KeyLen is S3's limit on the UTF-8 length of an object key.
+const maxS3KeyLen = 1024
+
+// ValidateBucketName reports whether name follows the S3 naming rules for
+// general purpose buckets, failing with ErrInvalidArgument otherwise:
+//
+//   - 3 to 63 characters
+//   - only lowercase letters, digits, dots and hyphens
+//   - begins and ends with a letter or digit
+//   - no two adjacent dots
+//   - not formatted as an IPv4 address ("192.168.5.4")
+//   - not prefixed "xn--", "sthree-" or "amzn-s3-demo-"
+//
+// Suffixes reserved for access point and directory bucket names
+// ("-s3alias", "--x-s3") are accepted because those names are valid
+// wherever a bucket is, and so are access point ARNs ("arn:...").
+// Buckets created in us-east-1 before March 2018 may use names these
+// rules reject; they cannot be used with this package.
+func ValidateBucketName(name string) error {
+	if reason := bucketNameProblem(name); reason != "" {
+		return newError(ErrInvalidArgument, "ValidateBucketName", fmt.Errorf("bucket name %q %s", name, reason))
+	}
+	return nil
+}
+
+// ValidateKey reports whether key is a usable S3 object key, failing with
+// ErrInvalidArgument when it is empty, longer than 1024 bytes, not valid
+// UTF-8, or starts with "/". S3 operations reject a leading slash the
+// same way unless TrimS3KeySlashes is set.
+func ValidateKey(key string) error {
+	if reason := keyProblem(key); reason != "" {
+		return newError(ErrInvalidArgument, "ValidateKey", fmt.Errorf("key %q %s", key, reason))
+	}
+	return nil
+}
+
+// bucketNameProblem describes why name is not a valid bucket name, or
+// returns "".
+func bucketNameProblem(name string) string {
+	if strings.HasPrefix(name, "arn:") {
+		if strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' }) {
+			return "contains whitespace"
+		}
+		return ""
+	}
+	if len(name) < 3 || len(name) > 63 {
+		return fmt.Sprintf("is %d characters; must be 3 to 63", len(name))
+	}
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-') {
+			return fmt.Sprintf("contains %q at offset %d; only lowercase letters, digits, '.' and '-' are allowed", r, i)
+		}
+	}
+	if !isAlnum(name[0]) || !isAlnum(name[len(name)-1]) {
+		return "must begin and end with a letter or digit"
+	}
+	if strings.Contains(name, "..") {
+		return "contains adjacent dots"
+	}
+	if ip := net.ParseIP(name); ip != nil && ip.To4() != nil {
+		return "is formatted as an IP address"
+	}
+	for _, p := range []string{"xn--", "sthree-", "amzn-s3-demo-"} {
+		if strings.HasPrefix(name, p) {
+			return fmt.Sprintf("uses the reserved prefix %q", p)
+		}
+	}
+	return ""
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// keyProblem describes why key is not a valid object key, or returns "".
+func keyProblem(key string) string {
+	switch {
+	case key == "":
+		return "is empty"
+	case len(key) > maxS3KeyLen:
+		return fmt.Sprintf("is %d bytes; the limit is %d", len(key), maxS3KeyLen)
+	case !utf8.ValidString(key):
+		return "is not valid UTF-8"
+	case strings.HasPrefix(key, "/"):
+		return `starts with "/"`
+	}
+	return ""
+}
+
+// checkS3Object validates bucket and key for op, returning the key with
+// any leading slashes removed when TrimS3KeySlashes is set.
+func checkS3Object(op, bucket, key string) (string, error) {
+	if err := checkS3Bucket(op, bucket); err != nil {
+		return "", err
+	}
+	key = trimS3KeySlashes(key)
+	if reason := keyProblem(key); reason != "" {
+		return "", newError(ErrInvalidArgument, op, fmt.Errorf("key %q %s", key, reason))
+	}
+	return key, nil
+}
+
+// checkS3Prefix is checkS3Object for a key prefix, which may be empty.
+// A non-empty prefix never normalizes to "": "/" names the keys under
+// "/", not the whole bucket.
+func checkS3Prefix(op, bucket, prefix string) (string, error) {
+	if err := checkS3Bucket(op, bucket); err != nil {
+		return "", err
+	}
+	if prefix == "" {
+		return "", nil
+	}
+	if reason := keyProblem(trimS3KeySlashes(prefix)); reason != "" {
+		return "", newError(ErrInvalidArgument, op, fmt.Errorf("prefix %q %s", prefix, reason))
+	}
+	return trimS3KeySlashes(prefix), nil
+}
+
+// trimS3KeySlashes strips leading slashes from key when TrimS3KeySlashes
+// is set.
+func trimS3KeySlashes(key string) string {
+	if TrimS3KeySlashes {
+		return strings.TrimLeft(key, "/")
+	}
+	return key
+}
+
+// checkS3Bucket validates bucket for op.
+func checkS3Bucket(op, bucket string) error {
+	if reason := bucketNameProblem(bucket); reason != "" {
+		return newError(ErrInvalidArgument, op, fmt.Errorf("bucket name %q %s", bucket, reason))
+	}
+	return nil
+}
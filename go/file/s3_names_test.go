@@ -0,0 +1,198 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"abc", true},
+		{"my-bucket", true},
+		{"my.bucket.example", true},
+		{"bucket-2024", true},
+		{"123", true},
+		{strings.Repeat("a", 63), true},
+		{"data--x-s3", true},
+		{"ap-s3alias", true},
+		{"arn:aws:s3:us-east-1:123456789012:accesspoint/ap", true},
+
+		{"", false},
+		{"ab", false},
+		{strings.Repeat("a", 64), false},
+		{"My-Bucket", false},
+		{"my_bucket", false},
+		{"my bucket", false},
+		{"bücket", false},
+		{"-bucket", false},
+		{"bucket-", false},
+		{".bucket", false},
+		{"bucket.", false},
+		{"my..bucket", false},
+		{"192.168.5.4", false},
+		{"xn--bucket", false},
+		{"sthree-bucket", false},
+		{"amzn-s3-demo-bucket", false},
+		{"arn:aws:s3:us-east-1:123456789012:accesspoint/my ap", false},
+	}
+	for _, tt := range tests {
+		err := ValidateBucketName(tt.name)
+		if tt.ok && err != nil {
+			t.Errorf("ValidateBucketName(%q) = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ValidateBucketName(%q) = %v, want ErrInvalidArgument", tt.name, err)
+		}
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{"a", true},
+		{"reports/2024/q1.csv", true},
+		{"spaces and ünïcödé/日本.txt", true},
+		{"trailing/", true},
+		{"a//b", true},
+		{strings.Repeat("k", maxS3KeyLen), true},
+		{strings.Repeat("é", maxS3KeyLen/2), true},
+
+		{"", false},
+		{"/leading.txt", false},
+		{strings.Repeat("k", maxS3KeyLen+1), false},
+		{strings.Repeat("é", maxS3KeyLen/2+1), false},
+		{"bad\xffutf8", false},
+	}
+	for _, tt := range tests {
+		err := ValidateKey(tt.key)
+		if tt.ok && err != nil {
+			t.Errorf("ValidateKey(%.40q) = %v, want nil", tt.key, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ValidateKey(%.40q) = %v, want ErrInvalidArgument", tt.key, err)
+		}
+	}
+}
+
+func TestCheckS3Object_LeadingSlash(t *testing.T) {
+	if _, err := checkS3Object("op", "bucket", "/docs/a.txt"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("default: err = %v, want ErrInvalidArgument", err)
+	}
+	for _, p := range []string{"/", "//", "/docs/"} {
+		if _, err := checkS3Prefix("op", "bucket", p); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("default prefix %q: err = %v, want ErrInvalidArgument", p, err)
+		}
+	}
+
+	TrimS3KeySlashes = true
+	defer func() { TrimS3KeySlashes = false }()
+	key, err := checkS3Object("op", "bucket", "//docs/a.txt")
+	if err != nil || key != "docs/a.txt" {
+		t.Errorf("trim: checkS3Object = %q, %v; want %q", key, err, "docs/a.txt")
+	}
+	if _, err := checkS3Object("op", "bucket", "/"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("trim: key of only slashes: err = %v, want ErrInvalidArgument", err)
+	}
+	if p, err := checkS3Prefix("op", "bucket", "/docs/"); err != nil || p != "docs/" {
+		t.Errorf("trim: checkS3Prefix = %q, %v; want %q", p, err, "docs/")
+	}
+	if _, err := checkS3Prefix("op", "bucket", "/"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("trim: prefix of only slashes: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestUploadToS3_LeadingSlash(t *testing.T) {
+	var input *s3.PutObjectInput
+	cleanup := setMockS3(capturePut(&input), &mockPresignClient{})
+	defer cleanup()
+
+	f, _ := NewFromBytes([]byte("data"))
+	if err := f.UploadToS3("bucket", "/docs/a.txt"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("UploadToS3 default: err = %v, want ErrInvalidArgument", err)
+	}
+
+	TrimS3KeySlashes = true
+	defer func() { TrimS3KeySlashes = false }()
+	if err := f.UploadToS3("bucket", "/docs/a.txt"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if got := aws.ToString(input.Key); got != "docs/a.txt" {
+		t.Errorf("Key = %q, want %q", got, "docs/a.txt")
+	}
+}
+
+func TestS3EntryPoints_FailFastOnInvalidNames(t *testing.T) {
+	fail := func() error { t.Error("S3 called despite an invalid name"); return errors.New("unexpected call") }
+	mock := &mockS3Client{
+		getObjectFn: func(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return nil, fail()
+		},
+		putObjectFn: func(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			return nil, fail()
+		},
+		listObjectsV2Fn: func(context.Context, *s3.ListObjectsV2Input, ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			return nil, fail()
+		},
+	}
+	cleanup := setMockS3(mock, &mockPresignClient{})
+	defer cleanup()
+
+	ctx := context.Background()
+	f, _ := NewFromBytes([]byte("data"))
+	dir, _ := NewFromFile(t.TempDir())
+	calls := map[string]func() error{
+		"NewFromS3": func() error {
+			_, err := NewFromS3WithContext(ctx, "Bad_Bucket", "k")
+			return err
+		},
+		"UploadToS3": func() error { return f.UploadToS3WithContext(ctx, "bucket", "") },
+		"DownloadS3ToFile": func() error {
+			_, err := DownloadS3ToFile(ctx, "ab", "k", t.TempDir()+"/k")
+			return err
+		},
+		"CreatePresignedUploadURL": func() error {
+			_, err := CreatePresignedUploadURL(ctx, "bucket", "bad\xff", nil)
+			return err
+		},
+		"Pipe": func() error {
+			_, err := Pipe(ctx, f, &S3Sink{Bucket: "192.168.0.1", Key: "k"})
+			return err
+		},
+		"UploadDirToS3": func() error { return dir.UploadDirToS3(ctx, "xn--bucket", "") },
+		"ListS3PrefixIter": func() error {
+			for _, err := range ListS3PrefixIter(ctx, "my..bucket", "") {
+				return err
+			}
+			return nil
+		},
+		"DeleteS3Prefix": func() error {
+			_, err := DeleteS3Prefix(ctx, "bucket", strings.Repeat("p", maxS3KeyLen+1))
+			return err
+		},
+		"AuditS3Prefix": func() error {
+			_, err := AuditS3Prefix(ctx, "-bucket", "", false)
+			return err
+		},
+	}
+	for op, call := range calls {
+		err := call()
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", op, err)
+			continue
+		}
+		var fe *FileError
+		if !errors.As(err, &fe) || fe.Op != op {
+			t.Errorf("%s: error op = %v", op, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// DefaultUploadBufferThreshold is how much of a lazy stream of unknown size
+// UploadToS3 buffers in memory before spooling it to a temp file. See
+// SetUploadBufferThreshold.
+const DefaultUploadBufferThreshold = 8 * 1024 * 1024
+
+var uploadBufferThreshold atomic.Int64
+
+func init() {
+	uploadBufferThreshold.Store(DefaultUploadBufferThreshold)
+}
+
+// SetUploadBufferThreshold sets how many bytes of a lazy stream of unknown
+// size UploadToS3 holds in memory. A stream that ends within n bytes is
+// sent from memory; a longer one is spooled through a temp file, so memory
+// stays bounded by n whatever the stream's length. Pass 0 to always spool.
+// Streams whose size was hinted are sent as they are read and never
+// buffered. Safe for concurrent use.
+func SetUploadBufferThreshold(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	uploadBufferThreshold.Store(n)
+}
+
+// UploadBufferThreshold returns the current threshold set by
+// SetUploadBufferThreshold.
+func UploadBufferThreshold() int64 {
+	return uploadBufferThreshold.Load()
+}
+
+// lazyUploadBody takes over a lazy stream for a PutObject body, returning
+// the body, its length and a done func to call with the upload's result.
+// A hinted size is trusted and the stream is sent as it is read, unless
+// the S3 clients cannot send unseekable bodies (see s3StreamsBodies);
+// otherwise the stream is read into memory up to UploadBufferThreshold and
+// spooled to a temp file past it. Errors are reported under op.
+//
+// The File gives up its stream to the upload. When the upload fails, done
+// hands the content back where it can (nothing was read from the stream
+// yet, or it fit in memory); otherwise the File is left without content,
+// done says so on the error it returns, and a later Read reports it.
+func (f *File) lazyUploadBody(op string) (io.Reader, int64, func(error) error, error) {
+	head, tail := f.streamHead, f.streamTail
+	f.streamHead, f.streamTail, f.lazy = nil, nil, false
+	f.consumedBy = op
+	lost := func(err error) error { return f.contentLost(err) }
+
+	if f.meta.Size > 0 && s3StreamsBodies() {
+		read := &countingReader{r: tail}
+		done := func(err error) error {
+			if err != nil && read.n == 0 {
+				f.streamHead, f.streamTail, f.lazy, f.consumedBy = head, tail, true, ""
+				return err
+			}
+			return lost(err)
+		}
+		return io.MultiReader(bytes.NewReader(head), read), f.meta.Size, done, nil
+	}
+
+	threshold := UploadBufferThreshold()
+	buf := bytes.NewBuffer(head)
+	if int64(buf.Len()) <= threshold {
+		// Read one byte past the threshold to learn whether the stream
+		// fits.
+		if _, err := io.CopyN(buf, tail, threshold-int64(buf.Len())+1); err != nil && err != io.EOF {
+			return nil, 0, nil, f.contentLost(newError(ErrRead, op, err))
+		}
+		if int64(buf.Len()) <= threshold {
+			data := buf.Bytes()
+			done := func(err error) error {
+				if err != nil {
+					f.data, f.loaded, f.consumedBy = data, true, ""
+				}
+				return err
+			}
+			return bytes.NewReader(data), int64(len(data)), done, nil
+		}
+	}
+
+	spool, err := createTemp("upload-*")
+	if err != nil {
+		return nil, 0, nil, f.contentLost(newError(ErrWrite, op, err))
+	}
+	cleanup := func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}
+	if _, err := buf.WriteTo(spool); err != nil {
+		cleanup()
+		return nil, 0, nil, f.contentLost(newError(ErrWrite, op, err))
+	}
+	if _, err := io.Copy(spool, tail); err != nil {
+		cleanup()
+		return nil, 0, nil, f.contentLost(newError(ErrRead, op, err))
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, f.contentLost(newError(ErrRead, op, err))
+	}
+	done := func(err error) error {
+		cleanup()
+		return lost(err)
+	}
+	return spool, size, done, nil
+}
+
+// contentLost notes on err, when it is a failure, that the File's lazy
+// stream went with it.
+func (f *File) contentLost(err error) error {
+	var fe *FileError
+	if errors.As(err, &fe) {
+		fe.Err = fmt.Errorf("%w (the File's lazy stream was consumed and its content is lost)", fe.Err)
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// streamS3 records the ContentLength and content of each
+// PutObject, calling before (when set) before the body is read.
+func streamS3(t *testing.T, before func(in *s3.PutObjectInput)) (*[]byte, *int64) {
+	t.Helper()
+	var (
+		got    []byte
+		length int64
+	)
+	t.Cleanup(setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			if before != nil {
+				before(in)
+			}
+			length = aws.ToInt64(in.ContentLength)
+			got, _ = io.ReadAll(in.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{}))
+	return &got, &length
+}
+
+func TestUploadToS3_KnownSizeStreamIsNotMaterialized(t *testing.T) {
+	data := generateRandomBytes(t, 3*streamHeadBytes)
+	src := &countingReader{r: bytes.NewReader(data)}
+	var readBeforePut int64
+	got, length := streamS3(t, func(*s3.PutObjectInput) { readBeforePut = src.n })
+
+	f, err := NewFromStreamLazy(src, MetadataHint{Size: int64(len(data))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if readBeforePut != streamHeadBytes {
+		t.Errorf("%d bytes read before PutObject, want only the %d-byte head", readBeforePut, streamHeadBytes)
+	}
+	if *length != int64(len(data)) || !bytes.Equal(*got, data) {
+		t.Errorf("PutObject got %d bytes, ContentLength %d; want %d", len(*got), *length, len(data))
+	}
+}
+
+func TestUploadToS3_UnknownSizeStreamThreshold(t *testing.T) {
+	SetUploadBufferThreshold(2 * streamHeadBytes)
+	defer SetUploadBufferThreshold(DefaultUploadBufferThreshold)
+
+	for _, tc := range []struct {
+		name    string
+		size    int
+		spooled bool
+	}{
+		{"at the threshold", 2 * streamHeadBytes, false},
+		{"past the threshold", 2*streamHeadBytes + 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var spooled bool
+			got, length := streamS3(t, func(in *s3.PutObjectInput) { _, spooled = in.Body.(*os.File) })
+			data := generateRandomBytes(t, tc.size)
+			f, _ := NewFromStreamLazy(bytes.NewReader(data))
+			if err := f.UploadToS3("bucket", "k"); err != nil {
+				t.Fatalf("UploadToS3: %v", err)
+			}
+			if spooled != tc.spooled {
+				t.Errorf("spooled = %v, want %v", spooled, tc.spooled)
+			}
+			if *length != int64(len(data)) || !bytes.Equal(*got, data) || f.Size() != int64(len(data)) {
+				t.Errorf("PutObject got %d bytes, ContentLength %d, Size %d; want %d", len(*got), *length, f.Size(), len(data))
+			}
+		})
+	}
+}
+
+func TestUploadToS3_LazyFileStreamsFromDisk(t *testing.T) {
+	data := generateRandomBytes(t, 100*1024)
+	p := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var fromDisk bool
+	got, _ := streamS3(t, func(in *s3.PutObjectInput) {
+		if h, ok := in.Body.(*Handle); ok {
+			_, fromDisk = h.r.(*os.File)
+		}
+	})
+
+	f, _ := NewFromFileLazy(p)
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if !fromDisk || f.Loaded() || !bytes.Equal(*got, data) {
+		t.Errorf("body from disk = %v, loaded = %v, content match = %v", fromDisk, f.Loaded(), bytes.Equal(*got, data))
+	}
+}
+
+func TestUploadToS3_PlainHTTPEndpointSpoolsHintedStream(t *testing.T) {
+	SetUploadBufferThreshold(0)
+	defer SetUploadBufferThreshold(DefaultUploadBufferThreshold)
+
+	var spooled bool
+	streamS3(t, func(in *s3.PutObjectInput) { _, spooled = in.Body.(*os.File) })
+	// As ConfigureS3 does for an http:// endpoint.
+	fn := CurrentS3ClientFactory()
+	setS3ClientFactory(fn, true)
+
+	data := generateRandomBytes(t, 2*streamHeadBytes)
+	f, _ := NewFromStreamLazy(bytes.NewReader(data), MetadataHint{Size: int64(len(data))})
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if !spooled {
+		t.Error("an unseekable body was sent to a plain-http endpoint")
+	}
+}
+
+func TestUploadToS3_FailedUploadKeepsStreamContent(t *testing.T) {
+	failing := func(read bool) *mockS3Client {
+		return &mockS3Client{
+			putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				if read {
+					_, _ = io.ReadAll(in.Body)
+				}
+				return nil, errors.New("refused")
+			},
+		}
+	}
+	data := generateRandomBytes(t, 3*streamHeadBytes)
+
+	t.Run("rejected before reading", func(t *testing.T) {
+		t.Cleanup(setMockS3(failing(false), &mockPresignClient{}))
+		f, _ := NewFromStreamLazy(bytes.NewReader(data), MetadataHint{Size: int64(len(data))})
+		if err := f.UploadToS3("bucket", "k"); !errors.Is(err, ErrS3) {
+			t.Fatalf("err = %v, want ErrS3", err)
+		}
+		if got, err := f.Read(); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Read after failed upload: %d bytes, %v", len(got), err)
+		}
+	})
+
+	t.Run("buffered in memory", func(t *testing.T) {
+		t.Cleanup(setMockS3(failing(true), &mockPresignClient{}))
+		f, _ := NewFromStreamLazy(bytes.NewReader(data))
+		if err := f.UploadToS3("bucket", "k"); !errors.Is(err, ErrS3) {
+			t.Fatalf("err = %v, want ErrS3", err)
+		}
+		if got, err := f.Read(); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Read after failed upload: %d bytes, %v", len(got), err)
+		}
+	})
+
+	t.Run("spooled", func(t *testing.T) {
+		SetUploadBufferThreshold(0)
+		defer SetUploadBufferThreshold(DefaultUploadBufferThreshold)
+		t.Cleanup(setMockS3(failing(true), &mockPresignClient{}))
+		f, _ := NewFromStreamLazy(bytes.NewReader(data))
+		err := f.UploadToS3("bucket", "k")
+		if !errors.Is(err, ErrS3) || !strings.Contains(err.Error(), "content is lost") {
+			t.Fatalf("err = %v, want an ErrS3 saying the content is lost", err)
+		}
+		if _, err := f.Read(); err == nil || !strings.Contains(err.Error(), "consumed by UploadToS3") {
+			t.Errorf("Read after failed upload: %v", err)
+		}
+	})
+}
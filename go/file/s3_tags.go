@@ -0,0 +1,143 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Limits S3 places on an object's tag set.
+const (
+	maxS3Tags        = 10
+	maxS3TagKeyLen   = 128
+	maxS3TagValueLen = 256
+)
+
+// SetS3Tags replaces the tag set of the file's S3 object using
+// PutObjectTagging, and Metadata.Tags with it. The file must be
+// S3-sourced. A tag set over S3's limits (10 tags, keys of 1 to 128
+// characters, values up to 256) fails with ErrInvalidArgument.
+// MetadataTimeout applies when ctx has no deadline.
+func (f *File) SetS3Tags(ctx context.Context, tags map[string]string) error {
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return newError(ErrInvalidSource, "SetS3Tags", fmt.Errorf("file is not S3-sourced"))
+	}
+	if err := checkS3Tags("SetS3Tags", tags); err != nil {
+		return err
+	}
+	if err := checkNetwork(ctx, "SetS3Tags"); err != nil {
+		return err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	s3Client, _ := s3Clients()
+
+	_, err := s3Client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tagSet(tags)},
+	})
+	if err != nil {
+		return dl.newError(ctx, ErrS3, "SetS3Tags", err)
+	}
+	f.meta.Tags = maps.Clone(tags)
+	return nil
+}
+
+// LoadS3Tags fetches the tags of the file's S3 object (at its VersionID,
+// when set) with GetObjectTagging into Metadata.Tags, replacing any
+// there. The file must be S3-sourced. MetadataTimeout applies when ctx
+// has no deadline.
+func (f *File) LoadS3Tags(ctx context.Context) error {
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return newError(ErrInvalidSource, "LoadS3Tags", fmt.Errorf("file is not S3-sourced"))
+	}
+	if err := checkNetwork(ctx, "LoadS3Tags"); err != nil {
+		return err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	s3Client, _ := s3Clients()
+
+	tags, err := getS3Tags(ctx, s3Client, bucket, key, f.meta.VersionID)
+	if err != nil {
+		if isS3NotFound(err) {
+			return newError(ErrNotFound, "LoadS3Tags", err)
+		}
+		return dl.newError(ctx, ErrS3, "LoadS3Tags", err)
+	}
+	f.meta.Tags = tags
+	return nil
+}
+
+// checkS3Tags fails op with ErrInvalidArgument when tags break S3's
+// limits on a tag set.
+func checkS3Tags(op string, tags map[string]string) error {
+	if len(tags) > maxS3Tags {
+		return newError(ErrInvalidArgument, op, fmt.Errorf("%d tags, S3 allows at most %d", len(tags), maxS3Tags))
+	}
+	for k, v := range tags {
+		if n := utf8.RuneCountInString(k); n == 0 || n > maxS3TagKeyLen {
+			return newError(ErrInvalidArgument, op, fmt.Errorf("tag key %q must be 1 to %d characters", k, maxS3TagKeyLen))
+		}
+		if utf8.RuneCountInString(v) > maxS3TagValueLen {
+			return newError(ErrInvalidArgument, op, fmt.Errorf("value of tag %q is over %d characters", k, maxS3TagValueLen))
+		}
+	}
+	return nil
+}
+
+// getS3Tags fetches the tags of an object version (the latest when
+// versionID is empty) as a map.
+func getS3Tags(ctx context.Context, s3Client S3API, bucket, key, versionID string, optFns ...func(*s3.Options)) (map[string]string, error) {
+	out, err := s3Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: nilIfEmpty(versionID),
+	}, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(out.TagSet))
+	for _, t := range out.TagSet {
+		tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return tags, nil
+}
+
+// tagSet converts a tag map to an S3 TagSet sorted by key.
+func tagSet(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	set := make([]types.Tag, 0, len(keys))
+	for _, k := range keys {
+		set = append(set, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return set
+}
+
+// encodeTagging encodes tags as the URL query string PutObjectInput.Tagging
+// expects (e.g. "project=alpha&team=data+eng"), sorted by key.
+func encodeTagging(tags map[string]string) string {
+	v := url.Values{}
+	for k, val := range tags {
+		v.Set(k, val)
+	}
+	return v.Encode()
+}
@@ -0,0 +1,326 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// capturePut returns a mock that records the last PutObjectInput.
+func capturePut(captured **s3.PutObjectInput) *mockS3Client {
+	return &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			*captured = params
+			if params.Body != nil {
+				_, _ = io.Copy(io.Discard, params.Body)
+			}
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+}
+
+func TestUploadToS3_WithRetention(t *testing.T) {
+	var input *s3.PutObjectInput
+	cleanup := setMockS3(capturePut(&input), &mockPresignClient{})
+	defer cleanup()
+
+	f, _ := NewFromBytes([]byte("data"))
+	before := time.Now().UTC().Truncate(time.Second)
+	if err := f.UploadToS3("bucket", "k", WithRetention(30*24*time.Hour)); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+
+	if input.Tagging == nil {
+		t.Fatal("Tagging not set")
+	}
+	// RFC 3339 colons must be URL-encoded in the Tagging string.
+	if !strings.HasPrefix(*input.Tagging, DefaultRetentionTagKey+"=") || !strings.Contains(*input.Tagging, "%3A") {
+		t.Errorf("Tagging = %q, want URL-encoded %s=<RFC3339>", *input.Tagging, DefaultRetentionTagKey)
+	}
+	vals, err := url.ParseQuery(*input.Tagging)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	expiry, err := time.Parse(time.RFC3339, vals.Get(DefaultRetentionTagKey))
+	if err != nil {
+		t.Fatalf("expiry not RFC3339: %v", err)
+	}
+	want := before.Add(30 * 24 * time.Hour)
+	if d := expiry.Sub(want); d < 0 || d > 5*time.Second {
+		t.Errorf("expiry = %s, want ~%s", expiry, want)
+	}
+	if input.ObjectLockRetainUntilDate != nil || input.ObjectLockMode != "" {
+		t.Error("object lock fields should be unset without WithObjectLock")
+	}
+}
+
+func TestUploadToS3_WithRetention_CustomKeyAndObjectLock(t *testing.T) {
+	var input *s3.PutObjectInput
+	cleanup := setMockS3(capturePut(&input), &mockPresignClient{})
+	defer cleanup()
+
+	f, _ := NewFromBytes([]byte("data"))
+	err := f.UploadToS3WithContext(context.Background(), "bucket", "k",
+		WithRetention(time.Hour),
+		WithRetentionTagKey("ttl expiry"),
+		WithObjectLock(types.ObjectLockModeGovernance),
+	)
+	if err != nil {
+		t.Fatalf("UploadToS3WithContext: %v", err)
+	}
+
+	if !strings.HasPrefix(aws.ToString(input.Tagging), "ttl+expiry=") {
+		t.Errorf("Tagging = %q, want URL-encoded custom key", aws.ToString(input.Tagging))
+	}
+	if input.ObjectLockMode != types.ObjectLockModeGovernance {
+		t.Errorf("ObjectLockMode = %q", input.ObjectLockMode)
+	}
+	if input.ObjectLockRetainUntilDate == nil {
+		t.Fatal("ObjectLockRetainUntilDate not set")
+	}
+	vals, _ := url.ParseQuery(aws.ToString(input.Tagging))
+	if got := input.ObjectLockRetainUntilDate.Format(time.RFC3339); got != vals.Get("ttl expiry") {
+		t.Errorf("retain-until %s should match tag expiry %s", got, vals.Get("ttl expiry"))
+	}
+}
+
+func TestUploadToS3_NoOptions_NoTagging(t *testing.T) {
+	var input *s3.PutObjectInput
+	cleanup := setMockS3(capturePut(&input), &mockPresignClient{})
+	defer cleanup()
+
+	f, _ := NewFromBytes([]byte("data"))
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	if input.Tagging != nil {
+		t.Errorf("Tagging = %q, want nil", *input.Tagging)
+	}
+}
+
+func TestSetS3Tags(t *testing.T) {
+	var captured *s3.PutObjectTaggingInput
+	mock := &mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("x"))}, nil
+		},
+		putObjectTaggingFn: func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+			captured = params
+			return &s3.PutObjectTaggingOutput{}, nil
+		},
+	}
+	cleanup := setMockS3(mock, &mockPresignClient{})
+	defer cleanup()
+
+	f, err := NewFromS3("bkt", "a/b.txt")
+	if err != nil {
+		t.Fatalf("NewFromS3: %v", err)
+	}
+	if err := f.SetS3Tags(context.Background(), map[string]string{"z": "1", "a": "two words"}); err != nil {
+		t.Fatalf("SetS3Tags: %v", err)
+	}
+
+	if aws.ToString(captured.Bucket) != "bkt" || aws.ToString(captured.Key) != "a/b.txt" {
+		t.Errorf("bucket/key = %s/%s", aws.ToString(captured.Bucket), aws.ToString(captured.Key))
+	}
+	set := captured.Tagging.TagSet
+	if len(set) != 2 || aws.ToString(set[0].Key) != "a" || aws.ToString(set[0].Value) != "two words" || aws.ToString(set[1].Key) != "z" {
+		t.Errorf("TagSet = %+v", set)
+	}
+	if f.Metadata().Tags["a"] != "two words" {
+		t.Errorf("Metadata().Tags = %v", f.Metadata().Tags)
+	}
+}
+
+func TestSetS3Tags_NonS3Source(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	if err := f.SetS3Tags(context.Background(), map[string]string{"a": "b"}); !errors.Is(err, ErrInvalidSource) {
+		t.Fatalf("expected ErrInvalidSource, got %v", err)
+	}
+}
+
+func TestNewFromS3_WithFetchTags(t *testing.T) {
+	var tagCalls int
+	mock := &mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("x"))}, nil
+		},
+		getObjectTaggingFn: func(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+			tagCalls++
+			return &s3.GetObjectTaggingOutput{TagSet: []types.Tag{
+				{Key: aws.String("team"), Value: aws.String("data")},
+			}}, nil
+		},
+	}
+	cleanup := setMockS3(mock, &mockPresignClient{})
+	defer cleanup()
+
+	plain, err := NewFromS3("bucket", "k")
+	if err != nil {
+		t.Fatalf("NewFromS3: %v", err)
+	}
+	if tagCalls != 0 || plain.Metadata().Attributes != nil {
+		t.Fatalf("tags fetched without WithFetchTags")
+	}
+
+	f, err := NewFromS3("bucket", "k", MetadataHint{Name: "named.txt"}, WithFetchTags())
+	if err != nil {
+		t.Fatalf("NewFromS3: %v", err)
+	}
+	if tagCalls != 1 {
+		t.Errorf("GetObjectTagging calls = %d, want 1", tagCalls)
+	}
+	if got := f.Metadata().Attributes["team"]; got != "data" {
+		t.Errorf("Attributes[team] = %q, want data", got)
+	}
+	if got := f.Metadata().Tags["team"]; got != "data" {
+		t.Errorf("Tags[team] = %q, want data", got)
+	}
+	if f.Name() != "named.txt" {
+		t.Errorf("hint alongside read option lost: Name() = %q", f.Name())
+	}
+}
+
+func TestMetadata_ReturnsAttributeCopy(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"), MetadataHint{Attributes: map[string]string{"a": "1"}})
+	m := f.Metadata()
+	m.Attributes["a"] = "mutated"
+	if f.Metadata().Attributes["a"] != "1" {
+		t.Error("Metadata() must not expose the internal Attributes map")
+	}
+}
+
+// taggingS3 is a mock S3 that stores the Tagging of each PutObject and
+// serves it back from GetObjectTagging.
+func taggingS3(t *testing.T, input **s3.PutObjectInput) {
+	t.Helper()
+	t.Cleanup(setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			*input = in
+			return &s3.PutObjectOutput{}, nil
+		},
+		getObjectTaggingFn: func(ctx context.Context, in *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+			if *input == nil {
+				return nil, &types.NoSuchKey{}
+			}
+			q, err := url.ParseQuery(aws.ToString((*input).Tagging))
+			if err != nil {
+				return nil, err
+			}
+			out := &s3.GetObjectTaggingOutput{}
+			for k := range q {
+				out.TagSet = append(out.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(q.Get(k))})
+			}
+			return out, nil
+		},
+	}, &mockPresignClient{}))
+}
+
+func TestUploadToS3_TagsRoundTrip(t *testing.T) {
+	var input *s3.PutObjectInput
+	taggingS3(t, &input)
+
+	tags := map[string]string{
+		"cost center": "R&D / 50%",
+		"expr":        "a=b+c",
+		"owner":       "zoë@example.com",
+		"empty":       "",
+	}
+	f, _ := NewFromBytes([]byte("data"), MetadataHint{Tags: tags})
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatal(err)
+	}
+	// The "&" and "=" inside keys and values must not split pairs.
+	if tagging := aws.ToString(input.Tagging); strings.Count(tagging, "&") != len(tags)-1 || strings.ContainsAny(tagging, " /@") {
+		t.Errorf("Tagging = %q is not URL-encoded", tagging)
+	}
+
+	uploaded := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k"}
+	if err := uploaded.LoadS3Tags(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := uploaded.Metadata().Tags; !maps.Equal(got, tags) {
+		t.Errorf("Tags = %q, want %q", got, tags)
+	}
+}
+
+func TestUploadToS3_TagsWithRetention(t *testing.T) {
+	var input *s3.PutObjectInput
+	taggingS3(t, &input)
+
+	f, _ := NewFromBytes([]byte("data"), MetadataHint{Tags: map[string]string{"team": "data"}})
+	if err := f.UploadToS3("bucket", "k", WithRetention(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	q, _ := url.ParseQuery(aws.ToString(input.Tagging))
+	if q.Get("team") != "data" || q.Get(DefaultRetentionTagKey) == "" {
+		t.Errorf("Tagging = %q, want both the File's tags and the expiry", aws.ToString(input.Tagging))
+	}
+}
+
+func TestUploadToS3_TagLimits(t *testing.T) {
+	many := map[string]string{}
+	for i := range maxS3Tags + 1 {
+		many[fmt.Sprint(i)] = "x"
+	}
+	for name, tags := range map[string]map[string]string{
+		"too many":   many,
+		"empty key":  {"": "x"},
+		"long key":   {strings.Repeat("k", maxS3TagKeyLen+1): "x"},
+		"long value": {"k": strings.Repeat("v", maxS3TagValueLen+1)},
+	} {
+		f, _ := NewFromBytes([]byte("data"), MetadataHint{Tags: tags})
+		if err := f.UploadToS3("bucket", "k"); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestUploadToS3_RetentionTagOverLimits(t *testing.T) {
+	var input *s3.PutObjectInput
+	taggingS3(t, &input)
+
+	full := map[string]string{}
+	for i := range maxS3Tags {
+		full[fmt.Sprint(i)] = "x"
+	}
+	for name, tc := range map[string]struct {
+		tags map[string]string
+		opts []UploadOption
+	}{
+		"one tag too many": {full, []UploadOption{WithRetention(time.Hour)}},
+		"long tag key":     {map[string]string{"team": "data"}, []UploadOption{WithRetention(time.Hour), WithRetentionTagKey(strings.Repeat("k", maxS3TagKeyLen+1))}},
+	} {
+		f, _ := NewFromBytes([]byte("data"), MetadataHint{Tags: tc.tags})
+		if err := f.UploadToS3("bucket", "k", tc.opts...); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", name, err)
+		}
+		if input != nil {
+			t.Errorf("%s: PutObject was sent", name)
+		}
+	}
+}
+
+func TestLoadS3Tags_Errors(t *testing.T) {
+	var input *s3.PutObjectInput
+	taggingS3(t, &input)
+
+	f, _ := NewFromBytes([]byte("x"))
+	if err := f.LoadS3Tags(context.Background()); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("non-S3 file: err = %v, want ErrInvalidSource", err)
+	}
+	missing := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "gone"}
+	if err := missing.LoadS3Tags(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing object: err = %v, want ErrNotFound", err)
+	}
+}
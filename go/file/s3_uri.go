@@ -0,0 +1,116 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// NewFromS3URI is NewFromS3 for an object named by a URI rather than a
+// bucket and key. See NewFromS3URIWithContext for the accepted forms.
+func NewFromS3URI(uri string, opts ...ReadOption) (*File, error) {
+	return NewFromS3URIWithContext(context.Background(), uri, opts...)
+}
+
+// NewFromS3URIWithContext is NewFromS3WithContext for an object named by
+// uri, which is one of:
+//
+//   - s3://bucket/key
+//   - a virtual-hosted-style URL, https://bucket.s3.region.amazonaws.com/key
+//   - a path-style URL, https://s3.region.amazonaws.com/bucket/key
+//
+// The key in an HTTPS URL is percent-decoded, and its query (say, a
+// presigned signature) is ignored. When the host names a region, the
+// object is fetched from that region whatever the client is configured
+// with. Any other URI, or one without a bucket and key, fails with
+// ErrInvalidSource.
+func NewFromS3URIWithContext(ctx context.Context, uri string, opts ...ReadOption) (*File, error) {
+	bucket, key, region, err := parseS3Location(uri)
+	if err != nil {
+		return nil, newError(ErrInvalidSource, "NewFromS3URI", err)
+	}
+	if region != "" {
+		opts = append(opts, readOption(func(o *readOptions) { o.region = region }))
+	}
+	return NewFromS3WithContext(ctx, bucket, key, opts...)
+}
+
+// s3RegionLabel matches a host label that names a region, such as
+// "us-east-1" or "us-gov-west-1", and not one of the other labels S3
+// endpoints carry ("dualstack", "fips", "accelerate", "website").
+var s3RegionLabel = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// parseS3Location extracts the bucket, key and (when the host names one)
+// region from an s3:// URI or an S3 HTTPS URL.
+func parseS3Location(uri string) (bucket, key, region string, err error) {
+	if strings.HasPrefix(uri, "s3://") {
+		bucket, key, ok := parseS3URI(uri)
+		if !ok {
+			return "", "", "", fmt.Errorf("%q does not name a bucket and key", uri)
+		}
+		return bucket, key, "", nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", "", "", fmt.Errorf("%q is not an s3:// URI or an S3 HTTPS URL", uri)
+	}
+	host := strings.ToLower(u.Hostname())
+	var rest string
+	for _, suffix := range []string{".amazonaws.com", ".amazonaws.com.cn"} {
+		if strings.HasSuffix(host, suffix) {
+			rest = strings.TrimSuffix(host, suffix)
+			break
+		}
+	}
+	labels := strings.Split(rest, ".")
+	service := -1
+	for i, l := range labels {
+		if l == "s3" || strings.HasPrefix(l, "s3-") {
+			service = i
+		}
+	}
+	if rest == "" || service < 0 {
+		return "", "", "", fmt.Errorf("%q is not an S3 endpoint", u.Host)
+	}
+
+	// Legacy dash-style endpoints put the region in the service label:
+	// s3-region and s3-website-region. Others, such as s3-accelerate,
+	// name no region.
+	switch l := strings.TrimPrefix(strings.TrimPrefix(labels[service], "s3-"), "website-"); {
+	case l == "external-1":
+		region = "us-east-1"
+	case s3RegionLabel.MatchString(l):
+		region = l
+	}
+	for _, l := range labels[service+1:] {
+		if s3RegionLabel.MatchString(l) {
+			region = l
+		}
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	if service > 0 {
+		bucket, key = strings.Join(labels[:service], "."), path
+	} else {
+		bucket, key, _ = strings.Cut(path, "/")
+	}
+	if bucket == "" || key == "" {
+		return "", "", "", fmt.Errorf("%q does not name a bucket and key", uri)
+	}
+	return bucket, key, region, nil
+}
+
+// s3OptFns returns the per-request client options the read options call
+// for.
+func (o readOptions) s3OptFns() []func(*s3.Options) {
+	if o.region == "" {
+		return nil
+	}
+	region := o.region
+	return []func(*s3.Options){func(so *s3.Options) { so.Region = region }}
+}
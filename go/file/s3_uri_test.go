@@ -0,0 +1,91 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestParseS3Location(t *testing.T) {
+	for _, tt := range []struct {
+		uri                 string
+		bucket, key, region string
+	}{
+		{"s3://bucket/path/to/file.txt", "bucket", "path/to/file.txt", ""},
+		{"https://bucket.s3.us-east-1.amazonaws.com/reports/q3.pdf", "bucket", "reports/q3.pdf", "us-east-1"},
+		{"https://my.dotted.bucket.s3.eu-west-2.amazonaws.com/a%20b.txt", "my.dotted.bucket", "a b.txt", "eu-west-2"},
+		{"https://bucket.s3.amazonaws.com/k?X-Amz-Signature=abc", "bucket", "k", ""},
+		{"https://bucket.s3.dualstack.ap-south-1.amazonaws.com/k", "bucket", "k", "ap-south-1"},
+		{"https://s3.us-west-2.amazonaws.com/bucket/dir/k.csv", "bucket", "dir/k.csv", "us-west-2"},
+		{"https://s3.amazonaws.com/bucket/k", "bucket", "k", ""},
+		{"https://s3-eu-central-1.amazonaws.com/bucket/k", "bucket", "k", "eu-central-1"},
+		{"https://bucket.s3.cn-north-1.amazonaws.com.cn/k", "bucket", "k", "cn-north-1"},
+		{"https://bucket.s3-accelerate.amazonaws.com/k", "bucket", "k", ""},
+		{"https://bucket.s3-accelerate.dualstack.amazonaws.com/k", "bucket", "k", ""},
+		{"https://bucket.s3-website-us-west-1.amazonaws.com/k", "bucket", "k", "us-west-1"},
+		{"https://bucket.s3-website.eu-west-3.amazonaws.com/k", "bucket", "k", "eu-west-3"},
+		{"https://bucket.s3-fips.us-gov-west-1.amazonaws.com/k", "bucket", "k", "us-gov-west-1"},
+	} {
+		bucket, key, region, err := parseS3Location(tt.uri)
+		if err != nil || bucket != tt.bucket || key != tt.key || region != tt.region {
+			t.Errorf("parseS3Location(%q) = (%q, %q, %q, %v), want (%q, %q, %q)",
+				tt.uri, bucket, key, region, err, tt.bucket, tt.key, tt.region)
+		}
+	}
+}
+
+func TestNewFromS3URI(t *testing.T) {
+	var got *s3.GetObjectInput
+	var region string
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			got = in
+			var o s3.Options
+			for _, fn := range optFns {
+				fn(&o)
+			}
+			region = o.Region
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("hello")), ContentType: aws.String("text/plain")}, nil
+		},
+	}, &mockPresignClient{})()
+
+	for uri, wantRegion := range map[string]string{
+		"s3://bucket/docs/hello.txt":                               "",
+		"https://bucket.s3.eu-west-1.amazonaws.com/docs/hello.txt": "eu-west-1",
+		"https://s3.eu-west-1.amazonaws.com/bucket/docs/hello.txt": "eu-west-1",
+	} {
+		f, err := NewFromS3URI(uri, MetadataHint{Name: "greeting.txt"})
+		if err != nil {
+			t.Fatalf("%s: %v", uri, err)
+		}
+		if aws.ToString(got.Bucket) != "bucket" || aws.ToString(got.Key) != "docs/hello.txt" || region != wantRegion {
+			t.Errorf("%s: GetObject(%s, %s) in region %q", uri, aws.ToString(got.Bucket), aws.ToString(got.Key), region)
+		}
+		if f.Source() != SourceS3 || f.URL() != "s3://bucket/docs/hello.txt" || f.Name() != "greeting.txt" {
+			t.Errorf("%s: File = %s %s %s", uri, f.Source(), f.URL(), f.Name())
+		}
+	}
+}
+
+func TestNewFromS3URI_Invalid(t *testing.T) {
+	for _, uri := range []string{
+		"",
+		"s3://bucket",
+		"s3://bucket/",
+		"s3:///key",
+		"gs://bucket/key",
+		"https://example.com/bucket/key",
+		"https://bucket.s3.us-east-1.amazonaws.com/",
+		"https://s3.us-east-1.amazonaws.com/bucket",
+		"ftp://bucket.s3.amazonaws.com/key",
+	} {
+		if _, err := NewFromS3URI(uri); !errors.Is(err, ErrInvalidSource) {
+			t.Errorf("NewFromS3URI(%q): err = %v, want ErrInvalidSource", uri, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package file
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// SampleRegion is a byte range of the content to sample. Offset counts
+// from the start of the content, or from its end when negative (-4096 is
+// the last 4 KB). A Centered region is placed in the middle of the content
+// instead, shifted by Offset. Regions that run past either end are
+// clipped to the content.
+type SampleRegion struct {
+	Offset   int64 `json:"offset"`
+	Length   int64 `json:"length"`
+	Centered bool  `json:"centered,omitempty"`
+}
+
+// SampleSpec lists the regions Sample reads and the statistics it
+// computes over them.
+type SampleSpec struct {
+	Regions []SampleRegion
+
+	// Histogram counts each byte value.
+	Histogram bool
+	// Entropy computes the Shannon entropy in bits per byte (0 to 8).
+	Entropy bool
+	// PrintableRatio computes the share of bytes that are printable ASCII,
+	// tab, newline or carriage return.
+	PrintableRatio bool
+	// NullBytes counts zero bytes.
+	NullBytes bool
+}
+
+// DefaultSampleSpec samples the first, middle and last 4 KB and computes
+// every statistic.
+func DefaultSampleSpec() SampleSpec {
+	return SampleSpec{
+		Regions: []SampleRegion{
+			{Offset: 0, Length: 4096},
+			{Length: 4096, Centered: true},
+			{Offset: -4096, Length: 4096},
+		},
+		Histogram:      true,
+		Entropy:        true,
+		PrintableRatio: true,
+		NullBytes:      true,
+	}
+}
+
+// RegionSample is the content of one sampled region. Offset is where the
+// region starts once resolved against the content's size; Data is shorter
+// than the requested length when the region was clipped. Data is base64
+// in JSON.
+type RegionSample struct {
+	Offset int64  `json:"offset"`
+	Data   []byte `json:"data"`
+}
+
+// SampleResult is what Sample returns. Statistics are computed once over
+// every sampled byte, counting bytes covered by overlapping regions once;
+// those not requested are omitted from JSON.
+type SampleResult struct {
+	Size           int64          `json:"size"`
+	Regions        []RegionSample `json:"regions"`
+	SampledBytes   int64          `json:"sampled_bytes"`
+	Histogram      []int64        `json:"histogram,omitempty"`
+	Entropy        *float64       `json:"entropy,omitempty"`
+	PrintableRatio *float64       `json:"printable_ratio,omitempty"`
+	NullBytes      *int64         `json:"null_bytes,omitempty"`
+}
+
+// Sample reads the regions of the content spec lists and computes the
+// requested statistics over them, for feature extraction that must not
+// load whole files. Only the sampled bytes are read where the source
+// allows it: buffered content is sliced, NewFromReaderAt and lazy local
+// files are read with ReadAt, and an S3 File without content is read with
+// one ranged GetObject per region (plus a HeadObject when its size is
+// unknown), under DownloadTimeout. Other sources are loaded with Read
+// first. A region with a negative Length fails with ErrInvalidOption;
+// directories fail with ErrInvalidSource.
+func (f *File) Sample(spec SampleSpec) (SampleResult, error) {
+	for _, r := range spec.Regions {
+		if r.Length < 0 {
+			return SampleResult{}, newError(ErrInvalidOption, "Sample", fmt.Errorf("region at %d has negative length %d", r.Offset, r.Length))
+		}
+	}
+	ctx := context.Background()
+
+	ra, size, done, err := f.sampleSource(ctx)
+	if err != nil {
+		return SampleResult{}, err
+	}
+	defer done()
+
+	spans := make([][2]int64, len(spec.Regions))
+	for i, r := range spec.Regions {
+		spans[i] = r.resolve(size)
+	}
+	merged := mergeSpans(spans)
+	chunks := make([][]byte, len(merged))
+	for i, m := range merged {
+		buf := make([]byte, m[1]-m[0])
+		n, err := ra.ReadAt(buf, m[0])
+		if err != nil && !(errors.Is(err, io.EOF) && n == len(buf)) {
+			var fe *FileError
+			if errors.As(err, &fe) {
+				return SampleResult{}, err
+			}
+			return SampleResult{}, newError(ErrRead, "Sample", err)
+		}
+		chunks[i] = buf
+	}
+
+	res := SampleResult{Size: size, Regions: make([]RegionSample, len(spans))}
+	for i, s := range spans {
+		data := []byte{}
+		for j, m := range merged {
+			if m[0] <= s[0] && s[1] <= m[1] {
+				data = slices.Clone(chunks[j][s[0]-m[0] : s[1]-m[0]])
+				break
+			}
+		}
+		res.Regions[i] = RegionSample{Offset: s[0], Data: data}
+	}
+	spec.computeStats(&res, chunks)
+	return res, nil
+}
+
+// resolve returns the [start, end) span of r within content of size bytes.
+func (r SampleRegion) resolve(size int64) [2]int64 {
+	start := r.Offset
+	switch {
+	case r.Centered:
+		start = (size-r.Length)/2 + r.Offset
+	case r.Offset < 0:
+		start = size + r.Offset
+	}
+	end := start + r.Length
+	start = min(max(start, 0), size)
+	end = min(max(end, start), size)
+	return [2]int64{start, end}
+}
+
+// mergeSpans returns the non-empty spans sorted by start, with overlapping
+// and adjacent spans merged.
+func mergeSpans(spans [][2]int64) [][2]int64 {
+	sorted := make([][2]int64, 0, len(spans))
+	for _, s := range spans {
+		if s[1] > s[0] {
+			sorted = append(sorted, s)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b [2]int64) int { return cmp.Compare(a[0], b[0]) })
+	var merged [][2]int64
+	for _, s := range sorted {
+		if n := len(merged); n > 0 && s[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], s[1])
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// computeStats fills in the statistics spec asks for over chunks.
+func (spec SampleSpec) computeStats(res *SampleResult, chunks [][]byte) {
+	var hist [256]int64
+	var total int64
+	for _, c := range chunks {
+		total += int64(len(c))
+		for _, b := range c {
+			hist[b]++
+		}
+	}
+	res.SampledBytes = total
+
+	if spec.Histogram {
+		res.Histogram = hist[:]
+	}
+	if spec.Entropy {
+		var e float64
+		for _, n := range hist {
+			if n > 0 {
+				p := float64(n) / float64(total)
+				e -= p * math.Log2(p)
+			}
+		}
+		res.Entropy = &e
+	}
+	if spec.PrintableRatio {
+		var printable int64
+		for b, n := range hist {
+			if b >= 0x20 && b < 0x7f || b == '\t' || b == '\n' || b == '\r' {
+				printable += n
+			}
+		}
+		var ratio float64
+		if total > 0 {
+			ratio = float64(printable) / float64(total)
+		}
+		res.PrintableRatio = &ratio
+	}
+	if spec.NullBytes {
+		nulls := hist[0]
+		res.NullBytes = &nulls
+	}
+}
+
+// sampleSource returns random access to the content and its size. done
+// releases whatever was opened.
+func (f *File) sampleSource(ctx context.Context) (io.ReaderAt, int64, func(), error) {
+	nothing := func() {}
+	switch {
+	case f.dir:
+		return nil, 0, nil, newError(ErrInvalidSource, "Sample", fmt.Errorf("%s is a directory", shownPath(f.meta.Path)))
+	case f.loaded && f.data != nil:
+		return bytes.NewReader(f.data), int64(len(f.data)), nothing, nil
+	case f.readerAt != nil && !f.loaded:
+		return f.readerAt, f.readerAtSize, nothing, nil
+	case f.onDisk():
+		fh, err := f.openOnDisk("Sample")
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		info, err := fh.Stat()
+		if err != nil {
+			fh.Close()
+			return nil, 0, nil, newError(ErrRead, "Sample", err)
+		}
+		return fh, info.Size(), func() { fh.Close() }, nil
+	case f.source == SourceS3 && !f.loaded && !f.lazy:
+		if bucket, key, ok := f.s3Location(); ok {
+			return f.s3Sampler(ctx, bucket, key)
+		}
+	}
+	data, err := f.Read()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nothing, nil
+}
+
+// s3Sampler returns a ranged-GET ReaderAt over the S3 object, holding a
+// transfer slot until done.
+func (f *File) s3Sampler(ctx context.Context, bucket, key string) (io.ReaderAt, int64, func(), error) {
+	if err := checkNetwork(ctx, "Sample"); err != nil {
+		return nil, 0, nil, err
+	}
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		cancel()
+		return nil, 0, nil, dl.newError(ctx, ErrS3, "Sample", err)
+	}
+	done := func() { release(); cancel() }
+
+	s3Client, _ := s3Clients()
+	size := f.meta.Size
+	if size <= 0 {
+		head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			done()
+			return nil, 0, nil, dl.newError(ctx, ErrS3, "Sample", err)
+		}
+		size = aws.ToInt64(head.ContentLength)
+	}
+	return &s3RangeReader{ctx: ctx, dl: dl, client: s3Client, bucket: bucket, key: key}, size, done, nil
+}
+
+// s3RangeReader reads an S3 object with ranged GetObject requests.
+type s3RangeReader struct {
+	ctx         context.Context
+	dl          opDeadline
+	client      S3API
+	bucket, key string
+}
+
+func (r *s3RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	out, err := r.client.GetObject(r.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1)),
+	})
+	if err != nil {
+		return 0, r.dl.newError(r.ctx, ErrS3, "Sample", err)
+	}
+	defer out.Body.Close()
+	n, err := io.ReadFull(out.Body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, r.dl.newError(r.ctx, ErrRead, "Sample", err)
+	}
+	return n, err
+}
@@ -0,0 +1,203 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestSampleRegion_Resolve(t *testing.T) {
+	cases := []struct {
+		region SampleRegion
+		size   int64
+		want   [2]int64
+	}{
+		{SampleRegion{Offset: 0, Length: 4}, 10, [2]int64{0, 4}},
+		{SampleRegion{Offset: 8, Length: 4}, 10, [2]int64{8, 10}},
+		{SampleRegion{Offset: 12, Length: 4}, 10, [2]int64{10, 10}},
+		{SampleRegion{Offset: -4, Length: 4}, 10, [2]int64{6, 10}},
+		{SampleRegion{Offset: -4, Length: 2}, 10, [2]int64{6, 8}},
+		{SampleRegion{Offset: -20, Length: 4}, 10, [2]int64{0, 0}},
+		{SampleRegion{Offset: -20, Length: 14}, 10, [2]int64{0, 4}},
+		{SampleRegion{Length: 4, Centered: true}, 10, [2]int64{3, 7}},
+		{SampleRegion{Length: 4, Centered: true}, 11, [2]int64{3, 7}},
+		{SampleRegion{Offset: 2, Length: 4, Centered: true}, 10, [2]int64{5, 9}},
+		{SampleRegion{Length: 40, Centered: true}, 10, [2]int64{0, 10}},
+		{SampleRegion{Offset: 0, Length: 4}, 0, [2]int64{0, 0}},
+	}
+	for _, c := range cases {
+		if got := c.region.resolve(c.size); got != c.want {
+			t.Errorf("%+v in %d bytes = %v, want %v", c.region, c.size, got, c.want)
+		}
+	}
+}
+
+func TestSample_Regions(t *testing.T) {
+	payload := make([]byte, 100)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	p := filepath.Join(t.TempDir(), "bytes.bin")
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffered, _ := NewFromBytes(payload)
+	lazy, _ := NewFromFileLazy(p)
+	at, _ := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+
+	spec := SampleSpec{Regions: []SampleRegion{
+		{Offset: 0, Length: 10},
+		{Length: 10, Centered: true},
+		{Offset: -10, Length: 10},
+		{Offset: 5, Length: 10}, // overlaps the first
+		{Offset: 200, Length: 10},
+	}}
+	for name, f := range map[string]*File{"buffered": buffered, "lazy file": lazy, "reader at": at} {
+		res, err := f.Sample(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if res.Size != 100 || res.SampledBytes != 35 {
+			t.Errorf("%s: Size = %d, SampledBytes = %d, want 100 and 35", name, res.Size, res.SampledBytes)
+		}
+		for i, want := range [][2]int{{0, 10}, {45, 55}, {90, 100}, {5, 15}, {100, 100}} {
+			got := res.Regions[i]
+			if got.Offset != int64(want[0]) || !bytes.Equal(got.Data, payload[want[0]:want[1]]) {
+				t.Errorf("%s: region %d = {%d %v}, want bytes [%d, %d)", name, i, got.Offset, got.Data, want[0], want[1])
+			}
+		}
+		if f != buffered && f.Loaded() {
+			t.Errorf("%s: Sample should not load the content", name)
+		}
+	}
+}
+
+func TestSample_Statistics(t *testing.T) {
+	// Every byte value once: uniform, so 8 bits of entropy.
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	cases := []struct {
+		name      string
+		data      []byte
+		entropy   float64
+		printable float64
+		nulls     int64
+	}{
+		{"uniform", all, 8, 98.0 / 256, 1},
+		{"text", []byte("aab\naab\n"), 1.5, 1, 0},
+		{"zeros", make([]byte, 64), 0, 0, 64},
+	}
+	spec := SampleSpec{
+		Regions:        []SampleRegion{{Length: 1 << 20}},
+		Histogram:      true,
+		Entropy:        true,
+		PrintableRatio: true,
+		NullBytes:      true,
+	}
+	for _, c := range cases {
+		f, _ := NewFromBytes(c.data)
+		res, err := f.Sample(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if math.Abs(*res.Entropy-c.entropy) > 1e-9 {
+			t.Errorf("%s: entropy = %v, want %v", c.name, *res.Entropy, c.entropy)
+		}
+		if math.Abs(*res.PrintableRatio-c.printable) > 1e-9 {
+			t.Errorf("%s: printable ratio = %v, want %v", c.name, *res.PrintableRatio, c.printable)
+		}
+		if *res.NullBytes != c.nulls {
+			t.Errorf("%s: null bytes = %d, want %d", c.name, *res.NullBytes, c.nulls)
+		}
+		var sum int64
+		for _, n := range res.Histogram {
+			sum += n
+		}
+		if len(res.Histogram) != 256 || sum != int64(len(c.data)) {
+			t.Errorf("%s: histogram has %d buckets summing to %d", c.name, len(res.Histogram), sum)
+		}
+	}
+}
+
+func TestSample_JSON(t *testing.T) {
+	f, _ := NewFromBytes([]byte("hello, sampler"))
+	res, err := f.Sample(SampleSpec{Regions: []SampleRegion{{Length: 5}}, Entropy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	if !strings.Contains(s, `"data":"aGVsbG8="`) || !strings.Contains(s, `"entropy":`) || strings.Contains(s, "histogram") {
+		t.Errorf("JSON = %s", s)
+	}
+	var back SampleResult
+	if err := json.Unmarshal(out, &back); err != nil || string(back.Regions[0].Data) != "hello" {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+}
+
+func TestSample_S3RangedReads(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	var ranges []string
+	var heads int
+	defer setMockS3(&mockS3Client{
+		headObjectFn: func(_ context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			heads++
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(payload)))}, nil
+		},
+		getObjectFn: func(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			r := aws.ToString(in.Range)
+			ranges = append(ranges, r)
+			var start, end int
+			fmt.Sscanf(r, "bytes=%d-%d", &start, &end)
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(payload[start : end+1]))}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "big.bin"}
+	res, err := f.Sample(SampleSpec{Regions: []SampleRegion{
+		{Length: 16},
+		{Length: 16, Centered: true},
+		{Offset: -16, Length: 16},
+	}})
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	if heads != 1 {
+		t.Errorf("HeadObject called %d times, want 1 for the unknown size", heads)
+	}
+	want := []string{"bytes=0-15", "bytes=4992-5007", "bytes=9984-9999"}
+	if fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Errorf("ranges = %v, want %v", ranges, want)
+	}
+	if string(res.Regions[1].Data) != string(payload[4992:5008]) || f.Loaded() {
+		t.Errorf("middle region = %q, loaded = %v", res.Regions[1].Data, f.Loaded())
+	}
+}
+
+func TestSample_Errors(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	if _, err := f.Sample(SampleSpec{Regions: []SampleRegion{{Length: -1}}}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("negative length: err = %v, want ErrInvalidOption", err)
+	}
+	dir, _ := NewFromFile(t.TempDir())
+	if _, err := dir.Sample(DefaultSampleSpec()); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("directory: err = %v, want ErrInvalidSource", err)
+	}
+}
@@ -0,0 +1,55 @@
+package file
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultDirMode is the permission of directories created for a
+// destination unless WithDirMode says otherwise.
+const defaultDirMode fs.FileMode = 0o755
+
+// resolveDest returns destPath with WithExpandPath applied, if set.
+func (o saveOptions) resolveDest(destPath, op string) (string, error) {
+	if !o.expandPath {
+		return destPath, nil
+	}
+	expanded, err := expandPath(destPath)
+	if err != nil {
+		return "", newError(ErrInvalidArgument, op, err)
+	}
+	return expanded, nil
+}
+
+// mkdirAll creates dir and its missing parents with the WithDirMode mode.
+func (o saveOptions) mkdirAll(dir string) error {
+	return os.MkdirAll(dir, o.dirMode)
+}
+
+// expandPath replaces a leading "~" with the home directory and expands
+// $VAR and ${VAR} references, failing on unset variables. The tilde is
+// resolved first so a variable's value is never itself expanded.
+func expandPath(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = home + p[1:]
+	}
+	var missing []string
+	p = os.Expand(p, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("path references unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	return p, nil
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSave_ExpandPath_Tilde(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	f, _ := NewFromBytes([]byte("report"))
+	saved, err := f.Save("~/exports/report.csv", WithExpandPath())
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	want := filepath.Join(home, "exports", "report.csv")
+	if saved.Path() != want {
+		t.Errorf("Path = %q, want %q", saved.Path(), want)
+	}
+	if got, _ := os.ReadFile(want); string(got) != "report" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestSave_ExpandPath_Env(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("FILE_TEST_DATA_DIR", dir)
+
+	f, _ := NewFromBytes([]byte("x"))
+	for _, p := range []string{"$FILE_TEST_DATA_DIR/out/a.bin", "${FILE_TEST_DATA_DIR}/out/b.bin"} {
+		saved, err := f.Save(p, WithExpandPath())
+		if err != nil {
+			t.Fatalf("Save(%q): %v", p, err)
+		}
+		if filepath.Dir(saved.Path()) != filepath.Join(dir, "out") {
+			t.Errorf("Save(%q) wrote %q", p, saved.Path())
+		}
+	}
+}
+
+func TestSave_ExpandPath_MissingEnv(t *testing.T) {
+	t.Setenv("FILE_TEST_UNSET_DIR", "")
+	os.Unsetenv("FILE_TEST_UNSET_DIR")
+
+	f, _ := NewFromBytes([]byte("x"))
+	_, err := f.Save("${FILE_TEST_UNSET_DIR}/out/x.bin", WithExpandPath())
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("err = %v, want ErrInvalidArgument", err)
+	}
+	if !strings.Contains(err.Error(), "FILE_TEST_UNSET_DIR") {
+		t.Errorf("error %q does not name the variable", err)
+	}
+}
+
+func TestSave_NoExpansionByDefault(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("FILE_TEST_DATA_DIR", "elsewhere")
+
+	f, _ := NewFromBytes([]byte("x"))
+	literal := filepath.Join(dir, "$FILE_TEST_DATA_DIR", "x.bin")
+	if _, err := f.Save(literal); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(literal); err != nil {
+		t.Errorf("literal path not written: %v", err)
+	}
+	if got, _ := newSaveOptions(nil).resolveDest("~/x.bin", "Save"); got != "~/x.bin" {
+		t.Errorf("resolveDest = %q, want the path unchanged", got)
+	}
+}
+
+func TestSave_WithDirMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory permission bits are not enforced on Windows")
+	}
+	root := t.TempDir()
+	f, _ := NewFromBytes([]byte("x"))
+	if _, err := f.Save(filepath.Join(root, "a", "b", "x.bin"), WithDirMode(0o700)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, d := range []string{"a", filepath.Join("a", "b")} {
+		info, err := os.Stat(filepath.Join(root, d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != 0o700 {
+			t.Errorf("%s mode = %v, want 0700", d, got)
+		}
+	}
+
+	// CommitAll creates nested directories with the same mode.
+	dest := filepath.Join(root, "commit")
+	if err := CommitAll(map[string]*File{"x/y.bin": f}, dest, WithDirMode(0o700)); err != nil {
+		t.Fatalf("CommitAll: %v", err)
+	}
+	for _, d := range []string{dest, filepath.Join(dest, "x")} {
+		if info, _ := os.Stat(d); info.Mode().Perm() != 0o700 {
+			t.Errorf("%s mode = %v, want 0700", d, info.Mode().Perm())
+		}
+	}
+}
+
+func TestWithDirMode_Rejected(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	for _, mode := range []os.FileMode{0o500, 0o644, os.ModeDir | 0o755} {
+		if _, err := f.Save(filepath.Join(t.TempDir(), "x"), WithDirMode(mode)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("WithDirMode(%v): err = %v, want ErrInvalidOption", mode, err)
+		}
+	}
+}
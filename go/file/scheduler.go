@@ -0,0 +1,267 @@
+package file
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Priority orders queued transfers in a Scheduler. Lower values are served
+// first.
+type Priority int
+
+const (
+	// PriorityInteractive is for transfers a user is waiting on. It is the
+	// default for every operation; see ContextWithPriority.
+	PriorityInteractive Priority = iota
+	// PriorityBatch is for bulk work that can yield to interactive transfers.
+	PriorityBatch
+
+	numPriorities = int(PriorityBatch) + 1
+)
+
+// TransferClass is the direction of a scheduled transfer.
+type TransferClass int
+
+const (
+	// ClassDownload covers operations that fetch content (NewFromURL,
+	// NewFromS3, NewFromStorage, DownloadS3ToFile).
+	ClassDownload TransferClass = iota
+	// ClassUpload covers operations that send content (UploadToS3,
+	// UploadToURL, UploadToStorage, Pipe).
+	ClassUpload
+
+	numClasses = int(ClassUpload) + 1
+)
+
+var defaultScheduler atomic.Pointer[Scheduler]
+
+// SetDefaultScheduler installs s to gate every transfer in the package so
+// concurrent batch and interactive work share a bounded number of in-flight
+// transfers. Nil (the default) means transfers are not throttled. Safe to
+// call while transfers are running: each one queues in the scheduler
+// current when it starts and releases its slot there.
+func SetDefaultScheduler(s *Scheduler) { defaultScheduler.Store(s) }
+
+// CurrentScheduler returns the scheduler set by SetDefaultScheduler, or
+// nil.
+func CurrentScheduler() *Scheduler { return defaultScheduler.Load() }
+
+// Scheduler is a weighted semaphore over in-flight transfers. Each transfer
+// holds a weight, chosen per (Priority, TransferClass), until it finishes;
+// the sum of held weights never exceeds the capacity. Waiting transfers are
+// served strictly by priority and FIFO within a priority, and a waiter at
+// the head of its queue is never overtaken by a lighter one behind it, so
+// heavy transfers are not starved. Safe for concurrent use.
+type Scheduler struct {
+	mu       sync.Mutex
+	capacity int64
+	weights  [numPriorities][numClasses]int64
+	used     int64
+	queues   [numPriorities][]*schedWaiter
+}
+
+// schedWaiter is one queued Acquire call.
+type schedWaiter struct {
+	weight int64
+	ready  chan struct{}
+}
+
+// NewScheduler returns a Scheduler admitting transfers whose weights sum to
+// at most capacity (minimum 1). Every weight starts at 1, so capacity is
+// the maximum number of concurrent transfers until SetWeight says
+// otherwise.
+func NewScheduler(capacity int64) *Scheduler {
+	if capacity < 1 {
+		capacity = 1
+	}
+	s := &Scheduler{capacity: capacity}
+	for p := range s.weights {
+		for c := range s.weights[p] {
+			s.weights[p][c] = 1
+		}
+	}
+	return s
+}
+
+// SetWeight sets the share of capacity a transfer of priority p and class c
+// holds while in flight. Weights are clamped to [1, capacity]. For example,
+// with capacity 8, weighting batch uploads at 4 lets at most two run at once
+// while leaving room for interactive work.
+func (s *Scheduler) SetWeight(p Priority, c TransferClass, weight int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weights[clampPriority(p)][clampClass(c)] = min(max(weight, 1), s.capacity)
+}
+
+// Acquire blocks until a transfer of priority p and class c may start, then
+// returns a release func the caller must call exactly once when the
+// transfer ends. If ctx is done first, the request leaves the queue
+// immediately and ctx.Err() is returned.
+func (s *Scheduler) Acquire(ctx context.Context, p Priority, c TransferClass) (release func(), err error) {
+	p = clampPriority(p)
+
+	s.mu.Lock()
+	w := s.weights[p][clampClass(c)]
+	if s.used+w <= s.capacity && !s.waitingAtOrAbove(p) {
+		s.used += w
+		s.mu.Unlock()
+		return s.releaser(w), nil
+	}
+	sw := &schedWaiter{weight: w, ready: make(chan struct{})}
+	s.queues[p] = append(s.queues[p], sw)
+	s.mu.Unlock()
+
+	select {
+	case <-sw.ready:
+		return s.releaser(w), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-sw.ready:
+			// Granted while we were cancelling; hand the slot back.
+			s.used -= w
+		default:
+			s.remove(p, sw)
+		}
+		s.grant()
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// QueueDepth returns how many transfers are waiting for capacity.
+func (s *Scheduler) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// InFlight returns the total weight currently held by running transfers.
+func (s *Scheduler) InFlight() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+// releaser returns a release func for a transfer holding w. Extra calls are
+// no-ops.
+func (s *Scheduler) releaser(w int64) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.used -= w
+			s.grant()
+			s.mu.Unlock()
+		})
+	}
+}
+
+// waitingAtOrAbove reports whether anything of priority p or more urgent is
+// queued. Caller holds s.mu.
+func (s *Scheduler) waitingAtOrAbove(p Priority) bool {
+	for i := 0; i <= int(p); i++ {
+		if len(s.queues[i]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// grant admits queued waiters in priority order until the head waiter does
+// not fit. Caller holds s.mu.
+func (s *Scheduler) grant() {
+	for p := range s.queues {
+		for len(s.queues[p]) > 0 {
+			sw := s.queues[p][0]
+			if s.used+sw.weight > s.capacity {
+				return
+			}
+			s.used += sw.weight
+			s.queues[p][0] = nil
+			s.queues[p] = s.queues[p][1:]
+			close(sw.ready)
+		}
+	}
+}
+
+// remove drops sw from the priority-p queue. Caller holds s.mu.
+func (s *Scheduler) remove(p Priority, sw *schedWaiter) {
+	q := s.queues[p]
+	for i, x := range q {
+		if x == sw {
+			s.queues[p] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+}
+
+func clampPriority(p Priority) Priority {
+	return min(max(p, PriorityInteractive), PriorityBatch)
+}
+
+func clampClass(c TransferClass) TransferClass {
+	return min(max(c, ClassDownload), ClassUpload)
+}
+
+// priorityKey carries the Priority set by ContextWithPriority.
+type priorityKey struct{}
+
+// transferSlotKey marks a context whose transfer already holds its
+// in-flight registration and default scheduler slot.
+type transferSlotKey struct{}
+
+// ContextWithPriority returns a copy of ctx under which every transfer
+// queues at priority p in the default scheduler: UploadToS3WithContext,
+// NewFromS3WithContext, NewFromURLWithContext, DownloadS3ToFile,
+// EnsureLoaded and the rest, including content a transfer fetches on the
+// way (a location-only S3 File piped elsewhere). Without it transfers use
+// Defaults.Priority; Pipe's WithPriority overrides it for that call.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, clampPriority(p))
+}
+
+// priorityFrom returns the priority a transfer under ctx queues at.
+func priorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return clampPriority(loadDefaults().Priority)
+}
+
+// acquireTransfer registers a transfer with the in-flight set Shutdown
+// drains and waits for a slot in the default scheduler at the priority ctx
+// carries. It returns the context the transfer must run under, which
+// Shutdown cancels if it has to force the transfer to stop, and a release
+// func to call when the transfer is done. After Shutdown it fails with
+// ErrShuttingDown.
+//
+// A transfer that needs another one to get its content, say Pipe fetching
+// a location-only S3 source, runs the inner one under the returned
+// context, which already holds the slot: acquiring again would deadlock a
+// full scheduler.
+func acquireTransfer(ctx context.Context, c TransferClass) (context.Context, func(), error) {
+	if ctx.Value(transferSlotKey{}) != nil {
+		return ctx, func() {}, nil
+	}
+	ctx, untrack, err := inFlight.track(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+	ctx = context.WithValue(ctx, transferSlotKey{}, true)
+	s := CurrentScheduler()
+	if s == nil {
+		return ctx, untrack, nil
+	}
+	release, err := s.Acquire(ctx, priorityFrom(ctx), c)
+	if err != nil {
+		untrack()
+		return ctx, nil, err
+	}
+	return ctx, func() { release(); untrack() }, nil
+}
@@ -0,0 +1,307 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// mustAcquire acquires a slot that must be granted immediately.
+func mustAcquire(t *testing.T, s *Scheduler, p Priority, c TransferClass) func() {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	release, err := s.Acquire(ctx, p, c)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	return release
+}
+
+// queueTask starts an Acquire in the background, waits until it is queued,
+// and returns a channel that receives the result.
+func queueTask(t *testing.T, ctx context.Context, s *Scheduler, p Priority, c TransferClass) <-chan error {
+	t.Helper()
+	before := s.QueueDepth()
+	done := make(chan error, 1)
+	go func() {
+		release, err := s.Acquire(ctx, p, c)
+		if err == nil {
+			defer release()
+		}
+		done <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for s.QueueDepth() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("task never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+func TestScheduler_LimitsInFlight(t *testing.T) {
+	s := NewScheduler(2)
+	var (
+		mu       sync.Mutex
+		running  int
+		peak     int
+		wg       sync.WaitGroup
+		finished = make(chan struct{})
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.Acquire(context.Background(), PriorityBatch, ClassDownload)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(2 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			release()
+		}()
+	}
+	go func() { wg.Wait(); close(finished) }()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tasks did not finish")
+	}
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+	if s.InFlight() != 0 || s.QueueDepth() != 0 {
+		t.Errorf("InFlight = %d, QueueDepth = %d after all released", s.InFlight(), s.QueueDepth())
+	}
+}
+
+func TestScheduler_InteractiveServedBeforeBatch(t *testing.T) {
+	s := NewScheduler(1)
+	release := mustAcquire(t, s, PriorityBatch, ClassDownload)
+
+	batch := queueTask(t, context.Background(), s, PriorityBatch, ClassDownload)
+	// Hold the interactive slot until the batch task is checked.
+	hold := make(chan struct{})
+	granted := make(chan struct{})
+	go func() {
+		r, err := s.Acquire(context.Background(), PriorityInteractive, ClassUpload)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		close(granted)
+		<-hold
+		r()
+	}()
+	for s.QueueDepth() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	release()
+	<-granted
+	if s.QueueDepth() != 1 {
+		t.Errorf("QueueDepth = %d, want batch task still queued", s.QueueDepth())
+	}
+	close(hold)
+	if err := <-batch; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduler_Weights(t *testing.T) {
+	s := NewScheduler(4)
+	s.SetWeight(PriorityBatch, ClassUpload, 3)
+
+	r1 := mustAcquire(t, s, PriorityBatch, ClassUpload)
+	if s.InFlight() != 3 {
+		t.Fatalf("InFlight = %d, want 3", s.InFlight())
+	}
+	// An interactive download (weight 1) still fits beside it.
+	r2 := mustAcquire(t, s, PriorityInteractive, ClassDownload)
+
+	// A second heavy batch upload has to wait for the first.
+	done := queueTask(t, context.Background(), s, PriorityBatch, ClassUpload)
+	r2()
+	select {
+	case <-done:
+		t.Fatal("heavy upload admitted over capacity")
+	case <-time.After(10 * time.Millisecond):
+	}
+	r1()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduler_CancelRemovesQueuedWork(t *testing.T) {
+	s := NewScheduler(1)
+	release := mustAcquire(t, s, PriorityInteractive, ClassDownload)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := queueTask(t, ctx, s, PriorityBatch, ClassDownload)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelled task was not removed promptly")
+	}
+	if s.QueueDepth() != 0 {
+		t.Errorf("QueueDepth = %d after cancel, want 0", s.QueueDepth())
+	}
+}
+
+func TestScheduler_GatesOperations(t *testing.T) {
+	orig := CurrentScheduler()
+	SetDefaultScheduler(NewScheduler(1))
+	defer SetDefaultScheduler(orig)
+
+	release := mustAcquire(t, CurrentScheduler(), PriorityInteractive, ClassDownload)
+	defer release()
+
+	f, _ := NewFromBytes([]byte("payload"))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := Pipe(ctx, f, &PathSink{Path: t.TempDir() + "/out"}, WithPriority(PriorityBatch))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Pipe to time out waiting for a slot, got %v", err)
+	}
+	if CurrentScheduler().QueueDepth() != 0 {
+		t.Errorf("QueueDepth = %d, want 0", CurrentScheduler().QueueDepth())
+	}
+}
+
+func TestSetDefaultScheduler_SwapWhileInFlight(t *testing.T) {
+	orig := CurrentScheduler()
+	defer SetDefaultScheduler(orig)
+	first := NewScheduler(1)
+	SetDefaultScheduler(first)
+
+	_, release, err := acquireTransfer(context.Background(), ClassUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := NewScheduler(1)
+	SetDefaultScheduler(second)
+	if CurrentScheduler() != second {
+		t.Fatal("CurrentScheduler did not return the scheduler just set")
+	}
+	release()
+	if first.InFlight() != 0 || second.InFlight() != 0 {
+		t.Errorf("InFlight = %d, %d; the slot should go back to the scheduler it came from", first.InFlight(), second.InFlight())
+	}
+
+	SetDefaultScheduler(nil)
+	if _, release, err = acquireTransfer(context.Background(), ClassUpload); err != nil {
+		t.Fatalf("no scheduler: %v", err)
+	}
+	release()
+}
+
+func TestScheduler_PipeLocationOnlySourceHoldsOneSlot(t *testing.T) {
+	orig := CurrentScheduler()
+	SetDefaultScheduler(NewScheduler(1))
+	defer SetDefaultScheduler(orig)
+
+	mock := &mockS3Client{
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("remote"))}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	src := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k"}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out := t.TempDir() + "/out"
+	if _, err := Pipe(ctx, src, &PathSink{Path: out}); err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "remote" {
+		t.Errorf("sink got %q", got)
+	}
+	if CurrentScheduler().InFlight() != 0 {
+		t.Errorf("InFlight = %d after Pipe, want 0", CurrentScheduler().InFlight())
+	}
+}
+
+func TestScheduler_ContextPriorityOrdersOperations(t *testing.T) {
+	orig := CurrentScheduler()
+	SetDefaultScheduler(NewScheduler(1))
+	defer SetDefaultScheduler(orig)
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	mock := &mockS3Client{
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			mu.Lock()
+			order = append(order, "download")
+			mu.Unlock()
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("x"))}, nil
+		},
+		putObjectFn: func(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			mu.Lock()
+			order = append(order, "upload")
+			mu.Unlock()
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	release := mustAcquire(t, CurrentScheduler(), PriorityInteractive, ClassDownload)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wait := func(depth int) {
+		deadline := time.Now().Add(time.Second)
+		for CurrentScheduler().QueueDepth() < depth {
+			if time.Now().After(deadline) {
+				t.Fatal("operation never queued")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	f, _ := NewFromBytes([]byte("data"))
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := f.UploadToS3WithContext(ContextWithPriority(ctx, PriorityBatch), "bucket", "k"); err != nil {
+			t.Errorf("UploadToS3: %v", err)
+		}
+	}()
+	wait(1)
+	go func() {
+		defer wg.Done()
+		if _, err := NewFromS3WithContext(ctx, "bucket", "k"); err != nil {
+			t.Errorf("NewFromS3: %v", err)
+		}
+	}()
+	wait(2)
+	release()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "download" {
+		t.Errorf("order = %v; the interactive download should overtake the batch upload", order)
+	}
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// AsReadSeekCloser returns the file's content as an io.ReadSeekCloser, the
+// body type AWS SDK clients such as the S3 Transfer Manager, Textract and
+// Rekognition accept, without copying it. The caller must close it.
+//
+// Buffered content is served from the buffer, a NewFromReaderAt File
+// through a section view of its ReaderAt, and a lazy local file
+// (NewFromFileLazy) by opening the file. Content that cannot be seeked in
+// place (a lazy stream, or an S3 or Storage File not yet loaded) is first
+// buffered with Read. Directories fail with ErrInvalidSource.
+func (f *File) AsReadSeekCloser() (io.ReadSeekCloser, error) {
+	rsc, _, err := f.readSeekCloser("AsReadSeekCloser")
+	return rsc, err
+}
+
+// readSeekCloser returns a seekable reader over the content and its length,
+// reporting errors under op.
+func (f *File) readSeekCloser(op string) (io.ReadSeekCloser, int64, error) {
+	h, err := f.openHandle(context.Background(), op)
+	if err != nil {
+		return nil, 0, err
+	}
+	return h, h.Size(), nil
+}
+
+// AsS3PutInput returns the PutObjectInput UploadToS3 would send for bucket
+// and key, for callers who invoke the SDK themselves: the body from
+// AsReadSeekCloser, its ContentLength, and the ContentType,
+// Content-Disposition and user metadata from the file's metadata. The body
+// is an io.ReadSeekCloser; close it once the request is done. Bucket and
+// key are validated as UploadToS3 validates them.
+func (f *File) AsS3PutInput(bucket, key string) (*s3.PutObjectInput, error) {
+	key, err := checkS3Object("AsS3PutInput", bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	input, err := s3PutInput("AsS3PutInput", bucket, key, f.meta)
+	if err != nil {
+		return nil, err
+	}
+	body, size, err := f.readSeekCloser("AsS3PutInput")
+	if err != nil {
+		return nil, err
+	}
+	input.Body = body
+	input.ContentLength = aws.Int64(size)
+	return input, nil
+}
+
+// s3PutInput returns a PutObjectInput for bucket and key carrying meta's
+// content headers, its Attributes as user metadata and its Tags as the tag
+// set, without a body. It fails with ErrMetadataTooLarge when the user
+// metadata would not fit, and with ErrInvalidArgument when the tags break
+// S3's limits.
+func s3PutInput(op, bucket, key string, meta Metadata) (*s3.PutObjectInput, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: nilIfEmpty(meta.MimeType),
+		Metadata:    encodeS3UserMetadata(meta.Attributes),
+	}
+	if err := checkS3UserMetadataSize(op, input.Metadata); err != nil {
+		return nil, err
+	}
+	if meta.Name != "" {
+		input.ContentDisposition = aws.String(BuildContentDisposition("attachment", meta.Name))
+	}
+	if len(meta.Tags) > 0 {
+		if err := checkS3Tags(op, meta.Tags); err != nil {
+			return nil, err
+		}
+		input.Tagging = aws.String(encodeTagging(meta.Tags))
+	}
+	return input, nil
+}
+
+// ApplyS3GetOutput folds a GetObject response, fetched with the SDK
+// directly, into the file: the body is read, closed and buffered as the
+// new content, and the metadata is resolved from the response as
+// NewFromS3 resolves it. The file must be S3-sourced, naming the object
+// out was fetched for; its Attributes and CreatedAt are kept.
+func (f *File) ApplyS3GetOutput(out *s3.GetObjectOutput) error {
+	if err := f.checkWritable("ApplyS3GetOutput"); err != nil {
+		return err
+	}
+	bucket, key, ok := f.s3Location()
+	if !ok {
+		return newError(ErrInvalidSource, "ApplyS3GetOutput", errors.New("file is not S3-sourced"))
+	}
+	if out == nil || out.Body == nil {
+		return newError(ErrInvalidArgument, "ApplyS3GetOutput", errors.New("response has no body"))
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return newError(ErrRead, "ApplyS3GetOutput", err)
+	}
+
+	nf := fileFromS3Output(bucket, key, out, data, MetadataHint{
+		Attributes: f.meta.Attributes,
+		CreatedAt:  f.meta.CreatedAt,
+	})
+	nf.provenance = f.provenance
+	if f.source != SourceS3 {
+		withProvenance(nf)
+	}
+	*f = *nf
+	return nil
+}
@@ -0,0 +1,167 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestAsS3PutInput_MatchesUploadToS3(t *testing.T) {
+	payload := []byte("name,qty\nwidget,3\n")
+	hint := MetadataHint{Name: "report (final).csv", MimeType: "text/csv"}
+	p := filepath.Join(t.TempDir(), "report (final).csv")
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func() (*File, error){
+		"buffered": func() (*File, error) { return NewFromBytes(payload, hint) },
+		"lazy file": func() (*File, error) {
+			return NewFromFileLazy(p, MetadataHint{MimeType: "text/csv"})
+		},
+		"reader at": func() (*File, error) {
+			return NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)), hint)
+		},
+		"lazy stream": func() (*File, error) {
+			return NewFromStreamLazy(bytes.NewReader(payload), hint)
+		},
+	}
+	for name, newFile := range cases {
+		t.Run(name, func(t *testing.T) {
+			var sent *s3.PutObjectInput
+			var sentBody []byte
+			defer setMockS3(&mockS3Client{
+				putObjectFn: func(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+					sent = in
+					sentBody, _ = io.ReadAll(in.Body)
+					return &s3.PutObjectOutput{}, nil
+				},
+			}, &mockPresignClient{})()
+
+			f, err := newFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.UploadToS3("bucket", "reports/r.csv"); err != nil {
+				t.Fatalf("UploadToS3: %v", err)
+			}
+
+			f, _ = newFile()
+			got, err := f.AsS3PutInput("bucket", "reports/r.csv")
+			if err != nil {
+				t.Fatalf("AsS3PutInput: %v", err)
+			}
+			body, err := io.ReadAll(got.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Body.(io.Closer).Close()
+
+			if !bytes.Equal(body, sentBody) {
+				t.Errorf("body = %q, want %q", body, sentBody)
+			}
+			for field, pair := range map[string][2]*string{
+				"Bucket":             {got.Bucket, sent.Bucket},
+				"Key":                {got.Key, sent.Key},
+				"ContentType":        {got.ContentType, sent.ContentType},
+				"ContentDisposition": {got.ContentDisposition, sent.ContentDisposition},
+			} {
+				if aws.ToString(pair[0]) != aws.ToString(pair[1]) {
+					t.Errorf("%s = %q, UploadToS3 sent %q", field, aws.ToString(pair[0]), aws.ToString(pair[1]))
+				}
+			}
+			if aws.ToInt64(got.ContentLength) != aws.ToInt64(sent.ContentLength) {
+				t.Errorf("ContentLength = %d, UploadToS3 sent %d", aws.ToInt64(got.ContentLength), aws.ToInt64(sent.ContentLength))
+			}
+		})
+	}
+}
+
+func TestAsS3PutInput_Errors(t *testing.T) {
+	f, _ := NewFromBytes([]byte("x"))
+	if _, err := f.AsS3PutInput("Bad_Bucket", "k"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("invalid bucket: err = %v, want ErrInvalidArgument", err)
+	}
+	dir, _ := NewFromFile(t.TempDir())
+	if _, err := dir.AsS3PutInput("bucket", "k"); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("directory: err = %v, want ErrInvalidSource", err)
+	}
+}
+
+func TestAsReadSeekCloser(t *testing.T) {
+	payload := []byte("0123456789")
+	p := filepath.Join(t.TempDir(), "digits.txt")
+	if err := os.WriteFile(p, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffered, _ := NewFromBytes(payload)
+	lazy, _ := NewFromFileLazy(p)
+	at, _ := NewFromReaderAt(bytes.NewReader(payload), int64(len(payload)))
+
+	for name, f := range map[string]*File{"buffered": buffered, "lazy file": lazy, "reader at": at} {
+		rsc, err := f.AsReadSeekCloser()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := rsc.Seek(6, io.SeekStart); err != nil {
+			t.Fatalf("%s: Seek: %v", name, err)
+		}
+		rest, _ := io.ReadAll(rsc)
+		if string(rest) != "6789" {
+			t.Errorf("%s: read after Seek = %q", name, rest)
+		}
+		if err := rsc.Close(); err != nil {
+			t.Errorf("%s: Close: %v", name, err)
+		}
+		if f.Loaded() != (name == "buffered") {
+			t.Errorf("%s: AsReadSeekCloser should not buffer the content", name)
+		}
+	}
+}
+
+func TestApplyS3GetOutput(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "docs/a.json",
+		meta: Metadata{Name: "a.json", CreatedAt: created, Attributes: map[string]string{"team": "core"}}}
+	modified := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	err := f.ApplyS3GetOutput(&s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader(`{"ok":true}`)),
+		ContentType:   aws.String("application/json"),
+		ContentLength: aws.Int64(11),
+		ETag:          aws.String(`"abc"`),
+		LastModified:  aws.Time(modified),
+		VersionId:     aws.String("v2"),
+	})
+	if err != nil {
+		t.Fatalf("ApplyS3GetOutput: %v", err)
+	}
+	if data, _ := f.Read(); string(data) != `{"ok":true}` {
+		t.Errorf("content = %q", data)
+	}
+	m := f.Metadata()
+	if m.MimeType != "application/json" || m.Size != 11 || m.Hash != "abc" || m.VersionID != "v2" ||
+		!m.LastModified.Equal(modified) || m.URL != "s3://bucket/docs/a.json" {
+		t.Errorf("metadata = %+v", m)
+	}
+	if !m.CreatedAt.Equal(created) || m.Attributes["team"] != "core" {
+		t.Errorf("CreatedAt and Attributes should be kept: %+v", m)
+	}
+
+	local, _ := NewFromBytes([]byte("x"))
+	if err := local.ApplyS3GetOutput(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("y"))}); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("local file: err = %v, want ErrInvalidSource", err)
+	}
+	if err := f.ApplyS3GetOutput(&s3.GetObjectOutput{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("no body: err = %v, want ErrInvalidArgument", err)
+	}
+}
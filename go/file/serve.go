@@ -0,0 +1,40 @@
+package file
+
+import (
+	"net/http"
+)
+
+// WriteHTTPResponse writes f as the response to r, for proxying content
+// (say, from S3) to a browser. Content-Type comes from MimeType
+// (application/octet-stream when empty), Content-Disposition names the
+// file as an attachment, with an RFC 6266 filename* parameter for
+// non-ASCII names, as BuildContentDisposition formats it, and ETag and
+// Last-Modified are set from Hash and LastModified when known. The body is served with
+// http.ServeContent, so Range requests get 206 responses and conditional
+// requests get 304s.
+//
+// Content that cannot be seeked in place is first buffered with Read, as
+// AsReadSeekCloser describes. An error getting the content is returned
+// before anything is written, leaving the response to the caller.
+func (f *File) WriteHTTPResponse(w http.ResponseWriter, r *http.Request) error {
+	body, _, err := f.readSeekCloser("WriteHTTPResponse")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	h := w.Header()
+	ct := f.meta.MimeType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	h.Set("Content-Type", ct)
+	if f.meta.Name != "" {
+		h.Set("Content-Disposition", BuildContentDisposition("attachment", f.meta.Name))
+	}
+	if f.meta.Hash != "" {
+		h.Set("ETag", quoteETag(f.meta.Hash))
+	}
+	http.ServeContent(w, r, "", f.meta.LastModified, body)
+	return nil
+}
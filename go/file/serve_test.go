@@ -0,0 +1,102 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteHTTPResponse_Headers(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f, _ := NewFromBytes([]byte("a,b\n1,2\n"), MetadataHint{
+		Name: "résumé 2024.csv", MimeType: "text/csv", Hash: "abc123", LastModified: modified,
+	})
+	rec := httptest.NewRecorder()
+	if err := f.WriteHTTPResponse(rec, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+	res := rec.Result()
+	for header, want := range map[string]string{
+		"Content-Type":        "text/csv",
+		"Content-Length":      "8",
+		"Content-Disposition": `attachment; filename="r_sum_ 2024.csv"; filename*=UTF-8''r%C3%A9sum%C3%A9%202024.csv`,
+		"ETag":                `"abc123"`,
+		"Last-Modified":       "Wed, 01 May 2024 12:00:00 GMT",
+	} {
+		if got := res.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if body, _ := io.ReadAll(res.Body); string(body) != "a,b\n1,2\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestWriteHTTPResponse_RangeAndConditional(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	p := filepath.Join(t.TempDir(), "digits.txt")
+	if err := os.WriteFile(p, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFromFileLazy(p, MetadataHint{Hash: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := f.WriteHTTPResponse(w, r); err != nil {
+			t.Errorf("WriteHTTPResponse: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "0123456789" {
+		t.Errorf("range: status %d body %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 10-19/1000" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match: status %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestWriteHTTPResponse_ErrorWritesNothing(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "gone.bin")
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := NewFromFileLazy(p)
+	os.Remove(p)
+
+	rec := httptest.NewRecorder()
+	err := f.WriteHTTPResponse(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrRead) {
+		t.Fatalf("err = %v, want a read failure", err)
+	}
+	if rec.Body.Len() != 0 || len(rec.Header()) != 0 {
+		t.Errorf("response written on error: headers %v, body %q", rec.Header(), rec.Body)
+	}
+}
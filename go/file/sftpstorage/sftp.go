@@ -0,0 +1,305 @@
+// Package sftpstorage is a file.Storage backend for sftp:// URIs.
+//
+// It is a separate module (github.com/SmooAI/file/go/file/sftpstorage) so
+// programs that never talk SFTP neither link the SSH stack nor carry it in
+// their module graph. Register it once at startup:
+//
+//	sftpstorage.Register(sftpstorage.Config{
+//		HostKeyCallback: hostKeys,
+//		Credentials: func(ctx context.Context, host, user string) (sftpstorage.Credentials, error) {
+//			return sftpstorage.Credentials{Signer: partnerKey}, nil
+//		},
+//	})
+//
+// after which file.NewFromStorage and (*file.File).UploadToStorage accept
+// URIs of the form sftp://user@host:port/absolute/path.
+package sftpstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// Scheme is the URI scheme this backend registers for.
+const Scheme = "sftp"
+
+// defaultPort is used when the URI has no port.
+const defaultPort = "22"
+
+// Credentials authenticates one SSH connection. Set Password, Signer, or
+// both; every one that is set is offered to the server.
+type Credentials struct {
+	Password string
+	Signer   ssh.Signer
+}
+
+// Config configures the backend.
+type Config struct {
+	// Credentials returns the credentials for user@host. Called for every
+	// connection, so it can fetch short-lived secrets. Required.
+	Credentials func(ctx context.Context, host, user string) (Credentials, error)
+
+	// HostKeyCallback verifies the server's host key, e.g. ssh.FixedHostKey
+	// or one built with golang.org/x/crypto/ssh/knownhosts. Required.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// DefaultUser is used for URIs without a user component.
+	DefaultUser string
+
+	// DialTimeout bounds TCP connect plus SSH handshake. Defaults to 30s.
+	DialTimeout time.Duration
+}
+
+// Storage implements file.Storage over SFTP. Each operation opens its own
+// SSH connection.
+type Storage struct {
+	cfg Config
+}
+
+// New returns a Storage using cfg.
+func New(cfg Config) *Storage {
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 30 * time.Second
+	}
+	return &Storage{cfg: cfg}
+}
+
+// Register creates a Storage from cfg and registers it for sftp:// URIs.
+func Register(cfg Config) *Storage {
+	s := New(cfg)
+	file.RegisterStorage(Scheme, s)
+	return s
+}
+
+// Get implements file.Storage.
+func (s *Storage) Get(ctx context.Context, uri string) (io.ReadCloser, file.Metadata, error) {
+	c, p, err := s.connect(ctx, uri)
+	if err != nil {
+		return nil, file.Metadata{}, err
+	}
+	f, err := c.Open(p)
+	if err != nil {
+		c.Close()
+		return nil, file.Metadata{}, wrap("Get", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		c.Close()
+		return nil, file.Metadata{}, wrap("Get", err)
+	}
+	return &remoteReader{File: f, conn: c}, metadataFor(uri, info), nil
+}
+
+// Put implements file.Storage. Missing parent directories are created.
+func (s *Storage) Put(ctx context.Context, uri string, r io.Reader, meta file.Metadata) error {
+	c, p, err := s.connect(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.MkdirAll(path.Dir(p)); err != nil {
+		return wrap("Put", err)
+	}
+	f, err := c.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return wrap("Put", err)
+	}
+	if _, err := f.ReadFrom(r); err != nil {
+		f.Close()
+		return wrap("Put", err)
+	}
+	return wrap("Put", f.Close())
+}
+
+// Stat implements file.Storage.
+func (s *Storage) Stat(ctx context.Context, uri string) (file.Metadata, error) {
+	c, p, err := s.connect(ctx, uri)
+	if err != nil {
+		return file.Metadata{}, err
+	}
+	defer c.Close()
+
+	info, err := c.Stat(p)
+	if err != nil {
+		return file.Metadata{}, wrap("Stat", err)
+	}
+	return metadataFor(uri, info), nil
+}
+
+// List implements file.Storage. uri names a directory; the regular files
+// directly inside it are returned, sorted by name.
+func (s *Storage) List(ctx context.Context, uri string) ([]file.Metadata, error) {
+	c, p, err := s.connect(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	entries, err := c.ReadDir(p)
+	if err != nil {
+		return nil, wrap("List", err)
+	}
+	base, _ := url.Parse(uri)
+	out := make([]file.Metadata, 0, len(entries))
+	for _, info := range entries {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		child := *base
+		child.Path = path.Join(p, info.Name())
+		out = append(out, metadataFor(child.String(), info))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Delete implements file.Storage.
+func (s *Storage) Delete(ctx context.Context, uri string) error {
+	c, p, err := s.connect(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return wrap("Delete", c.Remove(p))
+}
+
+// conn is an SFTP client together with the SSH connection under it.
+type conn struct {
+	*sftp.Client
+	ssh  *ssh.Client
+	stop func() bool
+}
+
+// Close closes the SFTP session and the SSH connection.
+func (c *conn) Close() error {
+	c.stop()
+	err := c.Client.Close()
+	if sshErr := c.ssh.Close(); err == nil {
+		err = sshErr
+	}
+	return err
+}
+
+// connect dials the host named in uri and returns a client plus the remote
+// path. The connection is torn down if ctx is cancelled while it is open.
+func (s *Storage) connect(ctx context.Context, uri string) (*conn, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", &file.FileError{Sentinel: file.ErrInvalidSource, Op: "sftp", Err: err}
+	}
+	if u.Scheme != Scheme || u.Hostname() == "" || u.Path == "" {
+		return nil, "", &file.FileError{Sentinel: file.ErrInvalidSource, Op: "sftp", Err: fmt.Errorf("want sftp://[user@]host[:port]/path, got %q", uri)}
+	}
+	if s.cfg.Credentials == nil || s.cfg.HostKeyCallback == nil {
+		return nil, "", &file.FileError{Sentinel: file.ErrInvalidSource, Op: "sftp", Err: errors.New("Config.Credentials and Config.HostKeyCallback are required")}
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	user := u.User.Username()
+	if user == "" {
+		user = s.cfg.DefaultUser
+	}
+
+	creds, err := s.cfg.Credentials(ctx, host, user)
+	if err != nil {
+		return nil, "", &file.FileError{Sentinel: file.ErrRead, Op: "sftp", Err: err}
+	}
+	var auth []ssh.AuthMethod
+	if creds.Signer != nil {
+		auth = append(auth, ssh.PublicKeys(creds.Signer))
+	}
+	if creds.Password != "" {
+		auth = append(auth, ssh.Password(creds.Password))
+	}
+
+	addr := net.JoinHostPort(host, port)
+	dialCtx, cancel := context.WithTimeout(ctx, s.cfg.DialTimeout)
+	defer cancel()
+	var d net.Dialer
+	nc, err := d.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, "", &file.FileError{Sentinel: file.ErrRead, Op: "sftp", Err: err}
+	}
+	if dl, ok := dialCtx.Deadline(); ok {
+		_ = nc.SetDeadline(dl)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(nc, addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: s.cfg.HostKeyCallback,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, "", &file.FileError{Sentinel: file.ErrRead, Op: "sftp", Err: err}
+	}
+	_ = nc.SetDeadline(time.Time{})
+	sc := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(sc)
+	if err != nil {
+		sc.Close()
+		return nil, "", &file.FileError{Sentinel: file.ErrRead, Op: "sftp", Err: err}
+	}
+	stop := context.AfterFunc(ctx, func() { sc.Close() })
+	return &conn{Client: client, ssh: sc, stop: stop}, u.Path, nil
+}
+
+// remoteReader closes the connection along with the remote file.
+type remoteReader struct {
+	*sftp.File
+	conn *conn
+}
+
+// Close closes the remote file and its connection.
+func (r *remoteReader) Close() error {
+	err := r.File.Close()
+	if cErr := r.conn.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// metadataFor converts a remote stat into file.Metadata.
+func metadataFor(uri string, info os.FileInfo) file.Metadata {
+	return file.Metadata{
+		Name:         info.Name(),
+		Size:         info.Size(),
+		LastModified: info.ModTime().UTC(),
+		URL:          uri,
+	}
+}
+
+// wrap maps a missing remote path to file.ErrNotFound, and other failures
+// to file.ErrWrite for mutations or file.ErrRead otherwise.
+func wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	sentinel := file.ErrRead
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		sentinel = file.ErrNotFound
+	case op == "Put" || op == "Delete":
+		sentinel = file.ErrWrite
+	}
+	return &file.FileError{Sentinel: sentinel, Op: "sftp." + op, Err: err}
+}
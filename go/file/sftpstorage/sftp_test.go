@@ -0,0 +1,203 @@
+package sftpstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+const (
+	testUser     = "partner"
+	testPassword = "s3cret"
+)
+
+// startServer runs an in-process SSH server with the sftp subsystem and
+// returns its address and host key.
+func startServer(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == testUser && string(pass) == testPassword {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("denied")
+		},
+	}
+	cfg.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(nc, cfg)
+		}
+	}()
+	return ln.Addr().String(), hostKey.PublicKey()
+}
+
+func serveConn(nc net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					srv, err := sftp.NewServer(ch)
+					if err == nil {
+						srv.Serve()
+					}
+					ch.Close()
+				}
+			}
+		}()
+	}
+}
+
+func newTestStorage(addr string, hostKey ssh.PublicKey, password string) *Storage {
+	return New(Config{
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		DefaultUser:     testUser,
+		Credentials: func(ctx context.Context, host, user string) (Credentials, error) {
+			return Credentials{Password: password}, nil
+		},
+	})
+}
+
+func TestStorage_RoundTripThroughFilePackage(t *testing.T) {
+	addr, hostKey := startServer(t)
+	file.RegisterStorage(Scheme, newTestStorage(addr, hostKey, testPassword))
+	defer file.RegisterStorage(Scheme, nil)
+
+	dir := t.TempDir()
+	uri := fmt.Sprintf("sftp://%s@%s%s/inbox/report.csv", testUser, addr, filepath.ToSlash(dir))
+	content := []byte("id,total\n1,10\n2,20\n")
+
+	src, _ := file.NewFromBytes(content, file.MetadataHint{Name: "report.csv"})
+	if err := src.UploadToStorage(context.Background(), uri); err != nil {
+		t.Fatalf("UploadToStorage: %v", err)
+	}
+	onDisk, err := os.ReadFile(filepath.Join(dir, "inbox", "report.csv"))
+	if err != nil || !bytes.Equal(onDisk, content) {
+		t.Fatalf("remote file = %q, %v", onDisk, err)
+	}
+
+	mtime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "inbox", "report.csv"), mtime, mtime)
+
+	f, err := file.NewFromStorage(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("NewFromStorage: %v", err)
+	}
+	got, _ := f.Read()
+	if !bytes.Equal(got, content) {
+		t.Errorf("content = %q", got)
+	}
+	if f.Name() != "report.csv" || f.Size() != int64(len(content)) || f.MimeType() != "text/csv" {
+		t.Errorf("metadata = %+v", f.Metadata())
+	}
+	if !f.LastModified().Equal(mtime) {
+		t.Errorf("LastModified = %s, want %s", f.LastModified(), mtime)
+	}
+}
+
+func TestStorage_StatListDelete(t *testing.T) {
+	addr, hostKey := startServer(t)
+	s := newTestStorage(addr, hostKey, testPassword)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("bbbbb"), 0o644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	base := fmt.Sprintf("sftp://%s%s", addr, filepath.ToSlash(dir))
+
+	meta, err := s.Stat(ctx, base+"/b.txt")
+	if err != nil || meta.Size != 5 || meta.Name != "b.txt" {
+		t.Fatalf("Stat = %+v, %v", meta, err)
+	}
+
+	list, err := s.List(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].URL != base+"/a.txt" || list[1].Size != 5 {
+		t.Errorf("List = %+v", list)
+	}
+
+	if err := s.Delete(ctx, base+"/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Stat(ctx, base+"/a.txt")
+	if !errors.Is(err, file.ErrNotFound) {
+		t.Errorf("Stat after delete: expected ErrNotFound, got %v", err)
+	}
+	rc, _, err := s.Get(ctx, base+"/a.txt")
+	if !errors.Is(err, file.ErrNotFound) {
+		if rc != nil {
+			io.Copy(io.Discard, rc)
+			rc.Close()
+		}
+		t.Errorf("Get after delete: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStorage_BadCredentials(t *testing.T) {
+	addr, hostKey := startServer(t)
+	s := newTestStorage(addr, hostKey, "wrong")
+	_, err := s.Stat(context.Background(), fmt.Sprintf("sftp://%s/tmp/x", addr))
+	if !errors.Is(err, file.ErrRead) {
+		t.Errorf("expected ErrRead, got %v", err)
+	}
+}
+
+func TestStorage_InvalidURI(t *testing.T) {
+	s := New(Config{})
+	_, err := s.Stat(context.Background(), "sftp://host-without-path")
+	if !errors.Is(err, file.ErrInvalidSource) {
+		t.Errorf("expected ErrInvalidSource, got %v", err)
+	}
+}
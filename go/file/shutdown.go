@@ -0,0 +1,82 @@
+package file
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlight tracks the transfers Shutdown waits for.
+var inFlight = newTransferSet()
+
+// transferSet is the set of running transfers, each with a cancel func
+// Shutdown can use to force it to stop.
+type transferSet struct {
+	mu      sync.Mutex
+	closing bool
+	next    uint64
+	active  map[uint64]context.CancelCauseFunc
+	wg      sync.WaitGroup
+}
+
+func newTransferSet() *transferSet {
+	return &transferSet{active: map[uint64]context.CancelCauseFunc{}}
+}
+
+// track adds a transfer running under ctx. It returns the context the
+// transfer must use and a func, safe to call more than once, that removes
+// it again. Once Shutdown has been called it fails with ErrShuttingDown.
+func (s *transferSet) track(ctx context.Context) (context.Context, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return ctx, nil, ErrShuttingDown
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	id := s.next
+	s.next++
+	s.active[id] = cancel
+	s.wg.Add(1)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.active, id)
+			s.mu.Unlock()
+			cancel(nil)
+			s.wg.Done()
+		})
+	}, nil
+}
+
+// Shutdown stops the package from starting new transfers and waits for
+// those in flight to finish: every download, upload and Pipe started
+// after it is called fails with ErrShuttingDown. When ctx ends first, the
+// remaining transfers are cancelled, failing with ErrShuttingDown, and
+// Shutdown returns an ErrShuttingDown error wrapping ctx's error without
+// waiting for them to unwind. Calling it again waits again. Operations
+// that never reach the network or disk through a transfer (metadata
+// accessors, local reads of buffered content) are unaffected.
+func Shutdown(ctx context.Context) error {
+	s := inFlight
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	for _, cancel := range s.active {
+		cancel(ErrShuttingDown)
+	}
+	s.mu.Unlock()
+	return newError(ErrShuttingDown, "Shutdown", ctx.Err())
+}
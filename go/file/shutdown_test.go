@@ -0,0 +1,106 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// slowS3 installs a mock whose GetObject takes delay, or blocks until its
+// context ends when delay is zero.
+func slowS3(t *testing.T, delay time.Duration) {
+	t.Helper()
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			var wait <-chan time.Time
+			if delay > 0 {
+				wait = time.After(delay)
+			}
+			select {
+			case <-wait:
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("data")))}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	}, &mockPresignClient{})
+	t.Cleanup(cleanup)
+	t.Cleanup(func() { inFlight = newTransferSet() })
+}
+
+// startTransfers runs n NewFromS3 calls and waits until all are in flight.
+func startTransfers(t *testing.T, n int) (errs []error, wait func()) {
+	t.Helper()
+	errs = make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = NewFromS3("bucket", "k")
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		inFlight.mu.Lock()
+		running := len(inFlight.active)
+		inFlight.mu.Unlock()
+		if running == n {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d transfers started", running, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return errs, wg.Wait
+}
+
+func TestShutdown_DrainsInFlight(t *testing.T) {
+	slowS3(t, 50*time.Millisecond)
+	errs, wait := startTransfers(t, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("transfer %d: %v", i, err)
+		}
+	}
+
+	if _, err := NewFromS3("bucket", "k"); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("NewFromS3 after Shutdown: err = %v, want ErrShuttingDown", err)
+	}
+	f, _ := NewFromBytes([]byte("x"))
+	if err := f.UploadToS3("bucket", "k"); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("UploadToS3 after Shutdown: err = %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestShutdown_ForceCancelsAtDeadline(t *testing.T) {
+	slowS3(t, 0)
+	errs, wait := startTransfers(t, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := Shutdown(ctx)
+	if !errors.Is(err, ErrShuttingDown) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want ErrShuttingDown wrapping DeadlineExceeded", err)
+	}
+	wait()
+	for i, err := range errs {
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("transfer %d: err = %v, want ErrShuttingDown", i, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package file
+
+import (
+	"errors"
+	"mime"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// SignOption configures GetSignedURL.
+type SignOption func(*signOptions)
+
+func (SignOption) isOption() {}
+
+// signOptions is the resolved set of SignOption values.
+type signOptions struct {
+	optionErrors
+	disposition string
+	contentType string
+}
+
+// newSignOptions applies opts over the defaults.
+func newSignOptions(opts []SignOption) signOptions {
+	var o signOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// validate reports rejected values.
+func (o signOptions) validate() error {
+	return errors.Join(o.errs...)
+}
+
+// overrides reports whether any response header is overridden.
+func (o signOptions) overrides() bool {
+	return o.disposition != "" || o.contentType != ""
+}
+
+// applyToGetInput sets the response header overrides on input.
+func (o signOptions) applyToGetInput(input *s3.GetObjectInput) {
+	input.ResponseContentDisposition = nilIfEmpty(o.disposition)
+	input.ResponseContentType = nilIfEmpty(o.contentType)
+}
+
+// WithDownloadFilename makes a signed URL serve the object as an
+// attachment named name, whatever Content-Disposition it was stored with.
+// Names outside ASCII are encoded as BuildContentDisposition encodes them,
+// with an RFC 5987 filename* parameter. An empty name is rejected.
+func WithDownloadFilename(name string) SignOption {
+	return func(o *signOptions) {
+		if name == "" {
+			o.reject("WithDownloadFilename", "empty filename")
+			return
+		}
+		o.disposition = BuildContentDisposition("attachment", name)
+	}
+}
+
+// WithResponseContentType makes a signed URL serve the object with
+// Content-Type mimeType, whatever it was stored with. A value that is not
+// a valid media type is rejected.
+func WithResponseContentType(mimeType string) SignOption {
+	return func(o *signOptions) {
+		mt, _, err := mime.ParseMediaType(mimeType)
+		if err == nil && !strings.Contains(mt, "/") {
+			err = errors.New("no subtype")
+		}
+		if err != nil {
+			o.reject("WithResponseContentType", "invalid media type %q: %v", mimeType, err)
+			return
+		}
+		o.contentType = mimeType
+	}
+}
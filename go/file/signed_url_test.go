@@ -0,0 +1,80 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// capturePresignGet returns a presign mock that records the last
+// GetObjectInput it signed.
+func capturePresignGet(captured **s3.GetObjectInput) *mockPresignClient {
+	return &mockPresignClient{
+		presignGetObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+			*captured = params
+			return &v4.PresignedHTTPRequest{URL: "https://signed.example/", Method: "GET"}, nil
+		},
+	}
+}
+
+func TestGetSignedURL_ResponseOverrides(t *testing.T) {
+	var input *s3.GetObjectInput
+	defer setMockS3(&mockS3Client{}, capturePresignGet(&input))()
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "exports/q3-7f2a.bin"}
+
+	if _, err := f.GetSignedURL(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if input.ResponseContentDisposition != nil || input.ResponseContentType != nil {
+		t.Errorf("overrides set without options: %+v", input)
+	}
+
+	for name, tc := range map[string]struct {
+		opts            []SignOption
+		wantDisposition string
+		wantType        string
+	}{
+		"ASCII filename": {
+			[]SignOption{WithDownloadFilename("Q3 Report.pdf"), WithResponseContentType("application/pdf")},
+			`attachment; filename="Q3 Report.pdf"`, "application/pdf",
+		},
+		"non-ASCII filename": {
+			[]SignOption{WithDownloadFilename("Résumé €.pdf")},
+			`attachment; filename="R_sum_ _.pdf"; filename*=UTF-8''R%C3%A9sum%C3%A9%20%E2%82%AC.pdf`, "",
+		},
+	} {
+		if _, err := f.GetSignedURL(time.Hour, tc.opts...); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := aws.ToString(input.ResponseContentDisposition); got != tc.wantDisposition {
+			t.Errorf("%s: ResponseContentDisposition = %s, want %s", name, got, tc.wantDisposition)
+		}
+		if got := aws.ToString(input.ResponseContentType); got != tc.wantType {
+			t.Errorf("%s: ResponseContentType = %s, want %s", name, got, tc.wantType)
+		}
+	}
+}
+
+func TestGetSignedURL_OverrideErrors(t *testing.T) {
+	var input *s3.GetObjectInput
+	defer setMockS3(&mockS3Client{}, capturePresignGet(&input))()
+	f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k"}
+	for name, opt := range map[string]SignOption{
+		"empty filename":     WithDownloadFilename(""),
+		"invalid media type": WithResponseContentType("pdf"),
+	} {
+		if _, err := f.GetSignedURL(time.Hour, opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: err = %v, want ErrInvalidOption", name, err)
+		}
+	}
+
+	stored := &File{source: SourceStorage, meta: Metadata{URL: "mem://bucket/k"}}
+	if _, err := stored.GetSignedURL(time.Hour, WithDownloadFilename("a.txt")); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("storage-sourced: err = %v, want ErrInvalidSource", err)
+	}
+}
@@ -0,0 +1,37 @@
+package file
+
+import "io"
+
+// MemoryFootprint returns the number of content bytes this File currently
+// holds in memory: the full buffer once loaded, the buffered head of a lazy
+// stream, and 0 for ReaderAt-backed files that have not been Read. Unlike
+// Size it changes as content is buffered or released.
+func (f *File) MemoryFootprint() int64 {
+	switch {
+	case f.loaded:
+		return int64(len(f.data))
+	case f.lazy:
+		return int64(len(f.streamHead))
+	default:
+		return 0
+	}
+}
+
+// TransferSize returns the number of bytes that crossed the wire in the most
+// recent download or upload of this File — after content encoding, so a
+// gzip-encoded HTTP response reports its compressed length while Size
+// reports the decoded content. It is 0 for a File that has not been
+// transferred. Prefer it over Size when reporting network metrics.
+func (f *File) TransferSize() int64 { return f.transferSize }
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,99 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestSizeAccounting_GzipEncodedDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("compressible text "), 1000)
+	var encoded bytes.Buffer
+	zw := gzip.NewWriter(&encoded)
+	zw.Write(content)
+	zw.Close()
+
+	var gotAcceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAcceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(encoded.Len()))
+		w.Write(encoded.Bytes())
+	}))
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	f, err := NewFromURL(srv.URL + "/big.txt")
+	if err != nil {
+		t.Fatalf("NewFromURL: %v", err)
+	}
+	if gotAcceptEncoding != "gzip" {
+		t.Errorf("Accept-Encoding = %q", gotAcceptEncoding)
+	}
+	got, _ := f.Read()
+	if !bytes.Equal(got, content) {
+		t.Fatal("content was not decoded")
+	}
+	if f.Size() != int64(len(content)) {
+		t.Errorf("Size = %d, want decoded %d", f.Size(), len(content))
+	}
+	if f.TransferSize() != int64(encoded.Len()) {
+		t.Errorf("TransferSize = %d, want encoded %d", f.TransferSize(), encoded.Len())
+	}
+	if f.MemoryFootprint() != int64(len(content)) {
+		t.Errorf("MemoryFootprint = %d, want %d", f.MemoryFootprint(), len(content))
+	}
+}
+
+func TestSizeAccounting_LazyStreamUpload(t *testing.T) {
+	total := 3 * streamHeadBytes
+	f, err := NewFromStreamLazy(bytes.NewReader(generateRandomBytes(t, total)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.MemoryFootprint() != streamHeadBytes {
+		t.Errorf("lazy MemoryFootprint = %d, want head %d", f.MemoryFootprint(), streamHeadBytes)
+	}
+	if f.TransferSize() != 0 {
+		t.Errorf("TransferSize before upload = %d", f.TransferSize())
+	}
+
+	cleanup := setMockS3(&mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			io.Copy(io.Discard, params.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	if err := f.UploadToS3("bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if f.TransferSize() != int64(total) || f.Size() != int64(total) {
+		t.Errorf("after upload Size = %d, TransferSize = %d, want %d", f.Size(), f.TransferSize(), total)
+	}
+	if f.MemoryFootprint() != 0 {
+		t.Errorf("after spooled upload MemoryFootprint = %d, want 0", f.MemoryFootprint())
+	}
+}
+
+func TestSizeAccounting_ReaderAt(t *testing.T) {
+	data := generateRandomBytes(t, 2*streamHeadBytes)
+	f, _ := NewFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	if f.Size() != int64(len(data)) || f.MemoryFootprint() != 0 {
+		t.Errorf("before Read: Size = %d, MemoryFootprint = %d", f.Size(), f.MemoryFootprint())
+	}
+	f.Read()
+	if f.MemoryFootprint() != int64(len(data)) {
+		t.Errorf("after Read MemoryFootprint = %d, want %d", f.MemoryFootprint(), len(data))
+	}
+}
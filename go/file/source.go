@@ -14,6 +14,11 @@ const (
 	SourceStream FileSource = "Stream"
 	// SourceS3 indicates the file was loaded from Amazon S3.
 	SourceS3 FileSource = "S3"
+	// SourceStorage indicates the file was loaded through a registered
+	// Storage backend (see RegisterStorage).
+	SourceStorage FileSource = "Storage"
+	// SourceFS indicates the file was loaded from an fs.FS (see NewFromFS).
+	SourceFS FileSource = "FS"
 )
 
 // String returns the string representation of a FileSource.
@@ -24,7 +29,7 @@ func (s FileSource) String() string {
 // Valid returns true if the FileSource is one of the known sources.
 func (s FileSource) Valid() bool {
 	switch s {
-	case SourceURL, SourceBytes, SourceFile, SourceStream, SourceS3:
+	case SourceURL, SourceBytes, SourceFile, SourceStream, SourceS3, SourceStorage, SourceFS:
 		return true
 	default:
 		return false
@@ -0,0 +1,271 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is a pluggable backend addressed by URI (sftp://host/path,
+// gs://bucket/key, ...). Backends live in their own subpackages so their
+// dependencies are only linked in when used, and register themselves for a
+// scheme with RegisterStorage. NewFromStorage and UploadToStorage then work
+// with any registered scheme.
+//
+// Metadata returned by a backend uses URL for the object's URI and fills
+// whichever other fields the backend knows (Size, LastModified, MimeType,
+// Hash, Attributes). A missing object is reported with an error matching
+// ErrNotFound under errors.Is.
+type Storage interface {
+	// Get opens the object for reading. The caller closes the reader.
+	Get(ctx context.Context, uri string) (io.ReadCloser, Metadata, error)
+	// Put writes r to the object, creating or replacing it. meta carries
+	// the content's name and MIME type for backends that store them.
+	Put(ctx context.Context, uri string, r io.Reader, meta Metadata) error
+	// Stat returns the object's metadata without reading its content.
+	Stat(ctx context.Context, uri string) (Metadata, error)
+	// List returns the metadata of the objects under uri.
+	List(ctx context.Context, uri string) ([]Metadata, error)
+	// Delete removes the object.
+	Delete(ctx context.Context, uri string) error
+}
+
+var (
+	storageMu       sync.RWMutex
+	storageBackends = map[string]Storage{}
+)
+
+// RegisterStorage makes s the backend for URIs with the given scheme
+// (without "://"), replacing any previous registration. Passing a nil s
+// removes the registration. Safe for concurrent use.
+func RegisterStorage(scheme string, s Storage) {
+	scheme = strings.ToLower(scheme)
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	if s == nil {
+		delete(storageBackends, scheme)
+		return
+	}
+	storageBackends[scheme] = s
+}
+
+// StorageFor returns the backend registered for uri's scheme.
+func StorageFor(uri string) (Storage, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, newError(ErrInvalidSource, "StorageFor", err)
+	}
+	storageMu.RLock()
+	s, ok := storageBackends[strings.ToLower(u.Scheme)]
+	storageMu.RUnlock()
+	if !ok {
+		return nil, newError(ErrInvalidSource, "StorageFor", fmt.Errorf("no storage backend registered for scheme %q", u.Scheme))
+	}
+	return s, nil
+}
+
+// NewFromStorage reads the object at uri through its registered backend.
+// Content is buffered eagerly, like NewFromS3, and metadata from the
+// backend is combined with hints and magic-byte detection the same way.
+func NewFromStorage(ctx context.Context, uri string, opts ...ReadOption) (*File, error) {
+	hint, ro := resolveHints(opts)
+	if err := checkOptions("NewFromStorage", ro.validate()); err != nil {
+		return nil, err
+	}
+
+	s, err := StorageFor(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, DownloadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrRead, "NewFromStorage", err)
+	}
+	defer release()
+
+	rc, remote, err := s.Get(ctx, uri)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrRead, "NewFromStorage", err)
+	}
+	defer rc.Close()
+
+	wire := &countingReader{r: rc}
+	data, err := io.ReadAll(wire)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrRead, "NewFromStorage", err)
+	}
+
+	return withProvenance(ro.normalizeName(&File{
+		source:       SourceStorage,
+		meta:         resolveMetadataFromStorage(uri, remote, data, hint),
+		data:         data,
+		loaded:       true,
+		transferSize: wire.n,
+	})), nil
+}
+
+// UploadToStorage writes the file to uri through its registered backend.
+// Content is streamed where the source allows (see Pipe). When ctx has no
+// deadline, UploadTimeout bounds each wait for the backend to take more of
+// the content or to finish, not the whole upload.
+func (f *File) UploadToStorage(ctx context.Context, uri string) error {
+	s, err := StorageFor(uri)
+	if err != nil {
+		return err
+	}
+	if err := checkNetwork(ctx, "UploadToStorage"); err != nil {
+		return err
+	}
+
+	ctx, cancel, dl, watch := withStreamTimeout(ctx, UploadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassUpload)
+	if err != nil {
+		return dl.newError(ctx, ErrWrite, "UploadToStorage", err)
+	}
+	defer release()
+
+	r, err := f.openStream(ctx)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	watch.kick()
+	sent := &countingReader{r: watch.pulled(r)}
+	if err := s.Put(ctx, uri, sent, f.meta.clone()); err != nil {
+		return dl.newError(ctx, ErrWrite, "UploadToStorage", err)
+	}
+	f.transferSize = sent.n
+	return nil
+}
+
+// SignedURLStorage is implemented by backends that can issue time-limited
+// download URLs. GetSignedURL uses it for Storage-sourced files.
+type SignedURLStorage interface {
+	Storage
+	SignedURL(ctx context.Context, uri string, expiresIn time.Duration) (string, error)
+}
+
+// storageSignedURL asks the file's backend for a signed URL.
+func (f *File) storageSignedURL(ctx context.Context, expiresIn time.Duration) (string, error) {
+	s, err := StorageFor(f.meta.URL)
+	if err != nil {
+		return "", err
+	}
+	signer, ok := s.(SignedURLStorage)
+	if !ok {
+		return "", newError(ErrInvalidSource, "GetSignedURL", fmt.Errorf("storage backend for %q cannot sign URLs", f.meta.URL))
+	}
+
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+
+	signed, err := signer.SignedURL(ctx, f.meta.URL, expiresIn)
+	if err != nil {
+		return "", dl.newError(ctx, ErrRead, "GetSignedURL", err)
+	}
+	return signed, nil
+}
+
+// New creates a File from any supported URI: http(s):// URLs
+// (NewFromURLWithContext), s3://bucket/key (NewFromS3WithContext), file://
+// URIs and plain paths (NewFromFile), data: URIs (NewFromDataURI), and any
+// scheme with a registered Storage backend (NewFromStorage).
+func New(ctx context.Context, uri string, opts ...ReadOption) (*File, error) {
+	if len(uri) >= 5 && strings.EqualFold(uri[:5], "data:") {
+		return NewFromDataURI(uri, opts...)
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Plain path (a one-letter "scheme" is a Windows drive letter).
+		return NewFromFile(uri, opts...)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return NewFromURLWithContext(ctx, uri, opts...)
+	case "s3":
+		bucket, key, ok := parseS3URI(uri)
+		if !ok {
+			return nil, newError(ErrInvalidSource, "New", fmt.Errorf("malformed S3 URI %q", uri))
+		}
+		return NewFromS3WithContext(ctx, bucket, key, opts...)
+	case "file":
+		return NewFromFile(u.Path, opts...)
+	default:
+		return NewFromStorage(ctx, uri, opts...)
+	}
+}
+
+// resolveMetadataFromStorage builds Metadata from backend metadata, data,
+// and hints, with the same fallbacks as the S3 path.
+func resolveMetadataFromStorage(uri string, remote Metadata, data []byte, hint MetadataHint) Metadata {
+	m := Metadata{}
+	applyHint(&m, hint)
+	m.noteOrigin(OriginHint)
+
+	if remote.Name != "" {
+		m.Name = remote.Name
+	} else if m.Name == "" {
+		if u, err := url.Parse(uri); err == nil && u.Path != "" {
+			m.Name = path.Base(u.Path)
+			m.noteOrigin(OriginURL)
+		}
+	}
+	if ct := declaredMimeType(remote.MimeType); ct != "" {
+		m.MimeType = ct
+	}
+	if remote.Size > 0 {
+		m.Size = remote.Size
+	}
+	if remote.Hash != "" {
+		m.Hash = remote.Hash
+	}
+	if !remote.LastModified.IsZero() {
+		m.LastModified = remote.LastModified
+	}
+	if !remote.CreatedAt.IsZero() {
+		m.CreatedAt = remote.CreatedAt
+	}
+	if remote.VersionID != "" {
+		m.VersionID = remote.VersionID
+	}
+	m.Attributes = mergeAttributes(m.Attributes, remote.Attributes)
+	m.URL = uri
+	m.noteOrigin(OriginStorage)
+
+	if m.Size == 0 {
+		m.Size = int64(len(data))
+		m.noteOrigin(OriginContent)
+	}
+	if m.MimeType == "" && m.Name != "" {
+		m.MimeType = MimeTypeFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
+	}
+	applyMagicDetection(&m, data)
+	m.noteOrigin(OriginMagicBytes)
+	if m.Extension == "" && m.MimeType != "" {
+		m.Extension = ExtensionFromMimeType(m.MimeType)
+		m.noteOrigin(OriginFallback)
+	}
+	if m.Extension == "" && m.Name != "" {
+		m.Extension = StrictExtensionFromFilename(m.Name)
+		m.noteOrigin(OriginFilename)
+	}
+	if m.MimeType == "" {
+		m.MimeType = octetStream
+		m.noteOrigin(OriginFallback)
+	}
+	return m
+}
@@ -0,0 +1,175 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStorage is an in-memory Storage backend for tests.
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]Metadata
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: map[string][]byte{}, meta: map[string]Metadata{}}
+}
+
+func (m *memStorage) Get(ctx context.Context, uri string) (io.ReadCloser, Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[uri]
+	if !ok {
+		return nil, Metadata{}, newError(ErrNotFound, "Get", fmt.Errorf("%s", uri))
+	}
+	return io.NopCloser(bytes.NewReader(data)), m.meta[uri], nil
+}
+
+func (m *memStorage) Put(ctx context.Context, uri string, r io.Reader, meta Metadata) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[uri] = data
+	m.meta[uri] = Metadata{URL: uri, MimeType: meta.MimeType, Size: int64(len(data))}
+	return nil
+}
+
+func (m *memStorage) Stat(ctx context.Context, uri string) (Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta, ok := m.meta[uri]
+	if !ok {
+		return Metadata{}, newError(ErrNotFound, "Stat", fmt.Errorf("%s", uri))
+	}
+	return meta, nil
+}
+
+func (m *memStorage) List(ctx context.Context, uri string) ([]Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Metadata
+	for k, meta := range m.meta {
+		if strings.HasPrefix(k, uri) {
+			out = append(out, meta)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStorage) Delete(ctx context.Context, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, uri)
+	delete(m.meta, uri)
+	return nil
+}
+
+func TestStorage_RoundTrip(t *testing.T) {
+	mem := newMemStorage()
+	RegisterStorage("mem", mem)
+	defer RegisterStorage("mem", nil)
+
+	src, _ := NewFromBytes([]byte(`{"k":"v"}`), MetadataHint{Name: "data.json"})
+	if err := src.UploadToStorage(context.Background(), "mem://bucket/dir/data.json"); err != nil {
+		t.Fatalf("UploadToStorage: %v", err)
+	}
+	if src.TransferSize() != 9 {
+		t.Errorf("TransferSize = %d", src.TransferSize())
+	}
+
+	mod := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mem.meta["mem://bucket/dir/data.json"] = Metadata{Size: 9, LastModified: mod, Attributes: map[string]string{"owner": "ops"}}
+
+	f, err := NewFromStorage(context.Background(), "mem://bucket/dir/data.json")
+	if err != nil {
+		t.Fatalf("NewFromStorage: %v", err)
+	}
+	if f.Source() != SourceStorage || f.URL() != "mem://bucket/dir/data.json" {
+		t.Errorf("source %s url %q", f.Source(), f.URL())
+	}
+	if f.Name() != "data.json" || f.MimeType() != "application/json" || f.Size() != 9 {
+		t.Errorf("metadata = %+v", f.Metadata())
+	}
+	if !f.LastModified().Equal(mod) || f.Metadata().Attributes["owner"] != "ops" {
+		t.Errorf("remote metadata not carried: %+v", f.Metadata())
+	}
+	if p := f.Provenance(); len(p) != 1 || p[0].URL != "mem://bucket/dir/data.json" {
+		t.Errorf("provenance = %+v", p)
+	}
+
+	if ok, err := f.Exists(context.Background()); !ok || err != nil {
+		t.Errorf("Exists = %v, %v", ok, err)
+	}
+	mem.Delete(context.Background(), "mem://bucket/dir/data.json")
+	if ok, err := f.Exists(context.Background()); ok || err != nil {
+		t.Errorf("after delete Exists = %v, %v", ok, err)
+	}
+}
+
+func TestStorage_UnknownScheme(t *testing.T) {
+	_, err := NewFromStorage(context.Background(), "nope://x/y")
+	if !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("expected ErrInvalidSource, got %v", err)
+	}
+}
+
+func TestStorage_MissingObject(t *testing.T) {
+	RegisterStorage("mem", newMemStorage())
+	defer RegisterStorage("mem", nil)
+
+	_, err := NewFromStorage(context.Background(), "mem://b/missing")
+	if !errors.Is(err, ErrRead) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrRead wrapping ErrNotFound, got %v", err)
+	}
+}
+
+func TestNew_Dispatch(t *testing.T) {
+	ctx := context.Background()
+
+	p := t.TempDir() + "/note.txt"
+	if err := os.WriteFile(p, []byte("local"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, uri := range []string{p, "file://" + p} {
+		f, err := New(ctx, uri)
+		if err != nil {
+			t.Fatalf("New(%q): %v", uri, err)
+		}
+		if f.Source() != SourceFile {
+			t.Errorf("New(%q).Source() = %s", uri, f.Source())
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("remote"))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+	f, err := New(ctx, srv.URL+"/r.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Source() != SourceURL {
+		t.Errorf("Source = %s, want URL", f.Source())
+	}
+
+	RegisterStorage("mem", newMemStorage())
+	defer RegisterStorage("mem", nil)
+	if _, err := New(ctx, "mem://b/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected storage dispatch to report ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// tempRoot returns the directory that holds every process's managed temp
+// directory. Tests replace it.
+var tempRoot = func() string {
+	return filepath.Join(os.TempDir(), "smooai-file")
+}
+
+// processStart identifies this process's temp directory alongside its PID,
+// so a directory left by an earlier process with a reused PID is not
+// mistaken for ours. It is the start time the system reports where it can
+// be read, so ReapOrphanedTemp in another process can match it.
+var processStart = func() time.Time {
+	if t, ok := processStartTime(os.Getpid()); ok {
+		return t
+	}
+	return time.Now()
+}()
+
+// startTimeSlack is how far a directory name's start time may be from the
+// start time the system reports for its PID and still match.
+const startTimeSlack = 2 * time.Second
+
+// processTempName is the name of this process's directory under tempRoot.
+func processTempName() string {
+	return fmt.Sprintf("%d-%d", os.Getpid(), processStart.Unix())
+}
+
+// createTemp creates a temp file in this process's managed directory,
+// which ReapOrphanedTemp can clean up if the process dies before removing
+// it. Temp files the package spools to disk go through here.
+func createTemp(pattern string) (*os.File, error) {
+	dir := filepath.Join(tempRoot(), processTempName())
+	// Created on every call: another process's reaper may have removed it.
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// ReapOrphanedTemp removes the temp directories that processes using this
+// package left behind in dir, returning how many it removed. An empty dir
+// means the default location under os.TempDir().
+//
+// Each process spools temp files into its own subdirectory named for its
+// PID and start time, and removes the files when done; a process that is
+// killed leaves them behind. A subdirectory is reaped when its PID is no
+// longer running. A directory whose PID is running and whose start time
+// matches that process's is never reaped, however old. When the PID is
+// running but the start times cannot be matched (the PID was reused, or
+// the system does not report start times), a positive olderThan breaks
+// the tie: the directory is reaped if it was created more than olderThan
+// ago. The calling process's own directory is never reaped, and entries
+// that are not managed temp directories are left alone. A missing dir reaps
+// nothing. Failures to remove are returned, wrapped in ErrWrite, after
+// every candidate has been tried.
+func ReapOrphanedTemp(dir string, olderThan time.Duration) (int, error) {
+	if dir == "" {
+		dir = tempRoot()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, newError(ErrRead, "ReapOrphanedTemp", err)
+	}
+
+	own := processTempName()
+	var (
+		reaped int
+		errs   []error
+	)
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == own {
+			continue
+		}
+		pid, started, ok := parseTempName(e.Name())
+		if !ok {
+			continue
+		}
+		if processRunning(pid) {
+			if t, ok := processStartTime(pid); ok && absDuration(t.Sub(started)) <= startTimeSlack {
+				continue
+			}
+			if olderThan <= 0 || time.Since(started) <= olderThan {
+				continue
+			}
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		reaped++
+	}
+	if len(errs) > 0 {
+		return reaped, newError(ErrWrite, "ReapOrphanedTemp", errors.Join(errs...))
+	}
+	return reaped, nil
+}
+
+// parseTempName splits a managed temp directory name into its PID and
+// start time.
+func parseTempName(name string) (pid int, started time.Time, ok bool) {
+	p, s, found := strings.Cut(name, "-")
+	if !found {
+		return 0, time.Time{}, false
+	}
+	pid, err := strconv.Atoi(p)
+	if err != nil || pid <= 0 {
+		return 0, time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || secs <= 0 {
+		return 0, time.Time{}, false
+	}
+	return pid, time.Unix(secs, 0), true
+}
+
+// processRunning reports whether a process with pid exists. Where it
+// cannot tell, it assumes the process is running, so nothing is reaped
+// from under it.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	return !errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
+
+// processStartTime returns when the process with pid started, read from
+// /proc on Linux. It reports false where the start time is not available.
+func processStartTime(pid int) (time.Time, bool) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, false
+	}
+	// The command name in parentheses may hold spaces; fields resume after
+	// the last ')'. starttime is field 22, the 20th after it.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, false
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	boot, ok := bootTime()
+	if !ok {
+		return time.Time{}, false
+	}
+	// /proc reports times in USER_HZ, which is 100 on every Linux ABI.
+	return boot.Add(time.Duration(ticks) * (time.Second / 100)), true
+}
+
+// bootTime returns the system boot time from /proc/stat.
+func bootTime() (time.Time, bool) {
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return time.Unix(secs, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,140 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// useTempRoot points the managed temp directory at a fresh directory for
+// the test.
+func useTempRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	prev := tempRoot
+	tempRoot = func() string { return root }
+	t.Cleanup(func() { tempRoot = prev })
+	return root
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run a child process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestReapOrphanedTemp(t *testing.T) {
+	root := useTempRoot(t)
+	now := time.Now().Unix()
+	dirs := map[string]bool{ // name -> want reaped
+		fmt.Sprintf("%d-%d", deadPID(t), now):            true,
+		fmt.Sprintf("%d-%d", os.Getppid(), now):          false, // live
+		fmt.Sprintf("%d-%d", os.Getppid(), now-7200):     true,  // live PID, but too old
+		processTempName():                                false, // ours
+		"notes":                                          false,
+		fmt.Sprintf("%d-%d-extra", deadPID(t), now-7200): false,
+	}
+	for name := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, name), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name, "upload-1"), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := ReapOrphanedTemp("", time.Hour)
+	if err != nil {
+		t.Fatalf("ReapOrphanedTemp: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("reaped %d directories, want 2", n)
+	}
+	for name, reap := range dirs {
+		_, err := os.Stat(filepath.Join(root, name))
+		if gone := os.IsNotExist(err); gone != reap {
+			t.Errorf("%s: removed = %v, want %v", name, gone, reap)
+		}
+	}
+
+	// Without an age limit only dead processes are reaped.
+	old := fmt.Sprintf("%d-%d", os.Getppid(), now-7200)
+	if err := os.MkdirAll(filepath.Join(root, old), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ReapOrphanedTemp(root, 0); err != nil || n != 0 {
+		t.Errorf("olderThan 0: reaped %d, err %v; want 0, nil", n, err)
+	}
+
+	if n, err := ReapOrphanedTemp(filepath.Join(root, "missing"), time.Hour); err != nil || n != 0 {
+		t.Errorf("missing dir: reaped %d, err %v; want 0, nil", n, err)
+	}
+}
+
+func TestUploadToS3_SpoolsInManagedTemp(t *testing.T) {
+	root := useTempRoot(t)
+	SetUploadBufferThreshold(0)
+	defer SetUploadBufferThreshold(DefaultUploadBufferThreshold)
+	var spooled string
+	defer setMockS3(&mockS3Client{
+		putObjectFn: func(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			if fh, ok := in.Body.(*os.File); ok {
+				spooled = fh.Name()
+			}
+			_, _ = io.Copy(io.Discard, in.Body)
+			return &s3.PutObjectOutput{}, nil
+		},
+	}, &mockPresignClient{})()
+
+	f, err := NewFromStreamLazy(bytes.NewReader(bytes.Repeat([]byte("z"), 100*1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UploadToS3("bucket", "k"); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	want := filepath.Join(root, processTempName()) + string(filepath.Separator)
+	if !strings.HasPrefix(spooled, want) {
+		t.Errorf("spooled to %q, want a file under %s", spooled, want)
+	}
+	if _, err := os.Stat(spooled); !os.IsNotExist(err) {
+		t.Errorf("spool %s not removed after upload", spooled)
+	}
+}
+
+func TestReapOrphanedTemp_SparesMatchedLiveProcess(t *testing.T) {
+	started, ok := processStartTime(os.Getppid())
+	if !ok {
+		t.Skip("process start times are not available")
+	}
+	root := useTempRoot(t)
+	live := fmt.Sprintf("%d-%d", os.Getppid(), started.Unix())
+	if err := os.MkdirAll(filepath.Join(root, live), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	// However small olderThan is, a live process's directory stays.
+	if n, err := ReapOrphanedTemp("", time.Nanosecond); err != nil || n != 0 {
+		t.Errorf("reaped %d, err %v; want 0, nil", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, live)); err != nil {
+		t.Errorf("live directory removed: %v", err)
+	}
+	// Our own directory is named so another process's reaper can match it.
+	if own, ok := processStartTime(os.Getpid()); !ok || absDuration(own.Sub(processStart)) > startTimeSlack {
+		t.Errorf("processStart = %v, system reports %v", processStart, own)
+	}
+}
@@ -0,0 +1,218 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// templateNow is the clock ExpandTemplate reads. Tests replace it.
+var templateNow = time.Now
+
+// TemplateContext is the data ExpandTemplate executes a template against.
+type TemplateContext struct {
+	// Name is the file name; SafeName is the same with path separators,
+	// control characters and characters Windows forbids replaced by "_".
+	Name     string
+	SafeName string
+	// Stem is Name without its extension.
+	Stem string
+	// Extension is the extension without a leading dot.
+	Extension string
+	MimeType  string
+	Size      int64
+	// Hash is the source-provided hash (an ETag or Content-MD5), which may
+	// be empty. Use SHA256 for a content hash.
+	Hash string
+	// LastModified is the source's modification time, or Now when the
+	// source did not report one. Year, Month and Day are its zero-padded
+	// UTC parts ("2024", "03", "07").
+	LastModified     time.Time
+	Year, Month, Day string
+	// Now is the time of expansion and Date its UTC day ("2024-03-07").
+	Now  time.Time
+	Date string
+	// Attributes is the file's Metadata.Attributes. Referencing a missing
+	// key is an error.
+	Attributes map[string]string
+	// Path is the slash-separated path relative to the directory being
+	// uploaded, for UploadDirToS3 prefixes; otherwise empty.
+	Path string
+
+	f *File
+}
+
+// SHA256 returns the hex SHA-256 of the content, read on first use. Use
+// trunc for a prefix: {{trunc 8 .SHA256}}.
+func (c *TemplateContext) SHA256() (string, error) {
+	r, err := c.f.contentReader(context.Background())
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// templateFuncs are the helpers available to ExpandTemplate.
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"slug":  slug,
+	"trunc": trunc,
+}
+
+// WithDirTemplate makes SaveToDir expand its dir with ExpandTemplate, so
+// "archive/{{.Year}}/{{.Month}}" files by modification date. Without it
+// dir is used as written, "{{" and all.
+func WithDirTemplate() SaveOption {
+	return func(o *saveOptions) { o.dirTemplate = true }
+}
+
+// WithPrefixTemplate makes UploadDirToS3 expand its prefix per file with
+// ExpandTemplate, the Path field set to the relative path. Without it the
+// prefix is used as written. Other uploads ignore it.
+func WithPrefixTemplate() UploadOption {
+	return func(o *uploadOptions) { o.prefixTemplate = true }
+}
+
+// ExpandTemplate executes tmpl as a text/template against the file's
+// TemplateContext and returns the result, for building paths and keys such
+// as "{{.Year}}/{{.Month}}/{{.Attributes.tenant}}/{{slug .Stem}}.{{.Extension}}".
+//
+// Besides the text/template builtins it provides lower, upper, slug
+// (lowercase ASCII letters and digits joined by "-") and trunc (the first
+// n runes: {{trunc 8 .SHA256}}). A template that does not parse, refers to
+// an unknown field or missing attribute, or expands to an empty string
+// fails with ErrTemplate.
+//
+// Fields such as Name and Attributes may come from a remote source, so the
+// part of the result that follows the template's literal leading
+// directories must stay beneath them: a ".." segment there, or an absolute
+// path when the template starts with an action, fails with ErrTemplate.
+func (f *File) ExpandTemplate(tmpl string) (string, error) {
+	return f.expandTemplate(tmpl, "", "ExpandTemplate")
+}
+
+// expandTemplate implements ExpandTemplate with the Path field set to rel.
+func (f *File) expandTemplate(tmpl, rel, op string) (string, error) {
+	t, err := template.New("path").Funcs(templateFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", newError(ErrTemplate, op, err)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, f.templateContext(rel)); err != nil {
+		return "", newError(ErrTemplate, op, err)
+	}
+	if sb.Len() == 0 {
+		return "", newError(ErrTemplate, op, fmt.Errorf("template %q expanded to an empty string", tmpl))
+	}
+	out := sb.String()
+	if err := checkExpanded(tmpl, out); err != nil {
+		return "", newError(ErrTemplate, op, err)
+	}
+	return out, nil
+}
+
+// checkExpanded rejects an expansion of tmpl that escapes the directories
+// spelled out literally at the start of tmpl. Only those directories are
+// trusted; what follows came, at least in part, from template data.
+func checkExpanded(tmpl, out string) error {
+	lead, _, _ := strings.Cut(tmpl, "{{")
+	trusted := lead[:strings.LastIndexAny(lead, `/\`)+1]
+	if !strings.HasPrefix(out, trusted) {
+		// A "{{-" action trimmed the literal text; trust none of it.
+		trusted = ""
+	}
+	rest := out[len(trusted):]
+	if trusted == "" && (filepath.IsAbs(rest) || filepath.VolumeName(rest) != "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, `\`)) {
+		return fmt.Errorf("template %q expanded to the absolute path %q", tmpl, out)
+	}
+	for _, seg := range strings.FieldsFunc(rest, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if seg == ".." {
+			return fmt.Errorf("template %q expanded to %q, which leaves its directory", tmpl, out)
+		}
+	}
+	return nil
+}
+
+// templateContext builds the TemplateContext for f.
+func (f *File) templateContext(rel string) *TemplateContext {
+	now := templateNow()
+	modified := f.meta.LastModified
+	if modified.IsZero() {
+		modified = now
+	}
+	mu := modified.UTC()
+	attrs := f.meta.Attributes
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	return &TemplateContext{
+		Name:         f.meta.Name,
+		SafeName:     safeName(f.meta.Name),
+		Stem:         strings.TrimSuffix(f.meta.Name, filepath.Ext(f.meta.Name)),
+		Extension:    f.meta.Extension,
+		MimeType:     f.meta.MimeType,
+		Size:         f.Size(),
+		Hash:         f.meta.Hash,
+		LastModified: modified,
+		Year:         fmt.Sprintf("%04d", mu.Year()),
+		Month:        fmt.Sprintf("%02d", int(mu.Month())),
+		Day:          fmt.Sprintf("%02d", mu.Day()),
+		Now:          now,
+		Date:         now.UTC().Format(time.DateOnly),
+		Attributes:   attrs,
+		Path:         rel,
+		f:            f,
+	}
+}
+
+// safeName replaces characters that are unsafe in a path component.
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// slug lowercases s and joins its runs of ASCII letters and digits with
+// "-".
+func slug(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return sb.String()
+}
+
+// trunc returns the first n runes of s.
+func trunc(n int, s string) string {
+	if n < 0 {
+		n = 0
+	}
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
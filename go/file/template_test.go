@@ -0,0 +1,189 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func fixedTemplateNow(t *testing.T, now time.Time) {
+	t.Helper()
+	orig := templateNow
+	templateNow = func() time.Time { return now }
+	t.Cleanup(func() { templateNow = orig })
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	tests := []struct {
+		name, got, want string
+	}{
+		{"lower", strings.ToLower("ReadMe"), "readme"},
+		{"slug", slug("Q3 Report — FINAL (v2)"), "q3-report-final-v2"},
+		{"slug edges", slug("  --hello__world--  "), "hello-world"},
+		{"slug empty", slug("—"), ""},
+		{"trunc", trunc(3, "héllo"), "hél"},
+		{"trunc short", trunc(10, "abc"), "abc"},
+		{"trunc negative", trunc(-1, "abc"), ""},
+		{"safeName", safeName("a/b\\c:d*e?.txt"), "a_b_c_d_e_.txt"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	f, _ := NewFromBytes([]byte("x"), MetadataHint{Name: "Mixed Case.TXT"})
+	for tmpl, want := range map[string]string{
+		"{{lower .Name}}":       "mixed case.txt",
+		"{{upper .Stem}}":       "MIXED CASE",
+		"{{slug .Name}}":        "mixed-case-txt",
+		"{{trunc 5 .SafeName}}": "Mixed",
+	} {
+		if got, err := f.ExpandTemplate(tmpl); err != nil || got != want {
+			t.Errorf("ExpandTemplate(%q) = %q, %v; want %q", tmpl, got, err, want)
+		}
+	}
+}
+
+func TestFile_ExpandTemplate(t *testing.T) {
+	fixedTemplateNow(t, time.Date(2024, 11, 30, 23, 0, 0, 0, time.UTC))
+	content := []byte("%PDF-1.4 quarterly numbers")
+	f, _ := NewFromBytes(content, MetadataHint{
+		Name:         "Q3 Report (Final).PDF",
+		LastModified: time.Date(2024, 3, 7, 12, 0, 0, 0, time.UTC),
+		Attributes:   map[string]string{"tenant": "acme", "up": "../..", "abs": "/etc/passwd"},
+	})
+
+	got, err := f.ExpandTemplate("{{.Year}}/{{.Month}}/{{.Attributes.tenant}}/{{slug .Stem}}-{{trunc 8 .SHA256}}.{{lower .Extension}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024/03/acme/q3-report-final-" + hexSHA256(content)[:8] + ".pdf"; got != want {
+		t.Errorf("key = %q, want %q", got, want)
+	}
+
+	if got, _ := f.ExpandTemplate("{{.Date}}/{{.Day}}/{{.Size}}"); got != "2024-11-30/07/26" {
+		t.Errorf("got %q", got)
+	}
+
+	t.Run("no modification time uses now", func(t *testing.T) {
+		g, _ := NewFromBytes([]byte("x"), MetadataHint{Name: "x.txt"})
+		if got, _ := g.ExpandTemplate("{{.Year}}-{{.Month}}"); got != "2024-11" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tmpl := range []string{
+			"{{.Attributes.region}}/x",
+			"{{.Nope}}",
+			"{{.Name",
+			"{{nope .Name}}",
+			"{{if false}}x{{end}}",
+			"{{.Attributes.up}}/x",
+			"keys/{{.Attributes.up}}/x",
+			"{{.Attributes.abs}}",
+		} {
+			if _, err := f.ExpandTemplate(tmpl); !errors.Is(err, ErrTemplate) {
+				t.Errorf("ExpandTemplate(%q) err = %v, want ErrTemplate", tmpl, err)
+			}
+		}
+	})
+}
+
+func TestFile_SaveToDir_Template(t *testing.T) {
+	dir := t.TempDir()
+	f, _ := NewFromBytes([]byte("data"), MetadataHint{
+		Name:         "notes.txt",
+		LastModified: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Attributes:   map[string]string{"owner": "ops"},
+	})
+
+	saved, err := f.SaveToDir(filepath.Join(dir, "{{.Attributes.owner}}", "{{.Year}}"), WithDirTemplate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "ops", "2023", "notes.txt"); saved.Path() != want {
+		t.Errorf("path = %q, want %q", saved.Path(), want)
+	}
+
+	t.Run("bad template writes nothing", func(t *testing.T) {
+		empty := t.TempDir()
+		_, err := f.SaveToDir(filepath.Join(empty, "{{.Attributes.missing}}"), WithDirTemplate())
+		if !errors.Is(err, ErrTemplate) {
+			t.Fatalf("err = %v, want ErrTemplate", err)
+		}
+		if entries, _ := os.ReadDir(empty); len(entries) != 0 {
+			t.Errorf("dir has %d entries", len(entries))
+		}
+	})
+
+	t.Run("attributes cannot leave the directory", func(t *testing.T) {
+		root := t.TempDir()
+		for _, owner := range []string{"..", "../../etc", "a/../../b"} {
+			g, _ := NewFromBytes([]byte("data"), MetadataHint{Name: "notes.txt", Attributes: map[string]string{"owner": owner}})
+			if _, err := g.SaveToDir(filepath.Join(root, "in", "{{.Attributes.owner}}"), WithDirTemplate()); !errors.Is(err, ErrTemplate) {
+				t.Errorf("owner %q: err = %v, want ErrTemplate", owner, err)
+			}
+		}
+		if entries, _ := os.ReadDir(root); len(entries) != 0 {
+			t.Errorf("root has %d entries", len(entries))
+		}
+	})
+
+	t.Run("without the option dir is literal", func(t *testing.T) {
+		literal := filepath.Join(t.TempDir(), "{{.Year}}")
+		saved, err := f.SaveToDir(literal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(literal, "notes.txt"); saved.Path() != want {
+			t.Errorf("path = %q, want %q", saved.Path(), want)
+		}
+	})
+}
+
+func TestFile_UploadDirToS3_Template(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	mock := &mockS3Client{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			io.Copy(io.Discard, params.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			keys = append(keys, aws.ToString(params.Key))
+			return &s3.PutObjectOutput{}, nil
+		},
+	}
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	f, _ := NewFromFile(dirFixture(t))
+	if err := f.UploadDirToS3(context.Background(), "bucket", "by-ext/{{.Extension}}", WithPrefixTemplate()); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if want := []string{"by-ext/json/sub/b.json", "by-ext/txt/a.txt", "by-ext/txt/sub/deep/c.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	keys = nil
+	if err := f.UploadDirToS3(context.Background(), "bucket", "{{.Name", WithPrefixTemplate()); !errors.Is(err, ErrTemplate) {
+		t.Fatalf("err = %v, want ErrTemplate", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("uploaded %v despite a bad template", keys)
+	}
+}
@@ -0,0 +1,381 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DefaultDiffContext is the number of unchanged lines DiffText shows
+// around each change unless DiffOptions.Context says otherwise.
+const DefaultDiffContext = 3
+
+// DefaultDiffMaxSize is the largest content, in bytes, DiffText compares
+// unless DiffOptions.MaxSize says otherwise.
+const DefaultDiffMaxSize = 1 << 20
+
+// DiffOptions configures DiffText.
+type DiffOptions struct {
+	// Context is the number of unchanged lines shown around each change:
+	// DefaultDiffContext when zero, none when negative.
+	Context int
+	// Color wraps the output in ANSI color codes for a terminal: file
+	// headers bold, hunk headers cyan, removed lines red and added lines
+	// green.
+	Color bool
+	// MaxSize caps the size of each side in bytes: DefaultDiffMaxSize when
+	// zero, no cap when negative.
+	MaxSize int64
+}
+
+// ANSI escape sequences for DiffOptions.Color.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// DiffText compares f's text with other's, line by line, and returns the
+// differences as a unified diff ("--- a/name", "+++ b/name", then "@@"
+// hunks), or "" when the texts are equal. Lines are matched with Myers'
+// O(ND) algorithm in linear space, so the diff is a shortest one. CRLF
+// line endings are normalized to LF before comparing; when the two sides
+// use different endings, a line before the file headers says so. A last
+// line without a newline is marked "\ No newline at end of file", as diff
+// does.
+//
+// Content that is not UTF-8 text fails with ErrNotText, and either side
+// over the MaxSize cap fails with ErrTooLarge before it is read when its
+// Size is known.
+func (f *File) DiffText(other *File, opts DiffOptions) (string, error) {
+	limit := opts.MaxSize
+	if limit == 0 {
+		limit = DefaultDiffMaxSize
+	}
+	context := opts.Context
+	if context == 0 {
+		context = DefaultDiffContext
+	}
+	context = max(context, 0)
+
+	a, err := diffSide(f, limit)
+	if err != nil {
+		return "", err
+	}
+	b, err := diffSide(other, limit)
+	if err != nil {
+		return "", err
+	}
+	aCRLF, bCRLF := bytes.Contains(a, []byte("\r\n")), bytes.Contains(b, []byte("\r\n"))
+	aLines := splitDiffLines(string(bytes.ReplaceAll(a, []byte("\r\n"), []byte("\n"))))
+	bLines := splitDiffLines(string(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))))
+
+	ops := diffLines(aLines, bLines)
+	hunks := groupHunks(ops, context)
+	if len(hunks) == 0 {
+		return "", nil
+	}
+
+	w := diffWriter{color: opts.Color}
+	if aCRLF != bCRLF {
+		w.line("", "", fmt.Sprintf("line endings differ (a: %s, b: %s); compared after normalizing CRLF to LF\n", lineEnding(aCRLF), lineEnding(bCRLF)))
+	}
+	w.line(ansiBold, "--- ", diffLabel("a/", f)+"\n")
+	w.line(ansiBold, "+++ ", diffLabel("b/", other)+"\n")
+	for _, h := range hunks {
+		w.line(ansiCyan, "", fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(h.aStart, h.aCount), hunkRange(h.bStart, h.bCount)))
+		for _, op := range h.ops {
+			switch op.kind {
+			case diffEqual:
+				w.line("", " ", aLines[op.a])
+			case diffDelete:
+				w.line(ansiRed, "-", aLines[op.a])
+			case diffInsert:
+				w.line(ansiGreen, "+", bLines[op.b])
+			}
+		}
+	}
+	return w.b.String(), nil
+}
+
+// diffSide returns f's content for DiffText, enforcing limit (when
+// positive) and rejecting binary content.
+func diffSide(f *File, limit int64) ([]byte, error) {
+	if limit > 0 && f.Size() > limit {
+		return nil, diffTooLarge(f, f.Size(), limit)
+	}
+	data, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, diffTooLarge(f, int64(len(data)), limit)
+	}
+	if !isText(data) {
+		return nil, newError(ErrNotText, "DiffText", fmt.Errorf("%s looks binary (%s)", describeFile(f), f.meta.MimeType))
+	}
+	return data, nil
+}
+
+// diffTooLarge is the error DiffText returns for size bytes of content.
+func diffTooLarge(f *File, size, limit int64) error {
+	return newError(ErrTooLarge, "DiffText", fmt.Errorf("%s is %d bytes, over the %d-byte diff limit", describeFile(f), size, limit))
+}
+
+// diffLabel names f in a file header: prefix plus its name, or just the
+// bare prefix letter when it has none.
+func diffLabel(prefix string, f *File) string {
+	if f.meta.Name == "" {
+		return strings.TrimSuffix(prefix, "/")
+	}
+	return prefix + f.meta.Name
+}
+
+// lineEnding names the line ending a side uses.
+func lineEnding(crlf bool) string {
+	if crlf {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// splitDiffLines splits s into lines, each keeping its "\n"; only the last
+// may lack one.
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange formats one side of a hunk header. start is the 0-based index
+// of the hunk's first line; an empty range names the line before it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffWriter builds DiffText's output.
+type diffWriter struct {
+	b     strings.Builder
+	color bool
+}
+
+// line writes prefix and text (which ends in "\n" unless it is the last
+// line of a side without one), colored with color when enabled.
+func (w *diffWriter) line(color, prefix, text string) {
+	noEOL := !strings.HasSuffix(text, "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if w.color && color != "" {
+		w.b.WriteString(color + prefix + text + ansiReset + "\n")
+	} else {
+		w.b.WriteString(prefix + text + "\n")
+	}
+	if noEOL {
+		w.b.WriteString("\\ No newline at end of file\n")
+	}
+}
+
+// diffKind is the kind of a diffOp.
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+// diffOp is one line of an edit script: a line of a (a), of b (b), or of
+// both, by index.
+type diffOp struct {
+	kind diffKind
+	a, b int
+}
+
+// diffLines returns the edit script turning a into b, deletions before
+// insertions within each change.
+func diffLines(a, b []string) []diffOp {
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	m := myers{a: intern(a), b: intern(b)}
+	m.matchedA = make([]bool, len(a))
+	m.matchedB = make([]bool, len(b))
+	m.compare(0, len(a), 0, len(b))
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && m.matchedA[i] && m.matchedB[j]:
+			ops = append(ops, diffOp{diffEqual, i, j})
+			i++
+			j++
+		case i < len(a) && !m.matchedA[i]:
+			ops = append(ops, diffOp{diffDelete, i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{diffInsert, i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+// myers finds a longest common subsequence of a and b with the
+// linear-space variant of Myers' algorithm, marking the lines in it.
+type myers struct {
+	a, b               []int
+	matchedA, matchedB []bool
+	vf, vb             []int
+}
+
+// compare marks the common subsequence of a[aLo:aHi] and b[bLo:bHi].
+func (m *myers) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && m.a[aLo] == m.b[bLo] {
+		m.matchedA[aLo], m.matchedB[bLo] = true, true
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && m.a[aHi-1] == m.b[bHi-1] {
+		aHi--
+		bHi--
+		m.matchedA[aHi], m.matchedB[bHi] = true, true
+	}
+	if aLo == aHi || bLo == bHi {
+		return
+	}
+	x, y, u, v := m.middleSnake(aLo, aHi, bLo, bHi)
+	for i := 0; i < u-x; i++ {
+		m.matchedA[x+i], m.matchedB[y+i] = true, true
+	}
+	m.compare(aLo, x, bLo, y)
+	m.compare(u, aHi, v, bHi)
+}
+
+// middleSnake returns the middle snake, from (x, y) to (u, v) in absolute
+// indexes, of a shortest edit script for a[aLo:aHi] and b[bLo:bHi]. The
+// forward search keeps the furthest x reached on each diagonal k = x-y in
+// vf; the backward search keeps, in vb, how far it got from the end on
+// each diagonal of the reversed sequences.
+func (m *myers) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, mm := aHi-aLo, bHi-bLo
+	maxD := (n + mm + 1) / 2
+	off := maxD + 1
+	if size := 2*maxD + 3; len(m.vf) < size {
+		m.vf = make([]int, size)
+		m.vb = make([]int, size)
+	}
+	vf, vb := m.vf, m.vb
+	vf[off+1], vb[off+1] = 0, 0
+	delta := n - mm
+	odd := delta%2 != 0
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var px int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				px = vf[off+k+1]
+			} else {
+				px = vf[off+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < mm && m.a[aLo+px] == m.b[bLo+py] {
+				px++
+				py++
+			}
+			vf[off+k] = px
+			if rk := delta - k; odd && rk >= -(d-1) && rk <= d-1 && px+vb[off+rk] >= n {
+				return aLo + sx, bLo + sy, aLo + px, bLo + py
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var px int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				px = vb[off+k+1]
+			} else {
+				px = vb[off+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < mm && m.a[aHi-1-px] == m.b[bHi-1-py] {
+				px++
+				py++
+			}
+			vb[off+k] = px
+			if fk := delta - k; !odd && fk >= -d && fk <= d && px+vf[off+fk] >= n {
+				return aHi - px, bHi - py, aHi - sx, bHi - sy
+			}
+		}
+	}
+	// Unreachable: a path of at most n+mm edits always exists.
+	return aLo, bLo, aLo, bLo
+}
+
+// diffHunk is a run of ops with its line ranges on each side.
+type diffHunk struct {
+	aStart, aCount, bStart, bCount int
+	ops                            []diffOp
+}
+
+// groupHunks splits ops into hunks with context unchanged lines around
+// each change, merging changes whose context would touch.
+func groupHunks(ops []diffOp, context int) []diffHunk {
+	var hunks []diffHunk
+	for i := 0; i < len(ops); {
+		if ops[i].kind == diffEqual {
+			i++
+			continue
+		}
+		start := max(i-context, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != diffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == diffEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = run
+		}
+		h := diffHunk{aStart: ops[start].a, bStart: ops[start].b, ops: ops[start:end]}
+		for _, op := range h.ops {
+			if op.kind != diffInsert {
+				h.aCount++
+			}
+			if op.kind != diffDelete {
+				h.bCount++
+			}
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
@@ -0,0 +1,129 @@
+package file
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func textFile(t *testing.T, name, content string) *File {
+	t.Helper()
+	f, err := NewFromBytes([]byte(content), MetadataHint{Name: name, MimeType: "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestDiffText_KnownGood(t *testing.T) {
+	const stored = "host=a\nport=1\nuser=x\nmode=dev\nlevel=1\nx=1\ny=2\nz=3\nw=4\nq=5\nend\n"
+	for _, tc := range []struct {
+		name string
+		a, b string
+		opts DiffOptions
+		want string
+	}{
+		{
+			name: "two hunks",
+			a:    stored,
+			b:    strings.Replace(stored, "port=1", "port=2", 1) + "new=1\n",
+			want: "--- a/app.conf\n+++ b/app.conf\n" +
+				"@@ -1,5 +1,5 @@\n host=a\n-port=1\n+port=2\n user=x\n mode=dev\n level=1\n" +
+				"@@ -9,3 +9,4 @@\n w=4\n q=5\n end\n+new=1\n",
+		},
+		{
+			name: "hunks merge with more context",
+			a:    stored,
+			b:    strings.Replace(stored, "port=1", "port=2", 1) + "new=1\n",
+			opts: DiffOptions{Context: 5},
+			want: "--- a/app.conf\n+++ b/app.conf\n" +
+				"@@ -1,11 +1,12 @@\n host=a\n-port=1\n+port=2\n user=x\n mode=dev\n level=1\n x=1\n y=2\n z=3\n w=4\n q=5\n end\n+new=1\n",
+		},
+		{
+			name: "no context",
+			a:    "a\nb\nc\n",
+			b:    "a\nc\n",
+			opts: DiffOptions{Context: -1},
+			want: "--- a/app.conf\n+++ b/app.conf\n@@ -2 +1,0 @@\n-b\n",
+		},
+		{
+			name: "empty versus content",
+			a:    "",
+			b:    "one\ntwo\n",
+			want: "--- a/app.conf\n+++ b/app.conf\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "content versus empty",
+			a:    "one\n",
+			b:    "",
+			want: "--- a/app.conf\n+++ b/app.conf\n@@ -1 +0,0 @@\n-one\n",
+		},
+		{
+			name: "missing final newline",
+			a:    "a\nb",
+			b:    "a\nc\n",
+			want: "--- a/app.conf\n+++ b/app.conf\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n",
+		},
+		{
+			name: "equal",
+			a:    stored,
+			b:    stored,
+			want: "",
+		},
+	} {
+		got, err := textFile(t, "app.conf", tc.a).DiffText(textFile(t, "app.conf", tc.b), tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: DiffText =\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDiffText_LineEndings(t *testing.T) {
+	a := textFile(t, "win.txt", "one\r\ntwo\r\nthree\r\n")
+	b := textFile(t, "unix.txt", "one\ntwo\nthree!\n")
+	got, err := a.DiffText(b, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "line endings differ (a: CRLF, b: LF); compared after normalizing CRLF to LF\n" +
+		"--- a/win.txt\n+++ b/unix.txt\n@@ -1,3 +1,3 @@\n one\n two\n-three\n+three!\n"
+	if got != want {
+		t.Errorf("DiffText =\n%s\nwant\n%s", got, want)
+	}
+
+	same, _ := a.DiffText(textFile(t, "unix.txt", "one\ntwo\nthree\n"), DiffOptions{})
+	if same != "" {
+		t.Errorf("texts differing only in line endings diff as\n%s", same)
+	}
+}
+
+func TestDiffText_Color(t *testing.T) {
+	got, err := textFile(t, "a.txt", "x\n").DiffText(textFile(t, "a.txt", "y\n"), DiffOptions{Color: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ansiBold + "--- a/a.txt" + ansiReset + "\n" + ansiBold + "+++ b/a.txt" + ansiReset + "\n" +
+		ansiCyan + "@@ -1 +1 @@" + ansiReset + "\n" +
+		ansiRed + "-x" + ansiReset + "\n" + ansiGreen + "+y" + ansiReset + "\n"
+	if got != want {
+		t.Errorf("DiffText = %q, want %q", got, want)
+	}
+}
+
+func TestDiffText_Rejects(t *testing.T) {
+	text := textFile(t, "a.txt", "hello\n")
+	binary, _ := NewFromBytes(pngBytes)
+	if _, err := text.DiffText(binary, DiffOptions{}); !errors.Is(err, ErrNotText) {
+		t.Errorf("binary: err = %v, want ErrNotText", err)
+	}
+	big := textFile(t, "big.txt", strings.Repeat("line\n", 100))
+	if _, err := text.DiffText(big, DiffOptions{MaxSize: 100}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("over the cap: err = %v, want ErrTooLarge", err)
+	}
+	if _, err := text.DiffText(big, DiffOptions{MaxSize: -1}); err != nil {
+		t.Errorf("without a cap: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultThroughputWindow is the rolling window TransferResult's
+// MinThroughput is measured over unless WithMinThroughput sets one.
+const defaultThroughputWindow = time.Second
+
+// throughputSample is the byte count a transfer had reached at a moment.
+type throughputSample struct {
+	at time.Time
+	n  int64
+}
+
+// throughputMeter tracks a transfer's rolling average throughput over
+// window and the lowest average seen. It is shared between the transfer
+// and the watchdog goroutine.
+type throughputMeter struct {
+	mu      sync.Mutex
+	window  time.Duration
+	start   time.Time
+	n       int64
+	samples []throughputSample // oldest first
+	min     int64
+	minSet  bool
+}
+
+func newThroughputMeter(start time.Time, window time.Duration) *throughputMeter {
+	return &throughputMeter{
+		window:  window,
+		start:   start,
+		samples: []throughputSample{{at: start}},
+	}
+}
+
+// add records k more bytes at now.
+func (m *throughputMeter) add(now time.Time, k int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.n += int64(k)
+	// Keep roughly ten samples per window.
+	if last := m.samples[len(m.samples)-1]; now.Sub(last.at) >= m.window/10 {
+		m.samples = append(m.samples, throughputSample{at: now, n: m.n})
+	}
+	m.rateLocked(now)
+}
+
+// rate returns the average throughput in bytes per second over the window
+// ending at now, and false until a full window has elapsed.
+func (m *throughputMeter) rate(now time.Time) (int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rateLocked(now)
+}
+
+func (m *throughputMeter) rateLocked(now time.Time) (int64, bool) {
+	cutoff := now.Add(-m.window)
+	// Find the newest sample at least a window old, dropping older ones.
+	i := -1
+	for j, s := range m.samples {
+		if s.at.After(cutoff) {
+			break
+		}
+		i = j
+	}
+	if i < 0 {
+		return 0, false
+	}
+	m.samples = m.samples[i:]
+	base := m.samples[0]
+	r := bytesPerSecond(m.n-base.n, now.Sub(base.at))
+	if !m.minSet || r < m.min {
+		m.min, m.minSet = r, true
+	}
+	return r, true
+}
+
+// stats returns the average throughput since start and the lowest rolling
+// average seen, which is the overall average for transfers shorter than
+// one window.
+func (m *throughputMeter) stats(end time.Time) (avg, low int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	avg = bytesPerSecond(m.n, end.Sub(m.start))
+	if !m.minSet {
+		return avg, avg
+	}
+	return avg, m.min
+}
+
+// watch cancels the transfer with a *stallError once the rolling average
+// drops below floor, checking a few times per window until ctx is done.
+func (m *throughputMeter) watch(ctx context.Context, cancel context.CancelCauseFunc, floor int64) {
+	tick := time.NewTicker(max(m.window/4, time.Millisecond))
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			if r, ok := m.rate(now); ok && r < floor {
+				cancel(&stallError{rate: r, floor: floor, window: m.window})
+				return
+			}
+		}
+	}
+}
+
+// bytesPerSecond converts n bytes over d to a rate.
+func bytesPerSecond(n int64, d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(float64(n) / d.Seconds())
+}
+
+// stallError is the cancellation cause when the watchdog trips.
+type stallError struct {
+	rate, floor int64
+	window      time.Duration
+}
+
+func (e *stallError) Error() string {
+	return fmt.Sprintf("throughput %d B/s over the last %v is below the minimum %d B/s", e.rate, e.window, e.floor)
+}
+
+// meteredReader feeds a throughputMeter and stops the transfer once its
+// context is cancelled, so a stalled source is abandoned at its next read.
+type meteredReader struct {
+	ctx context.Context
+	r   io.Reader
+	m   *throughputMeter
+}
+
+func (r *meteredReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, context.Cause(r.ctx)
+	}
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.m.add(time.Now(), n)
+	}
+	return n, err
+}
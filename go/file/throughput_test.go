@@ -0,0 +1,131 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// throttledReader returns chunk bytes per read, sleeping every before each
+// read once the first free bytes are served, and pauses once for pause
+// after pauseAt bytes. Tests set free to streamHeadBytes so the lazy
+// constructor's head read is not throttled.
+type throttledReader struct {
+	free      int
+	remaining int
+	chunk     int
+	every     time.Duration
+	pauseAt   int
+	pause     time.Duration
+	read      int
+}
+
+func (r *throttledReader) Read(b []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if r.read >= r.free {
+		time.Sleep(r.every)
+	}
+	if r.pause > 0 && r.read >= r.pauseAt {
+		time.Sleep(r.pause)
+		r.pause = 0
+	}
+	n := min(r.remaining, len(b))
+	if r.read >= r.free {
+		n = min(n, r.chunk)
+	}
+	for i := range b[:n] {
+		b[i] = 'x'
+	}
+	r.remaining -= n
+	r.read += n
+	return n, nil
+}
+
+func TestPipe_MinThroughputStalls(t *testing.T) {
+	// ~1 KB/s against a 20 KB/s floor.
+	src, _ := NewFromStreamLazy(&throttledReader{free: streamHeadBytes, remaining: 1 << 20, chunk: 10, every: 10 * time.Millisecond})
+	start := time.Now()
+	_, err := Pipe(context.Background(), src, &PathSink{Path: filepath.Join(t.TempDir(), "out")},
+		WithMinThroughput(20_000, 100*time.Millisecond))
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("err = %v, want ErrStalled", err)
+	}
+	if errors.Is(err, ErrTimeout) {
+		t.Error("a stall should not be reported as ErrTimeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("watchdog took %v to trip", elapsed)
+	}
+}
+
+func TestPipe_MinThroughputBriefPauseSurvives(t *testing.T) {
+	// ~100 KB/s with one 60 ms pause: the rolling average over any
+	// 200 ms window stays well above 10 KB/s, though an instantaneous
+	// check would trip during the pause.
+	src, _ := NewFromStreamLazy(&throttledReader{
+		free: streamHeadBytes, remaining: streamHeadBytes + 40_000, chunk: 1000,
+		every: 10 * time.Millisecond, pauseAt: streamHeadBytes + 15_000, pause: 60 * time.Millisecond,
+	})
+	res, err := Pipe(context.Background(), src, &PathSink{Path: filepath.Join(t.TempDir(), "out")},
+		WithMinThroughput(10_000, 200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	if res.Bytes != streamHeadBytes+40_000 {
+		t.Errorf("Bytes = %d", res.Bytes)
+	}
+	if res.AvgThroughput <= 0 || res.MinThroughput <= 0 || res.MinThroughput > res.AvgThroughput {
+		t.Errorf("throughput avg %d, min %d", res.AvgThroughput, res.MinThroughput)
+	}
+	if res.MinThroughput < 10_000 {
+		t.Errorf("MinThroughput = %d, below the floor that did not trip", res.MinThroughput)
+	}
+}
+
+func TestPipe_ThroughputShortTransfer(t *testing.T) {
+	src, _ := NewFromBytes(make([]byte, 4096))
+	res, err := Pipe(context.Background(), src, &PathSink{Path: filepath.Join(t.TempDir(), "out")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AvgThroughput <= 0 || res.MinThroughput != res.AvgThroughput {
+		t.Errorf("throughput avg %d, min %d; want equal and positive", res.AvgThroughput, res.MinThroughput)
+	}
+}
+
+func TestWithMinThroughput_Rejected(t *testing.T) {
+	for _, opt := range []TransferOption{WithMinThroughput(0, time.Second), WithMinThroughput(1, 0)} {
+		src, _ := NewFromBytes([]byte("x"))
+		if _, err := Pipe(context.Background(), src, &PathSink{Path: filepath.Join(t.TempDir(), "out")}, opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("err = %v, want ErrInvalidOption", err)
+		}
+	}
+}
+
+func TestThroughputMeter_RollingRate(t *testing.T) {
+	start := time.Unix(0, 0)
+	m := newThroughputMeter(start, time.Second)
+	if _, ok := m.rate(start.Add(500 * time.Millisecond)); ok {
+		t.Error("rate reported before a full window")
+	}
+	for i := 1; i <= 20; i++ {
+		n := 1000
+		if i > 10 {
+			n = 100
+		}
+		m.add(start.Add(time.Duration(i)*100*time.Millisecond), n)
+	}
+	r, ok := m.rate(start.Add(2 * time.Second))
+	if !ok || r != 1000 {
+		t.Errorf("rate = %d, %v; want 1000 (the last second)", r, ok)
+	}
+	avg, low := m.stats(start.Add(2 * time.Second))
+	if avg != 5500 || low != 1000 {
+		t.Errorf("stats = %d, %d; want 5500, 1000", avg, low)
+	}
+}
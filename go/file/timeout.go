@@ -0,0 +1,203 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Default per-operation budgets, applied when the caller's context carries no
+// deadline of its own (including the non-context variants, which use
+// context.Background()). A hung endpoint then fails with ErrTimeout instead
+// of blocking forever. Set a budget to zero to disable its default.
+//
+// Operations that stream a body of any length (Reader, DownloadS3ToFile,
+// DownloadURLToFile, UploadToS3, UploadToURL, UploadToStorage) do not get
+// a total budget. Theirs bounds the wait for the response and then each
+// wait for progress: a source that stops sending, or a destination that
+// stops accepting, for that long fails with ErrTimeout, however long the
+// whole transfer takes. UploadToS3 sends its body to the SDK as is, so
+// there each request is bounded as a whole: the single PutObject, or each
+// part of a multipart upload, which starts above DefaultMultipartThreshold.
+const (
+	// DefaultDownloadTimeout bounds fetching content: NewFromURL, NewFromS3
+	// and the streaming downloads.
+	DefaultDownloadTimeout = 5 * time.Minute
+
+	// DefaultUploadTimeout bounds uploads: UploadToS3, UploadToURL and
+	// UploadToStorage.
+	DefaultUploadTimeout = 10 * time.Minute
+
+	// DefaultMetadataTimeout bounds small control-plane calls: SetS3Tags,
+	// deleting an S3 object, presigning and the like.
+	DefaultMetadataTimeout = 30 * time.Second
+)
+
+var downloadTimeout, uploadTimeout, metadataTimeout atomic.Int64
+
+func init() {
+	downloadTimeout.Store(int64(DefaultDownloadTimeout))
+	uploadTimeout.Store(int64(DefaultUploadTimeout))
+	metadataTimeout.Store(int64(DefaultMetadataTimeout))
+}
+
+// SetDownloadTimeout sets the budget DownloadTimeout returns. Zero or less
+// disables it. Safe for concurrent use; operations already running keep
+// the budget they started with.
+func SetDownloadTimeout(d time.Duration) { downloadTimeout.Store(int64(max(d, 0))) }
+
+// DownloadTimeout returns the download budget, DefaultDownloadTimeout
+// unless changed with SetDownloadTimeout.
+func DownloadTimeout() time.Duration { return time.Duration(downloadTimeout.Load()) }
+
+// SetUploadTimeout sets the budget UploadTimeout returns. Zero or less
+// disables it. Safe for concurrent use.
+func SetUploadTimeout(d time.Duration) { uploadTimeout.Store(int64(max(d, 0))) }
+
+// UploadTimeout returns the upload budget, DefaultUploadTimeout unless
+// changed with SetUploadTimeout.
+func UploadTimeout() time.Duration { return time.Duration(uploadTimeout.Load()) }
+
+// SetMetadataTimeout sets the budget MetadataTimeout returns. Zero or less
+// disables it. Safe for concurrent use.
+func SetMetadataTimeout(d time.Duration) { metadataTimeout.Store(int64(max(d, 0))) }
+
+// MetadataTimeout returns the control-plane budget, DefaultMetadataTimeout
+// unless changed with SetMetadataTimeout.
+func MetadataTimeout() time.Duration { return time.Duration(metadataTimeout.Load()) }
+
+// opDeadline records when an operation started and the deadline budget it
+// runs under, so a timeout can report elapsed vs budget.
+type opDeadline struct {
+	start  time.Time
+	budget time.Duration
+}
+
+// withDefaultTimeout returns ctx bounded by d unless ctx already has a
+// deadline or d is zero. The returned opDeadline describes whichever
+// deadline is in effect.
+func withDefaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc, opDeadline) {
+	start := time.Now()
+	if dl, ok := ctx.Deadline(); ok {
+		return ctx, func() {}, opDeadline{start: start, budget: dl.Sub(start)}
+	}
+	if d <= 0 {
+		return ctx, func() {}, opDeadline{start: start}
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	return ctx, cancel, opDeadline{start: start, budget: d}
+}
+
+// withStreamTimeout is withDefaultTimeout for operations that stream a
+// body: the budget d is a watchdog rather than a deadline. It runs while
+// the operation waits for a response and, once the body flows, only while
+// the operation waits for progress, as reported through the returned
+// watchdog. A deadline on ctx still bounds the whole operation.
+func withStreamTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc, opDeadline, *watchdog) {
+	start := time.Now()
+	if dl, ok := ctx.Deadline(); ok {
+		return ctx, func() {}, opDeadline{start: start, budget: dl.Sub(start)}, nil
+	}
+	if d <= 0 {
+		return ctx, func() {}, opDeadline{start: start}, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &watchdog{d: d, timer: time.AfterFunc(d, func() { cancel(context.DeadlineExceeded) })}
+	return ctx, func() { w.timer.Stop(); cancel(context.Canceled) }, opDeadline{start: start, budget: d}, w
+}
+
+// watchdog cancels a stream's context when a wait it covers outlasts d. A
+// nil watchdog does nothing.
+type watchdog struct {
+	d     time.Duration
+	timer *time.Timer
+}
+
+// kick restarts the wait, as when a new request is sent or a body makes
+// progress.
+func (w *watchdog) kick() {
+	if w != nil {
+		w.timer.Reset(w.d)
+	}
+}
+
+// pause stops the clock until the next kick, while the caller rather than
+// the remote end decides the pace.
+func (w *watchdog) pause() {
+	if w != nil {
+		w.timer.Stop()
+	}
+}
+
+// source wraps a body being downloaded so that the watchdog covers each
+// wait for more of it and nothing in between.
+func (w *watchdog) source(r io.Reader) io.Reader {
+	if w == nil {
+		return r
+	}
+	return &watchedSource{r: r, w: w}
+}
+
+type watchedSource struct {
+	r io.Reader
+	w *watchdog
+}
+
+func (s *watchedSource) Read(p []byte) (int, error) {
+	s.w.kick()
+	defer s.w.pause()
+	return s.r.Read(p)
+}
+
+// pulled wraps a body being uploaded so that each read the destination
+// makes restarts the wait. The clock keeps running between reads, so it
+// also bounds the wait for the response once the body is sent.
+func (w *watchdog) pulled(r io.Reader) io.Reader {
+	if w == nil {
+		return r
+	}
+	return &pulledBody{r: r, w: w}
+}
+
+type pulledBody struct {
+	r io.Reader
+	w *watchdog
+}
+
+func (b *pulledBody) Read(p []byte) (int, error) {
+	b.w.kick()
+	return b.r.Read(p)
+}
+
+// newError wraps err like the package-level newError, but reports ErrTimeout
+// with the elapsed time and budget when ctx's deadline has passed, and
+// ErrShuttingDown when Shutdown cancelled ctx.
+func (d opDeadline) newError(ctx context.Context, sentinel error, op string, err error) *FileError {
+	if errors.Is(context.Cause(ctx), ErrShuttingDown) {
+		return newError(ErrShuttingDown, op, err)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		fe := newError(ErrTimeout, op, err)
+		fe.Elapsed = time.Since(d.start)
+		fe.Budget = d.budget
+		return fe
+	}
+	return newError(sentinel, op, err)
+}
+
+// WithTimeout bounds a single NewFromURL call, retries included, by d in
+// place of DownloadTimeout(), without touching the package defaults or the
+// shared HTTP client. A deadline already on the caller's context still
+// applies when it is sooner. A negative d is rejected; zero leaves
+// DownloadTimeout in effect.
+func WithTimeout(d time.Duration) ReadOption {
+	return readOption(func(o *readOptions) {
+		if d < 0 {
+			o.reject("WithTimeout", "negative timeout %s", d)
+			return
+		}
+		o.timeout = d
+	})
+}
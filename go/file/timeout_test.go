@@ -0,0 +1,320 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// setTimeouts overrides the default operation budgets and returns a cleanup
+// function.
+func setTimeouts(download, upload, metadata time.Duration) func() {
+	origD, origU, origM := DownloadTimeout(), UploadTimeout(), MetadataTimeout()
+	SetDownloadTimeout(download)
+	SetUploadTimeout(upload)
+	SetMetadataTimeout(metadata)
+	return func() {
+		SetDownloadTimeout(origD)
+		SetUploadTimeout(origU)
+		SetMetadataTimeout(origM)
+	}
+}
+
+// blockingS3 returns a mock whose calls block until the context is done.
+func blockingS3() *mockS3Client {
+	return &mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		putObjectTaggingFn: func(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+}
+
+func assertTimeout(t *testing.T, err error, budget, elapsed time.Duration) {
+	t.Helper()
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	var fe *FileError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FileError, got %T", err)
+	}
+	if fe.Budget != budget {
+		t.Errorf("Budget = %s, want %s", fe.Budget, budget)
+	}
+	if fe.Elapsed < budget {
+		t.Errorf("Elapsed = %s, should be at least the %s budget", fe.Elapsed, budget)
+	}
+	if elapsed > budget+2*time.Second {
+		t.Errorf("operation took %s, default budget %s did not kick in", elapsed, budget)
+	}
+	if !strings.Contains(err.Error(), "budget") {
+		t.Errorf("error %q should report the budget", err)
+	}
+}
+
+func TestDefaultTimeouts_ApplyWithoutCallerDeadline(t *testing.T) {
+	const budget = 50 * time.Millisecond
+	restore := setTimeouts(budget, budget, budget)
+	defer restore()
+	cleanup := setMockS3(blockingS3(), &mockPresignClient{})
+	defer cleanup()
+
+	ops := map[string]func() error{
+		"NewFromS3": func() error {
+			_, err := NewFromS3("bucket", "key")
+			return err
+		},
+		"UploadToS3": func() error {
+			f, _ := NewFromBytes([]byte("data"))
+			return f.UploadToS3("bucket", "key")
+		},
+		"DownloadS3ToFile": func() error {
+			_, err := DownloadS3ToFile(context.Background(), "bucket", "key", filepath.Join(t.TempDir(), "out"))
+			return err
+		},
+		"SetS3Tags": func() error {
+			f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "key"}
+			return f.SetS3Tags(context.Background(), map[string]string{"a": "b"})
+		},
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			err := op()
+			assertTimeout(t, err, budget, time.Since(start))
+		})
+	}
+}
+
+func TestDefaultTimeouts_NewFromURL(t *testing.T) {
+	const budget = 50 * time.Millisecond
+	restore := setTimeouts(budget, 0, 0)
+	defer restore()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	start := time.Now()
+	_, err := NewFromURL(srv.URL)
+	assertTimeout(t, err, budget, time.Since(start))
+}
+
+func TestDefaultTimeouts_ZeroDisables(t *testing.T) {
+	restore := setTimeouts(0, 0, 0)
+	defer restore()
+
+	var hadDeadline bool
+	cleanup := setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			_, hadDeadline = ctx.Deadline()
+			return nil, errors.New("boom")
+		},
+	}, &mockPresignClient{})
+	defer cleanup()
+
+	_, err := NewFromS3("bucket", "key")
+	if hadDeadline {
+		t.Error("a zero DownloadTimeout should not add a deadline")
+	}
+	if !errors.Is(err, ErrS3) || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected plain ErrS3, got %v", err)
+	}
+}
+
+func TestDefaultTimeouts_CallerDeadlineWins(t *testing.T) {
+	restore := setTimeouts(time.Hour, time.Hour, time.Hour)
+	defer restore()
+	cleanup := setMockS3(blockingS3(), &mockPresignClient{})
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewFromS3WithContext(ctx, "bucket", "key")
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("took %s; the caller's deadline should apply", elapsed)
+	}
+	var fe *FileError
+	errors.As(err, &fe)
+	if fe.Budget <= 0 || fe.Budget > 30*time.Millisecond {
+		t.Errorf("Budget = %s, want the caller's remaining deadline", fe.Budget)
+	}
+}
+
+func TestDefaultTimeouts_CallerCancelIsNotTimeout(t *testing.T) {
+	cleanup := setMockS3(blockingS3(), &mockPresignClient{})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewFromS3WithContext(ctx, "bucket", "key")
+	if errors.Is(err, ErrTimeout) || !errors.Is(err, ErrS3) {
+		t.Errorf("cancellation should surface as ErrS3, got %v", err)
+	}
+}
+
+func TestWithTimeout_PerCallDeadlines(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer srv.Close()
+	defer setMockHTTP(srv.Client())()
+
+	var short, long error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, short = NewFromURL(srv.URL, WithTimeout(30*time.Millisecond))
+	}()
+	_, long = NewFromURL(srv.URL, WithTimeout(5*time.Second))
+	<-done
+
+	var fe *FileError
+	if !errors.As(short, &fe) || !errors.Is(short, ErrTimeout) || fe.Budget > 30*time.Millisecond {
+		t.Errorf("short fetch: err = %v, want ErrTimeout within a 30ms budget", short)
+	}
+	if long != nil {
+		t.Errorf("long fetch: err = %v, the other call's timeout should not apply", long)
+	}
+	if _, err := NewFromURL(srv.URL, WithTimeout(-time.Second)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("negative timeout: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+// trickleBody sends chunk every interval until n chunks have gone, then
+// stalls until ctx is done when stall is set, or ends.
+type trickleBody struct {
+	ctx      context.Context
+	chunk    []byte
+	n        int
+	interval time.Duration
+	stall    bool
+}
+
+func (b *trickleBody) Read(p []byte) (int, error) {
+	if b.n == 0 {
+		if !b.stall {
+			return 0, io.EOF
+		}
+		<-b.ctx.Done()
+		return 0, b.ctx.Err()
+	}
+	select {
+	case <-b.ctx.Done():
+		return 0, b.ctx.Err()
+	case <-time.After(b.interval):
+	}
+	b.n--
+	return copy(p, b.chunk), nil
+}
+
+func (b *trickleBody) Close() error { return nil }
+
+func TestDefaultTimeouts_StreamingBoundsProgress(t *testing.T) {
+	const budget = 200 * time.Millisecond
+	restore := setTimeouts(budget, budget, budget)
+	defer restore()
+
+	// Ten chunks 50ms apart outlast the budget but never wait that long.
+	var stall bool
+	defer setMockS3(&mockS3Client{
+		getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			body := &trickleBody{ctx: ctx, chunk: []byte("0123456789"), n: 10, interval: 50 * time.Millisecond, stall: stall}
+			return &s3.GetObjectOutput{Body: body}, nil
+		},
+	}, &mockPresignClient{})()
+
+	t.Run("DownloadS3ToFile", func(t *testing.T) {
+		stall = false
+		saved, err := DownloadS3ToFile(context.Background(), "bucket", "key", filepath.Join(t.TempDir(), "out"))
+		if err != nil {
+			t.Fatalf("slow but steady download failed: %v", err)
+		}
+		if saved.Size() != 100 {
+			t.Errorf("Size() = %d, want 100", saved.Size())
+		}
+
+		stall = true
+		start := time.Now()
+		_, err = DownloadS3ToFile(context.Background(), "bucket", "key", filepath.Join(t.TempDir(), "out"))
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("stalled download: err = %v, want ErrTimeout", err)
+		}
+		if elapsed := time.Since(start); elapsed > budget+2*time.Second {
+			t.Errorf("stalled download took %s", elapsed)
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		stall = false
+		f := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "key"}
+		rc, err := f.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		// The caller's own pace between reads does not count either.
+		time.Sleep(2 * budget)
+		data, err := io.ReadAll(rc)
+		if err != nil || len(data) != 100 {
+			t.Errorf("read %d bytes, err = %v; want 100 bytes", len(data), err)
+		}
+	})
+}
+
+func TestDefaultTimeouts_MultipartBoundsEachPart(t *testing.T) {
+	const budget = 500 * time.Millisecond
+	restore := setTimeouts(budget, budget, budget)
+	defer restore()
+
+	fake := newFakeMultipartS3()
+	fake.afterPart = func(int) { time.Sleep(budget * 2 / 5) }
+	defer setMockS3(fake.client(), &mockPresignClient{})()
+
+	payload := bytes.Repeat([]byte("x"), 4*minPartSize)
+	f, _ := NewFromBytes(payload)
+	err := f.UploadToS3("bucket", "key", WithMultipartThreshold(minPartSize), WithPartSize(minPartSize), WithPartConcurrency(1))
+	if err != nil {
+		t.Fatalf("four parts outlasting the budget together failed: %v", err)
+	}
+	if !bytes.Equal(fake.completed, payload) {
+		t.Error("uploaded content mismatch")
+	}
+}
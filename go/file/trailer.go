@@ -0,0 +1,142 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// TrailerContentSHA256 is the HTTP trailer carrying the hex SHA-256 of a
+// body. UploadToURL sends it under WithTrailingChecksum, and NewFromURL
+// verifies it when a server sends it.
+const TrailerContentSHA256 = "X-Content-Sha256"
+
+// headerAmzChecksumSHA256 is S3's base64 SHA-256 checksum, sent as a header
+// or, for aws-chunked bodies, as a trailer.
+const headerAmzChecksumSHA256 = "X-Amz-Checksum-Sha256"
+
+// WithTrailingChecksum sends a SHA-256 of the content that is computed as
+// the bytes flow, instead of one computed up front. UploadToS3 asks the
+// SDK for an aws-chunked body with a trailing x-amz-checksum-sha256, which
+// S3 checks before storing the object. UploadToURL sends the body with
+// chunked encoding and a TrailerContentSHA256 trailer, for servers that
+// read trailers; others ignore it. S3Sink has the equivalent
+// TrailingChecksum field.
+func WithTrailingChecksum() UploadOption {
+	return func(o *uploadOptions) {
+		o.trailingChecksum = true
+	}
+}
+
+// sha256Base64 returns data's SHA-256 in S3's base64 form.
+func sha256Base64(data []byte) string {
+	sum := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// trailingSHA256 hashes a request body as it is read and, at EOF, fills in
+// the TrailerContentSHA256 trailer, which net/http sends after the body.
+type trailingSHA256 struct {
+	rc      io.ReadCloser
+	h       hash.Hash
+	trailer http.Header
+}
+
+func newTrailingSHA256(rc io.ReadCloser, trailer http.Header) *trailingSHA256 {
+	return &trailingSHA256{rc: rc, h: sha256.New(), trailer: trailer}
+}
+
+func (t *trailingSHA256) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	t.h.Write(p[:n])
+	if err == io.EOF {
+		t.trailer.Set(TrailerContentSHA256, hex.EncodeToString(t.h.Sum(nil)))
+	}
+	return n, err
+}
+
+func (t *trailingSHA256) Close() error { return t.rc.Close() }
+
+// publishedSHA256 returns the hex SHA-256 digests a response published for
+// its body: the TrailerContentSHA256 trailer and S3's
+// x-amz-checksum-sha256, as a header or a trailer. Trailers are only
+// available once the body has been read to EOF.
+func publishedSHA256(resp *http.Response) []string {
+	var digests []string
+	if d := resp.Trailer.Get(TrailerContentSHA256); d != "" {
+		digests = append(digests, strings.ToLower(d))
+	}
+	for _, h := range []http.Header{resp.Header, resp.Trailer} {
+		if d := base64DigestToHex(h.Get(headerAmzChecksumSHA256)); d != "" {
+			digests = append(digests, d)
+		}
+	}
+	return digests
+}
+
+// s3FullObjectSHA256 returns the hex SHA-256 of the whole object that out
+// carries, or "" when there is none. The composite checksum of a multipart
+// upload is a digest of its parts' digests, not of the body, so it is
+// ignored.
+func s3FullObjectSHA256(out *s3.GetObjectOutput) string {
+	v := aws.ToString(out.ChecksumSHA256)
+	if v == "" || out.ChecksumType == types.ChecksumTypeComposite || strings.Contains(v, "-") {
+		return ""
+	}
+	return base64DigestToHex(v)
+}
+
+// verifyS3SHA256 checks a GetObject body read into data against the
+// object's full-object SHA-256, when S3 returned one.
+func verifyS3SHA256(out *s3.GetObjectOutput, data []byte, op string) error {
+	want := s3FullObjectSHA256(out)
+	if want == "" {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return newError(ErrChecksumMismatch, op, fmt.Errorf("sha256 of body %s does not match S3 checksum %s", got, want))
+	}
+	return nil
+}
+
+// verifyS3Body wraps a streamed GetObject body so that, at EOF, it fails
+// with ErrChecksumMismatch when the bytes do not match the object's
+// full-object SHA-256.
+func verifyS3Body(out *s3.GetObjectOutput, op string) io.ReadCloser {
+	want := s3FullObjectSHA256(out)
+	if want == "" {
+		return out.Body
+	}
+	h := sha256.New()
+	return struct {
+		io.Reader
+		io.Closer
+	}{&digestReader{r: io.TeeReader(out.Body, h), h: h, algo: AlgorithmSHA256, want: want, op: op}, out.Body}
+}
+
+// verifyPublishedSHA256 checks data against every SHA-256 digest resp
+// published and returns the digest when there was one.
+func verifyPublishedSHA256(resp *http.Response, data []byte, op string) (string, error) {
+	published := publishedSHA256(resp)
+	if len(published) == 0 {
+		return "", nil
+	}
+	sum := sha256.Sum256(data)
+	got := hex.EncodeToString(sum[:])
+	for _, want := range published {
+		if want != got {
+			return "", newError(ErrChecksumMismatch, op, fmt.Errorf("sha256 of body %s does not match published digest %s", got, want))
+		}
+	}
+	return got, nil
+}
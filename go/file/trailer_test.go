@@ -0,0 +1,311 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestUploadToURL_TrailingChecksum(t *testing.T) {
+	content := []byte("streamed with a trailer")
+	var (
+		gotBody    []byte
+		gotTrailer string
+		declared   bool
+		chunked    bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// net/http moves the declared trailer names into r.Trailer.
+		_, declared = r.Trailer[TrailerContentSHA256]
+		chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		gotBody, _ = io.ReadAll(r.Body)
+		// Trailers are only readable after the body.
+		gotTrailer = r.Trailer.Get(TrailerContentSHA256)
+	}))
+	defer srv.Close()
+
+	f, _ := NewFromBytes(content)
+	if err := f.UploadToURL(srv.URL, WithTrailingChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotBody, content) {
+		t.Errorf("body = %q", gotBody)
+	}
+	if !chunked || !declared {
+		t.Errorf("chunked = %v, trailer declared = %v", chunked, declared)
+	}
+	if want := hexSHA256(content); gotTrailer != want {
+		t.Errorf("trailer = %q, want %q", gotTrailer, want)
+	}
+}
+
+func TestNewFromURL_VerifiesPublishedSHA256(t *testing.T) {
+	content := []byte("payload with a published digest")
+	sum := sha256.Sum256(content)
+	b64 := base64.StdEncoding.EncodeToString(sum[:])
+	wrong := hexSHA256([]byte("something else"))
+
+	tests := []struct {
+		name    string
+		serve   func(w http.ResponseWriter)
+		wantErr bool
+	}{
+		{"trailer ok", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", TrailerContentSHA256)
+			w.Write(content)
+			w.Header().Set(TrailerContentSHA256, hexSHA256(content))
+		}, false},
+		{"trailer mismatch", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", TrailerContentSHA256)
+			w.Write(content)
+			w.Header().Set(TrailerContentSHA256, wrong)
+		}, true},
+		{"amz header ok", func(w http.ResponseWriter) {
+			w.Header().Set("x-amz-checksum-sha256", b64)
+			w.Write(content)
+		}, false},
+		{"amz header mismatch", func(w http.ResponseWriter) {
+			w.Header().Set("x-amz-checksum-sha256", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+			w.Write(content)
+		}, true},
+		{"amz trailer mismatch", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", "x-amz-checksum-sha256")
+			w.Write(content)
+			w.Header().Set("x-amz-checksum-sha256", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+		}, true},
+		{"none", func(w http.ResponseWriter) { w.Write(content) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tt.serve(w) }))
+			defer srv.Close()
+
+			f, err := NewFromURL(srv.URL + "/p.bin")
+			if tt.wantErr {
+				if !errors.Is(err, ErrChecksumMismatch) {
+					t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.name != "none" && f.sourceDigests[AlgorithmSHA256] != hexSHA256(content) {
+				t.Errorf("sourceDigests = %v", f.sourceDigests)
+			}
+		})
+	}
+}
+
+func TestNewFromS3_VerifiesChecksum(t *testing.T) {
+	content := []byte("object with a stored checksum")
+	sum := sha256.Sum256(content)
+	zero := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name     string
+		checksum string
+		typ      types.ChecksumType
+		wantErr  bool
+	}{
+		{"match", base64.StdEncoding.EncodeToString(sum[:]), types.ChecksumTypeFullObject, false},
+		{"mismatch", zero, types.ChecksumTypeFullObject, true},
+		{"composite ignored", zero + "-3", types.ChecksumTypeComposite, false},
+		{"none", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mode types.ChecksumMode
+			mock := &mockS3Client{
+				getObjectFn: func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					mode = params.ChecksumMode
+					out := &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(content)), ChecksumType: tt.typ}
+					if tt.checksum != "" {
+						out.ChecksumSHA256 = aws.String(tt.checksum)
+					}
+					return out, nil
+				},
+			}
+			defer setMockS3(mock, &mockPresignClient{})()
+
+			f, err := NewFromS3("bucket", "k.bin")
+			if mode != types.ChecksumModeEnabled {
+				t.Errorf("ChecksumMode = %q", mode)
+			}
+			lazy := &File{source: SourceS3, s3Bucket: "bucket", s3Key: "k.bin"}
+			_, lazyErr := lazy.Read()
+			if tt.wantErr {
+				if !errors.Is(err, ErrChecksumMismatch) {
+					t.Errorf("NewFromS3 err = %v, want ErrChecksumMismatch", err)
+				}
+				if !errors.Is(lazyErr, ErrChecksumMismatch) {
+					t.Errorf("Read err = %v, want ErrChecksumMismatch", lazyErr)
+				}
+				return
+			}
+			if err != nil || lazyErr != nil {
+				t.Fatalf("NewFromS3 err = %v, Read err = %v", err, lazyErr)
+			}
+			if _, ok := f.sourceDigests[AlgorithmSHA256]; ok != (tt.name == "match") {
+				t.Errorf("sourceDigests = %v", f.sourceDigests)
+			}
+		})
+	}
+}
+
+// s3Recorder is a TLS server standing in for S3 that records each request
+// as the SDK framed it.
+type s3Recorder struct {
+	mu   sync.Mutex
+	reqs []recordedRequest
+}
+
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newS3Recorder(t *testing.T) *s3Recorder {
+	t.Helper()
+	rec := &s3Recorder{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.reqs = append(rec.reqs, recordedRequest{header: r.Header.Clone(), body: body})
+		rec.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		HTTPClient:   srv.Client(),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	})
+	orig := CurrentS3ClientFactory()
+	SetS3ClientFactory(func() (S3API, S3PresignAPI) { return client, &mockPresignClient{} })
+	t.Cleanup(func() { SetS3ClientFactory(orig) })
+	return rec
+}
+
+// assertTrailingChecksum checks r is an aws-chunked body of content ending
+// in an x-amz-checksum-sha256 trailer.
+func assertTrailingChecksum(t *testing.T, r recordedRequest, content []byte) {
+	t.Helper()
+	if got := r.header.Get("Content-Encoding"); got != "aws-chunked" {
+		t.Errorf("Content-Encoding = %q, want aws-chunked", got)
+	}
+	if got := r.header.Get("X-Amz-Trailer"); got != "x-amz-checksum-sha256" {
+		t.Errorf("X-Amz-Trailer = %q", got)
+	}
+	if got := r.header.Get("X-Amz-Decoded-Content-Length"); got != fmt.Sprint(len(content)) {
+		t.Errorf("X-Amz-Decoded-Content-Length = %q", got)
+	}
+	sum := sha256.Sum256(content)
+	trailer := "0\r\nx-amz-checksum-sha256:" + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if !bytes.HasSuffix(r.body, []byte(trailer)) {
+		t.Errorf("body = %q, want it to end with %q", r.body, trailer)
+	}
+	if !bytes.Contains(r.body, content) {
+		t.Errorf("body = %q does not carry the content", r.body)
+	}
+}
+
+func TestS3Sink_TrailingChecksumFraming(t *testing.T) {
+	rec := newS3Recorder(t)
+	content := []byte("piped with a trailing checksum")
+	f, _ := NewFromBytes(content)
+
+	if _, err := Pipe(context.Background(), f, &S3Sink{Bucket: "bucket", Key: "k", TrailingChecksum: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.reqs) != 1 {
+		t.Fatalf("requests = %d", len(rec.reqs))
+	}
+	assertTrailingChecksum(t, rec.reqs[0], content)
+}
+
+func TestUploadToS3_TrailingChecksumFraming(t *testing.T) {
+	rec := newS3Recorder(t)
+	content := []byte("uploaded with a trailing checksum")
+	f, _ := NewFromBytes(content)
+
+	if err := f.UploadToS3("bucket", "k", WithTrailingChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.reqs) != 1 {
+		t.Fatalf("requests = %d", len(rec.reqs))
+	}
+	assertTrailingChecksum(t, rec.reqs[0], content)
+}
+
+func TestS3Sink_TrailingChecksumMultipart(t *testing.T) {
+	fake := newFakeMultipartS3()
+	client := fake.client()
+	var (
+		createAlgo types.ChecksumAlgorithm
+		partAlgos  []types.ChecksumAlgorithm
+		completed  []types.CompletedPart
+	)
+	create, upload := client.createMultipartUploadFn, client.uploadPartFn
+	client.createMultipartUploadFn = func(ctx context.Context, in *s3.CreateMultipartUploadInput, o ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+		createAlgo = in.ChecksumAlgorithm
+		return create(ctx, in, o...)
+	}
+	client.uploadPartFn = func(ctx context.Context, in *s3.UploadPartInput, o ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+		partAlgos = append(partAlgos, in.ChecksumAlgorithm)
+		return upload(ctx, in, o...)
+	}
+	client.completeMultipartUploadFn = func(ctx context.Context, in *s3.CompleteMultipartUploadInput, o ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		completed = in.MultipartUpload.Parts
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+	defer setMockS3(client, &mockPresignClient{})()
+
+	content := bytes.Repeat([]byte("0123456789"), (minPartSize+minPartSize/2)/10)
+	f, _ := NewFromBytes(content)
+	if _, err := Pipe(context.Background(), f, &S3Sink{Bucket: "bucket", Key: "k", PartSize: minPartSize, TrailingChecksum: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if createAlgo != types.ChecksumAlgorithmSha256 {
+		t.Errorf("CreateMultipartUpload ChecksumAlgorithm = %q", createAlgo)
+	}
+	if len(partAlgos) != 2 || partAlgos[0] != types.ChecksumAlgorithmSha256 || partAlgos[1] != types.ChecksumAlgorithmSha256 {
+		t.Errorf("UploadPart ChecksumAlgorithm = %v", partAlgos)
+	}
+	if len(completed) != 2 {
+		t.Fatalf("completed parts = %d", len(completed))
+	}
+	for i, chunk := range [][]byte{content[:minPartSize], content[minPartSize:]} {
+		sum := sha256.Sum256(chunk)
+		if got, want := aws.ToString(completed[i].ChecksumSHA256), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+			t.Errorf("part %d ChecksumSHA256 = %q, want %q", i+1, got, want)
+		}
+	}
+	for i, p := range completed {
+		if aws.ToString(p.ETag) == "" {
+			t.Errorf("part %d has no ETag", i+1)
+		}
+	}
+}
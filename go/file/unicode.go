@@ -0,0 +1,119 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// InvalidUTF8Error reports where content stops being valid UTF-8. It is
+// wrapped in an ErrNotText FileError.
+type InvalidUTF8Error struct {
+	// Offset is the position of the first byte that does not start, or
+	// continue, a valid UTF-8 sequence.
+	Offset int64
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("invalid UTF-8 at byte offset %d", e.Offset)
+}
+
+// ValidateUTF8 scans the content and fails with ErrNotText, wrapping an
+// *InvalidUTF8Error with the offset, at the first byte that is not valid
+// UTF-8. A sequence cut off by the end of the content is invalid. The
+// content is streamed as Checksum streams it, so large lazy files are not
+// buffered.
+func (f *File) ValidateUTF8() error {
+	r, err := f.contentReader(context.Background())
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, 32<<10)
+	var off int64
+	for {
+		c, size, err := br.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return newError(ErrRead, "ValidateUTF8", err)
+		}
+		if c == utf8.RuneError && size == 1 {
+			return newError(ErrNotText, "ValidateUTF8", &InvalidUTF8Error{Offset: off})
+		}
+		off += int64(size)
+	}
+}
+
+// NormalizeUnicode returns a new in-memory File holding the content
+// converted to form (norm.NFC, norm.NFD, norm.NFKC or norm.NFKD). Metadata
+// other than the size is carried over. Bytes that are not valid UTF-8 are
+// passed through unchanged; check with ValidateUTF8 first when that
+// matters. An unknown form fails with ErrInvalidOption.
+func (f *File) NormalizeUnicode(form norm.Form) (*File, error) {
+	if !validNormForm(form) {
+		return nil, newError(ErrInvalidOption, "NormalizeUnicode", fmt.Errorf("unknown normalization form %d", form))
+	}
+	r, err := f.openStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	data, err := io.ReadAll(form.Reader(r))
+	if err != nil {
+		return nil, newError(ErrRead, "NormalizeUnicode", err)
+	}
+	out, err := NewFromBytes(data, MetadataHint{
+		Name:       f.meta.Name,
+		MimeType:   f.meta.MimeType,
+		Extension:  f.meta.Extension,
+		Attributes: f.meta.Attributes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.inheritProvenance(f)
+	out.inheritCreatedAt(f)
+	return out, nil
+}
+
+// WithNormalizeName makes the constructors normalize Metadata.Name to
+// form, whatever it was derived from (path, URL, Content-Disposition or
+// hint), so that a name uploaded from macOS, which decomposes accents
+// (NFD), matches the same name from Linux or Windows (NFC). norm.NFC, the
+// zero Form, is the usual choice. Only Name changes: Path, URL and S3 keys
+// keep their bytes, so keys derived from them do not move. An unknown form
+// is ignored, and NewFromURL rejects it with ErrInvalidOption.
+func WithNormalizeName(form norm.Form) ReadOption {
+	return readOption(func(o *readOptions) {
+		if !validNormForm(form) {
+			o.reject("WithNormalizeName", "unknown normalization form %d", form)
+			return
+		}
+		o.nameForm = &form
+	})
+}
+
+// normalizeName applies WithNormalizeName to a newly constructed f.
+func (o readOptions) normalizeName(f *File) *File {
+	if o.nameForm != nil {
+		f.meta.Name = o.nameForm.String(f.meta.Name)
+	}
+	return f
+}
+
+// validNormForm reports whether form is one of the four norm forms.
+func validNormForm(form norm.Form) bool {
+	switch form {
+	case norm.NFC, norm.NFD, norm.NFKC, norm.NFKD:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// nfdName is "café.txt" as macOS writes it: "e" followed by a combining
+// acute accent.
+const nfdName = "cafe\u0301.txt"
+
+func TestValidateUTF8(t *testing.T) {
+	valid, _ := NewFromBytes([]byte("héllo wörld ✓ �"))
+	if err := valid.ValidateUTF8(); err != nil {
+		t.Errorf("valid content: %v", err)
+	}
+
+	cases := map[string]struct {
+		data []byte
+		off  int64
+	}{
+		"bad continuation byte": {[]byte("ab\xc3\x28cd"), 2},
+		"stray continuation":    {[]byte("\x80abc"), 0},
+		"truncated at end":      {[]byte("abc\xe2\x82"), 3},
+	}
+	for name, tc := range cases {
+		f, _ := NewFromBytes(tc.data)
+		err := f.ValidateUTF8()
+		var ue *InvalidUTF8Error
+		if !errors.Is(err, ErrNotText) || !errors.As(err, &ue) || ue.Offset != tc.off {
+			t.Errorf("%s: err = %v, want ErrNotText at offset %d", name, err, tc.off)
+		}
+	}
+}
+
+func TestValidateUTF8_StreamsLazyFiles(t *testing.T) {
+	// Past the scanner's buffer, so the offset spans reads.
+	data := []byte(strings.Repeat("ü", 40000) + "\xff")
+	p := filepath.Join(t.TempDir(), "big.txt")
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFromFileLazy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ue *InvalidUTF8Error
+	if err := f.ValidateUTF8(); !errors.As(err, &ue) || ue.Offset != 80000 {
+		t.Errorf("err = %v, want offset 80000", err)
+	}
+	if f.Loaded() {
+		t.Error("ValidateUTF8 should not buffer a lazy file")
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	f, _ := NewFromBytes([]byte("cafe\u0301 nai\u0308ve"), MetadataHint{Name: "menu.txt", MimeType: "text/plain"})
+	nfc, err := f.NormalizeUnicode(norm.NFC)
+	if err != nil {
+		t.Fatalf("NormalizeUnicode: %v", err)
+	}
+	if data, _ := nfc.Read(); string(data) != "caf\u00e9 na\u00efve" {
+		t.Errorf("NFC content = %q", data)
+	}
+	if nfc.Name() != "menu.txt" || !strings.HasPrefix(nfc.MimeType(), "text/plain") || nfc.Size() != int64(len("caf\u00e9 na\u00efve")) {
+		t.Errorf("metadata = %+v", nfc.Metadata())
+	}
+	nfd, _ := nfc.NormalizeUnicode(norm.NFD)
+	if data, _ := nfd.Read(); string(data) != "cafe\u0301 nai\u0308ve" {
+		t.Errorf("NFD content = %q", data)
+	}
+	if _, err := f.NormalizeUnicode(norm.Form(9)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unknown form: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestWithNormalizeName(t *testing.T) {
+	p := filepath.Join(t.TempDir(), nfdName)
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, _ := NewFromFile(p)
+	if plain.Name() != nfdName {
+		t.Errorf("without the option the name must keep its bytes: %q", plain.Name())
+	}
+	f, err := NewFromFile(p, WithNormalizeName(norm.NFC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "caf\u00e9.txt" || f.Path() != p {
+		t.Errorf("Name() = %q, Path() = %q; want the NFC name and the original path", f.Name(), f.Path())
+	}
+
+	b, _ := NewFromBytes([]byte("x"), MetadataHint{Name: nfdName}, WithNormalizeName(norm.NFC))
+	if b.Name() != "caf\u00e9.txt" {
+		t.Errorf("hinted name = %q", b.Name())
+	}
+	if _, err := NewFromURL("http://example.invalid", WithNormalizeName(norm.Form(7))); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unknown form: err = %v, want ErrInvalidOption", err)
+	}
+}
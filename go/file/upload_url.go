@@ -0,0 +1,173 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// uploadURLAttempts is how many times UploadToURL tries a request that fails
+// with a transport error or a 5xx response.
+const uploadURLAttempts = 3
+
+// errOneShotBody is returned by a body source that cannot be replayed.
+var errOneShotBody = errors.New("body is a one-shot stream and cannot be re-read for a retry; buffer it with Read() or use NewFromReaderAt")
+
+// UploadToURL uploads the file with an HTTP PUT to rawURL, such as one
+// returned by CreatePresignedUploadURL.
+func (f *File) UploadToURL(rawURL string, opts ...UploadOption) error {
+	return f.UploadToURLWithContext(context.Background(), rawURL, opts...)
+}
+
+// UploadToURLWithContext uploads the file with an HTTP PUT using the given
+// context. Transport errors and 5xx responses are retried, each attempt
+// sending the body from the start: buffered content is rewound, file
+// sources are re-opened, and ReaderAt sources get a fresh section. The same
+// rewindable body backs the request's GetBody, so net/http's own retries are
+// safe too. Lazy streams can only be sent once, so a retry of one fails
+// with an ErrHTTP error saying why. When ctx has no deadline,
+// UploadTimeout bounds each wait for the server to take more of the body or
+// to answer, not the whole upload.
+//
+// With WithTrailingChecksum the body is sent with chunked encoding and a
+// TrailerContentSHA256 trailer. The other UploadOptions do not apply to URL
+// uploads and fail with ErrInvalidOption.
+func (f *File) UploadToURLWithContext(ctx context.Context, rawURL string, opts ...UploadOption) error {
+	o := newUploadOptions(opts)
+	o.rejectUnsupported("UploadToURL", opts, "WithTrailingChecksum")
+	if err := checkOptions("UploadToURL", o.validate()); err != nil {
+		return err
+	}
+	if err := checkNetwork(ctx, "UploadToURL"); err != nil {
+		return err
+	}
+
+	ctx, cancel, dl, watch := withStreamTimeout(ctx, UploadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassUpload)
+	if err != nil {
+		return dl.newError(ctx, ErrHTTP, "UploadToURL", err)
+	}
+	defer release()
+	watch.pause()
+
+	getBody, size, err := f.bodySource(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < uploadURLAttempts; attempt++ {
+		body, err := getBody()
+		if err != nil {
+			if lastErr != nil {
+				err = fmt.Errorf("%w (after: %v)", err, lastErr)
+			}
+			return newError(ErrHTTP, "UploadToURL", err)
+		}
+		watch.kick()
+		sent := &countingReader{r: watch.pulled(body)}
+		var reqBody io.ReadCloser = io.NopCloser(sent)
+		var trailer http.Header
+		if o.trailingChecksum {
+			trailer = http.Header{TrailerContentSHA256: nil}
+			reqBody = newTrailingSHA256(reqBody, trailer)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, rawURL, reqBody)
+		if err != nil {
+			body.Close()
+			return newError(ErrHTTP, "UploadToURL", err)
+		}
+		req.GetBody = getBody
+		req.ContentLength = size
+		if trailer != nil {
+			// Trailers need chunked encoding, and a replayed body must
+			// produce the trailer again.
+			req.Trailer = trailer
+			req.ContentLength = -1
+			req.GetBody = func() (io.ReadCloser, error) {
+				rc, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newTrailingSHA256(rc, trailer), nil
+			}
+		}
+		defer body.Close()
+		if f.meta.MimeType != "" {
+			req.Header.Set("Content-Type", f.meta.MimeType)
+		}
+
+		resp, err := CurrentHTTPClient().Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return dl.newError(ctx, ErrHTTP, "UploadToURL", err)
+			}
+			lastErr = err
+			continue
+		}
+		var statusErr *FileError
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			statusErr = httpStatusError("UploadToURL", resp)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			lastErr = statusErr
+			continue
+		}
+		if statusErr != nil {
+			return statusErr
+		}
+		f.transferSize = sent.n
+		return nil
+	}
+	return newError(ErrHTTP, "UploadToURL", fmt.Errorf("giving up after %d attempts: %w", uploadURLAttempts, lastErr))
+}
+
+// bodySource returns a function that yields a fresh reader over the full
+// content on each call, and the content length (-1 when unknown). Lazy
+// streams yield their reader once and errOneShotBody afterwards. Content not
+// loaded yet is fetched under ctx.
+func (f *File) bodySource(ctx context.Context) (func() (io.ReadCloser, error), int64, error) {
+	switch {
+	case f.lazy && f.streamHead != nil:
+		head, tail := f.streamHead, f.streamTail
+		used := false
+		// The stream is consumed by the upload, as with UploadToS3.
+		f.streamHead, f.streamTail, f.lazy = nil, nil, false
+		return func() (io.ReadCloser, error) {
+			if used {
+				return nil, errOneShotBody
+			}
+			used = true
+			return io.NopCloser(io.MultiReader(bytes.NewReader(head), tail)), nil
+		}, -1, nil
+	case f.readerAt != nil && !f.loaded:
+		return func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(f.readerAt, 0, f.readerAtSize)), nil
+		}, f.readerAtSize, nil
+	case f.source == SourceFile && f.meta.Path != "":
+		path := f.meta.Path
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, 0, newError(ErrRead, "UploadToURL", err)
+		}
+		return func() (io.ReadCloser, error) {
+			return os.Open(path)
+		}, info.Size(), nil
+	default:
+		data, err := f.load(ctx, "UploadToURL")
+		if err != nil {
+			return nil, 0, err
+		}
+		return func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}, int64(len(data)), nil
+	}
+}
@@ -0,0 +1,165 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// flakyUploadServer drops the connection after reading part of the first
+// request's body, then records the bodies of later attempts.
+func flakyUploadServer(t *testing.T) (*httptest.Server, func() [][]byte) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		attempts int
+		bodies   [][]byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+
+		if first {
+			io.CopyN(io.Discard, r.Body, 4)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	return srv, func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+}
+
+func TestUploadToURL_RetryResendsFullBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("retry-safe body "), 4096)
+
+	path := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(path, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]func() (*File, error){
+		"bytes":    func() (*File, error) { return NewFromBytes(payload) },
+		"file":     func() (*File, error) { return NewFromFile(path) },
+		"readerAt": func() (*File, error) { return NewFromReaderAt(bytes.NewReader(payload), int64(len(payload))) },
+	}
+	for name, newFile := range sources {
+		t.Run(name, func(t *testing.T) {
+			srv, bodies := flakyUploadServer(t)
+			defer srv.Close()
+			cleanup := setMockHTTP(srv.Client())
+			defer cleanup()
+
+			f, err := newFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.UploadToURL(srv.URL + "/upload"); err != nil {
+				t.Fatalf("UploadToURL: %v", err)
+			}
+			got := bodies()
+			if len(got) != 1 {
+				t.Fatalf("successful attempts = %d, want 1", len(got))
+			}
+			if !bytes.Equal(got[0], payload) {
+				t.Errorf("retried body is %d bytes, want the full %d-byte payload", len(got[0]), len(payload))
+			}
+		})
+	}
+}
+
+func TestUploadToURL_OneShotStreamCannotRetry(t *testing.T) {
+	srv, _ := flakyUploadServer(t)
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	f, _ := NewFromStreamLazy(bytes.NewReader(bytes.Repeat([]byte("x"), 2*streamHeadBytes)))
+	err := f.UploadToURL(srv.URL)
+	if !errors.Is(err, ErrHTTP) {
+		t.Fatalf("expected ErrHTTP, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "one-shot") {
+		t.Errorf("error %q should explain the body cannot be replayed", err)
+	}
+}
+
+func TestUploadToURL_ClientErrorNotRetried(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	f, _ := NewFromBytes([]byte("data"), MetadataHint{MimeType: "text/plain"})
+	err := f.UploadToURL(srv.URL)
+	if !errors.Is(err, ErrHTTP) {
+		t.Fatalf("expected ErrHTTP, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestUploadToURL_ServerErrorRetriesThenGivesUp(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	cleanup := setMockHTTP(srv.Client())
+	defer cleanup()
+
+	f, _ := NewFromBytes([]byte("data"))
+	if err := f.UploadToURL(srv.URL); !errors.Is(err, ErrHTTP) {
+		t.Fatalf("expected ErrHTTP, got %v", err)
+	}
+	if attempts != uploadURLAttempts {
+		t.Errorf("attempts = %d, want %d", attempts, uploadURLAttempts)
+	}
+}
+
+func TestUploadToURL_RejectsS3Options(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { attempts++ }))
+	defer srv.Close()
+
+	f, _ := NewFromBytes([]byte("data"))
+	err := f.UploadToURL(srv.URL, WithTrailingChecksum(), WithStorageClass(types.StorageClassGlacier))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("err = %v, want ErrInvalidOption", err)
+	}
+	if !strings.Contains(err.Error(), "WithStorageClass") {
+		t.Errorf("err = %q, want it to name WithStorageClass", err)
+	}
+	if attempts != 0 {
+		t.Errorf("server called %d times", attempts)
+	}
+}
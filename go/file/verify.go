@@ -0,0 +1,245 @@
+package file
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Algorithm identifies a content digest algorithm.
+type Algorithm string
+
+const (
+	// AlgorithmSHA256 is SHA-256, the algorithm used by Checksum and by S3's
+	// ChecksumSHA256 field.
+	AlgorithmSHA256 Algorithm = "sha256"
+	// AlgorithmMD5 is MD5, the algorithm used by the HTTP Content-MD5 header.
+	AlgorithmMD5 Algorithm = "md5"
+)
+
+// newHash returns a fresh hash.Hash for the algorithm.
+func (a Algorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case AlgorithmSHA256:
+		return sha256.New(), nil
+	case AlgorithmMD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", a)
+	}
+}
+
+// VerifyMode controls how WithVerify confirms a written file is intact.
+type VerifyMode int
+
+const (
+	// VerifyReread hashes the bytes as they stream to disk, then re-reads the
+	// written file and compares the two digests. When the source published a
+	// digest for the same algorithm (S3 ChecksumSHA256, HTTP Content-MD5) the
+	// streamed digest is compared against it as well. This is the default and
+	// catches corruption both in transit and on the way to disk.
+	VerifyReread VerifyMode = iota
+
+	// VerifySourceDigest skips the re-read: the write is fsync'd and the
+	// streamed digest is compared against the source-published digest only.
+	// This halves the disk I/O for large files but trusts the filesystem once
+	// fsync returns. When no source digest is available for the algorithm it
+	// falls back to VerifyReread.
+	VerifySourceDigest
+)
+
+// wrapVerifyWriter wraps the destination writer used by verified writes.
+// Tests replace it to inject corruption between the hasher and the disk.
+var wrapVerifyWriter = func(w io.Writer) io.Writer { return w }
+
+// DownloadS3ToFile streams an S3 object straight to destPath without
+// buffering it in memory, then returns a File for the written path whose
+// provenance records the S3 object it came from. Pass WithVerify to check
+// the written bytes; for AlgorithmSHA256 the request enables S3 checksum
+// mode so the object's stored ChecksumSHA256 (when present) is compared too.
+// WithCollisionStrategy decides what happens when destPath is taken, as
+// for Save, and the returned File reads the written path lazily.
+// DownloadTimeout bounds the request and each wait for more of the body
+// when ctx has no deadline.
+func DownloadS3ToFile(ctx context.Context, bucket, key, destPath string, opts ...SaveOption) (*File, error) {
+	o := newSaveOptions(opts)
+	if err := checkOptions("DownloadS3ToFile", o.validate()); err != nil {
+		return nil, err
+	}
+	key, err := checkS3Object("DownloadS3ToFile", bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	destPath, err = o.resolveDest(destPath, "DownloadS3ToFile")
+	if err != nil {
+		return nil, err
+	}
+
+	if o.collision == CollisionErrorOut {
+		// Fail before fetching anything; claimPath checks again below.
+		if _, err := os.Lstat(destPath); err == nil {
+			return nil, newError(ErrExists, "DownloadS3ToFile", fmt.Errorf("%s already exists", destPath))
+		}
+	}
+
+	ctx, cancel, dl, watch := withStreamTimeout(ctx, DownloadTimeout())
+	defer cancel()
+
+	ctx, release, err := acquireTransfer(ctx, ClassDownload)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, "DownloadS3ToFile", err)
+	}
+	defer release()
+
+	s3Client, _ := s3Clients()
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if o.verify && o.algo == AlgorithmSHA256 {
+		input.ChecksumMode = types.ChecksumModeEnabled
+	}
+	out, err := s3Client.GetObject(ctx, input)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, "DownloadS3ToFile", err)
+	}
+	defer out.Body.Close()
+
+	if err := o.mkdirAll(filepath.Dir(destPath)); err != nil {
+		return nil, newError(ErrWrite, "DownloadS3ToFile", err)
+	}
+	destPath, err = claimPath(destPath, o.collision, "DownloadS3ToFile")
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceDigest string
+	if o.algo == AlgorithmSHA256 && out.ChecksumSHA256 != nil {
+		sourceDigest = base64DigestToHex(*out.ChecksumSHA256)
+	}
+	wire := &countingReader{r: watch.source(out.Body)}
+	if err := writeFromReader(wire, destPath, o, sourceDigest, "DownloadS3ToFile"); err != nil {
+		if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+			return nil, dl.newError(ctx, ErrRead, "DownloadS3ToFile", err)
+		}
+		return nil, err
+	}
+	saved, err := NewFromFileLazy(destPath)
+	if err != nil {
+		return nil, err
+	}
+	saved.inheritProvenance(withProvenance(&File{source: SourceS3, s3Bucket: bucket, s3Key: key}))
+	saved.transferSize = wire.n
+	return saved, nil
+}
+
+// writeFromReader copies r to destPath. When o.verify is set the stream is
+// teed through a hasher and checked according to o.verifyMode; on mismatch
+// the destination is removed and an ErrChecksumMismatch error is returned.
+// A copy that fails part way removes the partial destination too.
+func writeFromReader(r io.Reader, destPath string, o saveOptions, sourceDigest, op string) error {
+	out, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return newError(ErrWrite, op, err)
+	}
+
+	if !o.verify {
+		if _, err := io.Copy(out, r); err != nil {
+			_ = out.Close()
+			_ = os.Remove(destPath)
+			return newError(ErrWrite, op, err)
+		}
+		if err := out.Close(); err != nil {
+			_ = os.Remove(destPath)
+			return newError(ErrWrite, op, err)
+		}
+		return nil
+	}
+
+	h, err := o.algo.newHash()
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(destPath)
+		return newError(ErrInvalidOption, op, err)
+	}
+	if _, err := io.Copy(wrapVerifyWriter(out), io.TeeReader(r, h)); err != nil {
+		_ = out.Close()
+		_ = os.Remove(destPath)
+		return newError(ErrWrite, op, err)
+	}
+	streamed := hex.EncodeToString(h.Sum(nil))
+
+	skipReread := o.verifyMode == VerifySourceDigest && sourceDigest != ""
+	if skipReread {
+		if err := out.Sync(); err != nil {
+			_ = out.Close()
+			_ = os.Remove(destPath)
+			return newError(ErrWrite, op, err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(destPath)
+		return newError(ErrWrite, op, err)
+	}
+
+	if sourceDigest != "" && streamed != sourceDigest {
+		_ = os.Remove(destPath)
+		return newError(ErrChecksumMismatch, op, fmt.Errorf("%s of received bytes %s does not match source digest %s", o.algo, streamed, sourceDigest))
+	}
+	if skipReread {
+		return nil
+	}
+
+	written, err := hashFile(destPath, o.algo)
+	if err != nil {
+		_ = os.Remove(destPath)
+		return newError(ErrRead, op, err)
+	}
+	if written != streamed {
+		_ = os.Remove(destPath)
+		return newError(ErrChecksumMismatch, op, fmt.Errorf("%s of written file %s does not match streamed digest %s", o.algo, written, streamed))
+	}
+	return nil
+}
+
+// hashFile returns the hex digest of the file at p.
+func hashFile(p string, algo Algorithm) (string, error) {
+	h, err := algo.newHash()
+	if err != nil {
+		return "", err
+	}
+	fl, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer fl.Close()
+	if _, err := io.Copy(h, fl); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// base64DigestToHex converts a base64-encoded digest (the encoding used by
+// Content-MD5 and S3 checksum fields) to lowercase hex. Returns "" when the
+// value is not plain base64 — S3 composite multipart checksums carry a
+// "-<parts>" suffix and can't be compared against a whole-object digest.
+func base64DigestToHex(s string) string {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(raw)
+}
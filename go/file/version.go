@@ -1,4 +1,55 @@
 package file
 
-// Version is the current version of the smooai-file Go package.
-const Version = "1.1.5"
+import (
+	"slices"
+	"strings"
+)
+
+// version is the current version of the smooai-file Go package. The
+// release tooling keeps it in sync with package.json; a build can stamp
+// its own with
+//
+//	-ldflags "-X github.com/SmooAI/file/go/file.version=1.2.3-rc1"
+var version = "1.1.5"
+
+// Version returns the version of the smooai-file Go package linked into
+// the program.
+func Version() string { return version }
+
+// Built-in capabilities reported by Capabilities.
+const (
+	CapabilityLazyFile        = "lazy-file"        // NewFromFileLazy
+	CapabilityLazyStream      = "lazy-stream"      // NewFromStreamLazy
+	CapabilityMultipartUpload = "multipart-upload" // Pipe into an S3Sink
+	CapabilityRangeRead       = "range-read"       // ReadRange and Open
+)
+
+// storageCapabilityNames names the backends whose URI scheme is not the
+// name the other smooai-file SDKs report them under.
+var storageCapabilityNames = map[string]string{"gs": "gcs"}
+
+// Capabilities returns the features available in this program, sorted, for
+// diagnostics and for comparing binaries. Besides the built-in
+// capabilities it lists each registered codec by name ("gzip", "zstd",
+// "brotli") and each registered Storage backend as "storage-" plus its
+// name ("storage-gcs", "storage-sftp"), so optional subpackages show up
+// only once linked in and registered.
+func Capabilities() []string {
+	caps := []string{CapabilityLazyFile, CapabilityLazyStream, CapabilityMultipartUpload, CapabilityRangeRead}
+	codecMu.RLock()
+	for codec := range compressors {
+		caps = append(caps, string(codec))
+	}
+	codecMu.RUnlock()
+	storageMu.RLock()
+	for scheme := range storageBackends {
+		scheme = strings.ToLower(scheme)
+		if name, ok := storageCapabilityNames[scheme]; ok {
+			scheme = name
+		}
+		caps = append(caps, "storage-"+scheme)
+	}
+	storageMu.RUnlock()
+	slices.Sort(caps)
+	return caps
+}
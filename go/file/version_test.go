@@ -0,0 +1,51 @@
+package file
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if !regexp.MustCompile(`^\d+\.\d+\.\d+`).MatchString(Version()) {
+		t.Errorf("Version() = %q, want a semantic version", Version())
+	}
+}
+
+func TestCapabilities_FollowRegistrations(t *testing.T) {
+	caps := Capabilities()
+	if !slices.IsSorted(caps) {
+		t.Errorf("Capabilities not sorted: %v", caps)
+	}
+	for _, want := range []string{CapabilityMultipartUpload, CapabilityLazyFile, CapabilityRangeRead, "gzip"} {
+		if !slices.Contains(caps, want) {
+			t.Errorf("Capabilities %v missing %q", caps, want)
+		}
+	}
+	// The zstd, brotli and storage subpackages are not linked into this
+	// test binary, so nothing of theirs may be reported.
+	for _, absent := range []string{"zstd", "brotli", "storage-gcs", "storage-sftp"} {
+		if slices.Contains(caps, absent) {
+			t.Errorf("Capabilities %v reports unlinked %q", caps, absent)
+		}
+	}
+
+	RegisterCodec(CodecZstd, gzipCompressor{})
+	RegisterStorage("MEMCAP", newMemStorage())
+	caps = Capabilities()
+	RegisterCodec(CodecZstd, nil)
+	RegisterStorage("memcap", nil)
+	if !slices.Contains(caps, "zstd") || !slices.Contains(caps, "storage-memcap") {
+		t.Errorf("Capabilities after registering = %v", caps)
+	}
+	if caps = Capabilities(); slices.Contains(caps, "zstd") || slices.Contains(caps, "storage-memcap") {
+		t.Errorf("Capabilities after unregistering = %v", caps)
+	}
+
+	RegisterStorage("gs", newMemStorage())
+	caps = Capabilities()
+	RegisterStorage("gs", nil)
+	if !slices.Contains(caps, "storage-gcs") || slices.Contains(caps, "storage-gs") {
+		t.Errorf("a gs:// backend should report as storage-gcs: %v", caps)
+	}
+}
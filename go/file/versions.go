@@ -0,0 +1,140 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// VersionInfo describes one version of an S3 object, as ListS3Versions
+// returns it.
+type VersionInfo struct {
+	// VersionID identifies the version; pass it to WithVersionID to read
+	// it. Objects written before versioning was enabled have "null".
+	VersionID string
+	// LastModified is when the version was written, or when the object
+	// was deleted for a delete marker.
+	LastModified time.Time
+	// Size is the version's size in bytes; zero for delete markers.
+	Size int64
+	// IsLatest reports whether this is the object's current version.
+	IsLatest bool
+	// IsDeleteMarker reports whether the version records a deletion
+	// rather than content.
+	IsDeleteMarker bool
+}
+
+// WithVersionID makes NewFromS3 read the given version of the object
+// instead of the latest one. The ID is the Metadata.VersionID of a File
+// read earlier, or a VersionInfo.VersionID from ListS3Versions. An empty
+// id reads the latest version.
+func WithVersionID(id string) ReadOption {
+	return readOption(func(o *readOptions) { o.versionID = id })
+}
+
+// ListS3Versions returns every version of the object at key, delete
+// markers included, newest first. The bucket must have versioning enabled
+// (or have had it) for there to be more than one. Listing is paginated,
+// each page under MetadataTimeout when ctx has no deadline; a failed
+// request fails with ErrS3. A key with no versions returns an empty list.
+func ListS3Versions(ctx context.Context, bucket, key string) ([]VersionInfo, error) {
+	key, err := checkS3Object("ListS3Versions", bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	s3Client, _ := s3Clients()
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	}
+	var versions []VersionInfo
+	for {
+		page, err := listS3VersionsPage(ctx, s3Client, input)
+		if err != nil {
+			return nil, err
+		}
+		// The prefix also matches longer keys ("config.json.bak").
+		for _, v := range page.Versions {
+			if aws.ToString(v.Key) == key {
+				versions = append(versions, VersionInfo{
+					VersionID:    aws.ToString(v.VersionId),
+					LastModified: aws.ToTime(v.LastModified),
+					Size:         aws.ToInt64(v.Size),
+					IsLatest:     aws.ToBool(v.IsLatest),
+				})
+			}
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.ToString(m.Key) == key {
+				versions = append(versions, VersionInfo{
+					VersionID:      aws.ToString(m.VersionId),
+					LastModified:   aws.ToTime(m.LastModified),
+					IsLatest:       aws.ToBool(m.IsLatest),
+					IsDeleteMarker: true,
+				})
+			}
+		}
+		if !aws.ToBool(page.IsTruncated) || (page.NextKeyMarker == nil && page.NextVersionIdMarker == nil) {
+			break
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+	// S3 lists versions and delete markers separately; merge them newest
+	// first, keeping the current version ahead of others written in the
+	// same second.
+	slices.SortStableFunc(versions, func(a, b VersionInfo) int {
+		if c := b.LastModified.Compare(a.LastModified); c != 0 {
+			return c
+		}
+		switch {
+		case a.IsLatest && !b.IsLatest:
+			return -1
+		case b.IsLatest && !a.IsLatest:
+			return 1
+		}
+		return 0
+	})
+	return versions, nil
+}
+
+// listS3VersionsPage fetches one ListObjectVersions page under
+// MetadataTimeout.
+func listS3VersionsPage(ctx context.Context, s3Client S3API, input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	ctx, cancel, dl := withDefaultTimeout(ctx, MetadataTimeout())
+	defer cancel()
+	page, err := s3Client.ListObjectVersions(ctx, input)
+	if err != nil {
+		return nil, dl.newError(ctx, ErrS3, "ListS3Versions", err)
+	}
+	return page, nil
+}
+
+// NewFromS3AtTime reads the object at key as it was at the given time: the
+// newest version written at or before at, read with NewFromS3WithContext
+// and WithVersionID, so the File's Metadata.VersionID names it. It fails
+// with ErrNotFound when the object did not exist then, either because no
+// version precedes at or because the newest one that does is a delete
+// marker. opts apply as they do to NewFromS3.
+func NewFromS3AtTime(ctx context.Context, bucket, key string, at time.Time, opts ...ReadOption) (*File, error) {
+	versions, err := ListS3Versions(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.LastModified.After(at) {
+			continue
+		}
+		if v.IsDeleteMarker {
+			return nil, newError(ErrNotFound, "NewFromS3AtTime",
+				fmt.Errorf("s3://%s/%s was deleted at %s, before %s", bucket, key, v.LastModified.Format(time.RFC3339), at.Format(time.RFC3339)))
+		}
+		return NewFromS3WithContext(ctx, bucket, key, append(slices.Clip(opts), WithVersionID(v.VersionID))...)
+	}
+	return nil, newError(ErrNotFound, "NewFromS3AtTime",
+		fmt.Errorf("s3://%s/%s has no version at or before %s", bucket, key, at.Format(time.RFC3339)))
+}
@@ -0,0 +1,133 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// versionHistory mocks config.json written on day 1, rewritten on day 3,
+// deleted on day 5 and recreated on day 7, plus a config.json.bak that
+// shares its prefix. Listing is split over two pages.
+func versionHistory(t *testing.T) (*mockS3Client, *[]string) {
+	t.Helper()
+	day := func(d int) *time.Time { return aws.Time(time.Date(2024, 6, d, 12, 0, 0, 0, time.UTC)) }
+	pages := []*s3.ListObjectVersionsOutput{
+		{
+			Versions: []types.ObjectVersion{
+				{Key: aws.String("config.json"), VersionId: aws.String("v4"), LastModified: day(7), Size: aws.Int64(4), IsLatest: aws.Bool(true)},
+				{Key: aws.String("config.json"), VersionId: aws.String("v2"), LastModified: day(3), Size: aws.Int64(2)},
+			},
+			DeleteMarkers: []types.DeleteMarkerEntry{
+				{Key: aws.String("config.json"), VersionId: aws.String("v3"), LastModified: day(5)},
+			},
+			IsTruncated:         aws.Bool(true),
+			NextKeyMarker:       aws.String("config.json"),
+			NextVersionIdMarker: aws.String("v2"),
+		},
+		{
+			Versions: []types.ObjectVersion{
+				{Key: aws.String("config.json"), VersionId: aws.String("v1"), LastModified: day(1), Size: aws.Int64(1)},
+				{Key: aws.String("config.json.bak"), VersionId: aws.String("b1"), LastModified: day(2), Size: aws.Int64(9), IsLatest: aws.Bool(true)},
+			},
+			IsTruncated: aws.Bool(false),
+		},
+	}
+	bodies := map[string]string{"v1": `{"v":1}`, "v2": `{"v":2}`, "v4": `{"v":4}`}
+	var fetched []string
+	return &mockS3Client{
+		listObjectVersionsFn: func(_ context.Context, in *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+			if aws.ToString(in.Prefix) != "config.json" {
+				t.Errorf("Prefix = %q", aws.ToString(in.Prefix))
+			}
+			if in.VersionIdMarker == nil {
+				return pages[0], nil
+			}
+			if aws.ToString(in.KeyMarker) != "config.json" || aws.ToString(in.VersionIdMarker) != "v2" {
+				t.Errorf("markers = %q, %q", aws.ToString(in.KeyMarker), aws.ToString(in.VersionIdMarker))
+			}
+			return pages[1], nil
+		},
+		getObjectFn: func(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			v := aws.ToString(in.VersionId)
+			fetched = append(fetched, v)
+			body, ok := bodies[v]
+			if !ok {
+				return nil, &types.NoSuchKey{}
+			}
+			return &s3.GetObjectOutput{
+				Body:        io.NopCloser(strings.NewReader(body)),
+				ContentType: aws.String("application/json"),
+				VersionId:   aws.String(v),
+			}, nil
+		},
+	}, &fetched
+}
+
+func TestListS3Versions(t *testing.T) {
+	mock, _ := versionHistory(t)
+	defer setMockS3(mock, &mockPresignClient{})()
+
+	versions, err := ListS3Versions(context.Background(), "bucket", "config.json")
+	if err != nil {
+		t.Fatalf("ListS3Versions: %v", err)
+	}
+	var ids []string
+	for _, v := range versions {
+		ids = append(ids, v.VersionID)
+	}
+	if strings.Join(ids, ",") != "v4,v3,v2,v1" {
+		t.Fatalf("versions = %v, want v4,v3,v2,v1 without the .bak key", ids)
+	}
+	if !versions[0].IsLatest || versions[0].Size != 4 || !versions[1].IsDeleteMarker || versions[2].IsDeleteMarker {
+		t.Errorf("versions = %+v", versions)
+	}
+
+	if _, err := ListS3Versions(context.Background(), "Bad_Bucket", "k"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("invalid bucket: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestNewFromS3AtTime(t *testing.T) {
+	mock, fetched := versionHistory(t)
+	defer setMockS3(mock, &mockPresignClient{})()
+	at := func(d, h int) time.Time { return time.Date(2024, 6, d, h, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{at(2, 0), `{"v":1}`},
+		{at(3, 12), `{"v":2}`}, // written exactly then
+		{at(4, 0), `{"v":2}`},
+		{at(8, 0), `{"v":4}`},
+	}
+	for _, tc := range cases {
+		f, err := NewFromS3AtTime(context.Background(), "bucket", "config.json", tc.at)
+		if err != nil {
+			t.Fatalf("at %s: %v", tc.at, err)
+		}
+		if data, _ := f.Read(); string(data) != tc.want {
+			t.Errorf("at %s: content = %s, want %s", tc.at, data, tc.want)
+		}
+		if want := strings.TrimSuffix(strings.TrimPrefix(tc.want, `{"v":`), "}"); f.Metadata().VersionID != "v"+want {
+			t.Errorf("at %s: VersionID = %q", tc.at, f.Metadata().VersionID)
+		}
+	}
+
+	for _, when := range []time.Time{at(6, 0), at(1, 0)} {
+		if _, err := NewFromS3AtTime(context.Background(), "bucket", "config.json", when); !errors.Is(err, ErrNotFound) {
+			t.Errorf("at %s: err = %v, want ErrNotFound", when, err)
+		}
+	}
+	if got := strings.Join(*fetched, ","); got != "v1,v2,v2,v4" {
+		t.Errorf("fetched versions %s; deleted and missing times should not fetch", got)
+	}
+}
@@ -0,0 +1,47 @@
+// Package zstdcodec adds Zstandard support (file.CodecZstd) to the file
+// package.
+//
+// It is a separate module (github.com/SmooAI/file/go/file/zstdcodec) so
+// programs that never use zstd neither link the encoder nor carry it in
+// their module graph. Register it once at startup:
+//
+//	zstdcodec.Register()
+//
+// after which (*file.File).Compress and Decompress accept file.CodecZstd
+// and NewFromURL decodes "Content-Encoding: zstd" responses.
+package zstdcodec
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+// Compressor implements file.Compressor for Zstandard. Levels use the zstd
+// scale (1-22) and are mapped to the nearest encoder speed.
+type Compressor struct{}
+
+// Register registers a Compressor for file.CodecZstd.
+func Register() {
+	file.RegisterCodec(file.CodecZstd, Compressor{})
+}
+
+// NewWriter implements file.Compressor.
+func (Compressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	var opts []zstd.EOption
+	if level != 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	return zstd.NewWriter(w, opts...)
+}
+
+// NewReader implements file.Compressor.
+func (Compressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
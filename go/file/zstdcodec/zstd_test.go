@@ -0,0 +1,98 @@
+package zstdcodec
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	file "github.com/SmooAI/file/go/file"
+)
+
+var payload = []byte(strings.Repeat("archived log line\n", 500))
+
+func TestZstd_RoundTrip(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecZstd, nil)
+	if !slices.Contains(file.Capabilities(), "zstd") {
+		t.Errorf("Capabilities = %v, want zstd once registered", file.Capabilities())
+	}
+
+	for _, level := range []int{0, 1, 19} {
+		f, _ := file.NewFromBytes(payload, file.MetadataHint{Name: "app.log"})
+		z, err := f.Compress(file.CodecZstd, level)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if z.Name() != "app.log.zst" || z.Extension() != "zst" || z.MimeType() != "application/zstd" {
+			t.Errorf("level %d: metadata = %+v", level, z.Metadata())
+		}
+		back, err := z.Decompress()
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		data, _ := back.Read()
+		if !bytes.Equal(data, payload) || back.Name() != "app.log" {
+			t.Errorf("level %d: round trip mismatch (name %q)", level, back.Name())
+		}
+	}
+}
+
+func TestZstd_UnregisteredCodec(t *testing.T) {
+	file.RegisterCodec(file.CodecZstd, nil)
+	f, _ := file.NewFromBytes(payload)
+	if _, err := f.Compress(file.CodecZstd, 0); !errors.Is(err, file.ErrCompression) {
+		t.Errorf("expected ErrCompression, got %v", err)
+	}
+}
+
+func TestZstd_MislabelledContent(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecZstd, nil)
+
+	// Labelled .zst but not zstd data: the decoder must reject it.
+	f, _ := file.NewFromBytes([]byte{0x0b, 0x02, 0x80, 0x68, 0x69, 0x03}, file.MetadataHint{Name: "x.zst", Extension: "zst"})
+	if _, err := f.Decompress(); !errors.Is(err, file.ErrCompression) {
+		t.Errorf("expected ErrCompression, got %v", err)
+	}
+}
+
+func TestZstd_HTTPContentEncoding(t *testing.T) {
+	Register()
+	defer file.RegisterCodec(file.CodecZstd, nil)
+
+	var encoded bytes.Buffer
+	zw, _ := zstd.NewWriter(&encoded)
+	zw.Write(payload)
+	zw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "zstd") {
+			t.Errorf("Accept-Encoding = %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "zstd")
+		w.Write(encoded.Bytes())
+	}))
+	defer srv.Close()
+	orig := file.CurrentHTTPClient()
+	file.SetHTTPClient(srv.Client())
+	defer file.SetHTTPClient(orig)
+
+	f, err := file.NewFromURL(srv.URL + "/app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := f.Read()
+	if !bytes.Equal(data, payload) {
+		t.Error("response was not decoded")
+	}
+	if f.Size() != int64(len(payload)) || f.TransferSize() != int64(encoded.Len()) {
+		t.Errorf("Size = %d, TransferSize = %d", f.Size(), f.TransferSize())
+	}
+}
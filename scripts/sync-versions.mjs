@@ -29,8 +29,8 @@ const files = [
     },
     {
         path: join(rootDir, 'go', 'file', 'version.go'),
-        pattern: /const Version = ".*"/,
-        replacement: `const Version = "${version}"`,
+        pattern: /var version = ".*"/,
+        replacement: `var version = "${version}"`,
     },
     {
         path: join(rootDir, 'dotnet', 'src', 'SmooAI.File', 'SmooAI.File.csproj'),